@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+const (
+	// AppDeploymentCRKindLabel is the label with the kind of the CR deploying the app
+	AppDeploymentCRKindLabel = "appdeployment.enterprise.splunk.com/cr-kind"
+
+	// AppDeploymentCRNameLabel is the label with the name of the CR deploying the app
+	AppDeploymentCRNameLabel = "appdeployment.enterprise.splunk.com/cr-name"
+
+	// AppDeploymentAppSourceLabel is the label with the name of the App source of the app
+	AppDeploymentAppSourceLabel = "appdeployment.enterprise.splunk.com/app-source"
+
+	// AppDeploymentAppNameLabel is the label with the name of the app package, when it is a valid label value
+	AppDeploymentAppNameLabel = "appdeployment.enterprise.splunk.com/app-name"
+
+	// AppDeploymentVersionLabel is the label with the version of the app, when it is known and a valid label value
+	AppDeploymentVersionLabel = "appdeployment.enterprise.splunk.com/version"
+)
+
+// AppPodPhaseInfo represents the phase of an app on a pod
+type AppPodPhaseInfo struct {
+	// Name of the pod
+	PodName string `json:"podName"`
+
+	// Phase of the app on the pod
+	Phase AppPhaseType `json:"phase,omitempty"`
+
+	// Status code of the phase
+	Status AppPhaseStatusType `json:"status,omitempty"`
+
+	// Name of the status code, e.g. AppPkgInstallComplete
+	StatusName string `json:"statusName,omitempty"`
+
+	// Number of failures in the phase
+	FailCount uint32 `json:"failCount,omitempty"`
+}
+
+// AppDeploymentResourceStatus defines the deployment state of an app of a CR
+type AppDeploymentResourceStatus struct {
+	// Kind of the CR deploying the app
+	CRKind string `json:"crKind"`
+
+	// Name of the CR deploying the app
+	CRName string `json:"crName"`
+
+	// Name of the App source of the app
+	AppSourceName string `json:"appSourceName"`
+
+	// Name of the status code of the app, e.g. AppPkgInstallComplete
+	StatusName string `json:"statusName,omitempty"`
+
+	// Phase of the app on each pod, when the app is installed on every replica of the CR
+	Pods []AppPodPhaseInfo `json:"pods,omitempty"`
+
+	// Time of the last change of the deployment state, in epoch seconds
+	LastUpdateTime int64 `json:"lastUpdateTime,omitempty"`
+
+	// Deployment state of the app, as tracked by the App Framework
+	AppDeploymentInfo `json:",inline"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AppDeployment is the Schema for the deployment state of an app of a CR. It is managed by the operator,
+// and the changes made by the users are overwritten
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:path=appdeployments,scope=Namespaced,shortName=appdeploy
+// +kubebuilder:printcolumn:name="Kind",type="string",JSONPath=".status.crKind",description="Kind of the CR deploying the app"
+// +kubebuilder:printcolumn:name="CR",type="string",JSONPath=".status.crName",description="Name of the CR deploying the app"
+// +kubebuilder:printcolumn:name="App",type="string",JSONPath=".status.appName",description="Name of the app package"
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.version",description="Version of the app"
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.statusName",description="Status of the app deployment"
+// +kubebuilder:printcolumn:name="Hash",type="string",JSONPath=".status.objectHash",priority=1,description="Object hash of the app package"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.lastError",priority=1,description="Last error of the app deployment"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of app deployment"
+// +kubebuilder:storageversion
+type AppDeployment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status AppDeploymentResourceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AppDeploymentList contains a list of AppDeployment
+type AppDeploymentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppDeployment `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AppDeployment{}, &AppDeploymentList{})
+}
@@ -0,0 +1,113 @@
+/*
+Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+const (
+	// AppSourcePausedAnnotation is the annotation that pauses the reconciliation (triggers
+	// an immediate requeue)
+	AppSourcePausedAnnotation = "appsource.enterprise.splunk.com/paused"
+
+	// AppSourceAllNamespaces allows the CRs in all the namespaces to refer to an AppSource
+	AppSourceAllNamespaces = "*"
+)
+
+// SharedAppSourceSpec defines the desired state of an AppSource, shared by the App sources of many CRs
+type SharedAppSourceSpec struct {
+	// Remote storage volume holding the apps. The secret of the volume is read from the namespace of the AppSource
+	Volume VolumeSpec `json:"volume"`
+
+	// Location relative to the volume path
+	Location string `json:"location"`
+
+	// Scope of the apps, used when the referring App source doesn't set one
+	Scope string `json:"scope,omitempty"`
+
+	// Properties for premium apps, used when the referring App source doesn't set them
+	PremiumAppsProps PremiumAppsProps `json:"premiumAppsProps,omitempty"`
+
+	// Interval in seconds to check the remote storage for app changes. The referring CRs are notified of
+	// the changes, so, they don't need to poll the remote storage themselves. 0 turns off the polling
+	AppsRepoPollInterval int64 `json:"appsRepoPollIntervalSeconds,omitempty"`
+
+	// Namespaces, other than the namespace of the AppSource, whose CRs are allowed to refer to this AppSource.
+	// "*" allows all the namespaces
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+// SharedAppSourceStatus defines the observed state of an AppSource
+type SharedAppSourceStatus struct {
+	// current phase of the AppSource
+	Phase Phase `json:"phase"`
+
+	// Generation of the AppSource last listed
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Time of the last listing of the remote storage
+	LastListTime int64 `json:"lastListTime,omitempty"`
+
+	// Hash of the last listing of the remote storage
+	ListingHash string `json:"listingHash,omitempty"`
+
+	// Number of app packages in the last listing of the remote storage
+	AppCount int `json:"appCount,omitempty"`
+
+	// Error from the last listing of the remote storage, if any
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AppSource is the Schema for a remote app location shared by the App sources of many CRs
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=appsources,scope=Namespaced,shortName=appsrc
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of app source"
+// +kubebuilder:printcolumn:name="Apps",type="integer",JSONPath=".status.appCount",description="Number of app packages in the app source"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of app source"
+// +kubebuilder:storageversion
+type AppSource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SharedAppSourceSpec   `json:"spec,omitempty"`
+	Status SharedAppSourceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AppSourceList contains a list of AppSource
+type AppSourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppSource `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AppSource{}, &AppSourceList{})
+}
@@ -32,6 +32,9 @@ const (
 	// ClusterManagerPausedAnnotation is the annotation that pauses the reconciliation (triggers
 	// an immediate requeue)
 	ClusterManagerPausedAnnotation = "clustermanager.enterprise.splunk.com/paused"
+
+	// ClusterManagerRoleLabel is the label of the pods of redundant cluster managers, set to active or standby
+	ClusterManagerRoleLabel = "clustermanager.enterprise.splunk.com/role"
 )
 
 // ClusterManagerSpec defines the desired state of ClusterManager
@@ -43,6 +46,36 @@ type ClusterManagerSpec struct {
 
 	// Splunk Enterprise App repository. Specifies remote App location and scope for Splunk App management
 	AppFrameworkConfig AppFrameworkSpec `json:"appRepo,omitempty"`
+
+	// Number of cluster managers. With more than one, cluster manager redundancy is configured, with one active
+	// cluster manager and the others on standby. Requires Splunk Enterprise 9.0 or later. Defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// manager_switchover_mode of redundant cluster managers: auto or manual. Defaults to auto
+	// +kubebuilder:validation:Enum=auto;manual
+	// +optional
+	ManagerSwitchoverMode string `json:"managerSwitchoverMode,omitempty"`
+
+	// replication_factor of the indexer cluster. Defaults to the one configured on the cluster manager
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ReplicationFactor int32 `json:"replicationFactor,omitempty"`
+
+	// search_factor of the indexer cluster, not greater than replicationFactor. Defaults to the one configured on the cluster manager
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	SearchFactor int32 `json:"searchFactor,omitempty"`
+
+	// site_replication_factor of a multisite indexer cluster, e.g. origin:2,total:3. Defaults to the one configured on the cluster manager
+	// +optional
+	SiteReplicationFactor string `json:"siteReplicationFactor,omitempty"`
+
+	// site_search_factor of a multisite indexer cluster, e.g. origin:1,total:2. Defaults to the one configured on the cluster manager
+	// +optional
+	SiteSearchFactor string `json:"siteSearchFactor,omitempty"`
 }
 
 // ClusterManagerStatus defines the observed state of ClusterManager
@@ -67,6 +100,55 @@ type ClusterManagerStatus struct {
 
 	// Telemetry App installation flag
 	TelAppInstalled bool `json:"telAppInstalled"`
+
+	// name of the pod of the active cluster manager, when redundant
+	// +optional
+	ActiveManager string `json:"activeManager,omitempty"`
+
+	// redundancy state of each cluster manager, when redundant
+	// +optional
+	Managers []ClusterManagerMemberStatus `json:"managers,omitempty"`
+
+	// replication and search factors of the indexer cluster
+	// +optional
+	ClusterFactors ClusterFactorsStatus `json:"clusterFactors,omitempty"`
+
+	// VolumeSnapshots of the etc and var persistent volume claims
+	// +optional
+	VolumeSnapshots VolumeSnapshotStatus `json:"volumeSnapshots,omitempty"`
+}
+
+// ClusterFactorsStatus is used to track the replication and search factors of the indexer cluster
+type ClusterFactorsStatus struct {
+	// replication_factor reported by the cluster manager
+	ReplicationFactor int32 `json:"replicationFactor,omitempty"`
+
+	// search_factor reported by the cluster manager
+	SearchFactor int32 `json:"searchFactor,omitempty"`
+
+	// site_replication_factor reported by the cluster manager
+	SiteReplicationFactor string `json:"siteReplicationFactor,omitempty"`
+
+	// site_search_factor reported by the cluster manager
+	SiteSearchFactor string `json:"siteSearchFactor,omitempty"`
+
+	// factors last applied on the cluster manager
+	Applied string `json:"applied,omitempty"`
+
+	// time the factors were last applied on the cluster manager, in epoch seconds
+	AppliedTime int64 `json:"appliedTime,omitempty"`
+
+	// reason the factors last applied are not in effect, if any
+	Message string `json:"message,omitempty"`
+}
+
+// ClusterManagerMemberStatus is used to track the redundancy state of each cluster manager
+type ClusterManagerMemberStatus struct {
+	// name of the cluster manager pod
+	Name string `json:"name"`
+
+	// high availability mode of the cluster manager: Active or Standby
+	HAMode string `json:"haMode,omitempty"`
 }
 
 // BundlePushInfo Indicates if bundle push required
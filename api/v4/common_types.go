@@ -55,9 +55,23 @@ const (
 	ScopePremiumApps          = "premiumApps"
 )
 
+// Values to represent the App Framework scheduling priority of a CR
+const (
+	AppFrameworkPriorityHigh   = "high"
+	AppFrameworkPriorityNormal = "normal"
+	AppFrameworkPriorityLow    = "low"
+)
+
+// Values to represent how the app packages are transferred to the pods
+const (
+	AppTransferModeStaged = "staged"
+	AppTransferModeStream = "stream"
+)
+
 // Values to represent the properties for the scope premiumApps
 const (
-	PremiumAppsTypeEs = "enterpriseSecurity"
+	PremiumAppsTypeEs   = "enterpriseSecurity"
+	PremiumAppsTypeItsi = "itsi"
 )
 
 // Values to represent the defaults of enterprise security app
@@ -238,6 +252,58 @@ type CommonSplunkSpec struct {
 	// Sets imagePullSecrets if image is being pulled from a private registry.
 	// See https://kubernetes.io/docs/tasks/configure-pod-container/pull-image-private-registry/
 	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// SnapshotPolicy schedules CSI VolumeSnapshots of the etc and var persistent volume claims
+	// +optional
+	SnapshotPolicy *SnapshotPolicySpec `json:"snapshotPolicy,omitempty"`
+
+	// Name of a snapshot set the persistent volume claims are pre-populated from, when they are created
+	// +optional
+	RestoreFromSnapshotSet string `json:"restoreFromSnapshotSet,omitempty"`
+}
+
+// SnapshotPolicySpec defines the schedule of the VolumeSnapshots of the etc and var persistent volume claims
+type SnapshotPolicySpec struct {
+	// Interval in seconds between two snapshot sets
+	// +kubebuilder:validation:Minimum=300
+	Interval int64 `json:"intervalSeconds"`
+
+	// Name of the VolumeSnapshotClass of the snapshots, the default one of the CSI driver if not set
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+
+	// Number of snapshot sets to keep, older ones are deleted (default 7)
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Retention int32 `json:"retention,omitempty"`
+
+	// If true, no new snapshot set is taken
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// VolumeSnapshotStatus is used to track the snapshot sets of the etc and var persistent volume claims
+type VolumeSnapshotStatus struct {
+	// Snapshot set being taken
+	InProgress string `json:"inProgress,omitempty"`
+
+	// Time the snapshot set being taken was started
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// True while the Splunk instances are quiesced for the snapshot set being taken
+	Quiesced bool `json:"quiesced,omitempty"`
+
+	// Reason the snapshot set being taken, or else the last one, was taken without quiescing the Splunk instances
+	NotQuiescedReason string `json:"notQuiescedReason,omitempty"`
+
+	// Last snapshot set taken
+	LastSnapshotSet string `json:"lastSnapshotSet,omitempty"`
+
+	// Time the last snapshot set was taken
+	LastSnapshotTime int64 `json:"lastSnapshotTime,omitempty"`
+
+	// Error of the last attempt to take a snapshot set
+	Message string `json:"message,omitempty"`
 }
 
 // StorageClassSpec defines storage class configuration
@@ -299,19 +365,23 @@ type VolumeSpec struct {
 	// Remote volume name
 	Name string `json:"name"`
 
-	// Remote volume URI
+	// Remote volume URI. For git, this is the repository URL. For http, this is the base URL of the manifest
 	Endpoint string `json:"endpoint"`
 
-	// Remote volume path
+	// Remote volume path. For git, the first element of the path is the branch, tag or commit, unless the ref is set
 	Path string `json:"path"`
 
+	// Branch, tag or commit of the git repository to deploy the apps from. When set, the whole path is the directory
+	// of the apps in the repository. Used only for the git provider
+	Ref string `json:"ref,omitempty"`
+
 	// Secret object name
 	SecretRef string `json:"secretRef"`
 
-	// Remote Storage type. Supported values: s3, blob. s3 works with aws or minio providers, whereas blob works with azure provider.
+	// Remote Storage type. Supported values: s3, blob, git, http. s3 works with aws or minio providers, blob works with azure provider, git works with git provider, whereas http works with http provider.
 	Type string `json:"storageType"`
 
-	// App Package Remote Store provider. Supported values: aws, minio, azure.
+	// App Package Remote Store provider. Supported values: aws, minio, azure, git, http.
 	Provider string `json:"provider"`
 
 	// Region of the remote storage volume where apps reside. Used for aws, if provided. Not used for minio and azure.
@@ -375,13 +445,17 @@ type AppSourceDefaultSpec struct {
 
 // PremiumAppsProps represents properties for premium apps such as ES
 type PremiumAppsProps struct {
-	// Type: enterpriseSecurity for now, can accomodate itsi etc.. later
+	// Type of the premium app: enterpriseSecurity or itsi
 	// +optional
 	Type string `json:"type,omitempty"`
 
 	// Enterpreise Security App defaults
 	// +optional
 	EsDefaults EsDefaults `json:"esDefaults,omitempty"`
+
+	// IT Service Intelligence App defaults
+	// +optional
+	ItsiDefaults ItsiDefaults `json:"itsiDefaults,omitempty"`
 }
 
 // EsDefaults captures defaults for the Enterprise Security App
@@ -400,15 +474,78 @@ type EsDefaults struct {
 	SslEnablement string `json:"sslEnablement,omitempty"`
 }
 
+// ItsiDefaults captures defaults for the IT Service Intelligence App
+type ItsiDefaults struct {
+	// Max. time(in seconds) to wait for the KV store to be ready after installing ITSI on a standalone.
+	// ITSI keeps its configuration in the KV store, so its post install steps need the KV store to be ready.
+	// Defaults to 300 seconds if left empty.
+	//
+	// +optional
+	KvStoreReadyTimeout int32 `json:"kvStoreReadyTimeout,omitempty"`
+}
+
 // AppSourceSpec defines list of App package (*.spl, *.tgz) locations on remote volumes
 type AppSourceSpec struct {
 	// Logical name for the set of apps placed in this location. Logical name must be unique to the appRepo
 	Name string `json:"name"`
 
-	// Location relative to the volume path
+	// Location relative to the volume path. Taken from the AppSource, when the App source refers to a shared AppSource
 	Location string `json:"location"`
 
+	// Shared AppSource holding the volume and location of the apps. The volume name and location are taken
+	// from the AppSource, while the scope and premium apps properties default to those of the AppSource
+	// +optional
+	AppSourceRef *AppSourceReference `json:"appSourceRef,omitempty"`
+
 	AppSourceDefaultSpec `json:",inline"`
+
+	// Install order for the app packages in this app source. An app is installed on a pod only after the apps listed before it are installed on the same pod.
+	// Applicable only for local and premiumApps scopes
+	// +optional
+	InstallOrder []string `json:"installOrder,omitempty"`
+
+	// Install dependencies of the app packages in this app source. Applicable only for local and premiumApps scopes
+	// +optional
+	AppDependencies []AppDependencySpec `json:"appDependencies,omitempty"`
+
+	// ConfigMaps holding the local/ configuration files of the app packages in this app source. The files are added to the
+	// local/ folder of the app package before it is copied to the pods, and an update to the ConfigMap reinstalls the app
+	// +optional
+	AppConfigOverlays []AppConfigOverlaySpec `json:"appConfigOverlays,omitempty"`
+}
+
+// AppSourceReference refers to a shared AppSource
+type AppSourceReference struct {
+	// Name of the AppSource
+	Name string `json:"name"`
+
+	// Namespace of the AppSource. Defaults to the namespace of the CR
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// AppConfigOverlaySpec refers to a ConfigMap holding the local/ configuration files for an app package
+type AppConfigOverlaySpec struct {
+	// App package name, as present in the app source location
+	AppName string `json:"appName"`
+
+	// Name of the ConfigMap in the namespace of the CR. Each key is a file name under the local/ folder of the app, e.g. inputs.conf
+	ConfigMapName string `json:"configMapName"`
+}
+
+// AppDependencySpec defines the install constraints for an app package
+type AppDependencySpec struct {
+	// App package name, as present in the app source location
+	Name string `json:"name"`
+
+	// App packages to be installed on a pod before this app is installed on the same pod. An app of the same app source is listed by
+	// its name, and an app of another app source of the CR as <appSource>/<app>
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Restart Splunk on the pod after this app is installed, and before the apps depending on it are installed
+	// +optional
+	RestartAfterInstall bool `json:"restartAfterInstall,omitempty"`
 }
 
 // AppFrameworkSpec defines the application package remote store repository
@@ -446,6 +583,66 @@ type AppFrameworkSpec struct {
 
 	// Maximum number of apps that can be downloaded at same time
 	MaxConcurrentAppDownloads uint64 `json:"maxConcurrentAppDownloads,omitempty"`
+
+	// Dry run mode. The App Framework checks the remote storage and records the plan of the app changes
+	// in the status, without downloading, copying or installing any apps
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Scheduling priority of the app downloads, pod copies and installs of this CR, across all the CRs managed by the operator.
+	// Work of a CR with a higher priority is always scheduled before the work of a CR with a lower priority. Defaults to normal
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=high;normal;low
+	Priority string `json:"priority,omitempty"`
+
+	// Relative share of the operator wide App Framework worker slots given to this CR, when competing with the CRs of the same priority.
+	// Defaults to 1
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=100
+	Weight uint32 `json:"weight,omitempty"`
+
+	// How the app packages are transferred to the pods. staged: the operator downloads the app packages to its storage, and copies them to the pods.
+	// stream: the operator streams the app packages from the remote storage to the pods, without staging them on the operator. Defaults to staged
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=staged;stream
+	TransferMode string `json:"transferMode,omitempty"`
+
+	// Verification of the apps after they are installed on the pods
+	// +optional
+	InstallVerification AppInstallVerificationSpec `json:"installVerification,omitempty"`
+
+	// Maintenance windows for the app installs and the bundle pushes, which can restart Splunk on the pods. When set, the apps
+	// are installed, and the bundles are pushed, only within a window. The downloads and the pod copies are done at any time
+	// +optional
+	MaintenanceWindows []MaintenanceWindowSpec `json:"maintenanceWindows,omitempty"`
+}
+
+// MaintenanceWindowSpec defines a recurring maintenance window
+type MaintenanceWindowSpec struct {
+	// Start of the window, as a cron schedule with the minute, hour, day of month, month and day of week fields, e.g. "0 2 * * 6"
+	// for 2 AM every Saturday
+	Schedule string `json:"schedule"`
+
+	// Length of the window in seconds
+	// +kubebuilder:validation:Minimum=60
+	DurationSeconds int64 `json:"durationSeconds"`
+
+	// IANA time zone of the schedule, e.g. America/New_York. Defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// AppInstallVerificationSpec defines the verification of the apps after they are installed on a pod
+type AppInstallVerificationSpec struct {
+	// Verifies an installed app with btool check, the app version reported by splunkd and the splunkd health.
+	// An app update that fails the verification is rolled back to the previously installed app package
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Time window(in seconds) for an installed app to pass the verification. Defaults to 300 seconds
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	WindowSeconds int64 `json:"windowSeconds,omitempty"`
 }
 
 // AppDeploymentInfo represents a single App deployment information
@@ -465,6 +662,9 @@ type AppDeploymentInfo struct {
 	// app after it is installed.
 	AppPackageTopFolder string `json:"appPackageTopFolder"`
 
+	// Hash of the local/ configuration overlay files applied to the app package
+	OverlayHash string `json:"overlayHash,omitempty"`
+
 	// App phase info to track download, copy and install
 	PhaseInfo PhaseInfo `json:"phaseInfo,omitempty"`
 
@@ -472,6 +672,12 @@ type AppDeploymentInfo struct {
 	// Each Pod's phase info is mapped to its ordinal value.
 	// Ignored, once the DeployStatus is marked as Complete
 	AuxPhaseInfo []PhaseInfo `json:"auxPhaseInfo,omitempty"`
+
+	// Version of the app, as set in the default/app.conf of the installed app package
+	Version string `json:"version,omitempty"`
+
+	// Last error seen while downloading, copying or installing the app. Cleared, once the app is installed
+	LastError string `json:"lastError,omitempty"`
 }
 
 // AppSrcDeployInfo represents deployment info for list of Apps
@@ -540,6 +746,95 @@ type AppDeploymentContext struct {
 
 	// Internal to the App framework. Used in case of CM(IDXC) and deployer(SHC)
 	BundlePushStatus BundlePushTracker `json:"bundlePushStatus,omitempty"`
+
+	// Plan of the app changes, recorded in the dry run mode
+	DryRunPlan *AppDeploymentPlan `json:"dryRunPlan,omitempty"`
+
+	// Summary of the app deployments. The deployment status of each app is in the AppDeployment resources owned by the CR
+	AppsStatusSummary AppsStatusSummary `json:"appsStatusSummary,omitempty"`
+
+	// State of the maintenance windows, when configured
+	MaintenanceWindowStatus MaintenanceWindowStatus `json:"maintenanceWindowStatus,omitempty"`
+}
+
+const (
+	// MaintenanceWindowStateOpen means a maintenance window is in progress, and the app installs and the bundle pushes go ahead
+	MaintenanceWindowStateOpen = "in window"
+
+	// MaintenanceWindowStateWaiting means the app installs and the bundle pushes wait for the next maintenance window
+	MaintenanceWindowStateWaiting = "waiting for window"
+)
+
+// MaintenanceWindowStatus represents the state of the maintenance windows of the App Framework
+type MaintenanceWindowStatus struct {
+	// State of the maintenance windows: "in window" or "waiting for window"
+	State string `json:"state,omitempty"`
+
+	// End of the current maintenance window, in epoch seconds
+	WindowEnd int64 `json:"windowEnd,omitempty"`
+
+	// Start of the next maintenance window, in epoch seconds
+	NextWindowStart int64 `json:"nextWindowStart,omitempty"`
+
+	// Error in the maintenance window configuration, if any. The app installs and the bundle pushes wait till it is fixed
+	Error string `json:"error,omitempty"`
+}
+
+// AppsStatusSummary represents the number of apps of a CR in each deployment state
+type AppsStatusSummary struct {
+	// Number of apps known to the App Framework
+	TotalApps int `json:"totalApps"`
+
+	// Number of apps installed
+	InstalledApps int `json:"installedApps"`
+
+	// Number of apps waiting to be downloaded, copied or installed
+	PendingApps int `json:"pendingApps"`
+
+	// Number of apps that failed to deploy after the retries
+	FailedApps int `json:"failedApps"`
+
+	// Number of apps deleted from the remote storage
+	DeletedApps int `json:"deletedApps"`
+}
+
+// AppPlanEntry represents an app change in the dry run plan
+type AppPlanEntry struct {
+	// Name of the App source
+	AppSourceName string `json:"appSourceName"`
+
+	// Name of the app package
+	AppName string `json:"appName"`
+
+	// Scope of the App source
+	Scope string `json:"scope,omitempty"`
+
+	// Object hash of the app package on the remote storage
+	ObjectHash string `json:"objectHash,omitempty"`
+}
+
+// AppDeploymentPlan represents the app changes the App Framework would make, if the dry run mode is turned off
+type AppDeploymentPlan struct {
+	// Time at which the plan was made, in epoch seconds
+	PlanTime int64 `json:"planTime,omitempty"`
+
+	// Hash of the App Framework config used for the plan
+	AppFrameworkConfigHash string `json:"appRepoHash,omitempty"`
+
+	// Apps to be installed
+	AppsToInstall []AppPlanEntry `json:"appsToInstall,omitempty"`
+
+	// Apps to be updated
+	AppsToUpdate []AppPlanEntry `json:"appsToUpdate,omitempty"`
+
+	// Apps to be deleted
+	AppsToDelete []AppPlanEntry `json:"appsToDelete,omitempty"`
+
+	// Indicates if a bundle push is needed, in case of CM(IDXC) and deployer(SHC)
+	BundlePushRequired bool `json:"bundlePushRequired,omitempty"`
+
+	// App sources with the cluster scoped app changes pushed through the bundle push
+	BundlePushAppSources []string `json:"bundlePushAppSources,omitempty"`
 }
 
 // AppPhaseStatusType defines the Phase status
@@ -32,6 +32,14 @@ const (
 	// IndexerClusterPausedAnnotation is the annotation that pauses the reconciliation (triggers
 	// an immediate requeue)
 	IndexerClusterPausedAnnotation = "indexercluster.enterprise.splunk.com/paused"
+
+	// IndexerClusterRestartAnnotation is the annotation that requests a restart of the peers. With the
+	// searchableRollingRestart update strategy, a change of its value restarts the peers without recycling their pods
+	IndexerClusterRestartAnnotation = "indexercluster.enterprise.splunk.com/restartedAt"
+
+	// IndexerClusterSiteLabel is the label of the IndexerCluster created for a site of a multisite IndexerCluster,
+	// set to the name of the site
+	IndexerClusterSiteLabel = "indexercluster.enterprise.splunk.com/site"
 )
 
 // IndexerClusterSpec defines the desired state of a Splunk Enterprise indexer cluster
@@ -40,8 +48,68 @@ type IndexerClusterSpec struct {
 
 	// Number of search head pods; a search head cluster will be created if > 1
 	Replicas int32 `json:"replicas"`
+
+	// How the indexer pods are updated, when their pod template changes. recycle: each peer is decommissioned, and its pod
+	// is recycled one at a time. searchableRollingRestart: when the change only needs a restart of the peers, the cluster
+	// manager restarts them with a searchable rolling restart, and the pods are not recycled. Defaults to recycle
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=recycle;searchableRollingRestart
+	UpdateStrategy string `json:"updateStrategy,omitempty"`
+
+	// Data rebalance of the indexer cluster, once the new peers are up after a scale up
+	// +optional
+	RebalanceOnScaleUp RebalanceOnScaleUpSpec `json:"rebalanceOnScaleUp,omitempty"`
+
+	// Splunk sites of a multisite indexer cluster, each mapped to a topology zone with its own number of indexers. An
+	// IndexerCluster is created per site, with its indexers constrained to the zone of the site. Replicas is ignored
+	// +optional
+	Sites []IndexerClusterSiteSpec `json:"sites,omitempty"`
+
+	// Label of the nodes for the topology zone of the sites. Defaults to topology.kubernetes.io/zone
+	// +optional
+	ZoneLabel string `json:"zoneLabel,omitempty"`
+
+	// site_replication_factor configured on the cluster manager for the sites, e.g. origin:1,total:2. Defaults to origin:1,total:2
+	// +optional
+	SiteReplicationFactor string `json:"siteReplicationFactor,omitempty"`
+
+	// site_search_factor configured on the cluster manager for the sites, e.g. origin:1,total:2. Defaults to origin:1,total:2
+	// +optional
+	SiteSearchFactor string `json:"siteSearchFactor,omitempty"`
 }
 
+// IndexerClusterSiteSpec maps a Splunk site of a multisite indexer cluster to a topology zone
+type IndexerClusterSiteSpec struct {
+	// Name of the Splunk site, i.e. site1 to site63
+	// +kubebuilder:validation:Pattern=`^site([1-9]|[1-5][0-9]|6[0-3])$`
+	Name string `json:"name"`
+
+	// Topology zone the indexers of the site run in, i.e. the value of the zone label of the nodes
+	Zone string `json:"zone"`
+
+	// Number of indexers of the site
+	// +kubebuilder:validation:Minimum=1
+	Replicas int32 `json:"replicas"`
+}
+
+// RebalanceOnScaleUpSpec defines the data rebalance of the indexer cluster after a scale up
+type RebalanceOnScaleUpSpec struct {
+	// Start a data rebalance on the cluster manager, once the new peers are up after a scale up
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Maximum runtime of the data rebalance, in minutes. The data rebalance is stopped after it. Defaults to 0, i.e. no limit
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxRuntimeMinutes int64 `json:"maxRuntimeMinutes,omitempty"`
+}
+
+// Values to represent how the indexer pods are updated
+const (
+	IndexerClusterUpdateStrategyRecycle                  = "recycle"
+	IndexerClusterUpdateStrategySearchableRollingRestart = "searchableRollingRestart"
+)
+
 // IndexerClusterMemberStatus is used to track the status of each indexer cluster peer.
 type IndexerClusterMemberStatus struct {
 	// Unique identifier or GUID for the peer
@@ -108,6 +176,88 @@ type IndexerClusterStatus struct {
 
 	// status of each indexer cluster peer
 	Peers []IndexerClusterMemberStatus `json:"peers"`
+
+	// status of the searchable rolling restart of the indexer cluster peers
+	// +optional
+	RollingRestartStatus IndexerClusterRollingRestartStatus `json:"rollingRestartStatus,omitempty"`
+
+	// status of the data rebalance after a scale up
+	// +optional
+	RebalanceStatus IndexerClusterRebalanceStatus `json:"rebalanceStatus,omitempty"`
+
+	// status of each site of a multisite indexer cluster
+	// +optional
+	Sites []IndexerClusterSiteStatus `json:"sites,omitempty"`
+
+	// multisite configuration applied on the cluster manager for the sites
+	// +optional
+	SiteConfig string `json:"siteConfig,omitempty"`
+
+	// VolumeSnapshots of the etc and var persistent volume claims
+	// +optional
+	VolumeSnapshots VolumeSnapshotStatus `json:"volumeSnapshots,omitempty"`
+}
+
+// IndexerClusterSiteStatus is used to track the status of each site of a multisite indexer cluster
+type IndexerClusterSiteStatus struct {
+	// Name of the Splunk site
+	Name string `json:"name"`
+
+	// Topology zone of the site
+	Zone string `json:"zone"`
+
+	// Name of the IndexerCluster of the site
+	IndexerCluster string `json:"indexerCluster"`
+
+	// current phase of the indexers of the site
+	Phase Phase `json:"phase,omitempty"`
+
+	// number of indexers of the site
+	Replicas int32 `json:"replicas"`
+
+	// number of ready indexers of the site
+	ReadyReplicas int32 `json:"readyReplicas"`
+}
+
+// Values to represent the state of the data rebalance after a scale up
+const (
+	RebalanceStatePending    = "Pending"
+	RebalanceStateInProgress = "InProgress"
+	RebalanceStateComplete   = "Complete"
+	RebalanceStateStopped    = "Stopped"
+)
+
+// IndexerClusterRebalanceStatus tracks the data rebalance of the indexer cluster after a scale up
+type IndexerClusterRebalanceStatus struct {
+	// State of the data rebalance: Pending, InProgress, Complete or Stopped
+	State string `json:"state,omitempty"`
+
+	// Number of peers the indexer cluster is scaled up to
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Time the data rebalance was started at, in seconds since the epoch
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time the data rebalance completed or was stopped at, in seconds since the epoch
+	EndTime int64 `json:"endTime,omitempty"`
+
+	// Percentage of the data rebalance that is complete, as reported by the cluster manager
+	Progress string `json:"progress,omitempty"`
+
+	// Latest message about the data rebalance
+	Message string `json:"message,omitempty"`
+}
+
+// IndexerClusterRollingRestartStatus tracks a searchable rolling restart of the indexer cluster peers by the cluster manager
+type IndexerClusterRollingRestartStatus struct {
+	// StatefulSet revision the pods are updated to, once the peers are restarted. Empty, when no rolling restart is in progress
+	Revision string `json:"revision,omitempty"`
+
+	// Time the rolling restart was started at, in seconds since the epoch
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Peers restarted by the cluster manager
+	RestartedPeers []string `json:"restartedPeers,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -52,6 +52,10 @@ type LicenseManagerStatus struct {
 
 	// Telemetry App installation flag
 	TelAppInstalled bool `json:"telAppInstalled"`
+
+	// VolumeSnapshots of the etc and var persistent volume claims
+	// +optional
+	VolumeSnapshots VolumeSnapshotStatus `json:"volumeSnapshots,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
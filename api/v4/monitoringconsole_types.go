@@ -58,6 +58,10 @@ type MonitoringConsoleStatus struct {
 
 	// App Framework status
 	AppContext AppDeploymentContext `json:"appContext,omitempty"`
+
+	// VolumeSnapshots of the etc and var persistent volume claims
+	// +optional
+	VolumeSnapshots VolumeSnapshotStatus `json:"volumeSnapshots,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -33,6 +33,10 @@ const (
 	// SearchHeadClusterPausedAnnotation is the annotation that pauses the reconciliation (triggers
 	// an immediate requeue)
 	SearchHeadClusterPausedAnnotation = "searchheadcluster.enterprise.splunk.com/paused"
+
+	// SearchHeadClusterDynamicCaptainAnnotation is the annotation that switches the search head cluster
+	// back from static captaincy to dynamic captaincy, once staticCaptain is unset
+	SearchHeadClusterDynamicCaptainAnnotation = "searchheadcluster.enterprise.splunk.com/restore-dynamic-captain"
 )
 
 // SearchHeadClusterSpec defines the desired state of a Splunk Enterprise search head cluster
@@ -44,6 +48,15 @@ type SearchHeadClusterSpec struct {
 
 	// Splunk Enterprise App repository. Specifies remote App location and scope for Splunk App management
 	AppFrameworkConfig AppFrameworkSpec `json:"appRepo,omitempty"`
+
+	// Ordinals of the search head cluster members configured as preferred captains
+	// +optional
+	CaptainPreference []int32 `json:"captainPreference,omitempty"`
+
+	// Emergency static captain mode, disabling captain election. The first member of captainPreference, or else the
+	// member 0, is the static captain. Switching back to dynamic captaincy also requires the restore-dynamic-captain annotation
+	// +optional
+	StaticCaptain bool `json:"staticCaptain,omitempty"`
 }
 
 // SearchHeadClusterMemberStatus is used to track the status of each search head cluster member
@@ -65,6 +78,9 @@ type SearchHeadClusterMemberStatus struct {
 
 	// Number of currently running realtime searches.
 	ActiveRealtimeSearchCount int `json:"active_realtime_search_count"`
+
+	// Indicates if this member is a preferred captain.
+	PreferredCaptain bool `json:"preferred_captain,omitempty"`
 }
 
 // SearchHeadClusterStatus defines the observed state of a Splunk Enterprise search head cluster
@@ -87,6 +103,18 @@ type SearchHeadClusterStatus struct {
 	// name or label of the search head captain
 	Captain string `json:"captain"`
 
+	// true if the search head captain is a preferred captain
+	CaptainPreferred bool `json:"captainPreferred,omitempty"`
+
+	// ordinals of the members configured as preferred captains
+	CaptainPreference []int32 `json:"captainPreference,omitempty"`
+
+	// name of the static captain, while captain election is disabled
+	StaticCaptain string `json:"staticCaptain,omitempty"`
+
+	// members configured with the static captain
+	StaticCaptainMembers []string `json:"staticCaptainMembers,omitempty"`
+
 	// true if the search head cluster's captain is ready to service requests
 	CaptainReady bool `json:"captainReady"`
 
@@ -119,6 +147,10 @@ type SearchHeadClusterStatus struct {
 
 	// Telemetry App installation flag
 	TelAppInstalled bool `json:"telAppInstalled"`
+
+	// VolumeSnapshots of the etc and var persistent volume claims
+	// +optional
+	VolumeSnapshots VolumeSnapshotStatus `json:"volumeSnapshots,omitempty"`
 }
 
 // SearchHeadCluster is the Schema for a Splunk Enterprise search head cluster
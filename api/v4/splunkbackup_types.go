@@ -0,0 +1,152 @@
+/*
+Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+const (
+	// SplunkBackupPausedAnnotation is the annotation that pauses the reconciliation (triggers
+	// an immediate requeue)
+	SplunkBackupPausedAnnotation = "splunkbackup.enterprise.splunk.com/paused"
+
+	// SplunkBackupTypeKVStore backs up the KV store of a SearchHeadCluster or a Standalone
+	SplunkBackupTypeKVStore = "kvstore"
+
+	// SplunkBackupTypeEtc snapshots the configuration of a ClusterManager, or of the deployer of a SearchHeadCluster
+	SplunkBackupTypeEtc = "etc"
+)
+
+// BackupTargetReference refers to the CR backed up, or restored, in the namespace of the SplunkBackup or SplunkRestore
+type BackupTargetReference struct {
+	// Kind of the CR: SearchHeadCluster or Standalone for the KV store, ClusterManager or SearchHeadCluster for etc
+	// +kubebuilder:validation:Enum=ClusterManager;SearchHeadCluster;Standalone
+	Kind string `json:"kind"`
+
+	// Name of the CR
+	Name string `json:"name"`
+}
+
+// SplunkBackupSchedule defines when the backups are taken
+type SplunkBackupSchedule struct {
+	// Interval in seconds between the backups. 0 takes a single backup
+	Interval int64 `json:"intervalSeconds,omitempty"`
+
+	// Suspend stops taking backups, the existing backups are kept
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// SplunkBackupSpec defines the desired state of a SplunkBackup
+type SplunkBackupSpec struct {
+	// Type of the backup: kvstore backs up the KV store, etc snapshots the apps and the local configuration of a
+	// cluster manager, or of a search head cluster deployer. Defaults to kvstore
+	// +kubebuilder:validation:Enum=kvstore;etc
+	Type string `json:"type,omitempty"`
+
+	// CR which is backed up
+	Target BackupTargetReference `json:"target"`
+
+	// Remote storage volume keeping the backups
+	Volume VolumeSpec `json:"volume"`
+
+	// Location relative to the volume path
+	Location string `json:"location"`
+
+	// Schedule of the backups
+	Schedule SplunkBackupSchedule `json:"schedule,omitempty"`
+
+	// Number of backups kept on the remote storage, the oldest backups are deleted. 0 keeps all the backups
+	// +kubebuilder:validation:Minimum=0
+	Retention int32 `json:"retention,omitempty"`
+}
+
+// SplunkBackupArchive is a backup kept on the remote storage
+type SplunkBackupArchive struct {
+	// Name of the archive, relative to the location of the SplunkBackup
+	Name string `json:"name"`
+
+	// Time the backup was taken
+	Time int64 `json:"time"`
+
+	// Size of the archive in bytes
+	Size int64 `json:"size,omitempty"`
+
+	// Pod the backup was taken on
+	Pod string `json:"pod,omitempty"`
+}
+
+// SplunkBackupStatus defines the observed state of a SplunkBackup
+type SplunkBackupStatus struct {
+	// current phase of the SplunkBackup
+	Phase Phase `json:"phase"`
+
+	// Time of the last backup
+	LastBackupTime int64 `json:"lastBackupTime,omitempty"`
+
+	// Name of the archive of the last backup
+	LastBackup string `json:"lastBackup,omitempty"`
+
+	// Backup being taken, if any
+	InProgress *SplunkBackupArchive `json:"inProgress,omitempty"`
+
+	// Backups kept on the remote storage, the oldest first
+	Backups []SplunkBackupArchive `json:"backups,omitempty"`
+
+	// Error from the last backup, if any
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SplunkBackup is the Schema for the scheduled backups of the KV store, or of the configuration, of a Splunk Enterprise CR
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=splunkbackups,scope=Namespaced,shortName=splbackup
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of backup"
+// +kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type",description="Type of the backups"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.target.name",description="CR backed up"
+// +kubebuilder:printcolumn:name="Last Backup",type="string",JSONPath=".status.lastBackup",description="Archive of the last backup"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of backup"
+// +kubebuilder:storageversion
+type SplunkBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SplunkBackupSpec   `json:"spec,omitempty"`
+	Status SplunkBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SplunkBackupList contains a list of SplunkBackup
+type SplunkBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SplunkBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SplunkBackup{}, &SplunkBackupList{})
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+const (
+	// SplunkRestorePausedAnnotation is the annotation that pauses the reconciliation (triggers
+	// an immediate requeue)
+	SplunkRestorePausedAnnotation = "splunkrestore.enterprise.splunk.com/paused"
+
+	// SplunkRestorePendingEtcRestoreAnnotation is set on the ClusterManager or the SearchHeadCluster an etc snapshot
+	// is restored into, with the name of the SplunkRestore. The peers of the indexer cluster, or the search heads,
+	// are not created or updated till the restore is done
+	SplunkRestorePendingEtcRestoreAnnotation = "splunkrestore.enterprise.splunk.com/pending-etc-restore"
+)
+
+// SplunkRestoreSpec defines the desired state of a SplunkRestore
+type SplunkRestoreSpec struct {
+	// CR which is restored, of a kind backed up by the SplunkBackup
+	Target BackupTargetReference `json:"target"`
+
+	// Name of the SplunkBackup, in the namespace of the SplunkRestore, keeping the backup
+	Backup string `json:"backup"`
+
+	// Name of the archive to restore, from the backups of the SplunkBackup. Defaults to the last backup
+	Archive string `json:"archive,omitempty"`
+}
+
+// SplunkRestoreStatus defines the observed state of a SplunkRestore
+type SplunkRestoreStatus struct {
+	// current phase of the SplunkRestore
+	Phase Phase `json:"phase"`
+
+	// Type of the backup restored
+	Type string `json:"type,omitempty"`
+
+	// Name of the archive restored
+	Archive string `json:"archive,omitempty"`
+
+	// Pod the archive is restored on
+	Pod string `json:"pod,omitempty"`
+
+	// Time the restore started
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time the restore completed
+	RestoreTime int64 `json:"restoreTime,omitempty"`
+
+	// True when the KV store of the search head cluster, or the indexer cluster, was put in maintenance mode for the restore
+	MaintenanceMode bool `json:"maintenanceMode,omitempty"`
+
+	// Error from the last restore attempt, if any
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SplunkRestore is the Schema for restoring a backup of a SplunkBackup into a Splunk Enterprise CR
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=splunkrestores,scope=Namespaced,shortName=splrestore
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of restore"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.target.name",description="CR restored"
+// +kubebuilder:printcolumn:name="Archive",type="string",JSONPath=".status.archive",description="Archive restored"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of restore"
+// +kubebuilder:storageversion
+type SplunkRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SplunkRestoreSpec   `json:"spec,omitempty"`
+	Status SplunkRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SplunkRestoreList contains a list of SplunkRestore
+type SplunkRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SplunkRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SplunkRestore{}, &SplunkRestoreList{})
+}
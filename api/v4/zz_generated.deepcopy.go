@@ -26,6 +26,67 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppConfigOverlaySpec) DeepCopyInto(out *AppConfigOverlaySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppConfigOverlaySpec.
+func (in *AppConfigOverlaySpec) DeepCopy() *AppConfigOverlaySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AppConfigOverlaySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppDependencySpec) DeepCopyInto(out *AppDependencySpec) {
+	*out = *in
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDependencySpec.
+func (in *AppDependencySpec) DeepCopy() *AppDependencySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AppDependencySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppDeployment) DeepCopyInto(out *AppDeployment) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDeployment.
+func (in *AppDeployment) DeepCopy() *AppDeployment {
+	if in == nil {
+		return nil
+	}
+	out := new(AppDeployment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AppDeployment) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppDeploymentContext) DeepCopyInto(out *AppDeploymentContext) {
 	*out = *in
@@ -38,6 +99,13 @@ func (in *AppDeploymentContext) DeepCopyInto(out *AppDeploymentContext) {
 		}
 	}
 	out.BundlePushStatus = in.BundlePushStatus
+	if in.DryRunPlan != nil {
+		in, out := &in.DryRunPlan, &out.DryRunPlan
+		*out = new(AppDeploymentPlan)
+		(*in).DeepCopyInto(*out)
+	}
+	out.AppsStatusSummary = in.AppsStatusSummary
+	out.MaintenanceWindowStatus = in.MaintenanceWindowStatus
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDeploymentContext.
@@ -71,6 +139,94 @@ func (in *AppDeploymentInfo) DeepCopy() *AppDeploymentInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppDeploymentList) DeepCopyInto(out *AppDeploymentList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AppDeployment, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDeploymentList.
+func (in *AppDeploymentList) DeepCopy() *AppDeploymentList {
+	if in == nil {
+		return nil
+	}
+	out := new(AppDeploymentList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AppDeploymentList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppDeploymentPlan) DeepCopyInto(out *AppDeploymentPlan) {
+	*out = *in
+	if in.AppsToInstall != nil {
+		in, out := &in.AppsToInstall, &out.AppsToInstall
+		*out = make([]AppPlanEntry, len(*in))
+		copy(*out, *in)
+	}
+	if in.AppsToUpdate != nil {
+		in, out := &in.AppsToUpdate, &out.AppsToUpdate
+		*out = make([]AppPlanEntry, len(*in))
+		copy(*out, *in)
+	}
+	if in.AppsToDelete != nil {
+		in, out := &in.AppsToDelete, &out.AppsToDelete
+		*out = make([]AppPlanEntry, len(*in))
+		copy(*out, *in)
+	}
+	if in.BundlePushAppSources != nil {
+		in, out := &in.BundlePushAppSources, &out.BundlePushAppSources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDeploymentPlan.
+func (in *AppDeploymentPlan) DeepCopy() *AppDeploymentPlan {
+	if in == nil {
+		return nil
+	}
+	out := new(AppDeploymentPlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppDeploymentResourceStatus) DeepCopyInto(out *AppDeploymentResourceStatus) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]AppPodPhaseInfo, len(*in))
+		copy(*out, *in)
+	}
+	in.AppDeploymentInfo.DeepCopyInto(&out.AppDeploymentInfo)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDeploymentResourceStatus.
+func (in *AppDeploymentResourceStatus) DeepCopy() *AppDeploymentResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AppDeploymentResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppFrameworkSpec) DeepCopyInto(out *AppFrameworkSpec) {
 	*out = *in
@@ -83,6 +239,14 @@ func (in *AppFrameworkSpec) DeepCopyInto(out *AppFrameworkSpec) {
 	if in.AppSources != nil {
 		in, out := &in.AppSources, &out.AppSources
 		*out = make([]AppSourceSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.InstallVerification = in.InstallVerification
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindowSpec, len(*in))
 		copy(*out, *in)
 	}
 }
@@ -97,6 +261,78 @@ func (in *AppFrameworkSpec) DeepCopy() *AppFrameworkSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppInstallVerificationSpec) DeepCopyInto(out *AppInstallVerificationSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppInstallVerificationSpec.
+func (in *AppInstallVerificationSpec) DeepCopy() *AppInstallVerificationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AppInstallVerificationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppPlanEntry) DeepCopyInto(out *AppPlanEntry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppPlanEntry.
+func (in *AppPlanEntry) DeepCopy() *AppPlanEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(AppPlanEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppPodPhaseInfo) DeepCopyInto(out *AppPodPhaseInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppPodPhaseInfo.
+func (in *AppPodPhaseInfo) DeepCopy() *AppPodPhaseInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(AppPodPhaseInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppSource) DeepCopyInto(out *AppSource) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppSource.
+func (in *AppSource) DeepCopy() *AppSource {
+	if in == nil {
+		return nil
+	}
+	out := new(AppSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AppSource) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppSourceDefaultSpec) DeepCopyInto(out *AppSourceDefaultSpec) {
 	*out = *in
@@ -113,10 +349,79 @@ func (in *AppSourceDefaultSpec) DeepCopy() *AppSourceDefaultSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppSourceList) DeepCopyInto(out *AppSourceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AppSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppSourceList.
+func (in *AppSourceList) DeepCopy() *AppSourceList {
+	if in == nil {
+		return nil
+	}
+	out := new(AppSourceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AppSourceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppSourceReference) DeepCopyInto(out *AppSourceReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppSourceReference.
+func (in *AppSourceReference) DeepCopy() *AppSourceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(AppSourceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppSourceSpec) DeepCopyInto(out *AppSourceSpec) {
 	*out = *in
+	if in.AppSourceRef != nil {
+		in, out := &in.AppSourceRef, &out.AppSourceRef
+		*out = new(AppSourceReference)
+		**out = **in
+	}
 	out.AppSourceDefaultSpec = in.AppSourceDefaultSpec
+	if in.InstallOrder != nil {
+		in, out := &in.InstallOrder, &out.InstallOrder
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AppDependencies != nil {
+		in, out := &in.AppDependencies, &out.AppDependencies
+		*out = make([]AppDependencySpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AppConfigOverlays != nil {
+		in, out := &in.AppConfigOverlays, &out.AppConfigOverlays
+		*out = make([]AppConfigOverlaySpec, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppSourceSpec.
@@ -151,6 +456,36 @@ func (in *AppSrcDeployInfo) DeepCopy() *AppSrcDeployInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppsStatusSummary) DeepCopyInto(out *AppsStatusSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppsStatusSummary.
+func (in *AppsStatusSummary) DeepCopy() *AppsStatusSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(AppsStatusSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupTargetReference) DeepCopyInto(out *BackupTargetReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupTargetReference.
+func (in *BackupTargetReference) DeepCopy() *BackupTargetReference {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupTargetReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BundlePushInfo) DeepCopyInto(out *BundlePushInfo) {
 	*out = *in
@@ -197,6 +532,21 @@ func (in *CacheManagerSpec) DeepCopy() *CacheManagerSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterFactorsStatus) DeepCopyInto(out *ClusterFactorsStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterFactorsStatus.
+func (in *ClusterFactorsStatus) DeepCopy() *ClusterFactorsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterFactorsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterManager) DeepCopyInto(out *ClusterManager) {
 	*out = *in
@@ -256,6 +606,21 @@ func (in *ClusterManagerList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterManagerMemberStatus) DeepCopyInto(out *ClusterManagerMemberStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterManagerMemberStatus.
+func (in *ClusterManagerMemberStatus) DeepCopy() *ClusterManagerMemberStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterManagerMemberStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterManagerSpec) DeepCopyInto(out *ClusterManagerSpec) {
 	*out = *in
@@ -287,6 +652,13 @@ func (in *ClusterManagerStatus) DeepCopyInto(out *ClusterManagerStatus) {
 		}
 	}
 	in.AppContext.DeepCopyInto(&out.AppContext)
+	if in.Managers != nil {
+		in, out := &in.Managers, &out.Managers
+		*out = make([]ClusterManagerMemberStatus, len(*in))
+		copy(*out, *in)
+	}
+	out.ClusterFactors = in.ClusterFactors
+	out.VolumeSnapshots = in.VolumeSnapshots
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterManagerStatus.
@@ -344,6 +716,11 @@ func (in *CommonSplunkSpec) DeepCopyInto(out *CommonSplunkSpec) {
 		*out = make([]v1.LocalObjectReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.SnapshotPolicy != nil {
+		in, out := &in.SnapshotPolicy, &out.SnapshotPolicy
+		*out = new(SnapshotPolicySpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommonSplunkSpec.
@@ -508,10 +885,81 @@ func (in *IndexerClusterMemberStatus) DeepCopy() *IndexerClusterMemberStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterRebalanceStatus) DeepCopyInto(out *IndexerClusterRebalanceStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterRebalanceStatus.
+func (in *IndexerClusterRebalanceStatus) DeepCopy() *IndexerClusterRebalanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterRebalanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterRollingRestartStatus) DeepCopyInto(out *IndexerClusterRollingRestartStatus) {
+	*out = *in
+	if in.RestartedPeers != nil {
+		in, out := &in.RestartedPeers, &out.RestartedPeers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterRollingRestartStatus.
+func (in *IndexerClusterRollingRestartStatus) DeepCopy() *IndexerClusterRollingRestartStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterRollingRestartStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterSiteSpec) DeepCopyInto(out *IndexerClusterSiteSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterSiteSpec.
+func (in *IndexerClusterSiteSpec) DeepCopy() *IndexerClusterSiteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterSiteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterSiteStatus) DeepCopyInto(out *IndexerClusterSiteStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterSiteStatus.
+func (in *IndexerClusterSiteStatus) DeepCopy() *IndexerClusterSiteStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterSiteStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IndexerClusterSpec) DeepCopyInto(out *IndexerClusterSpec) {
 	*out = *in
 	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
+	out.RebalanceOnScaleUp = in.RebalanceOnScaleUp
+	if in.Sites != nil {
+		in, out := &in.Sites, &out.Sites
+		*out = make([]IndexerClusterSiteSpec, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterSpec.
@@ -544,14 +992,37 @@ func (in *IndexerClusterStatus) DeepCopyInto(out *IndexerClusterStatus) {
 		*out = make([]IndexerClusterMemberStatus, len(*in))
 		copy(*out, *in)
 	}
+	in.RollingRestartStatus.DeepCopyInto(&out.RollingRestartStatus)
+	out.RebalanceStatus = in.RebalanceStatus
+	if in.Sites != nil {
+		in, out := &in.Sites, &out.Sites
+		*out = make([]IndexerClusterSiteStatus, len(*in))
+		copy(*out, *in)
+	}
+	out.VolumeSnapshots = in.VolumeSnapshots
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterStatus.
+func (in *IndexerClusterStatus) DeepCopy() *IndexerClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterStatus)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterStatus.
-func (in *IndexerClusterStatus) DeepCopy() *IndexerClusterStatus {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ItsiDefaults) DeepCopyInto(out *ItsiDefaults) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ItsiDefaults.
+func (in *ItsiDefaults) DeepCopy() *ItsiDefaults {
 	if in == nil {
 		return nil
 	}
-	out := new(IndexerClusterStatus)
+	out := new(ItsiDefaults)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -636,6 +1107,7 @@ func (in *LicenseManagerSpec) DeepCopy() *LicenseManagerSpec {
 func (in *LicenseManagerStatus) DeepCopyInto(out *LicenseManagerStatus) {
 	*out = *in
 	in.AppContext.DeepCopyInto(&out.AppContext)
+	out.VolumeSnapshots = in.VolumeSnapshots
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseManagerStatus.
@@ -648,6 +1120,36 @@ func (in *LicenseManagerStatus) DeepCopy() *LicenseManagerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindowSpec) DeepCopyInto(out *MaintenanceWindowSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowSpec.
+func (in *MaintenanceWindowSpec) DeepCopy() *MaintenanceWindowSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindowSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindowStatus) DeepCopyInto(out *MaintenanceWindowStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowStatus.
+func (in *MaintenanceWindowStatus) DeepCopy() *MaintenanceWindowStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindowStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MonitoringConsole) DeepCopyInto(out *MonitoringConsole) {
 	*out = *in
@@ -736,6 +1238,7 @@ func (in *MonitoringConsoleStatus) DeepCopyInto(out *MonitoringConsoleStatus) {
 		}
 	}
 	in.AppContext.DeepCopyInto(&out.AppContext)
+	out.VolumeSnapshots = in.VolumeSnapshots
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleStatus.
@@ -767,6 +1270,7 @@ func (in *PhaseInfo) DeepCopy() *PhaseInfo {
 func (in *PremiumAppsProps) DeepCopyInto(out *PremiumAppsProps) {
 	*out = *in
 	out.EsDefaults = in.EsDefaults
+	out.ItsiDefaults = in.ItsiDefaults
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PremiumAppsProps.
@@ -794,6 +1298,21 @@ func (in *Probe) DeepCopy() *Probe {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RebalanceOnScaleUpSpec) DeepCopyInto(out *RebalanceOnScaleUpSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RebalanceOnScaleUpSpec.
+func (in *RebalanceOnScaleUpSpec) DeepCopy() *RebalanceOnScaleUpSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RebalanceOnScaleUpSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SearchHeadCluster) DeepCopyInto(out *SearchHeadCluster) {
 	*out = *in
@@ -873,6 +1392,11 @@ func (in *SearchHeadClusterSpec) DeepCopyInto(out *SearchHeadClusterSpec) {
 	*out = *in
 	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
 	in.AppFrameworkConfig.DeepCopyInto(&out.AppFrameworkConfig)
+	if in.CaptainPreference != nil {
+		in, out := &in.CaptainPreference, &out.CaptainPreference
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterSpec.
@@ -888,6 +1412,16 @@ func (in *SearchHeadClusterSpec) DeepCopy() *SearchHeadClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SearchHeadClusterStatus) DeepCopyInto(out *SearchHeadClusterStatus) {
 	*out = *in
+	if in.CaptainPreference != nil {
+		in, out := &in.CaptainPreference, &out.CaptainPreference
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.StaticCaptainMembers != nil {
+		in, out := &in.StaticCaptainMembers, &out.StaticCaptainMembers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.ShcSecretChanged != nil {
 		in, out := &in.ShcSecretChanged, &out.ShcSecretChanged
 		*out = make([]bool, len(*in))
@@ -911,6 +1445,7 @@ func (in *SearchHeadClusterStatus) DeepCopyInto(out *SearchHeadClusterStatus) {
 		copy(*out, *in)
 	}
 	in.AppContext.DeepCopyInto(&out.AppContext)
+	out.VolumeSnapshots = in.VolumeSnapshots
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterStatus.
@@ -923,6 +1458,43 @@ func (in *SearchHeadClusterStatus) DeepCopy() *SearchHeadClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedAppSourceSpec) DeepCopyInto(out *SharedAppSourceSpec) {
+	*out = *in
+	out.Volume = in.Volume
+	out.PremiumAppsProps = in.PremiumAppsProps
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedAppSourceSpec.
+func (in *SharedAppSourceSpec) DeepCopy() *SharedAppSourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedAppSourceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedAppSourceStatus) DeepCopyInto(out *SharedAppSourceStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedAppSourceStatus.
+func (in *SharedAppSourceStatus) DeepCopy() *SharedAppSourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedAppSourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SmartStoreSpec) DeepCopyInto(out *SmartStoreSpec) {
 	*out = *in
@@ -950,6 +1522,21 @@ func (in *SmartStoreSpec) DeepCopy() *SmartStoreSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotPolicySpec) DeepCopyInto(out *SnapshotPolicySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotPolicySpec.
+func (in *SnapshotPolicySpec) DeepCopy() *SnapshotPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Spec) DeepCopyInto(out *Spec) {
 	*out = *in
@@ -982,6 +1569,228 @@ func (in *Spec) DeepCopy() *Spec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkBackup) DeepCopyInto(out *SplunkBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackup.
+func (in *SplunkBackup) DeepCopy() *SplunkBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SplunkBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkBackupArchive) DeepCopyInto(out *SplunkBackupArchive) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackupArchive.
+func (in *SplunkBackupArchive) DeepCopy() *SplunkBackupArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkBackupArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkBackupList) DeepCopyInto(out *SplunkBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SplunkBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackupList.
+func (in *SplunkBackupList) DeepCopy() *SplunkBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SplunkBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkBackupSchedule) DeepCopyInto(out *SplunkBackupSchedule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackupSchedule.
+func (in *SplunkBackupSchedule) DeepCopy() *SplunkBackupSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkBackupSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkBackupSpec) DeepCopyInto(out *SplunkBackupSpec) {
+	*out = *in
+	out.Target = in.Target
+	out.Volume = in.Volume
+	out.Schedule = in.Schedule
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackupSpec.
+func (in *SplunkBackupSpec) DeepCopy() *SplunkBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkBackupStatus) DeepCopyInto(out *SplunkBackupStatus) {
+	*out = *in
+	if in.InProgress != nil {
+		in, out := &in.InProgress, &out.InProgress
+		*out = new(SplunkBackupArchive)
+		**out = **in
+	}
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]SplunkBackupArchive, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackupStatus.
+func (in *SplunkBackupStatus) DeepCopy() *SplunkBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkRestore) DeepCopyInto(out *SplunkRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkRestore.
+func (in *SplunkRestore) DeepCopy() *SplunkRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SplunkRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkRestoreList) DeepCopyInto(out *SplunkRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SplunkRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkRestoreList.
+func (in *SplunkRestoreList) DeepCopy() *SplunkRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SplunkRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkRestoreSpec) DeepCopyInto(out *SplunkRestoreSpec) {
+	*out = *in
+	out.Target = in.Target
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkRestoreSpec.
+func (in *SplunkRestoreSpec) DeepCopy() *SplunkRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkRestoreStatus) DeepCopyInto(out *SplunkRestoreStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkRestoreStatus.
+func (in *SplunkRestoreStatus) DeepCopy() *SplunkRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Standalone) DeepCopyInto(out *Standalone) {
 	*out = *in
@@ -1071,6 +1880,7 @@ func (in *StandaloneStatus) DeepCopyInto(out *StandaloneStatus) {
 		}
 	}
 	in.AppContext.DeepCopyInto(&out.AppContext)
+	out.VolumeSnapshots = in.VolumeSnapshots
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandaloneStatus.
@@ -1114,6 +1924,21 @@ func (in *VolumeAndTypeSpec) DeepCopy() *VolumeAndTypeSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotStatus) DeepCopyInto(out *VolumeSnapshotStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotStatus.
+func (in *VolumeSnapshotStatus) DeepCopy() *VolumeSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeSpec) DeepCopyInto(out *VolumeSpec) {
 	*out = *in
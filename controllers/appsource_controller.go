@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	common "github.com/splunk/splunk-operator/controllers/common"
+	enterprise "github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// AppSourceReconciler reconciles an AppSource object
+type AppSourceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=enterprise.splunk.com,resources=appsources,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=enterprise.splunk.com,resources=appsources/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=enterprise.splunk.com,resources=appsources/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile lists the remote storage of the AppSource, and notifies the CRs referring to
+// the AppSource about the app changes, so that they don't list the remote storage themselves.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
+func (r *AppSourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	reconcileCounters.With(getPrometheusLabels(req, "AppSource")).Inc()
+	defer recordInstrumentionData(time.Now(), req, "controller", "AppSource")
+
+	reqLogger := log.FromContext(ctx)
+	reqLogger = reqLogger.WithValues("appsource", req.NamespacedName)
+
+	// Fetch the AppSource
+	instance := &enterpriseApi.AppSource{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			// Request object not found, could have been deleted after
+			// reconcile request. The referring CRs are notified, so that
+			// they report the missing AppSource. Don't requeue
+			return ctrl.Result{}, DeleteAppSource(ctx, r.Client, req.Namespace, req.Name)
+		}
+		// Error reading the object - requeue the request.
+		return ctrl.Result{}, errors.Wrap(err, "could not load app source data")
+	}
+
+	// If the reconciliation is paused, requeue
+	annotations := instance.GetAnnotations()
+	if annotations != nil {
+		if _, ok := annotations[enterpriseApi.AppSourcePausedAnnotation]; ok {
+			return ctrl.Result{Requeue: true, RequeueAfter: pauseRetryDelay}, nil
+		}
+	}
+
+	reqLogger.Info("start", "CR version", instance.GetResourceVersion())
+
+	result, err := ApplyAppSource(ctx, r.Client, instance)
+	if result.Requeue && result.RequeueAfter != 0 {
+		reqLogger.Info("Requeued", "period(seconds)", int(result.RequeueAfter/time.Second))
+	}
+
+	return result, err
+}
+
+// ApplyAppSource adding to handle unit test case
+var ApplyAppSource = func(ctx context.Context, client client.Client, instance *enterpriseApi.AppSource) (reconcile.Result, error) {
+	return enterprise.ApplyAppSource(ctx, client, instance)
+}
+
+// DeleteAppSource adding to handle unit test case
+var DeleteAppSource = func(ctx context.Context, client client.Client, namespace, name string) error {
+	return enterprise.DeleteAppSource(ctx, client, namespace, name)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AppSourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&enterpriseApi.AppSource{}).
+		WithEventFilter(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+			common.LabelChangedPredicate(),
+		)).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: enterpriseApi.TotalWorker,
+		}).
+		Complete(r)
+}
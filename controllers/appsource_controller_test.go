@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("AppSource Controller", func() {
+
+	Context("AppSource Management", func() {
+
+		It("Reconcile AppSource custom resource", func() {
+			namespace := "ns-splunk-appsource-1"
+			var applyCount, deleteCount int
+			ApplyAppSource = func(ctx context.Context, client client.Client, instance *enterpriseApi.AppSource) (reconcile.Result, error) {
+				applyCount++
+				return reconcile.Result{}, nil
+			}
+			DeleteAppSource = func(ctx context.Context, client client.Client, namespace, name string) error {
+				deleteCount++
+				return nil
+			}
+			ctx := context.TODO()
+			builder := fake.NewClientBuilder()
+			c := builder.Build()
+			instance := AppSourceReconciler{
+				Client: c,
+				Scheme: scheme.Scheme,
+			}
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "test",
+					Namespace: namespace,
+				},
+			}
+			// reconcile for a missing AppSource notifies the referring CRs
+			_, err := instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deleteCount).To(Equal(1))
+			// create resource first and then reconcile with annotations for pause
+			appSource := &enterpriseApi.AppSource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   namespace,
+					Annotations: map[string]string{enterpriseApi.AppSourcePausedAnnotation: ""},
+				},
+			}
+			Expect(c.Create(ctx, appSource)).Should(Succeed())
+			result, err := instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(pauseRetryDelay))
+			Expect(applyCount).To(Equal(0))
+			// reconcile after removing annotations for pause
+			appSource.Annotations = map[string]string{}
+			Expect(c.Update(ctx, appSource)).Should(Succeed())
+			_, err = instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(applyCount).To(Equal(1))
+		})
+
+	})
+})
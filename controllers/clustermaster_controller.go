@@ -150,6 +150,8 @@ func (r *ClusterMasterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				IsController: false,
 				OwnerType:    &enterpriseApiV3.ClusterMaster{},
 			}).
+		Watches(&source.Channel{Source: enterprise.GetAppSourceChangeEvents("ClusterMaster")},
+			&handler.EnqueueRequestForObject{}).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: enterpriseApi.TotalWorker,
 		}).
@@ -149,6 +149,8 @@ func (r *LicenseMasterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				IsController: false,
 				OwnerType:    &enterpriseApiV3.LicenseMaster{},
 			}).
+		Watches(&source.Channel{Source: enterprise.GetAppSourceChangeEvents("LicenseMaster")},
+			&handler.EnqueueRequestForObject{}).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: enterpriseApi.TotalWorker,
 		}).
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("SplunkBackup Controller", func() {
+
+	Context("SplunkBackup Management", func() {
+
+		It("Reconcile SplunkBackup custom resource", func() {
+			namespace := "ns-splunk-splunkbackup-1"
+			var applyCount int
+			ApplySplunkBackup = func(ctx context.Context, client client.Client, instance *enterpriseApi.SplunkBackup) (reconcile.Result, error) {
+				applyCount++
+				return reconcile.Result{}, nil
+			}
+			ctx := context.TODO()
+			builder := fake.NewClientBuilder()
+			c := builder.Build()
+			instance := SplunkBackupReconciler{
+				Client: c,
+				Scheme: scheme.Scheme,
+			}
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "test",
+					Namespace: namespace,
+				},
+			}
+			// reconcile for a missing SplunkBackup is ignored
+			_, err := instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(applyCount).To(Equal(0))
+			// create resource first and then reconcile with annotations for pause
+			splunkBackup := &enterpriseApi.SplunkBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   namespace,
+					Annotations: map[string]string{enterpriseApi.SplunkBackupPausedAnnotation: ""},
+				},
+			}
+			Expect(c.Create(ctx, splunkBackup)).Should(Succeed())
+			result, err := instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(pauseRetryDelay))
+			Expect(applyCount).To(Equal(0))
+			// reconcile after removing annotations for pause
+			splunkBackup.Annotations = map[string]string{}
+			Expect(c.Update(ctx, splunkBackup)).Should(Succeed())
+			_, err = instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(applyCount).To(Equal(1))
+		})
+
+	})
+})
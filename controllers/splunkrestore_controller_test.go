@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("SplunkRestore Controller", func() {
+
+	Context("SplunkRestore Management", func() {
+
+		It("Reconcile SplunkRestore custom resource", func() {
+			namespace := "ns-splunk-splunkrestore-1"
+			var applyCount int
+			ApplySplunkRestore = func(ctx context.Context, client client.Client, instance *enterpriseApi.SplunkRestore) (reconcile.Result, error) {
+				applyCount++
+				return reconcile.Result{}, nil
+			}
+			ctx := context.TODO()
+			builder := fake.NewClientBuilder()
+			c := builder.Build()
+			instance := SplunkRestoreReconciler{
+				Client: c,
+				Scheme: scheme.Scheme,
+			}
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "test",
+					Namespace: namespace,
+				},
+			}
+			// reconcile for a missing SplunkRestore is ignored
+			_, err := instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(applyCount).To(Equal(0))
+			// create resource first and then reconcile with annotations for pause
+			splunkRestore := &enterpriseApi.SplunkRestore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   namespace,
+					Annotations: map[string]string{enterpriseApi.SplunkRestorePausedAnnotation: ""},
+				},
+			}
+			Expect(c.Create(ctx, splunkRestore)).Should(Succeed())
+			result, err := instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(pauseRetryDelay))
+			Expect(applyCount).To(Equal(0))
+			// reconcile after removing annotations for pause
+			splunkRestore.Annotations = map[string]string{}
+			Expect(c.Update(ctx, splunkRestore)).Should(Succeed())
+			_, err = instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(applyCount).To(Equal(1))
+		})
+
+	})
+})
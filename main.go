@@ -18,6 +18,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"time"
 
@@ -42,6 +43,8 @@ import (
 	"github.com/splunk/splunk-operator/controllers"
 	debug "github.com/splunk/splunk-operator/controllers/debug"
 	"github.com/splunk/splunk-operator/pkg/config"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	//+kubebuilder:scaffold:imports
 	//extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 )
@@ -66,6 +69,10 @@ func main() {
 	var pprofActive bool
 	var logEncoder string
 	var logLevel int
+	var appNotificationAddr string
+	var appMaxDownloads int
+	var appMaxPodCopies int
+	var appMaxInstalls int
 
 	var leaseDuration time.Duration
 	var renewDeadline time.Duration
@@ -80,6 +87,11 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&pprofActive, "pprof", true, "Enable pprof endpoint")
 	flag.IntVar(&logLevel, "log-level", int(zapcore.InfoLevel), "set log level")
+	flag.StringVar(&appNotificationAddr, "app-notification-bind-address", "", "The address the App Framework remote storage notification receiver binds to. "+
+		"Disabled when empty. The token expected from the notifications is read from the APP_NOTIFICATION_TOKEN environment variable, which is required.")
+	flag.IntVar(&appMaxDownloads, "app-framework-max-downloads", splcommon.DefaultOperatorMaxAppDownloads, "The max. number of App Framework app downloads running at the same time across all the CRs.")
+	flag.IntVar(&appMaxPodCopies, "app-framework-max-pod-copies", splcommon.DefaultOperatorMaxAppPodCopies, "The max. number of App Framework app pod copies running at the same time across all the CRs.")
+	flag.IntVar(&appMaxInstalls, "app-framework-max-installs", splcommon.DefaultOperatorMaxAppInstalls, "The max. number of App Framework app installs running at the same time across all the CRs.")
 	flag.IntVar(&leaseDurationSecond, "lease-duration", int(leaseDurationSecond), "manager lease duration in seconds")
 	flag.IntVar(&renewDeadlineSecond, "renew-duration", int(renewDeadlineSecond), "manager renew duration in seconds")
 
@@ -106,6 +118,8 @@ func main() {
 	// Logging setup
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	enterprise.SetAppPhaseSchedulerLimits(appMaxDownloads, appMaxPodCopies, appMaxInstalls)
+
 	options := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -179,8 +193,42 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Standalone")
 		os.Exit(1)
 	}
+	if err = (&controllers.AppSourceReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AppSource")
+		os.Exit(1)
+	}
+	if err = (&controllers.SplunkBackupReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SplunkBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.SplunkRestoreReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SplunkRestore")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
+	if appNotificationAddr != "" {
+		appNotificationToken := os.Getenv("APP_NOTIFICATION_TOKEN")
+		if appNotificationToken == "" {
+			setupLog.Error(fmt.Errorf("APP_NOTIFICATION_TOKEN is not set"), "unable to set up the App Framework notification receiver")
+			os.Exit(1)
+		}
+		receiver := enterprise.NewAppSourceNotificationReceiver(mgr.GetClient(), appNotificationAddr, appNotificationToken)
+		if err := mgr.Add(receiver); err != nil {
+			setupLog.Error(err, "unable to set up the App Framework notification receiver")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -29,6 +29,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -38,11 +39,34 @@ import (
 // blank assignment to verify that AWSS3Client implements RemoteDataClient
 var _ RemoteDataClient = &AWSS3Client{}
 
+// blank assignment to verify that AWSS3Client implements RemoteDataStreamClient
+var _ RemoteDataStreamClient = &AWSS3Client{}
+
+// blank assignment to verify that AWSS3Client implements RemoteDataUploadClient
+var _ RemoteDataUploadClient = &AWSS3Client{}
+
 // SplunkAWSS3Client is an interface to AWS S3 client
 type SplunkAWSS3Client interface {
 	ListObjectsV2(options *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
 }
 
+// SplunkAWSGetObjectClient is used to stream the apps from remote storage
+type SplunkAWSGetObjectClient interface {
+	GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error)
+}
+
+// blank assignment to verify that the AWS S3 client can stream the apps
+var _ SplunkAWSGetObjectClient = &s3.S3{}
+
+// SplunkAWSPutObjectClient is used to upload the backups to remote storage, and delete them
+type SplunkAWSPutObjectClient interface {
+	PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error)
+	DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error)
+}
+
+// blank assignment to verify that the AWS S3 client can upload the backups
+var _ SplunkAWSPutObjectClient = &s3.S3{}
+
 // SplunkAWSDownloadClient is used to download the apps from remote storage
 type SplunkAWSDownloadClient interface {
 	Download(w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (n int64, err error)
@@ -222,54 +246,156 @@ func (awsclient *AWSS3Client) GetAppsList(ctx context.Context) (RemoteDataListRe
 		Delimiter:  aws.String("/"),                  // limit the listing to 1 level only
 	}
 
+	// page through the listing, as a single call returns at most 4K keys
 	client := awsclient.Client
-	resp, err := client.ListObjectsV2(options)
-	if err != nil {
-		scopedLog.Error(err, "Unable to list items in bucket", "AWS S3 Bucket", awsclient.BucketName, "endpoint", awsclient.Endpoint)
-		return remoteDataClientResponse, err
+	var contents []*s3.Object
+	var commonPrefixes []*s3.CommonPrefix
+	for {
+		resp, err := client.ListObjectsV2(options)
+		if err != nil {
+			scopedLog.Error(err, "Unable to list items in bucket", "AWS S3 Bucket", awsclient.BucketName, "endpoint", awsclient.Endpoint)
+			return remoteDataClientResponse, err
+		}
+		contents = append(contents, resp.Contents...)
+		commonPrefixes = append(commonPrefixes, resp.CommonPrefixes...)
+
+		if !aws.BoolValue(resp.IsTruncated) || resp.NextContinuationToken == nil {
+			break
+		}
+		options.ContinuationToken = resp.NextContinuationToken
 	}
 
-	if resp.Contents == nil {
+	if contents == nil && commonPrefixes == nil {
 		scopedLog.Info("empty objects list in bucket. No apps to install", "bucketName", awsclient.BucketName)
 		return remoteDataClientResponse, nil
 	}
 
-	tmp, err := json.Marshal(resp.Contents)
+	var err error
+	remoteDataClientResponse.Objects, err = convertS3Objects(contents)
 	if err != nil {
-		scopedLog.Error(err, "Failed to marshal s3 response", "AWS S3 Bucket", awsclient.BucketName)
+		scopedLog.Error(err, "Failed to convert s3 response", "AWS S3 Bucket", awsclient.BucketName)
 		return remoteDataClientResponse, err
 	}
 
-	err = json.Unmarshal(tmp, &(remoteDataClientResponse.Objects))
-	if err != nil {
-		scopedLog.Error(err, "Failed to unmarshal s3 response", "AWS S3 Bucket", awsclient.BucketName)
-		return remoteDataClientResponse, err
+	// app directories are packaged during the download
+	for _, commonPrefix := range commonPrefixes {
+		if commonPrefix.Prefix == nil {
+			continue
+		}
+
+		objects, err := awsclient.listAppDirObjects(ctx, *commonPrefix.Prefix)
+		if err != nil {
+			scopedLog.Error(err, "Unable to list the app directory", "AWS S3 Bucket", awsclient.BucketName, "prefix", *commonPrefix.Prefix)
+			return remoteDataClientResponse, err
+		}
+		addAppDirToList(ctx, &remoteDataClientResponse, *commonPrefix.Prefix, objects)
 	}
 
 	return remoteDataClientResponse, nil
 }
 
+// convertS3Objects converts the S3 objects to the remote objects
+func convertS3Objects(contents []*s3.Object) ([]*RemoteObject, error) {
+	var objects []*RemoteObject
+	if contents == nil {
+		return objects, nil
+	}
+
+	tmp, err := json.Marshal(contents)
+	if err != nil {
+		return objects, err
+	}
+
+	err = json.Unmarshal(tmp, &objects)
+	return objects, err
+}
+
+// listAppDirObjects lists all the objects under the app directory, at all the levels
+func (awsclient *AWSS3Client) listAppDirObjects(ctx context.Context, dirPrefix string) ([]*RemoteObject, error) {
+	options := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(awsclient.BucketName),
+		Prefix:  aws.String(dirPrefix),
+		MaxKeys: aws.Int64(4000), // return upto 4K keys from S3
+	}
+
+	var contents []*s3.Object
+	for {
+		resp, err := awsclient.Client.ListObjectsV2(options)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, resp.Contents...)
+
+		if !aws.BoolValue(resp.IsTruncated) || resp.NextContinuationToken == nil {
+			break
+		}
+		options.ContinuationToken = resp.NextContinuationToken
+	}
+
+	return convertS3Objects(contents)
+}
+
+// downloadAppDir downloads the objects of the app directory, and packages them into the local file
+func (awsclient *AWSS3Client) downloadAppDir(ctx context.Context, downloadRequest RemoteDataDownloadRequest) error {
+	objects, err := awsclient.listAppDirObjects(ctx, getAppDirPrefix(downloadRequest.RemoteFile))
+	if err != nil {
+		return err
+	}
+
+	return downloadAppDir(ctx, downloadRequest, objects, func(object *RemoteObject, localFile string) error {
+		file, err := os.Create(localFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = awsclient.Downloader.Download(file, &s3.GetObjectInput{
+			Bucket:  aws.String(awsclient.BucketName),
+			Key:     object.Key,
+			IfMatch: object.Etag,
+		})
+		return err
+	})
+}
+
 // DownloadApp downloads the app from remote storage to local file system
 func (awsclient *AWSS3Client) DownloadApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (bool, error) {
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("DownloadApp").WithValues("remoteFile", downloadRequest.RemoteFile, "localFile",
 		downloadRequest.LocalFile, "etag", downloadRequest.Etag)
 
+	if IsAppDirEtag(downloadRequest.Etag) {
+		err := awsclient.downloadAppDir(ctx, downloadRequest)
+		if err != nil {
+			scopedLog.Error(err, "Unable to download app directory", "RemoteFile", downloadRequest.RemoteFile)
+			return false, err
+		}
+		return true, nil
+	}
+
 	var numBytes int64
-	file, err := os.Create(downloadRequest.LocalFile)
+	file, err := openDownloadFile(downloadRequest)
 	if err != nil {
 		scopedLog.Error(err, "Unable to open local file")
 		return false, err
 	}
 	defer file.Close()
 
+	input := &s3.GetObjectInput{
+		Bucket:  aws.String(awsclient.BucketName),
+		Key:     aws.String(downloadRequest.RemoteFile),
+		IfMatch: aws.String(downloadRequest.Etag),
+	}
+
+	// Resume the partial download with a ranged request
+	var writer io.WriterAt = file
+	if downloadRequest.StartOffset > 0 {
+		input.Range = aws.String(getRangeHeader(downloadRequest.StartOffset))
+		writer = io.NewOffsetWriter(file, downloadRequest.StartOffset)
+	}
+
 	downloader := awsclient.Downloader
-	numBytes, err = downloader.Download(file,
-		&s3.GetObjectInput{
-			Bucket:  aws.String(awsclient.BucketName),
-			Key:     aws.String(downloadRequest.RemoteFile),
-			IfMatch: aws.String(downloadRequest.Etag),
-		})
+	numBytes, err = downloader.Download(writer, input)
 	if err != nil {
 		scopedLog.Error(err, "Unable to download item", "RemoteFile", downloadRequest.RemoteFile)
 		os.Remove(downloadRequest.RemoteFile)
@@ -280,3 +406,73 @@ func (awsclient *AWSS3Client) DownloadApp(ctx context.Context, downloadRequest R
 
 	return true, err
 }
+
+// StreamApp opens a stream to read an app package from remote storage
+func (awsclient *AWSS3Client) StreamApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (io.ReadCloser, error) {
+	getObjectClient, ok := awsclient.Client.(SplunkAWSGetObjectClient)
+	if !ok {
+		return nil, fmt.Errorf("the s3 client doesn't support streaming the apps")
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket:  aws.String(awsclient.BucketName),
+		Key:     aws.String(downloadRequest.RemoteFile),
+		IfMatch: aws.String(downloadRequest.Etag),
+	}
+
+	output, err := getObjectClient.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Body, nil
+}
+
+// UploadData uploads a local file to remote storage
+func (awsclient *AWSS3Client) UploadData(ctx context.Context, uploadRequest RemoteDataUploadRequest) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("UploadData").WithValues("remoteFile", uploadRequest.RemoteFile, "localFile", uploadRequest.LocalFile)
+
+	putObjectClient, ok := awsclient.Client.(SplunkAWSPutObjectClient)
+	if !ok {
+		return fmt.Errorf("the s3 client doesn't support uploading data")
+	}
+
+	file, err := os.Open(uploadRequest.LocalFile)
+	if err != nil {
+		scopedLog.Error(err, "Unable to open local file")
+		return err
+	}
+	defer file.Close()
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(awsclient.BucketName),
+		Key:    aws.String(uploadRequest.RemoteFile),
+		Body:   file,
+	}
+
+	_, err = putObjectClient.PutObjectWithContext(ctx, input)
+	if err != nil {
+		scopedLog.Error(err, "Unable to upload file")
+		return err
+	}
+
+	scopedLog.Info("File uploaded")
+	return nil
+}
+
+// DeleteData deletes a file from remote storage
+func (awsclient *AWSS3Client) DeleteData(ctx context.Context, remoteFile string) error {
+	putObjectClient, ok := awsclient.Client.(SplunkAWSPutObjectClient)
+	if !ok {
+		return fmt.Errorf("the s3 client doesn't support deleting data")
+	}
+
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(awsclient.BucketName),
+		Key:    aws.String(remoteFile),
+	}
+
+	_, err := putObjectClient.DeleteObjectWithContext(ctx, input)
+	return err
+}
@@ -18,11 +18,16 @@ package client
 import (
 	"context"
 	"crypto/tls"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
 	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
 
 	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
@@ -568,3 +573,139 @@ func TestAWSDownloadAppShouldFail(t *testing.T) {
 		t.Errorf("DownloadApp should have returned error since remoteFile name is empty")
 	}
 }
+
+// mockAWSS3AppDirClient lists an app package and an app directory, over two pages
+type mockAWSS3AppDirClient struct{}
+
+// ListObjectsV2 lists the app directory as a common prefix, and its files only when listed with the directory prefix
+func (mockClient mockAWSS3AppDirClient) ListObjectsV2(options *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	lastModified := time.Now()
+	nextPage := options.ContinuationToken != nil && *options.ContinuationToken == "page2"
+	if options.Delimiter != nil {
+		if !nextPage {
+			return &s3.ListObjectsV2Output{
+				Contents: []*s3.Object{
+					{Key: aws.String("appsRepo/app1.tgz"), ETag: aws.String("etag1"), LastModified: &lastModified, Size: aws.Int64(10)},
+				},
+				IsTruncated:           aws.Bool(true),
+				NextContinuationToken: aws.String("page2"),
+			}, nil
+		}
+		return &s3.ListObjectsV2Output{
+			CommonPrefixes: []*s3.CommonPrefix{{Prefix: aws.String("appsRepo/app2/")}},
+		}, nil
+	}
+
+	if *options.Prefix != "appsRepo/app2/" {
+		return &s3.ListObjectsV2Output{}, nil
+	}
+	if !nextPage {
+		return &s3.ListObjectsV2Output{
+			Contents: []*s3.Object{
+				{Key: aws.String("appsRepo/app2/default/app.conf"), ETag: aws.String("etag2"), LastModified: &lastModified, Size: aws.Int64(20)},
+			},
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("page2"),
+		}, nil
+	}
+	return &s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("appsRepo/app2/metadata/local.meta"), ETag: aws.String("etag3"), LastModified: &lastModified, Size: aws.Int64(30)},
+		},
+	}, nil
+}
+
+func TestAWSAppDir(t *testing.T) {
+	ctx := context.TODO()
+	awsClient := &AWSS3Client{
+		BucketName: "bucket",
+		Prefix:     "appsRepo/",
+		Client:     mockAWSS3AppDirClient{},
+		Downloader: spltest.MockAWSDownloadClient{},
+	}
+
+	response, err := awsClient.GetAppsList(ctx)
+	if err != nil {
+		t.Fatalf("apps list should be fetched. error: %v", err)
+	}
+	if len(response.Objects) != 2 || *response.Objects[1].Key != "appsRepo/app2.tgz" || !IsAppDirEtag(*response.Objects[1].Etag) {
+		t.Fatalf("app directory should be listed as an app package. got: %v", response.Objects)
+	}
+
+	// the files of the app directory are listed from all the pages
+	dirObjects := []*RemoteObject{
+		{Key: aws.String("appsRepo/app2/default/app.conf"), Etag: aws.String("etag2")},
+		{Key: aws.String("appsRepo/app2/metadata/local.meta"), Etag: aws.String("etag3")},
+	}
+	if *response.Objects[1].Etag != getAppDirEtag("appsRepo/app2/", dirObjects) {
+		t.Errorf("app directory etag should be derived from the files on all the pages")
+	}
+
+	downloadRequest := RemoteDataDownloadRequest{
+		LocalFile:  filepath.Join(t.TempDir(), "app2.tgz"),
+		RemoteFile: *response.Objects[1].Key,
+		Etag:       *response.Objects[1].Etag,
+	}
+	_, err = awsClient.DownloadApp(ctx, downloadRequest)
+	if err != nil {
+		t.Errorf("app directory should be downloaded. error: %v", err)
+	}
+	if _, err = os.Stat(downloadRequest.LocalFile); err != nil {
+		t.Errorf("app directory should be packaged. error: %v", err)
+	}
+}
+
+// mockAWSPutObjectClient records the uploads and the deletes of the backups
+type mockAWSPutObjectClient struct {
+	spltest.MockAWSS3Client
+	uploads map[string]string
+	deletes []string
+}
+
+func (mockClient *mockAWSPutObjectClient) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	mockClient.uploads[*input.Bucket+"/"+*input.Key] = string(data)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (mockClient *mockAWSPutObjectClient) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	mockClient.deletes = append(mockClient.deletes, *input.Bucket+"/"+*input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestAWSUploadData(t *testing.T) {
+	ctx := context.TODO()
+	localFile := filepath.Join(t.TempDir(), "kvstore.tar.gz")
+	err := os.WriteFile(localFile, []byte("backup data"), 0644)
+	if err != nil {
+		t.Fatalf("unable to create the local file. error: %v", err)
+	}
+
+	mockClient := &mockAWSPutObjectClient{uploads: make(map[string]string)}
+	awsClient := &AWSS3Client{BucketName: "backups", Client: mockClient}
+
+	err = awsClient.UploadData(ctx, RemoteDataUploadRequest{LocalFile: localFile, RemoteFile: "stack1/kvstore.tar.gz"})
+	if err != nil || mockClient.uploads["backups/stack1/kvstore.tar.gz"] != "backup data" {
+		t.Errorf("UploadData should upload the local file. uploads: %v, error: %v", mockClient.uploads, err)
+	}
+
+	err = awsClient.DeleteData(ctx, "stack1/kvstore.tar.gz")
+	if err != nil || len(mockClient.deletes) != 1 || mockClient.deletes[0] != "backups/stack1/kvstore.tar.gz" {
+		t.Errorf("DeleteData should delete the remote file. deletes: %v, error: %v", mockClient.deletes, err)
+	}
+
+	err = awsClient.UploadData(ctx, RemoteDataUploadRequest{LocalFile: localFile + ".missing", RemoteFile: "stack1/kvstore.tar.gz"})
+	if err == nil {
+		t.Errorf("UploadData should fail when the local file is missing")
+	}
+
+	// the client doesn't support uploads
+	awsClient.Client = spltest.MockAWSS3Client{}
+	err = awsClient.UploadData(ctx, RemoteDataUploadRequest{LocalFile: localFile, RemoteFile: "stack1/kvstore.tar.gz"})
+	if err == nil {
+		t.Errorf("UploadData should fail when the client doesn't support uploads")
+	}
+}
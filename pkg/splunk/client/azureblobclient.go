@@ -19,9 +19,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
+	"crypto/md5"
 	"crypto/sha256"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -30,6 +32,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
@@ -41,6 +44,9 @@ import (
 // blank assignment to verify that AzureBlobClient implements RemoteDataClient
 var _ RemoteDataClient = &AzureBlobClient{}
 
+// blank assignment to verify that AzureBlobClient implements RemoteDataUploadClient
+var _ RemoteDataUploadClient = &AzureBlobClient{}
+
 // AzureBlobClient is a client to implement Azure Blob specific APIs
 type AzureBlobClient struct {
 	BucketName         string
@@ -75,8 +81,9 @@ type Blobs struct {
 
 // EnumerationResults holds unmarshaled data from listing APIs
 type EnumerationResults struct {
-	XMLName xml.Name `xml:"EnumerationResults"`
-	Blobs   Blobs    `xml:"Blobs"`
+	XMLName    xml.Name `xml:"EnumerationResults"`
+	Blobs      Blobs    `xml:"Blobs"`
+	NextMarker string   `xml:"NextMarker"`
 }
 
 // TokenResponse holds the unmarshaled oauth token
@@ -347,14 +354,81 @@ func (client *AzureBlobClient) GetAppsList(ctx context.Context) (RemoteDataListR
 
 	scopedLog.Info("Getting Apps list")
 
+	objects, err := client.listBlobs(ctx, client.Prefix)
+	if err != nil {
+		return RemoteDataListResponse{}, err
+	}
+
+	// The blobs are listed at all the levels, so the blobs under a directory are grouped into an app directory,
+	// which is packaged during the download
+	azureRemoteDataResponse := RemoteDataListResponse{}
+	var dirPrefixes []string
+	dirObjects := make(map[string][]*RemoteObject)
+	for _, object := range objects {
+		relPath := strings.TrimPrefix(*object.Key, client.Prefix)
+		idx := strings.Index(relPath, "/")
+		if idx < 0 {
+			azureRemoteDataResponse.Objects = append(azureRemoteDataResponse.Objects, object)
+			continue
+		}
+
+		dirPrefix := client.Prefix + relPath[:idx+1]
+		if _, ok := dirObjects[dirPrefix]; !ok {
+			dirPrefixes = append(dirPrefixes, dirPrefix)
+		}
+		dirObjects[dirPrefix] = append(dirObjects[dirPrefix], object)
+	}
+
+	for _, dirPrefix := range dirPrefixes {
+		addAppDirToList(ctx, &azureRemoteDataResponse, dirPrefix, dirObjects[dirPrefix])
+	}
+
+	// Successfully listed apps
+	scopedLog.Info("Listing apps successful")
+
+	return azureRemoteDataResponse, nil
+}
+
+// listBlobs lists all the blobs under the prefix, at all the levels, paging through the listing
+func (client *AzureBlobClient) listBlobs(ctx context.Context, prefix string) ([]*RemoteObject, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("AzureBlob:listBlobs").WithValues("Endpoint", client.Endpoint, "Bucket", client.BucketName,
+		"Prefix", prefix)
+
+	var objects []*RemoteObject
+	var marker string
+	for {
+		azureRemoteDataResponse, nextMarker, err := client.listBlobsPage(ctx, prefix, marker)
+		if err != nil {
+			scopedLog.Error(err, "Unable to list the blobs", "marker", marker)
+			return nil, err
+		}
+		objects = append(objects, azureRemoteDataResponse.Objects...)
+
+		if nextMarker == "" {
+			return objects, nil
+		}
+		marker = nextMarker
+	}
+}
+
+// listBlobsPage lists a page of the blobs under the prefix, starting at the marker, and returns the marker of the next page
+func (client *AzureBlobClient) listBlobsPage(ctx context.Context, prefix string, marker string) (RemoteDataListResponse, string, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("AzureBlob:listBlobsPage").WithValues("Endpoint", client.Endpoint, "Bucket", client.BucketName,
+		"Prefix", prefix)
+
 	// create rest request URL with storage account name, container, prefix
-	appsListFetchURL := fmt.Sprintf(azureBlobListAppFetchURL, client.Endpoint, client.BucketName, client.Prefix)
+	appsListFetchURL := fmt.Sprintf(azureBlobListAppFetchURL, client.Endpoint, client.BucketName, prefix)
+	if marker != "" {
+		appsListFetchURL += fmt.Sprintf(azureBlobListMarkerParam, url.QueryEscape(marker))
+	}
 
 	// Create a http request with the URL
 	httpRequest, err := http.NewRequest("GET", appsListFetchURL, nil)
 	if err != nil {
 		scopedLog.Error(err, "Azure Blob Failed to create request for App fetch URL")
-		return RemoteDataListResponse{}, err
+		return RemoteDataListResponse{}, "", err
 	}
 
 	// Setup the httpRequest with required authentication
@@ -367,14 +441,14 @@ func (client *AzureBlobClient) GetAppsList(ctx context.Context) (RemoteDataListR
 	}
 	if err != nil {
 		scopedLog.Error(err, "Failed to get http request authenticated")
-		return RemoteDataListResponse{}, err
+		return RemoteDataListResponse{}, "", err
 	}
 
 	// List the apps
 	httpResponse, err := client.HTTPClient.Do(httpRequest)
 	if err != nil {
 		scopedLog.Error(err, "Azure blob, unable to execute list apps http request")
-		return RemoteDataListResponse{}, err
+		return RemoteDataListResponse{}, "", err
 	}
 
 	defer httpResponse.Body.Close()
@@ -382,24 +456,21 @@ func (client *AzureBlobClient) GetAppsList(ctx context.Context) (RemoteDataListR
 	// Authorization unsuccessul
 	if httpResponse.StatusCode != 200 {
 		err = errors.New("error authorizing the rest call. check your IAM/secret configuration")
-		return RemoteDataListResponse{}, err
+		return RemoteDataListResponse{}, "", err
 	}
 
 	// Extract response
-	azureRemoteDataResponse, err := extractResponse(ctx, httpResponse)
+	azureRemoteDataResponse, nextMarker, err := extractResponse(ctx, httpResponse)
 	if err != nil {
 		scopedLog.Error(err, "unable to extract app packages list from http response")
-		return azureRemoteDataResponse, err
+		return azureRemoteDataResponse, "", err
 	}
 
-	// Successfully listed apps
-	scopedLog.Info("Listing apps successful")
-
-	return azureRemoteDataResponse, err
+	return azureRemoteDataResponse, nextMarker, nil
 }
 
-// Extract data from httpResponse and fill it in RemoteDataListResponse structs
-func extractResponse(ctx context.Context, httpResponse *http.Response) (RemoteDataListResponse, error) {
+// Extract data from httpResponse and fill it in RemoteDataListResponse structs, along with the marker of the next page
+func extractResponse(ctx context.Context, httpResponse *http.Response) (RemoteDataListResponse, string, error) {
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("AzureBlob:extractResponse")
 
@@ -409,7 +480,7 @@ func extractResponse(ctx context.Context, httpResponse *http.Response) (RemoteDa
 	responseBody, err := io.ReadAll(httpResponse.Body)
 	if err != nil {
 		scopedLog.Error(err, "Errored when reading resp body for app packages list rest call")
-		return azureAppsRemoteData, err
+		return azureAppsRemoteData, "", err
 	}
 
 	// Variable to hold unmarshaled data
@@ -419,7 +490,7 @@ func extractResponse(ctx context.Context, httpResponse *http.Response) (RemoteDa
 	err = xml.Unmarshal(responseBody, data)
 	if err != nil {
 		scopedLog.Error(err, "Errored  unmarshalling app packages list", "rest call response:", string(responseBody))
-		return azureAppsRemoteData, err
+		return azureAppsRemoteData, "", err
 	}
 
 	// Extract data from all blobs
@@ -451,25 +522,28 @@ func extractResponse(ctx context.Context, httpResponse *http.Response) (RemoteDa
 		azureAppsRemoteData.Objects = append(azureAppsRemoteData.Objects, &newRemoteObject)
 	}
 
-	return azureAppsRemoteData, nil
+	return azureAppsRemoteData, data.NextMarker, nil
 }
 
-// DownloadApp downloads an app package from remote storage
-func (client *AzureBlobClient) DownloadApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (bool, error) {
+// getAppPackage sends the authenticated request to download an app package
+func (client *AzureBlobClient) getAppPackage(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (*http.Response, error) {
 	reqLogger := log.FromContext(ctx)
-	scopedLog := reqLogger.WithName("AzureBlob:DownloadApp").WithValues("Endpoint", client.Endpoint, "Bucket", client.BucketName,
+	scopedLog := reqLogger.WithName("AzureBlob:getAppPackage").WithValues("Endpoint", client.Endpoint, "Bucket", client.BucketName,
 		"Prefix", client.Prefix, "downloadRequest", downloadRequest)
 
-	scopedLog.Info("Download App package")
-
 	// create rest request URL with storage account name, container, prefix
 	appPackageFetchURL := fmt.Sprintf(azureBlobDownloadAppFetchURL, client.Endpoint, client.BucketName, downloadRequest.RemoteFile)
 
 	// Create a http request with the URL
-	httpRequest, err := http.NewRequest("GET", appPackageFetchURL, nil)
+	httpRequest, err := http.NewRequestWithContext(ctx, "GET", appPackageFetchURL, nil)
 	if err != nil {
 		scopedLog.Error(err, "Azure Blob Failed to create request for App package fetch URL")
-		return false, err
+		return nil, err
+	}
+
+	// Resume the partial download with a ranged request
+	if downloadRequest.StartOffset > 0 {
+		httpRequest.Header.Set(headerRange, getRangeHeader(downloadRequest.StartOffset))
 	}
 
 	// Setup the httpRequest with required authentication
@@ -482,7 +556,7 @@ func (client *AzureBlobClient) DownloadApp(ctx context.Context, downloadRequest
 	}
 	if err != nil {
 		scopedLog.Error(err, "Failed to get http request authenticated")
-		return false, err
+		return nil, err
 	}
 
 	scopedLog.Info("Calling the download rest request")
@@ -491,19 +565,75 @@ func (client *AzureBlobClient) DownloadApp(ctx context.Context, downloadRequest
 	httpResponse, err := client.HTTPClient.Do(httpRequest)
 	if err != nil {
 		scopedLog.Error(err, "Azure blob, unable to execute download apps http request")
-		return false, err
+		return nil, err
 	}
 
-	defer httpResponse.Body.Close()
-
 	// Authorization unsuccessul for download rest call
-	if httpResponse.StatusCode != 200 {
+	if httpResponse.StatusCode != 200 && httpResponse.StatusCode != 206 {
+		httpResponse.Body.Close()
 		err = errors.New("error authorizing the rest call. check your IAM/secret configuration")
+		return nil, err
+	}
+
+	return httpResponse, nil
+}
+
+// downloadAppDir downloads the blobs of the app directory, and packages them into the local file
+func (client *AzureBlobClient) downloadAppDir(ctx context.Context, downloadRequest RemoteDataDownloadRequest) error {
+	objects, err := client.listBlobs(ctx, getAppDirPrefix(downloadRequest.RemoteFile))
+	if err != nil {
+		return err
+	}
+
+	return downloadAppDir(ctx, downloadRequest, objects, func(object *RemoteObject, localFile string) error {
+		httpResponse, err := client.getAppPackage(ctx, RemoteDataDownloadRequest{RemoteFile: *object.Key})
+		if err != nil {
+			return err
+		}
+		defer httpResponse.Body.Close()
+
+		file, err := os.Create(localFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(file, httpResponse.Body)
+		return err
+	})
+}
+
+// DownloadApp downloads an app package from remote storage
+func (client *AzureBlobClient) DownloadApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("AzureBlob:DownloadApp").WithValues("Endpoint", client.Endpoint, "Bucket", client.BucketName,
+		"Prefix", client.Prefix, "downloadRequest", downloadRequest)
+
+	scopedLog.Info("Download App package")
+
+	if IsAppDirEtag(downloadRequest.Etag) {
+		err := client.downloadAppDir(ctx, downloadRequest)
+		if err != nil {
+			scopedLog.Error(err, "Unable to download app directory")
+			return false, err
+		}
+		return true, nil
+	}
+
+	httpResponse, err := client.getAppPackage(ctx, downloadRequest)
+	if err != nil {
 		return false, err
 	}
 
+	defer httpResponse.Body.Close()
+
+	// Start from the beginning, if the range is not honoured
+	if httpResponse.StatusCode != 206 {
+		downloadRequest.StartOffset = 0
+	}
+
 	// Create local file on operator
-	localFile, err := os.Create(downloadRequest.LocalFile)
+	localFile, err := openDownloadFile(downloadRequest)
 	if err != nil {
 		scopedLog.Error(err, "Unable to open local file")
 		return false, err
@@ -525,6 +655,104 @@ func (client *AzureBlobClient) DownloadApp(ctx context.Context, downloadRequest
 	return true, err
 }
 
+// StreamApp opens a stream to read an app package from remote storage
+func (client *AzureBlobClient) StreamApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (io.ReadCloser, error) {
+	downloadRequest.StartOffset = 0
+	httpResponse, err := client.getAppPackage(ctx, downloadRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// the etag of a blob is not a checksum, so the MD5 of the blob is verified, when it is set
+	contentMD5, err := base64.StdEncoding.DecodeString(httpResponse.Header.Get(headerContentMD5))
+	if err != nil || len(contentMD5) != md5.Size {
+		return httpResponse.Body, nil
+	}
+
+	return &checksumVerifyingReader{
+		body:           httpResponse.Body,
+		hash:           md5.New(),
+		appName:        path.Base(downloadRequest.RemoteFile),
+		expectedDigest: hex.EncodeToString(contentMD5),
+	}, nil
+}
+
+// sendBlobRequest sends an authenticated request for a blob, and checks the response status
+func (client *AzureBlobClient) sendBlobRequest(ctx context.Context, httpRequest *http.Request, wantStatus int) error {
+	var err error
+	if client.StorageAccountName != "" && client.SecretAccessKey != "" {
+		// Use Secrets
+		err = updateAzureHTTPRequestHeaderWithSecrets(ctx, client, httpRequest)
+	} else {
+		// No Secret provided, try using IAM
+		err = updateAzureHTTPRequestHeaderWithIAM(ctx, client, httpRequest)
+	}
+	if err != nil {
+		return err
+	}
+
+	httpResponse, err := client.HTTPClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != wantStatus {
+		return fmt.Errorf("%s request for blob failed with status code %d", httpRequest.Method, httpResponse.StatusCode)
+	}
+	return nil
+}
+
+// UploadData uploads a local file to remote storage, as a block blob
+func (client *AzureBlobClient) UploadData(ctx context.Context, uploadRequest RemoteDataUploadRequest) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("AzureBlob:UploadData").WithValues("Endpoint", client.Endpoint, "Bucket", client.BucketName,
+		"uploadRequest", uploadRequest)
+
+	file, err := os.Open(uploadRequest.LocalFile)
+	if err != nil {
+		scopedLog.Error(err, "Unable to open local file")
+		return err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	uploadURL := fmt.Sprintf(azureBlobUploadDataURL, client.Endpoint, client.BucketName, uploadRequest.RemoteFile)
+	httpRequest, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, file)
+	if err != nil {
+		scopedLog.Error(err, "Azure Blob Failed to create request for upload URL")
+		return err
+	}
+	httpRequest.ContentLength = fileInfo.Size()
+	// the content length is a part of the signature
+	httpRequest.Header.Set(headerContentLength, strconv.FormatInt(fileInfo.Size(), 10))
+	httpRequest.Header.Set(headerXmsBlobType, "BlockBlob")
+
+	err = client.sendBlobRequest(ctx, httpRequest, http.StatusCreated)
+	if err != nil {
+		scopedLog.Error(err, "Unable to upload file")
+		return err
+	}
+
+	scopedLog.Info("File uploaded")
+	return nil
+}
+
+// DeleteData deletes a blob from remote storage
+func (client *AzureBlobClient) DeleteData(ctx context.Context, remoteFile string) error {
+	deleteURL := fmt.Sprintf(azureBlobUploadDataURL, client.Endpoint, client.BucketName, remoteFile)
+	httpRequest, err := http.NewRequestWithContext(ctx, "DELETE", deleteURL, nil)
+	if err != nil {
+		return err
+	}
+
+	return client.sendBlobRequest(ctx, httpRequest, http.StatusAccepted)
+}
+
 // RegisterAzureBlobClient will add the corresponding function pointer to the map
 func RegisterAzureBlobClient() {
 	wrapperObject := GetRemoteDataClientWrapper{GetRemoteDataClient: NewAzureBlobClient, GetInitFunc: InitAzureBlobClientWrapper}
@@ -17,10 +17,13 @@ package client
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -1202,3 +1205,183 @@ func TestAzureBlobDownloadAppShouldFailInvalidIdentity(t *testing.T) {
 	}
 	mclient.RemoveHandlers()
 }
+
+// getAzureBlobListResponse returns the listing of the blobs, with the marker of the next page
+func getAzureBlobListResponse(nextMarker string, names ...string) string {
+	respdata := &EnumerationResults{NextMarker: nextMarker}
+	for _, name := range names {
+		respdata.Blobs.Blob = append(respdata.Blobs.Blob, Blob{
+			Name: name,
+			Properties: ContainerProperties{
+				CreationTime:  time.Now().UTC().Format(http.TimeFormat),
+				LastModified:  time.Now().UTC().Format(http.TimeFormat),
+				ETag:          "etag-" + name,
+				ContentLength: fmt.Sprint(64),
+			},
+		})
+	}
+	mrespdata, _ := xml.Marshal(respdata)
+	return string(mrespdata)
+}
+
+func TestAzureBlobAppDir(t *testing.T) {
+	ctx := context.TODO()
+	mclient := spltest.MockHTTPClient{}
+	azureBlobClient := &AzureBlobClient{
+		BucketName:         "appscontainer1",
+		StorageAccountName: "mystorageaccount",
+		SecretAccessKey:    "abcd",
+		Prefix:             "adminAppsRepo/",
+		Endpoint:           "https://mystorageaccount.blob.core.windows.net",
+		HTTPClient:         &mclient,
+	}
+
+	// the listing is paged, and the blobs of the app directory are split across the pages
+	listURL := "https://mystorageaccount.blob.core.windows.net/appscontainer1?prefix=%s&restype=container&comp=list&include=snapshots&include=metadata"
+	wantRequest, _ := http.NewRequest("GET", fmt.Sprintf(listURL, "adminAppsRepo/"), nil)
+	mclient.AddHandler(wantRequest, 200, getAzureBlobListResponse("page2", "adminAppsRepo/app1.tgz", "adminAppsRepo/app2/default/app.conf"), nil)
+	wantRequest, _ = http.NewRequest("GET", fmt.Sprintf(listURL, "adminAppsRepo/")+"&marker=page2", nil)
+	mclient.AddHandler(wantRequest, 200, getAzureBlobListResponse("", "adminAppsRepo/app2/metadata/local.meta"), nil)
+
+	response, err := azureBlobClient.GetAppsList(ctx)
+	if err != nil {
+		t.Fatalf("GetAppsList should not return error. error: %v", err)
+	}
+	if len(response.Objects) != 2 || *response.Objects[0].Key != "adminAppsRepo/app1.tgz" || *response.Objects[1].Key != "adminAppsRepo/app2.tgz" || !IsAppDirEtag(*response.Objects[1].Etag) {
+		t.Fatalf("app directory should be listed as an app package. got: %v", response.Objects)
+	}
+
+	var dirObjects []*RemoteObject
+	for _, key := range []string{"adminAppsRepo/app2/default/app.conf", "adminAppsRepo/app2/metadata/local.meta"} {
+		key, etag := key, "etag-"+key
+		dirObjects = append(dirObjects, &RemoteObject{Key: &key, Etag: &etag})
+	}
+	if *response.Objects[1].Etag != getAppDirEtag("adminAppsRepo/app2/", dirObjects) {
+		t.Errorf("app directory etag should be derived from the blobs on all the pages")
+	}
+
+	// the app directory is listed again, and its blobs are downloaded and packaged
+	wantRequest, _ = http.NewRequest("GET", fmt.Sprintf(listURL, "adminAppsRepo/app2/"), nil)
+	mclient.AddHandler(wantRequest, 200, getAzureBlobListResponse("", "adminAppsRepo/app2/default/app.conf", "adminAppsRepo/app2/metadata/local.meta"), nil)
+	wantRequest, _ = http.NewRequest("GET", "https://mystorageaccount.blob.core.windows.net/appscontainer1/adminAppsRepo/app2/default/app.conf", nil)
+	mclient.AddHandler(wantRequest, 200, "[install]", nil)
+	wantRequest, _ = http.NewRequest("GET", "https://mystorageaccount.blob.core.windows.net/appscontainer1/adminAppsRepo/app2/metadata/local.meta", nil)
+	mclient.AddHandler(wantRequest, 200, "[]", nil)
+
+	downloadRequest := RemoteDataDownloadRequest{
+		LocalFile:  t.TempDir() + "/app2.tgz",
+		RemoteFile: *response.Objects[1].Key,
+		Etag:       *response.Objects[1].Etag,
+	}
+	_, err = azureBlobClient.DownloadApp(ctx, downloadRequest)
+	if err != nil {
+		t.Errorf("app directory should be downloaded. error: %v", err)
+	}
+	if _, err = os.Stat(downloadRequest.LocalFile); err != nil {
+		t.Errorf("app directory should be packaged. error: %v", err)
+	}
+
+	// a change to the app directory after the listing fails the download
+	wantRequest, _ = http.NewRequest("GET", fmt.Sprintf(listURL, "adminAppsRepo/app2/"), nil)
+	mclient.AddHandler(wantRequest, 200, getAzureBlobListResponse("", "adminAppsRepo/app2/default/app.conf"), nil)
+	_, err = azureBlobClient.DownloadApp(ctx, downloadRequest)
+	if err == nil {
+		t.Errorf("DownloadApp should return error when the app directory has changed since the listing")
+	}
+}
+
+func TestAzureBlobUploadData(t *testing.T) {
+	ctx := context.TODO()
+	localFile := t.TempDir() + "/kvstore.tar.gz"
+	err := os.WriteFile(localFile, []byte("backup data"), 0644)
+	if err != nil {
+		t.Fatalf("unable to create the local file. error: %v", err)
+	}
+
+	mclient := spltest.MockHTTPClient{}
+	azureBlobClient := &AzureBlobClient{
+		BucketName:         "backups",
+		StorageAccountName: "mystorageaccount",
+		SecretAccessKey:    "abcd",
+		Endpoint:           "https://mystorageaccount.blob.core.windows.net",
+		HTTPClient:         &mclient,
+	}
+
+	wantRequest, _ := http.NewRequest("PUT", "https://mystorageaccount.blob.core.windows.net/backups/stack1/kvstore.tar.gz", nil)
+	mclient.AddHandler(wantRequest, 201, "", nil)
+	wantRequest, _ = http.NewRequest("DELETE", "https://mystorageaccount.blob.core.windows.net/backups/stack1/kvstore.tar.gz", nil)
+	mclient.AddHandler(wantRequest, 202, "", nil)
+
+	err = azureBlobClient.UploadData(ctx, RemoteDataUploadRequest{LocalFile: localFile, RemoteFile: "stack1/kvstore.tar.gz"})
+	if err != nil {
+		t.Errorf("UploadData should not return error. error: %v", err)
+	}
+	gotRequest := mclient.GotRequests[0]
+	if gotRequest.Header.Get(headerXmsBlobType) != "BlockBlob" || gotRequest.ContentLength != int64(len("backup data")) || gotRequest.Header.Get(headerAuthorization) == "" {
+		t.Errorf("UploadData should send an authenticated block blob request. headers: %v", gotRequest.Header)
+	}
+
+	err = azureBlobClient.DeleteData(ctx, "stack1/kvstore.tar.gz")
+	if err != nil {
+		t.Errorf("DeleteData should not return error. error: %v", err)
+	}
+	mclient.CheckRequests(t, "TestAzureBlobUploadData")
+
+	// unexpected status
+	mclient.RemoveHandlers()
+	wantRequest, _ = http.NewRequest("PUT", "https://mystorageaccount.blob.core.windows.net/backups/stack1/kvstore.tar.gz", nil)
+	mclient.AddHandler(wantRequest, 403, "", nil)
+	err = azureBlobClient.UploadData(ctx, RemoteDataUploadRequest{LocalFile: localFile, RemoteFile: "stack1/kvstore.tar.gz"})
+	if err == nil {
+		t.Errorf("UploadData should return error when the upload is not authorized")
+	}
+}
+
+func TestAzureBlobStreamApp(t *testing.T) {
+	ctx := context.TODO()
+	appPkg := "app1 package"
+	contentMD5 := md5.Sum([]byte(appPkg))
+
+	var md5Header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if md5Header != "" {
+			w.Header().Set("Content-MD5", md5Header)
+		}
+		w.Write([]byte(appPkg))
+	}))
+	defer server.Close()
+
+	azureBlobClient := &AzureBlobClient{
+		BucketName:         "appscontainer1",
+		StorageAccountName: "mystorageaccount",
+		SecretAccessKey:    base64.StdEncoding.EncodeToString([]byte("secret")),
+		Endpoint:           server.URL,
+		HTTPClient:         server.Client(),
+	}
+	readStream := func() error {
+		stream, err := azureBlobClient.StreamApp(ctx, RemoteDataDownloadRequest{RemoteFile: "adminAppsRepo/app1.tgz", Etag: "0x8D9A"})
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		_, err = io.ReadAll(stream)
+		return err
+	}
+
+	// blob without the MD5 is streamed as is
+	if err := readStream(); err != nil {
+		t.Errorf("StreamApp should not have returned error: %v", err)
+	}
+
+	// MD5 of the blob is verified
+	md5Header = base64.StdEncoding.EncodeToString(contentMD5[:])
+	if err := readStream(); err != nil {
+		t.Errorf("StreamApp should not have returned error for a matching MD5: %v", err)
+	}
+
+	tamperedMD5 := md5.Sum([]byte("tampered"))
+	md5Header = base64.StdEncoding.EncodeToString(tamperedMD5[:])
+	if err := readStream(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("StreamApp should have returned the checksum mismatch, got: %v", err)
+	}
+}
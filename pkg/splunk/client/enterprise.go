@@ -206,6 +206,9 @@ type SearchHeadCaptainMemberInfo struct {
 	// Indicates whether to use SSL when sending replication data.
 	ReplicationUseSSL bool `json:"replication_use_ssl"`
 
+	// Indicates whether the member is a preferred captain.
+	PreferredCaptain bool `json:"preferred_captain"`
+
 	// Indicates the status of the member.
 	Status string `json:"status"`
 }
@@ -304,6 +307,73 @@ func (c *SplunkClient) SetSearchHeadDetention(detain bool) error {
 	return c.Do(request, expectedStatus, nil)
 }
 
+// TransferSearchHeadCaptaincy transfers the captaincy of a search head cluster to the member with the given management URI.
+// You can use this on any member of a search head cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#shcluster.2Fmember.2Fconsensus.2Fdefault.2Ftransfer_captaincy
+func (c *SplunkClient) TransferSearchHeadCaptaincy(mgmtURI string) error {
+	endpoint := fmt.Sprintf("%s/services/shcluster/member/consensus/default/transfer_captaincy", c.ManagementURI)
+	reqBody := fmt.Sprintf("mgmt_uri=%s", mgmtURI)
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// setSearchHeadClusterConfig edits the search head clustering configuration of a search head cluster member.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#shcluster.2Fconfig.2Fconfig
+func (c *SplunkClient) setSearchHeadClusterConfig(reqBody string) error {
+	endpoint := fmt.Sprintf("%s/services/shcluster/config/config", c.ManagementURI)
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// SetSearchHeadPreferredCaptain sets whether a search head cluster member is a preferred captain.
+// You can use this on any member of a search head cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Configurecaptainelection
+func (c *SplunkClient) SetSearchHeadPreferredCaptain(preferred bool) error {
+	return c.setSearchHeadClusterConfig(fmt.Sprintf("preferred_captain=%t", preferred))
+}
+
+// SetSearchHeadStaticCaptain disables captain election on a search head cluster member, and configures the static captain.
+// Use it with captain set to true on the static captain, and to false on the other members.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Staticcaptain
+func (c *SplunkClient) SetSearchHeadStaticCaptain(captainURI string, captain bool) error {
+	mode := "member"
+	if captain {
+		mode = "captain"
+	}
+	return c.setSearchHeadClusterConfig(fmt.Sprintf("election=false&mode=%s&captain_uri=%s", mode, captainURI))
+}
+
+// SetSearchHeadDynamicCaptain enables captain election again on a search head cluster member with a static captain.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Staticcaptain
+func (c *SplunkClient) SetSearchHeadDynamicCaptain(mgmtURI string) error {
+	return c.setSearchHeadClusterConfig(fmt.Sprintf("election=true&mode=member&mgmt_uri=%s", mgmtURI))
+}
+
+// BootstrapSearchHeadCaptain bootstraps the captain election of a search head cluster among the given members.
+// You can use this on any member of a search head cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Staticcaptain
+func (c *SplunkClient) BootstrapSearchHeadCaptain(serversList []string) error {
+	endpoint := fmt.Sprintf("%s/services/shcluster/member/consensus/default/bootstrap", c.ManagementURI)
+	reqBody := fmt.Sprintf("servers_list=%s", strings.Join(serversList, ","))
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
 // RemoveSearchHeadClusterMember removes a search head cluster member.
 // You can use this on any member of a search head cluster.
 // See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Removeaclustermember
@@ -421,6 +491,36 @@ func (c *SplunkClient) GetClusterManagerInfo() (*ClusterManagerInfo, error) {
 	return &apiResponse.Entry[0].Content, nil
 }
 
+// ClusterManagerRedundancyInfo represents the redundancy state of a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/CMredundancy
+type ClusterManagerRedundancyInfo struct {
+	// High availability mode of the manager: Active or Standby.
+	HAMode string `json:"ha_mode"`
+
+	// Switchover mode of the manager: auto, manual or disabled.
+	SwitchoverMode string `json:"manager_switchover_mode"`
+}
+
+// GetClusterManagerRedundancy queries a cluster manager for its redundancy state.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/CMredundancy
+func (c *SplunkClient) GetClusterManagerRedundancy() (*ClusterManagerRedundancyInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content ClusterManagerRedundancyInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/cluster/manager/redundancy"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content, nil
+}
+
 // IndexerClusterPeerInfo represents the status of a indexer cluster peer.
 // See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fpeer.2Finfo
 type IndexerClusterPeerInfo struct {
@@ -626,6 +726,152 @@ func (c *SplunkClient) DecommissionIndexerClusterPeer(enforceCounts bool) error
 	return c.Do(request, expectedStatus, nil)
 }
 
+// SearchableRollingRestart starts a searchable rolling restart of all the indexer cluster peers.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Userollingrestart
+func (c *SplunkClient) SearchableRollingRestart() error {
+	endpoint := fmt.Sprintf("%s%s", c.ManagementURI, "/services/cluster/manager/control/control/restart")
+	reqBody := "searchable=true"
+
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// DataRebalanceStatus represents the status of a data rebalance of the indexer cluster.
+type DataRebalanceStatus struct {
+	// Indicates if a data rebalance is running.
+	Running bool `json:"is_running"`
+
+	// Percentage of the data rebalance that is complete, while it is running.
+	Progress float64 `json:"percent_complete"`
+
+	// Status message reported by the cluster manager.
+	Message string `json:"-"`
+}
+
+// dataRebalanceResponse represents the response of the data rebalance endpoint of the cluster manager
+type dataRebalanceResponse struct {
+	Entry []struct {
+		Content DataRebalanceStatus `json:"content"`
+	} `json:"entry"`
+
+	Messages []struct {
+		Text string `json:"text"`
+	} `json:"messages"`
+}
+
+// dataRebalance sends an action to the data rebalance endpoint of the cluster manager, and returns the response
+func (c *SplunkClient) dataRebalance(reqBody string) (*dataRebalanceResponse, error) {
+	endpoint := fmt.Sprintf("%s%s", c.ManagementURI, "/services/cluster/manager/control/control/rebalance_buckets?output_mode=json")
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	apiResponse := dataRebalanceResponse{}
+	expectedStatus := []int{200}
+	err = c.Do(request, expectedStatus, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	return &apiResponse, nil
+}
+
+// StartDataRebalance starts a data rebalance of the indexer cluster, that runs up to maxRuntimeMinutes, if not 0.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Rebalancethecluster
+func (c *SplunkClient) StartDataRebalance(maxRuntimeMinutes int64) error {
+	reqBody := "action=start"
+	if maxRuntimeMinutes > 0 {
+		reqBody = fmt.Sprintf("%s&max_runtime=%d", reqBody, maxRuntimeMinutes)
+	}
+	_, err := c.dataRebalance(reqBody)
+	return err
+}
+
+// StopDataRebalance stops the data rebalance of the indexer cluster.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Rebalancethecluster
+func (c *SplunkClient) StopDataRebalance() error {
+	_, err := c.dataRebalance("action=stop")
+	return err
+}
+
+// GetDataRebalanceStatus queries the cluster manager for the status of the data rebalance of the indexer cluster.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Rebalancethecluster
+func (c *SplunkClient) GetDataRebalanceStatus() (*DataRebalanceStatus, error) {
+	apiResponse, err := c.dataRebalance("action=status")
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, "/services/cluster/manager/control/control/rebalance_buckets")
+	}
+
+	status := apiResponse.Entry[0].Content
+	var messages []string
+	for _, message := range apiResponse.Messages {
+		messages = append(messages, message.Text)
+	}
+	status.Message = strings.Join(messages, " ")
+	return &status, nil
+}
+
+// ClusterManagerRestartProgress represents the progress of a rolling restart of the indexer cluster peers.
+type ClusterManagerRestartProgress struct {
+	// Peers restarted by the rolling restart.
+	Done []string `json:"done"`
+
+	// Peers that failed to restart.
+	Failed []string `json:"failed"`
+
+	// Peers being restarted.
+	InProgress []string `json:"in_progress"`
+
+	// Peers yet to be restarted.
+	ToBeRestarted []string `json:"to_be_restarted"`
+}
+
+// ClusterManagerStatus represents the status of the rolling restart and upgrade of the indexer cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Fstatus
+type ClusterManagerStatus struct {
+	// Indicates if a rolling restart or upgrade of the peers is in progress.
+	RollingRestartOrUpgrade bool `json:"rolling_restart_or_upgrade"`
+
+	// Indicates if the rolling restart is searchable.
+	SearchableRolling bool `json:"searchable_rolling"`
+
+	// Progress of the rolling restart of the peers.
+	RestartProgress ClusterManagerRestartProgress `json:"restart_progress"`
+}
+
+// GetClusterManagerStatus queries the cluster manager for the status of the rolling restart of the indexer cluster peers.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Fstatus
+func (c *SplunkClient) GetClusterManagerStatus() (*ClusterManagerStatus, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content ClusterManagerStatus `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/cluster/manager/status"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content, nil
+}
+
 // BundlePush pushes the Cluster manager apps bundle to all the indexer peers
 func (c *SplunkClient) BundlePush(ignoreIdenticalBundle bool) error {
 	endpoint := fmt.Sprintf("%s%s", c.ManagementURI, "/services/cluster/manager/control/default/apply")
@@ -883,7 +1129,9 @@ func (c *SplunkClient) UpdateMonitoringConsoleApp() error {
 type ClusterInfo struct {
 	MultiSite             string `json:"multisite"`
 	ReplicationFactor     int32  `json:"replication_factor"`
+	SearchFactor          int32  `json:"search_factor"`
 	SiteReplicationFactor string `json:"site_replication_factor,omitempty"`
+	SiteSearchFactor      string `json:"site_search_factor,omitempty"`
 }
 
 // GetClusterInfo queries the cluster about multi-site or single-site.
@@ -923,6 +1171,54 @@ func (c *SplunkClient) SetIdxcSecret(idxcSecret string) error {
 	return c.Do(request, expectedStatus, nil)
 }
 
+// SetMultisiteConfig enables multisite clustering on the cluster manager, with the given site of the cluster manager,
+// available sites, site_replication_factor and site_search_factor. The cluster manager needs a restart to apply it.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fconfig.2Fconfig
+func (c *SplunkClient) SetMultisiteConfig(site string, availableSites []string, siteReplicationFactor string, siteSearchFactor string) error {
+	endpoint := fmt.Sprintf("%s/services/cluster/config/config", c.ManagementURI)
+	reqBody := fmt.Sprintf("mode=manager&multisite=true&site=%s&available_sites=%s&site_replication_factor=%s&site_search_factor=%s",
+		site, strings.Join(availableSites, ","), siteReplicationFactor, siteSearchFactor)
+
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// SetClusterFactors sets the replication and search factors of an indexer cluster on the cluster manager.
+// Only the factors which are set are updated, and the cluster manager must be restarted for them to take effect.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fconfig.2Fconfig
+func (c *SplunkClient) SetClusterFactors(replicationFactor int32, searchFactor int32, siteReplicationFactor string, siteSearchFactor string) error {
+	endpoint := fmt.Sprintf("%s/services/cluster/config/config", c.ManagementURI)
+	var params []string
+	if replicationFactor > 0 {
+		params = append(params, fmt.Sprintf("replication_factor=%d", replicationFactor))
+	}
+	if searchFactor > 0 {
+		params = append(params, fmt.Sprintf("search_factor=%d", searchFactor))
+	}
+	if siteReplicationFactor != "" {
+		params = append(params, fmt.Sprintf("site_replication_factor=%s", siteReplicationFactor))
+	}
+	if siteSearchFactor != "" {
+		params = append(params, fmt.Sprintf("site_search_factor=%s", siteSearchFactor))
+	}
+	if len(params) == 0 {
+		return fmt.Errorf("no replication or search factor to set")
+	}
+
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(strings.Join(params, "&")))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
 // RestartSplunk restarts specific Splunk instance
 // Can be used for any Splunk Instance
 // See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsystem#server.2Fcontrol.2Frestart
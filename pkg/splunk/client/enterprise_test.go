@@ -205,6 +205,81 @@ func TestSetSearchHeadDetention(t *testing.T) {
 	splunkClientErrorTester(t, test)
 }
 
+func TestTransferSearchHeadCaptaincy(t *testing.T) {
+	body := strings.NewReader("mgmt_uri=https://splunk-s1-search-head-1.splunk-s1-search-head-headless.test.svc.cluster.local:8089")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/member/consensus/default/transfer_captaincy", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.TransferSearchHeadCaptaincy("https://splunk-s1-search-head-1.splunk-s1-search-head-headless.test.svc.cluster.local:8089")
+	}
+	splunkClientTester(t, "TestTransferSearchHeadCaptaincy", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestSetSearchHeadPreferredCaptain(t *testing.T) {
+	body := strings.NewReader("preferred_captain=true")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/config/config", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.SetSearchHeadPreferredCaptain(true)
+	}
+	splunkClientTester(t, "TestSetSearchHeadPreferredCaptain", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestSetSearchHeadStaticCaptain(t *testing.T) {
+	captainURI := "https://splunk-s1-search-head-0.splunk-s1-search-head-headless.test.svc.cluster.local:8089"
+	body := strings.NewReader("election=false&mode=captain&captain_uri=" + captainURI)
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/config/config", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.SetSearchHeadStaticCaptain(captainURI, true)
+	}
+	splunkClientTester(t, "TestSetSearchHeadStaticCaptain", 200, "", wantRequest, test)
+
+	body = strings.NewReader("election=false&mode=member&captain_uri=" + captainURI)
+	wantRequest, _ = http.NewRequest("POST", "https://localhost:8089/services/shcluster/config/config", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test = func(c SplunkClient) error {
+		return c.SetSearchHeadStaticCaptain(captainURI, false)
+	}
+	splunkClientTester(t, "TestSetSearchHeadStaticCaptain", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestSetSearchHeadDynamicCaptain(t *testing.T) {
+	mgmtURI := "https://splunk-s1-search-head-1.splunk-s1-search-head-headless.test.svc.cluster.local:8089"
+	body := strings.NewReader("election=true&mode=member&mgmt_uri=" + mgmtURI)
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/config/config", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.SetSearchHeadDynamicCaptain(mgmtURI)
+	}
+	splunkClientTester(t, "TestSetSearchHeadDynamicCaptain", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestBootstrapSearchHeadCaptain(t *testing.T) {
+	body := strings.NewReader("servers_list=https://sh0:8089,https://sh1:8089")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/member/consensus/default/bootstrap", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.BootstrapSearchHeadCaptain([]string{"https://sh0:8089", "https://sh1:8089"})
+	}
+	splunkClientTester(t, "TestBootstrapSearchHeadCaptain", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
 func TestBundlePush(t *testing.T) {
 	body := strings.NewReader("&ignore_identical_bundle=true")
 	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/default/apply", body)
@@ -315,6 +390,36 @@ func TestGetclusterManagerInfo(t *testing.T) {
 	splunkClientTester(t, "TestGetClusterManagerInfo", 500, "", wantRequest, test)
 }
 
+func TestGetClusterManagerRedundancy(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/manager/redundancy?count=0&output_mode=json", nil)
+	wantInfo := ClusterManagerRedundancyInfo{HAMode: "Active", SwitchoverMode: "auto"}
+	test := func(c SplunkClient) error {
+		gotInfo, err := c.GetClusterManagerRedundancy()
+		if err != nil {
+			return err
+		}
+		if *gotInfo != wantInfo {
+			t.Errorf("info=%v; want %v", *gotInfo, wantInfo)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"manager","content":{"ha_mode":"Active","manager_switchover_mode":"auto"}}]}`
+	splunkClientTester(t, "TestGetClusterManagerRedundancy", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetClusterManagerRedundancy()
+		if err == nil {
+			t.Errorf("GetClusterManagerRedundancy returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetClusterManagerRedundancy", 200, `{"entry":[]}`, wantRequest, test)
+
+	// test error code
+	splunkClientTester(t, "TestGetClusterManagerRedundancy", 500, "", wantRequest, test)
+}
+
 func TestGetIndexerClusterPeerInfo(t *testing.T) {
 	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/peer/info?count=0&output_mode=json", nil)
 	wantMemberStatus := "Up"
@@ -416,6 +521,117 @@ func TestDecommissionIndexerClusterPeer(t *testing.T) {
 	splunkClientErrorTester(t, test)
 }
 
+func TestSearchableRollingRestart(t *testing.T) {
+	body := strings.NewReader("searchable=true")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/control/restart", body)
+	test := func(c SplunkClient) error {
+		return c.SearchableRollingRestart()
+	}
+	splunkClientTester(t, "TestSearchableRollingRestart", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestStartDataRebalance(t *testing.T) {
+	body := strings.NewReader("action=start&max_runtime=60")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/control/rebalance_buckets?output_mode=json", body)
+	test := func(c SplunkClient) error {
+		return c.StartDataRebalance(60)
+	}
+	splunkClientTester(t, "TestStartDataRebalance", 200, `{"messages":[{"type":"INFO","text":"Data rebalance started"}]}`, wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestStopDataRebalance(t *testing.T) {
+	body := strings.NewReader("action=stop")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/control/rebalance_buckets?output_mode=json", body)
+	test := func(c SplunkClient) error {
+		return c.StopDataRebalance()
+	}
+	splunkClientTester(t, "TestStopDataRebalance", 200, `{"messages":[{"type":"INFO","text":"Data rebalance stopped"}]}`, wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestGetDataRebalanceStatus(t *testing.T) {
+	body := strings.NewReader("action=status")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/control/rebalance_buckets?output_mode=json", body)
+	test := func(c SplunkClient) error {
+		status, err := c.GetDataRebalanceStatus()
+		if err != nil {
+			return err
+		}
+		if !status.Running || status.Progress != 42.5 || status.Message != "Data rebalance is running" {
+			t.Errorf("data rebalance should be running. got: %v", *status)
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetDataRebalanceStatus", 200, `{"entry":[{"content":{"is_running":true,"percent_complete":42.5}}],"messages":[{"type":"INFO","text":"Data rebalance is running"}]}`, wantRequest, test)
+
+	test = func(c SplunkClient) error {
+		status, err := c.GetDataRebalanceStatus()
+		if err != nil {
+			return err
+		}
+		if status.Running {
+			t.Errorf("data rebalance should not be running. got: %v", *status)
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetDataRebalanceStatus", 200, `{"entry":[{"content":{"is_running":false,"percent_complete":100}}],"messages":[{"type":"INFO","text":"Data rebalance is not running"}]}`, wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+
+	// status message alone doesn't tell if the data rebalance is running
+	test = func(c SplunkClient) error {
+		_, err := c.GetDataRebalanceStatus()
+		if err == nil {
+			t.Errorf("GetDataRebalanceStatus should have returned error for a response without the status")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetDataRebalanceStatus", 200, `{"messages":[{"type":"INFO","text":"Data rebalance started"}]}`, wantRequest, test)
+}
+
+func TestGetClusterManagerStatus(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/manager/status?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		gotStatus, err := c.GetClusterManagerStatus()
+		if err != nil {
+			return err
+		}
+		if !gotStatus.RollingRestartOrUpgrade || !gotStatus.SearchableRolling {
+			t.Errorf("rolling restart should be in progress. got: %v", *gotStatus)
+		}
+		progress := gotStatus.RestartProgress
+		if len(progress.Done) != 1 || progress.Done[0] != "splunk-stack1-indexer-0" || len(progress.InProgress) != 1 || len(progress.ToBeRestarted) != 1 || len(progress.Failed) != 0 {
+			t.Errorf("incorrect restart progress. got: %v", progress)
+		}
+		return nil
+	}
+	body := `{"links":{},"origin":"https://localhost:8089/services/cluster/manager/status","updated":"2022-03-18T01:04:53+00:00","generator":{"build":"a7f645ddaf91","version":"9.0.0"},"entry":[{"name":"manager","id":"https://localhost:8089/services/cluster/manager/status/manager","updated":"1970-01-01T00:00:00+00:00","links":{},"author":"system","content":{"maintenance_mode":true,"multisite":false,"restart_progress":{"done":["splunk-stack1-indexer-0"],"failed":[],"in_progress":["splunk-stack1-indexer-1"],"to_be_restarted":["splunk-stack1-indexer-2"]},"rolling_restart_or_upgrade":true,"rolling_restart_type":"searchable","searchable_rolling":true,"service_ready_flag":true}}],"paging":{"total":1,"perPage":30,"offset":0},"messages":[]}`
+	splunkClientTester(t, "TestGetClusterManagerStatus", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetClusterManagerStatus()
+		if err == nil {
+			t.Errorf("GetClusterManagerStatus returned nil; want error")
+		}
+		return nil
+	}
+	body = splcommon.TestGetCMInfoEmpty
+	splunkClientTester(t, "TestGetClusterManagerStatus", 200, body, wantRequest, test)
+
+	// test error code
+	splunkClientTester(t, "TestGetClusterManagerStatus", 500, "", wantRequest, test)
+}
+
 func TestAutomateMCApplyChanges(t *testing.T) {
 	request1, _ := http.NewRequest("GET", "https://localhost:8089/services/server/info/server-info?count=0&output_mode=json", nil)
 	request2, _ := http.NewRequest("GET", "https://localhost:8089/services/search/distributed/peers?count=0&output_mode=json", nil)
@@ -642,6 +858,50 @@ func TestSetIdxcSecret(t *testing.T) {
 	splunkClientErrorTester(t, test)
 }
 
+func TestSetMultisiteConfig(t *testing.T) {
+	body := strings.NewReader("mode=manager&multisite=true&site=site1&available_sites=site1,site2&site_replication_factor=origin:1,total:2&site_search_factor=origin:1,total:2")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/config/config", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	test := func(c SplunkClient) error {
+		return c.SetMultisiteConfig("site1", []string{"site1", "site2"}, "origin:1,total:2", "origin:1,total:2")
+	}
+	splunkClientTester(t, "TestSetMultisiteConfig", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+}
+
+func TestSetClusterFactors(t *testing.T) {
+	body := strings.NewReader("replication_factor=3&search_factor=2")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/config/config", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	test := func(c SplunkClient) error {
+		return c.SetClusterFactors(3, 2, "", "")
+	}
+	splunkClientTester(t, "TestSetClusterFactors", 200, "", wantRequest, test)
+
+	// Test site factors
+	body = strings.NewReader("site_replication_factor=origin:2,total:3&site_search_factor=origin:1,total:2")
+	wantRequest, _ = http.NewRequest("POST", "https://localhost:8089/services/cluster/config/config", body)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test = func(c SplunkClient) error {
+		return c.SetClusterFactors(0, 0, "origin:2,total:3", "origin:1,total:2")
+	}
+	splunkClientTester(t, "TestSetClusterFactors", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+
+	// Test no factors to set
+	c := NewSplunkClient("https://localhost:8089", "admin", "p@ssw0rd")
+	err := c.SetClusterFactors(0, 0, "", "")
+	if err == nil {
+		t.Errorf("SetClusterFactors should return an error when no factor is set")
+	}
+}
+
 func TestRestartSplunk(t *testing.T) {
 	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/server/control/restart", nil)
 	test := func(c SplunkClient) error {
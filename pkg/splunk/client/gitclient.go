@@ -0,0 +1,389 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// blank assignment to verify that GitClient implements RemoteDataClient
+var _ RemoteDataClient = &GitClient{}
+
+// gitDefaultTokenUser is the user name sent along with an access token, when no user name is configured
+const gitDefaultTokenUser = "x-access-token"
+
+// gitRepoLocks serializes the git operations on a given local checkout directory
+var gitRepoLocks sync.Map
+
+// SplunkGitClient is an interface to the git command line client
+type SplunkGitClient interface {
+	RunGitCommand(ctx context.Context, dir string, env []string, args ...string) ([]byte, error)
+}
+
+// gitCommandClient runs the git binary available on the operator pod
+type gitCommandClient struct{}
+
+// RunGitCommand runs git with the given arguments from the given directory, and returns the stdout
+func (c *gitCommandClient) RunGitCommand(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v, stderr: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// GitClient is a client to use a git repository as the App Framework remote storage.
+// Every top level directory under the prefix is treated as an app, and is packaged as
+// a .tgz on download. The git tree hash of the directory is used as the object etag,
+// so that any change to the app contents is detected as an app update.
+type GitClient struct {
+	// RepoURL is the URL of the git repository
+	RepoURL string
+
+	// Ref is the branch, tag or commit to deploy the apps from
+	Ref string
+
+	// Username used along with the access token, not used with ssh keys
+	Username string
+
+	// Secret is either an ssh private key or an access token
+	Secret string
+
+	// Prefix is the directory path of the apps, relative to the repository root
+	Prefix string
+
+	// LocalDir is the directory where the repository is checked out on the operator pod
+	LocalDir string
+
+	Client SplunkGitClient
+}
+
+// NewGitClient returns a git client. The bucket name is treated as the git ref, that is the volume ref,
+// or the first element of the volume path, and the endpoint as the repository URL
+func NewGitClient(ctx context.Context, bucketName string, accessKeyID string, secretAccessKey string, prefix string, startAfter string, region string, endpoint string, fn GetInitFunc) (RemoteDataClient, error) {
+	var gitSplunkClient SplunkGitClient
+	var err error
+
+	cl := fn(ctx, endpoint, accessKeyID, secretAccessKey)
+	if cl == nil {
+		err = fmt.Errorf("failed to create a git client")
+		return nil, err
+	}
+
+	gitSplunkClient = cl.(SplunkGitClient)
+
+	if bucketName == "" {
+		err = fmt.Errorf("git ref is missing for the repository: %s", endpoint)
+		return nil, err
+	}
+
+	// The checkout is shared by all the CRs using the same repository and ref
+	digest := sha256.Sum256([]byte(endpoint + "@" + bucketName))
+
+	return &GitClient{
+		RepoURL:  endpoint,
+		Ref:      bucketName,
+		Username: accessKeyID,
+		Secret:   secretAccessKey,
+		Prefix:   cleanGitPrefix(prefix),
+		LocalDir: filepath.Join(splcommon.AppDownloadVolume, "gitRepos", hex.EncodeToString(digest[:8])),
+		Client:   gitSplunkClient,
+	}, nil
+}
+
+// RegisterGitClient will add the corresponding function pointer to the map
+func RegisterGitClient() {
+	wrapperObject := GetRemoteDataClientWrapper{GetRemoteDataClient: NewGitClient, GetInitFunc: InitGitClientWrapper}
+	RemoteDataClientsMap["git"] = wrapperObject
+}
+
+// InitGitClientWrapper is a wrapper around InitGitClientSession
+func InitGitClientWrapper(ctx context.Context, repoURL string, username string, secret string) interface{} {
+	return InitGitClientSession(ctx, repoURL, username, secret)
+}
+
+// InitGitClientSession checks if the git binary is available, and returns a git client
+func InitGitClientSession(ctx context.Context, repoURL string, username string, secret string) SplunkGitClient {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("InitGitClientSession")
+
+	if !strings.HasPrefix(repoURL, "https://") && !strings.HasPrefix(repoURL, "http://") &&
+		!strings.HasPrefix(repoURL, "ssh://") && !strings.Contains(repoURL, "@") && !strings.HasPrefix(repoURL, "file://") {
+		scopedLog.Info("Unsupported endpoint for git client", "repoURL", repoURL)
+		return nil
+	}
+
+	_, err := exec.LookPath("git")
+	if err != nil {
+		scopedLog.Error(err, "git binary is not available on the operator pod")
+		return nil
+	}
+
+	return &gitCommandClient{}
+}
+
+// cleanGitPrefix returns the prefix relative to the repository root, without leading and trailing slashes
+func cleanGitPrefix(prefix string) string {
+	cleanPrefix := strings.Trim(path.Clean("/"+prefix), "/")
+	return cleanPrefix
+}
+
+// isSSHPrivateKey checks if the secret is an ssh private key, rather than an access token
+func isSSHPrivateKey(secret string) bool {
+	return strings.Contains(secret, "PRIVATE KEY")
+}
+
+// getAuthEnv returns the environment needed to authenticate with the repository. The credentials are
+// never passed on the command line, as the arguments of a process are visible to the other processes
+func (client *GitClient) getAuthEnv() ([]string, error) {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+
+	if client.Secret == "" {
+		return env, nil
+	}
+
+	if isSSHPrivateKey(client.Secret) {
+		keyFile := client.LocalDir + ".key"
+		err := os.WriteFile(keyFile, []byte(strings.TrimSpace(client.Secret)+"\n"), 0600)
+		if err != nil {
+			return nil, fmt.Errorf("unable to write the ssh key: %v", err)
+		}
+		sshCommand := fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile=%s", keyFile, client.LocalDir+".known_hosts")
+		env = append(env, "GIT_SSH_COMMAND="+sshCommand)
+		return env, nil
+	}
+
+	username := client.Username
+	if username == "" {
+		username = gitDefaultTokenUser
+	}
+	authHeader := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+client.Secret))
+	// the access token is passed as the git configuration through the environment
+	env = append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0="+authHeader)
+
+	return env, nil
+}
+
+// runGit runs a git command from the local checkout directory
+func (client *GitClient) runGit(ctx context.Context, args ...string) ([]byte, error) {
+	return client.Client.RunGitCommand(ctx, client.LocalDir, nil, args...)
+}
+
+// syncRepo fetches the configured ref from the remote repository, and updates the local checkout to it
+func (client *GitClient) syncRepo(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("syncRepo").WithValues("repoURL", client.RepoURL, "ref", client.Ref)
+
+	_, err := os.Stat(filepath.Join(client.LocalDir, ".git"))
+	if err != nil {
+		scopedLog.Info("Initializing the local git repository", "localDir", client.LocalDir)
+		err = os.MkdirAll(client.LocalDir, 0755)
+		if err != nil {
+			return fmt.Errorf("unable to create the directory %s: %v", client.LocalDir, err)
+		}
+
+		_, err = client.runGit(ctx, "init", "--quiet")
+		if err != nil {
+			return err
+		}
+
+		_, err = client.runGit(ctx, "remote", "add", "origin", client.RepoURL)
+		if err != nil {
+			return err
+		}
+	} else {
+		// the repository URL can be changed on the volume, while the ref stays the same
+		_, err = client.runGit(ctx, "remote", "set-url", "origin", client.RepoURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	env, err := client.getAuthEnv()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Client.RunGitCommand(ctx, client.LocalDir, env, "fetch", "--quiet", "--depth", "1", "origin", client.Ref)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.runGit(ctx, "reset", "--quiet", "--hard", "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+
+	_, err = client.runGit(ctx, "clean", "--quiet", "-ffdx")
+	return err
+}
+
+// GetAppsList fetches the latest changes for the ref, and lists the apps under the prefix
+func (client *GitClient) GetAppsList(ctx context.Context) (RemoteDataListResponse, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("GetAppsList")
+
+	scopedLog.Info("Getting Apps list", "Repository", client.RepoURL, "Ref", client.Ref, "Prefix", client.Prefix)
+	remoteDataClientResponse := RemoteDataListResponse{}
+
+	mux, _ := gitRepoLocks.LoadOrStore(client.LocalDir, &sync.Mutex{})
+	mux.(*sync.Mutex).Lock()
+	defer mux.(*sync.Mutex).Unlock()
+
+	err := client.syncRepo(ctx)
+	if err != nil {
+		scopedLog.Error(err, "Unable to sync the git repository")
+		return remoteDataClientResponse, err
+	}
+
+	output, err := client.runGit(ctx, "log", "-1", "--format=%ct", "HEAD")
+	if err != nil {
+		return remoteDataClientResponse, err
+	}
+	commitTime, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return remoteDataClientResponse, fmt.Errorf("unable to parse the commit time: %v", err)
+	}
+	lastModified := time.Unix(commitTime, 0).UTC()
+
+	lsTreeArgs := []string{"ls-tree", "-l", "HEAD"}
+	if client.Prefix != "" {
+		lsTreeArgs = append(lsTreeArgs, client.Prefix+"/")
+	}
+	output, err = client.runGit(ctx, lsTreeArgs...)
+	if err != nil {
+		return remoteDataClientResponse, err
+	}
+
+	// Each entry is of the form "<mode> <type> <object> <size>\t<path>"
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		tabAt := strings.Index(line, "\t")
+		if tabAt < 0 {
+			continue
+		}
+		fields := strings.Fields(line[:tabAt])
+		if len(fields) != 4 {
+			continue
+		}
+		objectType, objectHash, objectPath := fields[1], fields[2], line[tabAt+1:]
+
+		if strings.HasPrefix(path.Base(objectPath), ".") {
+			continue
+		}
+
+		var newKey string
+		var newSize int64
+		switch objectType {
+		case "tree":
+			// app directories are packaged during the download
+			newKey = objectPath + ".tgz"
+		case "blob":
+			// already packaged apps are downloaded as is
+			newKey = objectPath
+			newSize, _ = strconv.ParseInt(fields[3], 10, 64)
+		default:
+			continue
+		}
+
+		scopedLog.Info("Got an object", "key", newKey, "hash", objectHash)
+
+		newETag := objectHash
+		newLastModified := lastModified
+		newStorageClass := "git"
+		newRemoteObject := RemoteObject{Etag: &newETag, Key: &newKey, LastModified: &newLastModified, Size: &newSize, StorageClass: &newStorageClass}
+		remoteDataClientResponse.Objects = append(remoteDataClientResponse.Objects, &newRemoteObject)
+	}
+
+	return remoteDataClientResponse, nil
+}
+
+// DownloadApp writes the app package for the given git object to the local file
+func (client *GitClient) DownloadApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("DownloadApp").WithValues("remoteFile", downloadRequest.RemoteFile,
+		"localFile", downloadRequest.LocalFile, "etag", downloadRequest.Etag)
+
+	mux, _ := gitRepoLocks.LoadOrStore(client.LocalDir, &sync.Mutex{})
+	mux.(*sync.Mutex).Lock()
+	defer mux.(*sync.Mutex).Unlock()
+
+	objectHash := strings.Trim(downloadRequest.Etag, "\"")
+
+	// The object can be missing, if the operator pod restarted, or the checkout was removed
+	output, err := client.runGit(ctx, "cat-file", "-t", objectHash)
+	if err != nil {
+		scopedLog.Info("Object not found in the local checkout, syncing the repository")
+		err = client.syncRepo(ctx)
+		if err != nil {
+			scopedLog.Error(err, "Unable to sync the git repository")
+			return false, err
+		}
+
+		output, err = client.runGit(ctx, "cat-file", "-t", objectHash)
+		if err != nil {
+			scopedLog.Error(err, "Object is not available on the ref")
+			return false, err
+		}
+	}
+
+	switch objectType := strings.TrimSpace(string(output)); objectType {
+	case "tree":
+		// package the app directory, with the directory name as the top level folder
+		appDirName := strings.TrimSuffix(path.Base(downloadRequest.RemoteFile), ".tgz")
+		_, err = client.runGit(ctx, "archive", "--format=tgz", "--prefix="+appDirName+"/", "-o", downloadRequest.LocalFile, objectHash)
+	case "blob":
+		output, err = client.runGit(ctx, "cat-file", "blob", objectHash)
+		if err == nil {
+			err = os.WriteFile(downloadRequest.LocalFile, output, 0644)
+		}
+	default:
+		err = fmt.Errorf("unsupported git object type: %s", objectType)
+	}
+
+	if err != nil {
+		scopedLog.Error(err, "Unable to download remote file")
+		return false, err
+	}
+
+	scopedLog.Info("File downloaded")
+
+	return true, nil
+}
@@ -0,0 +1,432 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// blank assignment to verify that HTTPManifestClient implements RemoteDataClient
+var _ RemoteDataClient = &HTTPManifestClient{}
+
+// blank assignment to verify that HTTPManifestClient implements RemoteDataStreamClient
+var _ RemoteDataStreamClient = &HTTPManifestClient{}
+
+// HTTPManifestApp is an app entry in the http manifest
+type HTTPManifestApp struct {
+	// Name of the app package. The extension of the URL is used, if the name doesn't end with .tgz or .spl
+	Name string `json:"name"`
+
+	// URL of the app package, either absolute or relative to the manifest URL
+	URL string `json:"url"`
+
+	// sha256 checksum of the app package
+	Sha256 string `json:"sha256"`
+
+	// Size of the app package in bytes
+	Size int64 `json:"size,omitempty"`
+}
+
+// HTTPManifest lists the app packages published at https URLs. It can be either a JSON or a YAML document
+type HTTPManifest struct {
+	Apps []HTTPManifestApp `json:"apps"`
+}
+
+// HTTPManifestClient is a client to download the apps listed in an http(s) manifest
+type HTTPManifestClient struct {
+	// ManifestURL is the URL of the manifest listing the apps
+	ManifestURL string
+
+	// Username is used for the basic auth. Bearer auth is used, if only the Secret is set
+	Username string
+
+	// Secret is either the basic auth password, or the bearer token
+	Secret string
+
+	// Prefix is prepended to the app package names, to form the object keys
+	Prefix string
+
+	HTTPClient SplunkHTTPClient
+}
+
+// NewHTTPManifestClient returns an http manifest client. The manifest is fetched
+// from the path of the bucket and prefix, relative to the endpoint
+func NewHTTPManifestClient(ctx context.Context, bucketName string, username string, secret string, prefix string, startAfter string, region string, endpoint string, fn GetInitFunc) (RemoteDataClient, error) {
+	cl := fn(ctx, endpoint, username, secret)
+	if cl == nil {
+		err := fmt.Errorf("failed to create an http manifest client")
+		return nil, err
+	}
+
+	manifestPath := path.Join(bucketName, prefix)
+	manifestURL, err := url.JoinPath(endpoint, manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest URL for endpoint: %s, path: %s, error: %v", endpoint, manifestPath, err)
+	}
+
+	return &HTTPManifestClient{
+		ManifestURL: manifestURL,
+		Username:    username,
+		Secret:      secret,
+		Prefix:      prefix,
+		HTTPClient:  cl.(SplunkHTTPClient),
+	}, nil
+}
+
+// RegisterHTTPManifestClient will add the corresponding function pointer to the map
+func RegisterHTTPManifestClient() {
+	wrapperObject := GetRemoteDataClientWrapper{GetRemoteDataClient: NewHTTPManifestClient, GetInitFunc: InitHTTPManifestClientWrapper}
+	RemoteDataClientsMap["http"] = wrapperObject
+}
+
+// InitHTTPManifestClientWrapper is a wrapper around InitHTTPManifestClientSession
+func InitHTTPManifestClientWrapper(ctx context.Context, endpoint string, username string, secret string) interface{} {
+	return InitHTTPManifestClientSession(ctx, endpoint)
+}
+
+// InitHTTPManifestClientSession initializes and returns a client session object
+func InitHTTPManifestClientSession(ctx context.Context, endpoint string) SplunkHTTPClient {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("InitHTTPManifestClientSession")
+
+	if strings.HasPrefix(endpoint, "http://") {
+		scopedLog.Info("Using insecure endpoint for the http manifest client", "endpoint", endpoint)
+	} else if !strings.HasPrefix(endpoint, "https://") {
+		scopedLog.Info("Unsupported endpoint for the http manifest client", "endpoint", endpoint)
+		return nil
+	}
+
+	// Enforcing minimum version TLS1.2
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		},
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	return &http.Client{
+		Transport: tr,
+		Timeout:   appFrameworkHttpclientTimeout * time.Second,
+	}
+}
+
+// newRequest returns a GET request for the given URL, with the configured authentication
+func (client *HTTPManifestClient) newRequest(ctx context.Context, requestURL string) (*http.Request, error) {
+	httpRequest, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Username != "" {
+		httpRequest.SetBasicAuth(client.Username, client.Secret)
+	} else if client.Secret != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+client.Secret)
+	}
+
+	return httpRequest, nil
+}
+
+// getManifest fetches and parses the manifest
+func (client *HTTPManifestClient) getManifest(ctx context.Context) (*HTTPManifest, error) {
+	httpRequest, err := client.newRequest(ctx, client.ManifestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResponse, err := client.HTTPClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch the manifest %s, status: %s", client.ManifestURL, httpResponse.Status)
+	}
+
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is a subset of YAML, so both the formats are parsed the same way
+	manifest := &HTTPManifest{}
+	err = yaml.Unmarshal(body, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the manifest %s, error: %v", client.ManifestURL, err)
+	}
+
+	return manifest, nil
+}
+
+// getAppPackageName returns the app package name, with the extension taken from the URL if missing in the name
+func (app *HTTPManifestApp) getAppPackageName() string {
+	for _, appExt := range []string{".tgz", ".spl", ".tar.gz", ".zip"} {
+		if strings.HasSuffix(app.Name, appExt) {
+			return app.Name
+		}
+	}
+
+	appURL, err := url.Parse(app.URL)
+	if err != nil {
+		return app.Name
+	}
+	if strings.HasSuffix(appURL.Path, ".tar.gz") {
+		return app.Name + ".tar.gz"
+	}
+	return app.Name + path.Ext(appURL.Path)
+}
+
+// resolveURL returns the absolute URL of the app package
+func (client *HTTPManifestClient) resolveURL(appURL string) (string, error) {
+	baseURL, err := url.Parse(client.ManifestURL)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(appURL)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+// GetAppsList gets the list of apps from the manifest
+func (client *HTTPManifestClient) GetAppsList(ctx context.Context) (RemoteDataListResponse, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("GetAppsList")
+
+	scopedLog.Info("Getting Apps list", "Manifest", client.ManifestURL)
+	remoteDataClientResponse := RemoteDataListResponse{}
+
+	manifest, err := client.getManifest(ctx)
+	if err != nil {
+		scopedLog.Error(err, "Unable to get the manifest")
+		return remoteDataClientResponse, err
+	}
+
+	for _, app := range manifest.Apps {
+		if app.Name == "" || app.URL == "" || app.Sha256 == "" {
+			err = fmt.Errorf("name, url and sha256 are required for every app in the manifest %s", client.ManifestURL)
+			return remoteDataClientResponse, err
+		}
+
+		// Create a new object to add to append to the response
+		newETag := strings.ToLower(app.Sha256)
+		newKey := client.Prefix + app.getAppPackageName()
+		newLastModified := time.Time{}
+		newSize := app.Size
+		newStorageClass := "http"
+		newRemoteObject := RemoteObject{Etag: &newETag, Key: &newKey, LastModified: &newLastModified, Size: &newSize, StorageClass: &newStorageClass}
+		remoteDataClientResponse.Objects = append(remoteDataClientResponse.Objects, &newRemoteObject)
+	}
+
+	return remoteDataClientResponse, nil
+}
+
+// getAppPackage looks up the app package in the manifest, and sends the request to download it
+func (client *HTTPManifestClient) getAppPackage(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (*HTTPManifestApp, *http.Response, error) {
+	// The manifest is fetched again, as it is the only mapping from the app name to the URL
+	manifest, err := client.getManifest(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appPackageName := path.Base(downloadRequest.RemoteFile)
+	var app *HTTPManifestApp
+	for i := range manifest.Apps {
+		if manifest.Apps[i].getAppPackageName() == appPackageName {
+			app = &manifest.Apps[i]
+			break
+		}
+	}
+	if app == nil {
+		return nil, nil, fmt.Errorf("app %s is not listed in the manifest %s", appPackageName, client.ManifestURL)
+	}
+
+	expectedSha256 := strings.ToLower(strings.Trim(downloadRequest.Etag, "\""))
+	if strings.ToLower(app.Sha256) != expectedSha256 {
+		return nil, nil, fmt.Errorf("app %s changed in the manifest since the listing, expected sha256: %s, got: %s", appPackageName, expectedSha256, app.Sha256)
+	}
+
+	appURL, err := client.resolveURL(app.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpRequest, err := client.newRequest(ctx, appURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Resume the partial download with a ranged request
+	if downloadRequest.StartOffset > 0 {
+		httpRequest.Header.Set(headerRange, getRangeHeader(downloadRequest.StartOffset))
+	}
+
+	httpResponse, err := client.HTTPClient.Do(httpRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if httpResponse.StatusCode != http.StatusOK && httpResponse.StatusCode != http.StatusPartialContent {
+		httpResponse.Body.Close()
+		return nil, nil, fmt.Errorf("unable to download the app %s, status: %s", appURL, httpResponse.Status)
+	}
+
+	return app, httpResponse, nil
+}
+
+// DownloadApp downloads an app package listed in the manifest, and verifies its checksum
+func (client *HTTPManifestClient) DownloadApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("DownloadApp").WithValues("remoteFile", downloadRequest.RemoteFile,
+		"localFile", downloadRequest.LocalFile, "etag", downloadRequest.Etag)
+
+	app, httpResponse, err := client.getAppPackage(ctx, downloadRequest)
+	if err != nil {
+		scopedLog.Error(err, "Unable to download the app package")
+		return false, err
+	}
+	defer httpResponse.Body.Close()
+
+	appPackageName := path.Base(downloadRequest.RemoteFile)
+	expectedSha256 := strings.ToLower(app.Sha256)
+
+	// Start from the beginning, if the range is not honoured
+	if httpResponse.StatusCode != http.StatusPartialContent {
+		downloadRequest.StartOffset = 0
+	}
+
+	// The checksum covers the data downloaded earlier as well
+	hash := sha256.New()
+	if downloadRequest.StartOffset > 0 {
+		err = hashFilePrefix(hash, downloadRequest.LocalFile, downloadRequest.StartOffset)
+		if err != nil {
+			scopedLog.Error(err, "Unable to read the partial download")
+			return false, err
+		}
+	}
+
+	localFile, err := openDownloadFile(downloadRequest)
+	if err != nil {
+		scopedLog.Error(err, "Unable to create local file")
+		return false, err
+	}
+	defer localFile.Close()
+
+	// Compute the checksum while writing the package
+	written, err := io.Copy(io.MultiWriter(localFile, hash), httpResponse.Body)
+	if err != nil {
+		scopedLog.Error(err, "Unable to write the app package")
+		return false, err
+	}
+	written += downloadRequest.StartOffset
+
+	if app.Size != 0 && written != app.Size {
+		err = fmt.Errorf("size mismatch for app %s, expected: %d, got: %d", appPackageName, app.Size, written)
+		return false, err
+	}
+
+	actualSha256 := hex.EncodeToString(hash.Sum(nil))
+	if actualSha256 != expectedSha256 {
+		err = fmt.Errorf("checksum mismatch for app %s, expected sha256: %s, got: %s", appPackageName, expectedSha256, actualSha256)
+		return false, err
+	}
+
+	scopedLog.Info("File downloaded")
+
+	return true, nil
+}
+
+// StreamApp opens a stream to read an app package listed in the manifest. The checksum is verified
+// at the end of the stream, and a mismatch is returned as the read error
+func (client *HTTPManifestClient) StreamApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (io.ReadCloser, error) {
+	downloadRequest.StartOffset = 0
+	app, httpResponse, err := client.getAppPackage(ctx, downloadRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checksumVerifyingReader{
+		body:           httpResponse.Body,
+		hash:           sha256.New(),
+		appName:        path.Base(downloadRequest.RemoteFile),
+		expectedDigest: strings.ToLower(app.Sha256),
+		expectedSize:   app.Size,
+	}, nil
+}
+
+// checksumVerifyingReader verifies the size and the checksum of the app package read through it
+type checksumVerifyingReader struct {
+	body           io.ReadCloser
+	hash           hash.Hash
+	appName        string
+	expectedDigest string
+	expectedSize   int64
+	read           int64
+}
+
+// Read reads the app package, and verifies it when the end of the package is reached
+func (reader *checksumVerifyingReader) Read(p []byte) (int, error) {
+	n, err := reader.body.Read(p)
+	reader.hash.Write(p[:n])
+	reader.read += int64(n)
+	if err != io.EOF {
+		return n, err
+	}
+
+	if reader.expectedSize != 0 && reader.read != reader.expectedSize {
+		return n, fmt.Errorf("size mismatch for app %s, expected: %d, got: %d", reader.appName, reader.expectedSize, reader.read)
+	}
+
+	actualDigest := hex.EncodeToString(reader.hash.Sum(nil))
+	if actualDigest != reader.expectedDigest {
+		return n, fmt.Errorf("checksum mismatch for app %s, expected: %s, got: %s", reader.appName, reader.expectedDigest, actualDigest)
+	}
+
+	return n, err
+}
+
+// Close closes the underlying response body
+func (reader *checksumVerifyingReader) Close() error {
+	return reader.body.Close()
+}
+
+// hashFilePrefix adds the first size bytes of the file to the hash
+func hashFilePrefix(hash io.Writer, fileName string, size int64) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.CopyN(hash, file, size)
+	return err
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestManifestServer returns a server publishing the given manifest and app packages
+func newTestManifestServer(t *testing.T, manifest string, packages map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vendor/apps/manifest.yaml", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, manifest)
+	})
+	for name, contents := range packages {
+		contents := contents
+		mux.HandleFunc("/vendor/packages/"+name, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, name, time.Time{}, strings.NewReader(contents))
+		})
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getSha256(contents string) string {
+	digest := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestInitHTTPManifestClientWrapper(t *testing.T) {
+	ctx := context.TODO()
+
+	httpClientSession := InitHTTPManifestClientWrapper(ctx, "https://apps.example.com", "", "")
+	if httpClientSession == nil {
+		t.Errorf("We should have got a valid http client object")
+	}
+
+	httpClientSession = InitHTTPManifestClientWrapper(ctx, "http://apps.example.com", "", "")
+	if httpClientSession == nil {
+		t.Errorf("We should have got a valid http client object for an insecure endpoint")
+	}
+
+	// Test erroneous endpoint
+	httpClientSession = InitHTTPManifestClientWrapper(ctx, "apps.example.com", "", "")
+	if httpClientSession != nil {
+		t.Errorf("Should have gotten a nil client due to unsupported endpoint")
+	}
+}
+
+func TestNewHTTPManifestClient(t *testing.T) {
+	ctx := context.TODO()
+
+	httpClient, err := NewHTTPManifestClient(ctx, "vendor", "", "token", "apps/manifest.yaml/", "", "", "https://apps.example.com/", InitHTTPManifestClientWrapper)
+	if httpClient == nil || err != nil {
+		t.Fatalf("NewHTTPManifestClient should have returned a valid http client.")
+	}
+	if httpClient.(*HTTPManifestClient).ManifestURL != "https://apps.example.com/vendor/apps/manifest.yaml" {
+		t.Errorf("Unexpected manifest URL: %s", httpClient.(*HTTPManifestClient).ManifestURL)
+	}
+
+	_, err = NewHTTPManifestClient(ctx, "vendor", "", "token", "apps/manifest.yaml/", "", "", "apps.example.com", InitHTTPManifestClientWrapper)
+	if err == nil {
+		t.Errorf("NewHTTPManifestClient should have returned an error for an invalid endpoint")
+	}
+}
+
+func TestHTTPManifestGetAppsListAndDownloadApp(t *testing.T) {
+	ctx := context.TODO()
+
+	packages := map[string]string{
+		"app1-1.0.0.tgz": "app1 package",
+		"app2.spl":       "app2 package",
+	}
+	manifest := fmt.Sprintf(`apps:
+- name: app1
+  url: ../packages/app1-1.0.0.tgz
+  sha256: %s
+  size: %d
+- name: app2.spl
+  url: /vendor/packages/app2.spl
+  sha256: %s
+`, getSha256(packages["app1-1.0.0.tgz"]), len(packages["app1-1.0.0.tgz"]), getSha256("tampered"))
+	server := newTestManifestServer(t, manifest, packages)
+
+	RegisterHTTPManifestClient()
+	getClientWrapper := RemoteDataClientsMap["http"]
+	getClient := getClientWrapper.GetRemoteDataClientFuncPtr(ctx)
+	initFn := getClientWrapper.GetRemoteDataClientInitFuncPtr(ctx)
+
+	// Without credentials the manifest can't be fetched
+	remoteDataClient, err := getClient(ctx, "vendor", "", "", "apps/manifest.yaml/", "", "", server.URL, initFn)
+	if err != nil {
+		t.Fatalf("Unable to create the http client: %v", err)
+	}
+	_, err = remoteDataClient.GetAppsList(ctx)
+	if err == nil {
+		t.Errorf("GetAppsList should have returned error for an unauthorized request")
+	}
+
+	remoteDataClient, err = getClient(ctx, "vendor", "", "token", "apps/manifest.yaml/", "", "", server.URL, initFn)
+	if err != nil {
+		t.Fatalf("Unable to create the http client: %v", err)
+	}
+
+	resp, err := remoteDataClient.GetAppsList(ctx)
+	if err != nil {
+		t.Fatalf("GetAppsList should not have returned error: %v", err)
+	}
+	if len(resp.Objects) != 2 {
+		t.Fatalf("Expected 2 apps, got: %d", len(resp.Objects))
+	}
+	if *resp.Objects[0].Key != "apps/manifest.yaml/app1.tgz" || *resp.Objects[0].Etag != getSha256(packages["app1-1.0.0.tgz"]) {
+		t.Errorf("Unexpected object key: %s, etag: %s", *resp.Objects[0].Key, *resp.Objects[0].Etag)
+	}
+	if *resp.Objects[0].Size != int64(len(packages["app1-1.0.0.tgz"])) {
+		t.Errorf("Unexpected object size: %d", *resp.Objects[0].Size)
+	}
+
+	// Download with a valid checksum
+	localFile := filepath.Join(t.TempDir(), "app1.tgz")
+	_, err = remoteDataClient.DownloadApp(ctx, RemoteDataDownloadRequest{LocalFile: localFile, RemoteFile: "apps/manifest.yaml/app1.tgz", Etag: *resp.Objects[0].Etag})
+	if err != nil {
+		t.Errorf("DownloadApp should not have returned error: %v", err)
+	}
+	contents, _ := os.ReadFile(localFile)
+	if string(contents) != packages["app1-1.0.0.tgz"] {
+		t.Errorf("Downloaded app package does not match the published package")
+	}
+
+	// Resume a partial download
+	err = os.WriteFile(localFile, []byte(packages["app1-1.0.0.tgz"][:5]+"garbage"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to create the partial download: %v", err)
+	}
+	_, err = remoteDataClient.DownloadApp(ctx, RemoteDataDownloadRequest{LocalFile: localFile, RemoteFile: "apps/manifest.yaml/app1.tgz", Etag: *resp.Objects[0].Etag, StartOffset: 5})
+	if err != nil {
+		t.Errorf("DownloadApp should not have returned error while resuming the download: %v", err)
+	}
+	contents, _ = os.ReadFile(localFile)
+	if string(contents) != packages["app1-1.0.0.tgz"] {
+		t.Errorf("Resumed app package does not match the published package, got: %s", contents)
+	}
+
+	// Resume with a corrupted partial download
+	err = os.WriteFile(localFile, []byte("garbage"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to create the partial download: %v", err)
+	}
+	_, err = remoteDataClient.DownloadApp(ctx, RemoteDataDownloadRequest{LocalFile: localFile, RemoteFile: "apps/manifest.yaml/app1.tgz", Etag: *resp.Objects[0].Etag, StartOffset: 5})
+	if err == nil {
+		t.Errorf("DownloadApp should have returned error for a corrupted partial download")
+	}
+
+	// Download with a checksum mismatch
+	localFile = filepath.Join(t.TempDir(), "app2.spl")
+	_, err = remoteDataClient.DownloadApp(ctx, RemoteDataDownloadRequest{LocalFile: localFile, RemoteFile: "apps/manifest.yaml/app2.spl", Etag: *resp.Objects[1].Etag})
+	if err == nil {
+		t.Errorf("DownloadApp should have returned error for a checksum mismatch")
+	}
+
+	// Download with a stale etag
+	_, err = remoteDataClient.DownloadApp(ctx, RemoteDataDownloadRequest{LocalFile: localFile, RemoteFile: "apps/manifest.yaml/app1.tgz", Etag: "abcd"})
+	if err == nil {
+		t.Errorf("DownloadApp should have returned error when the app changed since the listing")
+	}
+
+	// Download an app missing in the manifest
+	_, err = remoteDataClient.DownloadApp(ctx, RemoteDataDownloadRequest{LocalFile: localFile, RemoteFile: "apps/manifest.yaml/app3.tgz", Etag: "abcd"})
+	if err == nil {
+		t.Errorf("DownloadApp should have returned error for an app missing in the manifest")
+	}
+}
+
+func TestHTTPManifestStreamApp(t *testing.T) {
+	ctx := context.TODO()
+
+	packages := map[string]string{
+		"app1.tgz": "app1 package",
+		"app2.spl": "app2 package",
+	}
+	manifest := fmt.Sprintf(`apps:
+- name: app1.tgz
+  url: ../packages/app1.tgz
+  sha256: %s
+  size: %d
+- name: app2.spl
+  url: ../packages/app2.spl
+  sha256: %s
+`, getSha256(packages["app1.tgz"]), len(packages["app1.tgz"]), getSha256("tampered"))
+	server := newTestManifestServer(t, manifest, packages)
+
+	remoteDataClient, err := NewHTTPManifestClient(ctx, "vendor", "", "token", "apps/manifest.yaml/", "", "", server.URL, InitHTTPManifestClientWrapper)
+	if err != nil {
+		t.Fatalf("Unable to create the http client: %v", err)
+	}
+	streamClient, ok := remoteDataClient.(RemoteDataStreamClient)
+	if !ok {
+		t.Fatalf("http client should support streaming the apps")
+	}
+
+	// Stream with a valid checksum
+	stream, err := streamClient.StreamApp(ctx, RemoteDataDownloadRequest{RemoteFile: "apps/manifest.yaml/app1.tgz", Etag: getSha256(packages["app1.tgz"])})
+	if err != nil {
+		t.Fatalf("StreamApp should not have returned error: %v", err)
+	}
+	contents, err := io.ReadAll(stream)
+	stream.Close()
+	if err != nil || string(contents) != packages["app1.tgz"] {
+		t.Errorf("Streamed app package does not match the published package, got: %s, error: %v", contents, err)
+	}
+
+	// Checksum mismatch is reported at the end of the stream
+	stream, err = streamClient.StreamApp(ctx, RemoteDataDownloadRequest{RemoteFile: "apps/manifest.yaml/app2.spl", Etag: getSha256("tampered")})
+	if err != nil {
+		t.Fatalf("StreamApp should not have returned error: %v", err)
+	}
+	_, err = io.ReadAll(stream)
+	stream.Close()
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Reading the stream should have returned the checksum mismatch, got: %v", err)
+	}
+
+	// Stream with a stale etag
+	_, err = streamClient.StreamApp(ctx, RemoteDataDownloadRequest{RemoteFile: "apps/manifest.yaml/app1.tgz", Etag: "abcd"})
+	if err == nil {
+		t.Errorf("StreamApp should have returned error when the app changed since the listing")
+	}
+
+	if !IsAppStreamingSupported("http") || IsAppStreamingSupported("git") {
+		t.Errorf("Unexpected app streaming support for the providers")
+	}
+}
+
+func TestHTTPManifestGetAppsListShouldFail(t *testing.T) {
+	ctx := context.TODO()
+
+	// JSON manifest with a missing checksum
+	manifest := `{"apps": [{"name": "app1.tgz", "url": "https://apps.example.com/app1.tgz"}]}`
+	server := newTestManifestServer(t, manifest, nil)
+
+	remoteDataClient, err := NewHTTPManifestClient(ctx, "vendor", "", "token", "apps/manifest.yaml/", "", "", server.URL, InitHTTPManifestClientWrapper)
+	if err != nil {
+		t.Fatalf("Unable to create the http client: %v", err)
+	}
+	_, err = remoteDataClient.GetAppsList(ctx)
+	if err == nil {
+		t.Errorf("GetAppsList should have returned error for an app without checksum")
+	}
+
+	// Invalid manifest
+	server = newTestManifestServer(t, "apps: {", nil)
+	remoteDataClient, _ = NewHTTPManifestClient(ctx, "vendor", "", "token", "apps/manifest.yaml/", "", "", server.URL, InitHTTPManifestClientWrapper)
+	_, err = remoteDataClient.GetAppsList(ctx)
+	if err == nil {
+		t.Errorf("GetAppsList should have returned error for an invalid manifest")
+	}
+}
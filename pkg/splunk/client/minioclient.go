@@ -18,6 +18,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -32,12 +33,35 @@ import (
 // blank assignment to verify that MinioClient implements RemoteDataClient
 var _ RemoteDataClient = &MinioClient{}
 
+// blank assignment to verify that MinioClient implements RemoteDataStreamClient
+var _ RemoteDataStreamClient = &MinioClient{}
+
+// blank assignment to verify that MinioClient implements RemoteDataUploadClient
+var _ RemoteDataUploadClient = &MinioClient{}
+
 // SplunkMinioClient is an interface to Minio S3 client
 type SplunkMinioClient interface {
 	ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo
 	FGetObject(ctx context.Context, bucketName string, remoteFileName string, localFileName string, opts minio.GetObjectOptions) error
 }
 
+// SplunkMinioGetObjectClient is used to stream the apps from remote storage
+type SplunkMinioGetObjectClient interface {
+	GetObject(ctx context.Context, bucketName string, objectName string, opts minio.GetObjectOptions) (*minio.Object, error)
+}
+
+// blank assignment to verify that the Minio client can stream the apps
+var _ SplunkMinioGetObjectClient = &minio.Client{}
+
+// SplunkMinioPutObjectClient is used to upload the backups to remote storage, and delete them
+type SplunkMinioPutObjectClient interface {
+	FPutObject(ctx context.Context, bucketName string, objectName string, filePath string, opts minio.PutObjectOptions) (minio.UploadInfo, error)
+	RemoveObject(ctx context.Context, bucketName string, objectName string, opts minio.RemoveObjectOptions) error
+}
+
+// blank assignment to verify that the Minio client can upload the backups
+var _ SplunkMinioPutObjectClient = &minio.Client{}
+
 // MinioClient is a client to implement S3 specific APIs
 type MinioClient struct {
 	BucketName        string
@@ -153,6 +177,8 @@ func (client *MinioClient) GetAppsList(ctx context.Context) (RemoteDataListRespo
 		Recursive: false,
 	}
 
+	var dirPrefixes []string
+
 	// List all objects from a bucket-name with a matching prefix.
 	for object := range s3Client.ListObjects(context.Background(), client.BucketName, opts) {
 		if object.Err != nil {
@@ -161,25 +187,81 @@ func (client *MinioClient) GetAppsList(ctx context.Context) (RemoteDataListRespo
 		}
 		scopedLog.Info("Got an object", "object", object)
 
-		// Create a new object to add to append to the response
-		newETag := object.ETag
-		newKey := object.Key
-		newLastModified := object.LastModified
-		newSize := object.Size
-		newStorageClass := object.StorageClass
-		newRemoteObject := RemoteObject{Etag: &newETag, Key: &newKey, LastModified: &newLastModified, Size: &newSize, StorageClass: &newStorageClass}
-		remoteDataClientResponse.Objects = append(remoteDataClientResponse.Objects, &newRemoteObject)
+		// app directories are packaged during the download
+		if strings.HasSuffix(object.Key, "/") {
+			if object.Key != client.Prefix {
+				dirPrefixes = append(dirPrefixes, object.Key)
+			}
+			continue
+		}
+
+		remoteDataClientResponse.Objects = append(remoteDataClientResponse.Objects, convertMinioObject(object))
+	}
+
+	for _, dirPrefix := range dirPrefixes {
+		objects, err := client.listAppDirObjects(ctx, dirPrefix)
+		if err != nil {
+			scopedLog.Error(err, "Unable to list the app directory", "prefix", dirPrefix)
+			return remoteDataClientResponse, err
+		}
+		addAppDirToList(ctx, &remoteDataClientResponse, dirPrefix, objects)
 	}
 
 	return remoteDataClientResponse, nil
 }
 
+// convertMinioObject converts the minio object to the remote object
+func convertMinioObject(object minio.ObjectInfo) *RemoteObject {
+	newETag := object.ETag
+	newKey := object.Key
+	newLastModified := object.LastModified
+	newSize := object.Size
+	newStorageClass := object.StorageClass
+	return &RemoteObject{Etag: &newETag, Key: &newKey, LastModified: &newLastModified, Size: &newSize, StorageClass: &newStorageClass}
+}
+
+// listAppDirObjects lists all the objects under the app directory, at all the levels
+func (client *MinioClient) listAppDirObjects(ctx context.Context, dirPrefix string) ([]*RemoteObject, error) {
+	var objects []*RemoteObject
+
+	opts := minio.ListObjectsOptions{
+		UseV1:     true,
+		Prefix:    dirPrefix,
+		Recursive: true,
+	}
+
+	for object := range client.Client.ListObjects(ctx, client.BucketName, opts) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("got an object error: %v for bucket: %s", object.Err, client.BucketName)
+		}
+		objects = append(objects, convertMinioObject(object))
+	}
+
+	return objects, nil
+}
+
 // DownloadApp downloads an app package from remote storage
 func (client *MinioClient) DownloadApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (bool, error) {
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("DownloadApp").WithValues("remoteFile", downloadRequest.RemoteFile,
 		downloadRequest.LocalFile, downloadRequest.Etag)
 
+	if IsAppDirEtag(downloadRequest.Etag) {
+		objects, err := client.listAppDirObjects(ctx, getAppDirPrefix(downloadRequest.RemoteFile))
+		if err == nil {
+			err = downloadAppDir(ctx, downloadRequest, objects, func(object *RemoteObject, localFile string) error {
+				options := minio.GetObjectOptions{}
+				options.SetMatchETag(*object.Etag)
+				return client.Client.FGetObject(ctx, client.BucketName, *object.Key, localFile, options)
+			})
+		}
+		if err != nil {
+			scopedLog.Error(err, "Unable to download app directory")
+			return false, err
+		}
+		return true, nil
+	}
+
 	file, err := os.Create(downloadRequest.LocalFile)
 	if err != nil {
 		scopedLog.Error(err, "Unable to create local file")
@@ -187,6 +269,8 @@ func (client *MinioClient) DownloadApp(ctx context.Context, downloadRequest Remo
 	}
 	defer file.Close()
 
+	// StartOffset is not used, as FGetObject keeps its own partial file for the
+	// etag, and resumes the download from it
 	s3Client := client.Client
 
 	options := minio.GetObjectOptions{}
@@ -203,3 +287,52 @@ func (client *MinioClient) DownloadApp(ctx context.Context, downloadRequest Remo
 
 	return true, nil
 }
+
+// StreamApp opens a stream to read an app package from remote storage
+func (client *MinioClient) StreamApp(ctx context.Context, downloadRequest RemoteDataDownloadRequest) (io.ReadCloser, error) {
+	getObjectClient, ok := client.Client.(SplunkMinioGetObjectClient)
+	if !ok {
+		return nil, fmt.Errorf("the minio client doesn't support streaming the apps")
+	}
+
+	options := minio.GetObjectOptions{}
+	// set the option to match the specified etag on remote storage
+	options.SetMatchETag(downloadRequest.Etag)
+
+	object, err := getObjectClient.GetObject(ctx, client.BucketName, downloadRequest.RemoteFile, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+// UploadData uploads a local file to remote storage
+func (client *MinioClient) UploadData(ctx context.Context, uploadRequest RemoteDataUploadRequest) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("UploadData").WithValues("remoteFile", uploadRequest.RemoteFile, "localFile", uploadRequest.LocalFile)
+
+	putObjectClient, ok := client.Client.(SplunkMinioPutObjectClient)
+	if !ok {
+		return fmt.Errorf("the minio client doesn't support uploading data")
+	}
+
+	_, err := putObjectClient.FPutObject(ctx, client.BucketName, uploadRequest.RemoteFile, uploadRequest.LocalFile, minio.PutObjectOptions{})
+	if err != nil {
+		scopedLog.Error(err, "Unable to upload file")
+		return err
+	}
+
+	scopedLog.Info("File uploaded")
+	return nil
+}
+
+// DeleteData deletes a file from remote storage
+func (client *MinioClient) DeleteData(ctx context.Context, remoteFile string) error {
+	putObjectClient, ok := client.Client.(SplunkMinioPutObjectClient)
+	if !ok {
+		return fmt.Errorf("the minio client doesn't support deleting data")
+	}
+
+	return putObjectClient.RemoveObject(ctx, client.BucketName, remoteFile, minio.RemoveObjectOptions{})
+}
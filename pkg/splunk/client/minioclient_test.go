@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/minio/minio-go/v7"
 	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
 	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
 )
@@ -507,3 +508,43 @@ func TestMinioDownloadAppShouldFail(t *testing.T) {
 		t.Errorf("DownloadApp should have returned error since remoteFile name is empty")
 	}
 }
+
+// mockMinioPutObjectClient records the uploads and the deletes of the backups
+type mockMinioPutObjectClient struct {
+	spltest.MockMinioS3Client
+	uploads map[string]string
+	deletes []string
+}
+
+func (mockClient *mockMinioPutObjectClient) FPutObject(ctx context.Context, bucketName string, objectName string, filePath string, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	mockClient.uploads[bucketName+"/"+objectName] = filePath
+	return minio.UploadInfo{}, nil
+}
+
+func (mockClient *mockMinioPutObjectClient) RemoveObject(ctx context.Context, bucketName string, objectName string, opts minio.RemoveObjectOptions) error {
+	mockClient.deletes = append(mockClient.deletes, bucketName+"/"+objectName)
+	return nil
+}
+
+func TestMinioUploadData(t *testing.T) {
+	ctx := context.TODO()
+	mockClient := &mockMinioPutObjectClient{uploads: make(map[string]string)}
+	minioClient := &MinioClient{BucketName: "backups", Client: mockClient}
+
+	err := minioClient.UploadData(ctx, RemoteDataUploadRequest{LocalFile: "/tmp/kvstore.tar.gz", RemoteFile: "stack1/kvstore.tar.gz"})
+	if err != nil || mockClient.uploads["backups/stack1/kvstore.tar.gz"] != "/tmp/kvstore.tar.gz" {
+		t.Errorf("UploadData should upload the local file. uploads: %v, error: %v", mockClient.uploads, err)
+	}
+
+	err = minioClient.DeleteData(ctx, "stack1/kvstore.tar.gz")
+	if err != nil || len(mockClient.deletes) != 1 || mockClient.deletes[0] != "backups/stack1/kvstore.tar.gz" {
+		t.Errorf("DeleteData should delete the remote file. deletes: %v, error: %v", mockClient.deletes, err)
+	}
+
+	// the client doesn't support uploads
+	minioClient.Client = spltest.MockMinioS3Client{}
+	err = minioClient.DeleteData(ctx, "stack1/kvstore.tar.gz")
+	if err == nil {
+		t.Errorf("DeleteData should fail when the client doesn't support deletes")
+	}
+}
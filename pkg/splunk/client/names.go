@@ -20,11 +20,19 @@ const (
 	// For example : https://mystorageaccount.blob.core.windows.net/myappsbucket?prefix=standalone&restype=container&comp=list&include=snapshots&include=metadata
 	azureBlobListAppFetchURL = "%s/%s?prefix=%s&restype=container&comp=list&include=snapshots&include=metadata"
 
+	// Azure query parameter to get the next page of a listing, from the NextMarker of the previous page
+	azureBlobListMarkerParam = "&marker=%s"
+
 	// Azure URL for downloading an app package
 	// URL format is {azure_end_point}/{bucketName}/{pathToAppPackage}
 	// For example : https://mystorageaccount.blob.core.windows.net/myappsbucket/standlone/myappsteamapp.tgz
 	azureBlobDownloadAppFetchURL = "%s/%s/%s"
 
+	// Azure URL for uploading a blob, and deleting it
+	// URL format is {azure_end_point}/{bucketName}/{pathToBlob}
+	// For example : https://mystorageaccount.blob.core.windows.net/mybackupsbucket/standalone/kvstore-20221015.tar.gz
+	azureBlobUploadDataURL = "%s/%s/%s"
+
 	// Header strings
 	headerAuthorization      = "Authorization"
 	headerCacheControl       = "Cache-Control"
@@ -41,6 +49,7 @@ const (
 	headerIfUnmodifiedSince  = "If-Unmodified-Since"
 	headerRange              = "Range"
 	headerUserAgent          = "User-Agent"
+	headerXmsBlobType        = "x-ms-blob-type"
 	headerXmsDate            = "x-ms-date"
 	headerXmsVersion         = "x-ms-version"
 
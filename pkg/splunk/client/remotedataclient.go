@@ -16,7 +16,19 @@
 package client
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -28,6 +40,8 @@ import (
 // aws
 // minio
 // azure
+// git
+// http
 // in future we may have
 // googlestorage
 var RemoteDataClientsMap = make(map[string]GetRemoteDataClientWrapper)
@@ -55,9 +69,10 @@ type RemoteDataListResponse struct {
 // local file path where the downloaded data should be written as well as
 // the etag data if available
 type RemoteDataDownloadRequest struct {
-	LocalFile  string // file path where the remote data will be written
-	RemoteFile string // file name with path relative to the bucket
-	Etag       string // unique tag of the object
+	LocalFile   string // file path where the remote data will be written
+	RemoteFile  string // file name with path relative to the bucket
+	Etag        string // unique tag of the object
+	StartOffset int64  // offset to resume a partial download from, the LocalFile already has the data till this offset
 }
 
 // RemoteDataClient is an interface to provide
@@ -71,6 +86,53 @@ type RemoteDataClient interface {
 	DownloadApp(context.Context, RemoteDataDownloadRequest) (bool /* return pass/fail */, error)
 }
 
+// RemoteDataStreamClient is implemented by the RemoteDataClients that can stream an app package,
+// so that it can be copied to the pods without staging it on the operator
+type RemoteDataStreamClient interface {
+
+	// Open a stream to read a given app package as per the inputs provided in the `RemoteDataDownloadRequest`.
+	// LocalFile and StartOffset are not used
+	StreamApp(context.Context, RemoteDataDownloadRequest) (io.ReadCloser, error)
+}
+
+// IsAppStreamingSupported checks if the RemoteDataClient of the given provider can stream app packages
+func IsAppStreamingSupported(provider string) bool {
+	switch provider {
+	case "aws", "minio", "azure", "http":
+		return true
+	default:
+		return false
+	}
+}
+
+// RemoteDataUploadRequest struct specifies the local file to upload, and
+// the remote file path where it should be written
+type RemoteDataUploadRequest struct {
+	LocalFile  string // file path of the data to upload
+	RemoteFile string // file name with path relative to the bucket
+}
+
+// RemoteDataUploadClient is implemented by the RemoteDataClients that can write to the remote storage,
+// so that the backups taken by the operator can be kept there
+type RemoteDataUploadClient interface {
+
+	// Upload a given local file as per the inputs provided in the `RemoteDataUploadRequest`
+	UploadData(context.Context, RemoteDataUploadRequest) error
+
+	// Delete a given remote file, with path relative to the bucket
+	DeleteData(context.Context, string) error
+}
+
+// IsDataUploadSupported checks if the RemoteDataClient of the given provider can upload data
+func IsDataUploadSupported(provider string) bool {
+	switch provider {
+	case "aws", "minio", "azure":
+		return true
+	default:
+		return false
+	}
+}
+
 // GetRemoteDataClientWrapper is a wrapper around init function pointers
 type GetRemoteDataClientWrapper struct {
 	GetRemoteDataClient
@@ -122,7 +184,238 @@ func RegisterRemoteDataClient(ctx context.Context, provider string) {
 		RegisterMinioClient()
 	case "azure":
 		RegisterAzureBlobClient()
+	case "git":
+		RegisterGitClient()
+	case "http":
+		RegisterHTTPManifestClient()
 	default:
 		scopedLog.Error(nil, "Invalid provider specified", "provider", provider)
 	}
 }
+
+// openDownloadFile opens the local file for the download request. The file is truncated to
+// the start offset, so that a partial download is resumed from where it was left off
+func openDownloadFile(downloadRequest RemoteDataDownloadRequest) (*os.File, error) {
+	file, err := os.OpenFile(downloadRequest.LocalFile, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = file.Truncate(downloadRequest.StartOffset)
+	if err == nil {
+		_, err = file.Seek(downloadRequest.StartOffset, 0)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to resume the download at offset %d, error: %v", downloadRequest.StartOffset, err)
+	}
+
+	return file, nil
+}
+
+// getRangeHeader returns the http Range header value to resume a partial download
+func getRangeHeader(startOffset int64) string {
+	return fmt.Sprintf("bytes=%d-", startOffset)
+}
+
+// appDirEtagPrefix is the prefix of the etag of an app directory, i.e. an uncompressed app kept as the objects under
+// a directory on the remote storage. Like the app directories of a git repository, an app directory is listed as
+// <directory>.tgz, and is packaged into a tarball during the download
+const appDirEtagPrefix = "appdir-"
+
+// IsAppDirEtag checks if the etag is of an app directory, that is packaged during the download
+func IsAppDirEtag(etag string) bool {
+	return strings.HasPrefix(strings.Trim(etag, "\""), appDirEtagPrefix)
+}
+
+// getAppDirKey returns the key under which the app directory with the given prefix is listed
+func getAppDirKey(dirPrefix string) string {
+	return strings.TrimSuffix(dirPrefix, "/") + ".tgz"
+}
+
+// getAppDirPrefix returns the prefix of the app directory listed under the given key
+func getAppDirPrefix(key string) string {
+	return strings.TrimSuffix(key, ".tgz") + "/"
+}
+
+// isAppDirMarker checks if the object is a placeholder for a directory, rather than a file of the app
+func isAppDirMarker(object *RemoteObject) bool {
+	return object.Key == nil || strings.HasSuffix(*object.Key, "/")
+}
+
+// getAppDirEtag returns the etag of an app directory, derived from the keys and the etags of its objects,
+// so that a change to any of the objects is detected as a change of the app
+func getAppDirEtag(dirPrefix string, objects []*RemoteObject) string {
+	var entries []string
+	for _, object := range objects {
+		if isAppDirMarker(object) {
+			continue
+		}
+		var etag string
+		if object.Etag != nil {
+			etag = strings.Trim(*object.Etag, "\"")
+		}
+		entries = append(entries, strings.TrimPrefix(*object.Key, dirPrefix)+"\t"+etag)
+	}
+	sort.Strings(entries)
+
+	digest := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return appDirEtagPrefix + hex.EncodeToString(digest[:])
+}
+
+// getAppDirRemoteObject returns the remote object under which the app directory is listed, or nil if the directory
+// has no files. The size is not known till the app directory is packaged
+func getAppDirRemoteObject(dirPrefix string, objects []*RemoteObject) *RemoteObject {
+	var lastModified time.Time
+	var numFiles int
+	for _, object := range objects {
+		if isAppDirMarker(object) {
+			continue
+		}
+		numFiles++
+		if object.LastModified != nil && object.LastModified.After(lastModified) {
+			lastModified = *object.LastModified
+		}
+	}
+	if numFiles == 0 {
+		return nil
+	}
+
+	newKey := getAppDirKey(dirPrefix)
+	newETag := getAppDirEtag(dirPrefix, objects)
+	var newSize int64
+	newStorageClass := ""
+	return &RemoteObject{Etag: &newETag, Key: &newKey, LastModified: &lastModified, Size: &newSize, StorageClass: &newStorageClass}
+}
+
+// addAppDirToList adds the app directory to the list of apps, unless an app package is listed under the same key
+func addAppDirToList(ctx context.Context, remoteDataListResponse *RemoteDataListResponse, dirPrefix string, objects []*RemoteObject) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("addAppDirToList").WithValues("dirPrefix", dirPrefix)
+
+	appDirObject := getAppDirRemoteObject(dirPrefix, objects)
+	if appDirObject == nil {
+		scopedLog.Info("Ignoring the empty app directory")
+		return
+	}
+
+	for _, object := range remoteDataListResponse.Objects {
+		if object.Key != nil && *object.Key == *appDirObject.Key {
+			scopedLog.Info("Ignoring the app directory, as an app package with the same name exists", "key", *object.Key)
+			return
+		}
+	}
+
+	scopedLog.Info("Got an app directory", "key", *appDirObject.Key, "etag", *appDirObject.Etag)
+	remoteDataListResponse.Objects = append(remoteDataListResponse.Objects, appDirObject)
+}
+
+// downloadAppDir downloads the objects of the app directory to a temporary directory, with the given function,
+// and packages them into a tarball at the local file, with the directory name as the top folder of the app
+func downloadAppDir(ctx context.Context, downloadRequest RemoteDataDownloadRequest, objects []*RemoteObject, downloadObject func(object *RemoteObject, localFile string) error) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("downloadAppDir").WithValues("remoteFile", downloadRequest.RemoteFile, "localFile", downloadRequest.LocalFile)
+
+	dirPrefix := getAppDirPrefix(downloadRequest.RemoteFile)
+	if getAppDirEtag(dirPrefix, objects) != strings.Trim(downloadRequest.Etag, "\"") {
+		return fmt.Errorf("app directory %s has changed since it was listed", dirPrefix)
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(downloadRequest.LocalFile), filepath.Base(downloadRequest.LocalFile)+"_dir_*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	appDir := filepath.Join(tmpDir, path.Base(dirPrefix))
+	for _, object := range objects {
+		if isAppDirMarker(object) {
+			continue
+		}
+
+		relPath := strings.TrimPrefix(*object.Key, dirPrefix)
+		if !filepath.IsLocal(relPath) {
+			return fmt.Errorf("invalid file %s in the app directory %s", *object.Key, dirPrefix)
+		}
+
+		localFile := filepath.Join(appDir, relPath)
+		err = os.MkdirAll(filepath.Dir(localFile), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = downloadObject(object, localFile)
+		if err != nil {
+			scopedLog.Error(err, "Unable to download the file of the app directory", "key", *object.Key)
+			return err
+		}
+	}
+
+	err = packageAppDir(appDir, downloadRequest.LocalFile)
+	if err != nil {
+		scopedLog.Error(err, "Unable to package the app directory")
+		return err
+	}
+
+	scopedLog.Info("App directory downloaded and packaged", "numFiles", len(objects))
+	return nil
+}
+
+// packageAppDir writes the app directory into a tarball at the local file, with the directory as the top folder
+func packageAppDir(appDir string, localFile string) error {
+	file, err := os.Create(localFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = filepath.WalkDir(appDir, func(filePath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		fileInfo, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(fileInfo, "")
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(filepath.Dir(appDir), filePath)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if dirEntry.IsDir() {
+			header.Name += "/"
+		}
+
+		err = tarWriter.WriteHeader(header)
+		if err != nil || !fileInfo.Mode().IsRegular() {
+			return err
+		}
+
+		srcFile, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer srcFile.Close()
+
+		_, err = io.Copy(tarWriter, srcFile)
+		return err
+	})
+	if err == nil {
+		err = tarWriter.Close()
+	}
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+
+	return err
+}
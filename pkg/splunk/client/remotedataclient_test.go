@@ -16,8 +16,14 @@
 package client
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestRegisterRemoteDataClient(t *testing.T) {
@@ -45,10 +51,22 @@ func TestRegisterRemoteDataClient(t *testing.T) {
 		t.Errorf("We should have initialized the client for azure as well.")
 	}
 
-	// 3. Test for invalid provider
+	// 4. Test for git
+	RegisterRemoteDataClient(ctx, "git")
+	if len(RemoteDataClientsMap) != 4 {
+		t.Errorf("We should have initialized the client for git as well.")
+	}
+
+	// 5. Test for http
+	RegisterRemoteDataClient(ctx, "http")
+	if len(RemoteDataClientsMap) != 5 {
+		t.Errorf("We should have initialized the client for http as well.")
+	}
+
+	// 6. Test for invalid provider
 	RegisterRemoteDataClient(ctx, "invalid")
-	if len(RemoteDataClientsMap) > 3 {
-		t.Errorf("We should only have initialized the client for aws, minio and azure but not for an invalid provider.")
+	if len(RemoteDataClientsMap) > 5 {
+		t.Errorf("We should only have initialized the client for aws, minio, azure, git and http but not for an invalid provider.")
 	}
 
 }
@@ -82,3 +100,128 @@ func TestGetSetRemoteDataClientInitFuncPtr(t *testing.T) {
 		t.Errorf("We should have set GetInitFunc func pointer for AWS client.")
 	}
 }
+
+func getAppDirTestObjects(dirPrefix string, etags ...string) []*RemoteObject {
+	var objects []*RemoteObject
+	keys := []string{dirPrefix, dirPrefix + "default/app.conf", dirPrefix + "bin/script.py"}
+	for i := range keys {
+		key := keys[i]
+		etag := ""
+		if i > 0 {
+			etag = etags[i-1]
+		}
+		lastModified := time.Date(2022, 1, i+1, 0, 0, 0, 0, time.UTC)
+		objects = append(objects, &RemoteObject{Key: &key, Etag: &etag, LastModified: &lastModified})
+	}
+	return objects
+}
+
+func TestAddAppDirToList(t *testing.T) {
+	ctx := context.TODO()
+	dirPrefix := "appsRepo/app1/"
+
+	response := RemoteDataListResponse{}
+	addAppDirToList(ctx, &response, dirPrefix, getAppDirTestObjects(dirPrefix, "etag1", "etag2"))
+	if len(response.Objects) != 1 {
+		t.Fatalf("app directory should be listed. got: %d objects", len(response.Objects))
+	}
+	appDirObject := response.Objects[0]
+	if *appDirObject.Key != "appsRepo/app1.tgz" || !IsAppDirEtag(*appDirObject.Etag) || *appDirObject.Size != 0 {
+		t.Errorf("incorrect app directory object. key: %s, etag: %s, size: %d", *appDirObject.Key, *appDirObject.Etag, *appDirObject.Size)
+	}
+	if !appDirObject.LastModified.Equal(time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("app directory should be modified at the latest change of its files. got: %v", appDirObject.LastModified)
+	}
+
+	// etag changes with any of the files
+	if getAppDirEtag(dirPrefix, getAppDirTestObjects(dirPrefix, "etag1", "etag3")) == *appDirObject.Etag {
+		t.Errorf("app directory etag should change with the etag of a file")
+	}
+
+	// empty app directory, and the app directory with the same name as an app package, are ignored
+	addAppDirToList(ctx, &response, "appsRepo/app2/", getAppDirTestObjects("appsRepo/app2/", "etag1", "etag2")[:1])
+	addAppDirToList(ctx, &response, dirPrefix, getAppDirTestObjects(dirPrefix, "etag1", "etag2"))
+	if len(response.Objects) != 1 {
+		t.Errorf("app directory should not be listed. got: %d objects", len(response.Objects))
+	}
+}
+
+func TestDownloadAppDir(t *testing.T) {
+	ctx := context.TODO()
+	dirPrefix := "appsRepo/app1/"
+	objects := getAppDirTestObjects(dirPrefix, "etag1", "etag2")
+
+	downloadRequest := RemoteDataDownloadRequest{
+		LocalFile:  filepath.Join(t.TempDir(), "app1.tgz_hash"),
+		RemoteFile: "appsRepo/app1.tgz",
+		Etag:       getAppDirEtag(dirPrefix, objects),
+	}
+
+	downloadObject := func(object *RemoteObject, localFile string) error {
+		return os.WriteFile(localFile, []byte(*object.Etag), 0644)
+	}
+
+	err := downloadAppDir(ctx, downloadRequest, objects, downloadObject)
+	if err != nil {
+		t.Fatalf("app directory should be downloaded. error: %v", err)
+	}
+
+	file, err := os.Open(downloadRequest.LocalFile)
+	if err != nil {
+		t.Fatalf("app package should be created. error: %v", err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("app package should be a gzip compressed tarball. error: %v", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	files := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unable to read the app package. error: %v", err)
+		}
+		contents, _ := io.ReadAll(tarReader)
+		files[header.Name] = string(contents)
+	}
+	if files["app1/default/app.conf"] != "etag1" || files["app1/bin/script.py"] != "etag2" {
+		t.Errorf("app package should have the files of the app directory under the top folder. got: %v", files)
+	}
+	if _, ok := files["app1/"]; !ok {
+		t.Errorf("app package should have the top folder. got: %v", files)
+	}
+
+	// app directory changed since the listing
+	downloadRequest.Etag = getAppDirEtag(dirPrefix, getAppDirTestObjects(dirPrefix, "etag1", "etag3"))
+	err = downloadAppDir(ctx, downloadRequest, objects, downloadObject)
+	if err == nil {
+		t.Errorf("changed app directory should fail the download")
+	}
+
+	// files outside of the app directory are not downloaded
+	key := dirPrefix + "../app2/default/app.conf"
+	objects = append(objects, &RemoteObject{Key: &key})
+	downloadRequest.Etag = getAppDirEtag(dirPrefix, objects)
+	err = downloadAppDir(ctx, downloadRequest, objects, downloadObject)
+	if err == nil {
+		t.Errorf("file outside of the app directory should fail the download")
+	}
+}
+
+func TestIsDataUploadSupported(t *testing.T) {
+	for _, provider := range []string{"aws", "minio", "azure"} {
+		if !IsDataUploadSupported(provider) {
+			t.Errorf("upload should be supported for provider %s", provider)
+		}
+	}
+	for _, provider := range []string{"git", "http", ""} {
+		if IsDataUploadSupported(provider) {
+			t.Errorf("upload should not be supported for provider %s", provider)
+		}
+	}
+}
@@ -91,6 +91,15 @@ const (
 	// DefaultMaxConcurrentAppDownloads sets the default value for maximum concurrent app downloads
 	DefaultMaxConcurrentAppDownloads uint64 = 5
 
+	// DefaultOperatorMaxAppDownloads sets the default value for maximum concurrent app downloads across all the CRs
+	DefaultOperatorMaxAppDownloads = 15
+
+	// DefaultOperatorMaxAppPodCopies sets the default value for maximum concurrent app pod copies across all the CRs
+	DefaultOperatorMaxAppPodCopies = 15
+
+	// DefaultOperatorMaxAppInstalls sets the default value for maximum concurrent app installs across all the CRs
+	DefaultOperatorMaxAppInstalls = 30
+
 	// MockClientInduceErrorGet represents an error for get Api
 	MockClientInduceErrorGet = "mockClientGetError"
 
@@ -53,3 +53,9 @@ type StatefulSetPodManager interface {
 	// FinishRecycle completes recycle event for pod and returns true, or returns false if nothing to do
 	FinishRecycle(context.Context, int32) (bool, error)
 }
+
+// StatefulSetPodRecycleOrderer may be implemented by a StatefulSetPodManager to order the pods checked for updates
+type StatefulSetPodRecycleOrderer interface {
+	// GetRecycleOrder returns the ordinals of the given number of ready pods, in the order they are checked for updates
+	GetRecycleOrder(context.Context, int32) []int32
+}
@@ -188,8 +188,16 @@ func UpdateStatefulSetPods(ctx context.Context, c splcommon.ControllerClient, st
 	// ready and no StatefulSet scaling is required
 	// readyReplicas == desiredReplicas
 
-	// check existing pods for desired updates
-	for n := readyReplicas - 1; n >= 0; n-- {
+	// check existing pods for desired updates, from the highest ordinal unless the pod manager orders them
+	var order []int32
+	if orderer, ok := mgr.(splcommon.StatefulSetPodRecycleOrderer); ok {
+		order = orderer.GetRecycleOrder(ctx, readyReplicas)
+	} else {
+		for n := readyReplicas - 1; n >= 0; n-- {
+			order = append(order, n)
+		}
+	}
+	for _, n := range order {
 		// get Pod
 		podName := fmt.Sprintf("%s-%d", statefulSet.GetName(), n)
 		namespacedName := types.NamespacedName{Namespace: statefulSet.GetNamespace(), Name: podName}
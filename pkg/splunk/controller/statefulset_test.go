@@ -18,6 +18,8 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 
 	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
@@ -118,6 +120,24 @@ func TestApplyStatefulSet(t *testing.T) {
 	}
 }
 
+// orderedTestPodManager is used for UT testing of the order pods are recycled in
+type orderedTestPodManager struct {
+	DefaultStatefulSetPodManager
+	order    []int32
+	recycled []int32
+}
+
+// PrepareRecycle for orderedTestPodManager records the pod and returns true
+func (mgr *orderedTestPodManager) PrepareRecycle(ctx context.Context, n int32) (bool, error) {
+	mgr.recycled = append(mgr.recycled, n)
+	return true, nil
+}
+
+// GetRecycleOrder for orderedTestPodManager returns the configured order
+func (mgr *orderedTestPodManager) GetRecycleOrder(ctx context.Context, readyReplicas int32) []int32 {
+	return mgr.order
+}
+
 func TestDefaultStatefulSetPodManager(t *testing.T) {
 
 	// test for updating
@@ -333,6 +353,57 @@ func TestUpdateStatefulSetPods(t *testing.T) {
 
 }
 
+func TestUpdateStatefulSetPodsRecycleOrder(t *testing.T) {
+	var replicas int32 = 2
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1",
+			Namespace: "test",
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+		},
+		Status: appsv1.StatefulSetStatus{
+			Replicas:        replicas,
+			ReadyReplicas:   replicas,
+			UpdatedReplicas: 0,
+			UpdateRevision:  "v1",
+		},
+	}
+	var pods []client.Object
+	for n := 0; n < 2; n++ {
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("splunk-stack1-%d", n),
+				Namespace: "test",
+				Labels: map[string]string{
+					"controller-revision-hash": "v0",
+				},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Ready: true},
+				},
+			},
+		})
+	}
+
+	// pods are recycled from the highest ordinal by default
+	mgr := orderedTestPodManager{}
+	phase, err := updateStatefulSetPodsTester(t, &mgr.DefaultStatefulSetPodManager, statefulSet, 2, append(pods, statefulSet)...)
+	if err != nil || phase != enterpriseApi.PhaseUpdating {
+		t.Errorf("UpdateStatefulSetPods should recycle a pod. phase: %s, error: %v", phase, err)
+	}
+
+	// pods are recycled in the order of the pod manager
+	mgr.order = []int32{0, 1}
+	phase, err = updateStatefulSetPodsTester(t, &mgr, statefulSet, 2, append(pods, statefulSet)...)
+	if err != nil || phase != enterpriseApi.PhaseUpdating || !reflect.DeepEqual(mgr.recycled, []int32{0}) {
+		t.Errorf("UpdateStatefulSetPods should recycle the first pod of the order. recycled: %v, phase: %s, error: %v", mgr.recycled, phase, err)
+	}
+}
+
 func TestSetStatefulSetOwnerRef(t *testing.T) {
 
 	ctx := context.TODO()
@@ -26,6 +26,7 @@ import (
 	//stdlog "log"
 	//"github.com/go-logr/stdr"
 
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
 	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -322,6 +323,25 @@ func MergeServiceSpecUpdates(ctx context.Context, current *corev1.ServiceSpec, r
 		result = true
 	}
 
+	// check for changes in the cluster manager role of the Selector, as the service of redundant cluster managers only
+	// targets the active one. The rest of the Selector is left as it is
+	currentRole, currentHasRole := current.Selector[enterpriseApi.ClusterManagerRoleLabel]
+	revisedRole, revisedHasRole := revised.Selector[enterpriseApi.ClusterManagerRoleLabel]
+	if currentRole != revisedRole || currentHasRole != revisedHasRole {
+		scopedLog.Info("Service Selector cluster manager role differs",
+			"current", currentRole,
+			"revised", revisedRole)
+		if revisedHasRole {
+			if current.Selector == nil {
+				current.Selector = map[string]string{}
+			}
+			current.Selector[enterpriseApi.ClusterManagerRoleLabel] = revisedRole
+		} else {
+			delete(current.Selector, enterpriseApi.ClusterManagerRoleLabel)
+		}
+		result = true
+	}
+
 	// check for changes in Ports
 	if splcommon.CompareServicePorts(current.Ports, revised.Ports) {
 		scopedLog.Info("Service Ports differs",
@@ -246,6 +246,24 @@ func TestMergeServiceSpecUpdates(t *testing.T) {
 	matcher = func() bool { return reflect.DeepEqual(current.Ports, revised.Ports) }
 	svcUpdateTester("Service Ports change")
 
+	// check cluster manager role added to the Selector
+	current.Selector = map[string]string{"app.kubernetes.io/instance": "splunk-test-cluster-manager"}
+	revised.Selector = map[string]string{"app.kubernetes.io/instance": "splunk-test-cluster-manager", "clustermanager.enterprise.splunk.com/role": "active"}
+	matcher = func() bool { return reflect.DeepEqual(current.Selector, revised.Selector) }
+	svcUpdateTester("Service Selector cluster manager role added")
+
+	// check cluster manager role removed from the Selector
+	revised.Selector = map[string]string{"app.kubernetes.io/instance": "splunk-test-cluster-manager"}
+	matcher = func() bool { return reflect.DeepEqual(current.Selector, revised.Selector) }
+	svcUpdateTester("Service Selector cluster manager role removed")
+
+	// the rest of the Selector is not reconciled
+	revised.Selector = map[string]string{"app.kubernetes.io/instance": "splunk-test-indexer"}
+	if MergeServiceSpecUpdates(ctx, &current, &revised, name) || current.Selector["app.kubernetes.io/instance"] != "splunk-test-cluster-manager" {
+		t.Errorf("MergeServiceSpecUpdates() should only reconcile the cluster manager role of the Selector. got: %v", current.Selector)
+	}
+	revised.Selector = current.Selector
+
 	// new ExternalIPs
 	revised.ExternalIPs = []string{"1.2.3.4"}
 	matcher = func() bool { return reflect.DeepEqual(current.ExternalIPs, revised.ExternalIPs) }
@@ -0,0 +1,355 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	enterpriseApiV3 "github.com/splunk/splunk-operator/api/v3"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The deployment status of each app of a CR is kept in an AppDeployment resource owned by the CR, and the CR status keeps
+// only a summary. The App Framework still works on the AppsSrcDeployStatus of the CR status in memory: it is loaded from
+// the AppDeployments when a reconcile starts, and it is written back to the AppDeployments before the CR status is updated.
+
+// appPhaseStatusNames maps the app phase status codes to their names
+var appPhaseStatusNames = map[enterpriseApi.AppPhaseStatusType]string{
+	enterpriseApi.AppPkgDownloadPending:     "AppPkgDownloadPending",
+	enterpriseApi.AppPkgDownloadInProgress:  "AppPkgDownloadInProgress",
+	enterpriseApi.AppPkgDownloadComplete:    "AppPkgDownloadComplete",
+	enterpriseApi.AppPkgDownloadError:       "AppPkgDownloadError",
+	enterpriseApi.AppPkgPodCopyPending:      "AppPkgPodCopyPending",
+	enterpriseApi.AppPkgPodCopyInProgress:   "AppPkgPodCopyInProgress",
+	enterpriseApi.AppPkgPodCopyComplete:     "AppPkgPodCopyComplete",
+	enterpriseApi.AppPkgMissingFromOperator: "AppPkgMissingFromOperator",
+	enterpriseApi.AppPkgPodCopyError:        "AppPkgPodCopyError",
+	enterpriseApi.AppPkgInstallPending:      "AppPkgInstallPending",
+	enterpriseApi.AppPkgInstallInProgress:   "AppPkgInstallInProgress",
+	enterpriseApi.AppPkgInstallComplete:     "AppPkgInstallComplete",
+	enterpriseApi.AppPkgMissingOnPodError:   "AppPkgMissingOnPodError",
+	enterpriseApi.AppPkgInstallError:        "AppPkgInstallError",
+}
+
+// getAppPhaseStatusName returns the name of an app phase status code
+func getAppPhaseStatusName(status enterpriseApi.AppPhaseStatusType) string {
+	if name, ok := appPhaseStatusNames[status]; ok {
+		return name
+	}
+	return ""
+}
+
+// setAppDeployError records the last error seen while deploying an app
+func setAppDeployError(appDeployInfo *enterpriseApi.AppDeploymentInfo, err error) {
+	if err != nil {
+		appDeployInfo.LastError = err.Error()
+	}
+}
+
+// getAppDeploymentContext returns the app deployment context from the status of a CR, nil if the CR doesn't run the App Framework
+func getAppDeploymentContext(cr splcommon.MetaObject) *enterpriseApi.AppDeploymentContext {
+	switch cr := cr.(type) {
+	case *enterpriseApi.Standalone:
+		return &cr.Status.AppContext
+	case *enterpriseApi.LicenseManager:
+		return &cr.Status.AppContext
+	case *enterpriseApiV3.LicenseMaster:
+		return &cr.Status.AppContext
+	case *enterpriseApi.SearchHeadCluster:
+		return &cr.Status.AppContext
+	case *enterpriseApi.ClusterManager:
+		return &cr.Status.AppContext
+	case *enterpriseApiV3.ClusterMaster:
+		return &cr.Status.AppContext
+	case *enterpriseApi.MonitoringConsole:
+		return &cr.Status.AppContext
+	}
+	return nil
+}
+
+// getAppDeploymentName returns the name of the AppDeployment of an app of a CR
+func getAppDeploymentName(cr splcommon.MetaObject, appSrcName, appName string) string {
+	// app package names are not always valid resource names, so, a digest of the app source and the app name is used
+	digest := sha256.Sum256([]byte(appSrcName + "/" + appName))
+	return fmt.Sprintf("%s-%s-%s", strings.ToLower(cr.GetObjectKind().GroupVersionKind().Kind), cr.GetName(), hex.EncodeToString(digest[:])[:10])
+}
+
+// getAppDeploymentLabels returns the labels of the AppDeployment of an app of a CR
+func getAppDeploymentLabels(cr splcommon.MetaObject, appSrcName string, appDeployInfo *enterpriseApi.AppDeploymentInfo) map[string]string {
+	labels := map[string]string{
+		enterpriseApi.AppDeploymentCRKindLabel: cr.GetObjectKind().GroupVersionKind().Kind,
+		enterpriseApi.AppDeploymentCRNameLabel: cr.GetName(),
+	}
+
+	// app source, app and version names are user defined, so, they are added only when they are valid label values
+	optionalLabels := map[string]string{
+		enterpriseApi.AppDeploymentAppSourceLabel: appSrcName,
+		enterpriseApi.AppDeploymentAppNameLabel:   appDeployInfo.AppName,
+		enterpriseApi.AppDeploymentVersionLabel:   appDeployInfo.Version,
+	}
+	for key, value := range optionalLabels {
+		if value != "" && len(validation.IsValidLabelValue(value)) == 0 {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// getAppDeploymentStatus returns the status of the AppDeployment of an app of a CR
+func getAppDeploymentStatus(cr splcommon.MetaObject, appSrcName string, appDeployInfo *enterpriseApi.AppDeploymentInfo) enterpriseApi.AppDeploymentResourceStatus {
+	status := enterpriseApi.AppDeploymentResourceStatus{
+		CRKind:        cr.GetObjectKind().GroupVersionKind().Kind,
+		CRName:        cr.GetName(),
+		AppSourceName: appSrcName,
+		StatusName:    getAppPhaseStatusName(appDeployInfo.PhaseInfo.Status),
+	}
+	appDeployInfo.DeepCopyInto(&status.AppDeploymentInfo)
+
+	for podID, phaseInfo := range appDeployInfo.AuxPhaseInfo {
+		status.Pods = append(status.Pods, enterpriseApi.AppPodPhaseInfo{
+			PodName:    getApplicablePodNameForAppFramework(cr, podID),
+			Phase:      phaseInfo.Phase,
+			Status:     phaseInfo.Status,
+			StatusName: getAppPhaseStatusName(phaseInfo.Status),
+			FailCount:  phaseInfo.FailCount,
+		})
+	}
+	return status
+}
+
+// isAppDeploymentFailed checks if an app failed to deploy, after the retries
+func isAppDeploymentFailed(ctx context.Context, appDeployInfo *enterpriseApi.AppDeploymentInfo, afwConfig *enterpriseApi.AppFrameworkSpec) bool {
+	switch appDeployInfo.PhaseInfo.Status {
+	case enterpriseApi.AppPkgDownloadError, enterpriseApi.AppPkgPodCopyError, enterpriseApi.AppPkgMissingOnPodError, enterpriseApi.AppPkgInstallError:
+		return true
+	}
+
+	if appDeployInfo.DeployStatus == enterpriseApi.DeployStatusError || isPhaseMaxRetriesReached(ctx, &appDeployInfo.PhaseInfo, afwConfig) {
+		return true
+	}
+
+	for i := range appDeployInfo.AuxPhaseInfo {
+		if isPhaseMaxRetriesReached(ctx, &appDeployInfo.AuxPhaseInfo[i], afwConfig) {
+			return true
+		}
+	}
+	return false
+}
+
+// getAppsStatusSummary returns the number of apps in each deployment state
+func getAppsStatusSummary(ctx context.Context, appDeployContext *enterpriseApi.AppDeploymentContext) enterpriseApi.AppsStatusSummary {
+	var summary enterpriseApi.AppsStatusSummary
+	for _, appSrcDeployInfo := range appDeployContext.AppsSrcDeployStatus {
+		for i := range appSrcDeployInfo.AppDeploymentInfoList {
+			appDeployInfo := &appSrcDeployInfo.AppDeploymentInfoList[i]
+			summary.TotalApps++
+			switch {
+			case appDeployInfo.RepoState == enterpriseApi.RepoStateDeleted:
+				summary.DeletedApps++
+			case appDeployInfo.PhaseInfo.Phase == enterpriseApi.PhaseInstall && appDeployInfo.PhaseInfo.Status == enterpriseApi.AppPkgInstallComplete:
+				summary.InstalledApps++
+			case isAppDeploymentFailed(ctx, appDeployInfo, &appDeployContext.AppFrameworkConfig):
+				summary.FailedApps++
+			default:
+				summary.PendingApps++
+			}
+		}
+	}
+	return summary
+}
+
+// appDeploymentCacheSyncTimeout is how long the AppDeployments missing from the cache are waited for, before the
+// deployment status of the missing apps is rebuilt
+var appDeploymentCacheSyncTimeout = 2 * time.Minute
+
+// appDeploymentsMissingSince tracks when the AppDeployments of a CR were first found missing
+var appDeploymentsMissingSince = struct {
+	mutex sync.Mutex
+	since map[string]time.Time
+}{
+	since: make(map[string]time.Time),
+}
+
+// isAppDeploymentCacheSyncPending checks if the AppDeployments missing from the cache can still be waited for
+func isAppDeploymentCacheSyncPending(cr splcommon.MetaObject, missing bool) bool {
+	key := cr.GetObjectKind().GroupVersionKind().Kind + "/" + cr.GetNamespace() + "/" + cr.GetName()
+
+	appDeploymentsMissingSince.mutex.Lock()
+	defer appDeploymentsMissingSince.mutex.Unlock()
+
+	if !missing {
+		delete(appDeploymentsMissingSince.since, key)
+		return false
+	}
+
+	since, ok := appDeploymentsMissingSince.since[key]
+	if !ok {
+		appDeploymentsMissingSince.since[key] = time.Now()
+		return true
+	}
+	if time.Since(since) < appDeploymentCacheSyncTimeout {
+		return true
+	}
+
+	delete(appDeploymentsMissingSince.since, key)
+	return false
+}
+
+// listAppDeployments returns the AppDeployments owned by a CR
+func listAppDeployments(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject) ([]enterpriseApi.AppDeployment, error) {
+	appDeploymentList := &enterpriseApi.AppDeploymentList{}
+	err := c.List(ctx, appDeploymentList, client.InNamespace(cr.GetNamespace()), client.MatchingLabels{
+		enterpriseApi.AppDeploymentCRKindLabel: cr.GetObjectKind().GroupVersionKind().Kind,
+		enterpriseApi.AppDeploymentCRNameLabel: cr.GetName(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return appDeploymentList.Items, nil
+}
+
+// loadAppDeployStatusFromAppDeployments loads the deployment status of the apps of a CR from its AppDeployments,
+// when the CR status doesn't have it. Apps whose AppDeployments are gone are picked up again from the remote storage,
+// and their AppDeployments are recreated once they are published
+func loadAppDeployStatusFromAppDeployments(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appDeployContext *enterpriseApi.AppDeploymentContext) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("loadAppDeployStatusFromAppDeployments").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	if len(appDeployContext.AppsSrcDeployStatus) != 0 || appDeployContext.AppsStatusSummary.TotalApps == 0 {
+		return nil
+	}
+
+	appDeployments, err := listAppDeployments(ctx, client, cr)
+	if err != nil {
+		return err
+	}
+
+	// the AppDeployments are read from the cache, which may not have caught up with the last reconcile yet. If they
+	// are still missing after a while, they were deleted, so, the apps found are loaded, and the rest are rebuilt
+	missing := len(appDeployments) < appDeployContext.AppsStatusSummary.TotalApps
+	if isAppDeploymentCacheSyncPending(cr, missing) {
+		return fmt.Errorf("found %d app deployments, expected %d. will retry", len(appDeployments), appDeployContext.AppsStatusSummary.TotalApps)
+	}
+	if missing {
+		scopedLog.Info("App deployments are missing, rebuilding their status from the remote storage", "found", len(appDeployments), "expected", appDeployContext.AppsStatusSummary.TotalApps)
+
+		// check the remote storage right away, so that the missing apps are added back
+		appDeployContext.LastAppInfoCheckTime = 0
+	}
+
+	sort.Slice(appDeployments, func(i, j int) bool {
+		if appDeployments[i].Status.AppSourceName != appDeployments[j].Status.AppSourceName {
+			return appDeployments[i].Status.AppSourceName < appDeployments[j].Status.AppSourceName
+		}
+		return appDeployments[i].Status.AppName < appDeployments[j].Status.AppName
+	})
+
+	appsSrcDeployStatus := make(map[string]enterpriseApi.AppSrcDeployInfo)
+	for i := range appDeployments {
+		appSrcName := appDeployments[i].Status.AppSourceName
+		appSrcDeployInfo := appsSrcDeployStatus[appSrcName]
+		appSrcDeployInfo.AppDeploymentInfoList = append(appSrcDeployInfo.AppDeploymentInfoList, *appDeployments[i].Status.AppDeploymentInfo.DeepCopy())
+		appsSrcDeployStatus[appSrcName] = appSrcDeployInfo
+	}
+	appDeployContext.AppsSrcDeployStatus = appsSrcDeployStatus
+
+	scopedLog.Info("Loaded the app deployment status", "apps", len(appDeployments))
+	return nil
+}
+
+// publishAppDeployments writes the deployment status of the apps of a CR to its AppDeployments, and updates the summary
+// in the CR status. AppDeployments of the apps no longer tracked by the App Framework are deleted
+func publishAppDeployments(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("publishAppDeployments").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	appDeployContext := getAppDeploymentContext(cr)
+	if appDeployContext == nil || len(appDeployContext.AppsSrcDeployStatus) == 0 {
+		return nil
+	}
+
+	appDeployContext.AppsStatusSummary = getAppsStatusSummary(ctx, appDeployContext)
+
+	appDeployments, err := listAppDeployments(ctx, client, cr)
+	if err != nil {
+		return err
+	}
+	currentAppDeployments := make(map[string]*enterpriseApi.AppDeployment, len(appDeployments))
+	for i := range appDeployments {
+		currentAppDeployments[appDeployments[i].GetName()] = &appDeployments[i]
+	}
+
+	for appSrcName, appSrcDeployInfo := range appDeployContext.AppsSrcDeployStatus {
+		for i := range appSrcDeployInfo.AppDeploymentInfoList {
+			appDeployInfo := &appSrcDeployInfo.AppDeploymentInfoList[i]
+			name := getAppDeploymentName(cr, appSrcName, appDeployInfo.AppName)
+			labels := getAppDeploymentLabels(cr, appSrcName, appDeployInfo)
+			status := getAppDeploymentStatus(cr, appSrcName, appDeployInfo)
+
+			appDeployment, ok := currentAppDeployments[name]
+			if ok {
+				delete(currentAppDeployments, name)
+
+				status.LastUpdateTime = appDeployment.Status.LastUpdateTime
+				if reflect.DeepEqual(appDeployment.Status, status) && reflect.DeepEqual(appDeployment.GetLabels(), labels) {
+					continue
+				}
+
+				status.LastUpdateTime = time.Now().Unix()
+				appDeployment.Status = status
+				appDeployment.SetLabels(labels)
+				err = splutil.UpdateResource(ctx, client, appDeployment)
+			} else {
+				status.LastUpdateTime = time.Now().Unix()
+				appDeployment = &enterpriseApi.AppDeployment{}
+				appDeployment.SetName(name)
+				appDeployment.SetNamespace(cr.GetNamespace())
+				appDeployment.SetLabels(labels)
+				appDeployment.SetOwnerReferences([]metav1.OwnerReference{splcommon.AsOwner(cr, true)})
+				appDeployment.Status = status
+				err = splutil.CreateResource(ctx, client, appDeployment)
+			}
+			if err != nil {
+				scopedLog.Error(err, "unable to publish the app deployment", "app source", appSrcName, "app name", appDeployInfo.AppName)
+				return err
+			}
+		}
+	}
+
+	for _, appDeployment := range currentAppDeployments {
+		err = splutil.DeleteResource(ctx, client, appDeployment)
+		if err != nil {
+			scopedLog.Error(err, "unable to delete the app deployment", "app deployment", appDeployment.GetName())
+			return err
+		}
+	}
+
+	return nil
+}
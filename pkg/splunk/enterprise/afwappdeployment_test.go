@@ -0,0 +1,268 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getAppDeploymentTestCR() *enterpriseApi.Standalone {
+	cr := &enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Status.AppContext.AppFrameworkConfig.PhaseMaxRetries = 2
+	cr.Status.AppContext.AppsSrcDeployStatus = map[string]enterpriseApi.AppSrcDeployInfo{
+		"appSrc1": {
+			AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+				{
+					AppName:    "app1.tgz",
+					ObjectHash: "abcd1234",
+					RepoState:  enterpriseApi.RepoStateActive,
+					Version:    "1.0.0",
+					PhaseInfo:  enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallComplete},
+					AuxPhaseInfo: []enterpriseApi.PhaseInfo{
+						{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallComplete},
+						{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallComplete},
+					},
+				},
+				{
+					AppName:    "app 2.tgz",
+					ObjectHash: "efgh5678",
+					RepoState:  enterpriseApi.RepoStateActive,
+					LastError:  "unable to download app",
+					PhaseInfo:  enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseDownload, Status: enterpriseApi.AppPkgDownloadPending, FailCount: 3},
+				},
+			},
+		},
+		"appSrc2": {
+			AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+				{
+					AppName:    "app3.tgz",
+					ObjectHash: "ijkl9012",
+					RepoState:  enterpriseApi.RepoStateActive,
+					PhaseInfo:  enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhasePodCopy, Status: enterpriseApi.AppPkgPodCopyPending},
+				},
+				{
+					AppName:    "app4.tgz",
+					ObjectHash: "mnop3456",
+					RepoState:  enterpriseApi.RepoStateDeleted,
+					PhaseInfo:  enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallComplete},
+				},
+			},
+		},
+	}
+	return cr
+}
+
+func TestGetAppPhaseStatusName(t *testing.T) {
+	if getAppPhaseStatusName(enterpriseApi.AppPkgInstallComplete) != "AppPkgInstallComplete" {
+		t.Errorf("incorrect name for the install complete status")
+	}
+	if getAppPhaseStatusName(enterpriseApi.AppPkgPodCopyError) != "AppPkgPodCopyError" {
+		t.Errorf("incorrect name for the pod copy error status")
+	}
+	if getAppPhaseStatusName(0) != "" {
+		t.Errorf("unknown status should not have a name")
+	}
+}
+
+func TestGetAppDeploymentLabels(t *testing.T) {
+	cr := getAppDeploymentTestCR()
+
+	labels := getAppDeploymentLabels(cr, "appSrc1", &cr.Status.AppContext.AppsSrcDeployStatus["appSrc1"].AppDeploymentInfoList[0])
+	want := map[string]string{
+		enterpriseApi.AppDeploymentCRKindLabel:    "Standalone",
+		enterpriseApi.AppDeploymentCRNameLabel:    "stack1",
+		enterpriseApi.AppDeploymentAppSourceLabel: "appSrc1",
+		enterpriseApi.AppDeploymentAppNameLabel:   "app1.tgz",
+		enterpriseApi.AppDeploymentVersionLabel:   "1.0.0",
+	}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("incorrect labels. got: %v, want: %v", labels, want)
+	}
+
+	// invalid label values and unknown versions are left out
+	labels = getAppDeploymentLabels(cr, "appSrc1", &cr.Status.AppContext.AppsSrcDeployStatus["appSrc1"].AppDeploymentInfoList[1])
+	if _, ok := labels[enterpriseApi.AppDeploymentAppNameLabel]; ok {
+		t.Errorf("invalid app name should not be a label. got: %v", labels)
+	}
+	if _, ok := labels[enterpriseApi.AppDeploymentVersionLabel]; ok {
+		t.Errorf("unknown version should not be a label. got: %v", labels)
+	}
+}
+
+func TestGetAppDeploymentName(t *testing.T) {
+	cr := getAppDeploymentTestCR()
+
+	name := getAppDeploymentName(cr, "appSrc1", "app 2.tgz")
+	if !strings.HasPrefix(name, "standalone-stack1-") || len(name) != len("standalone-stack1-")+10 {
+		t.Errorf("incorrect app deployment name. got: %s", name)
+	}
+	if name == getAppDeploymentName(cr, "appSrc2", "app 2.tgz") {
+		t.Errorf("app deployments of the apps with the same name in different app sources should have different names")
+	}
+}
+
+func TestGetAppsStatusSummary(t *testing.T) {
+	cr := getAppDeploymentTestCR()
+
+	summary := getAppsStatusSummary(context.TODO(), &cr.Status.AppContext)
+	want := enterpriseApi.AppsStatusSummary{TotalApps: 4, InstalledApps: 1, PendingApps: 1, FailedApps: 1, DeletedApps: 1}
+	if summary != want {
+		t.Errorf("incorrect apps status summary. got: %v, want: %v", summary, want)
+	}
+}
+
+func TestPublishAppDeployments(t *testing.T) {
+	ctx := context.TODO()
+	cr := getAppDeploymentTestCR()
+	c := spltest.NewMockClient()
+	c.ListObj = &enterpriseApi.AppDeploymentList{}
+
+	err := publishAppDeployments(ctx, c, cr)
+	if err != nil {
+		t.Errorf("app deployments should be published. error: %v", err)
+	}
+	if len(c.Calls["Create"]) != 4 {
+		t.Errorf("an app deployment should be created for each app. got: %d", len(c.Calls["Create"]))
+	}
+	if cr.Status.AppContext.AppsStatusSummary.TotalApps != 4 {
+		t.Errorf("apps status summary should be updated. got: %v", cr.Status.AppContext.AppsStatusSummary)
+	}
+
+	var appDeployment *enterpriseApi.AppDeployment
+	for _, call := range c.Calls["Create"] {
+		if call.Obj.GetName() == getAppDeploymentName(cr, "appSrc1", "app1.tgz") {
+			appDeployment = call.Obj.(*enterpriseApi.AppDeployment)
+		}
+	}
+	if appDeployment == nil {
+		t.Fatalf("app deployment of app1.tgz should be created")
+	}
+	if appDeployment.Status.StatusName != "AppPkgInstallComplete" || appDeployment.Status.ObjectHash != "abcd1234" || appDeployment.Status.Version != "1.0.0" {
+		t.Errorf("incorrect app deployment status. got: %v", appDeployment.Status)
+	}
+	if len(appDeployment.Status.Pods) != 2 || appDeployment.Status.Pods[1].PodName != "splunk-stack1-standalone-1" {
+		t.Errorf("app deployment should have the phase of each pod. got: %v", appDeployment.Status.Pods)
+	}
+	if len(appDeployment.GetOwnerReferences()) != 1 || appDeployment.GetOwnerReferences()[0].Name != "stack1" {
+		t.Errorf("app deployment should be owned by the CR. got: %v", appDeployment.GetOwnerReferences())
+	}
+
+	// unchanged app deployments are not updated, and the ones of the apps no longer tracked are deleted
+	list := &enterpriseApi.AppDeploymentList{}
+	for _, call := range c.Calls["Create"] {
+		list.Items = append(list.Items, *call.Obj.(*enterpriseApi.AppDeployment))
+	}
+	list.Items = append(list.Items, enterpriseApi.AppDeployment{ObjectMeta: metav1.ObjectMeta{Name: "standalone-stack1-stale", Namespace: "test"}})
+	c.ListObj = list
+	c.ResetCalls()
+
+	appSrcDeployInfo := cr.Status.AppContext.AppsSrcDeployStatus["appSrc2"]
+	appSrcDeployInfo.AppDeploymentInfoList[0].PhaseInfo.Status = enterpriseApi.AppPkgPodCopyComplete
+	err = publishAppDeployments(ctx, c, cr)
+	if err != nil {
+		t.Errorf("app deployments should be published. error: %v", err)
+	}
+	if len(c.Calls["Update"]) != 1 || len(c.Calls["Create"]) != 0 {
+		t.Errorf("only the changed app deployment should be updated. got: %v", c.Calls)
+	}
+	if len(c.Calls["Delete"]) != 1 || c.Calls["Delete"][0].Obj.GetName() != "standalone-stack1-stale" {
+		t.Errorf("stale app deployment should be deleted. got: %v", c.Calls["Delete"])
+	}
+
+	// failure to list the app deployments is returned
+	c.InduceErrorKind[splcommon.MockClientInduceErrorList] = errors.New("list failed")
+	err = publishAppDeployments(ctx, c, cr)
+	if err == nil {
+		t.Errorf("app deployments list failure should be returned")
+	}
+}
+
+func TestLoadAppDeployStatusFromAppDeployments(t *testing.T) {
+	ctx := context.TODO()
+	cr := getAppDeploymentTestCR()
+	c := spltest.NewMockClient()
+	c.ListObj = &enterpriseApi.AppDeploymentList{}
+
+	err := publishAppDeployments(ctx, c, cr)
+	if err != nil {
+		t.Errorf("app deployments should be published. error: %v", err)
+	}
+	list := &enterpriseApi.AppDeploymentList{}
+	for _, call := range c.Calls["Create"] {
+		list.Items = append(list.Items, *call.Obj.(*enterpriseApi.AppDeployment))
+	}
+
+	// status is loaded only when it is missing from the CR
+	appDeployContext := enterpriseApi.AppDeploymentContext{AppsStatusSummary: cr.Status.AppContext.AppsStatusSummary}
+	c.ListObj = &enterpriseApi.AppDeploymentList{Items: list.Items[:3]}
+	err = loadAppDeployStatusFromAppDeployments(ctx, c, cr, &appDeployContext)
+	if err == nil || len(appDeployContext.AppsSrcDeployStatus) != 0 {
+		t.Errorf("app deployments missing from the cache should fail the load")
+	}
+
+	// app deployments still missing after the cache sync timeout are rebuilt
+	defer func(timeout time.Duration) { appDeploymentCacheSyncTimeout = timeout }(appDeploymentCacheSyncTimeout)
+	appDeploymentCacheSyncTimeout = 0
+	appDeployContext.LastAppInfoCheckTime = time.Now().Unix()
+	err = loadAppDeployStatusFromAppDeployments(ctx, c, cr, &appDeployContext)
+	if err != nil {
+		t.Errorf("app deployments found should be loaded, when the rest are deleted. error: %v", err)
+	}
+	var loadedApps int
+	for _, appSrcDeployInfo := range appDeployContext.AppsSrcDeployStatus {
+		loadedApps += len(appSrcDeployInfo.AppDeploymentInfoList)
+	}
+	if loadedApps != 3 || appDeployContext.LastAppInfoCheckTime != 0 {
+		t.Errorf("missing apps should be checked again on the remote storage. loaded apps: %d, LastAppInfoCheckTime: %d", loadedApps, appDeployContext.LastAppInfoCheckTime)
+	}
+	appDeployContext.AppsSrcDeployStatus = nil
+
+	c.ListObj = list
+	err = loadAppDeployStatusFromAppDeployments(ctx, c, cr, &appDeployContext)
+	if err != nil {
+		t.Errorf("app deployment status should be loaded. error: %v", err)
+	}
+	if !reflect.DeepEqual(appDeployContext.AppsSrcDeployStatus["appSrc2"], cr.Status.AppContext.AppsSrcDeployStatus["appSrc2"]) {
+		t.Errorf("incorrect app deployment status. got: %v, want: %v", appDeployContext.AppsSrcDeployStatus["appSrc2"], cr.Status.AppContext.AppsSrcDeployStatus["appSrc2"])
+	}
+	if len(appDeployContext.AppsSrcDeployStatus["appSrc1"].AppDeploymentInfoList) != 2 {
+		t.Errorf("all the apps of the app source should be loaded. got: %v", appDeployContext.AppsSrcDeployStatus["appSrc1"])
+	}
+
+	c.ResetCalls()
+	err = loadAppDeployStatusFromAppDeployments(ctx, c, cr, &appDeployContext)
+	if err != nil || len(c.Calls["List"]) != 0 {
+		t.Errorf("app deployment status should not be loaded again, when the CR status has it")
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// An App source of a CR can refer to a shared AppSource, instead of repeating the volume and the location. The reference
+// is resolved on every reconcile, by adding the volume of the AppSource to the volume list of the CR under a reserved
+// name. So, a change to the AppSource shows up as an App Framework config change for all the referring CRs.
+// The remote storage listing of an AppSource is cached, and shared by all the referring CRs.
+
+// sharedAppSourceListing is the cached remote storage listing of an AppSource
+type sharedAppSourceListing struct {
+	// generation of the AppSource listed
+	generation int64
+
+	// listTime is the time of the listing
+	listTime time.Time
+
+	response splclient.RemoteDataListResponse
+}
+
+// sharedAppSourceListings caches the remote storage listing of each AppSource
+var sharedAppSourceListings = struct {
+	mutex sync.Mutex
+
+	// listMutexes make sure that an AppSource is listed only once at a time
+	listMutexes map[string]*sync.Mutex
+
+	listings map[string]*sharedAppSourceListing
+}{
+	listMutexes: make(map[string]*sync.Mutex),
+	listings:    make(map[string]*sharedAppSourceListing),
+}
+
+// getSharedAppSourceKey returns the key of the AppSource in the listing cache
+func getSharedAppSourceKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// getSharedAppSourceVolName returns the name of the volume added to the CR for the AppSource
+func getSharedAppSourceVolName(namespace, name string) string {
+	// slash is not allowed in the object names, so, the name doesn't collide with the volumes of the CR
+	return "appsource:" + getSharedAppSourceKey(namespace, name)
+}
+
+// getAppSourceRefNamespace returns the namespace of the AppSource referred by an App source of the CR
+func getAppSourceRefNamespace(crNamespace string, ref *enterpriseApi.AppSourceReference) string {
+	if ref.Namespace != "" {
+		return ref.Namespace
+	}
+	return crNamespace
+}
+
+// isNamespaceAllowedForAppSource checks if the CRs of the namespace can refer to the AppSource
+func isNamespaceAllowedForAppSource(appSource *enterpriseApi.AppSource, namespace string) bool {
+	if appSource.GetNamespace() == namespace {
+		return true
+	}
+
+	for _, allowedNamespace := range appSource.Spec.AllowedNamespaces {
+		if allowedNamespace == enterpriseApi.AppSourceAllNamespaces || allowedNamespace == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getSharedAppSource gets the AppSource referred by an App source of the CR
+func getSharedAppSource(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, ref *enterpriseApi.AppSourceReference) (*enterpriseApi.AppSource, error) {
+	namespacedName := types.NamespacedName{Name: ref.Name, Namespace: getAppSourceRefNamespace(cr.GetNamespace(), ref)}
+
+	appSource := &enterpriseApi.AppSource{}
+	err := client.Get(ctx, namespacedName, appSource)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get AppSource %s. %s", namespacedName, err)
+	}
+
+	if !isNamespaceAllowedForAppSource(appSource, cr.GetNamespace()) {
+		return nil, fmt.Errorf("AppSource %s doesn't allow references from namespace %s", namespacedName, cr.GetNamespace())
+	}
+
+	appSource.Kind = "AppSource"
+	return appSource, nil
+}
+
+// resolveAppSourceRefs returns a copy of the App Framework config, with the volume, location and defaults
+// of the App sources referring to a shared AppSource filled in. The given config is left untouched.
+func resolveAppSourceRefs(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, crAppFrameworkConf *enterpriseApi.AppFrameworkSpec) (*enterpriseApi.AppFrameworkSpec, error) {
+	appFrameworkConf := crAppFrameworkConf.DeepCopy()
+	for i := range appFrameworkConf.AppSources {
+		appSrc := &appFrameworkConf.AppSources[i]
+		if appSrc.AppSourceRef == nil {
+			continue
+		}
+
+		appSource, err := getSharedAppSource(ctx, client, cr, appSrc.AppSourceRef)
+		if err != nil {
+			return nil, fmt.Errorf("invalid appSourceRef for App Source: %s. %s", appSrc.Name, err)
+		}
+
+		vol := appSource.Spec.Volume
+		vol.Name = getSharedAppSourceVolName(appSource.GetNamespace(), appSource.GetName())
+
+		appSrc.Location = appSource.Spec.Location
+		appSrc.VolName = vol.Name
+		if appSrc.Scope == "" {
+			appSrc.Scope = appSource.Spec.Scope
+		}
+		if reflect.DeepEqual(appSrc.PremiumAppsProps, enterpriseApi.PremiumAppsProps{}) {
+			appSrc.PremiumAppsProps = appSource.Spec.PremiumAppsProps
+		}
+
+		index, err := splclient.CheckIfVolumeExists(appFrameworkConf.VolList, vol.Name)
+		if err == nil {
+			appFrameworkConf.VolList[index] = vol
+		} else {
+			appFrameworkConf.VolList = append(appFrameworkConf.VolList, vol)
+		}
+	}
+
+	return appFrameworkConf, nil
+}
+
+// getSharedAppSourceForVolume returns the AppSource the volume was added for, if any
+func getSharedAppSourceForVolume(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appFrameworkConf *enterpriseApi.AppFrameworkSpec, vol *enterpriseApi.VolumeSpec) (*enterpriseApi.AppSource, error) {
+	if appFrameworkConf == nil {
+		return nil, nil
+	}
+
+	for _, appSrc := range appFrameworkConf.AppSources {
+		if appSrc.AppSourceRef != nil && appSrc.VolName == vol.Name {
+			return getSharedAppSource(ctx, client, cr, appSrc.AppSourceRef)
+		}
+	}
+
+	return nil, nil
+}
+
+// getSharedAppSourceAppFrameworkSpec returns the App Framework config used to list the AppSource
+func getSharedAppSourceAppFrameworkSpec(appSource *enterpriseApi.AppSource) *enterpriseApi.AppFrameworkSpec {
+	return &enterpriseApi.AppFrameworkSpec{
+		VolList: []enterpriseApi.VolumeSpec{appSource.Spec.Volume},
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{
+				Name:     appSource.GetName(),
+				Location: appSource.Spec.Location,
+				AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{
+					VolName: appSource.Spec.Volume.Name,
+					Scope:   appSource.Spec.Scope,
+				},
+			},
+		},
+	}
+}
+
+// getSharedAppSourceListingTTL returns how long the listing of the AppSource is used by the referring CRs
+func getSharedAppSourceListingTTL(appSource *enterpriseApi.AppSource) time.Duration {
+	pollInterval := appSource.Spec.AppsRepoPollInterval
+	if pollInterval < splcommon.MinAppsRepoPollInterval {
+		pollInterval = splcommon.MinAppsRepoPollInterval
+	} else if pollInterval > splcommon.MaxAppsRepoPollInterval {
+		pollInterval = splcommon.MaxAppsRepoPollInterval
+	}
+	return time.Duration(pollInterval) * time.Second
+}
+
+// getSharedAppSourceListMutex returns the mutex serializing the listings of the AppSource
+func getSharedAppSourceListMutex(key string) *sync.Mutex {
+	sharedAppSourceListings.mutex.Lock()
+	defer sharedAppSourceListings.mutex.Unlock()
+
+	listMutex, ok := sharedAppSourceListings.listMutexes[key]
+	if !ok {
+		listMutex = &sync.Mutex{}
+		sharedAppSourceListings.listMutexes[key] = listMutex
+	}
+	return listMutex
+}
+
+// copyRemoteDataListResponse copies the listing, so that the digest clean up by a CR doesn't change the cached listing
+func copyRemoteDataListResponse(response splclient.RemoteDataListResponse) splclient.RemoteDataListResponse {
+	copied := splclient.RemoteDataListResponse{Objects: make([]*splclient.RemoteObject, 0, len(response.Objects))}
+	for _, object := range response.Objects {
+		copiedObject := *object
+		copied.Objects = append(copied.Objects, &copiedObject)
+	}
+	return copied
+}
+
+// getCachedSharedAppSourceAppsList returns the cached listing of the AppSource, if it is still fresh
+func getCachedSharedAppSourceAppsList(appSource *enterpriseApi.AppSource) (splclient.RemoteDataListResponse, bool) {
+	sharedAppSourceListings.mutex.Lock()
+	defer sharedAppSourceListings.mutex.Unlock()
+
+	listing, ok := sharedAppSourceListings.listings[getSharedAppSourceKey(appSource.GetNamespace(), appSource.GetName())]
+	if !ok || listing.generation != appSource.GetGeneration() || time.Since(listing.listTime) >= getSharedAppSourceListingTTL(appSource) {
+		return splclient.RemoteDataListResponse{}, false
+	}
+
+	return copyRemoteDataListResponse(listing.response), true
+}
+
+// invalidateSharedAppSourceAppsList drops the cached listing of the AppSource
+func invalidateSharedAppSourceAppsList(namespace, name string) {
+	sharedAppSourceListings.mutex.Lock()
+	defer sharedAppSourceListings.mutex.Unlock()
+
+	delete(sharedAppSourceListings.listings, getSharedAppSourceKey(namespace, name))
+}
+
+// DeleteAppSource drops the cached listing of a deleted AppSource, and notifies the referring CRs
+func DeleteAppSource(ctx context.Context, client splcommon.ControllerClient, namespace, name string) error {
+	sharedAppSourceListings.mutex.Lock()
+	delete(sharedAppSourceListings.listings, getSharedAppSourceKey(namespace, name))
+	delete(sharedAppSourceListings.listMutexes, getSharedAppSourceKey(namespace, name))
+	sharedAppSourceListings.mutex.Unlock()
+
+	return notifyAppSourceReferrers(ctx, client, namespace, name)
+}
+
+// listSharedAppSource lists the AppSource on the remote storage, and caches the listing
+func listSharedAppSource(ctx context.Context, client splcommon.ControllerClient, appSource *enterpriseApi.AppSource) (splclient.RemoteDataListResponse, error) {
+	appFrameworkConf := getSharedAppSourceAppFrameworkSpec(appSource)
+	vol := appSource.Spec.Volume
+
+	remoteDataClientWrapper := splclient.RemoteDataClientsMap[vol.Provider]
+	initFunc := remoteDataClientWrapper.GetRemoteDataClientInitFuncPtr(ctx)
+	remoteDataClientMgr := RemoteDataClientManager{
+		client:              client,
+		cr:                  appSource,
+		appFrameworkRef:     appFrameworkConf,
+		vol:                 &vol,
+		location:            appSource.Spec.Location,
+		initFn:              initFunc,
+		getRemoteDataClient: GetRemoteStorageClient,
+	}
+
+	response, err := GetAppsList(ctx, remoteDataClientMgr)
+	if err != nil {
+		return response, err
+	}
+
+	sharedAppSourceListings.mutex.Lock()
+	defer sharedAppSourceListings.mutex.Unlock()
+
+	sharedAppSourceListings.listings[getSharedAppSourceKey(appSource.GetNamespace(), appSource.GetName())] = &sharedAppSourceListing{
+		generation: appSource.GetGeneration(),
+		listTime:   time.Now(),
+		response:   copyRemoteDataListResponse(response),
+	}
+
+	return response, nil
+}
+
+// getSharedAppSourceAppsList returns the listing of the AppSource, listing the remote storage only if the cached listing is stale
+func getSharedAppSourceAppsList(ctx context.Context, client splcommon.ControllerClient, appSource *enterpriseApi.AppSource) (splclient.RemoteDataListResponse, error) {
+	listMutex := getSharedAppSourceListMutex(getSharedAppSourceKey(appSource.GetNamespace(), appSource.GetName()))
+	listMutex.Lock()
+	defer listMutex.Unlock()
+
+	if response, ok := getCachedSharedAppSourceAppsList(appSource); ok {
+		return response, nil
+	}
+
+	return listSharedAppSource(ctx, client, appSource)
+}
+
+// getAppSourceListingHash returns the hash of the remote storage listing
+func getAppSourceListingHash(response splclient.RemoteDataListResponse) string {
+	entries := make([]string, 0, len(response.Objects))
+	for _, object := range response.Objects {
+		var key, etag string
+		if object.Key != nil {
+			key = *object.Key
+		}
+		if object.Etag != nil {
+			etag = *object.Etag
+		}
+		entries = append(entries, key+"\x00"+etag)
+	}
+	sort.Strings(entries)
+
+	hash := sha256.New()
+	for _, entry := range entries {
+		hash.Write([]byte(entry + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// validateAppSourceSpec validates the AppSource spec
+func validateAppSourceSpec(ctx context.Context, cr *enterpriseApi.AppSource) error {
+	err := validateRemoteVolumeSpec(ctx, []enterpriseApi.VolumeSpec{cr.Spec.Volume}, true)
+	if err != nil {
+		return err
+	}
+
+	if cr.Spec.Location == "" {
+		return fmt.Errorf("location is missing for AppSource: %s", cr.GetName())
+	}
+
+	if cr.Spec.Scope != "" && !isAppSourceScopeValid(cr.Spec.Scope) {
+		return fmt.Errorf("scope for AppSource: %s should be either %s or %s or %s or %s", cr.GetName(), enterpriseApi.ScopeLocal, enterpriseApi.ScopeCluster,
+			enterpriseApi.ScopeClusterWithPreConfig, enterpriseApi.ScopePremiumApps)
+	}
+
+	return nil
+}
+
+// notifyAppSourceReferrers marks the App sources referring to the AppSource dirty, and requeues their CRs
+func notifyAppSourceReferrers(ctx context.Context, client splcommon.ControllerClient, namespace, name string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("notifyAppSourceReferrers").WithValues("name", name, "namespace", namespace)
+
+	crs, err := listAppFrameworkCRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	for _, afwCR := range crs {
+		var appSrcNames []string
+		for _, appSrc := range afwCR.appFramework.AppSources {
+			if appSrc.AppSourceRef != nil && appSrc.AppSourceRef.Name == name && getAppSourceRefNamespace(afwCR.cr.GetNamespace(), appSrc.AppSourceRef) == namespace {
+				appSrcNames = append(appSrcNames, appSrc.Name)
+			}
+		}
+
+		if len(appSrcNames) == 0 {
+			continue
+		}
+
+		scopedLog.Info("AppSource changed", "kind", afwCR.kind, "crName", afwCR.cr.GetName(), "crNamespace", afwCR.cr.GetNamespace(), "appSources", appSrcNames)
+		markAppSourcesDirty(afwCR.kind, afwCR.cr.GetNamespace(), afwCR.cr.GetName(), appSrcNames)
+
+		select {
+		case GetAppSourceChangeEvents(afwCR.kind) <- event.GenericEvent{Object: afwCR.cr}:
+		default:
+			// App sources stay dirty, so, they are checked in the next reconcile of the CR
+			scopedLog.Info("Requeue events are backed up. Skipping the requeue", "kind", afwCR.kind, "crName", afwCR.cr.GetName())
+		}
+	}
+
+	return nil
+}
+
+// ApplyAppSource lists the shared AppSource on the remote storage, and notifies the referring CRs of the changes
+func ApplyAppSource(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.AppSource) (reconcile.Result, error) {
+	result := reconcile.Result{}
+
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("ApplyAppSource").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	cr.Kind = "AppSource"
+
+	if cr.Spec.AppsRepoPollInterval > 0 {
+		result.Requeue = true
+		result.RequeueAfter = getSharedAppSourceListingTTL(cr)
+	}
+
+	oldStatus := cr.Status.DeepCopy()
+
+	err := validateAppSourceSpec(ctx, cr)
+	if err == nil {
+		var response splclient.RemoteDataListResponse
+		listMutex := getSharedAppSourceListMutex(getSharedAppSourceKey(cr.GetNamespace(), cr.GetName()))
+		listMutex.Lock()
+		response, err = listSharedAppSource(ctx, client, cr)
+		listMutex.Unlock()
+
+		if err == nil {
+			listingHash := getAppSourceListingHash(response)
+			changed := cr.Status.ListingHash != listingHash || cr.Status.ObservedGeneration != cr.GetGeneration()
+
+			cr.Status.Phase = enterpriseApi.PhaseReady
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+			cr.Status.LastListTime = time.Now().Unix()
+			cr.Status.ListingHash = listingHash
+			cr.Status.AppCount = len(response.Objects)
+			cr.Status.Message = ""
+
+			if changed {
+				scopedLog.Info("AppSource changed. Notifying the referring CRs", "apps", len(response.Objects))
+				err = notifyAppSourceReferrers(ctx, client, cr.GetNamespace(), cr.GetName())
+			}
+		}
+	}
+
+	if err != nil {
+		scopedLog.Error(err, "Unable to list the AppSource")
+		cr.Status.Phase = enterpriseApi.PhaseError
+		cr.Status.Message = err.Error()
+		// listing is retried along with the next poll, or on the next change to the AppSource
+	}
+
+	if !reflect.DeepEqual(*oldStatus, cr.Status) {
+		updateErr := client.Status().Update(ctx, cr)
+		if updateErr != nil {
+			scopedLog.Error(updateErr, "status update failed")
+			return result, updateErr
+		}
+	}
+
+	return result, err
+}
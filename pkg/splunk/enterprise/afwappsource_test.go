@@ -0,0 +1,353 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	enterpriseApiV3 "github.com/splunk/splunk-operator/api/v3"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getSharedAppSourceTestClient(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(enterpriseApi.AddToScheme(scheme))
+	utilruntime.Must(enterpriseApiV3.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func getSharedAppSourceTestAppSource() *enterpriseApi.AppSource {
+	return &enterpriseApi.AppSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "security-apps",
+			Namespace:  "shared",
+			Generation: 1,
+		},
+		Spec: enterpriseApi.SharedAppSourceSpec{
+			Volume: enterpriseApi.VolumeSpec{
+				Name:      "vol1",
+				Endpoint:  "https://s3-us-west-2.amazonaws.com",
+				Path:      "bucket1/apps",
+				SecretRef: "s3-secret",
+				Type:      "s3",
+				Provider:  "aws",
+			},
+			Location:          "securityApps",
+			Scope:             enterpriseApi.ScopeLocal,
+			AllowedNamespaces: []string{"test"},
+		},
+	}
+}
+
+func getSharedAppSourceTestStandalone(namespace string) *enterpriseApi.Standalone {
+	return &enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: namespace,
+		},
+		Spec: enterpriseApi.StandaloneSpec{
+			AppFrameworkConfig: enterpriseApi.AppFrameworkSpec{
+				AppSources: []enterpriseApi.AppSourceSpec{
+					{
+						Name:         "securityApps",
+						AppSourceRef: &enterpriseApi.AppSourceReference{Name: "security-apps", Namespace: "shared"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestResolveAppSourceRefs(t *testing.T) {
+	ctx := context.TODO()
+	appSource := getSharedAppSourceTestAppSource()
+	c := getSharedAppSourceTestClient(appSource)
+
+	cr := getSharedAppSourceTestStandalone("test")
+	crAppFrameworkConf := cr.Spec.AppFrameworkConfig.DeepCopy()
+	appFrameworkConf, err := resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err != nil {
+		t.Fatalf("resolveAppSourceRefs should not have returned error: %v", err)
+	}
+
+	// Spec of the CR is left untouched
+	if !reflect.DeepEqual(cr.Spec.AppFrameworkConfig, *crAppFrameworkConf) {
+		t.Errorf("resolveAppSourceRefs should not have changed the App Framework config of the CR, got: %v", cr.Spec.AppFrameworkConfig)
+	}
+
+	volName := getSharedAppSourceVolName("shared", "security-apps")
+	appSrc := appFrameworkConf.AppSources[0]
+	if appSrc.Location != "securityApps" || appSrc.VolName != volName || appSrc.Scope != enterpriseApi.ScopeLocal {
+		t.Errorf("App source should have taken the location, volume and scope of the AppSource, got: %v", appSrc)
+	}
+	if len(appFrameworkConf.VolList) != 1 || appFrameworkConf.VolList[0].Name != volName || appFrameworkConf.VolList[0].Path != "bucket1/apps" {
+		t.Errorf("Volume of the AppSource should have been added, got: %v", appFrameworkConf.VolList)
+	}
+
+	// Resolving again doesn't add the volume again
+	resolvedAgain, err := resolveAppSourceRefs(ctx, c, cr, appFrameworkConf)
+	if err != nil || !reflect.DeepEqual(resolvedAgain, appFrameworkConf) {
+		t.Errorf("Resolving the references again should not have changed the config, got: %v %v", err, resolvedAgain.VolList)
+	}
+
+	// Scope of the App source takes precedence
+	cr = getSharedAppSourceTestStandalone("test")
+	cr.Spec.AppFrameworkConfig.AppSources[0].Scope = enterpriseApi.ScopePremiumApps
+	appFrameworkConf, _ = resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if appFrameworkConf.AppSources[0].Scope != enterpriseApi.ScopePremiumApps {
+		t.Errorf("Scope of the App source should not have been changed")
+	}
+
+	// Namespace not allowed to refer to the AppSource
+	cr = getSharedAppSourceTestStandalone("other")
+	_, err = resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err == nil {
+		t.Errorf("Reference from a namespace not allowed should have returned error")
+	}
+
+	appSource.Spec.AllowedNamespaces = []string{enterpriseApi.AppSourceAllNamespaces}
+	c = getSharedAppSourceTestClient(appSource)
+	_, err = resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err != nil {
+		t.Errorf("All the namespaces should have been allowed to refer to the AppSource: %v", err)
+	}
+
+	// Missing AppSource
+	cr = getSharedAppSourceTestStandalone("test")
+	cr.Spec.AppFrameworkConfig.AppSources[0].AppSourceRef.Name = "missing"
+	_, err = resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err == nil {
+		t.Errorf("Reference to a missing AppSource should have returned error")
+	}
+}
+
+func TestGetSharedAppSourceAppsList(t *testing.T) {
+	ctx := context.TODO()
+	appSource := getSharedAppSourceTestAppSource()
+	c := getSharedAppSourceTestClient(appSource)
+	defer invalidateSharedAppSourceAppsList(appSource.GetNamespace(), appSource.GetName())
+
+	savedGetAppsList := GetAppsList
+	defer func() { GetAppsList = savedGetAppsList }()
+
+	var listCount int
+	GetAppsList = func(ctx context.Context, remoteDataClientMgr RemoteDataClientManager) (splclient.RemoteDataListResponse, error) {
+		listCount++
+		if remoteDataClientMgr.cr.GetNamespace() != "shared" || remoteDataClientMgr.location != "securityApps" {
+			t.Errorf("AppSource should have been listed in its own namespace, got: %s %s", remoteDataClientMgr.cr.GetNamespace(), remoteDataClientMgr.location)
+		}
+		return getDryRunTestAppsList("securityApps", map[string]string{"app1.tgz": "\"abcd1111\""}), nil
+	}
+
+	// Listing of the AppSource is shared by all the referring CRs
+	for _, namespace := range []string{"test", "test", "shared"} {
+		cr := getSharedAppSourceTestStandalone(namespace)
+		appFrameworkConf, _ := resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+		sourceToAppsList, err := GetAppListFromRemoteBucket(ctx, c, cr, appFrameworkConf)
+		if err != nil || len(sourceToAppsList["securityApps"].Objects) != 1 {
+			t.Fatalf("Unable to get the apps list of the shared AppSource: %v", err)
+		}
+
+		// clean up of the digests doesn't change the cached listing
+		cleanAppSrcObjectDigests(ctx, "securityApps", sourceToAppsList["securityApps"])
+	}
+	if listCount != 1 {
+		t.Errorf("AppSource should have been listed only once, got %d listings", listCount)
+	}
+
+	response, _ := getSharedAppSourceAppsList(ctx, c, appSource)
+	if *response.Objects[0].Etag != "\"abcd1111\"" {
+		t.Errorf("Cached listing should not have been changed, got: %s", *response.Objects[0].Etag)
+	}
+
+	// AppSource change lists it again
+	appSource.Generation = 2
+	getSharedAppSourceAppsList(ctx, c, appSource)
+	if listCount != 2 {
+		t.Errorf("AppSource should have been listed again for the change, got %d listings", listCount)
+	}
+
+	// Dropped listing is listed again
+	invalidateSharedAppSourceAppsList(appSource.GetNamespace(), appSource.GetName())
+	getSharedAppSourceAppsList(ctx, c, appSource)
+	if listCount != 3 {
+		t.Errorf("AppSource should have been listed again after dropping the listing, got %d listings", listCount)
+	}
+}
+
+func TestGetRemoteStorageClientSharedAppSource(t *testing.T) {
+	ctx := context.TODO()
+	appSource := getSharedAppSourceTestAppSource()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s3-secret",
+			Namespace: "shared",
+		},
+		Data: map[string][]byte{
+			"s3_access_key": []byte("sharedAccessKey"),
+			"s3_secret_key": []byte("sharedSecretKey"),
+		},
+	}
+	c := getSharedAppSourceTestClient(appSource, secret)
+
+	splclient.RegisterRemoteDataClient(ctx, "aws")
+	getClientWrapper := splclient.RemoteDataClientsMap["aws"]
+	getClientWrapper.SetRemoteDataClientFuncPtr(ctx, "aws", splclient.NewMockAWSS3Client)
+	fn := func(ctx context.Context, region, accessKeyID, secretAccessKey string) interface{} {
+		return spltest.MockAWSS3Client{}
+	}
+
+	cr := getSharedAppSourceTestStandalone("test")
+	appFrameworkConf, _ := resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+
+	// Volume of the CR can't point the AppSource secret to another endpoint
+	vol := appFrameworkConf.VolList[0]
+	vol.Endpoint = "https://example.com"
+	remoteDataClient, err := GetRemoteStorageClient(ctx, c, cr, appFrameworkConf, &vol, "securityApps", fn)
+	if err != nil {
+		t.Fatalf("GetRemoteStorageClient should not have returned error: %v", err)
+	}
+
+	s3Client := remoteDataClient.Client.(*splclient.AWSS3Client)
+	if s3Client.AWSAccessKeyID != "sharedAccessKey" || !strings.HasSuffix(s3Client.Region, "https://s3-us-west-2.amazonaws.com") {
+		t.Errorf("Secret and endpoint of the shared AppSource should have been used, got: %s %s", s3Client.AWSAccessKeyID, s3Client.Region)
+	}
+
+	// Namespace not allowed to refer to the AppSource can't use the secret
+	cr.Namespace = "other"
+	_, err = GetRemoteStorageClient(ctx, c, cr, appFrameworkConf, &vol, "securityApps", fn)
+	if err == nil {
+		t.Errorf("Namespace not allowed should not have been able to use the AppSource secret")
+	}
+}
+
+func TestApplyAppSource(t *testing.T) {
+	ctx := context.TODO()
+	appSource := getSharedAppSourceTestAppSource()
+	appSource.Spec.AppsRepoPollInterval = 120
+	referrer := getSharedAppSourceTestStandalone("test")
+	other := getAppSourceNotificationTestStandalone("s3", enterpriseApi.VolumeSpec{Name: "vol1", Path: "bucket1/apps", Provider: "aws", Endpoint: "https://s3-us-west-2.amazonaws.com"})
+	c := getSharedAppSourceTestClient(appSource, referrer, other)
+	defer invalidateSharedAppSourceAppsList(appSource.GetNamespace(), appSource.GetName())
+	defer clearDirtyAppSources(referrer, getDirtyAppSources(referrer))
+
+	savedGetAppsList := GetAppsList
+	defer func() { GetAppsList = savedGetAppsList }()
+
+	apps := map[string]string{"app1.tgz": "abcd1111"}
+	var listCount int
+	GetAppsList = func(ctx context.Context, remoteDataClientMgr RemoteDataClientManager) (splclient.RemoteDataListResponse, error) {
+		listCount++
+		return getDryRunTestAppsList("securityApps", apps), nil
+	}
+
+	drainAppSourceChangeEvents("Standalone")
+	result, err := ApplyAppSource(ctx, c, appSource)
+	if err != nil {
+		t.Fatalf("ApplyAppSource should not have returned error: %v", err)
+	}
+	if !result.Requeue || result.RequeueAfter.Seconds() != 120 {
+		t.Errorf("AppSource should have been requeued for the poll interval, got: %v", result)
+	}
+	if appSource.Status.Phase != enterpriseApi.PhaseReady || appSource.Status.AppCount != 1 || appSource.Status.ListingHash == "" {
+		t.Errorf("AppSource status should have been updated, got: %v", appSource.Status)
+	}
+
+	// Only the referring CR is notified
+	if _, ok := getDirtyAppSources(referrer)["securityApps"]; !ok {
+		t.Errorf("App source of the referring CR should have been marked dirty")
+	}
+	if len(getDirtyAppSources(other)) != 0 {
+		t.Errorf("CR not referring to the AppSource should not have been notified")
+	}
+	if len(drainAppSourceChangeEvents("Standalone")) != 1 {
+		t.Errorf("Referring CR should have been requeued")
+	}
+
+	// Referring CRs use the listing of the AppSource
+	appFrameworkConf, _ := resolveAppSourceRefs(ctx, c, referrer, &referrer.Spec.AppFrameworkConfig)
+	GetAppListFromRemoteBucket(ctx, c, referrer, appFrameworkConf)
+	if listCount != 1 {
+		t.Errorf("Referring CR should have used the listing of the AppSource, got %d listings", listCount)
+	}
+
+	// No change in the listing, no notification
+	clearDirtyAppSources(referrer, getDirtyAppSources(referrer))
+	ApplyAppSource(ctx, c, appSource)
+	if len(getDirtyAppSources(referrer)) != 0 || len(drainAppSourceChangeEvents("Standalone")) != 0 {
+		t.Errorf("Referring CR should not have been notified without a change")
+	}
+
+	// App change is notified
+	apps["app2.tgz"] = "abcd2222"
+	ApplyAppSource(ctx, c, appSource)
+	if _, ok := getDirtyAppSources(referrer)["securityApps"]; !ok || appSource.Status.AppCount != 2 {
+		t.Errorf("App change should have been notified to the referring CR")
+	}
+
+	// Invalid AppSource
+	appSource.Spec.Location = ""
+	_, err = ApplyAppSource(ctx, c, appSource)
+	if err == nil || appSource.Status.Phase != enterpriseApi.PhaseError {
+		t.Errorf("Invalid AppSource should have returned error")
+	}
+}
+
+func TestHandleNotificationsSharedAppSource(t *testing.T) {
+	ctx := context.TODO()
+	appSource := getSharedAppSourceTestAppSource()
+	referrer := getSharedAppSourceTestStandalone("test")
+	c := getSharedAppSourceTestClient(appSource, referrer)
+	defer clearDirtyAppSources(referrer, getDirtyAppSources(referrer))
+
+	sharedAppSourceListings.mutex.Lock()
+	sharedAppSourceListings.listings[getSharedAppSourceKey("shared", "security-apps")] = &sharedAppSourceListing{generation: 1}
+	sharedAppSourceListings.mutex.Unlock()
+
+	receiver := NewAppSourceNotificationReceiver(c, ":0", "")
+	drainAppSourceChangeEvents("Standalone")
+	err := receiver.handleNotifications(ctx, []appSourceChangeNotification{{bucket: "bucket1", key: "apps/securityApps/app1.tgz"}})
+	if err != nil {
+		t.Fatalf("handleNotifications should not have returned error: %v", err)
+	}
+
+	if _, ok := getDirtyAppSources(referrer)["securityApps"]; !ok || len(drainAppSourceChangeEvents("Standalone")) != 1 {
+		t.Errorf("App source referring to the notified AppSource should have been marked dirty")
+	}
+
+	sharedAppSourceListings.mutex.Lock()
+	_, ok := sharedAppSourceListings.listings[getSharedAppSourceKey("shared", "security-apps")]
+	sharedAppSourceListings.mutex.Unlock()
+	if ok {
+		t.Errorf("Cached listing of the notified AppSource should have been dropped")
+	}
+}
@@ -0,0 +1,445 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The App Framework keeps the downloaded app packages in a cache on the Operator volume,
+// and hard links them into the download directory of the CR. So, an app package used by
+// several CRs is downloaded only once. A cache entry is named after the app name and its
+// object hash, and is considered in use as long as any CR has a link to it. Unused entries
+// are evicted in the least recently used order, when there is not enough disk space for a
+// new download.
+//
+// The storage reserved for a download is handed over to the cache entry, and released when
+// the entry is evicted. Only when the app package could not be cached, the local app package
+// holds the reservation, which is recorded and released once the package is deleted.
+
+const (
+	// appCacheDirName is the cache directory on the Operator volume
+	appCacheDirName = "appCache"
+
+	// appCachePartialFileSuffix is the suffix of a partially downloaded app package
+	appCachePartialFileSuffix = ".partial"
+)
+
+var (
+	md5DigestRegex    = regexp.MustCompile("^[a-f0-9]{32}$")
+	sha256DigestRegex = regexp.MustCompile("^[a-f0-9]{64}$")
+)
+
+// appCacheLock serializes the access to a cache entry
+type appCacheLock struct {
+	sync.Mutex
+
+	// refCount is the number of callers holding or waiting for the lock
+	refCount int
+}
+
+// appCacheLocks tracks the locks of the cache entries. As every version of an app package is
+// a new cache entry, a lock is removed as soon as nobody holds or waits for it
+var appCacheLocks = struct {
+	mutex sync.Mutex
+	locks map[string]*appCacheLock
+}{locks: make(map[string]*appCacheLock)}
+
+// appPkgStorageReservations records the storage reserved for the local app packages that are not cached,
+// by the local app package path
+var appPkgStorageReservations = struct {
+	mutex sync.Mutex
+	sizes map[string]uint64
+}{sizes: make(map[string]uint64)}
+
+// holdAppPkgStorage records the storage reserved for the download, as held by the local app package
+func holdAppPkgStorage(localFile string, size uint64) {
+	appPkgStorageReservations.mutex.Lock()
+	defer appPkgStorageReservations.mutex.Unlock()
+
+	appPkgStorageReservations.sizes[localFile] += size
+}
+
+// releaseAppPkgStorage releases the storage recorded for the local app package, if any. The storage is released
+// only once, no matter how many times it is called
+func releaseAppPkgStorage(localFile string) {
+	appPkgStorageReservations.mutex.Lock()
+	size, ok := appPkgStorageReservations.sizes[localFile]
+	delete(appPkgStorageReservations.sizes, localFile)
+	appPkgStorageReservations.mutex.Unlock()
+
+	if ok {
+		releaseStorage(size)
+	}
+}
+
+// getAppCacheLock returns the lock of the cache entry, taking a reference to it
+func getAppCacheLock(cacheFile string) *appCacheLock {
+	appCacheLocks.mutex.Lock()
+	defer appCacheLocks.mutex.Unlock()
+
+	lock, ok := appCacheLocks.locks[cacheFile]
+	if !ok {
+		lock = &appCacheLock{}
+		appCacheLocks.locks[cacheFile] = lock
+	}
+	lock.refCount++
+	return lock
+}
+
+// putAppCacheLock drops the reference to the lock of the cache entry, and removes the lock once unused
+func putAppCacheLock(cacheFile string, lock *appCacheLock) {
+	appCacheLocks.mutex.Lock()
+	defer appCacheLocks.mutex.Unlock()
+
+	lock.refCount--
+	if lock.refCount == 0 {
+		delete(appCacheLocks.locks, cacheFile)
+	}
+}
+
+// lockAppCacheFile locks the cache entry, and returns the function to unlock it
+func lockAppCacheFile(cacheFile string) func() {
+	lock := getAppCacheLock(cacheFile)
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		putAppCacheLock(cacheFile, lock)
+	}
+}
+
+// tryLockAppCacheFile locks the cache entry only if it is not locked already
+func tryLockAppCacheFile(cacheFile string) (func(), bool) {
+	lock := getAppCacheLock(cacheFile)
+	if !lock.TryLock() {
+		putAppCacheLock(cacheFile, lock)
+		return nil, false
+	}
+	return func() {
+		lock.Unlock()
+		putAppCacheLock(cacheFile, lock)
+	}, true
+}
+
+// getAppCacheDir returns the app package cache directory on the Operator volume
+func getAppCacheDir() string {
+	return filepath.Join(splcommon.AppDownloadVolume, appCacheDirName) + "/"
+}
+
+// getAppCacheFileName returns the cache entry of the app package
+func getAppCacheFileName(ctx context.Context, appName, objectHash string) string {
+	return getLocalAppFileName(ctx, getAppCacheDir(), appName, objectHash)
+}
+
+// getFileLinkCount returns the number of hard links to the file
+func getFileLinkCount(fileInfo os.FileInfo) uint64 {
+	if stat, ok := fileInfo.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
+
+// isAppPkgLinkedToCache checks if the local app package shares its data with a cache entry
+func isAppPkgLinkedToCache(localFile string) bool {
+	fileInfo, err := os.Stat(localFile)
+	if err != nil {
+		return false
+	}
+	return getFileLinkCount(fileInfo) > 1
+}
+
+// linkAppPkgFromCache links the cache entry to the local app package path, and marks the entry as recently used
+func linkAppPkgFromCache(ctx context.Context, cacheFile, localFile string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("linkAppPkgFromCache").WithValues("cacheFile", cacheFile, "localFile", localFile)
+
+	err := os.Remove(localFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		scopedLog.Error(err, "unable to remove the stale local app package")
+		return err
+	}
+
+	err = os.Link(cacheFile, localFile)
+	if err != nil {
+		scopedLog.Error(err, "unable to link the app package from the cache")
+		return err
+	}
+
+	// the cache keeps the app package as downloaded, while the local app package is a tarball
+	err = normalizeAppPkg(ctx, localFile)
+	if err != nil {
+		os.Remove(localFile)
+		return err
+	}
+
+	currentTime := time.Now()
+	err = os.Chtimes(cacheFile, currentTime, currentTime)
+	if err != nil {
+		scopedLog.Error(err, "unable to update the access time of the app package in the cache")
+	}
+
+	return nil
+}
+
+// fetchAppPkgFromCache links the app package from the cache for the download worker, if it is already cached
+func fetchAppPkgFromCache(ctx context.Context, downloadWorker *PipelineWorker) bool {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("fetchAppPkgFromCache").WithValues("appName", downloadWorker.appDeployInfo.AppName, "objectHash", downloadWorker.appDeployInfo.ObjectHash)
+
+	appDeployInfo := downloadWorker.appDeployInfo
+	cacheFile := getAppCacheFileName(ctx, appDeployInfo.AppName, appDeployInfo.ObjectHash)
+
+	unlock := lockAppCacheFile(cacheFile)
+	defer unlock()
+
+	if !isAppPkgCached(cacheFile, appDeployInfo.Size) {
+		return false
+	}
+
+	localPath, err := downloadWorker.createDownloadDirOnOperator(ctx)
+	if err != nil {
+		return false
+	}
+
+	localFile := getLocalAppFileName(ctx, localPath, appDeployInfo.AppName, appDeployInfo.ObjectHash)
+	err = linkAppPkgFromCache(ctx, cacheFile, localFile)
+	if err != nil {
+		return false
+	}
+
+	scopedLog.Info("Using the app package from the cache")
+	return true
+}
+
+// isAppPkgCached checks if the complete app package is available in the cache
+func isAppPkgCached(cacheFile string, size uint64) bool {
+	fileInfo, err := os.Stat(cacheFile)
+	if err != nil {
+		return false
+	}
+	return size == 0 || uint64(fileInfo.Size()) == size
+}
+
+// downloadAppPkgThroughCache downloads the app package into the cache, resuming any earlier partial
+// download, verifies it, and links it to the local app package path. The storage reserved for the
+// download is either handed over to the cache entry or the local app package, or released
+func downloadAppPkgThroughCache(ctx context.Context, remoteDataClientMgr RemoteDataClientManager, remoteFile string, localFile string, appName string, objectHash string, size uint64) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("downloadAppPkgThroughCache").WithValues("appName", appName, "objectHash", objectHash)
+
+	isReserved := true
+	defer func() {
+		if isReserved {
+			releaseStorage(size)
+		}
+	}()
+
+	if objectHash == "" {
+		return fmt.Errorf("object hash is missing for the app: %s", appName)
+	}
+
+	err := createAppDownloadDir(ctx, getAppCacheDir())
+	if err != nil {
+		return err
+	}
+
+	cacheFile := getAppCacheFileName(ctx, appName, objectHash)
+
+	// serialize the downloads of the same app package, across the CRs
+	unlock := lockAppCacheFile(cacheFile)
+	defer unlock()
+
+	// some other CR might have downloaded the same app package, while waiting for the lock
+	if isAppPkgCached(cacheFile, size) {
+		scopedLog.Info("App package is already downloaded to the cache")
+		// the storage reserved for this download is not needed anymore
+		return linkAppPkgFromCache(ctx, cacheFile, localFile)
+	}
+
+	partialFile := cacheFile + appCachePartialFileSuffix
+	var startOffset int64
+
+	// resume only when the size is known, so that we know when the download is complete
+	if fileInfo, err := os.Stat(partialFile); err == nil && size > 0 {
+		if uint64(fileInfo.Size()) <= size {
+			startOffset = fileInfo.Size()
+		}
+	}
+
+	if startOffset == 0 || uint64(startOffset) < size {
+		if startOffset > 0 {
+			scopedLog.Info("Resuming the partial download", "startOffset", startOffset, "size", size)
+		}
+
+		err = remoteDataClientMgr.DownloadApp(ctx, remoteFile, partialFile, objectHash, startOffset)
+		if err != nil {
+			// keep the partial download, to resume it in the next attempt
+			return err
+		}
+	}
+
+	err = verifyAppPkg(ctx, partialFile, objectHash, size)
+	if err != nil {
+		os.Remove(partialFile)
+		return err
+	}
+
+	err = os.Rename(partialFile, cacheFile)
+	if err != nil {
+		scopedLog.Error(err, "unable to move the app package to the cache")
+		os.Remove(partialFile)
+		return err
+	}
+
+	// from here on, the cache entry holds the storage reserved for the download
+	isReserved = false
+	err = linkAppPkgFromCache(ctx, cacheFile, localFile)
+	if err != nil {
+		// the volume doesn't support hard links, so just don't cache the app package
+		err = os.Rename(cacheFile, localFile)
+		if err != nil {
+			return err
+		}
+		holdAppPkgStorage(localFile, size)
+		return normalizeAppPkg(ctx, localFile)
+	}
+
+	return nil
+}
+
+// verifyAppPkg verifies the downloaded app package against the size, when known, and the object hash on the
+// remote storage. Both a sha256 object hash and an MD5 object hash (S3 etag) are verified, while a multipart
+// etag is not a digest of the object, and is skipped. A failed verification discards the partial download,
+// and the next attempt starts from the beginning.
+func verifyAppPkg(ctx context.Context, appPkgFile string, objectHash string, size uint64) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("verifyAppPkg").WithValues("appPkgFile", appPkgFile, "objectHash", objectHash)
+
+	fileInfo, err := os.Stat(appPkgFile)
+	if err != nil {
+		return err
+	}
+
+	if size > 0 && uint64(fileInfo.Size()) != size {
+		return fmt.Errorf("size mismatch for the app package %s, expected: %d, got: %d", appPkgFile, size, fileInfo.Size())
+	}
+
+	digest := strings.ToLower(strings.Trim(objectHash, "\""))
+
+	var digestHash hash.Hash
+	if sha256DigestRegex.MatchString(digest) {
+		digestHash = sha256.New()
+	} else if md5DigestRegex.MatchString(digest) {
+		digestHash = md5.New()
+	} else {
+		return nil
+	}
+
+	file, err := os.Open(appPkgFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(digestHash, file)
+	if err != nil {
+		return err
+	}
+
+	actualDigest := hex.EncodeToString(digestHash.Sum(nil))
+	if actualDigest != digest {
+		return fmt.Errorf("checksum mismatch for the app package %s, expected: %s, got: %s", appPkgFile, digest, actualDigest)
+	}
+
+	scopedLog.Info("App package verified")
+	return nil
+}
+
+// evictAppCache removes the least recently used app packages that are not in use by any CR, till
+// the required size is freed up, and returns the freed up size
+func evictAppCache(ctx context.Context, requiredSize uint64) uint64 {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("evictAppCache").WithValues("requiredSize", requiredSize)
+
+	dirEntries, err := os.ReadDir(getAppCacheDir())
+	if err != nil {
+		return 0
+	}
+
+	var cacheEntries []os.FileInfo
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || strings.HasSuffix(dirEntry.Name(), appCachePartialFileSuffix) {
+			continue
+		}
+		fileInfo, err := dirEntry.Info()
+		if err != nil || getFileLinkCount(fileInfo) > 1 {
+			continue
+		}
+		cacheEntries = append(cacheEntries, fileInfo)
+	}
+
+	sort.Slice(cacheEntries, func(i, j int) bool {
+		return cacheEntries[i].ModTime().Before(cacheEntries[j].ModTime())
+	})
+
+	var freedSize uint64
+	for _, fileInfo := range cacheEntries {
+		if freedSize >= requiredSize {
+			break
+		}
+
+		cacheFile := filepath.Join(getAppCacheDir(), fileInfo.Name())
+
+		// skip the entry, if it is being downloaded or linked right now
+		unlock, ok := tryLockAppCacheFile(cacheFile)
+		if !ok {
+			continue
+		}
+		err = os.Remove(cacheFile)
+		unlock()
+		if err != nil {
+			scopedLog.Error(err, "unable to evict the app package from the cache", "cacheFile", cacheFile)
+			continue
+		}
+
+		scopedLog.Info("Evicted the app package from the cache", "cacheFile", cacheFile, "size", fileInfo.Size())
+		freedSize += uint64(fileInfo.Size())
+	}
+
+	if freedSize > 0 {
+		releaseStorage(freedSize)
+	}
+
+	return freedSize
+}
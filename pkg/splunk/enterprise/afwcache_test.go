@@ -0,0 +1,353 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVerifyAppPkg(t *testing.T) {
+	ctx := context.TODO()
+	contents := []byte("app1 package")
+	appPkgFile := filepath.Join(t.TempDir(), "app1.tgz")
+	err := os.WriteFile(appPkgFile, contents, 0644)
+	if err != nil {
+		t.Fatalf("unable to create the app package: %v", err)
+	}
+
+	sha256Digest := sha256.Sum256(contents)
+	md5Digest := md5.Sum(contents)
+
+	// sha256 is always verified
+	err = verifyAppPkg(ctx, appPkgFile, hex.EncodeToString(sha256Digest[:]), 0)
+	if err != nil {
+		t.Errorf("verifyAppPkg should not have returned error for a matching sha256: %v", err)
+	}
+	err = verifyAppPkg(ctx, appPkgFile, hex.EncodeToString(md5Digest[:])+hex.EncodeToString(md5Digest[:]), 0)
+	if err == nil {
+		t.Errorf("verifyAppPkg should have returned error for a sha256 mismatch")
+	}
+
+	// MD5 etag is verified for every download
+	err = verifyAppPkg(ctx, appPkgFile, "\"0123456789abcdef0123456789abcdef\"", 0)
+	if err == nil {
+		t.Errorf("verifyAppPkg should have returned error for an MD5 etag mismatch of a complete download")
+	}
+	err = verifyAppPkg(ctx, appPkgFile, "\""+hex.EncodeToString(md5Digest[:])+"\"", uint64(len(contents)))
+	if err != nil {
+		t.Errorf("verifyAppPkg should not have returned error for a matching MD5 etag: %v", err)
+	}
+	err = verifyAppPkg(ctx, appPkgFile, "0123456789abcdef0123456789abcdef", uint64(len(contents)))
+	if err == nil {
+		t.Errorf("verifyAppPkg should have returned error for an MD5 etag mismatch")
+	}
+
+	// size is verified, when known
+	err = verifyAppPkg(ctx, appPkgFile, "abcd1111", uint64(len(contents))+1)
+	if err == nil {
+		t.Errorf("verifyAppPkg should have returned error for a size mismatch")
+	}
+
+	// multipart etags can't be verified
+	err = verifyAppPkg(ctx, appPkgFile, "0123456789abcdef0123456789abcdef-2", uint64(len(contents)))
+	if err != nil {
+		t.Errorf("verifyAppPkg should not verify a multipart etag: %v", err)
+	}
+}
+
+func TestDownloadAppPkgThroughCache(t *testing.T) {
+	ctx := context.TODO()
+
+	defer func(appDownloadVolume string) { splcommon.AppDownloadVolume = appDownloadVolume }(splcommon.AppDownloadVolume)
+	splcommon.AppDownloadVolume = t.TempDir()
+
+	operatorResourceTracker = &globalResourceTracker{
+		storage: &storageTracker{
+			availableDiskSpace: 1024,
+		},
+	}
+	initCommonResourceTracker()
+
+	contents := []byte("app1 package")
+	digest := sha256.Sum256(contents)
+	objectHash := hex.EncodeToString(digest[:])
+	size := uint64(len(contents))
+
+	// a complete partial download is verified and moved to the cache, without downloading it again
+	err := createAppDownloadDir(ctx, getAppCacheDir())
+	if err != nil {
+		t.Fatalf("unable to create the cache directory: %v", err)
+	}
+	cacheFile := getAppCacheFileName(ctx, "app1.tgz", objectHash)
+	err = os.WriteFile(cacheFile+appCachePartialFileSuffix, contents, 0644)
+	if err != nil {
+		t.Fatalf("unable to create the partial download: %v", err)
+	}
+
+	localFile := filepath.Join(t.TempDir(), "app1.tgz_"+objectHash)
+	err = downloadAppPkgThroughCache(ctx, RemoteDataClientManager{}, "adminAppsRepo/app1.tgz", localFile, "app1.tgz", objectHash, size)
+	if err != nil {
+		t.Fatalf("downloadAppPkgThroughCache should not have returned error: %v", err)
+	}
+	if !isAppPkgCached(cacheFile, size) || !isAppPkgLinkedToCache(localFile) {
+		t.Errorf("app package should have been linked from the cache")
+	}
+	if _, err = os.Stat(cacheFile + appCachePartialFileSuffix); err == nil {
+		t.Errorf("partial download should have been moved to the cache")
+	}
+	appCacheLocks.mutex.Lock()
+	_, ok := appCacheLocks.locks[cacheFile]
+	appCacheLocks.mutex.Unlock()
+	if ok {
+		t.Errorf("lock of the cache entry should have been removed after the download")
+	}
+
+	// a cached app package is linked for the other CRs, and the reserved storage is released
+	reserveStorage(size)
+	localFile2 := filepath.Join(t.TempDir(), "app1.tgz_"+objectHash)
+	err = downloadAppPkgThroughCache(ctx, RemoteDataClientManager{}, "adminAppsRepo/app1.tgz", localFile2, "app1.tgz", objectHash, size)
+	if err != nil {
+		t.Errorf("downloadAppPkgThroughCache should not have returned error for a cached app package: %v", err)
+	}
+	if operatorResourceTracker.storage.availableDiskSpace != 1024 {
+		t.Errorf("storage reserved for a cached app package should have been released")
+	}
+
+	// removing a linked app package doesn't release the storage, that is held by the cache entry
+	os.Remove(localFile2)
+	releaseAppPkgStorage(localFile2)
+	if operatorResourceTracker.storage.availableDiskSpace != 1024 {
+		t.Errorf("storage of a cached app package should not have been released")
+	}
+
+	// a corrupted partial download is discarded, and the reserved storage is released
+	reserveStorage(size)
+	objectHash2 := hex.EncodeToString(make([]byte, sha256.Size))
+	cacheFile2 := getAppCacheFileName(ctx, "app2.tgz", objectHash2)
+	err = os.WriteFile(cacheFile2+appCachePartialFileSuffix, contents, 0644)
+	if err != nil {
+		t.Fatalf("unable to create the partial download: %v", err)
+	}
+	err = downloadAppPkgThroughCache(ctx, RemoteDataClientManager{}, "adminAppsRepo/app2.tgz", filepath.Join(t.TempDir(), "app2.tgz"), "app2.tgz", objectHash2, size)
+	if err == nil {
+		t.Errorf("downloadAppPkgThroughCache should have returned error for a checksum mismatch")
+	}
+	if _, err = os.Stat(cacheFile2 + appCachePartialFileSuffix); err == nil {
+		t.Errorf("corrupted partial download should have been removed")
+	}
+	if operatorResourceTracker.storage.availableDiskSpace != 1024 {
+		t.Errorf("storage reserved for a failed download should have been released")
+	}
+
+	// object hash is required to cache the app package
+	err = downloadAppPkgThroughCache(ctx, RemoteDataClientManager{}, "adminAppsRepo/app3.tgz", filepath.Join(t.TempDir(), "app3.tgz"), "app3.tgz", "", size)
+	if err == nil {
+		t.Errorf("downloadAppPkgThroughCache should have returned error for a missing object hash")
+	}
+}
+
+func TestAppCacheLock(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "app1.tgz_abcd1111")
+
+	unlock := lockAppCacheFile(cacheFile)
+	if _, ok := tryLockAppCacheFile(cacheFile); ok {
+		t.Errorf("cache entry should not have been locked twice")
+	}
+
+	// the lock is kept as long as somebody waits for it
+	locked := make(chan bool)
+	go func() {
+		unlock2 := lockAppCacheFile(cacheFile)
+		locked <- true
+		unlock2()
+		locked <- true
+	}()
+	for {
+		appCacheLocks.mutex.Lock()
+		refCount := appCacheLocks.locks[cacheFile].refCount
+		appCacheLocks.mutex.Unlock()
+		if refCount == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	unlock()
+	<-locked
+	<-locked
+
+	appCacheLocks.mutex.Lock()
+	_, ok := appCacheLocks.locks[cacheFile]
+	appCacheLocks.mutex.Unlock()
+	if ok {
+		t.Errorf("lock of the cache entry should have been removed once unused")
+	}
+
+	unlock, ok = tryLockAppCacheFile(cacheFile)
+	if !ok {
+		t.Errorf("cache entry should have been locked")
+	}
+	unlock()
+}
+
+func TestFetchAppPkgFromCache(t *testing.T) {
+	ctx := context.TODO()
+
+	defer func(appDownloadVolume string) { splcommon.AppDownloadVolume = appDownloadVolume }(splcommon.AppDownloadVolume)
+	splcommon.AppDownloadVolume = t.TempDir()
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s1",
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.StandaloneSpec{
+			AppFrameworkConfig: enterpriseApi.AppFrameworkSpec{
+				AppSources: []enterpriseApi.AppSourceSpec{
+					{
+						Name:     "appSrc1",
+						Location: "adminAppsRepo",
+						AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{
+							Scope: enterpriseApi.ScopeLocal,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	worker := &PipelineWorker{
+		cr:         &cr,
+		appSrcName: "appSrc1",
+		afwConfig:  &cr.Spec.AppFrameworkConfig,
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{
+			AppName:    "app1.tgz",
+			ObjectHash: "abcd1111",
+			Size:       10,
+		},
+	}
+
+	if fetchAppPkgFromCache(ctx, worker) {
+		t.Errorf("fetchAppPkgFromCache should have returned false when the app package is not cached")
+	}
+
+	err := createAppDownloadDir(ctx, getAppCacheDir())
+	if err != nil {
+		t.Fatalf("unable to create the cache directory: %v", err)
+	}
+	err = createOrTruncateAppFileLocally(getAppCacheFileName(ctx, "app1.tgz", "abcd1111"), 10)
+	if err != nil {
+		t.Fatalf("unable to create the cached app package: %v", err)
+	}
+
+	if !fetchAppPkgFromCache(ctx, worker) {
+		t.Errorf("fetchAppPkgFromCache should have linked the cached app package")
+	}
+	if !isAppAlreadyDownloaded(ctx, worker) {
+		t.Errorf("app package should be available in the download directory of the CR")
+	}
+
+	// app package with a different size is not used
+	worker.appDeployInfo.Size = 20
+	if fetchAppPkgFromCache(ctx, worker) {
+		t.Errorf("fetchAppPkgFromCache should have returned false for a size mismatch")
+	}
+}
+
+func TestEvictAppCache(t *testing.T) {
+	ctx := context.TODO()
+
+	defer func(appDownloadVolume string) { splcommon.AppDownloadVolume = appDownloadVolume }(splcommon.AppDownloadVolume)
+	splcommon.AppDownloadVolume = t.TempDir()
+
+	operatorResourceTracker = &globalResourceTracker{
+		storage: &storageTracker{
+			availableDiskSpace: 0,
+		},
+	}
+	initCommonResourceTracker()
+
+	// no cache yet
+	if evictAppCache(ctx, 10) != 0 {
+		t.Errorf("nothing should have been evicted without the cache")
+	}
+
+	err := createAppDownloadDir(ctx, getAppCacheDir())
+	if err != nil {
+		t.Fatalf("unable to create the cache directory: %v", err)
+	}
+
+	testApps := []string{"app1.tgz", "app2.tgz", "app3.tgz", "app4.tgz"}
+	baseTime := time.Now().Add(-time.Hour)
+	for index, appName := range testApps {
+		cacheFile := getAppCacheFileName(ctx, appName, "abcd1111")
+		err = createOrTruncateAppFileLocally(cacheFile, 10)
+		if err != nil {
+			t.Fatalf("unable to create the cached app package: %v", err)
+		}
+		accessTime := baseTime.Add(time.Duration(index) * time.Minute)
+		os.Chtimes(cacheFile, accessTime, accessTime)
+	}
+
+	// app1 is the least recently used, but is in use by a CR
+	localFile := filepath.Join(t.TempDir(), "app1.tgz_abcd1111")
+	err = os.Link(getAppCacheFileName(ctx, "app1.tgz", "abcd1111"), localFile)
+	if err != nil {
+		t.Fatalf("unable to link the cached app package: %v", err)
+	}
+
+	// app5 is being downloaded
+	err = createOrTruncateAppFileLocally(getAppCacheFileName(ctx, "app5.tgz", "abcd1111")+appCachePartialFileSuffix, 10)
+	if err != nil {
+		t.Fatalf("unable to create the partial download: %v", err)
+	}
+
+	freedSize := evictAppCache(ctx, 15)
+	if freedSize != 20 {
+		t.Errorf("Expected 20 Bytes to be freed, got: %d", freedSize)
+	}
+	if operatorResourceTracker.storage.availableDiskSpace != 20 {
+		t.Errorf("Evicted storage should have been released")
+	}
+
+	for index, appName := range testApps {
+		_, err = os.Stat(getAppCacheFileName(ctx, appName, "abcd1111"))
+		if (index == 1 || index == 2) != os.IsNotExist(err) {
+			t.Errorf("Unexpected eviction state for the app package %s", appName)
+		}
+	}
+	if _, err = os.Stat(getAppCacheFileName(ctx, "app5.tgz", "abcd1111") + appCachePartialFileSuffix); err != nil {
+		t.Errorf("Partial download should not have been evicted")
+	}
+
+	// deleting the app package of the CR keeps the storage reserved till it is evicted
+	if !isAppPkgLinkedToCache(localFile) {
+		t.Errorf("App package should have been linked to the cache")
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The install order and the app dependencies of all the app sources of a CR form a DAG,
+// with an edge from each app to every app it depends on. As the same app name can be
+// present in several app sources, an app is identified by its app source and its name.
+// An install worker is held back till all the apps it depends on are installed on the
+// same pod, and fails when any of them is missing or failed to install.
+
+// appDependencyKeySeparator separates the app source and the app name, in a dependsOn entry as well
+const appDependencyKeySeparator = "/"
+
+// getAppDependencyKey returns the key of an app in the dependency graph
+func getAppDependencyKey(appSrcName, appName string) string {
+	return appSrcName + appDependencyKeySeparator + appName
+}
+
+// getAppDependencyKeyOf returns the key of an app listed in dependsOn. An app of another app source
+// is listed as <appSource>/<app>, otherwise the app is from the same app source
+func getAppDependencyKeyOf(appSrcName, dependency string) string {
+	if strings.Contains(dependency, appDependencyKeySeparator) {
+		return dependency
+	}
+	return getAppDependencyKey(appSrcName, dependency)
+}
+
+// getAppInstallDependencies returns the apps each app depends on, across all the app sources
+func getAppInstallDependencies(appFramework *enterpriseApi.AppFrameworkSpec) map[string][]string {
+	appDependencies := make(map[string][]string)
+
+	for _, appSrc := range appFramework.AppSources {
+		for i := 1; i < len(appSrc.InstallOrder); i++ {
+			appKey := getAppDependencyKey(appSrc.Name, appSrc.InstallOrder[i])
+			appDependencies[appKey] = append(appDependencies[appKey], getAppDependencyKey(appSrc.Name, appSrc.InstallOrder[i-1]))
+		}
+
+		for _, appDependency := range appSrc.AppDependencies {
+			appKey := getAppDependencyKey(appSrc.Name, appDependency.Name)
+			for _, dependency := range appDependency.DependsOn {
+				appDependencies[appKey] = append(appDependencies[appKey], getAppDependencyKeyOf(appSrc.Name, dependency))
+			}
+		}
+	}
+
+	return appDependencies
+}
+
+// findAppDependencyCycle returns the apps forming a dependency cycle, if there is one
+func findAppDependencyCycle(appDependencies map[string][]string) []string {
+	const (
+		notVisited = iota
+		inProgress
+		visited
+	)
+
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(appName string) []string
+	visit = func(appName string) []string {
+		state[appName] = inProgress
+		path = append(path, appName)
+
+		for _, dependency := range appDependencies[appName] {
+			switch state[dependency] {
+			case inProgress:
+				// the cycle starts at the first occurrence of the dependency on the current path
+				for i := range path {
+					if path[i] == dependency {
+						return append(append([]string{}, path[i:]...), dependency)
+					}
+				}
+			case notVisited:
+				if cycle := visit(dependency); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[appName] = visited
+		return nil
+	}
+
+	// visit the apps in a fixed order, so that the same cycle is reported every time
+	appNames := make([]string, 0, len(appDependencies))
+	for appName := range appDependencies {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
+		if state[appName] == notVisited {
+			if cycle := visit(appName); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateAppInstallDependencies validates the install order and the app dependencies of the app sources
+func validateAppInstallDependencies(ctx context.Context, appFramework *enterpriseApi.AppFrameworkSpec) error {
+	for _, appSrc := range appFramework.AppSources {
+		if len(appSrc.InstallOrder) == 0 && len(appSrc.AppDependencies) == 0 {
+			continue
+		}
+
+		scope := getAppSrcScope(ctx, appFramework, appSrc.Name)
+		if !canAppScopeHaveInstallWorker(scope) {
+			return fmt.Errorf("installOrder and appDependencies are not supported for App Source: %s with scope: %s. Supported scopes are %s and %s", appSrc.Name, scope, enterpriseApi.ScopeLocal, enterpriseApi.ScopePremiumApps)
+		}
+
+		duplicateAppChecker := make(map[string]bool)
+		for _, appName := range appSrc.InstallOrder {
+			if appName == "" {
+				return fmt.Errorf("app name is missing in installOrder for App Source: %s", appSrc.Name)
+			}
+			if duplicateAppChecker[appName] {
+				return fmt.Errorf("app %s is listed more than once in installOrder for App Source: %s", appName, appSrc.Name)
+			}
+			duplicateAppChecker[appName] = true
+		}
+
+		duplicateAppChecker = make(map[string]bool)
+		for _, appDependency := range appSrc.AppDependencies {
+			if appDependency.Name == "" {
+				return fmt.Errorf("app name is missing in appDependencies for App Source: %s", appSrc.Name)
+			}
+			if duplicateAppChecker[appDependency.Name] {
+				return fmt.Errorf("app %s is listed more than once in appDependencies for App Source: %s", appDependency.Name, appSrc.Name)
+			}
+			duplicateAppChecker[appDependency.Name] = true
+
+			for _, dependency := range appDependency.DependsOn {
+				if dependency == "" {
+					return fmt.Errorf("app name is missing in dependsOn of app %s for App Source: %s", appDependency.Name, appSrc.Name)
+				}
+
+				dependencySrcName, dependencyName, _ := strings.Cut(getAppDependencyKeyOf(appSrc.Name, dependency), appDependencyKeySeparator)
+				if dependencyName == "" || strings.Contains(dependencyName, appDependencyKeySeparator) {
+					return fmt.Errorf("dependency %s of app %s for App Source: %s is not in the form <appSource>/<app>", dependency, appDependency.Name, appSrc.Name)
+				}
+				if _, err := getAppSrcSpec(appFramework.AppSources, dependencySrcName); err != nil {
+					return fmt.Errorf("App Source: %s of dependency %s of app %s is not found", dependencySrcName, dependency, appDependency.Name)
+				}
+			}
+		}
+	}
+
+	cycle := findAppDependencyCycle(getAppInstallDependencies(appFramework))
+	if cycle != nil {
+		return fmt.Errorf("app dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// getAppPhaseInfoOnPod returns the phase info of the app for the given pod
+func getAppPhaseInfoOnPod(cr splcommon.MetaObject, appDeployInfo *enterpriseApi.AppDeploymentInfo, podName string) *enterpriseApi.PhaseInfo {
+	phaseInfo := &appDeployInfo.PhaseInfo
+	if isFanOutApplicableToCR(cr) {
+		podID, err := getOrdinalValFromPodName(podName)
+		if err == nil && podID < len(appDeployInfo.AuxPhaseInfo) {
+			phaseInfo = &appDeployInfo.AuxPhaseInfo[podID]
+		}
+	}
+	return phaseInfo
+}
+
+// isAppInstalledOnPod checks if the app is installed on the given pod
+func isAppInstalledOnPod(cr splcommon.MetaObject, appDeployInfo *enterpriseApi.AppDeploymentInfo, podName string) bool {
+	phaseInfo := getAppPhaseInfoOnPod(cr, appDeployInfo, podName)
+	return phaseInfo.Phase == enterpriseApi.PhaseInstall && phaseInfo.Status == enterpriseApi.AppPkgInstallComplete
+}
+
+// areAppDependenciesInstalledOnPod checks if all the apps the install worker depends on are installed on its target pod.
+// An error is returned when the app can never be installed, as an app it depends on is not present in its app source,
+// or failed to install on the pod
+func areAppDependenciesInstalledOnPod(ppln *AppInstallPipeline, installWorker *PipelineWorker) (bool, error) {
+	appKey := getAppDependencyKey(installWorker.appSrcName, installWorker.appDeployInfo.AppName)
+	installed := true
+	for _, dependency := range getAppInstallDependencies(installWorker.afwConfig)[appKey] {
+		dependencySrcName, dependencyName, _ := strings.Cut(dependency, appDependencyKeySeparator)
+
+		var dependencyDeployInfo *enterpriseApi.AppDeploymentInfo
+		appSrcDeployInfo := ppln.appDeployContext.AppsSrcDeployStatus[dependencySrcName]
+		for i := range appSrcDeployInfo.AppDeploymentInfoList {
+			appDeployInfo := &appSrcDeployInfo.AppDeploymentInfoList[i]
+			if appDeployInfo.AppName == dependencyName && appDeployInfo.RepoState == enterpriseApi.RepoStateActive {
+				dependencyDeployInfo = appDeployInfo
+				break
+			}
+		}
+
+		if dependencyDeployInfo == nil {
+			return false, fmt.Errorf("app %s depends on the app %s, which is not present in App Source: %s", installWorker.appDeployInfo.AppName, dependencyName, dependencySrcName)
+		}
+
+		phaseInfo := getAppPhaseInfoOnPod(installWorker.cr, dependencyDeployInfo, installWorker.targetPodName)
+		if phaseInfo.Status == enterpriseApi.AppPkgInstallError {
+			return false, fmt.Errorf("app %s depends on the app %s of App Source: %s, which failed to install", installWorker.appDeployInfo.AppName, dependencyName, dependencySrcName)
+		}
+
+		if !isAppInstalledOnPod(installWorker.cr, dependencyDeployInfo, installWorker.targetPodName) {
+			installed = false
+		}
+	}
+
+	return installed, nil
+}
+
+// isRestartRequiredAfterAppInstall checks if Splunk is to be restarted after installing the app
+func isRestartRequiredAfterAppInstall(appFramework *enterpriseApi.AppFrameworkSpec, appSrcName string, appName string) bool {
+	appSrcSpec, err := getAppSrcSpec(appFramework.AppSources, appSrcName)
+	if err != nil {
+		return false
+	}
+
+	for _, appDependency := range appSrcSpec.AppDependencies {
+		if appDependency.Name == appName {
+			return appDependency.RestartAfterInstall
+		}
+	}
+
+	return false
+}
+
+// restartSplunkAfterAppInstall restarts Splunk on the target pod of the install worker, if the app requires it
+func restartSplunkAfterAppInstall(rctx context.Context, localCtx *localScopePlaybookContext, cr splcommon.MetaObject, phaseInfo *enterpriseApi.PhaseInfo) error {
+	worker := localCtx.worker
+
+	if !isRestartRequiredAfterAppInstall(worker.afwConfig, worker.appSrcName, worker.appDeployInfo.AppName) {
+		return nil
+	}
+
+	reqLogger := log.FromContext(rctx)
+	scopedLog := reqLogger.WithName("restartSplunkAfterAppInstall").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
+
+	command := "/opt/splunk/bin/splunk restart --answer-yes --no-prompt"
+	streamOptions := splutil.NewStreamOptionsObject(command)
+
+	// splunk restart logs its progress on stderr, so, rely only on the exit status
+	stdOut, stdErr, err := localCtx.podExecClient.RunPodExecCommand(rctx, streamOptions, []string{"/bin/sh"})
+	if err != nil {
+		phaseInfo.FailCount++
+		scopedLog.Error(err, "splunk restart failed after app install", "stdout", stdOut, "stderr", stdErr, "failCount", phaseInfo.FailCount)
+		return fmt.Errorf("splunk restart failed after app install. stdOut: %s, stdErr: %s, failCount: %d", stdOut, stdErr, phaseInfo.FailCount)
+	}
+
+	scopedLog.Info("Restarted splunk after app install")
+	return nil
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getAppDependencyTestAppFramework() *enterpriseApi.AppFrameworkSpec {
+	return &enterpriseApi.AppFrameworkSpec{
+		Defaults: enterpriseApi.AppSourceDefaultSpec{
+			VolName: "test_volume",
+			Scope:   enterpriseApi.ScopeLocal,
+		},
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{
+				Name:         "adminApps",
+				Location:     "adminAppsRepo",
+				InstallOrder: []string{"TA-base.tgz", "app1.tgz", "app2.tgz"},
+			},
+			{
+				Name:     "securityApps",
+				Location: "securityAppsRepo",
+				AppDependencies: []enterpriseApi.AppDependencySpec{
+					{Name: "secApp.tgz", DependsOn: []string{"adminApps/app1.tgz"}, RestartAfterInstall: true},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateAppInstallDependencies(t *testing.T) {
+	ctx := context.TODO()
+
+	appFramework := getAppDependencyTestAppFramework()
+	err := validateAppInstallDependencies(ctx, appFramework)
+	if err != nil {
+		t.Errorf("validateAppInstallDependencies should not have returned error for a valid DAG: %v", err)
+	}
+
+	// Cycle across the app sources
+	appFramework.AppSources[0].AppDependencies = append(appFramework.AppSources[0].AppDependencies, enterpriseApi.AppDependencySpec{Name: "TA-base.tgz", DependsOn: []string{"securityApps/secApp.tgz"}})
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err == nil || err.Error() != "app dependency cycle detected: adminApps/TA-base.tgz -> securityApps/secApp.tgz -> adminApps/app1.tgz -> adminApps/TA-base.tgz" {
+		t.Errorf("validateAppInstallDependencies should have reported the cycle, got: %v", err)
+	}
+
+	// Same app name in different app sources is not a cycle
+	appFramework = getAppDependencyTestAppFramework()
+	appFramework.AppSources[1].AppDependencies = append(appFramework.AppSources[1].AppDependencies, enterpriseApi.AppDependencySpec{Name: "app1.tgz", DependsOn: []string{"secApp.tgz"}})
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err != nil {
+		t.Errorf("validateAppInstallDependencies should not have returned error for the same app name in different app sources: %v", err)
+	}
+
+	// Dependency on an unknown app source
+	appFramework = getAppDependencyTestAppFramework()
+	appFramework.AppSources[1].AppDependencies[0].DependsOn = []string{"otherApps/app1.tgz"}
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err == nil || err.Error() != "App Source: otherApps of dependency otherApps/app1.tgz of app secApp.tgz is not found" {
+		t.Errorf("validateAppInstallDependencies should have returned error for an unknown app source, got: %v", err)
+	}
+
+	// App depending on itself
+	appFramework = getAppDependencyTestAppFramework()
+	appFramework.AppSources[1].AppDependencies[0].DependsOn = []string{"secApp.tgz"}
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err == nil {
+		t.Errorf("validateAppInstallDependencies should have returned error for an app depending on itself")
+	}
+
+	// Duplicate app in the install order
+	appFramework = getAppDependencyTestAppFramework()
+	appFramework.AppSources[0].InstallOrder = []string{"app1.tgz", "app2.tgz", "app1.tgz"}
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err == nil {
+		t.Errorf("validateAppInstallDependencies should have returned error for a duplicate app in installOrder")
+	}
+
+	// Duplicate app in the app dependencies
+	appFramework = getAppDependencyTestAppFramework()
+	appFramework.AppSources[1].AppDependencies = append(appFramework.AppSources[1].AppDependencies, enterpriseApi.AppDependencySpec{Name: "secApp.tgz"})
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err == nil {
+		t.Errorf("validateAppInstallDependencies should have returned error for a duplicate app in appDependencies")
+	}
+
+	// Missing app names
+	appFramework = getAppDependencyTestAppFramework()
+	appFramework.AppSources[1].AppDependencies[0].DependsOn = []string{""}
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err == nil {
+		t.Errorf("validateAppInstallDependencies should have returned error for a missing app name in dependsOn")
+	}
+
+	// Cluster scoped apps are not installed by the install workers
+	appFramework = getAppDependencyTestAppFramework()
+	appFramework.AppSources[0].Scope = enterpriseApi.ScopeCluster
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err == nil {
+		t.Errorf("validateAppInstallDependencies should have returned error for a cluster scoped app source")
+	}
+}
+
+func TestAreAppDependenciesInstalledOnPod(t *testing.T) {
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.AppFrameworkConfig = *getAppDependencyTestAppFramework()
+
+	installed := enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallComplete}
+	pending := enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallPending}
+
+	appDeployContext := &enterpriseApi.AppDeploymentContext{
+		AppsSrcDeployStatus: map[string]enterpriseApi.AppSrcDeployInfo{
+			"adminApps": {
+				AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+					{AppName: "TA-base.tgz", RepoState: enterpriseApi.RepoStateActive, AuxPhaseInfo: []enterpriseApi.PhaseInfo{installed, installed}},
+					{AppName: "app1.tgz", RepoState: enterpriseApi.RepoStateActive, AuxPhaseInfo: []enterpriseApi.PhaseInfo{installed, pending}},
+				},
+			},
+			"securityApps": {
+				AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+					{AppName: "secApp.tgz", RepoState: enterpriseApi.RepoStateActive, AuxPhaseInfo: []enterpriseApi.PhaseInfo{pending, pending}},
+				},
+			},
+		},
+	}
+	ppln := &AppInstallPipeline{
+		appDeployContext: appDeployContext,
+		cr:               &cr,
+	}
+
+	testCases := []struct {
+		appSrcName    string
+		appName       string
+		targetPodName string
+		want          bool
+	}{
+		{"adminApps", "TA-base.tgz", "splunk-s1-standalone-0", true},
+		{"adminApps", "app1.tgz", "splunk-s1-standalone-1", true},
+		{"adminApps", "app2.tgz", "splunk-s1-standalone-0", true},
+		{"adminApps", "app2.tgz", "splunk-s1-standalone-1", false},
+		{"securityApps", "secApp.tgz", "splunk-s1-standalone-0", true},
+		{"securityApps", "secApp.tgz", "splunk-s1-standalone-1", false},
+	}
+
+	for _, testCase := range testCases {
+		worker := &PipelineWorker{
+			cr:            &cr,
+			afwConfig:     &cr.Spec.AppFrameworkConfig,
+			appSrcName:    testCase.appSrcName,
+			targetPodName: testCase.targetPodName,
+			appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: testCase.appName},
+		}
+		if got, err := areAppDependenciesInstalledOnPod(ppln, worker); err != nil || got != testCase.want {
+			t.Errorf("app: %s, pod: %s, expected: %t, got: %t, error: %v", testCase.appName, testCase.targetPodName, testCase.want, got, err)
+		}
+	}
+
+	// Dependency is looked up only in its own app source
+	worker := &PipelineWorker{
+		cr:            &cr,
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		appSrcName:    "securityApps",
+		targetPodName: "splunk-s1-standalone-0",
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "secApp.tgz"},
+	}
+	cr.Spec.AppFrameworkConfig.AppSources[1].AppDependencies[0].DependsOn = []string{"app1.tgz"}
+	if _, err := areAppDependenciesInstalledOnPod(ppln, worker); err == nil {
+		t.Errorf("app of another app source should not satisfy the dependency")
+	}
+	cr.Spec.AppFrameworkConfig.AppSources[1].AppDependencies[0].DependsOn = []string{"adminApps/app1.tgz"}
+
+	// Dependency that failed to install fails the app
+	appDeployContext.AppsSrcDeployStatus["adminApps"].AppDeploymentInfoList[1].AuxPhaseInfo[0].Status = enterpriseApi.AppPkgInstallError
+	if _, err := areAppDependenciesInstalledOnPod(ppln, worker); err == nil {
+		t.Errorf("app should fail, when a dependency failed to install")
+	}
+
+	// Dependency that is deleted from the app source fails the app
+	appDeployContext.AppsSrcDeployStatus["adminApps"].AppDeploymentInfoList[0].RepoState = enterpriseApi.RepoStateDeleted
+	worker.appSrcName = "adminApps"
+	worker.appDeployInfo.AppName = "app1.tgz"
+	if _, err := areAppDependenciesInstalledOnPod(ppln, worker); err == nil {
+		t.Errorf("app should fail, when a dependency is not present in its app source")
+	}
+}
+
+func TestRestartSplunkAfterAppInstall(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.AppFrameworkConfig = *getAppDependencyTestAppFramework()
+
+	worker := &PipelineWorker{
+		cr:            &cr,
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		appSrcName:    "adminApps",
+		targetPodName: "splunk-s1-standalone-0",
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz"},
+	}
+
+	mockPodExecClient := &spltest.MockPodExecClient{Cr: &cr}
+	localCtx := &localScopePlaybookContext{
+		worker:        worker,
+		podExecClient: mockPodExecClient,
+	}
+	phaseInfo := &enterpriseApi.PhaseInfo{}
+
+	// No restart for an app without the restart flag
+	err := restartSplunkAfterAppInstall(ctx, localCtx, &cr, phaseInfo)
+	if err != nil || len(mockPodExecClient.GotCmdList) != 0 {
+		t.Errorf("splunk should not have been restarted")
+	}
+
+	worker.appSrcName = "securityApps"
+	worker.appDeployInfo.AppName = "secApp.tgz"
+	mockPodExecClient.AddMockPodExecReturnContext(ctx, "/opt/splunk/bin/splunk restart", &spltest.MockPodExecReturnContext{StdErr: "Stopping splunkd..."})
+	err = restartSplunkAfterAppInstall(ctx, localCtx, &cr, phaseInfo)
+	if err != nil {
+		t.Errorf("restartSplunkAfterAppInstall should not have returned error: %v", err)
+	}
+	mockPodExecClient.CheckPodExecCommands(t, "restartSplunkAfterAppInstall")
+
+	// Restart failure is counted as an install failure
+	mockPodExecClient.MockReturnContexts["/opt/splunk/bin/splunk restart"].Err = fmt.Errorf("dummy error")
+	err = restartSplunkAfterAppInstall(ctx, localCtx, &cr, phaseInfo)
+	if err == nil || phaseInfo.FailCount != 1 {
+		t.Errorf("restartSplunkAfterAppInstall should have returned error and incremented the fail count")
+	}
+}
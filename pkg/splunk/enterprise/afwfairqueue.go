@@ -0,0 +1,330 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The per CR limits(maxConcurrentAppDownloads, install slot per pod) don't stop the App Framework pipelines of different
+// CRs from competing with each other. So, on top of them, every download, pod copy and install worker takes a slot from
+// an operator wide scheduler of its phase before running. The waiting CRs of the highest priority are served first, and
+// the CRs of the same priority share the slots in proportion to their weights, so that a big rollout on one CR does not
+// starve the other CRs.
+
+const (
+	labelAppPhase    = "phase"
+	labelAppPriority = "priority"
+)
+
+var appPhaseQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "splunk_operator_app_framework_queue_depth",
+	Help: "The number of App Framework workers waiting for an operator wide slot",
+}, []string{labelAppPhase, labelAppPriority})
+
+var appPhaseActiveSlots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "splunk_operator_app_framework_active_slots",
+	Help: "The number of operator wide App Framework slots in use",
+}, []string{labelAppPhase})
+
+var appPhaseWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "splunk_operator_app_framework_queue_wait_seconds",
+	Help:    "The time App Framework workers wait for an operator wide slot (in seconds)",
+	Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+}, []string{labelAppPhase, labelAppPriority})
+
+func init() {
+	metrics.Registry.MustRegister(
+		appPhaseQueueDepth,
+		appPhaseActiveSlots,
+		appPhaseWaitSeconds,
+	)
+}
+
+// initAppPhaseSchedulers initializes the operator wide schedulers of the App Framework pipeline phases
+func initAppPhaseSchedulers() {
+	operatorResourceTracker.appPhaseSchedulers = map[enterpriseApi.AppPhaseType]*appPhaseScheduler{
+		enterpriseApi.PhaseDownload: newAppPhaseScheduler(enterpriseApi.PhaseDownload, splcommon.DefaultOperatorMaxAppDownloads),
+		enterpriseApi.PhasePodCopy:  newAppPhaseScheduler(enterpriseApi.PhasePodCopy, splcommon.DefaultOperatorMaxAppPodCopies),
+		enterpriseApi.PhaseInstall:  newAppPhaseScheduler(enterpriseApi.PhaseInstall, splcommon.DefaultOperatorMaxAppInstalls),
+	}
+}
+
+// newAppPhaseScheduler returns a scheduler for the given phase
+func newAppPhaseScheduler(phase enterpriseApi.AppPhaseType, maxSlots int) *appPhaseScheduler {
+	return &appPhaseScheduler{
+		phase:          phase,
+		maxSlots:       maxSlots,
+		lastFinishTags: make(map[string]float64),
+	}
+}
+
+// SetAppPhaseSchedulerLimits sets the max. number of app downloads, pod copies and installs that can run
+// at the same time across all the CRs. Non-positive values leave the corresponding limit unchanged
+func SetAppPhaseSchedulerLimits(maxDownloads, maxPodCopies, maxInstalls int) {
+	limits := map[enterpriseApi.AppPhaseType]int{
+		enterpriseApi.PhaseDownload: maxDownloads,
+		enterpriseApi.PhasePodCopy:  maxPodCopies,
+		enterpriseApi.PhaseInstall:  maxInstalls,
+	}
+
+	for phase, maxSlots := range limits {
+		scheduler := getAppPhaseScheduler(phase)
+		if scheduler == nil || maxSlots <= 0 {
+			continue
+		}
+
+		scheduler.mutex.Lock()
+		scheduler.maxSlots = maxSlots
+		scheduler.dispatch()
+		scheduler.mutex.Unlock()
+	}
+}
+
+// getAppPhaseScheduler returns the operator wide scheduler for the given phase
+func getAppPhaseScheduler(phase enterpriseApi.AppPhaseType) *appPhaseScheduler {
+	if operatorResourceTracker == nil || operatorResourceTracker.appPhaseSchedulers == nil {
+		return nil
+	}
+	return operatorResourceTracker.appPhaseSchedulers[phase]
+}
+
+// getAppFrameworkPriority returns the scheduling priority configured for the App Framework
+func getAppFrameworkPriority(afwConfig *enterpriseApi.AppFrameworkSpec) string {
+	if afwConfig == nil {
+		return enterpriseApi.AppFrameworkPriorityNormal
+	}
+
+	switch afwConfig.Priority {
+	case enterpriseApi.AppFrameworkPriorityHigh, enterpriseApi.AppFrameworkPriorityLow:
+		return afwConfig.Priority
+	default:
+		return enterpriseApi.AppFrameworkPriorityNormal
+	}
+}
+
+// getAppFrameworkWeight returns the scheduling weight configured for the App Framework
+func getAppFrameworkWeight(afwConfig *enterpriseApi.AppFrameworkSpec) uint32 {
+	if afwConfig == nil || afwConfig.Weight == 0 {
+		return 1
+	}
+	return afwConfig.Weight
+}
+
+// getAppFrameworkPriorityRank returns the rank of a scheduling priority, higher the rank, sooner a slot is granted
+func getAppFrameworkPriorityRank(priority string) int {
+	switch priority {
+	case enterpriseApi.AppFrameworkPriorityHigh:
+		return 2
+	case enterpriseApi.AppFrameworkPriorityLow:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// getAppPhaseCRKey returns the key used to track a CR in the schedulers
+func getAppPhaseCRKey(cr splcommon.MetaObject) string {
+	return cr.GetObjectKind().GroupVersionKind().Kind + "/" + cr.GetNamespace() + "/" + cr.GetName()
+}
+
+// requestSlot queues a slot request for a CR. The ready channel of the returned slot is closed once the slot is granted
+func (scheduler *appPhaseScheduler) requestSlot(crKey, priority string, weight uint32) *appPhaseSlot {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	// The start tag of a request is the virtual time, unless the CR already has its share queued beyond that.
+	// Higher the weight of the CR, lesser its finish tag moves ahead with every request
+	startTag := math.Max(scheduler.virtualTime, scheduler.lastFinishTags[crKey])
+	slot := &appPhaseSlot{
+		scheduler:   scheduler,
+		crKey:       crKey,
+		priority:    priority,
+		weight:      weight,
+		startTag:    startTag,
+		finishTag:   startTag + 1/float64(weight),
+		requestTime: time.Now(),
+		ready:       make(chan struct{}),
+	}
+	scheduler.lastFinishTags[crKey] = slot.finishTag
+	scheduler.waiting = append(scheduler.waiting, slot)
+
+	scheduler.dispatch()
+	return slot
+}
+
+// isSlotPreferred checks if the slot request a is to be served before the slot request b
+func isSlotPreferred(a, b *appPhaseSlot) bool {
+	rankA, rankB := getAppFrameworkPriorityRank(a.priority), getAppFrameworkPriorityRank(b.priority)
+	if rankA != rankB {
+		return rankA > rankB
+	}
+	if a.finishTag != b.finishTag {
+		return a.finishTag < b.finishTag
+	}
+	return a.requestTime.Before(b.requestTime)
+}
+
+// dispatch grants the free slots to the waiting requests. Caller must hold the scheduler mutex
+func (scheduler *appPhaseScheduler) dispatch() {
+	for scheduler.activeSlots < scheduler.maxSlots && len(scheduler.waiting) > 0 {
+		next := 0
+		for i := 1; i < len(scheduler.waiting); i++ {
+			if isSlotPreferred(scheduler.waiting[i], scheduler.waiting[next]) {
+				next = i
+			}
+		}
+
+		slot := scheduler.waiting[next]
+		scheduler.waiting = append(scheduler.waiting[:next], scheduler.waiting[next+1:]...)
+
+		slot.granted = true
+		scheduler.activeSlots++
+		if slot.startTag > scheduler.virtualTime {
+			scheduler.virtualTime = slot.startTag
+		}
+		appPhaseWaitSeconds.WithLabelValues(string(scheduler.phase), slot.priority).Observe(time.Since(slot.requestTime).Seconds())
+		close(slot.ready)
+	}
+
+	// CRs that are not ahead of the virtual time start from the virtual time anyway, no need to track them
+	for crKey, finishTag := range scheduler.lastFinishTags {
+		if finishTag <= scheduler.virtualTime {
+			delete(scheduler.lastFinishTags, crKey)
+		}
+	}
+
+	scheduler.updateMetrics()
+}
+
+// updateMetrics updates the queue depth and the active slots metrics. Caller must hold the scheduler mutex
+func (scheduler *appPhaseScheduler) updateMetrics() {
+	depth := map[string]int{
+		enterpriseApi.AppFrameworkPriorityHigh:   0,
+		enterpriseApi.AppFrameworkPriorityNormal: 0,
+		enterpriseApi.AppFrameworkPriorityLow:    0,
+	}
+	for _, slot := range scheduler.waiting {
+		depth[slot.priority]++
+	}
+
+	for priority, count := range depth {
+		appPhaseQueueDepth.WithLabelValues(string(scheduler.phase), priority).Set(float64(count))
+	}
+	appPhaseActiveSlots.WithLabelValues(string(scheduler.phase)).Set(float64(scheduler.activeSlots))
+}
+
+// release returns a granted slot to the scheduler, or withdraws the request if it is still waiting
+func (slot *appPhaseSlot) release() {
+	scheduler := slot.scheduler
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if slot.released {
+		return
+	}
+	slot.released = true
+
+	if slot.granted {
+		scheduler.activeSlots--
+	} else {
+		for i := range scheduler.waiting {
+			if scheduler.waiting[i] == slot {
+				scheduler.waiting = append(scheduler.waiting[:i], scheduler.waiting[i+1:]...)
+				break
+			}
+		}
+	}
+
+	scheduler.dispatch()
+}
+
+// acquireAppPhaseSlot waits for an operator wide slot of the given phase for the worker.
+// Returns false if the pipeline is terminated before the slot is granted
+func acquireAppPhaseSlot(ctx context.Context, ppln *AppInstallPipeline, phase enterpriseApi.AppPhaseType, worker *PipelineWorker) bool {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("acquireAppPhaseSlot").WithValues("name", worker.cr.GetName(), "namespace", worker.cr.GetNamespace(), "phase", phase, "app name", worker.appDeployInfo.AppName)
+
+	scheduler := getAppPhaseScheduler(phase)
+	if scheduler == nil {
+		return true
+	}
+
+	slot := scheduler.requestSlot(getAppPhaseCRKey(worker.cr), getAppFrameworkPriority(worker.afwConfig), getAppFrameworkWeight(worker.afwConfig))
+
+	var sigTerm chan struct{}
+	if ppln != nil {
+		sigTerm = ppln.sigTerm
+	}
+
+	select {
+	case <-slot.ready:
+	default:
+		scopedLog.Info("Waiting for an operator wide slot", "priority", slot.priority)
+		select {
+		case <-slot.ready:
+		case <-sigTerm:
+			scopedLog.Info("Pipeline terminated while waiting for an operator wide slot")
+			slot.release()
+			return false
+		}
+	}
+
+	worker.phaseSlot = slot
+	return true
+}
+
+// tryAcquireAppPhaseSlot checks, without waiting, if an operator wide slot of the given phase is granted to the worker.
+// The slot request is queued on the first attempt, and stays queued till it is granted, so that the worker doesn't
+// lose its turn while it frees up the other resources it holds in the meantime
+func tryAcquireAppPhaseSlot(ctx context.Context, phase enterpriseApi.AppPhaseType, worker *PipelineWorker) bool {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("tryAcquireAppPhaseSlot").WithValues("name", worker.cr.GetName(), "namespace", worker.cr.GetNamespace(), "phase", phase, "app name", worker.appDeployInfo.AppName)
+
+	scheduler := getAppPhaseScheduler(phase)
+	if scheduler == nil {
+		return true
+	}
+
+	if worker.phaseSlot == nil {
+		worker.phaseSlot = scheduler.requestSlot(getAppPhaseCRKey(worker.cr), getAppFrameworkPriority(worker.afwConfig), getAppFrameworkWeight(worker.afwConfig))
+	}
+
+	select {
+	case <-worker.phaseSlot.ready:
+		return true
+	default:
+		scopedLog.Info("Waiting for an operator wide slot", "priority", worker.phaseSlot.priority)
+		return false
+	}
+}
+
+// releaseAppPhaseSlot releases the operator wide slot held by the worker, if any
+func releaseAppPhaseSlot(worker *PipelineWorker) {
+	if worker.phaseSlot != nil {
+		worker.phaseSlot.release()
+		worker.phaseSlot = nil
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func isAppPhaseSlotGranted(slot *appPhaseSlot) bool {
+	select {
+	case <-slot.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestGetAppFrameworkPriorityAndWeight(t *testing.T) {
+	if getAppFrameworkPriority(nil) != enterpriseApi.AppFrameworkPriorityNormal {
+		t.Errorf("priority should default to normal")
+	}
+
+	afwConfig := &enterpriseApi.AppFrameworkSpec{Priority: enterpriseApi.AppFrameworkPriorityHigh}
+	if getAppFrameworkPriority(afwConfig) != enterpriseApi.AppFrameworkPriorityHigh {
+		t.Errorf("configured priority should be returned")
+	}
+
+	afwConfig.Priority = "urgent"
+	if getAppFrameworkPriority(afwConfig) != enterpriseApi.AppFrameworkPriorityNormal {
+		t.Errorf("unknown priority should be treated as normal")
+	}
+
+	if getAppFrameworkWeight(afwConfig) != 1 {
+		t.Errorf("weight should default to 1")
+	}
+
+	afwConfig.Weight = 5
+	if getAppFrameworkWeight(afwConfig) != 5 {
+		t.Errorf("configured weight should be returned")
+	}
+}
+
+func TestAppPhaseSchedulerPriority(t *testing.T) {
+	scheduler := newAppPhaseScheduler(enterpriseApi.PhaseDownload, 1)
+
+	running := scheduler.requestSlot("Standalone/dev/s1", enterpriseApi.AppFrameworkPriorityLow, 1)
+	if !isAppPhaseSlotGranted(running) {
+		t.Errorf("slot should be granted right away, when there is a free slot")
+	}
+
+	low := scheduler.requestSlot("Standalone/dev/s2", enterpriseApi.AppFrameworkPriorityLow, 1)
+	normal := scheduler.requestSlot("Standalone/qa/s3", enterpriseApi.AppFrameworkPriorityNormal, 1)
+	high := scheduler.requestSlot("SearchHeadCluster/prod/shc", enterpriseApi.AppFrameworkPriorityHigh, 1)
+	if isAppPhaseSlotGranted(low) || isAppPhaseSlotGranted(normal) || isAppPhaseSlotGranted(high) {
+		t.Errorf("slot should not be granted, when all the slots are in use")
+	}
+
+	// requests are served in the order of their priority, irrespective of their arrival
+	running.release()
+	if !isAppPhaseSlotGranted(high) || isAppPhaseSlotGranted(normal) || isAppPhaseSlotGranted(low) {
+		t.Errorf("high priority request should be served first")
+	}
+
+	high.release()
+	if !isAppPhaseSlotGranted(normal) || isAppPhaseSlotGranted(low) {
+		t.Errorf("normal priority request should be served before the low priority request")
+	}
+
+	normal.release()
+	if !isAppPhaseSlotGranted(low) {
+		t.Errorf("low priority request should be served, once there is no other request")
+	}
+
+	// releasing the same slot again should not free up one more slot
+	low.release()
+	low.release()
+	if scheduler.activeSlots != 0 || len(scheduler.waiting) != 0 {
+		t.Errorf("all the slots should be free. active slots: %d, waiting: %d", scheduler.activeSlots, len(scheduler.waiting))
+	}
+}
+
+func TestAppPhaseSchedulerWeightedFairQueuing(t *testing.T) {
+	scheduler := newAppPhaseScheduler(enterpriseApi.PhasePodCopy, 1)
+	running := scheduler.requestSlot("Standalone/default/s0", enterpriseApi.AppFrameworkPriorityNormal, 1)
+
+	// CR a has twice the weight of CR b, and both of them have a big backlog
+	var slots []*appPhaseSlot
+	for i := 0; i < 6; i++ {
+		slots = append(slots, scheduler.requestSlot("Standalone/default/a", enterpriseApi.AppFrameworkPriorityNormal, 2))
+	}
+	for i := 0; i < 6; i++ {
+		slots = append(slots, scheduler.requestSlot("Standalone/default/b", enterpriseApi.AppFrameworkPriorityNormal, 1))
+	}
+
+	grants := map[string]int{}
+	current := running
+	for i := 0; i < 6; i++ {
+		current.release()
+		current = nil
+		for _, slot := range slots {
+			if isAppPhaseSlotGranted(slot) && !slot.released {
+				current = slot
+			}
+		}
+		if current == nil {
+			t.Fatalf("a waiting request should be granted the free slot")
+		}
+		grants[current.crKey]++
+	}
+
+	// even though all the requests of CR a are queued first, CR b gets its share
+	if grants["Standalone/default/a"] != 4 || grants["Standalone/default/b"] != 2 {
+		t.Errorf("slots should be shared as per the weights. grants: %v", grants)
+	}
+
+	// withdrawing the waiting requests frees up the queue
+	for _, slot := range slots {
+		slot.release()
+	}
+	if scheduler.activeSlots != 0 || len(scheduler.waiting) != 0 {
+		t.Errorf("all the slots should be free. active slots: %d, waiting: %d", scheduler.activeSlots, len(scheduler.waiting))
+	}
+}
+
+func TestAcquireAppPhaseSlot(t *testing.T) {
+	ctx := context.TODO()
+	initGlobalResourceTracker()
+	defer initGlobalResourceTracker()
+
+	SetAppPhaseSchedulerLimits(0, 0, 1)
+	if getAppPhaseScheduler(enterpriseApi.PhaseDownload).maxSlots != splcommon.DefaultOperatorMaxAppDownloads {
+		t.Errorf("non-positive limit should not change the default")
+	}
+	if getAppPhaseScheduler(enterpriseApi.PhaseInstall).maxSlots != 1 {
+		t.Errorf("install limit should be updated")
+	}
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.AppFrameworkConfig.Priority = enterpriseApi.AppFrameworkPriorityHigh
+
+	c := spltest.NewMockClient()
+	ppln := initAppInstallPipeline(ctx, &enterpriseApi.AppDeploymentContext{}, c, &cr)
+	worker1 := &PipelineWorker{
+		cr:            &cr,
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz"},
+	}
+	worker2 := &PipelineWorker{
+		cr:            &cr,
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app2.tgz"},
+	}
+
+	if !acquireAppPhaseSlot(ctx, ppln, enterpriseApi.PhaseInstall, worker1) || worker1.phaseSlot == nil {
+		t.Errorf("install slot should be granted")
+	}
+
+	// the only install slot is in use, so the second worker waits until the pipeline is terminated
+	acquired := make(chan bool)
+	go func() {
+		acquired <- acquireAppPhaseSlot(ctx, ppln, enterpriseApi.PhaseInstall, worker2)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	scheduler := getAppPhaseScheduler(enterpriseApi.PhaseInstall)
+	scheduler.mutex.Lock()
+	waiting := len(scheduler.waiting)
+	scheduler.mutex.Unlock()
+	if waiting != 1 {
+		t.Errorf("second worker should be waiting for the install slot")
+	}
+
+	close(ppln.sigTerm)
+	if <-acquired {
+		t.Errorf("slot should not be granted after the pipeline termination")
+	}
+	if worker2.phaseSlot != nil || len(scheduler.waiting) != 0 {
+		t.Errorf("slot request should be withdrawn after the pipeline termination")
+	}
+
+	releaseAppPhaseSlot(worker1)
+	if worker1.phaseSlot != nil || scheduler.activeSlots != 0 {
+		t.Errorf("install slot should be released")
+	}
+}
+
+func TestTryAcquireAppPhaseSlot(t *testing.T) {
+	ctx := context.TODO()
+	initGlobalResourceTracker()
+	defer initGlobalResourceTracker()
+
+	SetAppPhaseSchedulerLimits(0, 0, 1)
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	worker1 := &PipelineWorker{
+		cr:            &cr,
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz"},
+	}
+	worker2 := &PipelineWorker{
+		cr:            &cr,
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app2.tgz"},
+	}
+
+	if !tryAcquireAppPhaseSlot(ctx, enterpriseApi.PhaseInstall, worker1) {
+		t.Errorf("install slot should be granted")
+	}
+
+	// the only install slot is in use, so the second worker doesn't wait, but stays queued
+	scheduler := getAppPhaseScheduler(enterpriseApi.PhaseInstall)
+	if tryAcquireAppPhaseSlot(ctx, enterpriseApi.PhaseInstall, worker2) || tryAcquireAppPhaseSlot(ctx, enterpriseApi.PhaseInstall, worker2) {
+		t.Errorf("install slot should not be granted while it is in use")
+	}
+	if worker2.phaseSlot == nil || len(scheduler.waiting) != 1 {
+		t.Errorf("slot request should stay queued, without being queued again")
+	}
+
+	// the queued request is granted once the slot is free
+	releaseAppPhaseSlot(worker1)
+	if !tryAcquireAppPhaseSlot(ctx, enterpriseApi.PhaseInstall, worker2) || scheduler.activeSlots != 1 {
+		t.Errorf("install slot should be granted to the queued request")
+	}
+
+	releaseAppPhaseSlot(worker2)
+	if scheduler.activeSlots != 0 || len(scheduler.waiting) != 0 {
+		t.Errorf("install slot should be released")
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// the operator image may not have the time zone database
+	_ "time/tzdata"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The app installs can restart Splunk on the pods, and the bundle pushes can trigger rolling restarts of the peers. With
+// the maintenance windows configured, the install phase and the bundle pushes are held outside of the windows, while the
+// downloads and the pod copies go ahead, so that the apps are ready to be installed as soon as the next window starts.
+
+// maxCronScheduleSearchYears is how far the next start of a cron schedule is searched for, e.g. for "0 0 29 2 *"
+const maxCronScheduleSearchYears = 5
+
+// cronField defines the range of values of a cron schedule field
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// cronSchedule is a parsed cron schedule, with a bit set for each of the matching values of the fields
+type cronSchedule struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+
+	// day of month and day of week restrictions. When both are restricted, either of them matches the day
+	dayOfMonthRestricted bool
+	dayOfWeekRestricted  bool
+}
+
+// parseCronField parses a cron schedule field with a comma separated list of values, ranges and steps, e.g. "1-5", "*/15" or "0,30"
+func parseCronField(value string, field cronField) (uint64, bool, error) {
+	var bits uint64
+	restricted := true
+
+	for _, item := range strings.Split(value, ",") {
+		rangeItem, step := item, 1
+		if stepAt := strings.Index(item, "/"); stepAt >= 0 {
+			var err error
+			rangeItem = item[:stepAt]
+			step, err = strconv.Atoi(item[stepAt+1:])
+			if err != nil || step <= 0 {
+				return 0, false, fmt.Errorf("invalid step in the %s field: %s", field.name, item)
+			}
+		}
+
+		start, end := field.min, field.max
+		switch {
+		case rangeItem == "*":
+			if step == 1 {
+				restricted = false
+			}
+		case strings.Contains(rangeItem, "-"):
+			bounds := strings.SplitN(rangeItem, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, false, fmt.Errorf("invalid range in the %s field: %s", field.name, item)
+			}
+		default:
+			var err error
+			start, err = strconv.Atoi(rangeItem)
+			if err != nil {
+				return 0, false, fmt.Errorf("invalid value in the %s field: %s", field.name, item)
+			}
+			// a single value with a step is the start of the range, e.g. "5/15"
+			if step == 1 {
+				end = start
+			}
+		}
+
+		if start < field.min || end > field.max || start > end {
+			return 0, false, fmt.Errorf("out of range value in the %s field: %s", field.name, item)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, restricted, nil
+}
+
+// parseCronSchedule parses a cron schedule with the minute, hour, day of month, month and day of week fields
+func parseCronSchedule(schedule string) (*cronSchedule, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron schedule %q, expected %d fields: minute, hour, day of month, month and day of week", schedule, len(cronFields))
+	}
+
+	var bits [5]uint64
+	var restricted [5]bool
+	for i := range fields {
+		var err error
+		bits[i], restricted[i], err = parseCronField(fields[i], cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron schedule %q, %v", schedule, err)
+		}
+	}
+
+	// both 0 and 7 are sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute:               bits[0],
+		hour:                 bits[1],
+		dayOfMonth:           bits[2],
+		month:                bits[3],
+		dayOfWeek:            bits[4],
+		dayOfMonthRestricted: restricted[2],
+		dayOfWeekRestricted:  restricted[4],
+	}, nil
+}
+
+// matchesDay checks if the day of the time matches the day of month and the day of week fields of the schedule
+func (schedule *cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonthMatch := schedule.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeekMatch := schedule.dayOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if schedule.dayOfMonthRestricted && schedule.dayOfWeekRestricted {
+		return dayOfMonthMatch || dayOfWeekMatch
+	}
+	return dayOfMonthMatch && dayOfWeekMatch
+}
+
+// next returns the first start of the schedule after the given time, in the location of the time. A zero time
+// is returned if the schedule never starts, e.g. for the 31st of February
+func (schedule *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(maxCronScheduleSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if schedule.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !schedule.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if schedule.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if schedule.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// parseMaintenanceWindow parses the schedule and the time zone of the maintenance window
+func parseMaintenanceWindow(window *enterpriseApi.MaintenanceWindowSpec) (*cronSchedule, *time.Location, error) {
+	schedule, err := parseCronSchedule(window.Schedule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loc, err := time.LoadLocation(window.TimeZone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid time zone %q of the maintenance window, error: %v", window.TimeZone, err)
+	}
+
+	if window.DurationSeconds < 60 {
+		return nil, nil, fmt.Errorf("invalid duration %d of the maintenance window %q, it should be at least 60 seconds", window.DurationSeconds, window.Schedule)
+	}
+
+	return schedule, loc, nil
+}
+
+// validateMaintenanceWindows validates the maintenance windows of the App Framework
+func validateMaintenanceWindows(appFramework *enterpriseApi.AppFrameworkSpec) error {
+	for i := range appFramework.MaintenanceWindows {
+		_, _, err := parseMaintenanceWindow(&appFramework.MaintenanceWindows[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getMaintenanceWindowState checks if the time is within any of the maintenance windows. It returns the end of the current
+// window when it is, and the start of the next window otherwise
+func getMaintenanceWindowState(windows []enterpriseApi.MaintenanceWindowSpec, now time.Time) (bool, time.Time, time.Time, error) {
+	var inWindow bool
+	var windowEnd, nextWindowStart time.Time
+
+	for i := range windows {
+		schedule, loc, err := parseMaintenanceWindow(&windows[i])
+		if err != nil {
+			return false, windowEnd, nextWindowStart, err
+		}
+
+		// the first start after the beginning of a window that ends now, is either within the window, or the next start
+		duration := time.Duration(windows[i].DurationSeconds) * time.Second
+		start := schedule.next(now.In(loc).Add(-duration))
+		if start.IsZero() {
+			continue
+		}
+
+		if !start.After(now) {
+			inWindow = true
+			if start.Add(duration).After(windowEnd) {
+				windowEnd = start.Add(duration)
+			}
+		} else if nextWindowStart.IsZero() || start.Before(nextWindowStart) {
+			nextWindowStart = start
+		}
+	}
+
+	return inWindow, windowEnd, nextWindowStart, nil
+}
+
+// isMaintenanceWindowOpen checks if the app installs and the bundle pushes can go ahead now. It is always true,
+// when there are no maintenance windows
+func isMaintenanceWindowOpen(ctx context.Context, appFramework *enterpriseApi.AppFrameworkSpec) bool {
+	if len(appFramework.MaintenanceWindows) == 0 {
+		return true
+	}
+
+	inWindow, _, _, err := getMaintenanceWindowState(appFramework.MaintenanceWindows, time.Now())
+	if err != nil {
+		reqLogger := log.FromContext(ctx)
+		reqLogger.WithName("isMaintenanceWindowOpen").Error(err, "invalid maintenance window")
+		return false
+	}
+
+	return inWindow
+}
+
+// updateMaintenanceWindowStatus updates the state of the maintenance windows in the app deployment context
+func updateMaintenanceWindowStatus(ctx context.Context, appDeployContext *enterpriseApi.AppDeploymentContext, appFramework *enterpriseApi.AppFrameworkSpec) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("updateMaintenanceWindowStatus")
+
+	status := &appDeployContext.MaintenanceWindowStatus
+	*status = enterpriseApi.MaintenanceWindowStatus{}
+	if len(appFramework.MaintenanceWindows) == 0 {
+		return
+	}
+
+	inWindow, windowEnd, nextWindowStart, err := getMaintenanceWindowState(appFramework.MaintenanceWindows, time.Now())
+	switch {
+	case err != nil:
+		scopedLog.Error(err, "invalid maintenance window")
+		status.State = enterpriseApi.MaintenanceWindowStateWaiting
+		status.Error = err.Error()
+	case inWindow:
+		status.State = enterpriseApi.MaintenanceWindowStateOpen
+		status.WindowEnd = windowEnd.Unix()
+	default:
+		status.State = enterpriseApi.MaintenanceWindowStateWaiting
+		if !nextWindowStart.IsZero() {
+			status.NextWindowStart = nextWindowStart.Unix()
+		}
+		scopedLog.Info("Waiting for the maintenance window to install the apps", "nextWindowStart", nextWindowStart)
+	}
+}
+
+// isWaitingForMaintenanceWindow checks if the app installs and the bundle pushes are held till the next maintenance window
+func isWaitingForMaintenanceWindow(appDeployContext *enterpriseApi.AppDeploymentContext) bool {
+	return appDeployContext.MaintenanceWindowStatus.State == enterpriseApi.MaintenanceWindowStateWaiting
+}
+
+// getMaintenanceWindowRequeueTime returns the time till the next maintenance window, when waiting for it
+func getMaintenanceWindowRequeueTime(appDeployContext *enterpriseApi.AppDeploymentContext) time.Duration {
+	status := &appDeployContext.MaintenanceWindowStatus
+	if !isWaitingForMaintenanceWindow(appDeployContext) || status.NextWindowStart == 0 {
+		return 0
+	}
+
+	requeueAfter := time.Until(time.Unix(status.NextWindowStart, 0))
+	if requeueAfter < 5*time.Second {
+		requeueAfter = 5 * time.Second
+	}
+	return requeueAfter
+}
+
+// isOnlyHeldWorkPending checks if all the pending work of the pipeline is held till the next maintenance window
+func (ppln *AppInstallPipeline) isOnlyHeldWorkPending() bool {
+	if !ppln.waitingForMaintenanceWindow || ppln.pplnPhases == nil {
+		return false
+	}
+
+	for _, phase := range []enterpriseApi.AppPhaseType{enterpriseApi.PhaseDownload, enterpriseApi.PhasePodCopy} {
+		if pplnPhase, ok := ppln.pplnPhases[phase]; ok && len(pplnPhase.q) > 0 {
+			return false
+		}
+	}
+
+	// the status of a bundle push in progress is still checked
+	return !isPendingClusterScopeWork(ppln) || getBundlePushState(ppln) == enterpriseApi.BundlePushPending
+}
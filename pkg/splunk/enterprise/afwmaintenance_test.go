@@ -0,0 +1,227 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	validSchedules := []string{"0 2 * * 6", "*/15 * * * *", "0,30 1-5 1 */2 0-7", "5/20 22 * 12 7"}
+	for _, schedule := range validSchedules {
+		_, err := parseCronSchedule(schedule)
+		if err != nil {
+			t.Errorf("valid cron schedule %q failed to parse. error: %v", schedule, err)
+		}
+	}
+
+	invalidSchedules := []string{"", "0 2 * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "0 2 * * 6 *"}
+	for _, schedule := range invalidSchedules {
+		_, err := parseCronSchedule(schedule)
+		if err == nil {
+			t.Errorf("invalid cron schedule %q should fail to parse", schedule)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2022, 3, 10, 13, 20, 30, 0, time.UTC) // thursday
+	tests := []struct {
+		schedule string
+		want     time.Time
+	}{
+		{"*/15 * * * *", time.Date(2022, 3, 10, 13, 30, 0, 0, time.UTC)},
+		{"20 13 * * *", time.Date(2022, 3, 11, 13, 20, 0, 0, time.UTC)},
+		{"0 2 * * 6", time.Date(2022, 3, 12, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * 7", time.Date(2022, 3, 13, 2, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either of the day of month and the day of week matches, when both are restricted
+		{"0 0 20 * 5", time.Date(2022, 3, 11, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		schedule, err := parseCronSchedule(test.schedule)
+		if err != nil {
+			t.Fatalf("unable to parse the cron schedule %q. error: %v", test.schedule, err)
+		}
+		got := schedule.next(from)
+		if !got.Equal(test.want) {
+			t.Errorf("incorrect next start of %q. got: %v, want: %v", test.schedule, got, test.want)
+		}
+	}
+
+	schedule, _ := parseCronSchedule("0 0 31 2 *")
+	if !schedule.next(from).IsZero() {
+		t.Errorf("schedule that never starts should not have a next start")
+	}
+
+	// schedule is in the location of the time
+	loc, _ := time.LoadLocation("America/New_York")
+	schedule, _ = parseCronSchedule("0 2 * * *")
+	got := schedule.next(from.In(loc))
+	if want := time.Date(2022, 3, 11, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("incorrect next start in the time zone. got: %v, want: %v", got, want)
+	}
+}
+
+func TestGetMaintenanceWindowState(t *testing.T) {
+	windows := []enterpriseApi.MaintenanceWindowSpec{
+		{Schedule: "0 2 * * 6", DurationSeconds: 4 * 3600},
+		{Schedule: "0 22 * * 3", DurationSeconds: 3600, TimeZone: "America/New_York"},
+	}
+
+	// saturday 03:00 UTC is within the first window
+	inWindow, windowEnd, _, err := getMaintenanceWindowState(windows, time.Date(2022, 3, 12, 3, 0, 0, 0, time.UTC))
+	if err != nil || !inWindow {
+		t.Errorf("time should be within the maintenance window. error: %v", err)
+	}
+	if want := time.Date(2022, 3, 12, 6, 0, 0, 0, time.UTC); !windowEnd.Equal(want) {
+		t.Errorf("incorrect window end. got: %v, want: %v", windowEnd, want)
+	}
+
+	// the window end is not within the window
+	inWindow, _, nextWindowStart, err := getMaintenanceWindowState(windows, time.Date(2022, 3, 12, 6, 0, 0, 0, time.UTC))
+	if err != nil || inWindow {
+		t.Errorf("time should not be within the maintenance window. error: %v", err)
+	}
+	if want := time.Date(2022, 3, 17, 2, 0, 0, 0, time.UTC); !nextWindowStart.Equal(want) {
+		t.Errorf("incorrect next window start. got: %v, want: %v", nextWindowStart, want)
+	}
+
+	// wednesday 22:30 in New York is within the second window
+	inWindow, _, _, err = getMaintenanceWindowState(windows, time.Date(2022, 3, 17, 2, 30, 0, 0, time.UTC))
+	if err != nil || !inWindow {
+		t.Errorf("time should be within the maintenance window in the time zone. error: %v", err)
+	}
+
+	windows = append(windows, enterpriseApi.MaintenanceWindowSpec{Schedule: "0 2 * * 6", DurationSeconds: 3600, TimeZone: "Mars/Olympus_Mons"})
+	_, _, _, err = getMaintenanceWindowState(windows, time.Now())
+	if err == nil {
+		t.Errorf("invalid time zone should fail")
+	}
+}
+
+func TestValidateMaintenanceWindows(t *testing.T) {
+	appFramework := enterpriseApi.AppFrameworkSpec{
+		MaintenanceWindows: []enterpriseApi.MaintenanceWindowSpec{
+			{Schedule: "0 2 * * 6", DurationSeconds: 3600, TimeZone: "Europe/London"},
+		},
+	}
+	err := validateMaintenanceWindows(&appFramework)
+	if err != nil {
+		t.Errorf("valid maintenance windows should pass the validation. error: %v", err)
+	}
+
+	appFramework.MaintenanceWindows[0].DurationSeconds = 30
+	err = validateMaintenanceWindows(&appFramework)
+	if err == nil {
+		t.Errorf("maintenance window shorter than a minute should fail the validation")
+	}
+
+	appFramework.MaintenanceWindows[0].DurationSeconds = 3600
+	appFramework.MaintenanceWindows[0].Schedule = "0 2 * *"
+	err = validateMaintenanceWindows(&appFramework)
+	if err == nil {
+		t.Errorf("invalid schedule should fail the validation")
+	}
+}
+
+func TestUpdateMaintenanceWindowStatus(t *testing.T) {
+	ctx := context.TODO()
+	appDeployContext := enterpriseApi.AppDeploymentContext{}
+	appFramework := enterpriseApi.AppFrameworkSpec{}
+
+	updateMaintenanceWindowStatus(ctx, &appDeployContext, &appFramework)
+	if appDeployContext.MaintenanceWindowStatus.State != "" || isWaitingForMaintenanceWindow(&appDeployContext) || !isMaintenanceWindowOpen(ctx, &appFramework) {
+		t.Errorf("app installs should not wait, without the maintenance windows")
+	}
+
+	// a window that is always open
+	appFramework.MaintenanceWindows = []enterpriseApi.MaintenanceWindowSpec{{Schedule: "* * * * *", DurationSeconds: 120}}
+	updateMaintenanceWindowStatus(ctx, &appDeployContext, &appFramework)
+	if appDeployContext.MaintenanceWindowStatus.State != enterpriseApi.MaintenanceWindowStateOpen || appDeployContext.MaintenanceWindowStatus.WindowEnd == 0 {
+		t.Errorf("maintenance window should be open. got: %v", appDeployContext.MaintenanceWindowStatus)
+	}
+	if getMaintenanceWindowRequeueTime(&appDeployContext) != 0 || !isMaintenanceWindowOpen(ctx, &appFramework) {
+		t.Errorf("app installs should not wait, within the maintenance window")
+	}
+
+	// a window that never starts
+	appFramework.MaintenanceWindows = []enterpriseApi.MaintenanceWindowSpec{{Schedule: "0 0 31 2 *", DurationSeconds: 120}}
+	updateMaintenanceWindowStatus(ctx, &appDeployContext, &appFramework)
+	if !isWaitingForMaintenanceWindow(&appDeployContext) || appDeployContext.MaintenanceWindowStatus.NextWindowStart != 0 || isMaintenanceWindowOpen(ctx, &appFramework) {
+		t.Errorf("app installs should wait for the maintenance window. got: %v", appDeployContext.MaintenanceWindowStatus)
+	}
+
+	// a window that starts on the next new year
+	appFramework.MaintenanceWindows = []enterpriseApi.MaintenanceWindowSpec{{Schedule: "0 0 1 1 *", DurationSeconds: 60}}
+	updateMaintenanceWindowStatus(ctx, &appDeployContext, &appFramework)
+	if !isWaitingForMaintenanceWindow(&appDeployContext) || appDeployContext.MaintenanceWindowStatus.NextWindowStart == 0 {
+		t.Errorf("next maintenance window start should be set. got: %v", appDeployContext.MaintenanceWindowStatus)
+	}
+	if requeueAfter := getMaintenanceWindowRequeueTime(&appDeployContext); requeueAfter <= 0 || requeueAfter > 366*24*time.Hour {
+		t.Errorf("reconcile should be requeued for the next maintenance window. got: %v", requeueAfter)
+	}
+
+	// invalid window holds the app installs
+	appFramework.MaintenanceWindows = []enterpriseApi.MaintenanceWindowSpec{{Schedule: "0 0 1 1", DurationSeconds: 60}}
+	updateMaintenanceWindowStatus(ctx, &appDeployContext, &appFramework)
+	if !isWaitingForMaintenanceWindow(&appDeployContext) || appDeployContext.MaintenanceWindowStatus.Error == "" {
+		t.Errorf("invalid maintenance window should be reported. got: %v", appDeployContext.MaintenanceWindowStatus)
+	}
+}
+
+func TestIsOnlyHeldWorkPending(t *testing.T) {
+	cr := enterpriseApi.ClusterManager{}
+	cr.Kind = "ClusterManager"
+	appDeployContext := enterpriseApi.AppDeploymentContext{}
+	ppln := &AppInstallPipeline{appDeployContext: &appDeployContext, cr: &cr, pplnPhases: make(map[enterpriseApi.AppPhaseType]*PipelinePhase, 3)}
+	initPipelinePhase(ppln, enterpriseApi.PhaseDownload)
+	initPipelinePhase(ppln, enterpriseApi.PhasePodCopy)
+	initPipelinePhase(ppln, enterpriseApi.PhaseInstall)
+
+	if ppln.isOnlyHeldWorkPending() {
+		t.Errorf("no work is held, when not waiting for the maintenance window")
+	}
+
+	ppln.waitingForMaintenanceWindow = true
+	ppln.pplnPhases[enterpriseApi.PhaseInstall].q = append(ppln.pplnPhases[enterpriseApi.PhaseInstall].q, &PipelineWorker{})
+	if !ppln.isOnlyHeldWorkPending() {
+		t.Errorf("app installs should be held")
+	}
+
+	// downloads and pod copies go ahead
+	ppln.pplnPhases[enterpriseApi.PhasePodCopy].q = append(ppln.pplnPhases[enterpriseApi.PhasePodCopy].q, &PipelineWorker{})
+	if ppln.isOnlyHeldWorkPending() {
+		t.Errorf("pod copies should not be held")
+	}
+	ppln.pplnPhases[enterpriseApi.PhasePodCopy].q = nil
+
+	// pending bundle push is held, while the status of a bundle push in progress is checked
+	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushPending
+	if !ppln.isOnlyHeldWorkPending() || needToRunClusterScopedPlaybook(ppln) {
+		t.Errorf("bundle push should be held")
+	}
+	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushInProgress
+	if ppln.isOnlyHeldWorkPending() {
+		t.Errorf("bundle push in progress should be checked")
+	}
+}
@@ -0,0 +1,706 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	enterpriseApiV3 "github.com/splunk/splunk-operator/api/v3"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The remote storage can notify the Operator about the changes to the app packages, through S3 event notifications,
+// Azure Event Grid or a generic webhook. The App sources matching a notification are marked dirty, and only the CRs
+// owning them are requeued. Polling with appsRepoPollIntervalSeconds continues to work as a fallback.
+
+const (
+	// appSourceNotificationMaxBodySize is the maximum size of a notification request body
+	appSourceNotificationMaxBodySize = 1 << 20
+
+	// appSourceChangeEventsBufferSize is the number of requeue events buffered for each CR kind
+	appSourceChangeEventsBufferSize = 1024
+
+	// appSourceNotificationS3Path receives the S3 event notifications, either directly or through SNS/EventBridge
+	appSourceNotificationS3Path = "/appframework/s3"
+
+	// appSourceNotificationAzurePath receives the Azure Event Grid events
+	appSourceNotificationAzurePath = "/appframework/azure"
+
+	// appSourceNotificationWebhookPath receives the generic webhook notifications
+	appSourceNotificationWebhookPath = "/appframework/webhook"
+)
+
+// appSourceChangeTracker tracks the App sources of each CR reported as changed by the notifications
+type appSourceChangeTracker struct {
+	mutex sync.Mutex
+
+	// sequence orders the notifications, so that a notification received during a check is not lost
+	sequence uint64
+
+	// dirtyAppSources maps the CR to the sequence of the latest notification for each of its App sources
+	dirtyAppSources map[string]map[string]uint64
+}
+
+var appSrcChangeTracker = &appSourceChangeTracker{
+	dirtyAppSources: make(map[string]map[string]uint64),
+}
+
+// appSourceChangeEvents holds the channels used to requeue the CRs of each kind on a notification
+var appSourceChangeEvents = struct {
+	mutex    sync.Mutex
+	channels map[string]chan event.GenericEvent
+}{
+	channels: make(map[string]chan event.GenericEvent),
+}
+
+// GetAppSourceChangeEvents returns the channel of the requeue events for the CRs of the given kind
+func GetAppSourceChangeEvents(kind string) chan event.GenericEvent {
+	appSourceChangeEvents.mutex.Lock()
+	defer appSourceChangeEvents.mutex.Unlock()
+
+	channel, ok := appSourceChangeEvents.channels[kind]
+	if !ok {
+		channel = make(chan event.GenericEvent, appSourceChangeEventsBufferSize)
+		appSourceChangeEvents.channels[kind] = channel
+	}
+
+	return channel
+}
+
+// getAppSourceChangeTrackerKey returns the key of the CR in the app source change tracker
+func getAppSourceChangeTrackerKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// markAppSourcesDirty marks the App sources of the CR as changed on the remote storage
+func markAppSourcesDirty(kind, namespace, name string, appSrcNames []string) {
+	appSrcChangeTracker.mutex.Lock()
+	defer appSrcChangeTracker.mutex.Unlock()
+
+	key := getAppSourceChangeTrackerKey(kind, namespace, name)
+	if appSrcChangeTracker.dirtyAppSources[key] == nil {
+		appSrcChangeTracker.dirtyAppSources[key] = make(map[string]uint64)
+	}
+
+	for _, appSrcName := range appSrcNames {
+		appSrcChangeTracker.sequence++
+		appSrcChangeTracker.dirtyAppSources[key][appSrcName] = appSrcChangeTracker.sequence
+	}
+}
+
+// getDirtyAppSources returns a snapshot of the App sources of the CR marked as changed
+func getDirtyAppSources(cr splcommon.MetaObject) map[string]uint64 {
+	appSrcChangeTracker.mutex.Lock()
+	defer appSrcChangeTracker.mutex.Unlock()
+
+	key := getAppSourceChangeTrackerKey(cr.GetObjectKind().GroupVersionKind().Kind, cr.GetNamespace(), cr.GetName())
+	dirtyAppSources := make(map[string]uint64, len(appSrcChangeTracker.dirtyAppSources[key]))
+	for appSrcName, sequence := range appSrcChangeTracker.dirtyAppSources[key] {
+		dirtyAppSources[appSrcName] = sequence
+	}
+
+	return dirtyAppSources
+}
+
+// clearDirtyAppSources clears the App sources of the snapshot, unless they were marked again after the snapshot
+func clearDirtyAppSources(cr splcommon.MetaObject, dirtyAppSources map[string]uint64) {
+	appSrcChangeTracker.mutex.Lock()
+	defer appSrcChangeTracker.mutex.Unlock()
+
+	key := getAppSourceChangeTrackerKey(cr.GetObjectKind().GroupVersionKind().Kind, cr.GetNamespace(), cr.GetName())
+	for appSrcName, sequence := range dirtyAppSources {
+		if appSrcChangeTracker.dirtyAppSources[key][appSrcName] == sequence {
+			delete(appSrcChangeTracker.dirtyAppSources[key], appSrcName)
+		}
+	}
+
+	if len(appSrcChangeTracker.dirtyAppSources[key]) == 0 {
+		delete(appSrcChangeTracker.dirtyAppSources, key)
+	}
+}
+
+// checkDirtyAppSources gets the apps list only for the App sources marked as changed, and handles the app changes
+func checkDirtyAppSources(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject,
+	appFrameworkConf *enterpriseApi.AppFrameworkSpec, appStatusContext *enterpriseApi.AppDeploymentContext, dirtyAppSources map[string]uint64) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("checkDirtyAppSources").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	if appStatusContext.IsDeploymentInProgress {
+		scopedLog.Info("App installation is already in progress. Not checking the App sources reported as changed")
+		return nil
+	}
+
+	var appSources []enterpriseApi.AppSourceSpec
+	for _, appSource := range appFrameworkConf.AppSources {
+		if _, ok := dirtyAppSources[appSource.Name]; ok {
+			appSources = append(appSources, appSource)
+		}
+	}
+
+	// App sources removed from the config after the notification are handled along with the config change
+	if len(appSources) == 0 {
+		clearDirtyAppSources(cr, dirtyAppSources)
+		return nil
+	}
+
+	scopedLog.Info("Checking status of apps on remote storage for the App sources reported as changed", "App sources", len(appSources))
+
+	sourceToAppsList, err := getAppListFromRemoteBucketForAppSources(ctx, client, cr, appFrameworkConf, appSources)
+	if len(sourceToAppsList) != len(appSources) {
+		// App sources are still marked dirty, so, they are retried in the next reconcile
+		scopedLog.Error(err, "Unable to get apps list, will retry in next reconcile...")
+		return nil
+	}
+
+	appStatusContext.IsDeploymentInProgress = true
+	for _, appSource := range appSources {
+		err = cleanAppSrcObjectDigests(ctx, appSource.Name, sourceToAppsList[appSource.Name])
+		if err != nil {
+			return err
+		}
+
+		_, err = handleAppSrcRepoChanges(ctx, client, cr, appStatusContext, appSource.Name, sourceToAppsList[appSource.Name], appFrameworkConf)
+		if err != nil {
+			scopedLog.Error(err, "Unable to use the App list retrieved from the remote storage", "App source", appSource.Name)
+			return err
+		}
+	}
+
+	clearDirtyAppSources(cr, dirtyAppSources)
+	return nil
+}
+
+// appSourceChangeNotification is a change to an object on the remote storage
+type appSourceChangeNotification struct {
+	// providers the notification applies to. Empty matches any provider
+	providers []string
+
+	// endpointHost is the host of the remote storage, if known
+	endpointHost string
+
+	bucket string
+
+	// key of the changed object. Empty matches every App source of the bucket
+	key string
+}
+
+// matchesAppSource checks if the notification is about an object under the App source location
+func (notification *appSourceChangeNotification) matchesAppSource(vol *enterpriseApi.VolumeSpec, location string) bool {
+	if len(notification.providers) != 0 {
+		var providerMatched bool
+		for _, provider := range notification.providers {
+			if vol.Provider == provider {
+				providerMatched = true
+				break
+			}
+		}
+		if !providerMatched {
+			return false
+		}
+	}
+
+	if notification.endpointHost != "" && vol.Endpoint != "" {
+		endpoint, err := url.Parse(vol.Endpoint)
+		if err == nil && endpoint.Host != "" && !strings.EqualFold(endpoint.Host, notification.endpointHost) {
+			return false
+		}
+	}
+
+	bucket, prefix := getRemoteStorageBucketAndPrefix(vol, location)
+	if bucket != notification.bucket {
+		return false
+	}
+
+	return notification.key == "" || strings.HasPrefix(strings.TrimPrefix(notification.key, "/"), strings.TrimPrefix(prefix, "/"))
+}
+
+// appFrameworkCR is a CR along with its App Framework config
+type appFrameworkCR struct {
+	kind         string
+	cr           client.Object
+	appFramework *enterpriseApi.AppFrameworkSpec
+}
+
+// listAppFrameworkCRs lists the CRs of all the kinds supporting the App Framework
+var listAppFrameworkCRs = func(ctx context.Context, c splcommon.ControllerClient) ([]appFrameworkCR, error) {
+	var crs []appFrameworkCR
+
+	standaloneList := &enterpriseApi.StandaloneList{}
+	if err := c.List(ctx, standaloneList); err != nil {
+		return nil, err
+	}
+	for i := range standaloneList.Items {
+		crs = append(crs, appFrameworkCR{"Standalone", &standaloneList.Items[i], &standaloneList.Items[i].Spec.AppFrameworkConfig})
+	}
+
+	licenseManagerList := &enterpriseApi.LicenseManagerList{}
+	if err := c.List(ctx, licenseManagerList); err != nil {
+		return nil, err
+	}
+	for i := range licenseManagerList.Items {
+		crs = append(crs, appFrameworkCR{"LicenseManager", &licenseManagerList.Items[i], &licenseManagerList.Items[i].Spec.AppFrameworkConfig})
+	}
+
+	licenseMasterList := &enterpriseApiV3.LicenseMasterList{}
+	if err := c.List(ctx, licenseMasterList); err != nil {
+		return nil, err
+	}
+	for i := range licenseMasterList.Items {
+		crs = append(crs, appFrameworkCR{"LicenseMaster", &licenseMasterList.Items[i], &licenseMasterList.Items[i].Spec.AppFrameworkConfig})
+	}
+
+	clusterManagerList := &enterpriseApi.ClusterManagerList{}
+	if err := c.List(ctx, clusterManagerList); err != nil {
+		return nil, err
+	}
+	for i := range clusterManagerList.Items {
+		crs = append(crs, appFrameworkCR{"ClusterManager", &clusterManagerList.Items[i], &clusterManagerList.Items[i].Spec.AppFrameworkConfig})
+	}
+
+	clusterMasterList := &enterpriseApiV3.ClusterMasterList{}
+	if err := c.List(ctx, clusterMasterList); err != nil {
+		return nil, err
+	}
+	for i := range clusterMasterList.Items {
+		crs = append(crs, appFrameworkCR{"ClusterMaster", &clusterMasterList.Items[i], &clusterMasterList.Items[i].Spec.AppFrameworkConfig})
+	}
+
+	searchHeadClusterList := &enterpriseApi.SearchHeadClusterList{}
+	if err := c.List(ctx, searchHeadClusterList); err != nil {
+		return nil, err
+	}
+	for i := range searchHeadClusterList.Items {
+		crs = append(crs, appFrameworkCR{"SearchHeadCluster", &searchHeadClusterList.Items[i], &searchHeadClusterList.Items[i].Spec.AppFrameworkConfig})
+	}
+
+	monitoringConsoleList := &enterpriseApi.MonitoringConsoleList{}
+	if err := c.List(ctx, monitoringConsoleList); err != nil {
+		return nil, err
+	}
+	for i := range monitoringConsoleList.Items {
+		crs = append(crs, appFrameworkCR{"MonitoringConsole", &monitoringConsoleList.Items[i], &monitoringConsoleList.Items[i].Spec.AppFrameworkConfig})
+	}
+
+	return crs, nil
+}
+
+// AppSourceNotificationReceiver receives the remote storage notifications about the app package changes
+type AppSourceNotificationReceiver struct {
+	client      splcommon.ControllerClient
+	bindAddress string
+
+	// token is expected in the Authorization header of every notification
+	token string
+
+	// httpClient is used to confirm the SNS subscriptions
+	httpClient *http.Client
+}
+
+// NewAppSourceNotificationReceiver returns a new notification receiver listening on the given address
+func NewAppSourceNotificationReceiver(client splcommon.ControllerClient, bindAddress string, token string) *AppSourceNotificationReceiver {
+	return &AppSourceNotificationReceiver{
+		client:      client,
+		bindAddress: bindAddress,
+		token:       token,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Start runs the notification receiver till the context is done
+func (receiver *AppSourceNotificationReceiver) Start(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("AppSourceNotificationReceiver").WithValues("bindAddress", receiver.bindAddress)
+
+	if receiver.token == "" {
+		return fmt.Errorf("the App source notification receiver requires a token")
+	}
+
+	server := &http.Server{
+		Addr:              receiver.bindAddress,
+		Handler:           receiver,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	scopedLog.Info("Starting the App source notification receiver")
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// isAuthorized checks the token of the notification request. The token is taken either as a bearer token,
+// or as the basic auth password, as SNS only supports the credentials in the url of the subscription
+func (receiver *AppSourceNotificationReceiver) isAuthorized(r *http.Request) bool {
+	if receiver.token == "" {
+		return false
+	}
+
+	var token string
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	} else if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	if token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(receiver.token)) == 1
+}
+
+// ServeHTTP handles the notification requests
+func (receiver *AppSourceNotificationReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("AppSourceNotificationReceiver").WithValues("path", r.URL.Path)
+
+	if !receiver.isAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Event Grid webhooks with the CloudEvents schema are validated with an OPTIONS request
+	if r.Method == http.MethodOptions && r.URL.Path == appSourceNotificationAzurePath {
+		w.Header().Set("WebHook-Allowed-Origin", r.Header.Get("WebHook-Request-Origin"))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, appSourceNotificationMaxBodySize))
+	if err != nil {
+		http.Error(w, "unable to read the request body", http.StatusBadRequest)
+		return
+	}
+
+	var notifications []appSourceChangeNotification
+	switch r.URL.Path {
+	case appSourceNotificationS3Path:
+		notifications, err = receiver.parseS3Notification(ctx, body)
+	case appSourceNotificationAzurePath:
+		var validationResponse []byte
+		notifications, validationResponse, err = parseAzureEventGridNotification(body)
+		if err == nil && validationResponse != nil {
+			scopedLog.Info("Validated the Event Grid subscription")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(validationResponse)
+			return
+		}
+	case appSourceNotificationWebhookPath:
+		notifications, err = parseWebhookNotification(body)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		scopedLog.Error(err, "unable to parse the notification")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = receiver.handleNotifications(ctx, notifications)
+	if err != nil {
+		scopedLog.Error(err, "unable to handle the notification")
+		http.Error(w, "unable to handle the notification", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleNotifications marks the App sources matching the notifications dirty, and requeues their CRs
+func (receiver *AppSourceNotificationReceiver) handleNotifications(ctx context.Context, notifications []appSourceChangeNotification) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("handleNotifications")
+
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	crs, err := listAppFrameworkCRs(ctx, receiver.client)
+	if err != nil {
+		return err
+	}
+
+	// cached listings of the shared AppSources matching the notifications are dropped, so that the referring CRs list them again
+	changedAppSources := make(map[string]bool)
+	appSourceList := &enterpriseApi.AppSourceList{}
+	err = receiver.client.List(ctx, appSourceList)
+	if err != nil {
+		scopedLog.Error(err, "Unable to list the AppSources")
+	}
+	for i := range appSourceList.Items {
+		appSource := &appSourceList.Items[i]
+		for j := range notifications {
+			if notifications[j].matchesAppSource(&appSource.Spec.Volume, appSource.Spec.Location) {
+				invalidateSharedAppSourceAppsList(appSource.GetNamespace(), appSource.GetName())
+				changedAppSources[getSharedAppSourceKey(appSource.GetNamespace(), appSource.GetName())] = true
+				break
+			}
+		}
+	}
+
+	for _, afwCR := range crs {
+		var appSrcNames []string
+		for _, appSource := range afwCR.appFramework.AppSources {
+			if appSource.AppSourceRef != nil {
+				refNamespace := getAppSourceRefNamespace(afwCR.cr.GetNamespace(), appSource.AppSourceRef)
+				if changedAppSources[getSharedAppSourceKey(refNamespace, appSource.AppSourceRef.Name)] {
+					appSrcNames = append(appSrcNames, appSource.Name)
+				}
+				continue
+			}
+
+			vol, err := splclient.GetAppSrcVolume(ctx, appSource, afwCR.appFramework)
+			if err != nil {
+				continue
+			}
+
+			for i := range notifications {
+				if notifications[i].matchesAppSource(&vol, appSource.Location) {
+					appSrcNames = append(appSrcNames, appSource.Name)
+					break
+				}
+			}
+		}
+
+		if len(appSrcNames) == 0 {
+			continue
+		}
+
+		scopedLog.Info("App source change notified", "kind", afwCR.kind, "name", afwCR.cr.GetName(), "namespace", afwCR.cr.GetNamespace(), "appSources", appSrcNames)
+		markAppSourcesDirty(afwCR.kind, afwCR.cr.GetNamespace(), afwCR.cr.GetName(), appSrcNames)
+
+		select {
+		case GetAppSourceChangeEvents(afwCR.kind) <- event.GenericEvent{Object: afwCR.cr}:
+		default:
+			// App sources stay dirty, so, they are checked in the next reconcile of the CR
+			scopedLog.Info("Requeue events are backed up. Skipping the requeue", "kind", afwCR.kind, "name", afwCR.cr.GetName())
+		}
+	}
+
+	return nil
+}
+
+// s3EventNotification is the S3 event notification message
+type s3EventNotification struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+		S3          struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+
+	// EventBridge delivers the S3 events under detail
+	Source string `json:"source"`
+	Detail struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"detail"`
+}
+
+// snsMessage is the SNS HTTP(S) endpoint message
+type snsMessage struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// parseS3Notification parses the S3 event notification, delivered directly, through SNS or through EventBridge
+func (receiver *AppSourceNotificationReceiver) parseS3Notification(ctx context.Context, body []byte) ([]appSourceChangeNotification, error) {
+	var message snsMessage
+	err := json.Unmarshal(body, &message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 event notification: %v", err)
+	}
+
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		return nil, receiver.confirmSNSSubscription(ctx, message.SubscribeURL)
+	case "Notification":
+		body = []byte(message.Message)
+	}
+
+	var s3Event s3EventNotification
+	err = json.Unmarshal(body, &s3Event)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 event notification: %v", err)
+	}
+
+	s3Providers := []string{"aws", "minio"}
+
+	var notifications []appSourceChangeNotification
+	for _, record := range s3Event.Records {
+		// keys are URL encoded in the S3 event notifications
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object key in S3 event notification: %s", record.S3.Object.Key)
+		}
+		notifications = append(notifications, appSourceChangeNotification{providers: s3Providers, bucket: record.S3.Bucket.Name, key: key})
+	}
+
+	if s3Event.Source == "aws.s3" && s3Event.Detail.Bucket.Name != "" {
+		notifications = append(notifications, appSourceChangeNotification{providers: s3Providers, bucket: s3Event.Detail.Bucket.Name, key: s3Event.Detail.Object.Key})
+	}
+
+	// S3 sends a test event without any records when the notification is configured
+	return notifications, nil
+}
+
+// confirmSNSSubscription confirms the SNS subscription of the receiver
+func (receiver *AppSourceNotificationReceiver) confirmSNSSubscription(ctx context.Context, subscribeURL string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("confirmSNSSubscription")
+
+	// only visit the SNS endpoints
+	parsedURL, err := url.Parse(subscribeURL)
+	if err != nil || parsedURL.Scheme != "https" || !strings.HasPrefix(parsedURL.Hostname(), "sns.") || !strings.HasSuffix(parsedURL.Hostname(), ".amazonaws.com") {
+		return fmt.Errorf("invalid SNS subscribe URL: %s", subscribeURL)
+	}
+
+	resp, err := receiver.httpClient.Get(subscribeURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to confirm the SNS subscription, status code: %d", resp.StatusCode)
+	}
+
+	scopedLog.Info("Confirmed the SNS subscription")
+	return nil
+}
+
+// eventGridEvent is an Event Grid event, either with the Event Grid schema or with the CloudEvents schema
+type eventGridEvent struct {
+	EventType string `json:"eventType"`
+	Type      string `json:"type"`
+	Data      struct {
+		URL            string `json:"url"`
+		ValidationCode string `json:"validationCode"`
+	} `json:"data"`
+}
+
+// parseAzureEventGridNotification parses the Event Grid events. For the subscription validation event,
+// the validation response is returned
+func parseAzureEventGridNotification(body []byte) ([]appSourceChangeNotification, []byte, error) {
+	var events []eventGridEvent
+
+	body = bytes.TrimSpace(body)
+	if len(body) != 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &events); err != nil {
+			return nil, nil, fmt.Errorf("invalid Event Grid notification: %v", err)
+		}
+	} else {
+		var gridEvent eventGridEvent
+		if err := json.Unmarshal(body, &gridEvent); err != nil {
+			return nil, nil, fmt.Errorf("invalid Event Grid notification: %v", err)
+		}
+		events = append(events, gridEvent)
+	}
+
+	var notifications []appSourceChangeNotification
+	for _, gridEvent := range events {
+		if gridEvent.EventType == "Microsoft.EventGrid.SubscriptionValidationEvent" {
+			validationResponse, err := json.Marshal(map[string]string{"validationResponse": gridEvent.Data.ValidationCode})
+			return nil, validationResponse, err
+		}
+
+		if gridEvent.Data.URL == "" {
+			continue
+		}
+
+		// blob URL is of the form https://<account>.blob.core.windows.net/<container>/<blob>
+		blobURL, err := url.Parse(gridEvent.Data.URL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid blob url in Event Grid notification: %s", gridEvent.Data.URL)
+		}
+
+		blobPath := strings.SplitN(strings.TrimPrefix(blobURL.Path, "/"), "/", 2)
+		notification := appSourceChangeNotification{
+			providers:    []string{"azure"},
+			endpointHost: blobURL.Host,
+			bucket:       blobPath[0],
+		}
+		if len(blobPath) == 2 {
+			notification.key = blobPath[1]
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil, nil
+}
+
+// webhookNotification is the generic webhook notification
+type webhookNotification struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key,omitempty"`
+}
+
+// parseWebhookNotification parses the generic webhook notification, with the bucket(or the first element of the volume path)
+// and optionally the key of the changed object
+func parseWebhookNotification(body []byte) ([]appSourceChangeNotification, error) {
+	var webhook webhookNotification
+	err := json.Unmarshal(body, &webhook)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook notification: %v", err)
+	}
+
+	if webhook.Bucket == "" {
+		return nil, fmt.Errorf("bucket is missing in webhook notification")
+	}
+
+	return []appSourceChangeNotification{{bucket: webhook.Bucket, key: webhook.Key}}, nil
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApiV3 "github.com/splunk/splunk-operator/api/v3"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getAppSourceNotificationTestStandalone(name string, vol enterpriseApi.VolumeSpec) *enterpriseApi.Standalone {
+	return &enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.StandaloneSpec{
+			AppFrameworkConfig: enterpriseApi.AppFrameworkSpec{
+				Defaults: enterpriseApi.AppSourceDefaultSpec{
+					VolName: vol.Name,
+					Scope:   enterpriseApi.ScopeLocal,
+				},
+				VolList: []enterpriseApi.VolumeSpec{vol},
+				AppSources: []enterpriseApi.AppSourceSpec{
+					{Name: "adminApps", Location: "adminAppsRepo"},
+					{Name: "securityApps", Location: "securityAppsRepo"},
+				},
+			},
+		},
+	}
+}
+
+// drainAppSourceChangeEvents returns the names of the CRs requeued for the given kind
+func drainAppSourceChangeEvents(kind string) []string {
+	var names []string
+	for {
+		select {
+		case genericEvent := <-GetAppSourceChangeEvents(kind):
+			names = append(names, genericEvent.Object.GetName())
+		default:
+			return names
+		}
+	}
+}
+
+func TestAppSourceChangeTracker(t *testing.T) {
+	cr := getAppSourceNotificationTestStandalone("tracker", enterpriseApi.VolumeSpec{Name: "vol1"})
+
+	markAppSourcesDirty("Standalone", "test", "tracker", []string{"adminApps", "securityApps"})
+	dirtyAppSources := getDirtyAppSources(cr)
+	if len(dirtyAppSources) != 2 {
+		t.Errorf("Expected 2 dirty App sources, got: %d", len(dirtyAppSources))
+	}
+
+	// notification received while the App sources are being checked
+	markAppSourcesDirty("Standalone", "test", "tracker", []string{"securityApps"})
+	clearDirtyAppSources(cr, dirtyAppSources)
+
+	dirtyAppSources = getDirtyAppSources(cr)
+	if _, ok := dirtyAppSources["securityApps"]; !ok || len(dirtyAppSources) != 1 {
+		t.Errorf("App source notified during the check should stay dirty, got: %v", dirtyAppSources)
+	}
+
+	clearDirtyAppSources(cr, dirtyAppSources)
+	if len(getDirtyAppSources(cr)) != 0 {
+		t.Errorf("All the App sources should have been cleared")
+	}
+}
+
+func TestAppSourceNotificationReceiver(t *testing.T) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(enterpriseApi.AddToScheme(scheme))
+	utilruntime.Must(enterpriseApiV3.AddToScheme(scheme))
+
+	s3Standalone := getAppSourceNotificationTestStandalone("s3", enterpriseApi.VolumeSpec{Name: "vol1", Path: "bucket1/apps", Provider: "aws", Endpoint: "https://s3-us-west-2.amazonaws.com"})
+	azureStandalone := getAppSourceNotificationTestStandalone("azure", enterpriseApi.VolumeSpec{Name: "vol1", Path: "container1", Provider: "azure", Endpoint: "https://account1.blob.core.windows.net"})
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(s3Standalone, azureStandalone).Build()
+
+	receiver := NewAppSourceNotificationReceiver(c, ":0", "secret")
+	drainAppSourceChangeEvents("Standalone")
+	defer clearDirtyAppSources(s3Standalone, getDirtyAppSources(s3Standalone))
+	defer clearDirtyAppSources(azureStandalone, getDirtyAppSources(azureStandalone))
+
+	post := func(path string, body string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		receiver.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Missing or invalid token
+	if recorder := post(appSourceNotificationWebhookPath, `{"bucket": "bucket1"}`, ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusUnauthorized, recorder.Code)
+	}
+	if recorder := post(appSourceNotificationWebhookPath, `{"bucket": "bucket1"}`, "invalid"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusUnauthorized, recorder.Code)
+	}
+
+	// S3 event notification marks only the matching App source dirty
+	s3Event := `{"Records": [{"eventSource": "aws:s3", "s3": {"bucket": {"name": "bucket1"}, "object": {"key": "apps/adminAppsRepo/app1.tgz"}}}]}`
+	if recorder := post(appSourceNotificationS3Path, s3Event, "secret"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	dirtyAppSources := getDirtyAppSources(s3Standalone)
+	if _, ok := dirtyAppSources["adminApps"]; !ok || len(dirtyAppSources) != 1 {
+		t.Errorf("Only adminApps should have been marked dirty, got: %v", dirtyAppSources)
+	}
+	if len(getDirtyAppSources(azureStandalone)) != 0 {
+		t.Errorf("CR on a different provider should not have been marked dirty")
+	}
+	if names := drainAppSourceChangeEvents("Standalone"); len(names) != 1 || names[0] != "s3" {
+		t.Errorf("Only the CR owning the App source should have been requeued, got: %v", names)
+	}
+
+	// S3 event notification delivered through SNS, with the token as the basic auth password
+	message, _ := json.Marshal(map[string]string{
+		"Type":    "Notification",
+		"Message": strings.Replace(s3Event, "apps/adminAppsRepo/app1.tgz", "apps/securityAppsRepo/app%2B2.tgz", 1),
+	})
+	if recorder := post(appSourceNotificationS3Path+"?token=secret", string(message), ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Token should not be accepted as a query parameter, got: %d", recorder.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, appSourceNotificationS3Path, strings.NewReader(string(message)))
+	req.SetBasicAuth("sns", "secret")
+	recorder := httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	if _, ok := getDirtyAppSources(s3Standalone)["securityApps"]; !ok {
+		t.Errorf("securityApps should have been marked dirty through SNS")
+	}
+
+	// Object outside the App source locations
+	drainAppSourceChangeEvents("Standalone")
+	if recorder := post(appSourceNotificationS3Path, strings.Replace(s3Event, "apps/adminAppsRepo", "other", 1), "secret"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	if names := drainAppSourceChangeEvents("Standalone"); len(names) != 0 {
+		t.Errorf("No CR should have been requeued, got: %v", names)
+	}
+
+	// Event Grid subscription validation
+	recorder = post(appSourceNotificationAzurePath, `[{"eventType": "Microsoft.EventGrid.SubscriptionValidationEvent", "data": {"validationCode": "code1"}}]`, "secret")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"validationResponse":"code1"`) {
+		t.Errorf("Event Grid subscription should have been validated, got: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Event Grid blob event
+	blobEvent := `[{"eventType": "Microsoft.Storage.BlobCreated", "data": {"url": "https://account1.blob.core.windows.net/container1/adminAppsRepo/app1.tgz"}}]`
+	if recorder := post(appSourceNotificationAzurePath, blobEvent, "secret"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	if _, ok := getDirtyAppSources(azureStandalone)["adminApps"]; !ok {
+		t.Errorf("adminApps should have been marked dirty through Event Grid")
+	}
+
+	// Blob event from a different storage account
+	clearDirtyAppSources(azureStandalone, getDirtyAppSources(azureStandalone))
+	post(appSourceNotificationAzurePath, strings.Replace(blobEvent, "account1", "account2", 1), "secret")
+	if len(getDirtyAppSources(azureStandalone)) != 0 {
+		t.Errorf("Blob event from a different storage account should not have marked the App source dirty")
+	}
+
+	// Generic webhook without the key marks all the App sources of the bucket
+	clearDirtyAppSources(s3Standalone, getDirtyAppSources(s3Standalone))
+	if recorder := post(appSourceNotificationWebhookPath, `{"bucket": "bucket1"}`, "secret"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	if len(getDirtyAppSources(s3Standalone)) != 2 {
+		t.Errorf("All the App sources of the bucket should have been marked dirty")
+	}
+
+	// Invalid notifications
+	if recorder := post(appSourceNotificationWebhookPath, `{"key": "app1.tgz"}`, "secret"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusBadRequest, recorder.Code)
+	}
+	if recorder := post(appSourceNotificationS3Path, `not json`, "secret"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusBadRequest, recorder.Code)
+	}
+	if recorder := post("/unknown", `{}`, "secret"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusNotFound, recorder.Code)
+	}
+
+	// SNS subscription confirmation is only sent to the SNS endpoints
+	confirmation := `{"Type": "SubscriptionConfirmation", "SubscribeURL": "http://127.0.0.1/confirm"}`
+	if recorder := post(appSourceNotificationS3Path, confirmation, "secret"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	// Receiver without a token rejects every notification
+	receiver = NewAppSourceNotificationReceiver(c, ":0", "")
+	if recorder := post(appSourceNotificationWebhookPath, `{"bucket": "bucket1"}`, ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: %d, got: %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestAppSourceNotificationReceiverStart(t *testing.T) {
+	// Receiver is not started without a token
+	receiver := NewAppSourceNotificationReceiver(spltest.NewMockClient(), "127.0.0.1:0", "")
+	if err := receiver.Start(context.TODO()); err == nil {
+		t.Errorf("Start should have returned error without a token")
+	}
+
+	receiver = NewAppSourceNotificationReceiver(spltest.NewMockClient(), "127.0.0.1:0", "secret")
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() {
+		done <- receiver.Start(ctx)
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start should not have returned error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("receiver should have been stopped")
+	}
+}
+
+func TestCheckDirtyAppSources(t *testing.T) {
+	ctx := context.TODO()
+	client := spltest.NewMockClient()
+	cr := getAppSourceNotificationTestStandalone("dirty", enterpriseApi.VolumeSpec{Name: "vol1", Path: "bucket1", Provider: "aws"})
+	appFrameworkConf := &cr.Spec.AppFrameworkConfig
+
+	savedGetAppsList := GetAppsList
+	defer func() { GetAppsList = savedGetAppsList }()
+
+	var listedLocations []string
+	GetAppsList = func(ctx context.Context, remoteDataClientMgr RemoteDataClientManager) (splclient.RemoteDataListResponse, error) {
+		listedLocations = append(listedLocations, remoteDataClientMgr.location)
+		if remoteDataClientMgr.location == "failingRepo" {
+			return splclient.RemoteDataListResponse{}, fmt.Errorf("dummy error")
+		}
+		etag := "\"abcd2222\""
+		key := remoteDataClientMgr.location + "/app1.tgz"
+		size := int64(10)
+		lastModified := time.Now()
+		return splclient.RemoteDataListResponse{Objects: []*splclient.RemoteObject{{Key: &key, Etag: &etag, Size: &size, LastModified: &lastModified}}}, nil
+	}
+
+	installed := enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallComplete}
+	appStatusContext := &enterpriseApi.AppDeploymentContext{
+		AppsSrcDeployStatus: map[string]enterpriseApi.AppSrcDeployInfo{
+			"adminApps": {
+				AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+					{AppName: "app1.tgz", ObjectHash: "abcd1111", RepoState: enterpriseApi.RepoStateActive, DeployStatus: enterpriseApi.DeployStatusComplete, PhaseInfo: installed},
+				},
+			},
+			"securityApps": {
+				AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+					{AppName: "app1.tgz", ObjectHash: "abcd1111", RepoState: enterpriseApi.RepoStateActive, DeployStatus: enterpriseApi.DeployStatusComplete, PhaseInfo: installed},
+				},
+			},
+		},
+	}
+
+	markAppSourcesDirty("Standalone", "test", "dirty", []string{"securityApps"})
+	err := checkDirtyAppSources(ctx, client, cr, appFrameworkConf, appStatusContext, getDirtyAppSources(cr))
+	if err != nil {
+		t.Errorf("checkDirtyAppSources should not have returned error: %v", err)
+	}
+	if len(listedLocations) != 1 || listedLocations[0] != "securityAppsRepo" {
+		t.Errorf("Only the dirty App source should have been listed, got: %v", listedLocations)
+	}
+	if !appStatusContext.AppsSrcDeployStatus["securityApps"].AppDeploymentInfoList[0].IsUpdate {
+		t.Errorf("Updated app of the dirty App source should have been marked for an update")
+	}
+	if appStatusContext.AppsSrcDeployStatus["adminApps"].AppDeploymentInfoList[0].IsUpdate ||
+		appStatusContext.AppsSrcDeployStatus["adminApps"].AppDeploymentInfoList[0].RepoState != enterpriseApi.RepoStateActive {
+		t.Errorf("App source that is not dirty should not have been modified")
+	}
+	if !appStatusContext.IsDeploymentInProgress || len(getDirtyAppSources(cr)) != 0 {
+		t.Errorf("Deployment should be in progress and the dirty App sources should have been cleared")
+	}
+
+	// Dirty App sources are not checked while the deployment is in progress
+	listedLocations = nil
+	markAppSourcesDirty("Standalone", "test", "dirty", []string{"adminApps"})
+	checkDirtyAppSources(ctx, client, cr, appFrameworkConf, appStatusContext, getDirtyAppSources(cr))
+	if len(listedLocations) != 0 || len(getDirtyAppSources(cr)) != 1 {
+		t.Errorf("Dirty App sources should not have been checked while the deployment is in progress")
+	}
+
+	// Listing failure keeps the App source dirty
+	appStatusContext.IsDeploymentInProgress = false
+	appFrameworkConf.AppSources[0].Location = "failingRepo"
+	err = checkDirtyAppSources(ctx, client, cr, appFrameworkConf, appStatusContext, getDirtyAppSources(cr))
+	if err != nil || appStatusContext.IsDeploymentInProgress || len(getDirtyAppSources(cr)) != 1 {
+		t.Errorf("App source should stay dirty on a listing failure, err: %v", err)
+	}
+
+	// App source removed from the config is cleared
+	appFrameworkConf.AppSources = appFrameworkConf.AppSources[1:]
+	checkDirtyAppSources(ctx, client, cr, appFrameworkConf, appStatusContext, getDirtyAppSources(cr))
+	if len(getDirtyAppSources(cr)) != 0 {
+		t.Errorf("App source removed from the config should have been cleared")
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splctrl "github.com/splunk/splunk-operator/pkg/splunk/controller"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// appConfigOverlayFileSuffix is the suffix of the app package with the configuration overlay, on the Operator Pod
+const appConfigOverlayFileSuffix = ".overlay"
+
+// getAppConfigOverlayConfigMapName returns the ConfigMap holding the configuration overlay for the app, if any
+func getAppConfigOverlayConfigMapName(appFramework *enterpriseApi.AppFrameworkSpec, appSrcName string, appName string) string {
+	appSrcSpec, err := getAppSrcSpec(appFramework.AppSources, appSrcName)
+	if err != nil {
+		return ""
+	}
+
+	for _, appConfigOverlay := range appSrcSpec.AppConfigOverlays {
+		if appConfigOverlay.AppName == appName {
+			return appConfigOverlay.ConfigMapName
+		}
+	}
+
+	return ""
+}
+
+// validateAppConfigOverlays validates the app config overlays of the app sources
+func validateAppConfigOverlays(appFramework *enterpriseApi.AppFrameworkSpec) error {
+	for _, appSrc := range appFramework.AppSources {
+		duplicateAppChecker := make(map[string]bool)
+		for _, appConfigOverlay := range appSrc.AppConfigOverlays {
+			if appConfigOverlay.AppName == "" || appConfigOverlay.ConfigMapName == "" {
+				return fmt.Errorf("app name and configMap name are required in appConfigOverlays for App Source: %s", appSrc.Name)
+			}
+			if duplicateAppChecker[appConfigOverlay.AppName] {
+				return fmt.Errorf("app %s is listed more than once in appConfigOverlays for App Source: %s", appConfigOverlay.AppName, appSrc.Name)
+			}
+			duplicateAppChecker[appConfigOverlay.AppName] = true
+		}
+	}
+
+	return nil
+}
+
+// getAppConfigOverlayHash returns the hash of the files in the configuration overlay ConfigMap
+func getAppConfigOverlayHash(configMap *corev1.ConfigMap) string {
+	fileNames := make([]string, 0, len(configMap.Data))
+	for fileName := range configMap.Data {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	hash := sha256.New()
+	for _, fileName := range fileNames {
+		fmt.Fprintf(hash, "%s\x00%s\x00", fileName, configMap.Data[fileName])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// getAppConfigOverlay fetches the configuration overlay ConfigMap of the app
+func getAppConfigOverlay(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, configMapName string) (*corev1.ConfigMap, error) {
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: configMapName}
+	configMap, err := splctrl.GetConfigMap(ctx, client, namespacedName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the app config overlay ConfigMap: %s, error: %w", configMapName, err)
+	}
+
+	return configMap, nil
+}
+
+// getAppConfigOverlayMissingError returns the error reported for the app, when its configuration overlay ConfigMap is missing
+func getAppConfigOverlayMissingError(configMapName string) string {
+	return fmt.Sprintf("app config overlay ConfigMap: %s is not found, skipping the overlay", configMapName)
+}
+
+// markAppDeployInfoForUpdate resets the app deployment info, so that the app is installed again through the pipeline
+func markAppDeployInfoForUpdate(appDeployInfo *enterpriseApi.AppDeploymentInfo) {
+	appDeployInfo.IsUpdate = true
+	appDeployInfo.DeployStatus = enterpriseApi.DeployStatusPending
+	appDeployInfo.PhaseInfo.Phase = enterpriseApi.PhaseDownload
+	appDeployInfo.PhaseInfo.Status = enterpriseApi.AppPkgDownloadPending
+	appDeployInfo.PhaseInfo.FailCount = 0
+	appDeployInfo.AuxPhaseInfo = nil
+}
+
+// updateAppConfigOverlayHashes records the hash of the configuration overlay for the apps of the app source,
+// and marks the apps already deployed with a different overlay for an update. A missing overlay ConfigMap is
+// skipped and reported in the status of the app, which keeps the overlay it was deployed with.
+func updateAppConfigOverlayHashes(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appFramework *enterpriseApi.AppFrameworkSpec, appSrcName string, appSrcDeploymentInfo *enterpriseApi.AppSrcDeployInfo) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("updateAppConfigOverlayHashes").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "appSrcName", appSrcName)
+
+	var appChangesDetected bool
+	appList := appSrcDeploymentInfo.AppDeploymentInfoList
+	for idx := range appList {
+		if appList[idx].RepoState != enterpriseApi.RepoStateActive {
+			continue
+		}
+
+		var overlayHash string
+		configMapName := getAppConfigOverlayConfigMapName(appFramework, appSrcName, appList[idx].AppName)
+		if configMapName != "" {
+			configMap, err := getAppConfigOverlay(ctx, client, cr, configMapName)
+			if k8serrors.IsNotFound(err) {
+				scopedLog.Info("App config overlay ConfigMap not found, skipping it", "appName", appList[idx].AppName, "configMapName", configMapName)
+				appList[idx].LastError = getAppConfigOverlayMissingError(configMapName)
+				continue
+			}
+			if err != nil {
+				return appChangesDetected, err
+			}
+			overlayHash = getAppConfigOverlayHash(configMap)
+
+			if appList[idx].LastError == getAppConfigOverlayMissingError(configMapName) {
+				appList[idx].LastError = ""
+			}
+		}
+
+		if appList[idx].OverlayHash == overlayHash {
+			continue
+		}
+
+		// New and updated apps are already waiting to be installed with the latest overlay
+		if appList[idx].DeployStatus == enterpriseApi.DeployStatusPending && appList[idx].PhaseInfo.Phase == enterpriseApi.PhaseDownload {
+			appList[idx].OverlayHash = overlayHash
+			continue
+		}
+
+		scopedLog.Info("App config overlay change detected. Marking for an update.", "appName", appList[idx].AppName, "configMapName", configMapName)
+		appList[idx].OverlayHash = overlayHash
+		markAppDeployInfoForUpdate(&appList[idx])
+		appChangesDetected = true
+	}
+
+	return appChangesDetected, nil
+}
+
+// getAppPkgTopFolder returns the top folder of the app package, from the name of a tar entry
+func getAppPkgTopFolder(entryName string) string {
+	entryName = strings.TrimPrefix(path.Clean(entryName), "./")
+	return strings.SplitN(entryName, "/", 2)[0]
+}
+
+// createAppPkgWithConfigOverlay creates a copy of the app package with the configuration overlay files added
+// to the local/ folder of the app, and returns the path of the copy
+func createAppPkgWithConfigOverlay(ctx context.Context, appPkgLocalPath string, configMap *corev1.ConfigMap) (string, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("createAppPkgWithConfigOverlay").WithValues("appPkgLocalPath", appPkgLocalPath, "configMapName", configMap.GetName())
+
+	srcFile, err := os.Open(appPkgLocalPath)
+	if err != nil {
+		return "", err
+	}
+	defer srcFile.Close()
+
+	gzipReader, err := gzip.NewReader(srcFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the app package %s, error: %v", appPkgLocalPath, err)
+	}
+	defer gzipReader.Close()
+
+	// pod copy workers of different pods may apply the overlay at the same time
+	dstFile, err := os.CreateTemp(filepath.Dir(appPkgLocalPath), filepath.Base(appPkgLocalPath)+"_*"+appConfigOverlayFileSuffix)
+	if err != nil {
+		return "", err
+	}
+	defer dstFile.Close()
+	overlayPkgPath := dstFile.Name()
+
+	gzipWriter := gzip.NewWriter(dstFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = writeAppPkgWithConfigOverlay(tar.NewReader(gzipReader), tarWriter, configMap)
+	if err == nil {
+		err = tarWriter.Close()
+	}
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	if err != nil {
+		scopedLog.Error(err, "unable to add the config overlay to the app package")
+		os.Remove(overlayPkgPath)
+		return "", err
+	}
+
+	scopedLog.Info("Added the config overlay to the app package", "overlayPkgPath", overlayPkgPath)
+	return overlayPkgPath, nil
+}
+
+// writeAppPkgWithConfigOverlay copies the app package entries, replacing the local/ files present in the overlay
+func writeAppPkgWithConfigOverlay(tarReader *tar.Reader, tarWriter *tar.Writer, configMap *corev1.ConfigMap) error {
+	var topFolder string
+	var localDirFound bool
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		entryName := strings.TrimPrefix(path.Clean(header.Name), "./")
+		if topFolder == "" {
+			topFolder = getAppPkgTopFolder(entryName)
+		}
+
+		if entryName == path.Join(topFolder, "local") {
+			localDirFound = true
+		}
+
+		// overlay files take precedence over the local/ files shipped with the app package
+		if _, ok := configMap.Data[path.Base(entryName)]; ok && path.Dir(entryName) == path.Join(topFolder, "local") {
+			continue
+		}
+
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tarWriter, tarReader)
+		if err != nil {
+			return err
+		}
+	}
+
+	if topFolder == "" || topFolder == "." || topFolder == ".." {
+		return fmt.Errorf("unable to find the top folder of the app package")
+	}
+
+	modTime := time.Now()
+	if !localDirFound {
+		err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     path.Join(topFolder, "local") + "/",
+			Mode:     0755,
+			ModTime:  modTime,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fileNames := make([]string, 0, len(configMap.Data))
+	for fileName := range configMap.Data {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		contents := configMap.Data[fileName]
+		err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path.Join(topFolder, "local", fileName),
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			ModTime:  modTime,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(tarWriter, contents)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getAppPkgForPodCopy returns the app package to be copied to the pod, with the configuration overlay applied, if any
+func getAppPkgForPodCopy(ctx context.Context, worker *PipelineWorker, appPkgLocalPath string) (string, error) {
+	configMapName := getAppConfigOverlayConfigMapName(worker.afwConfig, worker.appSrcName, worker.appDeployInfo.AppName)
+	if configMapName == "" {
+		return appPkgLocalPath, nil
+	}
+
+	configMap, err := getAppConfigOverlay(ctx, worker.client, worker.cr, configMapName)
+	// the app is installed without the overlay, when it was missing already while checking the apps
+	if k8serrors.IsNotFound(err) && worker.appDeployInfo.OverlayHash == "" {
+		setAppDeployError(worker.appDeployInfo, fmt.Errorf("%s", getAppConfigOverlayMissingError(configMapName)))
+		return appPkgLocalPath, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return createAppPkgWithConfigOverlay(ctx, appPkgLocalPath, configMap)
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// createTestAppPkg creates a gzipped tarball with the given files
+func createTestAppPkg(t *testing.T, appPkgPath string, files map[string]string) {
+	file, err := os.Create(appPkgPath)
+	if err != nil {
+		t.Fatalf("unable to create the app package: %v", err)
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range []string{"app1/default/app.conf", "app1/local/inputs.conf", "app1/local/props.conf"} {
+		contents, ok := files[name]
+		if !ok {
+			continue
+		}
+		tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(contents))})
+		io.WriteString(tarWriter, contents)
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+}
+
+// readTestAppPkg returns the files in a gzipped tarball
+func readTestAppPkg(t *testing.T, appPkgPath string) map[string]string {
+	file, err := os.Open(appPkgPath)
+	if err != nil {
+		t.Fatalf("unable to open the app package: %v", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("unable to read the app package: %v", err)
+	}
+
+	files := make(map[string]string)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unable to read the app package: %v", err)
+		}
+		contents, _ := io.ReadAll(tarReader)
+		files[header.Name] = string(contents)
+	}
+	return files
+}
+
+func TestCreateAppPkgWithConfigOverlay(t *testing.T) {
+	ctx := context.TODO()
+	appPkgPath := filepath.Join(t.TempDir(), "app1.tgz_abcd1111")
+	createTestAppPkg(t, appPkgPath, map[string]string{
+		"app1/default/app.conf":  "[launcher]\n",
+		"app1/local/inputs.conf": "[vendor]\n",
+		"app1/local/props.conf":  "[vendor]\n",
+	})
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app1-overlay"},
+		Data: map[string]string{
+			"inputs.conf":  "[monitor:///var/log]\nindex = prod\n",
+			"outputs.conf": "[tcpout]\n",
+		},
+	}
+
+	overlayPkgPath, err := createAppPkgWithConfigOverlay(ctx, appPkgPath, configMap)
+	if err != nil {
+		t.Fatalf("createAppPkgWithConfigOverlay should not have returned error: %v", err)
+	}
+	if overlayPkgPath == appPkgPath {
+		t.Errorf("app package on the operator should not be modified")
+	}
+
+	files := readTestAppPkg(t, overlayPkgPath)
+	if files["app1/local/inputs.conf"] != configMap.Data["inputs.conf"] {
+		t.Errorf("overlay file should have replaced the local file of the app package")
+	}
+	if files["app1/local/outputs.conf"] != configMap.Data["outputs.conf"] {
+		t.Errorf("overlay file should have been added to the app package")
+	}
+	if files["app1/local/props.conf"] != "[vendor]\n" || files["app1/default/app.conf"] != "[launcher]\n" {
+		t.Errorf("other files of the app package should not be modified")
+	}
+	if _, ok := files["app1/local/"]; !ok {
+		t.Errorf("local folder should have been added to the app package")
+	}
+
+	// Invalid app package
+	err = os.WriteFile(appPkgPath, []byte("not a tarball"), 0644)
+	if err != nil {
+		t.Fatalf("unable to write the app package: %v", err)
+	}
+	_, err = createAppPkgWithConfigOverlay(ctx, appPkgPath, configMap)
+	if err == nil {
+		t.Errorf("createAppPkgWithConfigOverlay should have returned error for an invalid app package")
+	}
+}
+
+func TestUpdateAppConfigOverlayHashes(t *testing.T) {
+	ctx := context.TODO()
+	client := spltest.NewMockClient()
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.AppFrameworkConfig = enterpriseApi.AppFrameworkSpec{
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{
+				Name:     "adminApps",
+				Location: "adminAppsRepo",
+				AppConfigOverlays: []enterpriseApi.AppConfigOverlaySpec{
+					{AppName: "app1.tgz", ConfigMapName: "app1-overlay"},
+					{AppName: "app2.tgz", ConfigMapName: "app2-overlay"},
+				},
+			},
+		},
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app1-overlay", Namespace: "test"},
+		Data:       map[string]string{"inputs.conf": "[monitor:///var/log]\n"},
+	}
+	client.AddObject(configMap)
+
+	installed := enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallComplete}
+	appSrcDeploymentInfo := &enterpriseApi.AppSrcDeployInfo{
+		AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+			{AppName: "app1.tgz", RepoState: enterpriseApi.RepoStateActive, DeployStatus: enterpriseApi.DeployStatusComplete, PhaseInfo: installed},
+			{AppName: "app3.tgz", RepoState: enterpriseApi.RepoStateActive, DeployStatus: enterpriseApi.DeployStatusComplete, PhaseInfo: installed},
+		},
+	}
+
+	// Overlay added for an installed app
+	modified, err := updateAppConfigOverlayHashes(ctx, client, &cr, &cr.Spec.AppFrameworkConfig, "adminApps", appSrcDeploymentInfo)
+	if err != nil || !modified {
+		t.Errorf("overlay change should have been detected, err: %v", err)
+	}
+	app1 := appSrcDeploymentInfo.AppDeploymentInfoList[0]
+	if app1.OverlayHash != getAppConfigOverlayHash(configMap) || !app1.IsUpdate || app1.PhaseInfo.Phase != enterpriseApi.PhaseDownload {
+		t.Errorf("app with a new overlay should have been marked for an update")
+	}
+	if appSrcDeploymentInfo.AppDeploymentInfoList[1].IsUpdate {
+		t.Errorf("app without an overlay should not have been marked for an update")
+	}
+
+	// No change
+	appSrcDeploymentInfo.AppDeploymentInfoList[0].DeployStatus = enterpriseApi.DeployStatusComplete
+	appSrcDeploymentInfo.AppDeploymentInfoList[0].PhaseInfo = installed
+	modified, _ = updateAppConfigOverlayHashes(ctx, client, &cr, &cr.Spec.AppFrameworkConfig, "adminApps", appSrcDeploymentInfo)
+	if modified {
+		t.Errorf("no overlay change should have been detected")
+	}
+
+	// Overlay edit
+	configMap.Data["inputs.conf"] = "[monitor:///var/log]\nindex = prod\n"
+	modified, _ = updateAppConfigOverlayHashes(ctx, client, &cr, &cr.Spec.AppFrameworkConfig, "adminApps", appSrcDeploymentInfo)
+	if !modified || appSrcDeploymentInfo.AppDeploymentInfoList[0].OverlayHash != getAppConfigOverlayHash(configMap) {
+		t.Errorf("overlay edit should have been detected")
+	}
+
+	// A new app picks the overlay without an extra update
+	appSrcDeploymentInfo.AppDeploymentInfoList = append(appSrcDeploymentInfo.AppDeploymentInfoList, enterpriseApi.AppDeploymentInfo{
+		AppName:      "app2.tgz",
+		RepoState:    enterpriseApi.RepoStateActive,
+		DeployStatus: enterpriseApi.DeployStatusPending,
+		PhaseInfo:    enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseDownload, Status: enterpriseApi.AppPkgDownloadPending},
+	})
+	_, err = updateAppConfigOverlayHashes(ctx, client, &cr, &cr.Spec.AppFrameworkConfig, "adminApps", appSrcDeploymentInfo)
+	if err != nil || appSrcDeploymentInfo.AppDeploymentInfoList[2].LastError != getAppConfigOverlayMissingError("app2-overlay") {
+		t.Errorf("missing overlay ConfigMap should have been skipped and reported, err: %v", err)
+	}
+	if appSrcDeploymentInfo.AppDeploymentInfoList[0].OverlayHash != getAppConfigOverlayHash(configMap) {
+		t.Errorf("missing overlay ConfigMap should not have affected the other apps")
+	}
+
+	// Other errors still fail the check
+	client.InduceErrorKind[splcommon.MockClientInduceErrorGet] = errors.New("unable to get")
+	_, err = updateAppConfigOverlayHashes(ctx, client, &cr, &cr.Spec.AppFrameworkConfig, "adminApps", appSrcDeploymentInfo)
+	if err == nil {
+		t.Errorf("unable to get the overlay ConfigMap should have returned error")
+	}
+	delete(client.InduceErrorKind, splcommon.MockClientInduceErrorGet)
+
+	client.AddObject(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app2-overlay", Namespace: "test"},
+		Data:       map[string]string{"outputs.conf": "[tcpout]\n"},
+	})
+	_, err = updateAppConfigOverlayHashes(ctx, client, &cr, &cr.Spec.AppFrameworkConfig, "adminApps", appSrcDeploymentInfo)
+	if err != nil || appSrcDeploymentInfo.AppDeploymentInfoList[2].IsUpdate || appSrcDeploymentInfo.AppDeploymentInfoList[2].OverlayHash == "" {
+		t.Errorf("new app should have recorded the overlay hash without an update, err: %v", err)
+	}
+	if appSrcDeploymentInfo.AppDeploymentInfoList[2].LastError != "" {
+		t.Errorf("missing overlay error should have been cleared, once the ConfigMap is found")
+	}
+}
+
+func TestGetAppPkgForPodCopy(t *testing.T) {
+	ctx := context.TODO()
+	client := spltest.NewMockClient()
+
+	cr := enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.AppFrameworkConfig = enterpriseApi.AppFrameworkSpec{
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{
+				Name:              "adminApps",
+				AppConfigOverlays: []enterpriseApi.AppConfigOverlaySpec{{AppName: "app1.tgz", ConfigMapName: "app1-overlay"}},
+			},
+		},
+	}
+	worker := &PipelineWorker{
+		cr:            &cr,
+		client:        client,
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		appSrcName:    "adminApps",
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz"},
+	}
+
+	// Overlay missing already while checking the apps is skipped
+	appPkgPath, err := getAppPkgForPodCopy(ctx, worker, "/tmp/app1.tgz")
+	if err != nil || appPkgPath != "/tmp/app1.tgz" || worker.appDeployInfo.LastError != getAppConfigOverlayMissingError("app1-overlay") {
+		t.Errorf("missing overlay ConfigMap should have been skipped and reported, got: %s, err: %v", appPkgPath, err)
+	}
+
+	// Overlay deleted after it was recorded for the app fails the copy
+	worker.appDeployInfo.OverlayHash = "abcd"
+	_, err = getAppPkgForPodCopy(ctx, worker, "/tmp/app1.tgz")
+	if err == nil {
+		t.Errorf("overlay ConfigMap recorded for the app should not have been skipped")
+	}
+}
+
+func TestValidateAppConfigOverlays(t *testing.T) {
+	appFramework := &enterpriseApi.AppFrameworkSpec{
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{
+				Name: "adminApps",
+				AppConfigOverlays: []enterpriseApi.AppConfigOverlaySpec{
+					{AppName: "app1.tgz", ConfigMapName: "app1-overlay"},
+				},
+			},
+		},
+	}
+
+	if err := validateAppConfigOverlays(appFramework); err != nil {
+		t.Errorf("validateAppConfigOverlays should not have returned error: %v", err)
+	}
+
+	appFramework.AppSources[0].AppConfigOverlays = append(appFramework.AppSources[0].AppConfigOverlays, enterpriseApi.AppConfigOverlaySpec{AppName: "app1.tgz", ConfigMapName: "app1-overlay-2"})
+	if err := validateAppConfigOverlays(appFramework); err == nil {
+		t.Errorf("validateAppConfigOverlays should have returned error for a duplicate app")
+	}
+
+	appFramework.AppSources[0].AppConfigOverlays = []enterpriseApi.AppConfigOverlaySpec{{AppName: "app1.tgz"}}
+	if err := validateAppConfigOverlays(appFramework); err == nil {
+		t.Errorf("validateAppConfigOverlays should have returned error for a missing configMap name")
+	}
+}
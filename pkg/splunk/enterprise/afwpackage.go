@@ -0,0 +1,188 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The App Framework installs the app packages as gzip compressed tarballs, i.e. the .spl, .tgz and .tar.gz packages.
+// The .zip packages are converted into tarballs, once they are downloaded to the operator. The app directories, i.e.
+// the uncompressed apps kept as a directory on the remote storage, are packaged into tarballs by the remote data
+// client during the download. Either way, the rest of the phases, e.g. the top folder detection, see a tarball.
+
+// zipFileSignature is the signature at the start of a zip archive
+var zipFileSignature = []byte("PK\x03\x04")
+
+// appPkgNormalizeFileSuffix is the suffix of the tarball, while an app package is being converted into it
+const appPkgNormalizeFileSuffix = ".normalize"
+
+// isZipAppPkgName checks if the app package is a zip archive, from its name
+func isZipAppPkgName(appName string) bool {
+	return strings.HasSuffix(appName, ".zip")
+}
+
+// isAppPkgNormalizedOnOperator checks if the app package is converted into a tarball on the operator, so that
+// it can't be streamed from the remote storage to the pods as is
+func isAppPkgNormalizedOnOperator(appName string, objectHash string) bool {
+	return isZipAppPkgName(appName) || splclient.IsAppDirEtag(objectHash)
+}
+
+// isZipAppPkg checks if the app package is a zip archive, from its contents
+func isZipAppPkg(appPkgPath string) (bool, error) {
+	file, err := os.Open(appPkgPath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	signature := make([]byte, len(zipFileSignature))
+	_, err = io.ReadFull(file, signature)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(signature, zipFileSignature), nil
+}
+
+// normalizeAppPkg converts the app package into a gzip compressed tarball, if it is a zip archive. The app package
+// is replaced with the tarball rather than changed in place, as it can be a hard link to the app package cache
+func normalizeAppPkg(ctx context.Context, appPkgPath string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("normalizeAppPkg").WithValues("appPkgPath", appPkgPath)
+
+	isZip, err := isZipAppPkg(appPkgPath)
+	if err != nil || !isZip {
+		return err
+	}
+
+	zipReader, err := zip.OpenReader(appPkgPath)
+	if err != nil {
+		return fmt.Errorf("unable to read the app package %s, error: %v", appPkgPath, err)
+	}
+	defer zipReader.Close()
+
+	tarballPath := appPkgPath + appPkgNormalizeFileSuffix
+	dstFile, err := os.Create(tarballPath)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	gzipWriter := gzip.NewWriter(dstFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = writeZipAppPkgAsTarball(&zipReader.Reader, tarWriter)
+	if err == nil {
+		err = tarWriter.Close()
+	}
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	if err == nil {
+		err = os.Rename(tarballPath, appPkgPath)
+	}
+	if err != nil {
+		scopedLog.Error(err, "unable to convert the zip app package into a tarball")
+		os.Remove(tarballPath)
+		return err
+	}
+
+	scopedLog.Info("Converted the zip app package into a tarball")
+	return nil
+}
+
+// writeZipAppPkgAsTarball copies the directories and the regular files of the zip archive into the tarball
+func writeZipAppPkgAsTarball(zipReader *zip.Reader, tarWriter *tar.Writer) error {
+	var topFolder string
+
+	for _, zipFile := range zipReader.File {
+		entryName := strings.TrimPrefix(path.Clean(zipFile.Name), "./")
+		if !filepath.IsLocal(entryName) {
+			return fmt.Errorf("invalid entry %s in the app package", zipFile.Name)
+		}
+		if topFolder == "" {
+			topFolder = getAppPkgTopFolder(entryName)
+		}
+
+		fileInfo := zipFile.FileInfo()
+		if !fileInfo.IsDir() && !fileInfo.Mode().IsRegular() {
+			continue
+		}
+
+		header, err := tar.FileInfoHeader(fileInfo, "")
+		if err != nil {
+			return err
+		}
+		header.Name = entryName
+
+		// archivers on windows don't keep the unix permissions
+		if fileInfo.IsDir() {
+			header.Name += "/"
+			if fileInfo.Mode().Perm() == 0 {
+				header.Mode = 0755
+			}
+		} else if fileInfo.Mode().Perm() == 0 {
+			header.Mode = 0644
+		}
+
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if !fileInfo.IsDir() {
+			err = copyZipFile(zipFile, tarWriter)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if topFolder == "" || topFolder == "." {
+		return fmt.Errorf("unable to find the top folder of the app package")
+	}
+
+	return nil
+}
+
+// copyZipFile copies the contents of the file in the zip archive to the writer
+func copyZipFile(zipFile *zip.File, writer io.Writer) error {
+	reader, err := zipFile.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(writer, reader)
+	return err
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createZipAppPkg(t *testing.T, zipPath string, entries map[string]string) {
+	file, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("unable to create the zip app package. error: %v", err)
+	}
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+	for _, name := range []string{"app1/", "app1/default/", "app1/default/app.conf", "app1/bin/script.py", "../app2/default/app.conf"} {
+		contents, ok := entries[name]
+		if !ok {
+			continue
+		}
+		writer, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatalf("unable to add %s to the zip app package. error: %v", name, err)
+		}
+		io.WriteString(writer, contents)
+	}
+	err = zipWriter.Close()
+	if err != nil {
+		t.Fatalf("unable to write the zip app package. error: %v", err)
+	}
+}
+
+func readTarballAppPkg(t *testing.T, appPkgPath string) map[string]string {
+	file, err := os.Open(appPkgPath)
+	if err != nil {
+		t.Fatalf("unable to open the app package. error: %v", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("app package should be a gzip compressed tarball. error: %v", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	entries := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unable to read the app package. error: %v", err)
+		}
+		contents, _ := io.ReadAll(tarReader)
+		entries[header.Name] = string(contents)
+	}
+	return entries
+}
+
+func TestIsAppPkgNormalizedOnOperator(t *testing.T) {
+	if !isAppPkgNormalizedOnOperator("app1.zip", "abcd") || !isAppPkgNormalizedOnOperator("app1.tgz", "appdir-abcd") {
+		t.Errorf("zip app packages and app directories should be normalized on the operator")
+	}
+	if isAppPkgNormalizedOnOperator("app1.tgz", "abcd") || isAppPkgNormalizedOnOperator("app1.tar.gz", "abcd") {
+		t.Errorf("tarballs should not be normalized on the operator")
+	}
+}
+
+func TestNormalizeAppPkg(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	// zip app package is converted into a tarball
+	appPkgPath := filepath.Join(dir, "app1.zip_abcd")
+	createZipAppPkg(t, appPkgPath, map[string]string{
+		"app1/":                 "",
+		"app1/default/":         "",
+		"app1/default/app.conf": "[install]",
+		"app1/bin/script.py":    "print()",
+	})
+
+	// the cache link is left as is
+	cacheLinkPath := filepath.Join(dir, "app1.zip_abcd_cache")
+	err := os.Link(appPkgPath, cacheLinkPath)
+	if err != nil {
+		t.Fatalf("unable to link the app package. error: %v", err)
+	}
+
+	err = normalizeAppPkg(ctx, appPkgPath)
+	if err != nil {
+		t.Fatalf("zip app package should be converted. error: %v", err)
+	}
+	entries := readTarballAppPkg(t, appPkgPath)
+	if len(entries) != 4 || entries["app1/default/app.conf"] != "[install]" || entries["app1/bin/script.py"] != "print()" {
+		t.Errorf("tarball should have all the entries of the zip app package. got: %v", entries)
+	}
+	if _, ok := entries["app1/default/"]; !ok {
+		t.Errorf("tarball should have the directories of the zip app package. got: %v", entries)
+	}
+	if isZip, _ := isZipAppPkg(cacheLinkPath); !isZip {
+		t.Errorf("app package in the cache should not be changed")
+	}
+
+	// tarball is not changed
+	fileInfo, _ := os.Stat(appPkgPath)
+	err = normalizeAppPkg(ctx, appPkgPath)
+	if err != nil {
+		t.Errorf("tarball should not be converted. error: %v", err)
+	}
+	newFileInfo, _ := os.Stat(appPkgPath)
+	if !os.SameFile(fileInfo, newFileInfo) {
+		t.Errorf("tarball should not be replaced")
+	}
+
+	// entries outside of the app are not allowed
+	appPkgPath = filepath.Join(dir, "app2.zip_efgh")
+	createZipAppPkg(t, appPkgPath, map[string]string{
+		"app1/default/app.conf":    "[install]",
+		"../app2/default/app.conf": "[install]",
+	})
+	err = normalizeAppPkg(ctx, appPkgPath)
+	if err == nil {
+		t.Errorf("zip app package with entries outside of the app should fail the conversion")
+	}
+	if _, err = os.Stat(appPkgPath + appPkgNormalizeFileSuffix); err == nil {
+		t.Errorf("partial tarball should be removed")
+	}
+
+	// missing app package
+	err = normalizeAppPkg(ctx, filepath.Join(dir, "app3.zip_ijkl"))
+	if err == nil {
+		t.Errorf("missing app package should fail the conversion")
+	}
+}
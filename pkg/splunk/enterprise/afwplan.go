@@ -0,0 +1,173 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// In the dry run mode, the app changes are worked out on a copy of the app deployment context, and recorded
+// as a plan in the status. The app deployment context itself is left untouched, so, turning off the dry run
+// mode makes the App Framework check the remote storage again and carry out the changes.
+
+// getAppFrameworkConfigHash returns the hash of the App Framework config
+func getAppFrameworkConfigHash(appFrameworkConf *enterpriseApi.AppFrameworkSpec) string {
+	contents, _ := json.Marshal(appFrameworkConf)
+	hash := sha256.Sum256(contents)
+	return hex.EncodeToString(hash[:])
+}
+
+// isAppDeploymentPlanStale checks if the dry run plan is to be made again
+func isAppDeploymentPlanStale(appFrameworkConf *enterpriseApi.AppFrameworkSpec, appStatusContext *enterpriseApi.AppDeploymentContext) bool {
+	return appStatusContext.DryRunPlan == nil || appStatusContext.DryRunPlan.AppFrameworkConfigHash != getAppFrameworkConfigHash(appFrameworkConf)
+}
+
+// getAppDeployInfoByName returns the deployment info of the app from the list, if any
+func getAppDeployInfoByName(appDeployInfoList []enterpriseApi.AppDeploymentInfo, appName string) *enterpriseApi.AppDeploymentInfo {
+	for i := range appDeployInfoList {
+		if appDeployInfoList[i].AppName == appName {
+			return &appDeployInfoList[i]
+		}
+	}
+
+	return nil
+}
+
+// getAppDeploymentPlan works out the app changes for the remote storage listing, without modifying the app deployment context
+func getAppDeploymentPlan(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appStatusContext *enterpriseApi.AppDeploymentContext,
+	sourceToAppsList map[string]splclient.RemoteDataListResponse, appFrameworkConf *enterpriseApi.AppFrameworkSpec) (*enterpriseApi.AppDeploymentPlan, error) {
+	plannedContext := appStatusContext.DeepCopy()
+	if plannedContext.AppsSrcDeployStatus == nil {
+		plannedContext.AppsSrcDeployStatus = make(map[string]enterpriseApi.AppSrcDeployInfo)
+	}
+
+	_, err := handleAppRepoChanges(ctx, client, cr, plannedContext, sourceToAppsList, appFrameworkConf)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &enterpriseApi.AppDeploymentPlan{
+		PlanTime:               time.Now().Unix(),
+		AppFrameworkConfigHash: getAppFrameworkConfigHash(appFrameworkConf),
+	}
+
+	// visit the App sources in a fixed order, so that the plan doesn't change between the checks
+	appSrcNames := make([]string, 0, len(plannedContext.AppsSrcDeployStatus))
+	for appSrcName := range plannedContext.AppsSrcDeployStatus {
+		appSrcNames = append(appSrcNames, appSrcName)
+	}
+	sort.Strings(appSrcNames)
+
+	for _, appSrcName := range appSrcNames {
+		scope := getAppSrcScope(ctx, appFrameworkConf, appSrcName)
+		currentList := appStatusContext.AppsSrcDeployStatus[appSrcName].AppDeploymentInfoList
+
+		var bundlePushNeeded bool
+		for _, plannedApp := range plannedContext.AppsSrcDeployStatus[appSrcName].AppDeploymentInfoList {
+			currentApp := getAppDeployInfoByName(currentList, plannedApp.AppName)
+			entry := enterpriseApi.AppPlanEntry{
+				AppSourceName: appSrcName,
+				AppName:       plannedApp.AppName,
+				Scope:         scope,
+				ObjectHash:    plannedApp.ObjectHash,
+			}
+
+			switch {
+			case plannedApp.RepoState == enterpriseApi.RepoStateActive && (currentApp == nil || currentApp.RepoState != enterpriseApi.RepoStateActive):
+				plan.AppsToInstall = append(plan.AppsToInstall, entry)
+				bundlePushNeeded = true
+			case plannedApp.RepoState == enterpriseApi.RepoStateActive && (currentApp.ObjectHash != plannedApp.ObjectHash || currentApp.OverlayHash != plannedApp.OverlayHash):
+				plan.AppsToUpdate = append(plan.AppsToUpdate, entry)
+				bundlePushNeeded = true
+			case plannedApp.RepoState != enterpriseApi.RepoStateActive && currentApp != nil && currentApp.RepoState == enterpriseApi.RepoStateActive:
+				plan.AppsToDelete = append(plan.AppsToDelete, entry)
+			}
+		}
+
+		if bundlePushNeeded && scope == enterpriseApi.ScopeCluster {
+			plan.BundlePushAppSources = append(plan.BundlePushAppSources, appSrcName)
+		}
+	}
+
+	plan.BundlePushRequired = plannedContext.BundlePushStatus.BundlePushStage == enterpriseApi.BundlePushPending &&
+		appStatusContext.BundlePushStatus.BundlePushStage != enterpriseApi.BundlePushPending
+
+	return plan, nil
+}
+
+// planAppInfoStatus checks the status of apps on remote storage and records the plan of the app changes, in the dry run mode
+func planAppInfoStatus(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject,
+	appFrameworkConf *enterpriseApi.AppFrameworkSpec, appStatusContext *enterpriseApi.AppDeploymentContext) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("planAppInfoStatus").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	var turnOffManualChecking bool
+	kind := cr.GetObjectKind().GroupVersionKind().Kind
+
+	// turning off the dry run mode changes the config, so, the notified App sources are checked again anyway
+	dirtyAppSources := getDirtyAppSources(cr)
+
+	if !isAppDeploymentPlanStale(appFrameworkConf, appStatusContext) && len(dirtyAppSources) == 0 &&
+		!shouldCheckAppRepoStatus(ctx, client, cr, appStatusContext, kind, &turnOffManualChecking) {
+		return nil
+	}
+
+	scopedLog.Info("Dry run mode. Checking status of apps on remote storage to plan the app changes...")
+
+	sourceToAppsList, err := GetAppListFromRemoteBucket(ctx, client, cr, appFrameworkConf)
+	if len(sourceToAppsList) != len(appFrameworkConf.AppSources) {
+		scopedLog.Error(err, "Unable to get apps list, will retry in next reconcile...")
+	} else {
+		for _, appSource := range appFrameworkConf.AppSources {
+			err = cleanAppSrcObjectDigests(ctx, appSource.Name, sourceToAppsList[appSource.Name])
+			if err != nil {
+				return err
+			}
+		}
+
+		plan, err := getAppDeploymentPlan(ctx, client, cr, appStatusContext, sourceToAppsList, appFrameworkConf)
+		if err != nil {
+			scopedLog.Error(err, "Unable to plan the app changes")
+			return err
+		}
+
+		scopedLog.Info("Planned the app changes", "install", len(plan.AppsToInstall), "update", len(plan.AppsToUpdate), "delete", len(plan.AppsToDelete), "bundlePushRequired", plan.BundlePushRequired)
+		appStatusContext.DryRunPlan = plan
+		clearDirtyAppSources(cr, dirtyAppSources)
+	}
+
+	SetLastAppInfoCheckTime(ctx, appStatusContext)
+
+	if !isAppRepoPollingEnabled(appStatusContext) {
+		err = updateManualAppUpdateConfigMapLocked(ctx, client, cr, appStatusContext, kind, turnOffManualChecking)
+		if err != nil {
+			scopedLog.Error(err, "failed to update the manual app udpate configMap")
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getDryRunTestClusterManager() *enterpriseApi.ClusterManager {
+	return &enterpriseApi.ClusterManager{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ClusterManager",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cm",
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.ClusterManagerSpec{
+			AppFrameworkConfig: enterpriseApi.AppFrameworkSpec{
+				AppsRepoPollInterval: 60,
+				DryRun:               true,
+				VolList: []enterpriseApi.VolumeSpec{
+					{Name: "msos_s2s3_vol", Endpoint: "https://s3-eu-west-2.amazonaws.com", Path: "testbucket-rs-london", Provider: "aws"},
+				},
+				AppSources: []enterpriseApi.AppSourceSpec{
+					{Name: "localApps",
+						Location: "localAppsRepo",
+						AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{
+							VolName: "msos_s2s3_vol",
+							Scope:   enterpriseApi.ScopeLocal},
+					},
+					{Name: "clusterApps",
+						Location: "clusterAppsRepo",
+						AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{
+							VolName: "msos_s2s3_vol",
+							Scope:   enterpriseApi.ScopeCluster},
+					},
+				},
+			},
+		},
+	}
+}
+
+// getDryRunTestAppsList returns a remote storage listing with the given apps and etags
+func getDryRunTestAppsList(location string, apps map[string]string) splclient.RemoteDataListResponse {
+	var response splclient.RemoteDataListResponse
+	for appName, etag := range apps {
+		key := location + "/" + appName
+		etag := etag
+		size := int64(10)
+		lastModified := time.Now()
+		response.Objects = append(response.Objects, &splclient.RemoteObject{Key: &key, Etag: &etag, Size: &size, LastModified: &lastModified})
+	}
+	return response
+}
+
+func TestGetAppDeploymentPlan(t *testing.T) {
+	ctx := context.TODO()
+	client := spltest.NewMockClient()
+	cr := getDryRunTestClusterManager()
+
+	installed := enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallComplete}
+	appStatusContext := &enterpriseApi.AppDeploymentContext{
+		AppsSrcDeployStatus: map[string]enterpriseApi.AppSrcDeployInfo{
+			"localApps": {
+				AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+					{AppName: "app1.tgz", ObjectHash: "abcd1111", RepoState: enterpriseApi.RepoStateActive, DeployStatus: enterpriseApi.DeployStatusComplete, PhaseInfo: installed},
+					{AppName: "app2.tgz", ObjectHash: "abcd1111", RepoState: enterpriseApi.RepoStateActive, DeployStatus: enterpriseApi.DeployStatusComplete, PhaseInfo: installed},
+					{AppName: "app3.tgz", ObjectHash: "abcd1111", RepoState: enterpriseApi.RepoStateActive, DeployStatus: enterpriseApi.DeployStatusComplete, PhaseInfo: installed},
+				},
+			},
+		},
+	}
+	savedContext := appStatusContext.DeepCopy()
+
+	sourceToAppsList := map[string]splclient.RemoteDataListResponse{
+		// app1 is unchanged, app2 is updated, app3 is deleted and app4 is new
+		"localApps": getDryRunTestAppsList("localAppsRepo", map[string]string{"app1.tgz": "abcd1111", "app2.tgz": "abcd2222", "app4.tgz": "abcd1111"}),
+		// a new cluster scoped App source
+		"clusterApps": getDryRunTestAppsList("clusterAppsRepo", map[string]string{"clusterApp1.tgz": "abcd1111"}),
+	}
+
+	plan, err := getAppDeploymentPlan(ctx, client, cr, appStatusContext, sourceToAppsList, &cr.Spec.AppFrameworkConfig)
+	if err != nil {
+		t.Fatalf("getAppDeploymentPlan should not have returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(appStatusContext, savedContext) {
+		t.Errorf("App deployment context should not have been modified")
+	}
+
+	expectedInstall := []enterpriseApi.AppPlanEntry{
+		{AppSourceName: "clusterApps", AppName: "clusterApp1.tgz", Scope: enterpriseApi.ScopeCluster, ObjectHash: "abcd1111"},
+		{AppSourceName: "localApps", AppName: "app4.tgz", Scope: enterpriseApi.ScopeLocal, ObjectHash: "abcd1111"},
+	}
+	if !reflect.DeepEqual(plan.AppsToInstall, expectedInstall) {
+		t.Errorf("Expected apps to install: %v, got: %v", expectedInstall, plan.AppsToInstall)
+	}
+	if len(plan.AppsToUpdate) != 1 || plan.AppsToUpdate[0].AppName != "app2.tgz" || plan.AppsToUpdate[0].ObjectHash != "abcd2222" {
+		t.Errorf("Expected app2.tgz to be updated, got: %v", plan.AppsToUpdate)
+	}
+	if len(plan.AppsToDelete) != 1 || plan.AppsToDelete[0].AppName != "app3.tgz" {
+		t.Errorf("Expected app3.tgz to be deleted, got: %v", plan.AppsToDelete)
+	}
+	if !plan.BundlePushRequired || !reflect.DeepEqual(plan.BundlePushAppSources, []string{"clusterApps"}) {
+		t.Errorf("Bundle push should be required for the cluster scoped App source, got: %v %v", plan.BundlePushRequired, plan.BundlePushAppSources)
+	}
+	if plan.AppFrameworkConfigHash != getAppFrameworkConfigHash(&cr.Spec.AppFrameworkConfig) {
+		t.Errorf("Plan should have recorded the App Framework config hash")
+	}
+}
+
+func TestPlanAppInfoStatus(t *testing.T) {
+	initGlobalResourceTracker()
+	ctx := context.TODO()
+	client := spltest.NewMockClient()
+	cr := getDryRunTestClusterManager()
+
+	savedGetAppsList := GetAppsList
+	defer func() { GetAppsList = savedGetAppsList }()
+
+	var listCount int
+	GetAppsList = func(ctx context.Context, remoteDataClientMgr RemoteDataClientManager) (splclient.RemoteDataListResponse, error) {
+		listCount++
+		return getDryRunTestAppsList(remoteDataClientMgr.location, map[string]string{"app1.tgz": "abcd1111"}), nil
+	}
+
+	appStatusContext := &cr.Status.AppContext
+	err := initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, appStatusContext)
+	if err != nil {
+		t.Errorf("initAndCheckAppInfoStatus should not have returned error in dry run mode: %v", err)
+	}
+
+	if appStatusContext.DryRunPlan == nil || len(appStatusContext.DryRunPlan.AppsToInstall) != 2 {
+		t.Fatalf("Dry run plan should have listed the apps to install, got: %v", appStatusContext.DryRunPlan)
+	}
+	if len(appStatusContext.AppsSrcDeployStatus) != 0 || appStatusContext.IsDeploymentInProgress {
+		t.Errorf("Apps should not have been deployed in dry run mode")
+	}
+
+	// Plan is not made again till the poll interval expires
+	listCount = 0
+	initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, appStatusContext)
+	if listCount != 0 {
+		t.Errorf("Remote storage should not have been checked again, got %d listings", listCount)
+	}
+
+	// Config change makes the plan again
+	cr.Spec.AppFrameworkConfig.AppSources = cr.Spec.AppFrameworkConfig.AppSources[:1]
+	initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, appStatusContext)
+	if listCount != 1 || len(appStatusContext.DryRunPlan.AppsToInstall) != 1 {
+		t.Errorf("Plan should have been made again for the config change")
+	}
+
+	// Notification makes the plan again
+	markAppSourcesDirty("ClusterManager", "test", "cm", []string{"localApps"})
+	initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, appStatusContext)
+	if listCount != 2 || len(getDirtyAppSources(cr)) != 0 {
+		t.Errorf("Plan should have been made again for the notified App source")
+	}
+
+	// No pipeline phase is run in dry run mode
+	result := handleAppFrameworkActivity(ctx, client, cr, appStatusContext, &cr.Spec.AppFrameworkConfig)
+	if result.Requeue && result.RequeueAfter == 5*time.Second {
+		t.Errorf("App framework pipeline should not have been run in dry run mode")
+	}
+
+	// Turning off the dry run mode deploys the apps and clears the plan
+	cr.Spec.AppFrameworkConfig.DryRun = false
+	err = initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, appStatusContext)
+	if err != nil {
+		t.Errorf("initAndCheckAppInfoStatus should not have returned error: %v", err)
+	}
+	if appStatusContext.DryRunPlan != nil || len(appStatusContext.AppsSrcDeployStatus["localApps"].AppDeploymentInfoList) != 1 || !appStatusContext.IsDeploymentInProgress {
+		t.Errorf("Apps should have been deployed after turning off the dry run mode")
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Premium apps are installed like any other app on the standalone/SHC deployer, followed by the post install steps
+// of their type. Every premium app type registers its implementation here, which validates its properties and runs
+// its post install steps.
+
+// premiumAppTypes is the registry of premium app types, keyed by the type name
+var premiumAppTypes = map[string]premiumAppTypeImpl{}
+
+// registerPremiumAppType registers the implementation of a premium app type
+func registerPremiumAppType(typeName string, impl premiumAppTypeImpl) {
+	premiumAppTypes[typeName] = impl
+}
+
+// getPremiumAppType returns the implementation of the given premium app type
+func getPremiumAppType(typeName string) (premiumAppTypeImpl, bool) {
+	impl, ok := premiumAppTypes[typeName]
+	return impl, ok
+}
+
+// getPremiumAppTypeNames returns the names of the registered premium app types, sorted
+func getPremiumAppTypeNames() []string {
+	var typeNames []string
+	for typeName := range premiumAppTypes {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+	return typeNames
+}
+
+func init() {
+	registerPremiumAppType(enterpriseApi.PremiumAppsTypeEs, &esPremiumApp{})
+	registerPremiumAppType(enterpriseApi.PremiumAppsTypeItsi, &itsiPremiumApp{})
+}
+
+// esPremiumApp implements the Enterprise Security premium app type
+type esPremiumApp struct{}
+
+// blank assignment to implement premiumAppTypeImpl
+var _ premiumAppTypeImpl = &esPremiumApp{}
+
+// validate validates the ES defaults
+func (es *esPremiumApp) validate(appSrc *enterpriseApi.AppSourceSpec, crKind string) error {
+	// Check sslEnablement in ES defaults
+	sslEnablementValue := appSrc.AppSourceDefaultSpec.PremiumAppsProps.EsDefaults.SslEnablement
+	if sslEnablementValue != "" && !(sslEnablementValue == enterpriseApi.SslEnablementAuto ||
+		sslEnablementValue == enterpriseApi.SslEnablementIgnore ||
+		sslEnablementValue == enterpriseApi.SslEnablementStrict) {
+		return fmt.Errorf("invalid sslEnablement. Valid values are %s or %s or %s", enterpriseApi.SslEnablementAuto,
+			enterpriseApi.SslEnablementIgnore, enterpriseApi.SslEnablementStrict)
+	}
+
+	// SHC ES app cannot use ssl_enablement auto, product doesn't support it
+	if crKind == "SearchHeadCluster" && sslEnablementValue == enterpriseApi.SslEnablementAuto {
+		return fmt.Errorf("scope for app source: %s search head cluster cannot have an ES app installed with ssl_enablement auto", appSrc.Name)
+	}
+	return nil
+}
+
+// getSslCliOption gets the ssl cli option for installing ES app.
+// Returns `strict` if not configured. Note: Validation of spec done already
+// Reference: https://docs.splunk.com/Documentation/ES/latest/Install/InstallEnterpriseSecuritySHC
+func getSslCliOption(appSrcSpec *enterpriseApi.AppSourceSpec) string {
+	sslEn := appSrcSpec.PremiumAppsProps.EsDefaults.SslEnablement
+	if sslEn != "" {
+		return sslEn
+	}
+
+	return enterpriseApi.SslEnablementStrict
+}
+
+// postInstall runs the essinstall command for the ES app
+func (es *esPremiumApp) postInstall(rctx context.Context, preCtx *premiumAppScopePlaybookContext, phaseInfo *enterpriseApi.PhaseInfo) error {
+	worker := preCtx.localCtx.worker
+	cr := preCtx.cr
+	appSrcSpec := preCtx.appSrcSpec
+
+	reqLogger := log.FromContext(rctx)
+	scopedLog := reqLogger.WithName("handleEsappPostinstall").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
+
+	// For ES app, run post-install commands
+	var command string
+
+	// Create CLI command
+	sslEn := getSslCliOption(appSrcSpec)
+	if cr.GetObjectKind().GroupVersionKind().Kind != "SearchHeadCluster" {
+		command = fmt.Sprintf("/opt/splunk/bin/splunk search '| essinstall --ssl_enablement %s' -auth admin:`cat /mnt/splunk-secrets/password`", sslEn)
+	} else {
+		// Pass an extra parameter for SHC deployer in post install command
+		command = fmt.Sprintf("/opt/splunk/bin/splunk search '| essinstall --ssl_enablement %s --deployment_type shc_deployer' -auth admin:`cat /mnt/splunk-secrets/password`", sslEn)
+	}
+
+	streamOptions := splutil.NewStreamOptionsObject(command)
+	stdOut, stdErr, err := preCtx.localCtx.podExecClient.RunPodExecCommand(rctx, streamOptions, []string{"/bin/sh"})
+	if stdErr != "" || err != nil {
+		phaseInfo.FailCount++
+		scopedLog.Error(err, "premium scoped app package install failed", "stdout", stdOut, "stderr", stdErr, "post install command", command, "failCount", phaseInfo.FailCount)
+		return fmt.Errorf("premium scoped app package install failed. stdOut: %s, stdErr: %s, post install command: %s, failCount: %d", stdOut, stdErr, command, phaseInfo.FailCount)
+	}
+
+	return nil
+}
+
+// defaultItsiKvStoreReadyTimeout is the default max. time(in seconds) to wait for the KV store to be ready for ITSI
+const defaultItsiKvStoreReadyTimeout = 300
+
+// itsiKvStorePollInterval is the interval between the KV store status checks
+var itsiKvStorePollInterval = 10 * time.Second
+
+// itsiPremiumApp implements the IT Service Intelligence premium app type
+type itsiPremiumApp struct{}
+
+// blank assignment to implement premiumAppTypeImpl
+var _ premiumAppTypeImpl = &itsiPremiumApp{}
+
+// validate validates the ITSI defaults
+func (itsi *itsiPremiumApp) validate(appSrc *enterpriseApi.AppSourceSpec, crKind string) error {
+	// ITSI runs on the search heads, it is installed either on a standalone or through the SHC deployer
+	if crKind != "Standalone" && crKind != "SearchHeadCluster" {
+		return fmt.Errorf("app source: %s ITSI app can only be installed on Standalone or SearchHeadCluster, not on %s", appSrc.Name, crKind)
+	}
+
+	if appSrc.AppSourceDefaultSpec.PremiumAppsProps.ItsiDefaults.KvStoreReadyTimeout < 0 {
+		return fmt.Errorf("invalid kvStoreReadyTimeout for app source: %s. It cannot be negative", appSrc.Name)
+	}
+	return nil
+}
+
+// getItsiKvStoreReadyTimeout returns the max. time to wait for the KV store to be ready for ITSI
+func getItsiKvStoreReadyTimeout(appSrcSpec *enterpriseApi.AppSourceSpec) time.Duration {
+	timeout := appSrcSpec.PremiumAppsProps.ItsiDefaults.KvStoreReadyTimeout
+	if timeout == 0 {
+		timeout = defaultItsiKvStoreReadyTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// postInstall runs the ITSI post install steps.
+// On the SHC deployer, the ITSI apps are extracted to the shcluster apps so that the bundle push deploys them on the members.
+// On a standalone, waits for the KV store to be ready, so that ITSI can set up its KV store collections
+func (itsi *itsiPremiumApp) postInstall(rctx context.Context, preCtx *premiumAppScopePlaybookContext, phaseInfo *enterpriseApi.PhaseInfo) error {
+	if preCtx.cr.GetObjectKind().GroupVersionKind().Kind == "SearchHeadCluster" {
+		return itsi.stageAppsOnDeployer(rctx, preCtx, phaseInfo)
+	}
+	return itsi.waitForKvStore(rctx, preCtx, phaseInfo)
+}
+
+// stageAppsOnDeployer extracts the ITSI package, which bundles several apps, to the shcluster apps of the deployer
+func (itsi *itsiPremiumApp) stageAppsOnDeployer(rctx context.Context, preCtx *premiumAppScopePlaybookContext, phaseInfo *enterpriseApi.PhaseInfo) error {
+	worker := preCtx.localCtx.worker
+	cr := preCtx.cr
+
+	reqLogger := log.FromContext(rctx)
+	scopedLog := reqLogger.WithName("itsiPremiumApp.stageAppsOnDeployer").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
+
+	appPkgPathOnPod := filepath.Join(appBktMnt, worker.appSrcName, getAppPackageName(worker))
+	command := fmt.Sprintf("mkdir -p %s && tar -xzf %s -C %s", shcAppsLocationOnDeployer, appPkgPathOnPod, shcAppsLocationOnDeployer)
+
+	streamOptions := splutil.NewStreamOptionsObject(command)
+	stdOut, stdErr, err := preCtx.localCtx.podExecClient.RunPodExecCommand(rctx, streamOptions, []string{"/bin/sh"})
+	if stdErr != "" || err != nil {
+		phaseInfo.FailCount++
+		scopedLog.Error(err, "staging ITSI apps on the deployer failed", "stdout", stdOut, "stderr", stdErr, "failCount", phaseInfo.FailCount)
+		return fmt.Errorf("staging ITSI apps on the deployer failed. stdOut: %s, stdErr: %s, command: %s, failCount: %d", stdOut, stdErr, command, phaseInfo.FailCount)
+	}
+
+	scopedLog.Info("ITSI apps staged on the deployer", "location", shcAppsLocationOnDeployer)
+	return nil
+}
+
+// isKvStoreReady checks if the KV store is ready on the pod
+func isKvStoreReady(rctx context.Context, podExecClient splutil.PodExecClientImpl) (bool, error) {
+	command := "/opt/splunk/bin/splunk show kvstore-status -auth admin:`cat /mnt/splunk-secrets/password` 2>/dev/null | grep -q -E '^[[:space:]]*status[[:space:]]*:[[:space:]]*ready'; echo -n $?"
+
+	streamOptions := splutil.NewStreamOptionsObject(command)
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(rctx, streamOptions, []string{"/bin/sh"})
+	if err != nil {
+		return false, fmt.Errorf("could not get the KV store status. stdOut: %s, stdErr: %s, error: %v", stdOut, stdErr, err)
+	}
+
+	status, _ := strconv.Atoi(strings.TrimSpace(stdOut))
+	return status == 0, nil
+}
+
+// waitForKvStore waits until the KV store is ready, or the configured timeout elapses
+func (itsi *itsiPremiumApp) waitForKvStore(rctx context.Context, preCtx *premiumAppScopePlaybookContext, phaseInfo *enterpriseApi.PhaseInfo) error {
+	worker := preCtx.localCtx.worker
+	cr := preCtx.cr
+
+	reqLogger := log.FromContext(rctx)
+	scopedLog := reqLogger.WithName("itsiPremiumApp.waitForKvStore").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
+
+	var sigTerm chan struct{}
+	if preCtx.afwPipeline != nil {
+		sigTerm = preCtx.afwPipeline.sigTerm
+	}
+
+	timeout := getItsiKvStoreReadyTimeout(preCtx.appSrcSpec)
+	deadline := time.Now().Add(timeout)
+	for {
+		ready, err := isKvStoreReady(rctx, preCtx.localCtx.podExecClient)
+		if err != nil {
+			scopedLog.Error(err, "unable to check the KV store status")
+		} else if ready {
+			scopedLog.Info("KV store is ready for ITSI")
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			break
+		}
+
+		select {
+		case <-time.After(itsiKvStorePollInterval):
+		case <-sigTerm:
+			return fmt.Errorf("pipeline terminated while waiting for the KV store to be ready")
+		}
+	}
+
+	phaseInfo.FailCount++
+	scopedLog.Error(nil, "KV store is not ready for ITSI", "timeout", timeout, "failCount", phaseInfo.FailCount)
+	return fmt.Errorf("KV store is not ready for ITSI after %s. failCount: %d", timeout, phaseInfo.FailCount)
+}
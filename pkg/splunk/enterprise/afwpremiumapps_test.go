@@ -0,0 +1,175 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getPremiumAppTestPlaybookContext(cr splcommon.MetaObject, premiumAppType string, podExecClient *spltest.MockPodExecClient) *premiumAppScopePlaybookContext {
+	appSrcSpec := &enterpriseApi.AppSourceSpec{
+		Name: "appSrc1",
+		AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{
+			Scope: enterpriseApi.ScopePremiumApps,
+			PremiumAppsProps: enterpriseApi.PremiumAppsProps{
+				Type: premiumAppType,
+			},
+		},
+	}
+	worker := &PipelineWorker{
+		cr:            cr,
+		appSrcName:    "appSrc1",
+		targetPodName: "splunk-stack1-0",
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz", ObjectHash: "abcd1234"},
+	}
+	return &premiumAppScopePlaybookContext{
+		localCtx: &localScopePlaybookContext{
+			worker:        worker,
+			podExecClient: podExecClient,
+		},
+		appSrcSpec: appSrcSpec,
+		cr:         cr,
+	}
+}
+
+func TestGetSslCliOption(t *testing.T) {
+	appSrcSpec := &enterpriseApi.AppSourceSpec{
+		AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{
+			PremiumAppsProps: enterpriseApi.PremiumAppsProps{
+				EsDefaults: enterpriseApi.EsDefaults{
+					SslEnablement: "abc",
+				},
+			},
+		},
+	}
+	if getSslCliOption(appSrcSpec) != "abc" {
+		t.Errorf("Incorrect ssl enablement option returned")
+	}
+}
+
+func TestGetPremiumAppType(t *testing.T) {
+	if _, ok := getPremiumAppType(enterpriseApi.PremiumAppsTypeEs); !ok {
+		t.Errorf("ES premium app type should be registered")
+	}
+	if _, ok := getPremiumAppType(enterpriseApi.PremiumAppsTypeItsi); !ok {
+		t.Errorf("ITSI premium app type should be registered")
+	}
+	if _, ok := getPremiumAppType("unknownType"); ok {
+		t.Errorf("unknown premium app type should not be found")
+	}
+
+	typeNames := strings.Join(getPremiumAppTypeNames(), ",")
+	if typeNames != "enterpriseSecurity,itsi" {
+		t.Errorf("premium app type names should be sorted. got: %s", typeNames)
+	}
+}
+
+func TestEsPremiumAppPostInstall(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.SearchHeadCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "SearchHeadCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	podExecClient := &spltest.MockPodExecClient{}
+	podExecClient.AddMockPodExecReturnContext(ctx, "essinstall --ssl_enablement strict --deployment_type shc_deployer", &spltest.MockPodExecReturnContext{})
+	preCtx := getPremiumAppTestPlaybookContext(&cr, enterpriseApi.PremiumAppsTypeEs, podExecClient)
+
+	phaseInfo := &enterpriseApi.PhaseInfo{}
+	esApp, _ := getPremiumAppType(enterpriseApi.PremiumAppsTypeEs)
+	err := esApp.postInstall(ctx, preCtx, phaseInfo)
+	if err != nil {
+		t.Errorf("ES post install should succeed. error: %v", err)
+	}
+	podExecClient.CheckPodExecCommands(t, "esPremiumApp.postInstall")
+
+	// post install failure is counted
+	podExecClient.MockReturnContexts["essinstall --ssl_enablement strict --deployment_type shc_deployer"].StdErr = "essinstall failed"
+	err = esApp.postInstall(ctx, preCtx, phaseInfo)
+	if err == nil || phaseInfo.FailCount != 1 {
+		t.Errorf("ES post install failure should be returned and counted. error: %v, failCount: %d", err, phaseInfo.FailCount)
+	}
+}
+
+func TestItsiPremiumAppPostInstall(t *testing.T) {
+	ctx := context.TODO()
+	savedPollInterval := itsiKvStorePollInterval
+	defer func() { itsiKvStorePollInterval = savedPollInterval }()
+	itsiKvStorePollInterval = 10 * time.Millisecond
+
+	// standalone waits for the KV store to be ready
+	standalone := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	podExecClient := &spltest.MockPodExecClient{}
+	podExecClient.AddMockPodExecReturnContext(ctx, "show kvstore-status", &spltest.MockPodExecReturnContext{StdOut: "0"})
+	preCtx := getPremiumAppTestPlaybookContext(&standalone, enterpriseApi.PremiumAppsTypeItsi, podExecClient)
+
+	phaseInfo := &enterpriseApi.PhaseInfo{}
+	itsiApp, _ := getPremiumAppType(enterpriseApi.PremiumAppsTypeItsi)
+	err := itsiApp.postInstall(ctx, preCtx, phaseInfo)
+	if err != nil {
+		t.Errorf("ITSI post install should succeed, when the KV store is ready. error: %v", err)
+	}
+
+	// KV store that is not ready within the timeout fails the post install
+	podExecClient.MockReturnContexts["show kvstore-status"].StdOut = "1"
+	preCtx.appSrcSpec.PremiumAppsProps.ItsiDefaults.KvStoreReadyTimeout = 1
+	err = itsiApp.postInstall(ctx, preCtx, phaseInfo)
+	if err == nil || !strings.Contains(err.Error(), "KV store is not ready") || phaseInfo.FailCount != 1 {
+		t.Errorf("ITSI post install should fail, when the KV store is not ready. error: %v, failCount: %d", err, phaseInfo.FailCount)
+	}
+
+	// SHC deployer stages the ITSI apps for the bundle push
+	shc := enterpriseApi.SearchHeadCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "SearchHeadCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	podExecClient = &spltest.MockPodExecClient{}
+	podExecClient.AddMockPodExecReturnContext(ctx, "tar -xzf /operator-staging/appframework/appSrc1/app1.tgz_abcd1234 -C /opt/splunk/etc/shcluster/apps/", &spltest.MockPodExecReturnContext{})
+	preCtx = getPremiumAppTestPlaybookContext(&shc, enterpriseApi.PremiumAppsTypeItsi, podExecClient)
+
+	phaseInfo = &enterpriseApi.PhaseInfo{}
+	err = itsiApp.postInstall(ctx, preCtx, phaseInfo)
+	if err != nil {
+		t.Errorf("ITSI post install on the deployer should succeed. error: %v", err)
+	}
+	podExecClient.CheckPodExecCommands(t, "itsiPremiumApp.postInstall")
+}
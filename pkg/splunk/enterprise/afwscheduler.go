@@ -111,6 +111,15 @@ func getApplicablePodNameForAppFramework(cr splcommon.MetaObject, ordinalIdx int
 	return fmt.Sprintf("splunk-%s-%s-%d", cr.GetName(), podType, ordinalIdx)
 }
 
+// getAppFrameworkTargetPodName returns the pod the App Framework deploys the apps of the CR through: the active
+// cluster manager for a ClusterManager, otherwise the pod 0
+func getAppFrameworkTargetPodName(cr splcommon.MetaObject) string {
+	if cm, ok := cr.(*enterpriseApi.ClusterManager); ok {
+		return getActiveClusterManagerPodName(cm)
+	}
+	return getApplicablePodNameForAppFramework(cr, 0)
+}
+
 // runCustomCommandOnSplunkPods  runs the specified custom command on the pod/s
 func runCustomCommandOnSplunkPods(ctx context.Context, cr splcommon.MetaObject, replicas int32, command string, podExecClient splutil.PodExecClientImpl) error {
 	var err error
@@ -468,6 +477,7 @@ func (downloadWorker *PipelineWorker) createDownloadDirOnOperator(ctx context.Co
 func (downloadWorker *PipelineWorker) download(ctx context.Context, pplnPhase *PipelinePhase, remoteDataClientMgr RemoteDataClientManager, localPath string, downloadWorkersRunPool chan struct{}) {
 
 	defer func() {
+		releaseAppPhaseSlot(downloadWorker)
 		downloadWorker.isActive = false
 
 		<-downloadWorkersRunPool
@@ -487,22 +497,25 @@ func (downloadWorker *PipelineWorker) download(ctx context.Context, pplnPhase *P
 	remoteFile, err := getRemoteObjectKey(ctx, splunkCR, downloadWorker.afwConfig, appSrcName, appName)
 	if err != nil {
 		scopedLog.Error(err, "unable to get remote object key", "appName", appName)
+		setAppDeployError(appDeployInfo, err)
 		// increment the retry count and mark this app as download pending
 		updatePplnWorkerPhaseInfo(ctx, appDeployInfo, appDeployInfo.PhaseInfo.FailCount+1, enterpriseApi.AppPkgDownloadPending)
 
 		return
 	}
 
-	// download the app from remote storage
-	err = remoteDataClientMgr.DownloadApp(ctx, remoteFile, localFile, appDeployInfo.ObjectHash)
+	// download the app from remote storage through the app package cache
+	err = downloadAppPkgThroughCache(ctx, remoteDataClientMgr, remoteFile, localFile, appName, appDeployInfo.ObjectHash, appDeployInfo.Size)
 	if err != nil {
 		scopedLog.Error(err, "unable to download app", "appName", appName)
+		setAppDeployError(appDeployInfo, err)
 
 		// remove the local file
 		err = os.RemoveAll(localFile)
 		if err != nil {
 			scopedLog.Error(err, "unable to remove local file from operator")
 		}
+		releaseAppPkgStorage(localFile)
 
 		// increment the retry count and mark this app as download pending
 		updatePplnWorkerPhaseInfo(ctx, appDeployInfo, appDeployInfo.PhaseInfo.FailCount+1, enterpriseApi.AppPkgDownloadPending)
@@ -537,6 +550,14 @@ downloadWork:
 					break downloadWork
 				}
 
+				// app package is streamed from the remote storage to the pods, nothing to download
+				if isAppPkgStreamable(ctx, downloadWorker) {
+					scopedLog.Info("app package is streamed to the pods, hence skipping the download.", "appSrcName", downloadWorker.appSrcName, "appName", downloadWorker.appDeployInfo.AppName)
+					updatePplnWorkerPhaseInfo(ctx, downloadWorker.appDeployInfo, 0, enterpriseApi.AppPkgDownloadComplete)
+					<-downloadWorkersRunPool
+					continue
+				}
+
 				// do not redownload the app if it is already downloaded
 				if isAppAlreadyDownloaded(ctx, downloadWorker) {
 					scopedLog.Info("app is already downloaded on operator pod, hence skipping it.", "appSrcName", downloadWorker.appSrcName, "appName", downloadWorker.appDeployInfo.AppName)
@@ -546,12 +567,30 @@ downloadWork:
 					continue
 				}
 
-				// do not proceed if we dont have enough disk space to download this app
+				// do not redownload the app if some other CR already downloaded it to the cache
+				if fetchAppPkgFromCache(ctx, downloadWorker) {
+					updatePplnWorkerPhaseInfo(ctx, downloadWorker.appDeployInfo, 0, enterpriseApi.AppPkgDownloadComplete)
+					<-downloadWorkersRunPool
+					continue
+				}
 
+				// wait for an operator wide download slot, so that the CRs share the downloads as per their priority
+				if !acquireAppPhaseSlot(ctx, ppln, enterpriseApi.PhaseDownload, downloadWorker) {
+					downloadWorker.isActive = false
+					<-downloadWorkersRunPool
+					continue
+				}
+
+				// do not proceed if we dont have enough disk space to download this app,
+				// even after evicting the app packages not in use from the cache
 				err := reserveStorage(downloadWorker.appDeployInfo.Size)
+				if err != nil && evictAppCache(ctx, downloadWorker.appDeployInfo.Size) > 0 {
+					err = reserveStorage(downloadWorker.appDeployInfo.Size)
+				}
 				if err != nil {
 					scopedLog.Error(err, "insufficient storage for the app pkg download. appSrcName: %s, app name: %s, app size: %d Bytes", downloadWorker.appSrcName, downloadWorker.appDeployInfo.AppName, downloadWorker.appDeployInfo.Size)
 					// setting isActive to false here so that downloadPhaseManager can take care of it.
+					releaseAppPhaseSlot(downloadWorker)
 					downloadWorker.isActive = false
 					<-downloadWorkersRunPool
 					continue
@@ -572,6 +611,7 @@ downloadWork:
 					// increment the retry count and mark this app as download pending
 					updatePplnWorkerPhaseInfo(ctx, appDeployInfo, appDeployInfo.PhaseInfo.FailCount+1, enterpriseApi.AppPkgDownloadPending)
 
+					releaseAppPhaseSlot(downloadWorker)
 					<-downloadWorkersRunPool
 					continue
 				}
@@ -694,7 +734,18 @@ func markWorkerPhaseInstallationComplete(ctx context.Context, phaseInfo *enterpr
 
 			//For now, set the deploy status as complete. Eventually, we can phase it out
 			worker.appDeployInfo.DeployStatus = enterpriseApi.DeployStatusComplete
+			worker.appDeployInfo.LastError = ""
 		}
+	} else if worker.appDeployInfo != nil {
+		worker.appDeployInfo.LastError = ""
+	}
+}
+
+// runInstallWorkerPlaybook runs the install playbook of a worker, and records the error, if any
+func runInstallWorkerPlaybook(ctx context.Context, worker *PipelineWorker, playbook PlaybookImpl) {
+	err := playbook.runPlaybook(ctx)
+	if err != nil {
+		setAppDeployError(worker.appDeployInfo, err)
 	}
 }
 
@@ -749,6 +800,7 @@ func installApp(rctx context.Context, localCtx *localScopePlaybookContext, cr sp
 
 		if appInstalled {
 			scopedLog.Info("Not reinstalling app as it is already installed.")
+			worker.appDeployInfo.Version = getAppPkgVersion(rctx, localCtx.podExecClient, appPkgPathOnPod, worker.appDeployInfo.AppPackageTopFolder)
 			return nil
 		}
 
@@ -765,6 +817,7 @@ func installApp(rctx context.Context, localCtx *localScopePlaybookContext, cr sp
 		return fmt.Errorf("local scoped app package install failed. stdOut: %s, stdErr: %s, app pkg path: %s, failCount: %d", stdOut, stdErr, appPkgPathOnPod, phaseInfo.FailCount)
 	}
 
+	worker.appDeployInfo.Version = getAppPkgVersion(rctx, localCtx.podExecClient, appPkgPathOnPod, worker.appDeployInfo.AppPackageTopFolder)
 	return nil
 }
 
@@ -861,6 +914,7 @@ func (localCtx *localScopePlaybookContext) runPlaybook(rctx context.Context) err
 	scopedLog := reqLogger.WithName("localScopePlaybookContext.runPlaybook").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
 
 	defer func() {
+		releaseAppPhaseSlot(worker)
 		<-localCtx.sem
 		worker.isActive = false
 		worker.waiter.Done()
@@ -876,9 +930,30 @@ func (localCtx *localScopePlaybookContext) runPlaybook(rctx context.Context) err
 		return fmt.Errorf("app pkg installation failed. error %s", err.Error())
 	}
 
+	// Restart splunk, if the app is configured with restartAfterInstall
+	err = restartSplunkAfterAppInstall(rctx, localCtx, cr, phaseInfo)
+	if err != nil {
+		scopedLog.Error(err, "splunk restart error after app package installation")
+		return fmt.Errorf("splunk restart failed after app pkg installation. error %s", err.Error())
+	}
+
+	// Verify the installed app, if configured
+	var sigTerm chan struct{}
+	if localCtx.afwPipeline != nil {
+		sigTerm = localCtx.afwPipeline.sigTerm
+	}
+	err = verifyAppInstallOrRollback(rctx, localCtx, phaseInfo, sigTerm)
+	if err != nil {
+		scopedLog.Error(err, "app package installation verification error")
+		return fmt.Errorf("app pkg installation verification failed. error %s", err.Error())
+	}
+
 	// Mark the worker for install complete status
 	markWorkerPhaseInstallationComplete(rctx, phaseInfo, worker)
 
+	// Keep the verified app package for the rollback of a later update
+	keepVerifiedAppPkg(rctx, worker)
+
 	// Call the API to cleanup the app
 	err = cleanupApp(rctx, localCtx, cr, phaseInfo)
 	if err != nil {
@@ -931,6 +1006,7 @@ func runPodCopyWorker(ctx context.Context, worker *PipelineWorker, ch chan struc
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("runPodCopyWorker").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "app name", worker.appDeployInfo.AppName, "pod", worker.targetPodName)
 	defer func() {
+		releaseAppPhaseSlot(worker)
 		<-ch
 		worker.isActive = false
 		worker.waiter.Done()
@@ -945,21 +1021,49 @@ func runPodCopyWorker(ctx context.Context, worker *PipelineWorker, ch chan struc
 	appPkgPathOnPod := filepath.Join(appBktMnt, worker.appSrcName, appPkgFileName)
 
 	phaseInfo := getPhaseInfoByPhaseType(ctx, worker, enterpriseApi.PhasePodCopy)
-	_, err := os.Stat(appPkgLocalPath)
-	if err != nil {
-		// Move the worker to download phase
-		scopedLog.Error(err, "app package is missing", "pod name", worker.targetPodName)
-		phaseInfo.Status = enterpriseApi.AppPkgMissingFromOperator
-		return
-	}
 
 	// get the podExecClient to be used for copying file to pod
 	podExecClient := splutil.GetPodExecClient(worker.client, cr, worker.targetPodName)
-	stdOut, stdErr, err := CopyFileToPod(ctx, worker.client, cr.GetNamespace(), appPkgLocalPath, appPkgPathOnPod, podExecClient)
-	if err != nil {
-		phaseInfo.FailCount++
-		scopedLog.Error(err, "app package pod copy failed", "stdout", stdOut, "stderr", stdErr, "failCount", phaseInfo.FailCount)
-		return
+
+	var err error
+	if isAppPkgStreamable(ctx, worker) {
+		// app package is not staged on the operator, stream it from the remote storage
+		err = streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+		if err != nil {
+			phaseInfo.FailCount++
+			scopedLog.Error(err, "app package streaming to the pod failed", "failCount", phaseInfo.FailCount)
+			setAppDeployError(worker.appDeployInfo, err)
+			return
+		}
+	} else {
+		_, err = os.Stat(appPkgLocalPath)
+		if err != nil {
+			// Move the worker to download phase
+			scopedLog.Error(err, "app package is missing", "pod name", worker.targetPodName)
+			phaseInfo.Status = enterpriseApi.AppPkgMissingFromOperator
+			setAppDeployError(worker.appDeployInfo, err)
+			return
+		}
+
+		// add the app config overlay files to the app package, if any
+		appPkgCopyPath, err := getAppPkgForPodCopy(ctx, worker, appPkgLocalPath)
+		if err != nil {
+			phaseInfo.FailCount++
+			scopedLog.Error(err, "unable to apply the app config overlay", "failCount", phaseInfo.FailCount)
+			setAppDeployError(worker.appDeployInfo, err)
+			return
+		}
+		if appPkgCopyPath != appPkgLocalPath {
+			defer os.Remove(appPkgCopyPath)
+		}
+
+		stdOut, stdErr, err := CopyFileToPod(ctx, worker.client, cr.GetNamespace(), appPkgCopyPath, appPkgPathOnPod, podExecClient)
+		if err != nil {
+			phaseInfo.FailCount++
+			scopedLog.Error(err, "app package pod copy failed", "stdout", stdOut, "stderr", stdErr, "failCount", phaseInfo.FailCount)
+			setAppDeployError(worker.appDeployInfo, err)
+			return
+		}
 	}
 
 	if appSrcScope == enterpriseApi.ScopeCluster {
@@ -967,6 +1071,7 @@ func runPodCopyWorker(ctx context.Context, worker *PipelineWorker, ch chan struc
 		if err != nil {
 			phaseInfo.FailCount++
 			scopedLog.Error(err, "extracting the app package on pod failed", "failCount", phaseInfo.FailCount)
+			setAppDeployError(worker.appDeployInfo, err)
 			return
 		}
 	}
@@ -976,7 +1081,7 @@ func runPodCopyWorker(ctx context.Context, worker *PipelineWorker, ch chan struc
 }
 
 // podCopyWorkerHandler fetches and runs the pod copy workers
-func (pplnPhase *PipelinePhase) podCopyWorkerHandler(ctx context.Context, handlerWaiter *sync.WaitGroup, numPodCopyRunners int) {
+func (pplnPhase *PipelinePhase) podCopyWorkerHandler(ctx context.Context, ppln *AppInstallPipeline, handlerWaiter *sync.WaitGroup, numPodCopyRunners int) {
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("podCopyWorkerHandler")
 	defer handlerWaiter.Done()
@@ -1001,6 +1106,13 @@ podCopyHandler:
 				}
 
 				if worker != nil {
+					// wait for an operator wide pod copy slot, so that the CRs share the pod copies as per their priority
+					if !acquireAppPhaseSlot(ctx, ppln, enterpriseApi.PhasePodCopy, worker) {
+						worker.isActive = false
+						<-podCopyWorkerPool
+						continue
+					}
+
 					worker.waiter.Add(1)
 					go runPodCopyWorker(ctx, worker, podCopyWorkerPool)
 				} else {
@@ -1040,7 +1152,7 @@ func (ppln *AppInstallPipeline) podCopyPhaseManager(ctx context.Context) {
 	// For now, for the number of parallel pod copy, use the max. concurrent downloads. Standalone is something unique, but at the same time
 	// limited by the Operator n/w bw, so hopefullye its Ok.
 	handlerWaiter.Add(1)
-	go pplnPhase.podCopyWorkerHandler(ctx, &handlerWaiter, int(ppln.appDeployContext.AppsStatusMaxConcurrentAppDownloads))
+	go pplnPhase.podCopyWorkerHandler(ctx, ppln, &handlerWaiter, int(ppln.appDeployContext.AppsStatusMaxConcurrentAppDownloads))
 	defer func() {
 		ppln.shutdownPipelinePhase(ctx, string(enterpriseApi.PhasePodCopy), pplnPhase, &handlerWaiter)
 	}()
@@ -1143,6 +1255,11 @@ func needToRunClusterScopedPlaybook(afwPipeline *AppInstallPipeline) bool {
 		return false
 	}
 
+	// bundle push is held till the next maintenance window
+	if afwPipeline.waitingForMaintenanceWindow && getBundlePushState(afwPipeline) == enterpriseApi.BundlePushPending {
+		return false
+	}
+
 	// Its already time to yield the current reconcile
 	if afwPipeline.afwEntryTime+int64(afwPipeline.appDeployContext.AppFrameworkConfig.SchedulerYieldInterval) < time.Now().Unix() {
 		return false
@@ -1186,6 +1303,15 @@ installHandler:
 				podExecClient := splutil.GetPodExecClient(installWorker.client, installWorker.cr, installWorker.targetPodName)
 				podID, _ := getOrdinalValFromPodName(installWorker.targetPodName)
 
+				// check for an operator wide install slot, so that the CRs share the installs as per their priority. Till
+				// the slot is granted, the install slot of the pod is freed up, and the worker is picked again later
+				if !tryAcquireAppPhaseSlot(ctx, enterpriseApi.PhaseInstall, installWorker) {
+					installWorker.isActive = false
+					installWorker.waiter = nil
+					<-installTracker[podID]
+					continue
+				}
+
 				// Get app source spec
 				appSrcSpec, err := getAppSrcSpec(installWorker.afwConfig.AppSources, installWorker.appSrcName)
 				if err != nil {
@@ -1205,8 +1331,9 @@ installHandler:
 				if iwctx != nil {
 					// Handle install work
 					installWorker.waiter.Add(1)
-					go iwctx.runPlaybook(ctx)
+					go runInstallWorkerPlaybook(ctx, installWorker, iwctx)
 				} else {
+					releaseAppPhaseSlot(installWorker)
 					<-installTracker[podID]
 					scopedLog.Error(nil, "unable to get install worker context. app name %s", installWorker.appDeployInfo.AppName)
 				}
@@ -1224,7 +1351,7 @@ installHandler:
 
 	for {
 		if needToRunClusterScopedPlaybook(ppln) {
-			targetPodName := getApplicablePodNameForAppFramework(ppln.cr, 0)
+			targetPodName := getAppFrameworkTargetPodName(ppln.cr)
 			podExecClient := splutil.GetPodExecClient(ppln.client, ppln.cr, targetPodName)
 
 			// sgontla: can we just pass the CR???
@@ -1278,6 +1405,13 @@ func (ppln *AppInstallPipeline) installPhaseManager(ctx context.Context) {
 	go pplnPhase.installWorkerHandler(ctx, ppln, &handlerWaiter, podInstallTracker)
 	defer func() {
 		ppln.shutdownPipelinePhase(ctx, string(enterpriseApi.PhaseInstall), pplnPhase, &handlerWaiter)
+
+		// withdraw the operator wide slot requests of the workers that never got to run
+		pplnPhase.mutex.Lock()
+		for _, installWorker := range pplnPhase.q {
+			releaseAppPhaseSlot(installWorker)
+		}
+		pplnPhase.mutex.Unlock()
 	}()
 
 installPhase:
@@ -1307,8 +1441,22 @@ installPhase:
 					ppln.deleteWorkerFromPipelinePhase(ctx, phaseInfo.Phase, installWorker)
 				} else if phaseInfo.Status == enterpriseApi.AppPkgMissingOnPodError {
 					ppln.transitionWorkerPhase(ctx, installWorker, enterpriseApi.PhaseInstall, enterpriseApi.PhasePodCopy)
-				} else if checkIfWorkerIsEligibleForRun(ctx, installWorker, phaseInfo, enterpriseApi.AppPkgInstallComplete) &&
-					getInstallSlotForPod(ctx, podInstallTracker, installWorker.targetPodName) {
+				} else if !ppln.waitingForMaintenanceWindow &&
+					checkIfWorkerIsEligibleForRun(ctx, installWorker, phaseInfo, enterpriseApi.AppPkgInstallComplete) {
+					dependenciesInstalled, err := areAppDependenciesInstalledOnPod(ppln, installWorker)
+					if err != nil {
+						// the app can never be installed, so fail it rather than waiting for its dependencies forever
+						scopedLog.Error(err, "app dependency can't be installed", "name", installWorker.cr.GetName(), "namespace", installWorker.cr.GetNamespace(), "pod name", installWorker.targetPodName, "App name", installWorker.appDeployInfo.AppName)
+						setAppDeployError(installWorker.appDeployInfo, err)
+						phaseInfo.Status = enterpriseApi.AppPkgInstallError
+						phaseInfo.FailCount = installWorker.afwConfig.PhaseMaxRetries + 1
+						ppln.deleteWorkerFromPipelinePhase(ctx, phaseInfo.Phase, installWorker)
+						continue
+					}
+					if !dependenciesInstalled || !getInstallSlotForPod(ctx, podInstallTracker, installWorker.targetPodName) {
+						continue
+					}
+
 					installWorker.waiter = &pplnPhase.workerWaiter
 					select {
 					case pplnPhase.msgChannel <- installWorker:
@@ -1441,6 +1589,7 @@ func initAppInstallPipeline(ctx context.Context, appDeployContext *enterpriseApi
 	afwPipeline.cr = cr
 	afwPipeline.client = client
 	afwPipeline.sts = afwGetReleventStatefulsetByKind(ctx, cr, client)
+	afwPipeline.waitingForMaintenanceWindow = isWaitingForMaintenanceWindow(appDeployContext)
 
 	// Allocate the Download phase
 	initPipelinePhase(afwPipeline, enterpriseApi.PhaseDownload)
@@ -1460,7 +1609,12 @@ func deleteAppPkgFromOperator(ctx context.Context, worker *PipelineWorker) {
 	scopedLog := reqLogger.WithName("deleteAppPkgFromOperator").WithValues("name", worker.cr.GetName(), "namespace", worker.cr.GetNamespace(), "app pkg", worker.appDeployInfo.AppName)
 
 	appPkgLocalPath := getAppPackageLocalPath(ctx, worker)
+
 	err := os.Remove(appPkgLocalPath)
+	if err != nil && os.IsNotExist(err) && isAppPkgStreamable(ctx, worker) {
+		// app package was streamed to the pods, so it was never on the Operator
+		return
+	}
 	if err != nil {
 		// Issue is local, so just log an error msg and return
 		// ToDo: sgontla: For any transient errors, handle the clean-up at the end of the install
@@ -1469,7 +1623,9 @@ func deleteAppPkgFromOperator(ctx context.Context, worker *PipelineWorker) {
 	}
 
 	scopedLog.Info("Deleted app package from the operator", "App package path", appPkgLocalPath)
-	releaseStorage(worker.appDeployInfo.Size)
+
+	// the disk space of a cached app pkg is released when it is evicted from the cache
+	releaseAppPkgStorage(appPkgLocalPath)
 }
 
 func afwGetReleventStatefulsetByKind(ctx context.Context, cr splcommon.MetaObject, client splcommon.ControllerClient) *appsv1.StatefulSet {
@@ -1541,11 +1697,12 @@ func getSHCPlaybookContext(ctx context.Context, client splcommon.ControllerClien
 }
 
 // getLocalScopePlaybookContext returns the local scoped app install playbook context
-func getLocalScopePlaybookContext(ctx context.Context, installWorker *PipelineWorker, sem chan struct{}, podExecClient splutil.PodExecClientImpl) *localScopePlaybookContext {
+func getLocalScopePlaybookContext(ctx context.Context, installWorker *PipelineWorker, sem chan struct{}, podExecClient splutil.PodExecClientImpl, afwPipeline *AppInstallPipeline) *localScopePlaybookContext {
 	return &localScopePlaybookContext{
 		worker:        installWorker,
 		sem:           sem,
 		podExecClient: podExecClient,
+		afwPipeline:   afwPipeline,
 	}
 }
 
@@ -1556,7 +1713,7 @@ func getInsallWorkerPlaybookContext(ctx context.Context, worker *PipelineWorker,
 	scopedLog := reqLogger.WithName("getInsallWorkerPlaybookContext").WithValues("crName", ppln.cr.GetName(), "namespace", ppln.cr.GetNamespace())
 
 	// Since local app context is needed for premiumAppContext we retrieve it for both cases
-	localCtx := getLocalScopePlaybookContext(ctx, worker, sem, podExecClient)
+	localCtx := getLocalScopePlaybookContext(ctx, worker, sem, podExecClient, ppln)
 	if appSrcScope == enterpriseApi.ScopeLocal {
 		return localCtx
 	} else if appSrcScope == enterpriseApi.ScopePremiumApps {
@@ -1974,54 +2131,9 @@ func (idxcPlaybookContext *IdxcPlaybookContext) runPlaybook(ctx context.Context)
 	return nil
 }
 
-// getSslCliOption gets the ssl cli option for installing ES app.
-// Returns `strict` if not configured. Note: Validation of spec done already
-// Reference: https://docs.splunk.com/Documentation/ES/latest/Install/InstallEnterpriseSecuritySHC
-func getSslCliOption(appSrcSpec *enterpriseApi.AppSourceSpec) string {
-	sslEn := appSrcSpec.PremiumAppsProps.EsDefaults.SslEnablement
-	if sslEn != "" {
-		return sslEn
-	}
-
-	return enterpriseApi.SslEnablementStrict
-}
-
-// Handles ES app post install steps
-func handleEsappPostinstall(rctx context.Context, preCtx *premiumAppScopePlaybookContext, phaseInfo *enterpriseApi.PhaseInfo) error {
-	worker := preCtx.localCtx.worker
-	cr := preCtx.cr
-	appSrcSpec := preCtx.appSrcSpec
-
-	reqLogger := log.FromContext(rctx)
-	scopedLog := reqLogger.WithName("handleEsappPostinstall").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
-
-	// For ES app, run post-install commands
-	var command string
-
-	// Create CLI command
-	sslEn := getSslCliOption(appSrcSpec)
-	if cr.GetObjectKind().GroupVersionKind().Kind != "SearchHeadCluster" {
-		command = fmt.Sprintf("/opt/splunk/bin/splunk search '| essinstall --ssl_enablement %s' -auth admin:`cat /mnt/splunk-secrets/password`", sslEn)
-	} else {
-		// Pass an extra parameter for SHC deployer in post install command
-		command = fmt.Sprintf("/opt/splunk/bin/splunk search '| essinstall --ssl_enablement %s --deployment_type shc_deployer' -auth admin:`cat /mnt/splunk-secrets/password`", sslEn)
-	}
-
-	streamOptions := splutil.NewStreamOptionsObject(command)
-	stdOut, stdErr, err := preCtx.localCtx.podExecClient.RunPodExecCommand(rctx, streamOptions, []string{"/bin/sh"})
-	if stdErr != "" || err != nil {
-		phaseInfo.FailCount++
-		scopedLog.Error(err, "premium scoped app package install failed", "stdout", stdOut, "stderr", stdErr, "post install command", command, "failCount", phaseInfo.FailCount)
-		return fmt.Errorf("premium scoped app package install failed. stdOut: %s, stdErr: %s, post install command: %s, failCount: %d", stdOut, stdErr, command, phaseInfo.FailCount)
-	}
-
-	return nil
-}
-
 // runPlaybook implements installing the app for premiumApps
-// For ES app:
 //  1. Installs the app like any other app on standalone/SHC deployer
-//  2. Runs the post install command for the ES app
+//  2. Runs the post install steps of the premium app type
 //  3. Sets the bundle push flag for the deployer only
 func (preCtx *premiumAppScopePlaybookContext) runPlaybook(rctx context.Context) error {
 	cr := preCtx.cr
@@ -2032,6 +2144,7 @@ func (preCtx *premiumAppScopePlaybookContext) runPlaybook(rctx context.Context)
 	scopedLog := reqLogger.WithName("premiumAppScopePlaybookContext.runPlaybook").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
 
 	defer func() {
+		releaseAppPhaseSlot(worker)
 		<-preCtx.localCtx.sem
 		worker.isActive = false
 		worker.waiter.Done()
@@ -2047,18 +2160,39 @@ func (preCtx *premiumAppScopePlaybookContext) runPlaybook(rctx context.Context)
 		return fmt.Errorf("app pkg installation failed. error %s", err.Error())
 	}
 
-	// Handle post install for ES app
-	if appSrcSpec.PremiumAppsProps.Type == enterpriseApi.PremiumAppsTypeEs {
-		err = handleEsappPostinstall(rctx, preCtx, phaseInfo)
+	// Handle post install for the premium app type
+	if premiumAppType, ok := getPremiumAppType(appSrcSpec.PremiumAppsProps.Type); ok {
+		err = premiumAppType.postInstall(rctx, preCtx, phaseInfo)
 		if err != nil {
 			scopedLog.Error(err, "app package post installation error")
 			return fmt.Errorf("app pkg post installation failed. error %s", err.Error())
 		}
 	}
 
+	// Restart splunk, if the app is configured with restartAfterInstall
+	err = restartSplunkAfterAppInstall(rctx, preCtx.localCtx, cr, phaseInfo)
+	if err != nil {
+		scopedLog.Error(err, "splunk restart error after premium app package installation")
+		return fmt.Errorf("splunk restart failed after app pkg installation. error %s", err.Error())
+	}
+
+	// Verify the installed app, if configured
+	var sigTerm chan struct{}
+	if preCtx.afwPipeline != nil {
+		sigTerm = preCtx.afwPipeline.sigTerm
+	}
+	err = verifyAppInstallOrRollback(rctx, preCtx.localCtx, phaseInfo, sigTerm)
+	if err != nil {
+		scopedLog.Error(err, "premium app package installation verification error")
+		return fmt.Errorf("app pkg installation verification failed. error %s", err.Error())
+	}
+
 	// Mark app package installation complete
 	markWorkerPhaseInstallationComplete(rctx, phaseInfo, worker)
 
+	// Keep the verified app package for the rollback of a later update
+	keepVerifiedAppPkg(rctx, worker)
+
 	// Call the API to clean up app
 	err = cleanupApp(rctx, preCtx.localCtx, cr, phaseInfo)
 	if err != nil {
@@ -2149,7 +2283,7 @@ func afwSchedulerEntry(ctx context.Context, client splcommon.ControllerClient, c
 		deployInfoList := appSrcDeployInfo.AppDeploymentInfoList
 
 		sts := afwGetReleventStatefulsetByKind(ctx, cr, client)
-		podName := getApplicablePodNameForAppFramework(cr, 0)
+		podName := getAppFrameworkTargetPodName(cr)
 
 		podExecClient := splutil.GetPodExecClient(client, cr, podName)
 		appsPathOnPod := filepath.Join(appBktMnt, appSrcName)
@@ -2186,6 +2320,12 @@ func afwSchedulerEntry(ctx context.Context, client splcommon.ControllerClient, c
 	// Finally mark if all the App framework is complete
 	checkAndUpdateAppFrameworkProgressFlag(afwPipeline)
 
+	// rest of the work is resumed when the next maintenance window starts
+	if afwPipeline.isOnlyHeldWorkPending() {
+		scopedLog.Info("App installs are waiting for the maintenance window", "nextWindowStart", appDeployContext.MaintenanceWindowStatus.NextWindowStart)
+		return false, nil
+	}
+
 	return needToRevisitAppFramework(afwPipeline), nil
 }
 
@@ -2202,7 +2342,7 @@ yieldScheduler:
 			scopedLog.Info("Yielding from AFW scheduler", "time elapsed", time.Now().Unix()-ppln.afwEntryTime)
 			break yieldScheduler
 		default:
-			if ppln.isPipelineEmpty() {
+			if ppln.isPipelineEmpty() || ppln.isOnlyHeldWorkPending() {
 				break yieldScheduler
 			}
 		}
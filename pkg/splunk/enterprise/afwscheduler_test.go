@@ -1275,21 +1275,6 @@ func TestGetPhaseInfoByPhaseType(t *testing.T) {
 	}
 }
 
-func TestGetSslCliOption(t *testing.T) {
-	appSrcSpec := &enterpriseApi.AppSourceSpec{
-		AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{
-			PremiumAppsProps: enterpriseApi.PremiumAppsProps{
-				EsDefaults: enterpriseApi.EsDefaults{
-					SslEnablement: "abc",
-				},
-			},
-		},
-	}
-	if getSslCliOption(appSrcSpec) != "abc" {
-		t.Errorf("Incorrect ssl enablement option returned")
-	}
-}
-
 func TestAfwGetReleventStatefulsetByKind(t *testing.T) {
 	ctx := context.TODO()
 	cr := enterpriseApi.ClusterManager{
@@ -1496,6 +1481,13 @@ func TestPipelineWorkerDownloadShouldPass(t *testing.T) {
 		}
 	}
 
+	// the mock downloader writes the app packages of the expected size
+	defer func(sizes map[string]int64) { spltest.MockAWSDownloadSizes = sizes }(spltest.MockAWSDownloadSizes)
+	spltest.MockAWSDownloadSizes = map[string]int64{}
+	for index, appSrc := range cr.Spec.AppFrameworkConfig.AppSources {
+		spltest.MockAWSDownloadSizes[appSrc.Location+"/"+testApps[index]] = testSizes[index]
+	}
+
 	client := spltest.NewMockClient()
 
 	// Create S3 secret
@@ -2253,7 +2245,7 @@ func TestPodCopyWorkerHandler(t *testing.T) {
 	var handlerWaiter sync.WaitGroup
 	handlerWaiter.Add(1)
 	defer handlerWaiter.Wait()
-	go ppln.pplnPhases[enterpriseApi.PhaseInstall].podCopyWorkerHandler(ctx, &handlerWaiter, 5)
+	go ppln.pplnPhases[enterpriseApi.PhaseInstall].podCopyWorkerHandler(ctx, ppln, &handlerWaiter, 5)
 
 	worker.waiter = &ppln.pplnPhases[enterpriseApi.PhaseInstall].workerWaiter
 
@@ -3368,12 +3360,25 @@ func TestDeleteAppPkgFromOperator(t *testing.T) {
 	}
 	defer os.Remove(appPkgLocalPath)
 
+	// app package that could not be cached holds the storage reserved for its download
+	holdAppPkgStorage(appPkgLocalPath, worker.appDeployInfo.Size)
+
 	diskSpaceBeforeRemoval := operatorResourceTracker.storage.availableDiskSpace
 	deleteAppPkgFromOperator(ctx, worker)
 
 	if operatorResourceTracker.storage.availableDiskSpace != diskSpaceBeforeRemoval+worker.appDeployInfo.Size {
 		t.Errorf("Unable to clean up the app package on Operator pod")
 	}
+
+	// reserved storage is released only once
+	_, err = os.Create(appPkgLocalPath)
+	if err != nil {
+		t.Errorf("Unable to create the local package file, error: %v", err)
+	}
+	deleteAppPkgFromOperator(ctx, worker)
+	if operatorResourceTracker.storage.availableDiskSpace != diskSpaceBeforeRemoval+worker.appDeployInfo.Size {
+		t.Errorf("storage of the app package should have been released only once")
+	}
 }
 
 func TestGetInstallSlotForPod(t *testing.T) {
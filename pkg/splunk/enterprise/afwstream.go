@@ -0,0 +1,215 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// With the stream transfer mode, the download phase doesn't download the app packages to the operator. Instead, the pod
+// copy phase streams each app package from the remote storage straight into the pod over the exec stream. The package
+// is written to a partial file on the pod first, and renamed only after the whole package is received, so that an
+// interrupted stream never leaves a truncated app package behind. The size and the checksum of the partial file
+// are verified before it is renamed.
+
+// appPkgStreamPartialSuffix is the suffix of the app package on the pod, while it is being streamed
+const appPkgStreamPartialSuffix = ".part"
+
+// isAppPkgStreamable checks if the app package of the worker is to be streamed from the remote storage to the pods
+func isAppPkgStreamable(ctx context.Context, worker *PipelineWorker) bool {
+	afwConfig := worker.afwConfig
+	if afwConfig == nil || afwConfig.TransferMode != enterpriseApi.AppTransferModeStream {
+		return false
+	}
+
+	// the config overlay files are added to the app package on the operator, so it has to be staged
+	if getAppConfigOverlayConfigMapName(afwConfig, worker.appSrcName, worker.appDeployInfo.AppName) != "" {
+		return false
+	}
+
+	// the size is needed to tell a complete stream from a truncated one
+	if worker.appDeployInfo.Size == 0 {
+		return false
+	}
+
+	// the app package is converted into a tarball on the operator
+	if isAppPkgNormalizedOnOperator(worker.appDeployInfo.AppName, worker.appDeployInfo.ObjectHash) {
+		return false
+	}
+
+	appSrc, err := getAppSrcSpec(afwConfig.AppSources, worker.appSrcName)
+	if err != nil {
+		return false
+	}
+
+	vol, err := splclient.GetAppSrcVolume(ctx, *appSrc, afwConfig)
+	if err != nil {
+		return false
+	}
+
+	return splclient.IsAppStreamingSupported(vol.Provider)
+}
+
+// appPkgStreamReader counts and hashes the bytes read from the app package stream, and records how the stream ended
+type appPkgStreamReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	read   int64
+	eof    bool
+	err    error
+}
+
+// Read reads from the app package stream
+func (streamReader *appPkgStreamReader) Read(p []byte) (int, error) {
+	n, err := streamReader.reader.Read(p)
+	streamReader.hash.Write(p[:n])
+	streamReader.read += int64(n)
+	if err == io.EOF {
+		streamReader.eof = true
+	} else if err != nil {
+		streamReader.err = err
+	}
+	return n, err
+}
+
+// getAppPkgStreamChecksum returns the checksum command to run on the pod, along with the matching hash, and the
+// checksum of the app package on the remote storage. A sha256 checksum(http) and an MD5 etag(aws, minio) are
+// known upfront. Other etags(azure, multipart) are not checksums, so the app package on the pod is verified
+// against the streamed bytes only
+func getAppPkgStreamChecksum(objectHash string) (string, hash.Hash, string) {
+	digest := strings.ToLower(strings.Trim(objectHash, "\""))
+	switch {
+	case sha256DigestRegex.MatchString(digest):
+		return "sha256sum", sha256.New(), digest
+	case md5DigestRegex.MatchString(digest):
+		return "md5sum", md5.New(), digest
+	default:
+		return "md5sum", md5.New(), ""
+	}
+}
+
+// verifyStreamedAppPkgOnPod verifies the checksum of the streamed app package, and of the partial file on the pod
+func verifyStreamedAppPkgOnPod(ctx context.Context, partialPath string, checksumCommand string, streamReader *appPkgStreamReader, expectedDigest string, podExecClient splutil.PodExecClientImpl) error {
+	streamedDigest := hex.EncodeToString(streamReader.hash.Sum(nil))
+	if expectedDigest != "" && streamedDigest != expectedDigest {
+		return fmt.Errorf("checksum mismatch for the app package, expected: %s, got: %s", expectedDigest, streamedDigest)
+	}
+
+	command := fmt.Sprintf("%s %s | cut -d ' ' -f 1", checksumCommand, partialPath)
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil || stdErr != "" {
+		return fmt.Errorf("unable to get the checksum of the app package on the pod. stdErr: %s, err: %v", stdErr, err)
+	}
+
+	podDigest := strings.TrimSpace(stdOut)
+	if podDigest != streamedDigest {
+		return fmt.Errorf("checksum mismatch for the app package on the pod, expected: %s, got: %s", streamedDigest, podDigest)
+	}
+
+	return nil
+}
+
+// openAppPkgStream opens a stream to read the app package of the worker from the remote storage
+var openAppPkgStream = func(ctx context.Context, worker *PipelineWorker) (io.ReadCloser, error) {
+	remoteFile, err := getRemoteObjectKey(ctx, worker.cr, worker.afwConfig, worker.appSrcName, worker.appDeployInfo.AppName)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteDataClientMgr, err := getRemoteDataClientMgr(ctx, worker.client, worker.cr, worker.afwConfig, worker.appSrcName)
+	if err != nil {
+		return nil, err
+	}
+
+	return remoteDataClientMgr.StreamApp(ctx, remoteFile, worker.appDeployInfo.ObjectHash)
+}
+
+// streamAppPkgToPod streams the app package of the worker from the remote storage to the given path on the pod
+func streamAppPkgToPod(ctx context.Context, worker *PipelineWorker, appPkgPathOnPod string, podExecClient splutil.PodExecClientImpl) error {
+	cr := worker.cr
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("streamAppPkgToPod").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "app name", worker.appDeployInfo.AppName, "pod", worker.targetPodName)
+
+	if worker.appDeployInfo.Size == 0 {
+		return fmt.Errorf("size of the app package is unknown, so the stream can't be verified")
+	}
+
+	// Make sure the destination directory is existing
+	command := fmt.Sprintf("test -d %s; echo -n $?", path.Dir(appPkgPathOnPod))
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	dirTestResult, _ := strconv.Atoi(stdOut)
+	if err != nil || dirTestResult != 0 {
+		return fmt.Errorf("directory on Pod doesn't exist. stdout: %s, stdErr: %s, err: %v", stdOut, stdErr, err)
+	}
+
+	stream, err := openAppPkgStream(ctx, worker)
+	if err != nil {
+		return fmt.Errorf("unable to open the app package stream from remote storage. error: %v", err)
+	}
+	defer stream.Close()
+
+	partialPath := appPkgPathOnPod + appPkgStreamPartialSuffix
+	checksumCommand, checksumHash, expectedDigest := getAppPkgStreamChecksum(worker.appDeployInfo.ObjectHash)
+	streamReader := &appPkgStreamReader{reader: stream, hash: checksumHash}
+	streamOptions := splutil.NewStreamOptionsObject("")
+	streamOptions.Stdin = streamReader
+
+	_, stdErr, err = podExecClient.RunPodExecCommand(ctx, streamOptions, []string{"dd", "of=" + partialPath, "bs=1M"})
+	switch {
+	case err != nil:
+		err = fmt.Errorf("writing the app package on the pod failed. stdErr: %s, err: %v", stdErr, err)
+	case streamReader.err != nil:
+		err = fmt.Errorf("reading the app package from remote storage failed. error: %v", streamReader.err)
+	case !streamReader.eof:
+		err = fmt.Errorf("app package stream ended after %d bytes, before reading the whole app package", streamReader.read)
+	case uint64(streamReader.read) != worker.appDeployInfo.Size:
+		err = fmt.Errorf("size mismatch for the app package, expected: %d, got: %d", worker.appDeployInfo.Size, streamReader.read)
+	default:
+		err = verifyStreamedAppPkgOnPod(ctx, partialPath, checksumCommand, streamReader, expectedDigest, podExecClient)
+	}
+
+	if err != nil {
+		command = fmt.Sprintf("rm -f %s", partialPath)
+		_, _, rmErr := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+		if rmErr != nil {
+			scopedLog.Error(rmErr, "unable to remove the partial app package from the pod", "path", partialPath)
+		}
+		return err
+	}
+
+	command = fmt.Sprintf("mv -f %s %s", partialPath, appPkgPathOnPod)
+	stdOut, stdErr, err = podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil || stdErr != "" {
+		return fmt.Errorf("unable to move the app package to %s. stdout: %s, stdErr: %s, err: %v", appPkgPathOnPod, stdOut, stdErr, err)
+	}
+
+	scopedLog.Info("App package streamed to the pod", "bytes", streamReader.read, "path", appPkgPathOnPod)
+	return nil
+}
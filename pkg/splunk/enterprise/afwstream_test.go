@@ -0,0 +1,239 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// streamPodExecClient mocks the pod exec client, and keeps the data streamed to the pod
+type streamPodExecClient struct {
+	spltest.MockPodExecClient
+	streamed bytes.Buffer
+}
+
+// RunPodExecCommand drains the stdin for the streaming command, other commands are mocked
+func (client *streamPodExecClient) RunPodExecCommand(ctx context.Context, streamOptions *remotecommand.StreamOptions, baseCmd []string) (string, string, error) {
+	if baseCmd[0] == "dd" {
+		client.GotCmdList = append(client.GotCmdList, strings.Join(baseCmd, " "))
+		_, err := io.Copy(&client.streamed, streamOptions.Stdin)
+		return "", "", err
+	}
+	return client.MockPodExecClient.RunPodExecCommand(ctx, streamOptions, baseCmd)
+}
+
+// failingReader returns the data, and then fails
+type failingReader struct {
+	data io.Reader
+}
+
+func (reader *failingReader) Read(p []byte) (int, error) {
+	n, err := reader.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func getStreamTestAppFrameworkSpec(provider string) enterpriseApi.AppFrameworkSpec {
+	return enterpriseApi.AppFrameworkSpec{
+		TransferMode: enterpriseApi.AppTransferModeStream,
+		VolList: []enterpriseApi.VolumeSpec{
+			{Name: "vol1", Endpoint: "https://s3-eu-west-2.amazonaws.com", Path: "testbucket-rs-london", SecretRef: "s3-secret", Type: "s3", Provider: provider},
+		},
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{Name: "appSrc1", Location: "adminAppsRepo", AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{VolName: "vol1", Scope: enterpriseApi.ScopeLocal}},
+		},
+	}
+}
+
+func TestIsAppPkgStreamable(t *testing.T) {
+	ctx := context.TODO()
+	afwConfig := getStreamTestAppFrameworkSpec("aws")
+	worker := &PipelineWorker{
+		appSrcName:    "appSrc1",
+		afwConfig:     &afwConfig,
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz", Size: 10},
+	}
+
+	if !isAppPkgStreamable(ctx, worker) {
+		t.Errorf("app package should be streamed for the s3 volume")
+	}
+
+	worker.appDeployInfo.Size = 0
+	if isAppPkgStreamable(ctx, worker) {
+		t.Errorf("app package should not be streamed, when its size is unknown")
+	}
+	worker.appDeployInfo.Size = 10
+
+	afwConfig.VolList[0].Provider = "git"
+	if isAppPkgStreamable(ctx, worker) {
+		t.Errorf("app package should not be streamed, when the provider doesn't support streaming")
+	}
+
+	afwConfig.VolList[0].Provider = "minio"
+	afwConfig.AppSources[0].AppConfigOverlays = []enterpriseApi.AppConfigOverlaySpec{{AppName: "app1.tgz", ConfigMapName: "app1-overlay"}}
+	if isAppPkgStreamable(ctx, worker) {
+		t.Errorf("app package should not be streamed, when it has the config overlay")
+	}
+
+	afwConfig.AppSources[0].AppConfigOverlays = nil
+	afwConfig.TransferMode = enterpriseApi.AppTransferModeStaged
+	if isAppPkgStreamable(ctx, worker) {
+		t.Errorf("app package should not be streamed, when the transfer mode is staged")
+	}
+
+	afwConfig.TransferMode = ""
+	if isAppPkgStreamable(ctx, worker) {
+		t.Errorf("app package should be staged by default")
+	}
+}
+
+func TestStreamAppPkgToPod(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.AppFrameworkConfig = getStreamTestAppFrameworkSpec("aws")
+
+	appPkg := "app package contents"
+	worker := &PipelineWorker{
+		cr:            &cr,
+		appSrcName:    "appSrc1",
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		targetPodName: "splunk-stack1-standalone-0",
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz", ObjectHash: "abcd1234", Size: uint64(len(appPkg))},
+	}
+
+	savedOpenAppPkgStream := openAppPkgStream
+	defer func() { openAppPkgStream = savedOpenAppPkgStream }()
+
+	var stream io.Reader
+	openAppPkgStream = func(ctx context.Context, worker *PipelineWorker) (io.ReadCloser, error) {
+		return io.NopCloser(stream), nil
+	}
+
+	appPkgPathOnPod := "/init-apps/appSrc1/app1.tgz_abcd1234"
+	appPkgDigest := md5.Sum([]byte(appPkg))
+	podDigest := hex.EncodeToString(appPkgDigest[:])
+	getPodExecClient := func() *streamPodExecClient {
+		podExecClient := &streamPodExecClient{}
+		podExecClient.AddMockPodExecReturnContext(ctx, "test -d /init-apps/appSrc1", &spltest.MockPodExecReturnContext{StdOut: "0"})
+		podExecClient.AddMockPodExecReturnContext(ctx, "md5sum "+appPkgPathOnPod+".part", &spltest.MockPodExecReturnContext{StdOut: podDigest + "\n"})
+		podExecClient.AddMockPodExecReturnContext(ctx, "rm -f", &spltest.MockPodExecReturnContext{})
+		podExecClient.AddMockPodExecReturnContext(ctx, "mv -f", &spltest.MockPodExecReturnContext{})
+		return podExecClient
+	}
+
+	// app package is streamed to the partial file, and moved in place once it is complete
+	stream = strings.NewReader(appPkg)
+	podExecClient := getPodExecClient()
+	err := streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+	if err != nil {
+		t.Errorf("unable to stream the app package. error: %v", err)
+	}
+	if podExecClient.streamed.String() != appPkg {
+		t.Errorf("app package contents are not streamed to the pod. got: %s", podExecClient.streamed.String())
+	}
+	wantCmds := []string{"test -d /init-apps/appSrc1", "dd of=" + appPkgPathOnPod + ".part bs=1M", "md5sum " + appPkgPathOnPod + ".part", "mv -f"}
+	if strings.Join(podExecClient.GotCmdList, ",") != strings.Join(wantCmds, ",") {
+		t.Errorf("unexpected pod exec commands. got: %v, want: %v", podExecClient.GotCmdList, wantCmds)
+	}
+
+	// partial file is removed, when the remote storage stream fails
+	stream = &failingReader{data: strings.NewReader(appPkg)}
+	podExecClient = getPodExecClient()
+	err = streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("remote storage read error should be returned. error: %v", err)
+	}
+	if podExecClient.GotCmdList[len(podExecClient.GotCmdList)-1] != "rm -f" {
+		t.Errorf("partial app package should be removed from the pod. got: %v", podExecClient.GotCmdList)
+	}
+
+	// partial file is removed, when the size doesn't match
+	stream = strings.NewReader(appPkg[1:])
+	podExecClient = getPodExecClient()
+	err = streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+	if err == nil || !strings.Contains(err.Error(), "size mismatch") {
+		t.Errorf("size mismatch should be returned. error: %v", err)
+	}
+
+	// app package with an MD5 etag is verified against the etag
+	worker.appDeployInfo.ObjectHash = "\"" + podDigest + "\""
+	stream = strings.NewReader(appPkg)
+	podExecClient = getPodExecClient()
+	err = streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+	if err != nil {
+		t.Errorf("app package matching the MD5 etag should be streamed. error: %v", err)
+	}
+	worker.appDeployInfo.ObjectHash = "0123456789abcdef0123456789abcdef"
+	stream = strings.NewReader(appPkg)
+	podExecClient = getPodExecClient()
+	err = streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch for the app package") || podExecClient.GotCmdList[len(podExecClient.GotCmdList)-1] != "rm -f" {
+		t.Errorf("MD5 etag mismatch should be returned, and the partial app package removed. error: %v", err)
+	}
+	worker.appDeployInfo.ObjectHash = "abcd1234"
+
+	// partial file is removed, when it doesn't match the streamed app package
+	stream = strings.NewReader(appPkg)
+	podExecClient = &streamPodExecClient{}
+	podExecClient.AddMockPodExecReturnContext(ctx, "test -d /init-apps/appSrc1", &spltest.MockPodExecReturnContext{StdOut: "0"})
+	podExecClient.AddMockPodExecReturnContext(ctx, "md5sum", &spltest.MockPodExecReturnContext{StdOut: "0123456789abcdef0123456789abcdef"})
+	podExecClient.AddMockPodExecReturnContext(ctx, "rm -f", &spltest.MockPodExecReturnContext{})
+	err = streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch for the app package on the pod") || podExecClient.GotCmdList[len(podExecClient.GotCmdList)-1] != "rm -f" {
+		t.Errorf("checksum mismatch on the pod should be returned, and the partial app package removed. error: %v", err)
+	}
+
+	// app package of an unknown size is not streamed
+	worker.appDeployInfo.Size = 0
+	stream = strings.NewReader(appPkg)
+	podExecClient = getPodExecClient()
+	err = streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+	if err == nil || podExecClient.streamed.Len() != 0 {
+		t.Errorf("app package of an unknown size should not be streamed")
+	}
+	worker.appDeployInfo.Size = uint64(len(appPkg))
+
+	// app package is not streamed, when the directory on the pod is missing
+	stream = strings.NewReader(appPkg)
+	podExecClient = &streamPodExecClient{}
+	podExecClient.AddMockPodExecReturnContext(ctx, "test -d /init-apps/appSrc1", &spltest.MockPodExecReturnContext{StdOut: "1"})
+	err = streamAppPkgToPod(ctx, worker, appPkgPathOnPod, podExecClient)
+	if err == nil || podExecClient.streamed.Len() != 0 {
+		t.Errorf("app package should not be streamed, when the directory on the pod is missing")
+	}
+}
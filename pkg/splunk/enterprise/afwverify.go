@@ -0,0 +1,401 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// With the install verification, an installed app is not marked as installed right away. The install worker checks
+// the app with btool, the app version reported by splunkd and the splunkd health, till they pass or the verification
+// window elapses. Once an app is installed and verified on all the pods, a copy of its package is kept on the Operator
+// volume, under its own storage reservation. When a later update of the app fails the verification, that package is
+// installed back on the pod. The kept package is removed, when the app or the CR is deleted.
+
+const (
+	// defaultAppInstallVerifyWindow is the default time window(in seconds) for an installed app to pass the verification
+	defaultAppInstallVerifyWindow = 300
+
+	// appRollbackDirName is the directory on the Operator volume keeping the last verified app packages
+	appRollbackDirName = "rollbackApps"
+
+	// splunkdHealthRed is the splunkd health status, when a feature of splunkd is not working
+	splunkdHealthRed = "red"
+)
+
+// errAppInstallVerifyTerminated is returned, when the pipeline is terminated before the installed app is verified
+var errAppInstallVerifyTerminated = errors.New("pipeline terminated while verifying the app install")
+
+// appInstallVerifyPollInterval is the interval between the verification attempts of an installed app
+var appInstallVerifyPollInterval = 15 * time.Second
+
+// splunkRestResponse is the part of a splunkd REST response needed to verify an installed app
+type splunkRestResponse struct {
+	Entry []struct {
+		Content struct {
+			Version string `json:"version"`
+			Health  string `json:"health"`
+		} `json:"content"`
+	} `json:"entry"`
+}
+
+// isAppInstallVerificationEnabled checks if the installed apps are to be verified
+func isAppInstallVerificationEnabled(afwConfig *enterpriseApi.AppFrameworkSpec) bool {
+	return afwConfig != nil && afwConfig.InstallVerification.Enabled
+}
+
+// getAppInstallVerifyWindow returns the time window for an installed app to pass the verification
+func getAppInstallVerifyWindow(afwConfig *enterpriseApi.AppFrameworkSpec) time.Duration {
+	window := afwConfig.InstallVerification.WindowSeconds
+	if window <= 0 {
+		window = defaultAppInstallVerifyWindow
+	}
+	return time.Duration(window) * time.Second
+}
+
+// getAppRollbackDir returns the directory on the Operator volume keeping the last verified app packages of the CR
+func getAppRollbackDir(cr splcommon.MetaObject) string {
+	return filepath.Join(splcommon.AppDownloadVolume, appRollbackDirName, cr.GetNamespace(), cr.GroupVersionKind().Kind, cr.GetName()) + "/"
+}
+
+// getAppRollbackPkgDir returns the directory on the Operator volume keeping the last verified package of the app
+func getAppRollbackPkgDir(cr splcommon.MetaObject, appSrcName, appName string) string {
+	return filepath.Join(getAppRollbackDir(cr), appSrcName, appName) + "/"
+}
+
+// getAppRollbackPkg returns the last verified package of the app, empty if there is none
+func getAppRollbackPkg(cr splcommon.MetaObject, appSrcName, appName string) string {
+	rollbackPkgDir := getAppRollbackPkgDir(cr, appSrcName, appName)
+	dirEntries, err := os.ReadDir(rollbackPkgDir)
+	if err != nil {
+		return ""
+	}
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() && !strings.HasSuffix(dirEntry.Name(), appCachePartialFileSuffix) {
+			return filepath.Join(rollbackPkgDir, dirEntry.Name())
+		}
+	}
+	return ""
+}
+
+// copyAppPkg copies the app package, removing any partial copy on a failure
+func copyAppPkg(srcFile, dstFile string) error {
+	src, err := os.Open(srcFile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dstFile)
+	}
+	return err
+}
+
+// removeAppRollbackPkgs removes the packages in the directory, and releases their storage
+func removeAppRollbackPkgs(ctx context.Context, rollbackDir string) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("removeAppRollbackPkgs").WithValues("rollbackDir", rollbackDir)
+
+	filepath.WalkDir(rollbackDir, func(path string, dirEntry os.DirEntry, err error) error {
+		if err == nil && !dirEntry.IsDir() {
+			releaseAppPkgStorage(path)
+		}
+		return nil
+	})
+
+	err := os.RemoveAll(rollbackDir)
+	if err != nil {
+		scopedLog.Error(err, "unable to remove the app packages kept for the rollback")
+	}
+}
+
+// keepAppPkgForRollback keeps a copy of the app package of the worker as the last verified package of the app. The copy
+// holds its own storage reservation, so that it doesn't depend on the app package or the app cache
+func keepAppPkgForRollback(ctx context.Context, worker *PipelineWorker) error {
+	cr := worker.cr
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("keepAppPkgForRollback").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "app name", worker.appDeployInfo.AppName)
+
+	appPkgLocalPath := getAppPackageLocalPath(ctx, worker)
+	fileInfo, err := os.Stat(appPkgLocalPath)
+	if err != nil {
+		// streamed app packages are not available on the Operator
+		scopedLog.Info("App package is not available on the Operator, it cannot be kept for the rollback")
+		return nil
+	}
+
+	rollbackPkgDir := getAppRollbackPkgDir(cr, worker.appSrcName, worker.appDeployInfo.AppName)
+	rollbackPkg := filepath.Join(rollbackPkgDir, getAppPackageName(worker))
+	oldRollbackPkg := getAppRollbackPkg(cr, worker.appSrcName, worker.appDeployInfo.AppName)
+	if oldRollbackPkg == rollbackPkg {
+		return nil
+	}
+
+	err = os.MkdirAll(rollbackPkgDir, 0700)
+	if err != nil {
+		return err
+	}
+
+	size := uint64(fileInfo.Size())
+	err = reserveStorage(size)
+	if err != nil && evictAppCache(ctx, size) > 0 {
+		err = reserveStorage(size)
+	}
+	if err != nil {
+		return fmt.Errorf("insufficient storage to keep the app package for the rollback. error: %v", err)
+	}
+
+	// copy next to the kept package, so that the kept package is replaced only by a complete copy
+	partialPkg := rollbackPkg + appCachePartialFileSuffix
+	err = copyAppPkg(appPkgLocalPath, partialPkg)
+	if err == nil {
+		err = os.Rename(partialPkg, rollbackPkg)
+	}
+	if err != nil {
+		os.Remove(partialPkg)
+		releaseStorage(size)
+		scopedLog.Error(err, "unable to keep the app package for the rollback")
+		return err
+	}
+	holdAppPkgStorage(rollbackPkg, size)
+
+	if oldRollbackPkg != "" {
+		err = os.Remove(oldRollbackPkg)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			scopedLog.Error(err, "unable to remove the previously kept app package", "path", oldRollbackPkg)
+		} else {
+			releaseAppPkgStorage(oldRollbackPkg)
+		}
+	}
+
+	scopedLog.Info("Kept the app package for the rollback", "path", rollbackPkg)
+	return nil
+}
+
+// removeAppRollbackPkg removes the package kept for the rollback of the app
+func removeAppRollbackPkg(ctx context.Context, cr splcommon.MetaObject, appSrcName, appName string) {
+	removeAppRollbackPkgs(ctx, getAppRollbackPkgDir(cr, appSrcName, appName))
+}
+
+// runSplunkRestGet runs a GET on the splunkd REST endpoint on the pod
+func runSplunkRestGet(ctx context.Context, podExecClient splutil.PodExecClientImpl, endpoint string) (*splunkRestResponse, error) {
+	command := fmt.Sprintf("curl -s -k -u admin:`cat /mnt/splunk-secrets/password` https://localhost:8089%s?output_mode=json", endpoint)
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return nil, fmt.Errorf("splunkd REST call to %s failed. stdOut: %s, stdErr: %s, error: %v", endpoint, stdOut, stdErr, err)
+	}
+
+	response := &splunkRestResponse{}
+	err = json.Unmarshal([]byte(stdOut), response)
+	if err != nil || len(response.Entry) == 0 {
+		return nil, fmt.Errorf("invalid splunkd REST response from %s. stdOut: %s", endpoint, stdOut)
+	}
+	return response, nil
+}
+
+// getAppPkgVersion returns the version in the default/app.conf of the app package on the pod, empty if not set
+func getAppPkgVersion(ctx context.Context, podExecClient splutil.PodExecClientImpl, appPkgPathOnPod, appTopFolder string) string {
+	command := fmt.Sprintf("tar -xzf %s -O %s/default/app.conf 2>/dev/null | sed -n 's/^[[:space:]]*version[[:space:]]*=[[:space:]]*//p' | head -1", appPkgPathOnPod, appTopFolder)
+	stdOut, _, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(stdOut)
+}
+
+// verifyAppInstall verifies the app installed by the worker: btool check, the version reported by splunkd and the splunkd health
+func verifyAppInstall(ctx context.Context, localCtx *localScopePlaybookContext, expectedVersion string) error {
+	worker := localCtx.worker
+	podExecClient := localCtx.podExecClient
+	appTopFolder := worker.appDeployInfo.AppPackageTopFolder
+
+	// btool check logs the invalid configuration, but it doesn't always fail. Only the invalid key and stanza
+	// lines are looked for, as the rest of the output(ex. missing spec files, deprecation warnings) is harmless
+	command := fmt.Sprintf("/opt/splunk/bin/splunk btool check --app=%s 2>&1 | grep -E '^[[:space:]]*(Invalid key in stanza|Invalid stanza)'", appTopFolder)
+	stdOut, _, _ := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if strings.TrimSpace(stdOut) != "" {
+		return fmt.Errorf("btool check failed for the app %s. stdOut: %s", appTopFolder, stdOut)
+	}
+
+	response, err := runSplunkRestGet(ctx, podExecClient, "/services/apps/local/"+appTopFolder)
+	if err != nil {
+		return err
+	}
+	installedVersion := response.Entry[0].Content.Version
+	if expectedVersion != "" && installedVersion != expectedVersion {
+		return fmt.Errorf("app %s reports the version %s, expected %s", appTopFolder, installedVersion, expectedVersion)
+	}
+
+	response, err = runSplunkRestGet(ctx, podExecClient, "/services/server/health/splunkd")
+	if err != nil {
+		return err
+	}
+	if response.Entry[0].Content.Health == splunkdHealthRed {
+		return fmt.Errorf("splunkd health is %s after installing the app %s", splunkdHealthRed, appTopFolder)
+	}
+
+	return nil
+}
+
+// waitForAppInstallVerification verifies the installed app till the verification passes, or the verification window elapses
+func waitForAppInstallVerification(ctx context.Context, localCtx *localScopePlaybookContext, sigTerm chan struct{}) error {
+	worker := localCtx.worker
+	cr := worker.cr
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("waitForAppInstallVerification").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
+
+	// version of the app package is recorded by the install, unless the package doesn't have one
+	expectedVersion := worker.appDeployInfo.Version
+	if expectedVersion == "" {
+		appPkgPathOnPod := filepath.Join(appBktMnt, worker.appSrcName, getAppPackageName(worker))
+		expectedVersion = getAppPkgVersion(ctx, localCtx.podExecClient, appPkgPathOnPod, worker.appDeployInfo.AppPackageTopFolder)
+	}
+
+	deadline := time.Now().Add(getAppInstallVerifyWindow(worker.afwConfig))
+	for {
+		err := verifyAppInstall(ctx, localCtx, expectedVersion)
+		if err == nil {
+			scopedLog.Info("App install verified", "version", expectedVersion)
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return err
+		}
+		scopedLog.Info("App install is not verified yet", "reason", err.Error())
+
+		select {
+		case <-time.After(appInstallVerifyPollInterval):
+		case <-sigTerm:
+			return errAppInstallVerifyTerminated
+		}
+	}
+}
+
+// rollbackAppInstall installs the last verified package of the app back on the pod
+func rollbackAppInstall(ctx context.Context, localCtx *localScopePlaybookContext) error {
+	worker := localCtx.worker
+	cr := worker.cr
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("rollbackAppInstall").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
+
+	rollbackPkg := getAppRollbackPkg(cr, worker.appSrcName, worker.appDeployInfo.AppName)
+	if rollbackPkg == "" {
+		return fmt.Errorf("no previously installed package of the app %s to roll back to", worker.appDeployInfo.AppName)
+	}
+
+	rollbackPkgPathOnPod := filepath.Join(appBktMnt, worker.appSrcName, filepath.Base(rollbackPkg))
+	stdOut, stdErr, err := CopyFileToPod(ctx, worker.client, cr.GetNamespace(), rollbackPkg, rollbackPkgPathOnPod, localCtx.podExecClient)
+	if err != nil {
+		return fmt.Errorf("unable to copy the previous app package to the pod. stdOut: %s, stdErr: %s, error: %v", stdOut, stdErr, err)
+	}
+
+	command := fmt.Sprintf("/opt/splunk/bin/splunk install app %s -update 1 -auth admin:`cat /mnt/splunk-secrets/password`; rc=$?; rm -f %s; exit $rc", rollbackPkgPathOnPod, rollbackPkgPathOnPod)
+	stdOut, stdErr, err = localCtx.podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if stdErr != "" || err != nil {
+		return fmt.Errorf("unable to install the previous app package. stdOut: %s, stdErr: %s, error: %v", stdOut, stdErr, err)
+	}
+
+	scopedLog.Info("Rolled back the app to the previous package", "package", filepath.Base(rollbackPkg))
+	return nil
+}
+
+// verifyAppInstallOrRollback verifies the app installed by the worker, and rolls it back to the previously installed
+// package, if the verification fails. Returns an error if the verification fails. Once rolled back, the package is
+// marked failed, so that it is not installed again till a new package is uploaded
+func verifyAppInstallOrRollback(ctx context.Context, localCtx *localScopePlaybookContext, phaseInfo *enterpriseApi.PhaseInfo, sigTerm chan struct{}) error {
+	worker := localCtx.worker
+	if !isAppInstallVerificationEnabled(worker.afwConfig) {
+		return nil
+	}
+
+	cr := worker.cr
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("verifyAppInstallOrRollback").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "pod", worker.targetPodName, "app name", worker.appDeployInfo.AppName)
+	eventPublisher, _ := newK8EventPublisher(worker.client, cr)
+
+	verifyErr := waitForAppInstallVerification(ctx, localCtx, sigTerm)
+	if verifyErr == nil {
+		return nil
+	}
+
+	// the app is verified again in the next attempt
+	if verifyErr == errAppInstallVerifyTerminated {
+		return verifyErr
+	}
+
+	phaseInfo.FailCount++
+	scopedLog.Error(verifyErr, "app install verification failed", "failCount", phaseInfo.FailCount)
+
+	if !worker.appDeployInfo.IsUpdate {
+		eventPublisher.Warning(ctx, "AppInstallVerification", fmt.Sprintf("app %s failed the verification on pod %s. %v", worker.appDeployInfo.AppName, worker.targetPodName, verifyErr))
+		return fmt.Errorf("app install verification failed. error: %v, failCount: %d", verifyErr, phaseInfo.FailCount)
+	}
+
+	err := rollbackAppInstall(ctx, localCtx)
+	if err != nil {
+		scopedLog.Error(err, "app rollback failed")
+		eventPublisher.Warning(ctx, "AppInstallVerification", fmt.Sprintf("app %s failed the verification on pod %s, and could not be rolled back. %v", worker.appDeployInfo.AppName, worker.targetPodName, err))
+	} else {
+		eventPublisher.Warning(ctx, "AppInstallVerification", fmt.Sprintf("app %s failed the verification on pod %s, and was rolled back to the previous package. %v", worker.appDeployInfo.AppName, worker.targetPodName, verifyErr))
+
+		// retrying would install the same bad package again, and restart splunk again
+		phaseInfo.Status = enterpriseApi.AppPkgInstallError
+		phaseInfo.FailCount = worker.afwConfig.PhaseMaxRetries + 1
+	}
+
+	return fmt.Errorf("app install verification failed. error: %v, failCount: %d", verifyErr, phaseInfo.FailCount)
+}
+
+// keepVerifiedAppPkg keeps the app package for the rollback, once the app is installed on all the pods
+func keepVerifiedAppPkg(ctx context.Context, worker *PipelineWorker) {
+	if !isAppInstallVerificationEnabled(worker.afwConfig) {
+		return
+	}
+
+	if isFanOutApplicableToCR(worker.cr) && !isAppInstallationCompleteOnAllReplicas(worker.appDeployInfo.AuxPhaseInfo) {
+		return
+	}
+
+	err := keepAppPkgForRollback(ctx, worker)
+	if err != nil {
+		reqLogger := log.FromContext(ctx)
+		reqLogger.Error(err, "unable to keep the verified app package", "app name", worker.appDeployInfo.AppName)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getVerifyTestWorker(cr *enterpriseApi.Standalone) *PipelineWorker {
+	cr.Spec.AppFrameworkConfig = enterpriseApi.AppFrameworkSpec{
+		InstallVerification: enterpriseApi.AppInstallVerificationSpec{Enabled: true, WindowSeconds: 1},
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{Name: "appSrc1", Location: "adminAppsRepo", AppSourceDefaultSpec: enterpriseApi.AppSourceDefaultSpec{Scope: enterpriseApi.ScopeLocal}},
+		},
+	}
+	return &PipelineWorker{
+		cr:            cr,
+		client:        spltest.NewMockClient(),
+		appSrcName:    "appSrc1",
+		afwConfig:     &cr.Spec.AppFrameworkConfig,
+		targetPodName: "splunk-stack1-standalone-0",
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz", ObjectHash: "abcd1234", AppPackageTopFolder: "app1"},
+	}
+}
+
+func addAppVerifyMockContexts(ctx context.Context, podExecClient *spltest.MockPodExecClient, btoolOut, version, health string) {
+	podExecClient.MockReturnContexts = nil
+	// only the invalid keys and stanzas are looked for in the btool output
+	podExecClient.AddMockPodExecReturnContext(ctx, "btool check --app=app1 2>&1 | grep -E '^[[:space:]]*(Invalid key in stanza|Invalid stanza)'", &spltest.MockPodExecReturnContext{StdOut: btoolOut})
+	podExecClient.AddMockPodExecReturnContext(ctx, "/services/apps/local/app1", &spltest.MockPodExecReturnContext{StdOut: `{"entry":[{"name":"app1","content":{"version":"` + version + `"}}]}`})
+	podExecClient.AddMockPodExecReturnContext(ctx, "/services/server/health/splunkd", &spltest.MockPodExecReturnContext{StdOut: `{"entry":[{"name":"splunkd","content":{"health":"` + health + `"}}]}`})
+}
+
+func TestVerifyAppInstall(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	podExecClient := &spltest.MockPodExecClient{}
+	localCtx := &localScopePlaybookContext{worker: getVerifyTestWorker(&cr), podExecClient: podExecClient}
+
+	addAppVerifyMockContexts(ctx, podExecClient, "", "1.0.0", "green")
+	if err := verifyAppInstall(ctx, localCtx, "1.0.0"); err != nil {
+		t.Errorf("app install should be verified. error: %v", err)
+	}
+
+	if err := verifyAppInstall(ctx, localCtx, ""); err != nil {
+		t.Errorf("version should not be checked, when the app package doesn't have one. error: %v", err)
+	}
+
+	if err := verifyAppInstall(ctx, localCtx, "2.0.0"); err == nil || !strings.Contains(err.Error(), "expected 2.0.0") {
+		t.Errorf("version mismatch should fail the verification. error: %v", err)
+	}
+
+	addAppVerifyMockContexts(ctx, podExecClient, "Invalid key in stanza [foo] in app1/local/props.conf", "1.0.0", "green")
+	if err := verifyAppInstall(ctx, localCtx, "1.0.0"); err == nil || !strings.Contains(err.Error(), "btool check failed") {
+		t.Errorf("btool errors should fail the verification. error: %v", err)
+	}
+
+	addAppVerifyMockContexts(ctx, podExecClient, "", "1.0.0", "red")
+	if err := verifyAppInstall(ctx, localCtx, "1.0.0"); err == nil || !strings.Contains(err.Error(), "splunkd health") {
+		t.Errorf("red splunkd health should fail the verification. error: %v", err)
+	}
+
+	addAppVerifyMockContexts(ctx, podExecClient, "", "1.0.0", "green")
+	podExecClient.MockReturnContexts["/services/apps/local/app1"].StdOut = "Could not connect"
+	if err := verifyAppInstall(ctx, localCtx, "1.0.0"); err == nil || !strings.Contains(err.Error(), "invalid splunkd REST response") {
+		t.Errorf("invalid REST response should fail the verification. error: %v", err)
+	}
+}
+
+func TestKeepAppPkgForRollback(t *testing.T) {
+	ctx := context.TODO()
+	defer func(appDownloadVolume string) { splcommon.AppDownloadVolume = appDownloadVolume }(splcommon.AppDownloadVolume)
+	splcommon.AppDownloadVolume = t.TempDir()
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	worker := getVerifyTestWorker(&cr)
+
+	operatorResourceTracker = &globalResourceTracker{
+		storage: &storageTracker{
+			availableDiskSpace: 1024,
+		},
+	}
+
+	// nothing to keep, when the app package is not on the Operator
+	if err := keepAppPkgForRollback(ctx, worker); err != nil || getAppRollbackPkg(&cr, "appSrc1", "app1.tgz") != "" {
+		t.Errorf("missing app package should not be kept. error: %v", err)
+	}
+
+	createLocalAppPkg := func() {
+		localPath := getAppPackageLocalPath(ctx, worker)
+		os.MkdirAll(filepath.Dir(localPath), 0700)
+		if err := os.WriteFile(localPath, []byte(worker.appDeployInfo.ObjectHash), 0600); err != nil {
+			t.Fatalf("unable to create the app package. error: %v", err)
+		}
+	}
+
+	createLocalAppPkg()
+	if err := keepAppPkgForRollback(ctx, worker); err != nil {
+		t.Errorf("app package should be kept for the rollback. error: %v", err)
+	}
+	if filepath.Base(getAppRollbackPkg(&cr, "appSrc1", "app1.tgz")) != "app1.tgz_abcd1234" {
+		t.Errorf("app package should be kept for the rollback. got: %s", getAppRollbackPkg(&cr, "appSrc1", "app1.tgz"))
+	}
+
+	// only the latest verified package is kept
+	worker.appDeployInfo.ObjectHash = "efgh5678"
+	createLocalAppPkg()
+	if err := keepAppPkgForRollback(ctx, worker); err != nil {
+		t.Errorf("app package should be kept for the rollback. error: %v", err)
+	}
+	dirEntries, _ := os.ReadDir(getAppRollbackPkgDir(&cr, "appSrc1", "app1.tgz"))
+	if len(dirEntries) != 1 || dirEntries[0].Name() != "app1.tgz_efgh5678" {
+		t.Errorf("only the latest verified app package should be kept. got: %v", dirEntries)
+	}
+	if operatorResourceTracker.storage.availableDiskSpace != 1024-uint64(len("efgh5678")) {
+		t.Errorf("kept app package should hold its own storage. available: %d", operatorResourceTracker.storage.availableDiskSpace)
+	}
+
+	// the kept package is a copy, so removing the app package doesn't release its storage
+	os.Remove(getAppPackageLocalPath(ctx, worker))
+	releaseAppPkgStorage(getAppPackageLocalPath(ctx, worker))
+	if getAppRollbackPkg(&cr, "appSrc1", "app1.tgz") == "" || operatorResourceTracker.storage.availableDiskSpace != 1024-uint64(len("efgh5678")) {
+		t.Errorf("kept app package should not depend on the app package")
+	}
+
+	// the package of an app with a similar name is not mistaken for the app package
+	os.MkdirAll(getAppRollbackPkgDir(&cr, "appSrc1", "app1.tgz_x"), 0700)
+	os.WriteFile(filepath.Join(getAppRollbackPkgDir(&cr, "appSrc1", "app1.tgz_x"), "app1.tgz_x_0000"), []byte("other"), 0600)
+	if filepath.Base(getAppRollbackPkg(&cr, "appSrc1", "app1.tgz")) != "app1.tgz_efgh5678" {
+		t.Errorf("only the package of the app should be returned. got: %s", getAppRollbackPkg(&cr, "appSrc1", "app1.tgz"))
+	}
+
+	// the kept package is removed with the app, and its storage is released
+	removeAppRollbackPkg(ctx, &cr, "appSrc1", "app1.tgz")
+	if getAppRollbackPkg(&cr, "appSrc1", "app1.tgz") != "" || operatorResourceTracker.storage.availableDiskSpace != 1024 {
+		t.Errorf("kept app package should be removed with the app. available: %d", operatorResourceTracker.storage.availableDiskSpace)
+	}
+
+	// the kept packages are removed with the CR
+	removeAppRollbackPkgs(ctx, getAppRollbackDir(&cr))
+	if _, err := os.Stat(getAppRollbackDir(&cr)); err == nil {
+		t.Errorf("kept app packages should be removed with the CR")
+	}
+
+	// nothing is kept, when there is not enough storage for the copy
+	operatorResourceTracker.storage.availableDiskSpace = 0
+	createLocalAppPkg()
+	if err := keepAppPkgForRollback(ctx, worker); err == nil || getAppRollbackPkg(&cr, "appSrc1", "app1.tgz") != "" {
+		t.Errorf("app package should not be kept without the storage. error: %v", err)
+	}
+}
+
+func TestVerifyAppInstallOrRollback(t *testing.T) {
+	ctx := context.TODO()
+	defer func(appDownloadVolume string) { splcommon.AppDownloadVolume = appDownloadVolume }(splcommon.AppDownloadVolume)
+	splcommon.AppDownloadVolume = t.TempDir()
+	savedPollInterval := appInstallVerifyPollInterval
+	defer func() { appInstallVerifyPollInterval = savedPollInterval }()
+	appInstallVerifyPollInterval = 10 * time.Millisecond
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	worker := getVerifyTestWorker(&cr)
+	podExecClient := &spltest.MockPodExecClient{}
+	localCtx := &localScopePlaybookContext{worker: worker, podExecClient: podExecClient}
+	phaseInfo := &enterpriseApi.PhaseInfo{}
+
+	// verification is skipped, unless it is enabled
+	worker.afwConfig.InstallVerification.Enabled = false
+	if err := verifyAppInstallOrRollback(ctx, localCtx, phaseInfo, nil); err != nil || len(podExecClient.GotCmdList) != 0 {
+		t.Errorf("app install should not be verified, when the verification is disabled")
+	}
+	worker.afwConfig.InstallVerification.Enabled = true
+
+	addAppVerifyMockContexts(ctx, podExecClient, "", "1.0.0", "green")
+	podExecClient.AddMockPodExecReturnContext(ctx, "tar -xzf /operator-staging/appframework/appSrc1/app1.tgz_abcd1234 -O app1/default/app.conf", &spltest.MockPodExecReturnContext{StdOut: "1.0.0\n"})
+	if err := verifyAppInstallOrRollback(ctx, localCtx, phaseInfo, nil); err != nil || phaseInfo.FailCount != 0 {
+		t.Errorf("app install should be verified. error: %v", err)
+	}
+
+	// keep the previous package of the app
+	rollbackDir := getAppRollbackPkgDir(&cr, "appSrc1", "app1.tgz")
+	os.MkdirAll(rollbackDir, 0700)
+	os.WriteFile(filepath.Join(rollbackDir, "app1.tgz_0000"), []byte("previous"), 0600)
+
+	// failed update is rolled back to the previous package
+	worker.appDeployInfo.IsUpdate = true
+	podExecClient.MockReturnContexts["/services/server/health/splunkd"].StdOut = `{"entry":[{"content":{"health":"red"}}]}`
+	podExecClient.AddMockPodExecReturnContext(ctx, "test -d /operator-staging/appframework/appSrc1", &spltest.MockPodExecReturnContext{StdOut: "0"})
+	podExecClient.AddMockPodExecReturnContext(ctx, "install app /operator-staging/appframework/appSrc1/app1.tgz_0000 -update 1", &spltest.MockPodExecReturnContext{})
+	podExecClient.GotCmdList = nil
+	err := verifyAppInstallOrRollback(ctx, localCtx, phaseInfo, nil)
+	if err == nil || phaseInfo.Status != enterpriseApi.AppPkgInstallError || !isPhaseMaxRetriesReached(ctx, phaseInfo, worker.afwConfig) {
+		t.Errorf("rolled back package should be marked failed, without retries. error: %v, status: %d, failCount: %d", err, phaseInfo.Status, phaseInfo.FailCount)
+	}
+	var rolledBack bool
+	for _, cmd := range podExecClient.GotCmdList {
+		if strings.Contains(cmd, "app1.tgz_0000 -update 1") {
+			rolledBack = true
+		}
+	}
+	if !rolledBack {
+		t.Errorf("app should be rolled back to the previous package. got: %v", podExecClient.GotCmdList)
+	}
+
+	// failed update is retried, when it can't be rolled back
+	os.RemoveAll(rollbackDir)
+	phaseInfo = &enterpriseApi.PhaseInfo{Status: enterpriseApi.AppPkgInstallInProgress}
+	err = verifyAppInstallOrRollback(ctx, localCtx, phaseInfo, nil)
+	if err == nil || phaseInfo.FailCount != 1 || phaseInfo.Status != enterpriseApi.AppPkgInstallInProgress {
+		t.Errorf("failed verification should be returned and counted. error: %v, status: %d, failCount: %d", err, phaseInfo.Status, phaseInfo.FailCount)
+	}
+
+	// pipeline termination stops the verification
+	sigTerm := make(chan struct{})
+	close(sigTerm)
+	worker.afwConfig.InstallVerification.WindowSeconds = 60
+	err = verifyAppInstallOrRollback(ctx, localCtx, phaseInfo, sigTerm)
+	if err != errAppInstallVerifyTerminated || phaseInfo.FailCount != 1 {
+		t.Errorf("verification should stop without a failure, when the pipeline is terminated. error: %v, failCount: %d", err, phaseInfo.FailCount)
+	}
+}
@@ -56,7 +56,7 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 	}
 
 	// validate and updates defaults for CR
-	err := validateClusterManagerSpec(ctx, client, cr)
+	appFrameworkConf, err := validateClusterManagerSpec(ctx, client, cr)
 	if err != nil {
 		return result, err
 	}
@@ -96,7 +96,7 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 	defer updateCRStatus(ctx, client, cr)
 
 	// If needed, Migrate the app framework status
-	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig, false)
+	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf, false)
 	if err != nil {
 		return result, err
 	}
@@ -104,8 +104,8 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 	// If the app framework is configured then do following things -
 	// 1. Initialize the S3Clients based on providers
 	// 2. Check the status of apps on remote storage.
-	if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
-		err := initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext)
+	if len(appFrameworkConf.AppSources) != 0 {
+		err := initAndCheckAppInfoStatus(ctx, client, cr, appFrameworkConf, &cr.Status.AppContext)
 		if err != nil {
 			eventPublisher.Warning(ctx, "initAndCheckAppInfoStatus", fmt.Sprintf("init and check app info status failed %s", err.Error()))
 			cr.Status.AppContext.IsDeploymentInProgress = false
@@ -134,13 +134,16 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 		// If this is the last of its kind getting deleted,
 		// remove the entry for this CR type from configMap or else
 		// just decrement the refCount for this CR type.
-		if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
+		if len(appFrameworkConf.AppSources) != 0 {
 			err = UpdateOrRemoveEntryFromConfigMapLocked(ctx, client, cr, SplunkClusterManager)
 			if err != nil {
 				return result, err
 			}
 		}
 
+		// remove the app packages kept for the rollback
+		removeAppRollbackPkgs(ctx, getAppRollbackDir(cr))
+
 		// Check if ClusterManager has any remaining references to other CRs, if so don't delete
 		err = checkCmRemainingReferences(ctx, client, cr)
 		if err != nil {
@@ -161,12 +164,29 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 		return result, err
 	}
 
-	// create or update a regular service for the cluster manager
-	err = splctrl.ApplyService(ctx, client, getSplunkService(ctx, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, false))
+	// create or update a regular service for the cluster manager, targeting the active one when redundant
+	service := getSplunkService(ctx, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, false)
+	if isClusterManagerRedundant(cr) {
+		service.Spec.Selector[enterpriseApi.ClusterManagerRoleLabel] = clusterManagerRoleActive
+	}
+	err = splctrl.ApplyService(ctx, client, service)
 	if err != nil {
 		return result, err
 	}
 
+	// create or update a headless service and the redundancy defaults for redundant cluster managers
+	if isClusterManagerRedundant(cr) {
+		err = splctrl.ApplyService(ctx, client, getSplunkService(ctx, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, true))
+		if err != nil {
+			return result, err
+		}
+		err = applyClusterManagerRedundancyConfigMap(ctx, client, cr)
+		if err != nil {
+			eventPublisher.Warning(ctx, "applyClusterManagerRedundancyConfigMap", fmt.Sprintf("create/update cluster manager redundancy config failed %s", err.Error()))
+			return result, err
+		}
+	}
+
 	// create or update statefulset for the cluster manager
 	statefulSet, err := getClusterManagerStatefulSet(ctx, client, cr)
 	if err != nil {
@@ -187,12 +207,25 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 	}
 
 	clusterManagerManager := splctrl.DefaultStatefulSetPodManager{}
-	phase, err := clusterManagerManager.Update(ctx, client, statefulSet, 1)
+	phase, err := clusterManagerManager.Update(ctx, client, statefulSet, cr.Spec.Replicas)
 	if err != nil {
 		return result, err
 	}
 	cr.Status.Phase = phase
 
+	// track the active cluster manager, when redundant
+	mgr := clusterManagerPodManager{log: scopedLog, cr: cr, secrets: namespaceScopedSecret, newSplunkClient: splclient.NewSplunkClient}
+	if isClusterManagerRedundant(cr) {
+		err = mgr.updateActiveClusterManager(ctx, client)
+		if err != nil {
+			eventPublisher.Warning(ctx, "updateActiveClusterManager", fmt.Sprintf("update active cluster manager failed %s", err.Error()))
+			return result, err
+		}
+	} else {
+		cr.Status.ActiveManager = ""
+		cr.Status.Managers = nil
+	}
+
 	// no need to requeue if everything is ready
 	if cr.Status.Phase == enterpriseApi.PhaseReady {
 		//upgrade fron automated MC to MC CRD
@@ -214,7 +247,7 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 
 		// Add a splunk operator telemetry app
 		if cr.Spec.EtcVolumeStorageConfig.EphemeralStorage || !cr.Status.TelAppInstalled {
-			err := addTelApp(ctx, podExecClient, numberOfClusterMasterReplicas, cr)
+			err := addTelApp(ctx, podExecClient, cr.Spec.Replicas, cr)
 			if err != nil {
 				return result, err
 			}
@@ -223,6 +256,16 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 			cr.Status.TelAppInstalled = true
 		}
 
+		// Apply the replication and search factors of the indexer cluster, which restarts the cluster manager
+		applied, err := mgr.applyClusterFactors(ctx, client)
+		if err != nil {
+			eventPublisher.Warning(ctx, "applyClusterFactors", fmt.Sprintf("apply replication and search factors failed %s", err.Error()))
+			return result, err
+		}
+		if !applied {
+			return result, nil
+		}
+
 		// Manager apps bundle push requires multiple reconcile iterations in order to reflect the configMap on the CM pod.
 		// So keep PerformCmBundlePush() as the last call in this block of code, so that other functionalities are not blocked
 		err = PerformCmBundlePush(ctx, client, cr)
@@ -230,7 +273,7 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 			return result, err
 		}
 
-		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig)
+		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf)
 		result = *finalResult
 
 		// trigger MonitoringConsole reconcile by changing the splunk/image-tag annotation
@@ -239,6 +282,9 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 			return result, err
 		}
 	}
+	// take the snapshot sets of the PVCs on schedule
+	applyVolumeSnapshotPolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, cr.Status.Phase == enterpriseApi.PhaseReady, &result)
+
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
 	if !result.Requeue {
@@ -263,33 +309,68 @@ func (mgr *clusterManagerPodManager) getClusterManagerClient(cr *enterpriseApi.C
 }
 
 // validateClusterManagerSpec checks validity and makes default updates to a ClusterManagerSpec, and returns error if something is wrong.
-func validateClusterManagerSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) error {
+// It returns the App Framework config to deploy the apps with, with the AppSource references resolved.
+func validateClusterManagerSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) (*enterpriseApi.AppFrameworkSpec, error) {
+	// a single cluster manager, unless redundancy is configured
+	if cr.Spec.Replicas == 0 {
+		cr.Spec.Replicas = 1
+	}
+	if cr.Spec.ManagerSwitchoverMode == "" {
+		cr.Spec.ManagerSwitchoverMode = defaultManagerSwitchoverMode
+	}
 
 	if !reflect.DeepEqual(cr.Status.SmartStore, cr.Spec.SmartStore) {
 		err := ValidateSplunkSmartstoreSpec(ctx, &cr.Spec.SmartStore)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	appFrameworkConf, err := resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasClusterFactors(cr) {
+		err = validateClusterFactors(cr)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, cr.Spec.AppFrameworkConfig) {
-		err := ValidateAppFrameworkSpec(ctx, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext, false, cr.GetObjectKind().GroupVersionKind().Kind)
+	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, *appFrameworkConf) {
+		err = ValidateAppFrameworkSpec(ctx, appFrameworkConf, &cr.Status.AppContext, false, cr.GetObjectKind().GroupVersionKind().Kind)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	err = validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return appFrameworkConf, nil
 }
 
 // getClusterManagerStatefulSet returns a Kubernetes StatefulSet object for a Splunk Enterprise license manager.
 func getClusterManagerStatefulSet(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) (*appsv1.StatefulSet, error) {
 	var extraEnvVar []corev1.EnvVar
 
-	ss, err := getSplunkStatefulSet(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, 1, extraEnvVar)
+	replicas := cr.Spec.Replicas
+	if replicas == 0 {
+		replicas = 1
+	}
+	ss, err := getSplunkStatefulSet(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, replicas, extraEnvVar)
 	if err != nil {
 		return ss, err
 	}
+	if isClusterManagerRedundant(cr) {
+		err = addClusterManagerRedundancyDefaults(ctx, client, cr, ss)
+		if err != nil {
+			return ss, err
+		}
+	}
 	smartStoreConfigMap := getSmartstoreConfigMap(ctx, client, cr, SplunkClusterManager)
 
 	if smartStoreConfigMap != nil {
@@ -350,6 +431,12 @@ func PerformCmBundlePush(ctx context.Context, c splcommon.ControllerClient, cr *
 		return fmt.Errorf("will re-attempt to push the bundle after the 5 seconds period passed from last check. LastCheckInterval=%d, current epoch=%d", cr.Status.BundlePushTracker.LastCheckInterval, currentEpoch)
 	}
 
+	// the bundle push can trigger a rolling restart of the peers, so it is held till the next maintenance window
+	if !isMaintenanceWindowOpen(ctx, &cr.Spec.AppFrameworkConfig) {
+		scopedLog.Info("Waiting for the maintenance window to push the bundle")
+		return nil
+	}
+
 	scopedLog.Info("Attempting to push the bundle")
 	cr.Status.BundlePushTracker.LastCheckInterval = currentEpoch
 
@@ -359,8 +446,7 @@ func PerformCmBundlePush(ctx context.Context, c splcommon.ControllerClient, cr *
 	// for the configMap update to the Pod before proceeding for the manager apps
 	// bundle push.
 
-	cmPodName := fmt.Sprintf("splunk-%s-%s-0", cr.GetName(), "cluster-manager")
-	podExecClient := splutil.GetPodExecClient(c, cr, cmPodName)
+	podExecClient := splutil.GetPodExecClient(c, cr, getActiveClusterManagerPodName(cr))
 	err := CheckIfsmartstoreConfigMapUpdatedToPod(ctx, c, cr, podExecClient)
 	if err != nil {
 		return err
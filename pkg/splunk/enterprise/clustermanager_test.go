@@ -418,7 +418,7 @@ func TestValidateClusterManagerSpec(t *testing.T) {
 		},
 	}
 	c := spltest.NewMockClient()
-	err := validateClusterManagerSpec(ctx, c, &current)
+	_, err := validateClusterManagerSpec(ctx, c, &current)
 	if err == nil {
 		t.Errorf("Didn't detect incorrect appframework config")
 	}
@@ -441,7 +441,7 @@ func TestGetClusterManagerStatefulSet(t *testing.T) {
 
 	test := func(want string) {
 		f := func() (interface{}, error) {
-			if err := validateClusterManagerSpec(ctx, c, &cr); err != nil {
+			if _, err := validateClusterManagerSpec(ctx, c, &cr); err != nil {
 				t.Errorf("validateClusterManagerSpec() returned error: %v", err)
 			}
 			return getClusterManagerStatefulSet(ctx, c, &cr)
@@ -0,0 +1,259 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The replication and search factors of the indexer cluster may be declared on the ClusterManager. They are validated
+// against the replicas of the IndexerClusters referring to the cluster manager, and applied through the REST API of the
+// cluster manager, which is then restarted for them to take effect. A change to factors exceeding the replicas is not
+// applied, and reported in the status, without holding the rest of the reconcile. Once applied, the IndexerClusters
+// refuse to scale below the replication factor.
+
+// clusterFactorsApplyTimeout is how long the cluster manager may take to report the factors last applied. The bundle
+// push and the App Framework are held till then
+var clusterFactorsApplyTimeout = 15 * time.Minute
+
+// GetClusterManagerClusterInfoCall function pointer to mock
+var GetClusterManagerClusterInfoCall = func(ctx context.Context, mgr *clusterManagerPodManager) (*splclient.ClusterInfo, error) {
+	c := mgr.getClusterManagerClient(mgr.cr)
+	return c.GetClusterInfo(false)
+}
+
+// SetClusterFactorsCall function pointer to mock
+var SetClusterFactorsCall = func(ctx context.Context, mgr *clusterManagerPodManager) error {
+	c := mgr.getClusterManagerClient(mgr.cr)
+	err := c.SetClusterFactors(mgr.cr.Spec.ReplicationFactor, mgr.cr.Spec.SearchFactor, mgr.cr.Spec.SiteReplicationFactor, mgr.cr.Spec.SiteSearchFactor)
+	if err != nil {
+		return err
+	}
+	return c.RestartSplunk()
+}
+
+// hasClusterFactors checks if any replication or search factor is declared on the ClusterManager
+func hasClusterFactors(cr *enterpriseApi.ClusterManager) bool {
+	return cr.Spec.ReplicationFactor > 0 || cr.Spec.SearchFactor > 0 || cr.Spec.SiteReplicationFactor != "" || cr.Spec.SiteSearchFactor != ""
+}
+
+// getClusterFactorsConfig returns the replication and search factors declared on the ClusterManager
+func getClusterFactorsConfig(cr *enterpriseApi.ClusterManager) string {
+	return fmt.Sprintf("replication_factor=%d&search_factor=%d&site_replication_factor=%s&site_search_factor=%s",
+		cr.Spec.ReplicationFactor, cr.Spec.SearchFactor, cr.Spec.SiteReplicationFactor, cr.Spec.SiteSearchFactor)
+}
+
+// normalizeSiteFactor strips the braces and spaces of a site factor reported by the cluster manager, i.e. "{ origin:2, total:3 }"
+func normalizeSiteFactor(factor string) string {
+	factor = strings.Trim(strings.TrimSpace(factor), "{}")
+	return strings.ReplaceAll(factor, " ", "")
+}
+
+// isSameSiteFactor checks if two site factors have the same values, regardless of their order
+func isSameSiteFactor(factor string, other string) bool {
+	values, err := parseIndexerClusterSiteFactor(normalizeSiteFactor(factor))
+	if err != nil {
+		return false
+	}
+	otherValues, err := parseIndexerClusterSiteFactor(normalizeSiteFactor(other))
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(values, otherValues)
+}
+
+// getClusterManagerIndexerReplicas returns the total replicas of the IndexerClusters referring to the cluster manager
+func getClusterManagerIndexerReplicas(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) (int32, error) {
+	indexerClusterList := &enterpriseApi.IndexerClusterList{}
+	err := c.List(ctx, indexerClusterList, client.InNamespace(cr.GetNamespace()))
+	if err != nil {
+		return 0, err
+	}
+
+	var replicas int32
+	for _, indexerCluster := range indexerClusterList.Items {
+		// the replicas of a multisite IndexerCluster are those of the IndexerClusters of its sites
+		if indexerCluster.Spec.ClusterManagerRef.Name != cr.GetName() || len(indexerCluster.Spec.Sites) > 0 {
+			continue
+		}
+		replicas += indexerCluster.Spec.Replicas
+	}
+	return replicas, nil
+}
+
+// validateClusterFactors checks the replication and search factors declared on the ClusterManager
+func validateClusterFactors(cr *enterpriseApi.ClusterManager) error {
+	if cr.Spec.ReplicationFactor > 0 && cr.Spec.SearchFactor > cr.Spec.ReplicationFactor {
+		return fmt.Errorf("searchFactor %d should not be greater than replicationFactor %d", cr.Spec.SearchFactor, cr.Spec.ReplicationFactor)
+	}
+
+	var siteReplicationFactor, siteSearchFactor map[string]int32
+	var err error
+	if cr.Spec.SiteReplicationFactor != "" {
+		siteReplicationFactor, err = parseIndexerClusterSiteFactor(cr.Spec.SiteReplicationFactor)
+		if err != nil {
+			return err
+		}
+	}
+	if cr.Spec.SiteSearchFactor != "" {
+		siteSearchFactor, err = parseIndexerClusterSiteFactor(cr.Spec.SiteSearchFactor)
+		if err != nil {
+			return err
+		}
+	}
+	for key, value := range siteSearchFactor {
+		if replicationValue, ok := siteReplicationFactor[key]; ok && value > replicationValue {
+			return fmt.Errorf("siteSearchFactor %s should not be greater than siteReplicationFactor %s", cr.Spec.SiteSearchFactor, cr.Spec.SiteReplicationFactor)
+		}
+	}
+	return nil
+}
+
+// validateClusterFactorsReplicas checks the indexer cluster has enough indexers for the replication factor declared on the ClusterManager
+func validateClusterFactorsReplicas(cr *enterpriseApi.ClusterManager, replicas int32) error {
+	if replicas == 0 {
+		return nil
+	}
+	if cr.Spec.ReplicationFactor > replicas {
+		return fmt.Errorf("replicationFactor %d should not be greater than the %d indexers of the cluster", cr.Spec.ReplicationFactor, replicas)
+	}
+	if cr.Spec.SiteReplicationFactor != "" {
+		// the site factors are already validated
+		siteReplicationFactor, _ := parseIndexerClusterSiteFactor(cr.Spec.SiteReplicationFactor)
+		if siteReplicationFactor["total"] > replicas {
+			return fmt.Errorf("siteReplicationFactor %s should not be greater than the %d indexers of the cluster", cr.Spec.SiteReplicationFactor, replicas)
+		}
+	}
+	return nil
+}
+
+// isClusterFactorsApplied checks if the cluster manager reports the replication and search factors declared on the ClusterManager
+func isClusterFactorsApplied(cr *enterpriseApi.ClusterManager, clusterInfo *splclient.ClusterInfo) bool {
+	if cr.Spec.ReplicationFactor > 0 && clusterInfo.ReplicationFactor != cr.Spec.ReplicationFactor {
+		return false
+	}
+	if cr.Spec.SearchFactor > 0 && clusterInfo.SearchFactor != cr.Spec.SearchFactor {
+		return false
+	}
+	if cr.Spec.SiteReplicationFactor != "" && (clusterInfo.MultiSite != "true" || !isSameSiteFactor(clusterInfo.SiteReplicationFactor, cr.Spec.SiteReplicationFactor)) {
+		return false
+	}
+	if cr.Spec.SiteSearchFactor != "" && (clusterInfo.MultiSite != "true" || !isSameSiteFactor(clusterInfo.SiteSearchFactor, cr.Spec.SiteSearchFactor)) {
+		return false
+	}
+	return true
+}
+
+// applyClusterFactors applies the replication and search factors declared on the ClusterManager, restarting the
+// cluster manager when they change. Returns true once the cluster manager reports them.
+func (mgr *clusterManagerPodManager) applyClusterFactors(ctx context.Context, c splcommon.ControllerClient) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyClusterFactors").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	if !hasClusterFactors(mgr.cr) {
+		mgr.cr.Status.ClusterFactors = enterpriseApi.ClusterFactorsStatus{}
+		return true, nil
+	}
+
+	config := getClusterFactorsConfig(mgr.cr)
+	clusterInfo, err := GetClusterManagerClusterInfoCall(ctx, mgr)
+	if err != nil {
+		if mgr.isClusterFactorsApplyTimedOut(ctx, config) {
+			return true, nil
+		}
+		scopedLog.Info("Waiting for the cluster manager to report its replication and search factors", "error", err.Error())
+		return false, nil
+	}
+	mgr.cr.Status.ClusterFactors.ReplicationFactor = clusterInfo.ReplicationFactor
+	mgr.cr.Status.ClusterFactors.SearchFactor = clusterInfo.SearchFactor
+	mgr.cr.Status.ClusterFactors.SiteReplicationFactor = normalizeSiteFactor(clusterInfo.SiteReplicationFactor)
+	mgr.cr.Status.ClusterFactors.SiteSearchFactor = normalizeSiteFactor(clusterInfo.SiteSearchFactor)
+
+	if isClusterFactorsApplied(mgr.cr, clusterInfo) {
+		mgr.cr.Status.ClusterFactors.Applied = config
+		mgr.cr.Status.ClusterFactors.Message = ""
+		return true, nil
+	}
+
+	// the cluster manager is restarted to apply the factors
+	if mgr.cr.Status.ClusterFactors.Applied == config {
+		if mgr.isClusterFactorsApplyTimedOut(ctx, config) {
+			return true, nil
+		}
+		scopedLog.Info("Waiting for the cluster manager to restart with the new replication and search factors")
+		return false, nil
+	}
+
+	// a change the indexer cluster doesn't have enough indexers for is not applied, without holding the rest of the reconcile
+	replicas, err := getClusterManagerIndexerReplicas(ctx, c, mgr.cr)
+	if err != nil {
+		return false, err
+	}
+	err = validateClusterFactorsReplicas(mgr.cr, replicas)
+	if err != nil {
+		scopedLog.Info("Not changing the replication and search factors of the indexer cluster", "reason", err.Error())
+		mgr.cr.Status.ClusterFactors.Message = err.Error()
+		return true, nil
+	}
+
+	// don't restart the cluster manager in the middle of a bundle push
+	if mgr.cr.Status.BundlePushTracker.NeedToPushManagerApps {
+		scopedLog.Info("Waiting for the bundle push to complete before changing the replication and search factors")
+		return false, nil
+	}
+
+	scopedLog.Info("Changing the replication and search factors of the indexer cluster", "current", mgr.cr.Status.ClusterFactors, "desired", config)
+	err = SetClusterFactorsCall(ctx, mgr)
+	if err != nil {
+		scopedLog.Error(err, "Unable to change the replication and search factors of the indexer cluster")
+		return false, err
+	}
+	mgr.cr.Status.ClusterFactors.Applied = config
+	mgr.cr.Status.ClusterFactors.AppliedTime = time.Now().Unix()
+	mgr.cr.Status.ClusterFactors.Message = ""
+	return false, nil
+}
+
+// isClusterFactorsApplyTimedOut for clusterManagerPodManager checks if the cluster manager didn't report the factors last
+// applied in time. The failure is recorded in the status, so that the rest of the reconcile is no longer held
+func (mgr *clusterManagerPodManager) isClusterFactorsApplyTimedOut(ctx context.Context, config string) bool {
+	status := &mgr.cr.Status.ClusterFactors
+	if status.Applied != config || status.AppliedTime == 0 {
+		return false
+	}
+	if time.Since(time.Unix(status.AppliedTime, 0)) < clusterFactorsApplyTimeout {
+		return false
+	}
+
+	if status.Message == "" {
+		reqLogger := log.FromContext(ctx)
+		scopedLog := reqLogger.WithName("isClusterFactorsApplyTimedOut").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+		status.Message = fmt.Sprintf("cluster manager didn't report the replication and search factors %s within %s", config, clusterFactorsApplyTimeout)
+		scopedLog.Info("Replication and search factors are not applied, no longer holding the bundle push and the apps", "reason", status.Message)
+	}
+	return true
+}
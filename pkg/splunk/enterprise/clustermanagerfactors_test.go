@@ -0,0 +1,267 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNormalizeSiteFactor(t *testing.T) {
+	if got := normalizeSiteFactor("{ origin:2, total:3 }"); got != "origin:2,total:3" {
+		t.Errorf("normalizeSiteFactor() = %s; want origin:2,total:3", got)
+	}
+	if !isSameSiteFactor("{ total:3, origin:2 }", "origin:2,total:3") {
+		t.Errorf("site factors should be the same regardless of their order")
+	}
+	if isSameSiteFactor("{ origin:1, total:3 }", "origin:2,total:3") {
+		t.Errorf("site factors should differ")
+	}
+}
+
+func TestValidateClusterFactors(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	cr := &enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "test"},
+		Spec: enterpriseApi.ClusterManagerSpec{
+			ReplicationFactor: 3,
+			SearchFactor:      2,
+		},
+	}
+
+	err := validateClusterFactors(cr)
+	if err != nil {
+		t.Errorf("cluster factors should be valid. error: %v", err)
+	}
+
+	cr.Spec.SearchFactor = 4
+	err = validateClusterFactors(cr)
+	if err == nil {
+		t.Errorf("searchFactor should not be greater than replicationFactor")
+	}
+	cr.Spec.SearchFactor = 2
+
+	cr.Spec.SiteReplicationFactor = "origin:1,total:2"
+	cr.Spec.SiteSearchFactor = "origin:2,total:2"
+	err = validateClusterFactors(cr)
+	if err == nil {
+		t.Errorf("siteSearchFactor should not be greater than siteReplicationFactor")
+	}
+	cr.Spec.SiteSearchFactor = "origin:1,total:2"
+
+	cr.Spec.SiteReplicationFactor = "origin:2"
+	err = validateClusterFactors(cr)
+	if err == nil {
+		t.Errorf("siteReplicationFactor should set origin and total")
+	}
+	cr.Spec.SiteReplicationFactor = "origin:1,total:2"
+
+	// an IndexerCluster of another cluster manager and a multisite IndexerCluster are ignored
+	indexerClusters := []enterpriseApi.IndexerCluster{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"},
+			Spec: enterpriseApi.IndexerClusterSpec{
+				Replicas:         2,
+				CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{ClusterManagerRef: corev1.ObjectReference{Name: "cm"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "test"},
+			Spec: enterpriseApi.IndexerClusterSpec{
+				Replicas:         5,
+				CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{ClusterManagerRef: corev1.ObjectReference{Name: "othercm"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "multisite", Namespace: "test"},
+			Spec: enterpriseApi.IndexerClusterSpec{
+				Replicas:         5,
+				CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{ClusterManagerRef: corev1.ObjectReference{Name: "cm"}},
+				Sites:            []enterpriseApi.IndexerClusterSiteSpec{{Name: "site1", Zone: "zone-a", Replicas: 5}},
+			},
+		},
+	}
+	for i := range indexerClusters {
+		err = c.Create(ctx, &indexerClusters[i])
+		if err != nil {
+			t.Errorf("IndexerCluster should be created. error: %v", err)
+		}
+	}
+
+	replicas, err := getClusterManagerIndexerReplicas(ctx, c, cr)
+	if err != nil || replicas != 2 {
+		t.Errorf("replicas of the IndexerCluster of the cluster manager should be counted, got: %d, error: %v", replicas, err)
+	}
+	err = validateClusterFactorsReplicas(cr, replicas)
+	if err == nil {
+		t.Errorf("replicationFactor should not be greater than the indexers of the cluster")
+	}
+
+	// no IndexerCluster yet
+	err = validateClusterFactorsReplicas(cr, 0)
+	if err != nil {
+		t.Errorf("cluster factors should be valid without an IndexerCluster. error: %v", err)
+	}
+
+	cr.Spec.ReplicationFactor = 2
+	err = validateClusterFactorsReplicas(cr, replicas)
+	if err != nil {
+		t.Errorf("cluster factors should be valid. error: %v", err)
+	}
+
+	cr.Spec.SiteReplicationFactor = "origin:1,total:3"
+	err = validateClusterFactorsReplicas(cr, replicas)
+	if err == nil {
+		t.Errorf("siteReplicationFactor should not be greater than the indexers of the cluster")
+	}
+}
+
+func TestApplyClusterFactors(t *testing.T) {
+	ctx := context.TODO()
+	cr := &enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "test"},
+	}
+	mgr := &clusterManagerPodManager{cr: cr}
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	savedGetCall := GetClusterManagerClusterInfoCall
+	savedSetCall := SetClusterFactorsCall
+	defer func() {
+		GetClusterManagerClusterInfoCall = savedGetCall
+		SetClusterFactorsCall = savedSetCall
+	}()
+	clusterInfo := &splclient.ClusterInfo{MultiSite: "false", ReplicationFactor: 3, SearchFactor: 2}
+	var getErr error
+	GetClusterManagerClusterInfoCall = func(ctx context.Context, mgr *clusterManagerPodManager) (*splclient.ClusterInfo, error) {
+		return clusterInfo, getErr
+	}
+	setCalls := 0
+	SetClusterFactorsCall = func(ctx context.Context, mgr *clusterManagerPodManager) error {
+		setCalls++
+		return nil
+	}
+
+	// nothing to apply
+	applied, err := mgr.applyClusterFactors(ctx, c)
+	if !applied || err != nil || setCalls != 0 {
+		t.Errorf("no cluster factors should be applied. applied: %t, error: %v", applied, err)
+	}
+
+	// factors already reported by the cluster manager
+	cr.Spec.ReplicationFactor = 3
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if !applied || err != nil || setCalls != 0 || cr.Status.ClusterFactors.SearchFactor != 2 {
+		t.Errorf("cluster factors should already be applied. applied: %t, status: %v, error: %v", applied, cr.Status.ClusterFactors, err)
+	}
+
+	// factors are changed, waiting for the bundle push
+	cr.Spec.SearchFactor = 3
+	cr.Status.BundlePushTracker.NeedToPushManagerApps = true
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if applied || err != nil || setCalls != 0 {
+		t.Errorf("cluster factors should not be changed during a bundle push. applied: %t, error: %v", applied, err)
+	}
+	cr.Status.BundlePushTracker.NeedToPushManagerApps = false
+
+	// factors are changed, then waiting for the cluster manager to restart
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if applied || err != nil || setCalls != 1 || cr.Status.ClusterFactors.Applied != getClusterFactorsConfig(cr) {
+		t.Errorf("cluster factors should be changed. applied: %t, error: %v", applied, err)
+	}
+	getErr = fmt.Errorf("cluster manager is restarting")
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if applied || err != nil || setCalls != 1 {
+		t.Errorf("should wait for the cluster manager to restart. applied: %t, error: %v", applied, err)
+	}
+	getErr = nil
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if applied || err != nil || setCalls != 1 {
+		t.Errorf("should wait for the cluster manager to report the new factors. applied: %t, error: %v", applied, err)
+	}
+
+	// the rest of the reconcile is no longer held, when the factors are not reported in time
+	appliedTime := cr.Status.ClusterFactors.AppliedTime
+	cr.Status.ClusterFactors.AppliedTime = time.Now().Add(-clusterFactorsApplyTimeout).Unix()
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if !applied || err != nil || setCalls != 1 || !strings.Contains(cr.Status.ClusterFactors.Message, "didn't report the replication and search factors") {
+		t.Errorf("timed out cluster factors should be reported in the status. applied: %t, status: %v, error: %v", applied, cr.Status.ClusterFactors, err)
+	}
+	getErr = fmt.Errorf("cluster manager is down")
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if !applied || err != nil || setCalls != 1 {
+		t.Errorf("timed out cluster factors should not hold the reconcile. applied: %t, error: %v", applied, err)
+	}
+	getErr = nil
+	cr.Status.ClusterFactors.AppliedTime = appliedTime
+	clusterInfo.SearchFactor = 3
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if !applied || err != nil || setCalls != 1 || cr.Status.ClusterFactors.Message != "" {
+		t.Errorf("cluster factors should be applied. applied: %t, status: %v, error: %v", applied, cr.Status.ClusterFactors, err)
+	}
+
+	// site factors are only applied on a multisite cluster manager
+	cr.Spec.SiteReplicationFactor = "origin:2,total:3"
+	clusterInfo.MultiSite = "true"
+	clusterInfo.SiteReplicationFactor = "{ origin:2, total:3 }"
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if !applied || err != nil || setCalls != 1 || cr.Status.ClusterFactors.SiteReplicationFactor != "origin:2,total:3" {
+		t.Errorf("site cluster factors should be applied. applied: %t, status: %v, error: %v", applied, cr.Status.ClusterFactors, err)
+	}
+
+	// a change exceeding the indexers of the cluster is reported, without holding the reconcile
+	indexerCluster := &enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"},
+		Spec: enterpriseApi.IndexerClusterSpec{
+			Replicas:         3,
+			CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{ClusterManagerRef: corev1.ObjectReference{Name: "cm"}},
+		},
+	}
+	err = c.Create(ctx, indexerCluster)
+	if err != nil {
+		t.Fatalf("IndexerCluster should be created. error: %v", err)
+	}
+	cr.Spec.ReplicationFactor = 5
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if !applied || err != nil || setCalls != 1 || !strings.Contains(cr.Status.ClusterFactors.Message, "should not be greater than the 3 indexers") {
+		t.Errorf("cluster factors exceeding the indexers should be reported. applied: %t, status: %v, error: %v", applied, cr.Status.ClusterFactors, err)
+	}
+
+	// errors are reported
+	cr.Spec.ReplicationFactor = 3
+	cr.Spec.SearchFactor = 2
+	SetClusterFactorsCall = func(ctx context.Context, mgr *clusterManagerPodManager) error {
+		return fmt.Errorf("invalid replication factor")
+	}
+	applied, err = mgr.applyClusterFactors(ctx, c)
+	if applied || err == nil {
+		t.Errorf("cluster factors should not be applied. applied: %t, error: %v", applied, err)
+	}
+}
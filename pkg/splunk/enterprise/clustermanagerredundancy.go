@@ -0,0 +1,215 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splctrl "github.com/splunk/splunk-operator/pkg/splunk/controller"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// With more than one replica, the cluster managers are configured for cluster manager redundancy through additional
+// ansible defaults, listing every cluster manager in server.conf. The Operator polls the redundancy state of each
+// cluster manager, and labels the pod of the active one, so that the service of the cluster manager, used by the peers
+// and the search heads, only targets the active cluster manager.
+
+const (
+	// clusterManagerRoleActive is the role label of the pod of the active cluster manager
+	clusterManagerRoleActive = "active"
+
+	// clusterManagerRoleStandby is the role label of the pods of the standby cluster managers
+	clusterManagerRoleStandby = "standby"
+
+	// defaultManagerSwitchoverMode is the default manager_switchover_mode of redundant cluster managers
+	defaultManagerSwitchoverMode = "auto"
+
+	// clusterManagerRedundancyDefaultsStr is the ansible defaults of redundant cluster managers
+	clusterManagerRedundancyDefaultsStr = `splunk:
+  conf:
+    - key: server
+      value:
+        directory: /opt/splunk/etc/system/local
+        content:
+          clustering:
+            manager_switchover_mode: %s
+            manager_uri: %s
+%s`
+
+	// clusterManagerRedundancyStanzaStr is the server.conf stanza of each redundant cluster manager
+	clusterManagerRedundancyStanzaStr = `          clustermanager:%s:
+            manager_uri: https://%s:8089
+`
+)
+
+// GetClusterManagerRedundancyCall function pointer to mock
+var GetClusterManagerRedundancyCall = func(ctx context.Context, mgr *clusterManagerPodManager, n int32) (*splclient.ClusterManagerRedundancyInfo, error) {
+	c := mgr.getClusterManagerPodClient(n)
+	return c.GetClusterManagerRedundancy()
+}
+
+// isClusterManagerRedundant checks if cluster manager redundancy is configured
+func isClusterManagerRedundant(cr *enterpriseApi.ClusterManager) bool {
+	return cr.Spec.Replicas > 1
+}
+
+// getActiveClusterManagerPodName returns the name of the pod of the active cluster manager, the pod 0 till it is known
+func getActiveClusterManagerPodName(cr *enterpriseApi.ClusterManager) string {
+	if cr.Status.ActiveManager != "" {
+		return cr.Status.ActiveManager
+	}
+	return GetSplunkStatefulsetPodName(SplunkClusterManager, cr.GetName(), 0)
+}
+
+// getClusterManagerPodClient for clusterManagerPodManager returns a SplunkClient for a specific cluster manager pod
+func (mgr *clusterManagerPodManager) getClusterManagerPodClient(n int32) *splclient.SplunkClient {
+	fqdnName := GetSplunkStatefulsetURL(mgr.cr.GetNamespace(), SplunkClusterManager, mgr.cr.GetName(), n, false)
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(mgr.secrets.Data["password"]))
+}
+
+// getClusterManagerRedundancyDefaults returns the ansible defaults configuring redundancy on each cluster manager
+func getClusterManagerRedundancyDefaults(cr *enterpriseApi.ClusterManager) string {
+	var managerURIs []string
+	var stanzas string
+	for n := int32(0); n < cr.Spec.Replicas; n++ {
+		name := fmt.Sprintf("cm%d", n)
+		managerURIs = append(managerURIs, fmt.Sprintf("clustermanager:%s", name))
+		stanzas += fmt.Sprintf(clusterManagerRedundancyStanzaStr, name, GetSplunkStatefulsetURL(cr.GetNamespace(), SplunkClusterManager, cr.GetName(), n, false))
+	}
+	return fmt.Sprintf(clusterManagerRedundancyDefaultsStr, cr.Spec.ManagerSwitchoverMode, strings.Join(managerURIs, ","), stanzas)
+}
+
+// applyClusterManagerRedundancyConfigMap creates or updates the ConfigMap with the redundancy defaults of the cluster managers
+func applyClusterManagerRedundancyConfigMap(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) error {
+	configMapName := GetSplunkRedundancyConfigMapName(cr.GetName(), SplunkClusterManager)
+	configMap := splctrl.PrepareConfigMap(configMapName, cr.GetNamespace(), map[string]string{"default.yml": getClusterManagerRedundancyDefaults(cr)})
+	configMap.SetOwnerReferences(append(configMap.GetOwnerReferences(), splcommon.AsOwner(cr, true)))
+	_, err := splctrl.ApplyConfigMap(ctx, client, configMap)
+	return err
+}
+
+// addClusterManagerRedundancyDefaults adds the redundancy defaults to the cluster manager pod template
+func addClusterManagerRedundancyDefaults(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.ClusterManager, statefulSet *appsv1.StatefulSet) error {
+	configMapName := GetSplunkRedundancyConfigMapName(cr.GetName(), SplunkClusterManager)
+	configMapVolDefaultMode := int32(corev1.ConfigMapVolumeSourceDefaultMode)
+	addSplunkVolumeToTemplate(&statefulSet.Spec.Template, "mnt-splunk-redundancy", "/mnt/splunk-redundancy", corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: configMapName,
+			},
+			DefaultMode: &configMapVolDefaultMode,
+		},
+	})
+
+	for i := range statefulSet.Spec.Template.Spec.Containers {
+		env := statefulSet.Spec.Template.Spec.Containers[i].Env
+		for j := range env {
+			if env[j].Name == "SPLUNK_DEFAULTS_URL" {
+				env[j].Value = fmt.Sprintf("%s,%s", "/mnt/splunk-redundancy/default.yml", env[j].Value)
+			}
+		}
+	}
+
+	// any change to the redundancy defaults recycles the pods
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: configMapName}
+	configMapResourceVersion, err := splctrl.GetConfigMapResourceVersion(ctx, client, namespacedName)
+	if err != nil {
+		return err
+	}
+	if statefulSet.Spec.Template.ObjectMeta.Annotations == nil {
+		statefulSet.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
+	}
+	statefulSet.Spec.Template.ObjectMeta.Annotations["redundancyConfigRev"] = configMapResourceVersion
+	return nil
+}
+
+// updateActiveClusterManager polls the redundancy state of each cluster manager, and labels the pod of the active one
+func (mgr *clusterManagerPodManager) updateActiveClusterManager(ctx context.Context, client splcommon.ControllerClient) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("updateActiveClusterManager").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	var activeManager string
+	managers := []enterpriseApi.ClusterManagerMemberStatus{}
+	for n := int32(0); n < mgr.cr.Spec.Replicas; n++ {
+		member := enterpriseApi.ClusterManagerMemberStatus{Name: GetSplunkStatefulsetPodName(SplunkClusterManager, mgr.cr.GetName(), n)}
+		info, err := GetClusterManagerRedundancyCall(ctx, mgr, n)
+		if err != nil {
+			scopedLog.Info("Unable to get the redundancy state of cluster manager", "podName", member.Name, "error", err.Error())
+		} else {
+			member.HAMode = info.HAMode
+			if strings.EqualFold(info.HAMode, "Active") && activeManager == "" {
+				activeManager = member.Name
+			}
+		}
+		managers = append(managers, member)
+	}
+	mgr.cr.Status.Managers = managers
+
+	// keep the current labels, till a cluster manager becomes active
+	if activeManager == "" {
+		scopedLog.Info("Waiting for a cluster manager to become active")
+		return nil
+	}
+
+	// label the standby cluster managers first, so that the service never targets two of them
+	for _, member := range managers {
+		if member.Name != activeManager {
+			err := setClusterManagerRole(ctx, client, mgr.cr, member.Name, clusterManagerRoleStandby)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := setClusterManagerRole(ctx, client, mgr.cr, activeManager, clusterManagerRoleActive)
+	if err != nil {
+		return err
+	}
+
+	if mgr.cr.Status.ActiveManager != activeManager {
+		scopedLog.Info("Active cluster manager changed", "previous", mgr.cr.Status.ActiveManager, "current", activeManager)
+		mgr.cr.Status.ActiveManager = activeManager
+	}
+	return nil
+}
+
+// setClusterManagerRole sets the role label of a cluster manager pod
+func setClusterManagerRole(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.ClusterManager, podName string, role string) error {
+	pod := &corev1.Pod{}
+	err := client.Get(ctx, types.NamespacedName{Namespace: cr.GetNamespace(), Name: podName}, pod)
+	if err != nil {
+		// the pod may not be created yet
+		return nil
+	}
+
+	if pod.GetLabels()[enterpriseApi.ClusterManagerRoleLabel] == role {
+		return nil
+	}
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
+	}
+	pod.Labels[enterpriseApi.ClusterManagerRoleLabel] = role
+	return splutil.UpdateResource(ctx, client, pod)
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getRedundantClusterManager() *enterpriseApi.ClusterManager {
+	return &enterpriseApi.ClusterManager{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ClusterManager",
+			APIVersion: "enterprise.splunk.com/v4",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cm",
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.ClusterManagerSpec{
+			Replicas:              2,
+			ManagerSwitchoverMode: "auto",
+		},
+	}
+}
+
+func TestGetActiveClusterManagerPodName(t *testing.T) {
+	cr := getRedundantClusterManager()
+
+	// the pod 0, till the active cluster manager is known
+	if got := getActiveClusterManagerPodName(cr); got != "splunk-cm-cluster-manager-0" {
+		t.Errorf("getActiveClusterManagerPodName() = %s; want splunk-cm-cluster-manager-0", got)
+	}
+
+	cr.Status.ActiveManager = "splunk-cm-cluster-manager-1"
+	if got := getActiveClusterManagerPodName(cr); got != "splunk-cm-cluster-manager-1" {
+		t.Errorf("getActiveClusterManagerPodName() = %s; want splunk-cm-cluster-manager-1", got)
+	}
+
+	// the App Framework deploys the apps through the active cluster manager
+	if got := getAppFrameworkTargetPodName(cr); got != "splunk-cm-cluster-manager-1" {
+		t.Errorf("getAppFrameworkTargetPodName() = %s; want splunk-cm-cluster-manager-1", got)
+	}
+	standalone := &enterpriseApi.Standalone{TypeMeta: metav1.TypeMeta{Kind: "Standalone"}, ObjectMeta: metav1.ObjectMeta{Name: "stack1"}}
+	if got := getAppFrameworkTargetPodName(standalone); got != "splunk-stack1-standalone-0" {
+		t.Errorf("getAppFrameworkTargetPodName() = %s; want splunk-stack1-standalone-0", got)
+	}
+}
+
+func TestGetClusterManagerRedundancyDefaults(t *testing.T) {
+	cr := getRedundantClusterManager()
+	defaults := getClusterManagerRedundancyDefaults(cr)
+	want := `splunk:
+  conf:
+    - key: server
+      value:
+        directory: /opt/splunk/etc/system/local
+        content:
+          clustering:
+            manager_switchover_mode: auto
+            manager_uri: clustermanager:cm0,clustermanager:cm1
+          clustermanager:cm0:
+            manager_uri: https://splunk-cm-cluster-manager-0.splunk-cm-cluster-manager-headless.test.svc.cluster.local:8089
+          clustermanager:cm1:
+            manager_uri: https://splunk-cm-cluster-manager-1.splunk-cm-cluster-manager-headless.test.svc.cluster.local:8089
+`
+	if defaults != want {
+		t.Errorf("getClusterManagerRedundancyDefaults() = %s; want %s", defaults, want)
+	}
+}
+
+func TestGetClusterManagerStatefulSetRedundant(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	cr := getRedundantClusterManager()
+
+	err := applyClusterManagerRedundancyConfigMap(ctx, c, cr)
+	if err != nil {
+		t.Errorf("redundancy config map should be created. error: %v", err)
+	}
+
+	ss, err := getClusterManagerStatefulSet(ctx, c, cr)
+	if err != nil {
+		t.Errorf("cluster manager statefulset should be created. error: %v", err)
+	}
+	if *ss.Spec.Replicas != 2 {
+		t.Errorf("cluster manager statefulset should have 2 replicas. got: %d", *ss.Spec.Replicas)
+	}
+
+	var defaultsURL string
+	for _, env := range ss.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "SPLUNK_DEFAULTS_URL" {
+			defaultsURL = env.Value
+		}
+	}
+	if !strings.HasPrefix(defaultsURL, "/mnt/splunk-redundancy/default.yml,") {
+		t.Errorf("redundancy defaults should be used. got: %s", defaultsURL)
+	}
+
+	found := false
+	for _, volume := range ss.Spec.Template.Spec.Volumes {
+		if volume.ConfigMap != nil && volume.ConfigMap.Name == "splunk-cm-cluster-manager-redundancy" {
+			found = true
+		}
+	}
+	if !found || ss.Spec.Template.ObjectMeta.Annotations["redundancyConfigRev"] == "" {
+		t.Errorf("redundancy defaults should be mounted")
+	}
+}
+
+func TestUpdateActiveClusterManager(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	cr := getRedundantClusterManager()
+	for n := 0; n < 2; n++ {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("splunk-cm-cluster-manager-%d", n), Namespace: "test"}}
+		err := c.Create(ctx, pod)
+		if err != nil {
+			t.Errorf("pod should be created. error: %v", err)
+		}
+	}
+
+	savedCall := GetClusterManagerRedundancyCall
+	defer func() { GetClusterManagerRedundancyCall = savedCall }()
+	haModes := []string{"Standby", "Standby"}
+	GetClusterManagerRedundancyCall = func(ctx context.Context, mgr *clusterManagerPodManager, n int32) (*splclient.ClusterManagerRedundancyInfo, error) {
+		if haModes[n] == "" {
+			return nil, fmt.Errorf("cluster manager is down")
+		}
+		return &splclient.ClusterManagerRedundancyInfo{HAMode: haModes[n], SwitchoverMode: "auto"}, nil
+	}
+
+	getRole := func(n int) string {
+		pod := &corev1.Pod{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: "test", Name: fmt.Sprintf("splunk-cm-cluster-manager-%d", n)}, pod)
+		if err != nil {
+			t.Errorf("pod should exist. error: %v", err)
+		}
+		return pod.GetLabels()[enterpriseApi.ClusterManagerRoleLabel]
+	}
+
+	mgr := &clusterManagerPodManager{cr: cr}
+
+	// waits for a cluster manager to become active
+	err := mgr.updateActiveClusterManager(ctx, c)
+	if err != nil || cr.Status.ActiveManager != "" || len(cr.Status.Managers) != 2 || getRole(0) != "" {
+		t.Errorf("no cluster manager should be active. got: %v, error: %v", cr.Status, err)
+	}
+
+	haModes[0] = "Active"
+	err = mgr.updateActiveClusterManager(ctx, c)
+	if err != nil || cr.Status.ActiveManager != "splunk-cm-cluster-manager-0" || getRole(0) != clusterManagerRoleActive || getRole(1) != clusterManagerRoleStandby {
+		t.Errorf("first cluster manager should be active. got: %v, error: %v", cr.Status, err)
+	}
+
+	// switchover to the second cluster manager, when the first one is down
+	haModes[0] = ""
+	haModes[1] = "Active"
+	err = mgr.updateActiveClusterManager(ctx, c)
+	if err != nil || cr.Status.ActiveManager != "splunk-cm-cluster-manager-1" || getRole(0) != clusterManagerRoleStandby || getRole(1) != clusterManagerRoleActive {
+		t.Errorf("second cluster manager should be active. got: %v, error: %v", cr.Status, err)
+	}
+	if cr.Status.Managers[0].HAMode != "" || cr.Status.Managers[1].HAMode != "Active" {
+		t.Errorf("redundancy state should be tracked. got: %v", cr.Status.Managers)
+	}
+}
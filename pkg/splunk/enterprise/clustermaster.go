@@ -55,7 +55,7 @@ func ApplyClusterMaster(ctx context.Context, client splcommon.ControllerClient,
 	}
 
 	// validate and updates defaults for CR
-	err := validateClusterMasterSpec(ctx, client, cr)
+	appFrameworkConf, err := validateClusterMasterSpec(ctx, client, cr)
 	if err != nil {
 		return result, err
 	}
@@ -95,7 +95,7 @@ func ApplyClusterMaster(ctx context.Context, client splcommon.ControllerClient,
 	defer updateCRStatus(ctx, client, cr)
 
 	// If needed, Migrate the app framework status
-	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig, false)
+	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf, false)
 	if err != nil {
 		return result, err
 	}
@@ -103,8 +103,8 @@ func ApplyClusterMaster(ctx context.Context, client splcommon.ControllerClient,
 	// If the app framework is configured then do following things -
 	// 1. Initialize the S3Clients based on providers
 	// 2. Check the status of apps on remote storage.
-	if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
-		err := initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext)
+	if len(appFrameworkConf.AppSources) != 0 {
+		err := initAndCheckAppInfoStatus(ctx, client, cr, appFrameworkConf, &cr.Status.AppContext)
 		if err != nil {
 			eventPublisher.Warning(ctx, "initAndCheckAppInfoStatus", fmt.Sprintf("init and check app info status failed %s", err.Error()))
 			cr.Status.AppContext.IsDeploymentInProgress = false
@@ -133,12 +133,15 @@ func ApplyClusterMaster(ctx context.Context, client splcommon.ControllerClient,
 		// If this is the last of its kind getting deleted,
 		// remove the entry for this CR type from configMap or else
 		// just decrement the refCount for this CR type.
-		if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
+		if len(appFrameworkConf.AppSources) != 0 {
 			err = UpdateOrRemoveEntryFromConfigMapLocked(ctx, client, cr, SplunkClusterMaster)
 			if err != nil {
 				return result, err
 			}
 		}
+
+		// remove the app packages kept for the rollback
+		removeAppRollbackPkgs(ctx, getAppRollbackDir(cr))
 		DeleteOwnerReferencesForResources(ctx, client, cr, &cr.Spec.SmartStore, SplunkClusterMaster)
 		terminating, err := splctrl.CheckForDeletion(ctx, cr, client)
 
@@ -222,7 +225,7 @@ func ApplyClusterMaster(ctx context.Context, client splcommon.ControllerClient,
 			return result, err
 		}
 
-		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig)
+		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf)
 		result = *finalResult
 	}
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
@@ -249,23 +252,34 @@ func (mgr *clusterMasterPodManager) getClusterMasterClient(cr *enterpriseApiV3.C
 }
 
 // validateClusterMasterSpec checks validity and makes default updates to a ClusterMasterSpec, and returns error if something is wrong.
-func validateClusterMasterSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApiV3.ClusterMaster) error {
+// It returns the App Framework config to deploy the apps with, with the AppSource references resolved.
+func validateClusterMasterSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApiV3.ClusterMaster) (*enterpriseApi.AppFrameworkSpec, error) {
 
 	if !reflect.DeepEqual(cr.Status.SmartStore, cr.Spec.SmartStore) {
 		err := ValidateSplunkSmartstoreSpec(ctx, &cr.Spec.SmartStore)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, cr.Spec.AppFrameworkConfig) {
-		err := ValidateAppFrameworkSpec(ctx, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext, false, cr.GetObjectKind().GroupVersionKind().Kind)
+	appFrameworkConf, err := resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, *appFrameworkConf) {
+		err = ValidateAppFrameworkSpec(ctx, appFrameworkConf, &cr.Status.AppContext, false, cr.GetObjectKind().GroupVersionKind().Kind)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	err = validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return appFrameworkConf, nil
 }
 
 // getClusterMasterStatefulSet returns a Kubernetes StatefulSet object for a Splunk Enterprise license manager.
@@ -336,6 +350,12 @@ func PerformCmasterBundlePush(ctx context.Context, c splcommon.ControllerClient,
 		return fmt.Errorf("will re-attempt to push the bundle after the 5 seconds period passed from last check. LastCheckInterval=%d, current epoch=%d", cr.Status.BundlePushTracker.LastCheckInterval, currentEpoch)
 	}
 
+	// the bundle push can trigger a rolling restart of the peers, so it is held till the next maintenance window
+	if !isMaintenanceWindowOpen(ctx, &cr.Spec.AppFrameworkConfig) {
+		scopedLog.Info("Waiting for the maintenance window to push the bundle")
+		return nil
+	}
+
 	scopedLog.Info("Attempting to push the bundle")
 	cr.Status.BundlePushTracker.LastCheckInterval = currentEpoch
 
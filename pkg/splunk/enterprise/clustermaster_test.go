@@ -151,7 +151,7 @@ func TestGetClusterMasterStatefulSet(t *testing.T) {
 
 	test := func(want string) {
 		f := func() (interface{}, error) {
-			if err := validateClusterMasterSpec(ctx, c, &cr); err != nil {
+			if _, err := validateClusterMasterSpec(ctx, c, &cr); err != nil {
 				t.Errorf("validateClusterMasterSpec() returned error: %v", err)
 			}
 			return getClusterMasterStatefulSet(ctx, c, &cr)
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/wk8/go-ordered-map/v2"
 	appsv1 "k8s.io/api/apps/v1"
@@ -377,6 +378,11 @@ func validateCommonSplunkSpec(ctx context.Context, c splcommon.ControllerClient,
 		return err
 	}
 
+	err = validateSnapshotPolicy(spec)
+	if err != nil {
+		return err
+	}
+
 	setVolumeDefaults(spec)
 
 	return ValidateSpec(&spec.Spec, defaultResources)
@@ -642,7 +648,8 @@ func getSplunkStatefulSet(ctx context.Context, client splcommon.ControllerClient
 		return nil, err
 	}
 
-	if k8serrors.IsNotFound(err) {
+	isNewStatefulSet := k8serrors.IsNotFound(err)
+	if isNewStatefulSet {
 		// create statefulset configuration
 		statefulSet = &appsv1.StatefulSet{
 			TypeMeta: metav1.TypeMeta{
@@ -708,6 +715,14 @@ func getSplunkStatefulSet(ctx context.Context, client splcommon.ControllerClient
 	// append labels and annotations from parent
 	splcommon.AppendParentMeta(statefulSet.Spec.Template.GetObjectMeta(), cr.GetObjectMeta())
 
+	// pre-populate the PVCs of a new statefulset from a snapshot set
+	if isNewStatefulSet && spec.RestoreFromSnapshotSet != "" {
+		err = restoreVolumeSnapshotSet(ctx, client, cr, spec.RestoreFromSnapshotSet, instanceType, statefulSet)
+		if err != nil {
+			return statefulSet, err
+		}
+	}
+
 	// retrieve the secret to upload to the statefulSet pod
 	statefulSetSecret, err := splutil.GetLatestVersionedSecret(ctx, client, cr, cr.GetNamespace(), statefulSet.GetName())
 	if err != nil || statefulSetSecret == nil {
@@ -1513,29 +1528,12 @@ func validateSplunkAppSources(appFramework *enterpriseApi.AppFrameworkSpec, loca
 
 // validatePremiumAppsInputs validates premium app source spec
 func validatePremiumAppsInputs(appSrc enterpriseApi.AppSourceSpec, crKind string) error {
-
-	if appSrc.AppSourceDefaultSpec.PremiumAppsProps.Type != enterpriseApi.PremiumAppsTypeEs {
-		return fmt.Errorf("invalid PremiumAppsProps. Valid value is %s", enterpriseApi.PremiumAppsTypeEs)
-	}
-
-	// Check sslEnablement in ES defaults
-	sslEnablementValue := appSrc.AppSourceDefaultSpec.PremiumAppsProps.EsDefaults.SslEnablement
-	if sslEnablementValue != "" && !(sslEnablementValue == enterpriseApi.SslEnablementAuto ||
-		sslEnablementValue == enterpriseApi.SslEnablementIgnore ||
-		sslEnablementValue == enterpriseApi.SslEnablementStrict) {
-		return fmt.Errorf("invalid sslEnablement. Valid values are %s or %s or %s", enterpriseApi.SslEnablementAuto,
-			enterpriseApi.SslEnablementIgnore, enterpriseApi.SslEnablementStrict)
+	premiumAppType, ok := getPremiumAppType(appSrc.AppSourceDefaultSpec.PremiumAppsProps.Type)
+	if !ok {
+		return fmt.Errorf("invalid PremiumAppsProps. Valid values are %s", strings.Join(getPremiumAppTypeNames(), " or "))
 	}
 
-	// SHC ES app cannot use ssl_enablement auto, product doesn't support it
-	if crKind == "SearchHeadCluster" {
-		if appSrc.PremiumAppsProps.Type == enterpriseApi.PremiumAppsTypeEs {
-			if appSrc.AppSourceDefaultSpec.PremiumAppsProps.EsDefaults.SslEnablement == enterpriseApi.SslEnablementAuto {
-				return fmt.Errorf("scope for app source: %s search head cluster cannot have an ES app installed with ssl_enablement auto", appSrc.Name)
-			}
-		}
-	}
-	return nil
+	return premiumAppType.validate(&appSrc, crKind)
 }
 
 // isAppFrameworkConfigured checks and returns true if App Framework is configured
@@ -1591,6 +1589,21 @@ func ValidateAppFrameworkSpec(ctx context.Context, appFramework *enterpriseApi.A
 	}
 
 	err = validateSplunkAppSources(appFramework, localScope, crKind)
+	if err != nil {
+		return err
+	}
+
+	err = validateAppInstallDependencies(ctx, appFramework)
+	if err != nil {
+		return err
+	}
+
+	err = validateMaintenanceWindows(appFramework)
+	if err != nil {
+		return err
+	}
+
+	err = validateAppConfigOverlays(appFramework)
 	if err == nil {
 		scopedLog.Info("App framework configuration is valid")
 	}
@@ -1619,7 +1632,7 @@ func validateRemoteVolumeSpec(ctx context.Context, volList []enterpriseApi.Volum
 		if volume.Endpoint == "" {
 			return fmt.Errorf("volume Endpoint URI is missing")
 		}
-		if volume.Path == "" {
+		if volume.Path == "" && volume.Ref == "" {
 			return fmt.Errorf("volume Path is missing")
 		}
 		// Make the secretRef optional if theyre using IAM roles
@@ -1628,20 +1641,27 @@ func validateRemoteVolumeSpec(ctx context.Context, volList []enterpriseApi.Volum
 		}
 
 		// provider is used in App framework to pick the S3 client(supported providers are aws and minio),
-		// or Blob client (supported provider is azure) and is not applicable to Smartstore
+		// Blob client (supported provider is azure), git client (supported provider is git) or http manifest client
+		// (supported provider is http) and is not applicable to Smartstore
 		// For now, Smartstore supports only S3, which is by default.
 		if isAppFramework {
 			if !isValidStorageType(volume.Type) {
-				return fmt.Errorf("storageType '%s' is invalid. Valid values are 's3' and 'blob'", volume.Type)
+				return fmt.Errorf("storageType '%s' is invalid. Valid values are 's3', 'blob', 'git' and 'http'", volume.Type)
 			}
 
 			if !isValidProvider(volume.Provider) {
-				return fmt.Errorf("provider '%s' is invalid. Valid values are 'aws', 'minio' and 'azure'", volume.Provider)
+				return fmt.Errorf("provider '%s' is invalid. Valid values are 'aws', 'minio', 'azure', 'git' and 'http'", volume.Provider)
 			}
 
 			if !isValidProviderForStorageType(volume.Type, volume.Provider) {
-				return fmt.Errorf("storageType '%s' cannot be used with provider '%s'. Valid combinations are (s3,aws), (s3,minio) and (blob,azure)", volume.Type, volume.Provider)
+				return fmt.Errorf("storageType '%s' cannot be used with provider '%s'. Valid combinations are (s3,aws), (s3,minio), (blob,azure), (git,git) and (http,http)", volume.Type, volume.Provider)
+			}
+
+			if volume.Ref != "" && volume.Provider != "git" {
+				return fmt.Errorf("volume ref is supported only for the git provider. volume: %s", volume.Name)
 			}
+		} else if volume.Ref != "" {
+			return fmt.Errorf("volume ref is supported only for the App Framework. volume: %s", volume.Name)
 		}
 	}
 	return nil
@@ -1649,19 +1669,23 @@ func validateRemoteVolumeSpec(ctx context.Context, volList []enterpriseApi.Volum
 
 // isValidStorageType checks if the storage type specified is valid and supported
 func isValidStorageType(storage string) bool {
-	return storage != "" && (storage == "s3" || storage == "blob")
+	return storage != "" && (storage == "s3" || storage == "blob" || storage == "git" || storage == "http")
 }
 
 // isValidProvider checks if the provider specified is valid and supported
 func isValidProvider(provider string) bool {
-	return provider != "" && (provider == "aws" || provider == "minio" || provider == "azure")
+	return provider != "" && (provider == "aws" || provider == "minio" || provider == "azure" || provider == "git" || provider == "http")
 }
 
 // Valid provider for s3 are aws and minio
 // Valid provider for blob is azure
+// Valid provider for git is git
+// Valid provider for http is http
 func isValidProviderForStorageType(storageType string, provider string) bool {
 	return ((storageType == "s3" && (provider == "aws" || provider == "minio")) ||
-		(storageType == "blob" && provider == "azure"))
+		(storageType == "blob" && provider == "azure") ||
+		(storageType == "git" && provider == "git") ||
+		(storageType == "http" && provider == "http"))
 }
 
 // validateSplunkIndexesSpec validates the smartstore index spec
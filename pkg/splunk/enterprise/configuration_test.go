@@ -325,7 +325,7 @@ func TestSmartStoreConfigDoesNotFailOnClusterManagerCR(t *testing.T) {
 		},
 	}
 
-	err := validateClusterManagerSpec(ctx, c, &cr)
+	_, err := validateClusterManagerSpec(ctx, c, &cr)
 
 	if err != nil {
 		t.Errorf("Smartstore configuration should not fail on ClusterManager CR: %v", err)
@@ -664,6 +664,24 @@ func TestValidatePremiumAppsInputs(t *testing.T) {
 	if err == nil {
 		t.Errorf("Expected to see an error for invalid ssl_enablement for SHC in ES")
 	}
+
+	// ITSI is supported on standalone and SHC
+	appSrcSpec.PremiumAppsProps.Type = enterpriseApi.PremiumAppsTypeItsi
+	err = validatePremiumAppsInputs(appSrcSpec, "SearchHeadCluster")
+	if err != nil {
+		t.Errorf("Should pass, valid ITSI config. error: %v", err)
+	}
+
+	err = validatePremiumAppsInputs(appSrcSpec, "ClusterManager")
+	if err == nil {
+		t.Errorf("Expected to see an error for ITSI on cluster manager")
+	}
+
+	appSrcSpec.PremiumAppsProps.ItsiDefaults.KvStoreReadyTimeout = -1
+	err = validatePremiumAppsInputs(appSrcSpec, "Standalone")
+	if err == nil {
+		t.Errorf("Expected to see an error for negative kvStoreReadyTimeout")
+	}
 }
 
 func TestValidateAppFrameworkSpec(t *testing.T) {
@@ -969,14 +987,14 @@ func TestValidateAppFrameworkSpec(t *testing.T) {
 	// Invalid remote volume type should return error.
 	AppFramework.VolList[0].Type = "s4"
 	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
-	if err == nil || !strings.Contains(err.Error(), "storageType 's4' is invalid. Valid values are 's3' and 'blob'") {
+	if err == nil || !strings.Contains(err.Error(), "storageType 's4' is invalid. Valid values are 's3', 'blob', 'git' and 'http'") {
 		t.Errorf("ValidateAppFrameworkSpec with invalid remote volume type should have returned error.")
 	}
 
 	AppFramework.VolList[0].Type = "s3"
 	AppFramework.VolList[0].Provider = "invalid-provider"
 	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
-	if err == nil || !strings.Contains(err.Error(), "provider 'invalid-provider' is invalid. Valid values are 'aws', 'minio' and 'azure'") {
+	if err == nil || !strings.Contains(err.Error(), "provider 'invalid-provider' is invalid. Valid values are 'aws', 'minio', 'azure', 'git' and 'http'") {
 		t.Errorf("ValidateAppFrameworkSpec with invalid provider should have returned error.")
 	}
 
@@ -984,7 +1002,7 @@ func TestValidateAppFrameworkSpec(t *testing.T) {
 	AppFramework.VolList[0].Type = "s3"
 	AppFramework.VolList[0].Provider = "azure"
 	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
-	if err == nil || !strings.Contains(err.Error(), "storageType 's3' cannot be used with provider 'azure'. Valid combinations are (s3,aws), (s3,minio) and (blob,azure)") {
+	if err == nil || !strings.Contains(err.Error(), "storageType 's3' cannot be used with provider 'azure'. Valid combinations are (s3,aws), (s3,minio), (blob,azure), (git,git) and (http,http)") {
 		t.Errorf("ValidateAppFrameworkSpec with s3 and azure combination should have returned error.")
 	}
 
@@ -1016,7 +1034,7 @@ func TestValidateAppFrameworkSpec(t *testing.T) {
 	AppFramework.VolList[0].Type = "blob"
 	AppFramework.VolList[0].Provider = "aws"
 	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
-	if err == nil || !strings.Contains(err.Error(), "storageType 'blob' cannot be used with provider 'aws'. Valid combinations are (s3,aws), (s3,minio) and (blob,azure)") {
+	if err == nil || !strings.Contains(err.Error(), "storageType 'blob' cannot be used with provider 'aws'. Valid combinations are (s3,aws), (s3,minio), (blob,azure), (git,git) and (http,http)") {
 		t.Errorf("ValidateAppFrameworkSpec with blob and aws combination should have returned error.")
 	}
 
@@ -1024,10 +1042,59 @@ func TestValidateAppFrameworkSpec(t *testing.T) {
 	AppFramework.VolList[0].Type = "blob"
 	AppFramework.VolList[0].Provider = "minio"
 	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
-	if err == nil || !strings.Contains(err.Error(), "storageType 'blob' cannot be used with provider 'minio'. Valid combinations are (s3,aws), (s3,minio) and (blob,azure)") {
+	if err == nil || !strings.Contains(err.Error(), "storageType 'blob' cannot be used with provider 'minio'. Valid combinations are (s3,aws), (s3,minio), (blob,azure), (git,git) and (http,http)") {
 		t.Errorf("ValidateAppFrameworkSpec with blob and minio combination should have returned error.")
 	}
 
+	// Validate git and git are right combination
+	AppFramework.VolList[0].Type = "git"
+	AppFramework.VolList[0].Provider = "git"
+	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
+	if err != nil {
+		t.Errorf("ValidateAppFrameworkSpec with git and git combination should not have returned error.")
+	}
+
+	// Validate the ref is used only for git, and takes the place of the path
+	AppFramework.VolList[0].Ref = "main"
+	savedPath := AppFramework.VolList[0].Path
+	AppFramework.VolList[0].Path = ""
+	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
+	if err != nil {
+		t.Errorf("ValidateAppFrameworkSpec with a git ref and no path should not have returned error. error: %v", err)
+	}
+	AppFramework.VolList[0].Path = savedPath
+	AppFramework.VolList[0].Type = "s3"
+	AppFramework.VolList[0].Provider = "aws"
+	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
+	if err == nil || !strings.Contains(err.Error(), "volume ref is supported only for the git provider") {
+		t.Errorf("ValidateAppFrameworkSpec with a ref for the aws provider should have returned error.")
+	}
+	AppFramework.VolList[0].Ref = ""
+
+	// Validate s3 and git are not right combination
+	AppFramework.VolList[0].Type = "s3"
+	AppFramework.VolList[0].Provider = "git"
+	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
+	if err == nil || !strings.Contains(err.Error(), "storageType 's3' cannot be used with provider 'git'") {
+		t.Errorf("ValidateAppFrameworkSpec with s3 and git combination should have returned error.")
+	}
+
+	// Validate http and http are right combination
+	AppFramework.VolList[0].Type = "http"
+	AppFramework.VolList[0].Provider = "http"
+	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
+	if err != nil {
+		t.Errorf("ValidateAppFrameworkSpec with http and http combination should not have returned error.")
+	}
+
+	// Validate http and aws are not right combination
+	AppFramework.VolList[0].Type = "http"
+	AppFramework.VolList[0].Provider = "aws"
+	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, false, "")
+	if err == nil || !strings.Contains(err.Error(), "storageType 'http' cannot be used with provider 'aws'") {
+		t.Errorf("ValidateAppFrameworkSpec with http and aws combination should have returned error.")
+	}
+
 	//
 	// Start of tests for premiumApps input validations
 	//
@@ -1064,7 +1131,7 @@ func TestValidateAppFrameworkSpec(t *testing.T) {
 	AppFramework.AppSources[0].Scope = enterpriseApi.ScopePremiumApps
 	AppFramework.AppSources[0].PremiumAppsProps.Type = "unknowndPremiumType"
 	err = ValidateAppFrameworkSpec(ctx, &AppFramework, &appFrameworkContext, true, "")
-	if err == nil || !strings.HasPrefix(err.Error(), "invalid PremiumAppsProps. Valid value is enterpriseSecurity") {
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid PremiumAppsProps. Valid values are enterpriseSecurity or itsi") {
 		t.Errorf("invalid premium app type should be detected, but failed")
 	}
 
@@ -38,25 +38,8 @@ func DeleteSplunkPvc(ctx context.Context, cr splcommon.MetaObject, c splcommon.C
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("DeleteSplunkPvc")
 
-	var components []string
-	switch objectKind {
-	case "Standalone":
-		components = append(components, "standalone")
-	case "LicenseMaster":
-		components = append(components, splcommon.LicenseManager)
-	case "LicenseManager":
-		components = append(components, "license-manager")
-	case "SearchHeadCluster":
-		components = append(components, "search-head", "deployer")
-	case "IndexerCluster":
-		components = append(components, "indexer")
-	case "ClusterManager":
-		components = append(components, "cluster-manager")
-	case "ClusterMaster":
-		components = append(components, splcommon.ClusterManager)
-	case "MonitoringConsole":
-		components = append(components, "monitoring-console")
-	default:
+	components := getSplunkPvcComponents(objectKind)
+	if len(components) == 0 {
 		scopedLog.Info("Skipping PVC removal")
 		return nil
 	}
@@ -86,3 +69,27 @@ func DeleteSplunkPvc(ctx context.Context, cr splcommon.MetaObject, c splcommon.C
 	}
 	return nil
 }
+
+// getSplunkPvcComponents returns the components whose PVCs belong to a CR of the given kind
+func getSplunkPvcComponents(objectKind string) []string {
+	switch objectKind {
+	case "Standalone":
+		return []string{"standalone"}
+	case "LicenseMaster":
+		return []string{splcommon.LicenseManager}
+	case "LicenseManager":
+		return []string{"license-manager"}
+	case "SearchHeadCluster":
+		return []string{"search-head", "deployer"}
+	case "IndexerCluster":
+		return []string{"indexer"}
+	case "ClusterManager":
+		return []string{"cluster-manager"}
+	case "ClusterMaster":
+		return []string{splcommon.ClusterManager}
+	case "MonitoringConsole":
+		return []string{"monitoring-console"}
+	default:
+		return nil
+	}
+}
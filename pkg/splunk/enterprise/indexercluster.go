@@ -110,6 +110,11 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 	}
 
 	mgr := newIndexerClusterPodManager(scopedLog, cr, namespaceScopedSecret, splclient.NewSplunkClient)
+	// a multisite IndexerCluster manages an IndexerCluster per site, instead of its own indexers
+	if len(cr.Spec.Sites) > 0 {
+		return mgr.applyIndexerClusterSites(ctx, client, result)
+	}
+
 	// Check if we have configured enough number(<= RF) of replicas
 	if mgr.cr.Status.ClusterManagerPhase == enterpriseApi.PhaseReady {
 		err = VerifyRFPeers(ctx, mgr, client)
@@ -148,6 +153,17 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 		return result, err
 	}
 
+	// the peers wait for an etc snapshot being restored into the cluster manager
+	restorePending, err := isEtcRestorePending(ctx, client, managerIdxCluster)
+	if err != nil {
+		return result, err
+	}
+	if restorePending {
+		scopedLog.Info("Waiting for the etc restore of the cluster manager", "clusterManagerRef", cr.Spec.ClusterManagerRef.Name)
+		cr.Status.Phase = enterpriseApi.PhasePending
+		return result, nil
+	}
+
 	// create or update statefulset for the indexers
 	statefulSet, err := getIndexerStatefulSet(ctx, client, cr)
 	if err != nil {
@@ -289,6 +305,15 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 			return result, err
 		}
 	}
+	// poll the progress of the data rebalance after a scale up
+	if cr.Status.Phase == enterpriseApi.PhaseReady && isDataRebalanceActive(cr) {
+		result.Requeue = true
+		result.RequeueAfter = dataRebalancePollInterval
+	}
+
+	// take the snapshot sets of the PVCs on schedule
+	applyVolumeSnapshotPolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, cr.Status.Phase == enterpriseApi.PhaseReady, &result)
+
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
 	if !result.Requeue {
@@ -361,6 +386,11 @@ func ApplyIndexerCluster(ctx context.Context, client splcommon.ControllerClient,
 	}
 
 	mgr := newIndexerClusterPodManager(scopedLog, cr, namespaceScopedSecret, splclient.NewSplunkClient)
+	// a multisite IndexerCluster manages an IndexerCluster per site, instead of its own indexers
+	if len(cr.Spec.Sites) > 0 {
+		return mgr.applyIndexerClusterSites(ctx, client, result)
+	}
+
 	// Check if we have configured enough number(<= RF) of replicas
 	if mgr.cr.Status.ClusterMasterPhase == enterpriseApi.PhaseReady {
 		err = VerifyRFPeers(ctx, mgr, client)
@@ -539,6 +569,15 @@ func ApplyIndexerCluster(ctx context.Context, client splcommon.ControllerClient,
 			return result, err
 		}
 	}
+	// poll the progress of the data rebalance after a scale up
+	if cr.Status.Phase == enterpriseApi.PhaseReady && isDataRebalanceActive(cr) {
+		result.Requeue = true
+		result.RequeueAfter = dataRebalancePollInterval
+	}
+
+	// take the snapshot sets of the PVCs on schedule
+	applyVolumeSnapshotPolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, cr.Status.Phase == enterpriseApi.PhaseReady, &result)
+
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
 	if !result.Requeue {
@@ -790,8 +829,30 @@ func (mgr *indexerClusterPodManager) Update(ctx context.Context, c splcommon.Con
 		return enterpriseApi.PhasePending, nil
 	}
 
+	// apply the restart only updates with a searchable rolling restart of the peers, if enabled
+	if mgr.isSearchableRollingRestartEnabled() {
+		inProgress, phase, err := mgr.applySearchableRollingRestart(ctx, statefulSet, desiredReplicas)
+		if err != nil || inProgress {
+			return phase, err
+		}
+	}
+
+	// rebalance the data once the indexer cluster is scaled up, if enabled
+	err = mgr.markDataRebalancePending(ctx, statefulSet, desiredReplicas)
+	if err != nil {
+		return enterpriseApi.PhaseError, err
+	}
+
 	// manage scaling and updates
-	return splctrl.UpdateStatefulSetPods(ctx, c, statefulSet, mgr, desiredReplicas)
+	phase, err := splctrl.UpdateStatefulSetPods(ctx, c, statefulSet, mgr, desiredReplicas)
+	if err == nil && phase == enterpriseApi.PhaseReady && isDataRebalanceActive(mgr.cr) {
+		err = mgr.applyDataRebalance(ctx)
+		if err != nil {
+			return enterpriseApi.PhaseError, err
+		}
+	}
+
+	return phase, err
 }
 
 // PrepareScaleDown for indexerClusterPodManager prepares indexer pod to be removed via scale down event; it returns true when ready
@@ -888,7 +949,8 @@ func (mgr *indexerClusterPodManager) getClient(ctx context.Context, n int32) *sp
 	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", adminPwd)
 }
 
-// getClusterManagerClient for indexerClusterPodManager returns a SplunkClient for cluster manager
+// getClusterManagerClient for indexerClusterPodManager returns a SplunkClient for cluster manager. The service of the
+// cluster manager only targets the active cluster manager, when redundant
 func (mgr *indexerClusterPodManager) getClusterManagerClient(ctx context.Context) *splclient.SplunkClient {
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("indexerClusterPodManager.getClusterManagerClient")
@@ -950,6 +1012,13 @@ func (mgr *indexerClusterPodManager) verifyRFPeers(ctx context.Context, c splcom
 	}
 
 	if mgr.cr.Spec.Replicas < replicationFactor {
+		// refuse to scale an indexer cluster which already runs enough peers below the replication factor
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: GetSplunkStatefulsetName(SplunkIndexer, mgr.cr.GetName())}
+		statefulSet, err := splctrl.GetStatefulSetByName(ctx, mgr.c, namespacedName)
+		if err == nil && statefulSet.Spec.Replicas != nil && *statefulSet.Spec.Replicas >= replicationFactor {
+			return fmt.Errorf("refusing to scale indexer cluster to %d replicas, below the replication factor %d", mgr.cr.Spec.Replicas, replicationFactor)
+		}
+
 		mgr.log.Info("Changing number of replicas as it is less than RF number of peers", "replicas", mgr.cr.Spec.Replicas)
 		mgr.cr.Spec.Replicas = replicationFactor
 	}
@@ -1049,6 +1118,13 @@ func validateIndexerClusterSpec(ctx context.Context, c splcommon.ControllerClien
 		len(cr.Spec.ClusterMasterRef.Namespace) > 0 && cr.Spec.ClusterMasterRef.Namespace != cr.GetNamespace() {
 		return fmt.Errorf("multisite cluster does not support cluster manager to be located in a different namespace")
 	}
+
+	if len(cr.Spec.Sites) > 0 {
+		err := validateIndexerClusterSites(cr)
+		if err != nil {
+			return err
+		}
+	}
 	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
 }
 
@@ -1097,7 +1173,8 @@ func getIndexerClusterSortedSiteList(ctx context.Context, c splcommon.Controller
 
 	namespaceList := enterpriseApi.IndexerClusterList{}
 	for _, v := range indexerList.Items {
-		if v.Spec.ClusterManagerRef == ref {
+		// a multisite IndexerCluster has no indexers of its own, only the IndexerCluster of each site
+		if v.Spec.ClusterManagerRef == ref && len(v.Spec.Sites) == 0 {
 			namespaceList.Items = append(namespaceList.Items, v)
 		}
 	}
@@ -1110,6 +1187,11 @@ func getIndexerClusterSortedSiteList(ctx context.Context, c splcommon.Controller
 }
 
 func getSiteName(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster) string {
+	// the IndexerCluster of a site of a multisite IndexerCluster is labelled with its site
+	if site, ok := cr.GetLabels()[enterpriseApi.IndexerClusterSiteLabel]; ok {
+		return site
+	}
+
 	defaults := cr.Spec.Defaults
 	// site name starts with site:
 	pattern := `site:\s+(\w+)`
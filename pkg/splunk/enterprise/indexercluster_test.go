@@ -466,6 +466,9 @@ func TestVerifyRFPeers(t *testing.T) {
 
 	wantCalls := map[string][]spltest.MockFuncCall{"Get": {funcCalls[0]}}
 
+	// replicas below the replication factor check the running peers
+	belowRFCalls := map[string][]spltest.MockFuncCall{"Get": {funcCalls[0], {MetaName: "*v1.StatefulSet-test-splunk-stack1-indexer"}}}
+
 	// test 1 ready pod
 	mockHandlers := []spltest.MockHTTPHandler{
 		{
@@ -482,7 +485,7 @@ func TestVerifyRFPeers(t *testing.T) {
 	indexerClusterPodManagerReplicasTester(t, method, mockHandlers, 3 /*replicas*/, 3 /*desired replicas*/, enterpriseApi.PhaseReady, wantCalls, nil)
 
 	// test for singlesite i.e. with replication_factor=3(on ClusterManager) and replicas=1(on IndexerCluster)
-	indexerClusterPodManagerReplicasTester(t, method, mockHandlers, 1 /*replicas*/, 3 /*desired replicas*/, enterpriseApi.PhaseReady, wantCalls, nil)
+	indexerClusterPodManagerReplicasTester(t, method, mockHandlers, 1 /*replicas*/, 3 /*desired replicas*/, enterpriseApi.PhaseReady, belowRFCalls, nil)
 
 	// Now test for multi-site too
 	mockHandlers[0].Body = `{"links":{"_reload":"/services/cluster/config/_reload","_acl":"/services/cluster/config/_acl"},"origin":"https://localhost:8089/services/cluster/config","updated":"2020-10-28T21:37:07+00:00","generator":{"build":"152fb4b2bb96","version":"8.0.6"},"entry":[{"name":"config","id":"https://localhost:8089/services/cluster/config/config","updated":"1970-01-01T00:00:00+00:00","links":{"alternate":"/services/cluster/config/config","list":"/services/cluster/config/config","_reload":"/services/cluster/config/config/_reload","edit":"/services/cluster/config/config","disable":"/services/cluster/config/config/disable"},"author":"system","acl":{"app":"","can_list":true,"can_write":true,"modifiable":false,"owner":"system","perms":{"read":["admin","splunk-system-role"],"write":["admin","splunk-system-role"]},"removable":false,"sharing":"system"},"content":{"access_logging_for_heartbeats":false,"auto_rebalance_primaries":true,"buckets_to_summarize":"primaries","cluster_label":"idxc_label","cxn_timeout":60,"decommission_force_finish_idle_time":0,"decommission_force_timeout":180,"disabled":false,"eai:acl":null,"forwarderdata_rcv_port":0,"forwarderdata_use_ssl":false,"frozen_notifications_per_batch":10,"guid":"F643BA71-0D3C-4D63-A0BC-A1604AC928E3","heartbeat_period":18446744073709552000,"heartbeat_timeout":60,"master_uri":"https://127.0.0.1:8089","max_auto_service_interval":30,"max_fixup_time_ms":5000,"max_peer_build_load":2,"max_peer_rep_load":5,"max_peer_sum_rep_load":5,"max_peers_to_download_bundle":5,"max_primary_backups_per_service":10,"mode":"master","multisite":"true","notify_buckets_period":10,"notify_scan_min_period":10,"notify_scan_period":10,"percent_peers_to_restart":10,"ping_flag":true,"quiet_period":60,"rcv_timeout":60,"rebalance_primaries_execution_limit_ms":0,"rebalance_threshold":0.9,"register_forwarder_address":"","register_replication_address":"","register_search_address":"","remote_storage_upload_timeout":60,"rep_cxn_timeout":60,"rep_max_rcv_timeout":180,"rep_max_send_timeout":180,"rep_rcv_timeout":60,"rep_send_timeout":60,"replication_factor":3,"replication_port":null,"replication_use_ssl":false,"report_remote_storage_bucket_upload_to_targets":false,"reporting_delay_period":30,"restart_inactivity_timeout":600,"restart_timeout":60,"rolling_restart":"restart","search_factor":3,"search_files_retry_timeout":600,"secret":"********","send_timeout":60,"service_interval":0,"site":"site1","site_by_site":true,"site_replication_factor":"{ origin:2, total:2 }","site_search_factor":"{ origin:2, total:2 }","summary_replication":"false","use_batch_discard":"true","use_batch_mask_changes":"true","use_batch_remote_rep_changes":"false"}}],"paging":{"total":1,"perPage":10000000,"offset":0},"messages":[]}`
@@ -491,7 +494,93 @@ func TestVerifyRFPeers(t *testing.T) {
 	indexerClusterPodManagerReplicasTester(t, method, mockHandlers, 2 /*replicas*/, 2 /*desired replicas*/, enterpriseApi.PhaseReady, wantCalls, nil)
 
 	//test for multisite i.e. with site_replication_factor=origin:2,total:2(on ClusterManager) and replicas=1(on IndexerCluster)
-	indexerClusterPodManagerReplicasTester(t, method, mockHandlers, 1 /*replicas*/, 2 /*desired replicas*/, enterpriseApi.PhaseReady, wantCalls, nil)
+	indexerClusterPodManagerReplicasTester(t, method, mockHandlers, 1 /*replicas*/, 2 /*desired replicas*/, enterpriseApi.PhaseReady, belowRFCalls, nil)
+}
+
+func TestIndexerClusterScaleBelowRF(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	utilruntime.Must(enterpriseApiV3.AddToScheme(clientgoscheme.Scheme))
+
+	savedVerifyRFPeers := VerifyRFPeers
+	savedNewIndexerClusterPodManager := newIndexerClusterPodManager
+	defer func() {
+		VerifyRFPeers = savedVerifyRFPeers
+		newIndexerClusterPodManager = savedNewIndexerClusterPodManager
+	}()
+	VerifyRFPeers = func(ctx context.Context, mgr indexerClusterPodManager, client splcommon.ControllerClient) error {
+		return mgr.verifyRFPeers(ctx, client)
+	}
+
+	// cluster manager reports replication_factor=3
+	clusterConfig := `{"entry":[{"content":{"multisite":"false","replication_factor":3,"search_factor":2}}]}`
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	for _, service := range []string{"cluster-manager", "cluster-master"} {
+		wantRequest, _ := http.NewRequest("GET", fmt.Sprintf("https://splunk-manager1-%s-service.test.svc.cluster.local:8089/services/cluster/config?count=0&output_mode=json", service), nil)
+		mockSplunkClient.AddHandler(wantRequest, 200, clusterConfig, nil)
+	}
+	newIndexerClusterPodManager = func(log logr.Logger, cr *enterpriseApi.IndexerCluster, secret *corev1.Secret, newSplunkClient NewSplunkClientFunc) indexerClusterPodManager {
+		return indexerClusterPodManager{
+			log:     log,
+			cr:      cr,
+			secrets: secret,
+			newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+				c := splclient.NewSplunkClient(managementURI, username, password)
+				c.Client = mockSplunkClient
+				return c
+			},
+		}
+	}
+
+	for _, clusterMaster := range []bool{false, true} {
+		clusterManager := &enterpriseApi.ClusterManager{ObjectMeta: metav1.ObjectMeta{Name: "manager1", Namespace: "test"}}
+		clusterManager.Status.Phase = enterpriseApi.PhaseReady
+		clusterMasterCR := &enterpriseApiV3.ClusterMaster{ObjectMeta: metav1.ObjectMeta{Name: "manager1", Namespace: "test"}}
+		clusterMasterCR.Status.Phase = enterpriseApi.PhaseReady
+
+		// the indexer cluster already runs 3 peers
+		replicas := int32(3)
+		statefulSet := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-indexer", Namespace: "test"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		}
+		cr := &enterpriseApi.IndexerCluster{
+			TypeMeta:   metav1.TypeMeta{Kind: "IndexerCluster"},
+			ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+			Spec:       enterpriseApi.IndexerClusterSpec{Replicas: 1},
+		}
+		apply := ApplyIndexerClusterManager
+		if clusterMaster {
+			cr.Spec.ClusterMasterRef.Name = "manager1"
+			apply = ApplyIndexerCluster
+		} else {
+			cr.Spec.ClusterManagerRef.Name = "manager1"
+		}
+		c := fake.NewClientBuilder().WithObjects(clusterManager, clusterMasterCR, statefulSet, cr).Build()
+
+		// scaling the running peers below the replication factor is refused
+		_, err := apply(ctx, c, cr)
+		if err == nil || !strings.Contains(err.Error(), "refusing to scale indexer cluster to 1 replicas, below the replication factor 3") {
+			t.Errorf("scaling below the replication factor should have been refused, clusterMaster: %t, error: %v", clusterMaster, err)
+		}
+		if cr.Spec.Replicas != 1 {
+			t.Errorf("replicas should not have been changed, got: %d", cr.Spec.Replicas)
+		}
+
+		// a new indexer cluster is raised to the replication factor
+		replicas = 1
+		err = c.Update(ctx, statefulSet)
+		if err != nil {
+			t.Fatalf("unable to update the statefulset: %v", err)
+		}
+		_, err = apply(ctx, c, cr)
+		if err != nil && strings.Contains(err.Error(), "refusing to scale") {
+			t.Errorf("new indexer cluster should not have been refused, clusterMaster: %t, error: %v", clusterMaster, err)
+		}
+		if cr.Spec.Replicas != 3 {
+			t.Errorf("replicas should have been raised to the replication factor, got: %d", cr.Spec.Replicas)
+		}
+	}
 }
 
 func checkResponseFromUpdateStatus(t *testing.T, method string, mockHandlers []spltest.MockHTTPHandler, replicas int32, statefulSet *appsv1.StatefulSet, retry bool) error {
@@ -1923,3 +2012,65 @@ func TestImageUpdatedTo9(t *testing.T) {
 		t.Errorf("Should not have detected an upgrade from 8 to 9, there is no version")
 	}
 }
+
+func TestApplyIndexerClusterManagerPendingEtcRestore(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	restore := getTestSplunkRestore()
+	restore.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "ClusterManager", Name: "cm"}
+	err := c.Create(ctx, restore)
+	if err != nil {
+		t.Fatalf("SplunkRestore should be created. error: %v", err)
+	}
+
+	cm := &enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cm",
+			Namespace:   "test",
+			Annotations: map[string]string{enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation: restore.GetName()},
+		},
+	}
+	err = c.Create(ctx, cm)
+	if err != nil {
+		t.Fatalf("ClusterManager should be created. error: %v", err)
+	}
+
+	cr := &enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"},
+		Spec: enterpriseApi.IndexerClusterSpec{
+			Replicas: 3,
+			CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{
+				Mock:              true,
+				ClusterManagerRef: corev1.ObjectReference{Name: "cm"},
+			},
+		},
+	}
+	err = c.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("IndexerCluster should be created. error: %v", err)
+	}
+
+	// the peers wait for the etc restore of the cluster manager
+	_, err = ApplyIndexerClusterManager(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhasePending {
+		t.Errorf("peers should wait for the etc restore. phase: %s, error: %v", cr.Status.Phase, err)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-idxc-indexer"}, statefulSet)
+	if err == nil {
+		t.Errorf("indexer statefulset should not be created during the etc restore")
+	}
+
+	// the peers don't wait once the restore is done
+	restore.Status.RestoreTime = time.Now().Unix()
+	err = c.Status().Update(ctx, restore)
+	if err != nil {
+		t.Fatalf("SplunkRestore status should be updated. error: %v", err)
+	}
+	ApplyIndexerClusterManager(ctx, c, cr)
+	if cr.Status.Phase == enterpriseApi.PhasePending {
+		t.Errorf("peers should not wait once the etc restore is done")
+	}
+}
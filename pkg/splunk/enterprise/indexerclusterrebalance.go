@@ -0,0 +1,254 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// With rebalanceOnScaleUp enabled, a data rebalance is marked as pending when the indexer cluster is scaled up. Once all
+// the peers are up, the data rebalance is started on the cluster manager, and its progress is polled into the status of
+// the IndexerCluster. The data rebalance is never run while the indexer cluster is in maintenance mode, or a bundle is
+// being pushed to the peers. If either of them starts, the data rebalance is stopped, and started again afterwards.
+
+// dataRebalancePollInterval is the interval the progress of the data rebalance is polled at
+const dataRebalancePollInterval = 30 * time.Second
+
+// StartDataRebalanceCall function pointer to mock
+var StartDataRebalanceCall = func(ctx context.Context, mgr *indexerClusterPodManager, maxRuntimeMinutes int64) error {
+	c := mgr.getClusterManagerClient(ctx)
+	return c.StartDataRebalance(maxRuntimeMinutes)
+}
+
+// StopDataRebalanceCall function pointer to mock
+var StopDataRebalanceCall = func(ctx context.Context, mgr *indexerClusterPodManager) error {
+	c := mgr.getClusterManagerClient(ctx)
+	return c.StopDataRebalance()
+}
+
+// GetDataRebalanceStatusCall function pointer to mock
+var GetDataRebalanceStatusCall = func(ctx context.Context, mgr *indexerClusterPodManager) (*splclient.DataRebalanceStatus, error) {
+	c := mgr.getClusterManagerClient(ctx)
+	return c.GetDataRebalanceStatus()
+}
+
+// isDataRebalanceActive checks if a data rebalance is pending, or in progress
+func isDataRebalanceActive(cr *enterpriseApi.IndexerCluster) bool {
+	state := cr.Status.RebalanceStatus.State
+	return state == enterpriseApi.RebalanceStatePending || state == enterpriseApi.RebalanceStateInProgress
+}
+
+// markDataRebalancePending marks a data rebalance as pending, when the indexer cluster is being scaled up
+func (mgr *indexerClusterPodManager) markDataRebalancePending(ctx context.Context, statefulSet *appsv1.StatefulSet, desiredReplicas int32) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("markDataRebalancePending").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	// the initial peers of the indexer cluster have no data to rebalance
+	replicas := *statefulSet.Spec.Replicas
+	if !mgr.cr.Spec.RebalanceOnScaleUp.Enabled || statefulSet.Status.ReadyReplicas == 0 || replicas >= desiredReplicas {
+		return nil
+	}
+
+	status := &mgr.cr.Status.RebalanceStatus
+	if status.State == enterpriseApi.RebalanceStatePending && status.Replicas == desiredReplicas {
+		return nil
+	}
+
+	// a data rebalance in progress doesn't cover the new peers
+	if status.State == enterpriseApi.RebalanceStateInProgress {
+		scopedLog.Info("Stopping the data rebalance, to start it again once the new peers are up")
+		err := StopDataRebalanceCall(ctx, mgr)
+		if err != nil {
+			return err
+		}
+	}
+
+	scopedLog.Info("Data rebalance is pending till the new peers are up", "replicas", replicas, "desiredReplicas", desiredReplicas)
+	*status = enterpriseApi.IndexerClusterRebalanceStatus{
+		State:    enterpriseApi.RebalanceStatePending,
+		Replicas: desiredReplicas,
+		Message:  "Waiting for the new peers to be up",
+	}
+	return nil
+}
+
+// applyDataRebalance starts the pending data rebalance, or tracks the progress of the one in progress
+func (mgr *indexerClusterPodManager) applyDataRebalance(ctx context.Context) error {
+	switch mgr.cr.Status.RebalanceStatus.State {
+	case enterpriseApi.RebalanceStatePending:
+		return mgr.startDataRebalance(ctx)
+	case enterpriseApi.RebalanceStateInProgress:
+		return mgr.checkDataRebalance(ctx)
+	}
+	return nil
+}
+
+// getDataRebalanceBlocker returns the reason the data rebalance can't run now, if any
+func (mgr *indexerClusterPodManager) getDataRebalanceBlocker(ctx context.Context) (string, error) {
+	if mgr.cr.Status.MaintenanceMode {
+		return "indexer cluster is in maintenance mode", nil
+	}
+
+	clusterInfo, err := GetClusterManagerInfoCall(ctx, mgr)
+	if err != nil {
+		return "", err
+	}
+	if clusterInfo.MaintenanceMode {
+		return "indexer cluster is in maintenance mode", nil
+	}
+	if clusterInfo.ActiveBundle.Checksum != clusterInfo.LatestBundle.Checksum {
+		return "bundle push is in progress", nil
+	}
+	if clusterInfo.RollingRestart {
+		return "rolling restart of the peers is in progress", nil
+	}
+
+	return "", nil
+}
+
+// startDataRebalance starts the pending data rebalance on the cluster manager, once all the peers are up
+func (mgr *indexerClusterPodManager) startDataRebalance(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("startDataRebalance").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	status := &mgr.cr.Status.RebalanceStatus
+	if !mgr.cr.Spec.RebalanceOnScaleUp.Enabled {
+		scopedLog.Info("Data rebalance is disabled, skipping the pending data rebalance")
+		*status = enterpriseApi.IndexerClusterRebalanceStatus{}
+		return nil
+	}
+
+	// the indexer cluster may have been scaled down, since the data rebalance was marked as pending
+	if mgr.cr.Spec.Replicas < status.Replicas {
+		status.Replicas = mgr.cr.Spec.Replicas
+	}
+
+	var upPeers int32
+	for _, peer := range mgr.cr.Status.Peers {
+		if peer.Status == "Up" {
+			upPeers++
+		}
+	}
+	if upPeers < status.Replicas {
+		scopedLog.Info("Waiting for the new peers to be up", "upPeers", upPeers, "replicas", status.Replicas)
+		status.Message = "Waiting for the new peers to be up"
+		return nil
+	}
+
+	blocker, err := mgr.getDataRebalanceBlocker(ctx)
+	if err != nil {
+		return err
+	}
+	if blocker != "" {
+		scopedLog.Info("Waiting to start the data rebalance", "reason", blocker)
+		status.Message = fmt.Sprintf("Waiting to start, as the %s", blocker)
+		return nil
+	}
+
+	// the maximum runtime covers the data rebalance stopped and started again, from the time it was first started
+	maxRuntimeMinutes := mgr.cr.Spec.RebalanceOnScaleUp.MaxRuntimeMinutes
+	if status.StartTime != 0 && maxRuntimeMinutes > 0 {
+		elapsedMinutes := int64(time.Since(time.Unix(status.StartTime, 0)).Minutes())
+		if elapsedMinutes >= maxRuntimeMinutes {
+			scopedLog.Info("Not starting the data rebalance again, as it reached the maximum runtime", "maxRuntimeMinutes", maxRuntimeMinutes)
+			status.State = enterpriseApi.RebalanceStateStopped
+			status.Message = "Stopped, as the maximum runtime is reached"
+			status.EndTime = time.Now().Unix()
+			return nil
+		}
+		maxRuntimeMinutes -= elapsedMinutes
+	}
+
+	err = StartDataRebalanceCall(ctx, mgr, maxRuntimeMinutes)
+	if err != nil {
+		scopedLog.Error(err, "Unable to start the data rebalance")
+		return err
+	}
+
+	scopedLog.Info("Started the data rebalance", "maxRuntimeMinutes", maxRuntimeMinutes)
+	status.State = enterpriseApi.RebalanceStateInProgress
+	if status.StartTime == 0 {
+		status.StartTime = time.Now().Unix()
+	}
+	status.Progress = ""
+	status.Message = "Data rebalance started"
+	return nil
+}
+
+// stopDataRebalance stops the data rebalance in progress, and updates the status to the given state
+func (mgr *indexerClusterPodManager) stopDataRebalance(ctx context.Context, state string, message string) error {
+	err := StopDataRebalanceCall(ctx, mgr)
+	if err != nil {
+		return err
+	}
+
+	status := &mgr.cr.Status.RebalanceStatus
+	status.State = state
+	status.Message = message
+	if state == enterpriseApi.RebalanceStateStopped {
+		status.EndTime = time.Now().Unix()
+	}
+	return nil
+}
+
+// checkDataRebalance polls the progress of the data rebalance into the status, and stops it when it runs too long,
+// or the indexer cluster enters maintenance mode, or a bundle push starts
+func (mgr *indexerClusterPodManager) checkDataRebalance(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("checkDataRebalance").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	status := &mgr.cr.Status.RebalanceStatus
+	blocker, err := mgr.getDataRebalanceBlocker(ctx)
+	if err != nil {
+		return err
+	}
+	if blocker != "" {
+		scopedLog.Info("Stopping the data rebalance, to start it again later", "reason", blocker)
+		return mgr.stopDataRebalance(ctx, enterpriseApi.RebalanceStatePending, fmt.Sprintf("Stopped, as the %s", blocker))
+	}
+
+	maxRuntime := time.Duration(mgr.cr.Spec.RebalanceOnScaleUp.MaxRuntimeMinutes) * time.Minute
+	if maxRuntime > 0 && time.Since(time.Unix(status.StartTime, 0)) > maxRuntime {
+		scopedLog.Info("Stopping the data rebalance, as it reached the maximum runtime", "maxRuntime", maxRuntime.String())
+		return mgr.stopDataRebalance(ctx, enterpriseApi.RebalanceStateStopped, "Stopped, as the maximum runtime is reached")
+	}
+
+	rebalanceStatus, err := GetDataRebalanceStatusCall(ctx, mgr)
+	if err != nil {
+		scopedLog.Error(err, "Unable to get the status of the data rebalance")
+		return err
+	}
+	status.Message = rebalanceStatus.Message
+
+	if rebalanceStatus.Running {
+		status.Progress = fmt.Sprintf("%.2f", rebalanceStatus.Progress)
+		scopedLog.Info("Data rebalance is in progress", "progress", status.Progress)
+		return nil
+	}
+
+	scopedLog.Info("Data rebalance complete", "message", rebalanceStatus.Message)
+	status.State = enterpriseApi.RebalanceStateComplete
+	status.EndTime = time.Now().Unix()
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMarkDataRebalancePending(t *testing.T) {
+	ctx := context.TODO()
+	replicas := int32(2)
+	statefulSet := &appsv1.StatefulSet{
+		Spec:   appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1.StatefulSetStatus{ReadyReplicas: 2},
+	}
+	cr := enterpriseApi.IndexerCluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}}
+	mgr := &indexerClusterPodManager{cr: &cr}
+
+	err := mgr.markDataRebalancePending(ctx, statefulSet, 3)
+	if err != nil || isDataRebalanceActive(&cr) {
+		t.Errorf("data rebalance should not be pending, when disabled. error: %v", err)
+	}
+
+	cr.Spec.RebalanceOnScaleUp.Enabled = true
+	err = mgr.markDataRebalancePending(ctx, statefulSet, 2)
+	if err != nil || isDataRebalanceActive(&cr) {
+		t.Errorf("data rebalance should not be pending, without a scale up. error: %v", err)
+	}
+
+	statefulSet.Status.ReadyReplicas = 0
+	err = mgr.markDataRebalancePending(ctx, statefulSet, 3)
+	if err != nil || isDataRebalanceActive(&cr) {
+		t.Errorf("data rebalance should not be pending, for the initial peers. error: %v", err)
+	}
+
+	statefulSet.Status.ReadyReplicas = 2
+	err = mgr.markDataRebalancePending(ctx, statefulSet, 3)
+	if err != nil || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStatePending || cr.Status.RebalanceStatus.Replicas != 3 {
+		t.Errorf("data rebalance should be pending after a scale up. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+
+	// data rebalance in progress is stopped, when scaled up again
+	savedStopCall := StopDataRebalanceCall
+	defer func() { StopDataRebalanceCall = savedStopCall }()
+	stopCalls := 0
+	StopDataRebalanceCall = func(ctx context.Context, mgr *indexerClusterPodManager) error {
+		stopCalls++
+		return nil
+	}
+	cr.Status.RebalanceStatus.State = enterpriseApi.RebalanceStateInProgress
+	err = mgr.markDataRebalancePending(ctx, statefulSet, 4)
+	if err != nil || stopCalls != 1 || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStatePending || cr.Status.RebalanceStatus.Replicas != 4 {
+		t.Errorf("data rebalance should be stopped and pending. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+}
+
+func TestApplyDataRebalance(t *testing.T) {
+	ctx := context.TODO()
+	savedStartCall := StartDataRebalanceCall
+	savedStopCall := StopDataRebalanceCall
+	savedStatusCall := GetDataRebalanceStatusCall
+	savedInfoCall := GetClusterManagerInfoCall
+	defer func() {
+		StartDataRebalanceCall = savedStartCall
+		StopDataRebalanceCall = savedStopCall
+		GetDataRebalanceStatusCall = savedStatusCall
+		GetClusterManagerInfoCall = savedInfoCall
+	}()
+
+	var startCalls, stopCalls int
+	var maxRuntime int64
+	StartDataRebalanceCall = func(ctx context.Context, mgr *indexerClusterPodManager, maxRuntimeMinutes int64) error {
+		startCalls++
+		maxRuntime = maxRuntimeMinutes
+		return nil
+	}
+	StopDataRebalanceCall = func(ctx context.Context, mgr *indexerClusterPodManager) error {
+		stopCalls++
+		return nil
+	}
+	rebalanceStatus := splclient.DataRebalanceStatus{Running: true, Progress: 42.5, Message: "Data rebalance is running"}
+	GetDataRebalanceStatusCall = func(ctx context.Context, mgr *indexerClusterPodManager) (*splclient.DataRebalanceStatus, error) {
+		return &rebalanceStatus, nil
+	}
+	clusterInfo := splclient.ClusterManagerInfo{}
+	GetClusterManagerInfoCall = func(ctx context.Context, mgr *indexerClusterPodManager) (*splclient.ClusterManagerInfo, error) {
+		return &clusterInfo, nil
+	}
+
+	cr := enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: enterpriseApi.IndexerClusterSpec{
+			Replicas:           3,
+			RebalanceOnScaleUp: enterpriseApi.RebalanceOnScaleUpSpec{Enabled: true, MaxRuntimeMinutes: 60},
+		},
+	}
+	cr.Status.RebalanceStatus = enterpriseApi.IndexerClusterRebalanceStatus{State: enterpriseApi.RebalanceStatePending, Replicas: 3}
+	for n := 0; n < 3; n++ {
+		cr.Status.Peers = append(cr.Status.Peers, enterpriseApi.IndexerClusterMemberStatus{Name: fmt.Sprintf("splunk-test-indexer-%d", n), Status: "Up"})
+	}
+	cr.Status.Peers[2].Status = "Down"
+	mgr := &indexerClusterPodManager{cr: &cr}
+
+	// waits for the new peers to be up
+	err := mgr.applyDataRebalance(ctx)
+	if err != nil || startCalls != 0 || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStatePending {
+		t.Errorf("data rebalance should wait for the new peers. error: %v", err)
+	}
+
+	// never starts during maintenance mode or a bundle push
+	cr.Status.Peers[2].Status = "Up"
+	clusterInfo.MaintenanceMode = true
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || startCalls != 0 {
+		t.Errorf("data rebalance should not start during maintenance mode. error: %v", err)
+	}
+	clusterInfo.MaintenanceMode = false
+	clusterInfo.LatestBundle.Checksum = "new"
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || startCalls != 0 {
+		t.Errorf("data rebalance should not start during a bundle push. error: %v", err)
+	}
+
+	clusterInfo.ActiveBundle.Checksum = "new"
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || startCalls != 1 || maxRuntime != 60 || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStateInProgress {
+		t.Errorf("data rebalance should be started. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+
+	// progress is polled into the status
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || cr.Status.RebalanceStatus.Progress != "42.50" {
+		t.Errorf("data rebalance progress should be updated. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+
+	// stopped when a bundle push starts, and started again afterwards
+	clusterInfo.LatestBundle.Checksum = "newer"
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || stopCalls != 1 || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStatePending {
+		t.Errorf("data rebalance should be stopped during a bundle push. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+	clusterInfo.ActiveBundle.Checksum = "newer"
+	startTime := time.Now().Add(-20 * time.Minute).Unix()
+	cr.Status.RebalanceStatus.StartTime = startTime
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || startCalls != 2 || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStateInProgress {
+		t.Errorf("data rebalance should be started again. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+	if cr.Status.RebalanceStatus.StartTime != startTime || maxRuntime != 40 {
+		t.Errorf("data rebalance started again should keep the original start time and the remaining runtime. got: %v, maxRuntime: %d", cr.Status.RebalanceStatus, maxRuntime)
+	}
+
+	// stopped after the maximum runtime
+	cr.Status.RebalanceStatus.StartTime = time.Now().Add(-61 * time.Minute).Unix()
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || stopCalls != 2 || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStateStopped || isDataRebalanceActive(&cr) {
+		t.Errorf("data rebalance should be stopped after the maximum runtime. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+
+	// not started again, once the maximum runtime is reached while stopped
+	cr.Status.RebalanceStatus = enterpriseApi.IndexerClusterRebalanceStatus{State: enterpriseApi.RebalanceStatePending, Replicas: 3, StartTime: time.Now().Add(-61 * time.Minute).Unix()}
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || startCalls != 2 || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStateStopped {
+		t.Errorf("data rebalance should not be started again after the maximum runtime. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+
+	// complete, once not running on the cluster manager
+	cr.Status.RebalanceStatus = enterpriseApi.IndexerClusterRebalanceStatus{State: enterpriseApi.RebalanceStateInProgress, Replicas: 3, StartTime: time.Now().Unix()}
+	rebalanceStatus = splclient.DataRebalanceStatus{Message: "Data rebalance is not running"}
+	err = mgr.applyDataRebalance(ctx)
+	if err != nil || cr.Status.RebalanceStatus.State != enterpriseApi.RebalanceStateComplete || cr.Status.RebalanceStatus.EndTime == 0 {
+		t.Errorf("data rebalance should be complete. got: %v, error: %v", cr.Status.RebalanceStatus, err)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// With the searchableRollingRestart update strategy, the pending updates of the indexer pods that only change the pod
+// template annotations applied by a restart of splunkd, i.e. the restart annotation of the IndexerCluster, are applied
+// with a searchable rolling restart by the cluster manager. Once a peer is back up after its restart, its pod is marked
+// as updated, i.e. the pod gets the annotations and the revision of the StatefulSet template, so that
+// UpdateStatefulSetPods doesn't recycle it. Any other change to the pod template, including the revisions of the config
+// maps applied by splunk-ansible when the container starts, e.g. defaultConfigRev, still recycles the pods one at a time.
+
+// podRevisionLabel is the label of the StatefulSet revision a pod is created from
+const podRevisionLabel = "controller-revision-hash"
+
+// restartAppliedAnnotations are the pod template annotations whose changes are applied by a restart of splunkd
+var restartAppliedAnnotations = map[string]bool{
+	enterpriseApi.IndexerClusterRestartAnnotation: true,
+}
+
+// SearchableRollingRestartCall function pointer to mock
+var SearchableRollingRestartCall = func(ctx context.Context, mgr *indexerClusterPodManager) error {
+	c := mgr.getClusterManagerClient(ctx)
+	return c.SearchableRollingRestart()
+}
+
+// GetClusterManagerStatusCall function pointer to mock
+var GetClusterManagerStatusCall = func(ctx context.Context, mgr *indexerClusterPodManager) (*splclient.ClusterManagerStatus, error) {
+	c := mgr.getClusterManagerClient(ctx)
+	return c.GetClusterManagerStatus()
+}
+
+// isSearchableRollingRestartEnabled checks if the indexer pods are updated with a searchable rolling restart,
+// or if a searchable rolling restart started earlier is still being tracked
+func (mgr *indexerClusterPodManager) isSearchableRollingRestartEnabled() bool {
+	return mgr.cr.Spec.UpdateStrategy == enterpriseApi.IndexerClusterUpdateStrategySearchableRollingRestart ||
+		mgr.cr.Status.RollingRestartStatus.Revision != ""
+}
+
+// applySearchableRollingRestart starts, or tracks the progress of a searchable rolling restart of the peers, when the
+// pending updates of the indexer pods only need a restart. It returns true, while the rolling restart is in progress
+func (mgr *indexerClusterPodManager) applySearchableRollingRestart(ctx context.Context, statefulSet *appsv1.StatefulSet, desiredReplicas int32) (bool, enterpriseApi.Phase, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applySearchableRollingRestart").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	if mgr.cr.Status.RollingRestartStatus.Revision != "" {
+		return mgr.checkSearchableRollingRestart(ctx, statefulSet)
+	}
+
+	// scaling and pods that are not ready are handled by UpdateStatefulSetPods
+	replicas := *statefulSet.Spec.Replicas
+	if statefulSet.Status.UpdateRevision == "" || statefulSet.Status.ReadyReplicas != replicas || replicas != desiredReplicas {
+		return false, enterpriseApi.PhaseReady, nil
+	}
+
+	pods, err := mgr.getPodsPendingUpdate(ctx, statefulSet)
+	if err != nil || len(pods) == 0 {
+		return false, enterpriseApi.PhaseReady, err
+	}
+
+	restartOnly, err := isRestartOnlyUpdate(ctx, mgr.c, statefulSet, pods)
+	if err != nil {
+		return false, enterpriseApi.PhaseError, err
+	}
+	if !restartOnly {
+		scopedLog.Info("Pod template changes need the indexer pods to be recycled")
+		return false, enterpriseApi.PhaseReady, nil
+	}
+
+	for _, peer := range mgr.cr.Status.Peers {
+		if peer.Status != "Up" {
+			scopedLog.Info("Waiting for the peers to be up, to start the searchable rolling restart", "peerName", peer.Name, "status", peer.Status)
+			return true, enterpriseApi.PhaseUpdating, nil
+		}
+	}
+
+	err = SearchableRollingRestartCall(ctx, mgr)
+	if err != nil {
+		scopedLog.Error(err, "Unable to start the searchable rolling restart")
+		return true, enterpriseApi.PhaseError, err
+	}
+
+	scopedLog.Info("Started the searchable rolling restart of the peers", "revision", statefulSet.Status.UpdateRevision, "pods", len(pods))
+	mgr.cr.Status.RollingRestartStatus = enterpriseApi.IndexerClusterRollingRestartStatus{
+		Revision:  statefulSet.Status.UpdateRevision,
+		StartTime: time.Now().Unix(),
+	}
+
+	return true, enterpriseApi.PhaseUpdating, nil
+}
+
+// checkSearchableRollingRestart tracks the progress of the searchable rolling restart on the cluster manager, and marks the
+// pods of the restarted peers as updated, as they come back up. It returns true, while the rolling restart is in progress
+func (mgr *indexerClusterPodManager) checkSearchableRollingRestart(ctx context.Context, statefulSet *appsv1.StatefulSet) (bool, enterpriseApi.Phase, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("checkSearchableRollingRestart").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	restartStatus := &mgr.cr.Status.RollingRestartStatus
+	cmStatus, err := GetClusterManagerStatusCall(ctx, mgr)
+	if err != nil {
+		scopedLog.Error(err, "Unable to get the status of the searchable rolling restart")
+		return true, enterpriseApi.PhaseError, err
+	}
+
+	for _, peerName := range cmStatus.RestartProgress.Done {
+		if !isPeerRestarted(restartStatus, peerName) {
+			scopedLog.Info("Peer restarted", "peerName", peerName)
+			restartStatus.RestartedPeers = append(restartStatus.RestartedPeers, peerName)
+		}
+	}
+
+	// the pods are not marked as updated, if the pod template changed again after the rolling restart was started
+	if restartStatus.Revision == statefulSet.Status.UpdateRevision {
+		for _, peer := range mgr.cr.Status.Peers {
+			if peer.Status != "Up" || !isPeerRestarted(restartStatus, peer.Name) {
+				continue
+			}
+			err = markPodUpdated(ctx, mgr.c, statefulSet, peer.Name)
+			if err != nil {
+				scopedLog.Error(err, "Unable to mark the pod as updated", "podName", peer.Name)
+				return true, enterpriseApi.PhaseError, err
+			}
+		}
+	}
+
+	if cmStatus.RollingRestartOrUpgrade {
+		scopedLog.Info("Waiting for the searchable rolling restart to complete", "done", len(cmStatus.RestartProgress.Done),
+			"inProgress", cmStatus.RestartProgress.InProgress, "toBeRestarted", len(cmStatus.RestartProgress.ToBeRestarted))
+		return true, enterpriseApi.PhaseUpdating, nil
+	}
+
+	// pods of the peers that failed to restart are recycled by UpdateStatefulSetPods
+	if len(cmStatus.RestartProgress.Failed) > 0 {
+		scopedLog.Info("Peers failed to restart", "failed", cmStatus.RestartProgress.Failed)
+	}
+	scopedLog.Info("Searchable rolling restart complete", "restartedPeers", len(restartStatus.RestartedPeers),
+		"duration", time.Since(time.Unix(restartStatus.StartTime, 0)).Round(time.Second).String())
+	*restartStatus = enterpriseApi.IndexerClusterRollingRestartStatus{}
+
+	return false, enterpriseApi.PhaseUpdating, nil
+}
+
+// isPeerRestarted checks if the peer is restarted by the searchable rolling restart
+func isPeerRestarted(restartStatus *enterpriseApi.IndexerClusterRollingRestartStatus, peerName string) bool {
+	for _, restartedPeer := range restartStatus.RestartedPeers {
+		if restartedPeer == peerName {
+			return true
+		}
+	}
+	return false
+}
+
+// getPodsPendingUpdate returns the indexer pods that are not created from the latest revision of the StatefulSet
+func (mgr *indexerClusterPodManager) getPodsPendingUpdate(ctx context.Context, statefulSet *appsv1.StatefulSet) ([]*corev1.Pod, error) {
+	var pods []*corev1.Pod
+	for n := int32(0); n < *statefulSet.Spec.Replicas; n++ {
+		podName := fmt.Sprintf("%s-%d", statefulSet.GetName(), n)
+		namespacedName := types.NamespacedName{Namespace: statefulSet.GetNamespace(), Name: podName}
+		var pod corev1.Pod
+		err := mgr.c.Get(ctx, namespacedName, &pod)
+		if err != nil {
+			return nil, err
+		}
+		if pod.GetLabels()[podRevisionLabel] != statefulSet.Status.UpdateRevision {
+			pods = append(pods, &pod)
+		}
+	}
+	return pods, nil
+}
+
+// getRevisionPodTemplate returns the pod template of the StatefulSet revision
+func getRevisionPodTemplate(ctx context.Context, c splcommon.ControllerClient, namespace string, revisionName string) (*corev1.PodTemplateSpec, error) {
+	var revision appsv1.ControllerRevision
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: revisionName}, &revision)
+	if err != nil {
+		return nil, err
+	}
+
+	// revision data is a patch that replaces the pod template of the StatefulSet
+	revisionData := struct {
+		Spec struct {
+			Template corev1.PodTemplateSpec `json:"template"`
+		} `json:"spec"`
+	}{}
+	err = json.Unmarshal(revision.Data.Raw, &revisionData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the StatefulSet revision %s, error: %v", revisionName, err)
+	}
+
+	return &revisionData.Spec.Template, nil
+}
+
+// isRestartOnlyChange checks if a pod template only differs from the StatefulSet template in the annotations applied by a
+// restart of splunkd
+func isRestartOnlyChange(podTemplate *corev1.PodTemplateSpec, template *corev1.PodTemplateSpec) bool {
+	if !equality.Semantic.DeepEqual(podTemplate.Spec, template.Spec) ||
+		!equality.Semantic.DeepEqual(podTemplate.GetLabels(), template.GetLabels()) {
+		return false
+	}
+	for key, value := range template.GetAnnotations() {
+		if podTemplate.GetAnnotations()[key] != value && !restartAppliedAnnotations[key] {
+			return false
+		}
+	}
+	for key := range podTemplate.GetAnnotations() {
+		if _, ok := template.GetAnnotations()[key]; !ok && !restartAppliedAnnotations[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// isRestartOnlyUpdate checks if the pods only differ from the StatefulSet template in the annotations applied by a
+// restart of splunkd
+func isRestartOnlyUpdate(ctx context.Context, c splcommon.ControllerClient, statefulSet *appsv1.StatefulSet, pods []*corev1.Pod) (bool, error) {
+	for _, pod := range pods {
+		podTemplate, err := getRevisionPodTemplate(ctx, c, pod.GetNamespace(), pod.GetLabels()[podRevisionLabel])
+		if err != nil {
+			return false, err
+		}
+		if !isRestartOnlyChange(podTemplate, &statefulSet.Spec.Template) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// markPodUpdated updates the annotations and the revision of the pod to the ones of the StatefulSet template, once the
+// peer is restarted, so that the pod is not recycled for the updates. A pod whose revision differs from the StatefulSet
+// template in anything a restart doesn't apply is left to be recycled
+func markPodUpdated(ctx context.Context, c splcommon.ControllerClient, statefulSet *appsv1.StatefulSet, podName string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("markPodUpdated").WithValues("podName", podName, "namespace", statefulSet.GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: statefulSet.GetNamespace(), Name: podName}
+	var pod corev1.Pod
+	err := c.Get(ctx, namespacedName, &pod)
+	if err != nil {
+		return err
+	}
+	if pod.GetLabels()[podRevisionLabel] == statefulSet.Status.UpdateRevision {
+		return nil
+	}
+
+	podTemplate, err := getRevisionPodTemplate(ctx, c, pod.GetNamespace(), pod.GetLabels()[podRevisionLabel])
+	if err != nil {
+		return err
+	}
+	if !isRestartOnlyChange(podTemplate, &statefulSet.Spec.Template) {
+		scopedLog.Info("Pod template changes are not applied by the restart, the pod is left to be recycled")
+		return nil
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	for key, value := range statefulSet.Spec.Template.GetAnnotations() {
+		pod.Annotations[key] = value
+	}
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
+	}
+	pod.Labels[podRevisionLabel] = statefulSet.Status.UpdateRevision
+
+	return splutil.UpdateResource(ctx, c, &pod)
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// getRollingRestartTestObjects returns an indexer StatefulSet whose pods are created from the revision rev1, while the
+// StatefulSet is at the revision rev2. The pod templates of the revisions differ in the given image and annotation
+func getRollingRestartTestObjects(t *testing.T, replicas int32, rev1Image string) (*appsv1.StatefulSet, []client.Object) {
+	podTemplate := func(image string, configRev string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:      map[string]string{"app.kubernetes.io/name": "indexer"},
+				Annotations: map[string]string{enterpriseApi.IndexerClusterRestartAnnotation: configRev},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "splunk", Image: image}},
+			},
+		}
+	}
+
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-test-indexer", Namespace: "test"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Template: podTemplate("splunk/splunk:latest", "2"),
+		},
+		Status: appsv1.StatefulSetStatus{
+			Replicas:       replicas,
+			ReadyReplicas:  replicas,
+			UpdateRevision: "splunk-test-indexer-rev2",
+		},
+	}
+
+	var objects []client.Object
+	revisions := map[string]corev1.PodTemplateSpec{
+		"splunk-test-indexer-rev1": podTemplate(rev1Image, "1"),
+		"splunk-test-indexer-rev2": statefulSet.Spec.Template,
+	}
+	for name, template := range revisions {
+		revisionData := map[string]interface{}{"spec": map[string]interface{}{"template": template}}
+		data, err := json.Marshal(revisionData)
+		if err != nil {
+			t.Fatalf("unable to marshal the revision data. error: %v", err)
+		}
+		objects = append(objects, &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+			Data:       runtime.RawExtension{Raw: data},
+		})
+	}
+
+	for n := int32(0); n < replicas; n++ {
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("splunk-test-indexer-%d", n),
+				Namespace:   "test",
+				Labels:      map[string]string{podRevisionLabel: "splunk-test-indexer-rev1"},
+				Annotations: map[string]string{enterpriseApi.IndexerClusterRestartAnnotation: "1"},
+			},
+		})
+	}
+
+	return statefulSet, objects
+}
+
+func getRollingRestartTestPodManager(objects []client.Object, replicas int32) *indexerClusterPodManager {
+	cr := enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: enterpriseApi.IndexerClusterSpec{
+			Replicas:       replicas,
+			UpdateStrategy: enterpriseApi.IndexerClusterUpdateStrategySearchableRollingRestart,
+		},
+	}
+	for n := int32(0); n < replicas; n++ {
+		cr.Status.Peers = append(cr.Status.Peers, enterpriseApi.IndexerClusterMemberStatus{
+			Name:   fmt.Sprintf("splunk-test-indexer-%d", n),
+			Status: "Up",
+		})
+	}
+
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objects...).Build()
+	return &indexerClusterPodManager{c: c, cr: &cr}
+}
+
+func getRollingRestartTestPodRevision(t *testing.T, mgr *indexerClusterPodManager, podName string) string {
+	var pod corev1.Pod
+	err := mgr.c.Get(context.TODO(), types.NamespacedName{Namespace: "test", Name: podName}, &pod)
+	if err != nil {
+		t.Fatalf("unable to get the pod %s. error: %v", podName, err)
+	}
+	return pod.GetLabels()[podRevisionLabel]
+}
+
+func TestIsRestartOnlyUpdate(t *testing.T) {
+	ctx := context.TODO()
+
+	statefulSet, objects := getRollingRestartTestObjects(t, 1, "splunk/splunk:latest")
+	mgr := getRollingRestartTestPodManager(objects, 1)
+	pods, err := mgr.getPodsPendingUpdate(ctx, statefulSet)
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("pod should be pending update. got: %d, error: %v", len(pods), err)
+	}
+	restartOnly, err := isRestartOnlyUpdate(ctx, mgr.c, statefulSet, pods)
+	if err != nil || !restartOnly {
+		t.Errorf("change in the restart annotation should only need a restart. error: %v", err)
+	}
+
+	// defaults are applied by splunk-ansible when the container starts
+	statefulSet.Spec.Template.Annotations["defaultConfigRev"] = "2"
+	restartOnly, err = isRestartOnlyUpdate(ctx, mgr.c, statefulSet, pods)
+	if err != nil || restartOnly {
+		t.Errorf("change in the defaults should need the pods to be recycled. error: %v", err)
+	}
+	err = markPodUpdated(ctx, mgr.c, statefulSet, "splunk-test-indexer-0")
+	if got := getRollingRestartTestPodRevision(t, mgr, "splunk-test-indexer-0"); err != nil || got != "splunk-test-indexer-rev1" {
+		t.Errorf("pod should not be marked as updated, when the changes are not applied by the restart. got: %s, error: %v", got, err)
+	}
+
+	statefulSet, objects = getRollingRestartTestObjects(t, 1, "splunk/splunk:9.0.0")
+	mgr = getRollingRestartTestPodManager(objects, 1)
+	pods, _ = mgr.getPodsPendingUpdate(ctx, statefulSet)
+	restartOnly, err = isRestartOnlyUpdate(ctx, mgr.c, statefulSet, pods)
+	if err != nil || restartOnly {
+		t.Errorf("change in the image should need the pods to be recycled. error: %v", err)
+	}
+}
+
+func TestApplySearchableRollingRestart(t *testing.T) {
+	ctx := context.TODO()
+	savedRestartCall := SearchableRollingRestartCall
+	savedStatusCall := GetClusterManagerStatusCall
+	defer func() {
+		SearchableRollingRestartCall = savedRestartCall
+		GetClusterManagerStatusCall = savedStatusCall
+	}()
+
+	restartCalls := 0
+	SearchableRollingRestartCall = func(ctx context.Context, mgr *indexerClusterPodManager) error {
+		restartCalls++
+		return nil
+	}
+	cmStatus := splclient.ClusterManagerStatus{}
+	GetClusterManagerStatusCall = func(ctx context.Context, mgr *indexerClusterPodManager) (*splclient.ClusterManagerStatus, error) {
+		return &cmStatus, nil
+	}
+
+	// pods are recycled, when the change needs it
+	statefulSet, objects := getRollingRestartTestObjects(t, 2, "splunk/splunk:9.0.0")
+	mgr := getRollingRestartTestPodManager(objects, 2)
+	inProgress, _, err := mgr.applySearchableRollingRestart(ctx, statefulSet, 2)
+	if err != nil || inProgress || restartCalls != 0 {
+		t.Errorf("rolling restart should not be started for the changes that need the pods to be recycled. error: %v", err)
+	}
+
+	// rolling restart is started for the restart only changes
+	statefulSet, objects = getRollingRestartTestObjects(t, 2, "splunk/splunk:latest")
+	mgr = getRollingRestartTestPodManager(objects, 2)
+	inProgress, phase, err := mgr.applySearchableRollingRestart(ctx, statefulSet, 2)
+	if err != nil || !inProgress || phase != enterpriseApi.PhaseUpdating || restartCalls != 1 {
+		t.Errorf("rolling restart should be started. phase: %v, error: %v", phase, err)
+	}
+	if mgr.cr.Status.RollingRestartStatus.Revision != "splunk-test-indexer-rev2" {
+		t.Errorf("rolling restart should be tracked. got: %v", mgr.cr.Status.RollingRestartStatus)
+	}
+
+	// pods of the restarted peers are updated as the peers come back up
+	cmStatus.RollingRestartOrUpgrade = true
+	cmStatus.RestartProgress.Done = []string{"splunk-test-indexer-0", "splunk-test-indexer-1"}
+	mgr.cr.Status.Peers[1].Status = "Restarting"
+	inProgress, _, err = mgr.applySearchableRollingRestart(ctx, statefulSet, 2)
+	if err != nil || !inProgress || restartCalls != 1 {
+		t.Errorf("rolling restart should be in progress. error: %v", err)
+	}
+	if got := getRollingRestartTestPodRevision(t, mgr, "splunk-test-indexer-0"); got != "splunk-test-indexer-rev2" {
+		t.Errorf("pod of the restarted peer should be updated. got: %s", got)
+	}
+	if got := getRollingRestartTestPodRevision(t, mgr, "splunk-test-indexer-1"); got != "splunk-test-indexer-rev1" {
+		t.Errorf("pod of the peer that is not up should not be updated. got: %s", got)
+	}
+
+	// rolling restart is complete
+	cmStatus.RollingRestartOrUpgrade = false
+	mgr.cr.Status.Peers[1].Status = "Up"
+	inProgress, _, err = mgr.applySearchableRollingRestart(ctx, statefulSet, 2)
+	if err != nil || inProgress {
+		t.Errorf("rolling restart should be complete. error: %v", err)
+	}
+	if got := getRollingRestartTestPodRevision(t, mgr, "splunk-test-indexer-1"); got != "splunk-test-indexer-rev2" {
+		t.Errorf("pod of the restarted peer should be updated. got: %s", got)
+	}
+	if mgr.cr.Status.RollingRestartStatus.Revision != "" {
+		t.Errorf("rolling restart status should be cleared. got: %v", mgr.cr.Status.RollingRestartStatus)
+	}
+
+	// nothing to do, once all the pods are updated
+	inProgress, _, err = mgr.applySearchableRollingRestart(ctx, statefulSet, 2)
+	if err != nil || inProgress || restartCalls != 1 {
+		t.Errorf("rolling restart should not be started again. error: %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splctrl "github.com/splunk/splunk-operator/pkg/splunk/controller"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// A multisite IndexerCluster lists the Splunk sites of the indexer cluster in its spec, each mapped to a topology zone.
+// Once multisite clustering is configured on the cluster manager, an IndexerCluster is created per site, with a node
+// affinity to the zone of the site. The indexers of each site are then managed the same way as for a multipart
+// IndexerCluster. Scaling down is done one site at a time, so that the copies of the buckets are never removed from
+// more than one site at once.
+
+const (
+	// defaultIndexerClusterZoneLabel is the label of the nodes for their topology zone
+	defaultIndexerClusterZoneLabel = "topology.kubernetes.io/zone"
+
+	// defaultIndexerClusterSiteFactor is the default site_replication_factor and site_search_factor
+	defaultIndexerClusterSiteFactor = "origin:1,total:2"
+
+	// indexerClusterSitePollInterval is the interval the status of the sites is polled at, once they are ready
+	indexerClusterSitePollInterval = 30 * time.Second
+)
+
+// SetMultisiteConfigCall function pointer to mock
+var SetMultisiteConfigCall = func(ctx context.Context, mgr *indexerClusterPodManager, availableSites []string, siteReplicationFactor string, siteSearchFactor string) error {
+	c := mgr.getClusterManagerClient(ctx)
+	err := c.SetMultisiteConfig(availableSites[0], availableSites, siteReplicationFactor, siteSearchFactor)
+	if err != nil {
+		return err
+	}
+	return c.RestartSplunk()
+}
+
+// getIndexerClusterSiteName returns the name of the IndexerCluster of a site
+func getIndexerClusterSiteName(cr *enterpriseApi.IndexerCluster, site string) string {
+	return fmt.Sprintf("%s-%s", cr.GetName(), site)
+}
+
+// parseIndexerClusterSiteFactor parses a site_replication_factor or site_search_factor, e.g. origin:2,site1:1,total:3
+func parseIndexerClusterSiteFactor(factor string) (map[string]int32, error) {
+	values := make(map[string]int32)
+	for _, item := range strings.Split(factor, ",") {
+		kv := strings.Split(strings.TrimSpace(item), ":")
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid site factor %s", factor)
+		}
+		value, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 32)
+		if err != nil || value < 1 {
+			return nil, fmt.Errorf("invalid site factor %s", factor)
+		}
+		values[strings.TrimSpace(kv[0])] = int32(value)
+	}
+	if values["origin"] == 0 || values["total"] == 0 {
+		return nil, fmt.Errorf("site factor %s should set origin and total", factor)
+	}
+	if values["origin"] > values["total"] {
+		return nil, fmt.Errorf("origin of site factor %s should not be greater than total", factor)
+	}
+	return values, nil
+}
+
+// validateIndexerClusterSites checks validity and makes default updates to the sites of a multisite IndexerClusterSpec
+func validateIndexerClusterSites(cr *enterpriseApi.IndexerCluster) error {
+	if cr.Spec.ZoneLabel == "" {
+		cr.Spec.ZoneLabel = defaultIndexerClusterZoneLabel
+	}
+	if cr.Spec.SiteReplicationFactor == "" {
+		cr.Spec.SiteReplicationFactor = defaultIndexerClusterSiteFactor
+	}
+	if cr.Spec.SiteSearchFactor == "" {
+		cr.Spec.SiteSearchFactor = defaultIndexerClusterSiteFactor
+	}
+
+	if cr.Spec.UpdateStrategy == enterpriseApi.IndexerClusterUpdateStrategySearchableRollingRestart || cr.Spec.RebalanceOnScaleUp.Enabled {
+		return fmt.Errorf("updateStrategy and rebalanceOnScaleUp are not supported by a multisite IndexerCluster")
+	}
+
+	siteRF, err := parseIndexerClusterSiteFactor(cr.Spec.SiteReplicationFactor)
+	if err != nil {
+		return err
+	}
+	siteSF, err := parseIndexerClusterSiteFactor(cr.Spec.SiteSearchFactor)
+	if err != nil {
+		return err
+	}
+	for k, v := range siteSF {
+		if v > siteRF[k] {
+			return fmt.Errorf("site_search_factor %s should not be greater than site_replication_factor %s", cr.Spec.SiteSearchFactor, cr.Spec.SiteReplicationFactor)
+		}
+	}
+
+	sites := make(map[string]enterpriseApi.IndexerClusterSiteSpec)
+	var replicas int32
+	for _, site := range cr.Spec.Sites {
+		if _, ok := sites[site.Name]; ok {
+			return fmt.Errorf("site %s is listed more than once", site.Name)
+		}
+		if site.Zone == "" {
+			return fmt.Errorf("site %s should be mapped to a zone", site.Name)
+		}
+		if site.Replicas < siteRF["origin"] || site.Replicas < siteRF[site.Name] {
+			return fmt.Errorf("site %s should have enough replicas for the site_replication_factor %s", site.Name, cr.Spec.SiteReplicationFactor)
+		}
+		sites[site.Name] = site
+		replicas += site.Replicas
+	}
+	if replicas < siteRF["total"] {
+		return fmt.Errorf("sites should have enough replicas for the site_replication_factor %s", cr.Spec.SiteReplicationFactor)
+	}
+	for k := range siteRF {
+		if _, ok := sites[k]; !ok && k != "origin" && k != "total" {
+			return fmt.Errorf("site_replication_factor %s refers to unknown site %s", cr.Spec.SiteReplicationFactor, k)
+		}
+	}
+
+	// the buckets of a site can't be moved to another zone
+	for _, siteStatus := range cr.Status.Sites {
+		site, ok := sites[siteStatus.Name]
+		if !ok {
+			return fmt.Errorf("removing site %s from a multisite IndexerCluster is not supported", siteStatus.Name)
+		}
+		if site.Zone != siteStatus.Zone {
+			return fmt.Errorf("changing the zone of site %s is not supported", site.Name)
+		}
+	}
+	return nil
+}
+
+// getIndexerClusterSiteConfig returns the multisite configuration of the cluster manager for the sites
+func getIndexerClusterSiteConfig(cr *enterpriseApi.IndexerCluster) (string, []string) {
+	var availableSites []string
+	for _, site := range cr.Spec.Sites {
+		availableSites = append(availableSites, site.Name)
+	}
+	siteConfig := fmt.Sprintf("available_sites=%s;site_replication_factor=%s;site_search_factor=%s",
+		strings.Join(availableSites, ","), cr.Spec.SiteReplicationFactor, cr.Spec.SiteSearchFactor)
+	return siteConfig, availableSites
+}
+
+// applyMultisiteConfig configures multisite clustering on the cluster manager, and returns true once it is applied
+func (mgr *indexerClusterPodManager) applyMultisiteConfig(ctx context.Context) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyMultisiteConfig").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	siteConfig, availableSites := getIndexerClusterSiteConfig(mgr.cr)
+	if mgr.cr.Status.SiteConfig != siteConfig {
+		scopedLog.Info("Configuring multisite clustering on the cluster manager", "siteConfig", siteConfig)
+		err := SetMultisiteConfigCall(ctx, mgr, availableSites, mgr.cr.Spec.SiteReplicationFactor, mgr.cr.Spec.SiteSearchFactor)
+		if err != nil {
+			scopedLog.Error(err, "Unable to configure multisite clustering on the cluster manager")
+			return false, err
+		}
+		mgr.cr.Status.SiteConfig = siteConfig
+		return false, nil
+	}
+
+	// the cluster manager is restarted to apply the configuration
+	clusterInfo, err := GetClusterInfoCall(ctx, mgr, false)
+	if err != nil {
+		scopedLog.Info("Waiting for the cluster manager to restart", "error", err.Error())
+		return false, nil
+	}
+	if clusterInfo.MultiSite != "true" {
+		scopedLog.Info("Waiting for the cluster manager to enable multisite clustering")
+		return false, nil
+	}
+	return true, nil
+}
+
+// getIndexerClusterSiteSpec returns the IndexerClusterSpec of the IndexerCluster of a site
+func getIndexerClusterSiteSpec(cr *enterpriseApi.IndexerCluster, site enterpriseApi.IndexerClusterSiteSpec, replicas int32) enterpriseApi.IndexerClusterSpec {
+	spec := cr.Spec.DeepCopy()
+	spec.Sites = nil
+	spec.ZoneLabel = ""
+	spec.SiteReplicationFactor = ""
+	spec.SiteSearchFactor = ""
+	spec.Replicas = replicas
+
+	// constrain the indexers of the site to its zone
+	zoneRequirement := corev1.NodeSelectorRequirement{
+		Key:      cr.Spec.ZoneLabel,
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   []string{site.Zone},
+	}
+	if spec.Affinity.NodeAffinity == nil {
+		spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	if spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	nodeSelector := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(nodeSelector.NodeSelectorTerms) == 0 {
+		nodeSelector.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+	for i := range nodeSelector.NodeSelectorTerms {
+		nodeSelector.NodeSelectorTerms[i].MatchExpressions = append(nodeSelector.NodeSelectorTerms[i].MatchExpressions, zoneRequirement)
+	}
+
+	// the site of the indexers, and the cluster manager of the multisite indexer cluster
+	var multisiteMaster string
+	if len(cr.Spec.ClusterManagerRef.Name) > 0 {
+		multisiteMaster = GetSplunkServiceName(SplunkClusterManager, cr.Spec.ClusterManagerRef.Name, false)
+	} else {
+		multisiteMaster = GetSplunkServiceName(SplunkClusterMaster, cr.Spec.ClusterMasterRef.Name, false)
+	}
+	spec.ExtraEnv = append(spec.ExtraEnv,
+		corev1.EnvVar{Name: "SPLUNK_SITE", Value: site.Name},
+		corev1.EnvVar{Name: "SPLUNK_MULTISITE_MASTER", Value: multisiteMaster})
+
+	return *spec
+}
+
+// isIndexerClusterSiteReady checks if the indexers of a site are ready, and have applied the latest replicas
+func isIndexerClusterSiteReady(site *enterpriseApi.IndexerCluster) bool {
+	return site.Status.Phase == enterpriseApi.PhaseReady && site.Status.Replicas == site.Spec.Replicas && site.Status.ReadyReplicas == site.Spec.Replicas
+}
+
+// applyIndexerClusterSite creates or updates the IndexerCluster of a site, and returns true if it was changed
+func applyIndexerClusterSite(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster, site *enterpriseApi.IndexerCluster, spec enterpriseApi.IndexerClusterSpec, siteName string) (bool, error) {
+	if site.GetName() == "" {
+		site.ObjectMeta = metav1.ObjectMeta{
+			Name:       getIndexerClusterSiteName(cr, siteName),
+			Namespace:  cr.GetNamespace(),
+			Labels:     map[string]string{enterpriseApi.IndexerClusterSiteLabel: siteName},
+			Finalizers: cr.GetFinalizers(),
+		}
+		for k, v := range cr.GetLabels() {
+			if _, ok := site.Labels[k]; !ok {
+				site.Labels[k] = v
+			}
+		}
+		site.SetOwnerReferences([]metav1.OwnerReference{splcommon.AsOwner(cr, true)})
+		site.Spec = spec
+		return true, splutil.CreateResource(ctx, client, site)
+	}
+
+	if reflect.DeepEqual(site.Spec, spec) {
+		return false, nil
+	}
+	site.Spec = spec
+	return true, splutil.UpdateResource(ctx, client, site)
+}
+
+// applyIndexerClusterSites reconciles the IndexerCluster of each site of a multisite IndexerCluster
+func (mgr *indexerClusterPodManager) applyIndexerClusterSites(ctx context.Context, client splcommon.ControllerClient, result reconcile.Result) (reconcile.Result, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyIndexerClusterSites").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+	eventPublisher, _ := newK8EventPublisher(client, mgr.cr)
+	cr := mgr.cr
+	mgr.c = client
+
+	// check if deletion has been requested, the IndexerCluster of each site is garbage collected
+	if cr.ObjectMeta.DeletionTimestamp != nil {
+		terminating, err := splctrl.CheckForDeletion(ctx, cr, client)
+		if terminating && err != nil {
+			cr.Status.Phase = enterpriseApi.PhaseTerminating
+		} else {
+			result.Requeue = false
+		}
+		if err != nil {
+			eventPublisher.Warning(ctx, "Delete", fmt.Sprintf("delete custom resource failed %s", err.Error()))
+		}
+		return result, err
+	}
+
+	cr.Status.Phase = enterpriseApi.PhasePending
+	if cr.Status.ClusterManagerPhase != enterpriseApi.PhaseReady && cr.Status.ClusterMasterPhase != enterpriseApi.PhaseReady {
+		scopedLog.Info("Waiting for cluster manager to become ready")
+		return result, nil
+	}
+
+	configured, err := mgr.applyMultisiteConfig(ctx)
+	if err != nil {
+		eventPublisher.Warning(ctx, "applyMultisiteConfig", fmt.Sprintf("configure multisite clustering failed %s", err.Error()))
+		return result, err
+	}
+	if !configured {
+		return result, nil
+	}
+
+	// get the IndexerCluster of each site
+	sites := make([]*enterpriseApi.IndexerCluster, len(cr.Spec.Sites))
+	allReady := true
+	for i, siteSpec := range cr.Spec.Sites {
+		sites[i] = &enterpriseApi.IndexerCluster{}
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: getIndexerClusterSiteName(cr, siteSpec.Name)}
+		err = client.Get(ctx, namespacedName, sites[i])
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return result, err
+		}
+		if err != nil || !isIndexerClusterSiteReady(sites[i]) {
+			allReady = false
+		}
+	}
+
+	// scale down one site at a time, once all the sites are ready
+	scalingDown := false
+	cr.Status.Sites = []enterpriseApi.IndexerClusterSiteStatus{}
+	for i, siteSpec := range cr.Spec.Sites {
+		site := sites[i]
+		replicas := siteSpec.Replicas
+		if site.GetName() != "" && replicas < site.Spec.Replicas {
+			if allReady && !scalingDown {
+				scopedLog.Info("Scaling down site", "site", siteSpec.Name, "replicas", site.Spec.Replicas, "desiredReplicas", replicas)
+				scalingDown = true
+			} else {
+				scopedLog.Info("Waiting for the other sites to be ready, to scale down site", "site", siteSpec.Name)
+				replicas = site.Spec.Replicas
+			}
+		}
+
+		changed, err := applyIndexerClusterSite(ctx, client, cr, site, getIndexerClusterSiteSpec(cr, siteSpec, replicas), siteSpec.Name)
+		if err != nil {
+			eventPublisher.Warning(ctx, "applyIndexerClusterSite", fmt.Sprintf("create/update indexer cluster of site %s failed %s", siteSpec.Name, err.Error()))
+			return result, err
+		}
+
+		siteStatus := enterpriseApi.IndexerClusterSiteStatus{
+			Name:           siteSpec.Name,
+			Zone:           siteSpec.Zone,
+			IndexerCluster: site.GetName(),
+			Phase:          site.Status.Phase,
+			Replicas:       site.Spec.Replicas,
+			ReadyReplicas:  site.Status.ReadyReplicas,
+		}
+		if changed || siteStatus.Phase == "" {
+			siteStatus.Phase = enterpriseApi.PhasePending
+		}
+		cr.Status.Sites = append(cr.Status.Sites, siteStatus)
+	}
+
+	mgr.updateSitesStatus(sites)
+	if cr.Status.Phase == enterpriseApi.PhaseReady {
+		result.RequeueAfter = indexerClusterSitePollInterval
+	}
+	return result, nil
+}
+
+// updateSitesStatus aggregates the status of the IndexerCluster of each site into the status of the multisite IndexerCluster
+func (mgr *indexerClusterPodManager) updateSitesStatus(sites []*enterpriseApi.IndexerCluster) {
+	status := &mgr.cr.Status
+	status.Phase = enterpriseApi.PhaseReady
+	status.Replicas = 0
+	status.ReadyReplicas = 0
+	status.Peers = []enterpriseApi.IndexerClusterMemberStatus{}
+	status.Initialized = true
+	status.IndexingReady = true
+	status.ServiceReady = true
+	status.MaintenanceMode = false
+
+	for i, site := range sites {
+		siteStatus := status.Sites[i]
+		if status.Phase == enterpriseApi.PhaseReady && (siteStatus.Phase != enterpriseApi.PhaseReady || !isIndexerClusterSiteReady(site)) {
+			status.Phase = siteStatus.Phase
+			if status.Phase == enterpriseApi.PhaseReady {
+				status.Phase = enterpriseApi.PhaseUpdating
+			}
+		}
+		status.Replicas += siteStatus.Replicas
+		status.ReadyReplicas += siteStatus.ReadyReplicas
+		status.Peers = append(status.Peers, site.Status.Peers...)
+		status.Initialized = status.Initialized && site.Status.Initialized
+		status.IndexingReady = status.IndexingReady && site.Status.IndexingReady
+		status.ServiceReady = status.ServiceReady && site.Status.ServiceReady
+		status.MaintenanceMode = status.MaintenanceMode || site.Status.MaintenanceMode
+	}
+}
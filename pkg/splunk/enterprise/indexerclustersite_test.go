@@ -0,0 +1,250 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func getMultisiteIndexerCluster() *enterpriseApi.IndexerCluster {
+	return &enterpriseApi.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "IndexerCluster",
+			APIVersion: "enterprise.splunk.com/v4",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "idxc",
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.IndexerClusterSpec{
+			CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{
+				ClusterManagerRef: corev1.ObjectReference{Name: "cm"},
+			},
+			Sites: []enterpriseApi.IndexerClusterSiteSpec{
+				{Name: "site1", Zone: "zone-a", Replicas: 2},
+				{Name: "site2", Zone: "zone-b", Replicas: 2},
+			},
+		},
+	}
+}
+
+func TestParseIndexerClusterSiteFactor(t *testing.T) {
+	values, err := parseIndexerClusterSiteFactor("origin:2, site1:1, total:3")
+	if err != nil || values["origin"] != 2 || values["site1"] != 1 || values["total"] != 3 {
+		t.Errorf("site factor should be parsed. got: %v, error: %v", values, err)
+	}
+
+	for _, factor := range []string{"", "origin:1", "total:2", "origin:x,total:2", "origin:3,total:2", "origin:0,total:2", "origin,total:2"} {
+		_, err = parseIndexerClusterSiteFactor(factor)
+		if err == nil {
+			t.Errorf("site factor %s should be invalid", factor)
+		}
+	}
+}
+
+func TestValidateIndexerClusterSites(t *testing.T) {
+	cr := getMultisiteIndexerCluster()
+	err := validateIndexerClusterSites(cr)
+	if err != nil {
+		t.Errorf("sites should be valid. error: %v", err)
+	}
+	if cr.Spec.ZoneLabel != defaultIndexerClusterZoneLabel || cr.Spec.SiteReplicationFactor != defaultIndexerClusterSiteFactor || cr.Spec.SiteSearchFactor != defaultIndexerClusterSiteFactor {
+		t.Errorf("defaults should be set. got: %s %s %s", cr.Spec.ZoneLabel, cr.Spec.SiteReplicationFactor, cr.Spec.SiteSearchFactor)
+	}
+
+	invalid := map[string]func(cr *enterpriseApi.IndexerCluster){
+		"duplicate site":        func(cr *enterpriseApi.IndexerCluster) { cr.Spec.Sites[1].Name = "site1" },
+		"missing zone":          func(cr *enterpriseApi.IndexerCluster) { cr.Spec.Sites[1].Zone = "" },
+		"too few site peers":    func(cr *enterpriseApi.IndexerCluster) { cr.Spec.SiteReplicationFactor = "origin:3,total:4" },
+		"too few peers":         func(cr *enterpriseApi.IndexerCluster) { cr.Spec.SiteReplicationFactor = "origin:1,total:5" },
+		"unknown site":          func(cr *enterpriseApi.IndexerCluster) { cr.Spec.SiteReplicationFactor = "origin:1,site3:1,total:2" },
+		"search factor too big": func(cr *enterpriseApi.IndexerCluster) { cr.Spec.SiteSearchFactor = "origin:2,total:3" },
+		"removed site": func(cr *enterpriseApi.IndexerCluster) {
+			cr.Status.Sites = []enterpriseApi.IndexerClusterSiteStatus{{Name: "site3", Zone: "zone-c"}}
+		},
+		"moved site": func(cr *enterpriseApi.IndexerCluster) {
+			cr.Status.Sites = []enterpriseApi.IndexerClusterSiteStatus{{Name: "site1", Zone: "zone-c"}}
+		},
+		"rebalance": func(cr *enterpriseApi.IndexerCluster) { cr.Spec.RebalanceOnScaleUp.Enabled = true },
+	}
+	for name, update := range invalid {
+		cr := getMultisiteIndexerCluster()
+		update(cr)
+		err = validateIndexerClusterSites(cr)
+		if err == nil {
+			t.Errorf("sites should be invalid: %s", name)
+		}
+	}
+}
+
+func TestGetIndexerClusterSiteSpec(t *testing.T) {
+	cr := getMultisiteIndexerCluster()
+	cr.Spec.ZoneLabel = defaultIndexerClusterZoneLabel
+	cr.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"indexers"}}}},
+			},
+		},
+	}
+
+	spec := getIndexerClusterSiteSpec(cr, cr.Spec.Sites[1], 3)
+	if len(spec.Sites) != 0 || spec.Replicas != 3 || spec.ClusterManagerRef.Name != "cm" {
+		t.Errorf("site spec should be derived from the multisite spec. got: %v", spec)
+	}
+
+	expressions := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+	if len(expressions) != 2 || expressions[1].Key != defaultIndexerClusterZoneLabel || expressions[1].Values[0] != "zone-b" {
+		t.Errorf("site spec should have a zone affinity. got: %v", expressions)
+	}
+	if len(cr.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions) != 1 {
+		t.Errorf("multisite spec should not be modified")
+	}
+
+	env := map[string]string{}
+	for _, v := range spec.ExtraEnv {
+		env[v.Name] = v.Value
+	}
+	if env["SPLUNK_SITE"] != "site2" || env["SPLUNK_MULTISITE_MASTER"] != "splunk-cm-cluster-manager-service" {
+		t.Errorf("site spec should set the site of the indexers. got: %v", env)
+	}
+}
+
+func TestApplyIndexerClusterSites(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	savedSetCall := SetMultisiteConfigCall
+	savedInfoCall := GetClusterInfoCall
+	defer func() {
+		SetMultisiteConfigCall = savedSetCall
+		GetClusterInfoCall = savedInfoCall
+	}()
+	setCalls := 0
+	SetMultisiteConfigCall = func(ctx context.Context, mgr *indexerClusterPodManager, availableSites []string, siteReplicationFactor string, siteSearchFactor string) error {
+		setCalls++
+		return nil
+	}
+	clusterInfo := splclient.ClusterInfo{MultiSite: "false"}
+	GetClusterInfoCall = func(ctx context.Context, mgr *indexerClusterPodManager, mockCall bool) (*splclient.ClusterInfo, error) {
+		return &clusterInfo, nil
+	}
+
+	cr := getMultisiteIndexerCluster()
+	err := validateIndexerClusterSites(cr)
+	if err != nil {
+		t.Errorf("sites should be valid. error: %v", err)
+	}
+	mgr := &indexerClusterPodManager{cr: cr}
+	result := reconcile.Result{Requeue: true}
+
+	// waits for the cluster manager
+	_, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || setCalls != 0 || cr.Status.Phase != enterpriseApi.PhasePending {
+		t.Errorf("sites should wait for the cluster manager. error: %v", err)
+	}
+
+	// configures multisite clustering on the cluster manager, and waits for it to restart
+	cr.Status.ClusterManagerPhase = enterpriseApi.PhaseReady
+	_, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || setCalls != 1 || cr.Status.SiteConfig == "" {
+		t.Errorf("multisite clustering should be configured. error: %v", err)
+	}
+	_, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || setCalls != 1 || cr.Status.Phase != enterpriseApi.PhasePending {
+		t.Errorf("sites should wait for multisite clustering. error: %v", err)
+	}
+
+	// creates the IndexerCluster of each site
+	clusterInfo.MultiSite = "true"
+	_, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || len(cr.Status.Sites) != 2 || cr.Status.Replicas != 4 || cr.Status.Phase != enterpriseApi.PhasePending {
+		t.Errorf("sites should be created. got: %v, error: %v", cr.Status, err)
+	}
+	sites := make([]*enterpriseApi.IndexerCluster, 2)
+	for i, name := range []string{"idxc-site1", "idxc-site2"} {
+		sites[i] = &enterpriseApi.IndexerCluster{}
+		err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: name}, sites[i])
+		if err != nil || sites[i].Spec.Replicas != 2 || sites[i].GetLabels()[enterpriseApi.IndexerClusterSiteLabel] != cr.Spec.Sites[i].Name {
+			t.Errorf("site %s should be created. error: %v", name, err)
+		}
+		if getSiteName(ctx, c, sites[i]) != cr.Spec.Sites[i].Name {
+			t.Errorf("site %s should be labelled with its site", name)
+		}
+	}
+
+	// ready, once all the sites are ready
+	setSitesReady := func() {
+		for _, site := range sites {
+			err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: site.GetName()}, site)
+			if err != nil {
+				t.Errorf("site %s should exist. error: %v", site.GetName(), err)
+			}
+			site.Status.Phase = enterpriseApi.PhaseReady
+			site.Status.Replicas = site.Spec.Replicas
+			site.Status.ReadyReplicas = site.Spec.Replicas
+			site.Status.Peers = make([]enterpriseApi.IndexerClusterMemberStatus, site.Spec.Replicas)
+			err = c.Update(ctx, site)
+			if err != nil {
+				t.Errorf("site %s should be updated. error: %v", site.GetName(), err)
+			}
+		}
+	}
+	setSitesReady()
+	result, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhaseReady || cr.Status.ReadyReplicas != 4 || len(cr.Status.Peers) != 4 || result.RequeueAfter != indexerClusterSitePollInterval {
+		t.Errorf("multisite indexer cluster should be ready. got: %v, error: %v", cr.Status, err)
+	}
+
+	// scales down one site at a time
+	cr.Spec.Sites[0].Replicas = 1
+	cr.Spec.Sites[1].Replicas = 1
+	cr.Spec.SiteReplicationFactor = "origin:1,total:2"
+	_, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || cr.Status.Sites[0].Replicas != 1 || cr.Status.Sites[1].Replicas != 2 || cr.Status.Phase == enterpriseApi.PhaseReady {
+		t.Errorf("only the first site should be scaled down. got: %v, error: %v", cr.Status.Sites, err)
+	}
+	_, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || cr.Status.Sites[1].Replicas != 2 {
+		t.Errorf("second site should wait for the first site. got: %v, error: %v", cr.Status.Sites, err)
+	}
+	setSitesReady()
+	_, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || cr.Status.Sites[1].Replicas != 1 {
+		t.Errorf("second site should be scaled down. got: %v, error: %v", cr.Status.Sites, err)
+	}
+
+	// scales up all the sites at once
+	cr.Spec.Sites[0].Replicas = 3
+	cr.Spec.Sites[1].Replicas = 3
+	_, err = mgr.applyIndexerClusterSites(ctx, c, result)
+	if err != nil || cr.Status.Sites[0].Replicas != 3 || cr.Status.Sites[1].Replicas != 3 {
+		t.Errorf("sites should be scaled up. got: %v, error: %v", cr.Status.Sites, err)
+	}
+}
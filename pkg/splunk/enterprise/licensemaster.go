@@ -49,14 +49,14 @@ func ApplyLicenseMaster(ctx context.Context, client splcommon.ControllerClient,
 	eventPublisher, _ := newK8EventPublisher(client, cr)
 
 	// validate and updates defaults for CR
-	err := validateLicenseMasterSpec(ctx, client, cr)
+	appFrameworkConf, err := validateLicenseMasterSpec(ctx, client, cr)
 	if err != nil {
 		scopedLog.Error(err, "Failed to validate license manager spec")
 		return result, err
 	}
 
 	// If needed, Migrate the app framework status
-	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig, true)
+	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf, true)
 	if err != nil {
 		return result, err
 	}
@@ -64,8 +64,8 @@ func ApplyLicenseMaster(ctx context.Context, client splcommon.ControllerClient,
 	// If the app framework is configured then do following things -
 	// 1. Initialize the S3Clients based on providers
 	// 2. Check the status of apps on remote storage.
-	if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
-		err := initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext)
+	if len(appFrameworkConf.AppSources) != 0 {
+		err := initAndCheckAppInfoStatus(ctx, client, cr, appFrameworkConf, &cr.Status.AppContext)
 		if err != nil {
 			eventPublisher.Warning(ctx, "initAndCheckAppInfoStatus", fmt.Sprintf("init and check app info status failed %s", err.Error()))
 			cr.Status.AppContext.IsDeploymentInProgress = false
@@ -98,13 +98,16 @@ func ApplyLicenseMaster(ctx context.Context, client splcommon.ControllerClient,
 		// If this is the last of its kind getting deleted,
 		// remove the entry for this CR type from configMap or else
 		// just decrement the refCount for this CR type.
-		if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
+		if len(appFrameworkConf.AppSources) != 0 {
 			err = UpdateOrRemoveEntryFromConfigMapLocked(ctx, client, cr, SplunkLicenseManager)
 			if err != nil {
 				return result, err
 			}
 		}
 
+		// remove the app packages kept for the rollback
+		removeAppRollbackPkgs(ctx, getAppRollbackDir(cr))
+
 		DeleteOwnerReferencesForResources(ctx, client, cr, nil, SplunkLicenseMaster)
 		terminating, err := splctrl.CheckForDeletion(ctx, cr, client)
 
@@ -171,7 +174,7 @@ func ApplyLicenseMaster(ctx context.Context, client splcommon.ControllerClient,
 			cr.Status.TelAppInstalled = true
 		}
 
-		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig)
+		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf)
 		result = *finalResult
 	}
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
@@ -196,16 +199,27 @@ func getLicenseMasterStatefulSet(ctx context.Context, client splcommon.Controlle
 }
 
 // validateLicenseManagerSpec checks validity and makes default updates to a LicenseMasterSpec, and returns error if something is wrong.
-func validateLicenseMasterSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApiV3.LicenseMaster) error {
+// It returns the App Framework config to deploy the apps with, with the AppSource references resolved.
+func validateLicenseMasterSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApiV3.LicenseMaster) (*enterpriseApi.AppFrameworkSpec, error) {
+
+	appFrameworkConf, err := resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, cr.Spec.AppFrameworkConfig) {
-		err := ValidateAppFrameworkSpec(ctx, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext, true, cr.GetObjectKind().GroupVersionKind().Kind)
+	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, *appFrameworkConf) {
+		err = ValidateAppFrameworkSpec(ctx, appFrameworkConf, &cr.Status.AppContext, true, cr.GetObjectKind().GroupVersionKind().Kind)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	err = validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return appFrameworkConf, nil
 }
 
 // helper function to get the list of LicenseMaster types in the current namespace
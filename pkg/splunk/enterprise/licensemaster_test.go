@@ -166,7 +166,7 @@ func TestGetLicenseMasterStatefulSet(t *testing.T) {
 
 	test := func(want string) {
 		f := func() (interface{}, error) {
-			if err := validateLicenseMasterSpec(ctx, c, &cr); err != nil {
+			if _, err := validateLicenseMasterSpec(ctx, c, &cr); err != nil {
 				t.Errorf("validateLicenseMasterSpec() returned error: %v", err)
 			}
 			return getLicenseMasterStatefulSet(ctx, c, &cr)
@@ -57,7 +57,7 @@ func ApplyMonitoringConsole(ctx context.Context, client splcommon.ControllerClie
 	}
 
 	// validate and updates defaults for CR
-	err := validateMonitoringConsoleSpec(ctx, client, cr)
+	appFrameworkConf, err := validateMonitoringConsoleSpec(ctx, client, cr)
 	if err != nil {
 		return result, err
 	}
@@ -66,7 +66,7 @@ func ApplyMonitoringConsole(ctx context.Context, client splcommon.ControllerClie
 	cr.Status.Phase = enterpriseApi.PhaseError
 
 	// If needed, Migrate the app framework status
-	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig, true)
+	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf, true)
 	if err != nil {
 		return result, err
 	}
@@ -74,8 +74,8 @@ func ApplyMonitoringConsole(ctx context.Context, client splcommon.ControllerClie
 	// If the app framework is configured then do following things -
 	// 1. Initialize the S3Clients based on providers
 	// 2. Check the status of apps on remote storage.
-	if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
-		err := initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext)
+	if len(appFrameworkConf.AppSources) != 0 {
+		err := initAndCheckAppInfoStatus(ctx, client, cr, appFrameworkConf, &cr.Status.AppContext)
 		if err != nil {
 			eventPublisher.Warning(ctx, "initAndCheckAppInfoStatus", fmt.Sprintf("init and check app info status failed %s", err.Error()))
 			cr.Status.AppContext.IsDeploymentInProgress = false
@@ -101,13 +101,16 @@ func ApplyMonitoringConsole(ctx context.Context, client splcommon.ControllerClie
 		// If this is the last of its kind getting deleted,
 		// remove the entry for this CR type from configMap or else
 		// just decrement the refCount for this CR type.
-		if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
+		if len(appFrameworkConf.AppSources) != 0 {
 			err = UpdateOrRemoveEntryFromConfigMapLocked(ctx, client, cr, SplunkLicenseManager)
 			if err != nil {
 				return result, err
 			}
 		}
 
+		// remove the app packages kept for the rollback
+		removeAppRollbackPkgs(ctx, getAppRollbackDir(cr))
+
 		terminating, err := splctrl.CheckForDeletion(ctx, cr, client)
 		if terminating && err != nil { // don't bother if no error, since it will just be removed immmediately after
 			cr.Status.Phase = enterpriseApi.PhaseTerminating
@@ -154,10 +157,13 @@ func ApplyMonitoringConsole(ctx context.Context, client splcommon.ControllerClie
 
 	// no need to requeue if everything is ready
 	if cr.Status.Phase == enterpriseApi.PhaseReady {
-		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig)
+		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf)
 		result = *finalResult
 
 	}
+	// take the snapshot sets of the PVCs on schedule
+	applyVolumeSnapshotPolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, cr.Status.Phase == enterpriseApi.PhaseReady, &result)
+
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
 	if !result.Requeue {
@@ -216,14 +222,26 @@ func getMonitoringConsoleList(ctx context.Context, c splcommon.ControllerClient,
 }
 
 // validateMonitoringConsoleSpec checks validity and makes default updates to a MonitoringConsole, and returns error if something is wrong.
-func validateMonitoringConsoleSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.MonitoringConsole) error {
-	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, cr.Spec.AppFrameworkConfig) {
-		err := ValidateAppFrameworkSpec(ctx, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext, true, cr.GetObjectKind().GroupVersionKind().Kind)
+// It returns the App Framework config to deploy the apps with, with the AppSource references resolved.
+func validateMonitoringConsoleSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.MonitoringConsole) (*enterpriseApi.AppFrameworkSpec, error) {
+	appFrameworkConf, err := resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, *appFrameworkConf) {
+		err = ValidateAppFrameworkSpec(ctx, appFrameworkConf, &cr.Status.AppContext, true, cr.GetObjectKind().GroupVersionKind().Kind)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+
+	err = validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return appFrameworkConf, nil
 }
 
 // ApplyMonitoringConsoleEnvConfigMap creates or updates a Kubernetes ConfigMap for extra env for monitoring console pod
@@ -362,7 +362,7 @@ func TestGetMonitoringConsoleStatefulSet(t *testing.T) {
 
 	test := func(want string) {
 		f := func() (interface{}, error) {
-			if err := validateMonitoringConsoleSpec(ctx, c, &cr); err != nil {
+			if _, err := validateMonitoringConsoleSpec(ctx, c, &cr); err != nil {
 				t.Errorf("validateMonitoringConsoleSpec() returned error: %v", err)
 			}
 			return getMonitoringConsoleStatefulSet(ctx, c, &cr)
@@ -44,6 +44,9 @@ const (
 	// identifier
 	smartstoreTemplateStr = "splunk-%s-%s-smartstore"
 
+	// identifier, instanceType
+	redundancyTemplateStr = "splunk-%s-%s-redundancy"
+
 	// identifier
 	probeConfigMapTemplateStr = "splunk-%s-probe-configmap"
 
@@ -200,6 +203,35 @@ version = 1.0.0
 
 	// Command to reload app configuration
 	telAppReloadString = "curl -k -u admin:`cat /mnt/splunk-secrets/password` https://localhost:8089/services/apps/local/_reload"
+
+	// Directory on the pod where the KV store backups are written, and restored from
+	kvStoreBackupDirOnPod = "/opt/splunk/var/lib/splunk/kvstorebackup"
+
+	// Command to back up the KV store into an archive under kvStoreBackupDirOnPod
+	kvStoreBackupCmdStr = "/opt/splunk/bin/splunk backup kvstore -archiveName %s -auth admin:`cat /mnt/splunk-secrets/password`"
+
+	// Command to restore the KV store from an archive under kvStoreBackupDirOnPod
+	kvStoreRestoreCmdStr = "/opt/splunk/bin/splunk restore kvstore -archiveName %s -auth admin:`cat /mnt/splunk-secrets/password`"
+
+	// Command checking that no KV store backup or restore is running
+	kvStoreBackupRestoreReadyCmdStr = "/opt/splunk/bin/splunk show kvstore-status -auth admin:`cat /mnt/splunk-secrets/password` 2>/dev/null | grep -q -E '^[[:space:]]*backupRestoreStatus[[:space:]]*:[[:space:]]*Ready'"
+
+	// Commands to put the KV store of a search head cluster in maintenance mode, and back
+	kvStoreEnableMaintenanceCmdStr  = "/opt/splunk/bin/splunk enable kvstore-maintenance-mode -auth admin:`cat /mnt/splunk-secrets/password`"
+	kvStoreDisableMaintenanceCmdStr = "/opt/splunk/bin/splunk disable kvstore-maintenance-mode -auth admin:`cat /mnt/splunk-secrets/password`"
+
+	// Directory on the pod where the etc snapshots are written, and restored from
+	etcBackupDirOnPod = "/opt/splunk/var/lib/splunk/etcbackup"
+
+	// Command to archive the directories under /opt/splunk into an etc snapshot. tar exits with 1 when a file changed while being read
+	etcBackupCmdStr = "mkdir -p " + etcBackupDirOnPod + " && { tar -czf %s -C /opt/splunk %s || [ $? -eq 1 ]; }"
+
+	// Command to extract an etc snapshot under /opt/splunk, and restart splunk for the configuration to take effect
+	etcRestoreCmdStr = "tar -xzf %s -C /opt/splunk && /opt/splunk/bin/splunk restart --answer-yes --no-prompt"
+
+	// Commands to put the indexer cluster in maintenance mode, and back
+	idxcEnableMaintenanceCmdStr  = "/opt/splunk/bin/splunk enable maintenance-mode --answer-yes -auth admin:`cat /mnt/splunk-secrets/password`"
+	idxcDisableMaintenanceCmdStr = "/opt/splunk/bin/splunk disable maintenance-mode -auth admin:`cat /mnt/splunk-secrets/password`"
 )
 
 const (
@@ -240,6 +272,11 @@ func GetSplunkDefaultsName(identifier string, instanceType InstanceType) string
 	return fmt.Sprintf(defaultsTemplateStr, identifier, instanceType.ToKind())
 }
 
+// GetSplunkRedundancyConfigMapName uses a template to name the Kubernetes ConfigMap with the redundancy defaults of a SplunkEnterprise resource.
+func GetSplunkRedundancyConfigMapName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(redundancyTemplateStr, identifier, instanceType.ToString())
+}
+
 // GetSplunkMonitoringconsoleConfigMapName uses a template to name a Kubernetes ConfigMap for a SplunkEnterprise resource.
 func GetSplunkMonitoringconsoleConfigMapName(identifier string, instanceType InstanceType) string {
 	return fmt.Sprintf(statefulSetTemplateStr, identifier, instanceType.ToKind())
@@ -78,6 +78,14 @@ func TestGetSplunkDefaultsName(t *testing.T) {
 	}
 }
 
+func TestGetSplunkRedundancyConfigMapName(t *testing.T) {
+	got := GetSplunkRedundancyConfigMapName("t1", SplunkClusterManager)
+	want := "splunk-t1-cluster-manager-redundancy"
+	if got != want {
+		t.Errorf("GetSplunkRedundancyConfigMapName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkClusterManager, got, want)
+	}
+}
+
 func TestGetSplunkMonitoringconsoleConfigMapName(t *testing.T) {
 	got := GetSplunkMonitoringconsoleConfigMapName("t1", SplunkMonitoringConsole)
 	want := "splunk-t1-monitoring-console"
@@ -52,13 +52,13 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 	cr.Kind = "SearchHeadCluster"
 
 	// validate and updates defaults for CR
-	err := validateSearchHeadClusterSpec(ctx, client, cr)
+	appFrameworkConf, err := validateSearchHeadClusterSpec(ctx, client, cr)
 	if err != nil {
 		return result, err
 	}
 
 	// If needed, Migrate the app framework status
-	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig, false)
+	err = checkAndMigrateAppDeployStatus(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf, false)
 	if err != nil {
 		return result, err
 	}
@@ -66,8 +66,8 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 	// If the app framework is configured then do following things -
 	// 1. Initialize the S3Clients based on providers
 	// 2. Check the status of apps on remote storage.
-	if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
-		err := initAndCheckAppInfoStatus(ctx, client, cr, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext)
+	if len(appFrameworkConf.AppSources) != 0 {
+		err := initAndCheckAppInfoStatus(ctx, client, cr, appFrameworkConf, &cr.Status.AppContext)
 		if err != nil {
 			eventPublisher.Warning(ctx, "initAndCheckAppInfoStatus", fmt.Sprintf("init and check app info status failed %s", err.Error()))
 			cr.Status.AppContext.IsDeploymentInProgress = false
@@ -116,13 +116,16 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 		// If this is the last of its kind getting deleted,
 		// remove the entry for this CR type from configMap or else
 		// just decrement the refCount for this CR type.
-		if len(cr.Spec.AppFrameworkConfig.AppSources) != 0 {
+		if len(appFrameworkConf.AppSources) != 0 {
 			err = UpdateOrRemoveEntryFromConfigMapLocked(ctx, client, cr, SplunkSearchHead)
 			if err != nil {
 				return result, err
 			}
 		}
 
+		// remove the app packages kept for the rollback
+		removeAppRollbackPkgs(ctx, getAppRollbackDir(cr))
+
 		DeleteOwnerReferencesForResources(ctx, client, cr, nil, SplunkSearchHead)
 		terminating, err := splctrl.CheckForDeletion(ctx, cr, client)
 		if terminating && err != nil { // don't bother if no error, since it will just be removed immmediately after
@@ -173,6 +176,17 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 	}
 	cr.Status.DeployerPhase = phase
 
+	// the search heads wait for an etc snapshot being restored into the deployer
+	restorePending, err := isEtcRestorePending(ctx, client, cr)
+	if err != nil {
+		return result, err
+	}
+	if restorePending {
+		scopedLog.Info("Waiting for the etc restore of the deployer")
+		cr.Status.Phase = enterpriseApi.PhasePending
+		return result, nil
+	}
+
 	// create or update statefulset for the search heads
 	statefulSet, err = getSearchHeadStatefulSet(ctx, client, cr)
 	if err != nil {
@@ -194,7 +208,7 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 
 	var finalResult *reconcile.Result
 	if cr.Status.DeployerPhase == enterpriseApi.PhaseReady {
-		finalResult = handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig)
+		finalResult = handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, appFrameworkConf)
 	}
 
 	// no need to requeue if everything is ready
@@ -234,6 +248,9 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 			result = *finalResult
 		}
 	}
+	// take the snapshot sets of the PVCs on schedule
+	applyVolumeSnapshotPolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, cr.Status.Phase == enterpriseApi.PhaseReady && cr.Status.DeployerPhase == enterpriseApi.PhaseReady, &result)
+
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
 	if !result.Requeue {
@@ -449,6 +466,14 @@ func (mgr *searchHeadClusterPodManager) Update(ctx context.Context, c splcommon.
 
 	// update CR status with SHC information
 	err = mgr.updateStatus(ctx, statefulSet)
+
+	// the captain configuration is reconciled before checking the captain, since a static captain recovers a search head cluster without one
+	if err == nil && mgr.cr.Status.ReadyReplicas > 0 {
+		err = mgr.applyCaptainConfig(ctx)
+		if err != nil {
+			return enterpriseApi.PhaseError, err
+		}
+	}
 	if err != nil || mgr.cr.Status.ReadyReplicas == 0 || !mgr.cr.Status.Initialized || !mgr.cr.Status.CaptainReady {
 		mgr.log.Info("Search head cluster is not ready", "reason ", err)
 		return enterpriseApi.PhasePending, nil
@@ -485,6 +510,16 @@ func (mgr *searchHeadClusterPodManager) PrepareRecycle(ctx context.Context, n in
 
 	switch mgr.cr.Status.Members[n].Status {
 	case "Up":
+		// Transfer captaincy to another member first, so that the search head cluster doesn't go through an election.
+		// A static captain can't be transferred, as captain election is disabled
+		if mgr.cr.Status.Captain == memberName && mgr.cr.Status.StaticCaptain == "" {
+			target := mgr.getCaptaincyTransferTarget(n)
+			if target >= 0 {
+				mgr.log.Info("Transferring captaincy before detaining search head cluster member", "memberName", memberName, "targetName", mgr.cr.Status.Members[target].Name)
+				return false, TransferSearchHeadCaptaincyCall(ctx, mgr, n, target)
+			}
+		}
+
 		// Detain search head
 		mgr.log.Info("Detaining search head cluster member", "memberName", memberName)
 		c := mgr.getClient(ctx, n)
@@ -518,6 +553,33 @@ func (mgr *searchHeadClusterPodManager) PrepareRecycle(ctx context.Context, n in
 	return false, fmt.Errorf("Status=%s", mgr.cr.Status.Members[n].Status)
 }
 
+// GetRecycleOrder for searchHeadClusterPodManager returns the ordinals of the search head pods to check for updates, with the captain last
+func (mgr *searchHeadClusterPodManager) GetRecycleOrder(ctx context.Context, readyReplicas int32) []int32 {
+	var order []int32
+	captain := int32(-1)
+	for n := readyReplicas - 1; n >= 0; n-- {
+		if mgr.cr.Status.Captain != "" && GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), n) == mgr.cr.Status.Captain {
+			captain = n
+			continue
+		}
+		order = append(order, n)
+	}
+	if captain >= 0 {
+		order = append(order, captain)
+	}
+	return order
+}
+
+// getCaptaincyTransferTarget for searchHeadClusterPodManager returns a healthy member to transfer captaincy to from member n, or -1 if there is none
+func (mgr *searchHeadClusterPodManager) getCaptaincyTransferTarget(n int32) int32 {
+	for i, member := range mgr.cr.Status.Members {
+		if int32(i) != n && member.Status == "Up" {
+			return int32(i)
+		}
+	}
+	return -1
+}
+
 // FinishRecycle for searchHeadClusterPodManager completes recycle event for search head pod; it returns true when complete
 func (mgr *searchHeadClusterPodManager) FinishRecycle(ctx context.Context, n int32) (bool, error) {
 	memberName := GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), n)
@@ -565,6 +627,12 @@ var GetSearchHeadClusterMemberInfo = func(ctx context.Context, mgr *searchHeadCl
 	return c.GetSearchHeadClusterMemberInfo()
 }
 
+// TransferSearchHeadCaptaincyCall used in mocking this function
+var TransferSearchHeadCaptaincyCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, target int32) error {
+	c := mgr.getClient(ctx, n)
+	return c.TransferSearchHeadCaptaincy(mgr.getMemberURI(target))
+}
+
 // GetSearchHeadCaptainInfo used in mocking this function
 var GetSearchHeadCaptainInfo = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32) (*splclient.SearchHeadCaptainInfo, error) {
 	c := mgr.getClient(ctx, n)
@@ -655,19 +723,35 @@ func getDeployerStatefulSet(ctx context.Context, client splcommon.ControllerClie
 }
 
 // validateSearchHeadClusterSpec checks validity and makes default updates to a SearchHeadClusterSpec, and returns error if something is wrong.
-func validateSearchHeadClusterSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SearchHeadCluster) error {
+// It returns the App Framework config to deploy the apps with, with the AppSource references resolved.
+func validateSearchHeadClusterSpec(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SearchHeadCluster) (*enterpriseApi.AppFrameworkSpec, error) {
 	if cr.Spec.Replicas < 3 {
 		cr.Spec.Replicas = 3
 	}
 
-	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, cr.Spec.AppFrameworkConfig) {
-		err := ValidateAppFrameworkSpec(ctx, &cr.Spec.AppFrameworkConfig, &cr.Status.AppContext, false, cr.GetObjectKind().GroupVersionKind().Kind)
+	err := validateCaptainPreference(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	appFrameworkConf, err := resolveAppSourceRefs(ctx, c, cr, &cr.Spec.AppFrameworkConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(cr.Status.AppContext.AppFrameworkConfig, *appFrameworkConf) {
+		err = ValidateAppFrameworkSpec(ctx, appFrameworkConf, &cr.Status.AppContext, false, cr.GetObjectKind().GroupVersionKind().Kind)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	err = validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return appFrameworkConf, nil
 }
 
 // helper function to get the list of SearchHeadCluster types in the current namespace
@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime/debug"
 	"strings"
 	"testing"
@@ -397,6 +398,80 @@ func TestSearchHeadClusterPodManager(t *testing.T) {
 
 }
 
+func TestSearchHeadClusterCaptainTransfer(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Status: enterpriseApi.SearchHeadClusterStatus{
+			Captain: "splunk-stack1-search-head-2",
+			Members: []enterpriseApi.SearchHeadClusterMemberStatus{
+				{Name: "splunk-stack1-search-head-0", Status: "ManualDetention"},
+				{Name: "splunk-stack1-search-head-1", Status: "Up"},
+				{Name: "splunk-stack1-search-head-2", Status: "Up"},
+			},
+		},
+	}
+	mgr := &searchHeadClusterPodManager{log: logt.WithName("TestSearchHeadClusterCaptainTransfer"), cr: &cr}
+
+	// the captain is recycled last
+	order := mgr.GetRecycleOrder(ctx, 3)
+	if !reflect.DeepEqual(order, []int32{1, 0, 2}) {
+		t.Errorf("GetRecycleOrder() = %v; want [1 0 2]", order)
+	}
+
+	savedCall := TransferSearchHeadCaptaincyCall
+	defer func() { TransferSearchHeadCaptaincyCall = savedCall }()
+	var transfers [][]int32
+	TransferSearchHeadCaptaincyCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, target int32) error {
+		transfers = append(transfers, []int32{n, target})
+		return nil
+	}
+
+	// captaincy is transferred to a healthy member, before detaining the captain
+	ready, err := mgr.PrepareRecycle(ctx, 2)
+	if ready || err != nil || !reflect.DeepEqual(transfers, [][]int32{{2, 1}}) {
+		t.Errorf("captaincy should be transferred. transfers: %v, error: %v", transfers, err)
+	}
+
+	// a static captain is detained without a transfer, as captain election is disabled
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-search-head-2.splunk-stack1-search-head-headless.test.svc.cluster.local:8089/services/shcluster/member/control/control/set_manual_detention?manual_detention=on",
+		Status: 200,
+	})
+	mgr.c = spltest.NewMockClient()
+	mgr.newSplunkClient = func(managementURI, username, password string) *splclient.SplunkClient {
+		c := splclient.NewSplunkClient(managementURI, username, password)
+		c.Client = mockSplunkClient
+		return c
+	}
+	cr.Status.StaticCaptain = "splunk-stack1-search-head-2"
+	transfers = nil
+	ready, err = mgr.PrepareRecycle(ctx, 2)
+	if ready || err != nil || len(transfers) != 0 {
+		t.Errorf("static captain should be detained without a captaincy transfer. transfers: %v, error: %v", transfers, err)
+	}
+	mockSplunkClient.CheckRequests(t, "PrepareRecycle(static captain)")
+	cr.Status.StaticCaptain = ""
+
+	// captaincy can't be transferred without another healthy member
+	cr.Status.Members[1].Status = "ManualDetention"
+	if target := mgr.getCaptaincyTransferTarget(2); target != -1 {
+		t.Errorf("getCaptaincyTransferTarget() = %d; want -1", target)
+	}
+
+	// the new captain is recycled last
+	cr.Status.Captain = "splunk-stack1-search-head-1"
+	order = mgr.GetRecycleOrder(ctx, 3)
+	if !reflect.DeepEqual(order, []int32{2, 0, 1}) {
+		t.Errorf("GetRecycleOrder() = %v; want [2 0 1]", order)
+	}
+}
+
 func TestApplyShcSecret(t *testing.T) {
 	ctx := context.TODO()
 	method := "ApplyShcSecret"
@@ -657,7 +732,7 @@ func TestGetSearchHeadStatefulSet(t *testing.T) {
 
 	test := func(want string) {
 		f := func() (interface{}, error) {
-			if err := validateSearchHeadClusterSpec(ctx, c, &cr); err != nil {
+			if _, err := validateSearchHeadClusterSpec(ctx, c, &cr); err != nil {
 				t.Errorf("validateSearchHeadClusterSpec() returned error: %v", err)
 			}
 			return getSearchHeadStatefulSet(ctx, c, &cr)
@@ -724,7 +799,7 @@ func TestGetDeployerStatefulSet(t *testing.T) {
 
 	test := func(want string) {
 		f := func() (interface{}, error) {
-			if err := validateSearchHeadClusterSpec(ctx, c, &cr); err != nil {
+			if _, err := validateSearchHeadClusterSpec(ctx, c, &cr); err != nil {
 				t.Errorf("validateSearchHeadClusterSpec() returned error: %v", err)
 			}
 			return getDeployerStatefulSet(ctx, c, &cr)
@@ -1890,3 +1965,64 @@ func TestSearchHeadClusterWithReadyState(t *testing.T) {
 		t.Errorf("Unexpected error while running reconciliation for search head cluster with app framework. Error=%v", err)
 	}
 }
+
+func TestApplySearchHeadClusterPendingEtcRestore(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	restore := getTestSplunkRestore()
+	restore.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "SearchHeadCluster", Name: "stack1"}
+	err := c.Create(ctx, restore)
+	if err != nil {
+		t.Fatalf("SplunkRestore should be created. error: %v", err)
+	}
+
+	shc := &enterpriseApi.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "stack1",
+			Namespace:   "test",
+			Annotations: map[string]string{enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation: restore.GetName()},
+		},
+		Spec: enterpriseApi.SearchHeadClusterSpec{
+			Replicas: 3,
+			CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{
+				Mock: true,
+			},
+		},
+	}
+	err = c.Create(ctx, shc)
+	if err != nil {
+		t.Fatalf("SearchHeadCluster should be created. error: %v", err)
+	}
+
+	// the deployer is created, while the search heads wait for the etc restore of the deployer
+	_, err = ApplySearchHeadCluster(ctx, c, shc)
+	if err != nil || shc.Status.Phase != enterpriseApi.PhasePending {
+		t.Errorf("search heads should wait for the etc restore. phase: %s, error: %v", shc.Status.Phase, err)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-deployer"}, statefulSet)
+	if err != nil {
+		t.Errorf("deployer statefulset should be created. error: %v", err)
+	}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-search-head"}, statefulSet)
+	if err == nil {
+		t.Errorf("search head statefulset should not be created during the etc restore")
+	}
+
+	// the search heads are created once the restore is done
+	restore.Status.RestoreTime = time.Now().Unix()
+	err = c.Status().Update(ctx, restore)
+	if err != nil {
+		t.Fatalf("SplunkRestore status should be updated. error: %v", err)
+	}
+	_, err = ApplySearchHeadCluster(ctx, c, shc)
+	if err != nil {
+		t.Errorf("ApplySearchHeadCluster should not have returned error. error: %v", err)
+	}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-search-head"}, statefulSet)
+	if err != nil {
+		t.Errorf("search head statefulset should be created once the etc restore is done. error: %v", err)
+	}
+}
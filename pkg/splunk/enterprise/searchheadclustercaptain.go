@@ -0,0 +1,283 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The members of a search head cluster listed in captainPreference are configured as preferred captains, which the
+// captain election favors. In an emergency, e.g. after the loss of a majority of the members, captain election may be
+// disabled with staticCaptain. Switching back to dynamic captaincy requires the search head cluster to be healthy
+// again, so the Operator only does it once staticCaptain is unset and the restore-dynamic-captain annotation is set. The
+// annotation is removed once dynamic captaincy is restored, so that it doesn't restore a later static captain right away.
+
+// GetSearchHeadCaptainMembersCall used in mocking this function
+var GetSearchHeadCaptainMembersCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32) (map[string]splclient.SearchHeadCaptainMemberInfo, error) {
+	c := mgr.getClient(ctx, n)
+	return c.GetSearchHeadCaptainMembers()
+}
+
+// SetSearchHeadPreferredCaptainCall used in mocking this function
+var SetSearchHeadPreferredCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, preferred bool) error {
+	c := mgr.getClient(ctx, n)
+	return c.SetSearchHeadPreferredCaptain(preferred)
+}
+
+// SetSearchHeadStaticCaptainCall used in mocking this function
+var SetSearchHeadStaticCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, captain int32) error {
+	c := mgr.getClient(ctx, n)
+	return c.SetSearchHeadStaticCaptain(mgr.getMemberURI(captain), n == captain)
+}
+
+// RestoreSearchHeadDynamicCaptainCall used in mocking this function
+var RestoreSearchHeadDynamicCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32) error {
+	c := mgr.getClient(ctx, n)
+	return c.SetSearchHeadDynamicCaptain(mgr.getMemberURI(n))
+}
+
+// BootstrapSearchHeadCaptainCall used in mocking this function
+var BootstrapSearchHeadCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, serversList []string) error {
+	c := mgr.getClient(ctx, n)
+	return c.BootstrapSearchHeadCaptain(serversList)
+}
+
+// getMemberURI for searchHeadClusterPodManager returns the management URI of the member n
+func (mgr *searchHeadClusterPodManager) getMemberURI(n int32) string {
+	fqdnName := GetSplunkStatefulsetURL(mgr.cr.GetNamespace(), SplunkSearchHead, mgr.cr.GetName(), n, false)
+	return fmt.Sprintf("https://%s:8089", fqdnName)
+}
+
+// hasCaptainConfig checks if preferred captains or a static captain are configured, or still need to be removed
+func hasCaptainConfig(cr *enterpriseApi.SearchHeadCluster) bool {
+	return len(cr.Spec.CaptainPreference) > 0 || cr.Spec.StaticCaptain ||
+		len(cr.Status.CaptainPreference) > 0 || cr.Status.StaticCaptain != ""
+}
+
+// getStaticCaptainOrdinal returns the ordinal of the static captain: the first preferred captain, or else the member 0
+func getStaticCaptainOrdinal(cr *enterpriseApi.SearchHeadCluster) int32 {
+	if len(cr.Spec.CaptainPreference) > 0 {
+		return cr.Spec.CaptainPreference[0]
+	}
+	return 0
+}
+
+// validateCaptainPreference checks the ordinals of the preferred captains of a SearchHeadClusterSpec
+func validateCaptainPreference(cr *enterpriseApi.SearchHeadCluster) error {
+	seen := make(map[int32]bool)
+	for _, n := range cr.Spec.CaptainPreference {
+		if n < 0 || n >= cr.Spec.Replicas {
+			return fmt.Errorf("captainPreference %d is not the ordinal of a search head cluster member", n)
+		}
+		if seen[n] {
+			return fmt.Errorf("captainPreference %d is duplicated", n)
+		}
+		seen[n] = true
+	}
+	return nil
+}
+
+// isStaticCaptainMember checks if the static captain is configured on a member
+func isStaticCaptainMember(cr *enterpriseApi.SearchHeadCluster, memberName string) bool {
+	for _, name := range cr.Status.StaticCaptainMembers {
+		if name == memberName {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCaptainConfig for searchHeadClusterPodManager reconciles the preferred captains and the static captain of the members
+func (mgr *searchHeadClusterPodManager) applyCaptainConfig(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyCaptainConfig").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	if mgr.cr.Status.StaticCaptain == "" {
+		err := mgr.clearDynamicCaptainAnnotation(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !hasCaptainConfig(mgr.cr) {
+		mgr.cr.Status.CaptainPreferred = false
+		return nil
+	}
+
+	if mgr.cr.Spec.StaticCaptain {
+		return mgr.applyStaticCaptain(ctx)
+	}
+
+	if mgr.cr.Status.StaticCaptain != "" {
+		if _, ok := mgr.cr.GetAnnotations()[enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation]; !ok {
+			scopedLog.Info("Captain election remains disabled until the search head cluster is annotated", "annotation", enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation)
+			return nil
+		}
+		return mgr.restoreDynamicCaptain(ctx)
+	}
+
+	return mgr.applyCaptainPreference(ctx)
+}
+
+// applyStaticCaptain for searchHeadClusterPodManager disables captain election, configuring the static captain on each member
+func (mgr *searchHeadClusterPodManager) applyStaticCaptain(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyStaticCaptain").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	captain := getStaticCaptainOrdinal(mgr.cr)
+	captainName := GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), captain)
+	if mgr.cr.Status.StaticCaptain != captainName {
+		scopedLog.Info("Disabling captain election with a static captain", "captain", captainName)
+		mgr.cr.Status.StaticCaptain = captainName
+		mgr.cr.Status.StaticCaptainMembers = nil
+	}
+
+	// the static captain is configured first, then the other members; members which are down are configured later
+	order := []int32{captain}
+	for n := int32(0); n < int32(len(mgr.cr.Status.Members)); n++ {
+		if n != captain {
+			order = append(order, n)
+		}
+	}
+	for _, n := range order {
+		memberName := GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), n)
+		if isStaticCaptainMember(mgr.cr, memberName) {
+			continue
+		}
+		err := SetSearchHeadStaticCaptainCall(ctx, mgr, n, captain)
+		if err != nil {
+			if n == captain {
+				return err
+			}
+			scopedLog.Info("Unable to configure the static captain on member", "memberName", memberName, "error", err.Error())
+			continue
+		}
+		mgr.cr.Status.StaticCaptainMembers = append(mgr.cr.Status.StaticCaptainMembers, memberName)
+	}
+	mgr.cr.Status.CaptainPreferred = false
+	return nil
+}
+
+// restoreDynamicCaptain for searchHeadClusterPodManager enables captain election again on each member, and bootstraps a captain
+func (mgr *searchHeadClusterPodManager) restoreDynamicCaptain(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("restoreDynamicCaptain").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	captain := int32(0)
+	var serversList []string
+	for n := int32(0); n < int32(len(mgr.cr.Status.Members)); n++ {
+		memberName := GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), n)
+		if memberName == mgr.cr.Status.StaticCaptain {
+			captain = n
+		}
+		if isStaticCaptainMember(mgr.cr, memberName) {
+			err := RestoreSearchHeadDynamicCaptainCall(ctx, mgr, n)
+			if err != nil {
+				return err
+			}
+		}
+		serversList = append(serversList, mgr.getMemberURI(n))
+	}
+
+	scopedLog.Info("Bootstrapping captain election", "serversList", serversList)
+	err := BootstrapSearchHeadCaptainCall(ctx, mgr, captain, serversList)
+	if err != nil {
+		return err
+	}
+
+	scopedLog.Info("Restored dynamic captaincy")
+	mgr.cr.Status.StaticCaptain = ""
+	mgr.cr.Status.StaticCaptainMembers = nil
+	return mgr.clearDynamicCaptainAnnotation(ctx)
+}
+
+// clearDynamicCaptainAnnotation for searchHeadClusterPodManager removes the restore-dynamic-captain annotation, if set
+func (mgr *searchHeadClusterPodManager) clearDynamicCaptainAnnotation(ctx context.Context) error {
+	if _, ok := mgr.cr.GetAnnotations()[enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation]; !ok {
+		return nil
+	}
+
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("clearDynamicCaptainAnnotation").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	// the update is made on a copy, so that the status changes of this reconcile are kept
+	cr := mgr.cr.DeepCopy()
+	delete(cr.Annotations, enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation)
+	err := mgr.c.Update(ctx, cr)
+	if err != nil {
+		return err
+	}
+
+	scopedLog.Info("Removed the annotation", "annotation", enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation)
+	mgr.cr.SetAnnotations(cr.GetAnnotations())
+	mgr.cr.SetResourceVersion(cr.GetResourceVersion())
+	return nil
+}
+
+// applyCaptainPreference for searchHeadClusterPodManager configures the members of captainPreference as preferred captains, and no other member
+func (mgr *searchHeadClusterPodManager) applyCaptainPreference(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyCaptainPreference").WithValues("name", mgr.cr.GetName(), "namespace", mgr.cr.GetNamespace())
+
+	// the preferred captains are reported by the captain
+	captain := int32(-1)
+	for n, member := range mgr.cr.Status.Members {
+		if member.Name == mgr.cr.Status.Captain {
+			captain = int32(n)
+		}
+	}
+	if captain < 0 || !mgr.cr.Status.CaptainReady {
+		scopedLog.Info("Waiting for the captain to configure preferred captains")
+		return nil
+	}
+	members, err := GetSearchHeadCaptainMembersCall(ctx, mgr, captain)
+	if err != nil {
+		scopedLog.Info("Unable to retrieve the preferred captains", "error", err.Error())
+		return nil
+	}
+
+	preferred := make(map[int32]bool)
+	for _, n := range mgr.cr.Spec.CaptainPreference {
+		preferred[n] = true
+	}
+	for n := range mgr.cr.Status.Members {
+		member := &mgr.cr.Status.Members[n]
+		info, ok := members[member.Name]
+		if !ok {
+			// the member hasn't joined the search head cluster yet
+			continue
+		}
+		member.PreferredCaptain = info.PreferredCaptain
+		if info.PreferredCaptain != preferred[int32(n)] {
+			scopedLog.Info("Changing preferred captain of member", "memberName", member.Name, "preferredCaptain", preferred[int32(n)])
+			err = SetSearchHeadPreferredCaptainCall(ctx, mgr, int32(n), preferred[int32(n)])
+			if err != nil {
+				return err
+			}
+			member.PreferredCaptain = preferred[int32(n)]
+		}
+	}
+
+	mgr.cr.Status.CaptainPreferred = mgr.cr.Status.Members[captain].PreferredCaptain
+	mgr.cr.Status.CaptainPreference = append([]int32(nil), mgr.cr.Spec.CaptainPreference...)
+	return nil
+}
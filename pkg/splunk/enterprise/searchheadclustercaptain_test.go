@@ -0,0 +1,228 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getCaptainTestSearchHeadCluster() *enterpriseApi.SearchHeadCluster {
+	return &enterpriseApi.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.SearchHeadClusterSpec{
+			Replicas: 3,
+		},
+		Status: enterpriseApi.SearchHeadClusterStatus{
+			Captain:      "splunk-stack1-search-head-0",
+			CaptainReady: true,
+			Members: []enterpriseApi.SearchHeadClusterMemberStatus{
+				{Name: "splunk-stack1-search-head-0", Status: "Up"},
+				{Name: "splunk-stack1-search-head-1", Status: "Up"},
+				{Name: "splunk-stack1-search-head-2", Status: "Up"},
+			},
+		},
+	}
+}
+
+func TestValidateCaptainPreference(t *testing.T) {
+	cr := getCaptainTestSearchHeadCluster()
+	cr.Spec.CaptainPreference = []int32{2, 0}
+	if err := validateCaptainPreference(cr); err != nil {
+		t.Errorf("captainPreference should be valid. error: %v", err)
+	}
+
+	cr.Spec.CaptainPreference = []int32{3}
+	if err := validateCaptainPreference(cr); err == nil {
+		t.Errorf("captainPreference should be the ordinal of a member")
+	}
+
+	cr.Spec.CaptainPreference = []int32{1, 1}
+	if err := validateCaptainPreference(cr); err == nil {
+		t.Errorf("captainPreference should not be duplicated")
+	}
+}
+
+func TestApplyCaptainPreference(t *testing.T) {
+	ctx := context.TODO()
+	cr := getCaptainTestSearchHeadCluster()
+	mgr := &searchHeadClusterPodManager{log: logt.WithName("TestApplyCaptainPreference"), cr: cr}
+
+	savedGetCall := GetSearchHeadCaptainMembersCall
+	savedSetCall := SetSearchHeadPreferredCaptainCall
+	defer func() {
+		GetSearchHeadCaptainMembersCall = savedGetCall
+		SetSearchHeadPreferredCaptainCall = savedSetCall
+	}()
+	members := map[string]splclient.SearchHeadCaptainMemberInfo{
+		"splunk-stack1-search-head-0": {Label: "splunk-stack1-search-head-0"},
+		"splunk-stack1-search-head-1": {Label: "splunk-stack1-search-head-1", PreferredCaptain: true},
+		"splunk-stack1-search-head-2": {Label: "splunk-stack1-search-head-2"},
+	}
+	getCalls := 0
+	GetSearchHeadCaptainMembersCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32) (map[string]splclient.SearchHeadCaptainMemberInfo, error) {
+		getCalls++
+		return members, nil
+	}
+	var setCalls []string
+	SetSearchHeadPreferredCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, preferred bool) error {
+		setCalls = append(setCalls, fmt.Sprintf("%d=%t", n, preferred))
+		return nil
+	}
+
+	// nothing to configure
+	err := mgr.applyCaptainConfig(ctx)
+	if err != nil || getCalls != 0 {
+		t.Errorf("no captain configuration should be applied. error: %v", err)
+	}
+
+	// the captain becomes the only preferred captain
+	cr.Spec.CaptainPreference = []int32{0}
+	err = mgr.applyCaptainConfig(ctx)
+	if err != nil || !reflect.DeepEqual(setCalls, []string{"0=true", "1=false"}) {
+		t.Errorf("preferred captains should be configured. calls: %v, error: %v", setCalls, err)
+	}
+	if !cr.Status.CaptainPreferred || !cr.Status.Members[0].PreferredCaptain || cr.Status.Members[1].PreferredCaptain || !reflect.DeepEqual(cr.Status.CaptainPreference, []int32{0}) {
+		t.Errorf("preferred captains should be tracked. status: %v", cr.Status)
+	}
+
+	// preferred captains are removed
+	members["splunk-stack1-search-head-0"] = splclient.SearchHeadCaptainMemberInfo{PreferredCaptain: true}
+	members["splunk-stack1-search-head-1"] = splclient.SearchHeadCaptainMemberInfo{}
+	setCalls = nil
+	cr.Spec.CaptainPreference = nil
+	err = mgr.applyCaptainConfig(ctx)
+	if err != nil || !reflect.DeepEqual(setCalls, []string{"0=false"}) || cr.Status.CaptainPreferred || cr.Status.CaptainPreference != nil {
+		t.Errorf("preferred captains should be removed. calls: %v, error: %v", setCalls, err)
+	}
+
+	// errors are reported
+	cr.Spec.CaptainPreference = []int32{2}
+	SetSearchHeadPreferredCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, preferred bool) error {
+		return fmt.Errorf("member is down")
+	}
+	err = mgr.applyCaptainConfig(ctx)
+	if err == nil {
+		t.Errorf("preferred captain errors should be reported")
+	}
+}
+
+func TestApplyStaticCaptain(t *testing.T) {
+	ctx := context.TODO()
+	cr := getCaptainTestSearchHeadCluster()
+	cr.Spec.CaptainPreference = []int32{1}
+	cr.Spec.StaticCaptain = true
+	c := spltest.NewMockClient()
+	mgr := &searchHeadClusterPodManager{log: logt.WithName("TestApplyStaticCaptain"), cr: cr, c: c}
+
+	savedStaticCall := SetSearchHeadStaticCaptainCall
+	savedRestoreCall := RestoreSearchHeadDynamicCaptainCall
+	savedBootstrapCall := BootstrapSearchHeadCaptainCall
+	defer func() {
+		SetSearchHeadStaticCaptainCall = savedStaticCall
+		RestoreSearchHeadDynamicCaptainCall = savedRestoreCall
+		BootstrapSearchHeadCaptainCall = savedBootstrapCall
+	}()
+	down := map[int32]bool{2: true}
+	var calls []string
+	SetSearchHeadStaticCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, captain int32) error {
+		calls = append(calls, fmt.Sprintf("static %d/%d", n, captain))
+		if down[n] {
+			return fmt.Errorf("member is down")
+		}
+		return nil
+	}
+	RestoreSearchHeadDynamicCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32) error {
+		calls = append(calls, fmt.Sprintf("dynamic %d", n))
+		return nil
+	}
+	BootstrapSearchHeadCaptainCall = func(ctx context.Context, mgr *searchHeadClusterPodManager, n int32, serversList []string) error {
+		calls = append(calls, fmt.Sprintf("bootstrap %d/%d", n, len(serversList)))
+		return nil
+	}
+
+	// the static captain is configured first, and a member which is down is skipped
+	err := mgr.applyCaptainConfig(ctx)
+	if err != nil || !reflect.DeepEqual(calls, []string{"static 1/1", "static 0/1", "static 2/1"}) {
+		t.Errorf("static captain should be configured. calls: %v, error: %v", calls, err)
+	}
+	if cr.Status.StaticCaptain != "splunk-stack1-search-head-1" || len(cr.Status.StaticCaptainMembers) != 2 {
+		t.Errorf("static captain should be tracked. status: %v", cr.Status)
+	}
+
+	// the member is configured once it is back
+	delete(down, 2)
+	calls = nil
+	err = mgr.applyCaptainConfig(ctx)
+	if err != nil || !reflect.DeepEqual(calls, []string{"static 2/1"}) || len(cr.Status.StaticCaptainMembers) != 3 {
+		t.Errorf("static captain should be configured on the member. calls: %v, error: %v", calls, err)
+	}
+
+	// static captaincy remains without the annotation
+	cr.Spec.StaticCaptain = false
+	calls = nil
+	err = mgr.applyCaptainConfig(ctx)
+	if err != nil || len(calls) != 0 || cr.Status.StaticCaptain == "" {
+		t.Errorf("static captaincy should remain. calls: %v, error: %v", calls, err)
+	}
+
+	// dynamic captaincy is restored with the annotation
+	cr.Annotations = map[string]string{enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation: ""}
+	err = mgr.applyCaptainConfig(ctx)
+	if err != nil || !reflect.DeepEqual(calls, []string{"dynamic 0", "dynamic 1", "dynamic 2", "bootstrap 1/3"}) {
+		t.Errorf("dynamic captaincy should be restored. calls: %v, error: %v", calls, err)
+	}
+	if cr.Status.StaticCaptain != "" || cr.Status.StaticCaptainMembers != nil {
+		t.Errorf("static captain should be cleared. status: %v", cr.Status)
+	}
+
+	// the annotation is removed, without losing the status changes
+	if _, ok := cr.Annotations[enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation]; ok || len(c.Calls["Update"]) != 1 {
+		t.Errorf("restore dynamic captain annotation should be removed. annotations: %v", cr.Annotations)
+	}
+	updated := c.Calls["Update"][0].Obj.(*enterpriseApi.SearchHeadCluster)
+	if _, ok := updated.Annotations[enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation]; ok {
+		t.Errorf("restore dynamic captain annotation should be removed from the CR. annotations: %v", updated.Annotations)
+	}
+
+	// a stale annotation is removed, so that it doesn't restore a later static captain right away
+	cr.Annotations = map[string]string{enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation: ""}
+	cr.Status.CaptainReady = false
+	err = mgr.applyCaptainConfig(ctx)
+	cr.Status.CaptainReady = true
+	if _, ok := cr.Annotations[enterpriseApi.SearchHeadClusterDynamicCaptainAnnotation]; err != nil || ok || len(c.Calls["Update"]) != 2 {
+		t.Errorf("stale restore dynamic captain annotation should be removed. annotations: %v, error: %v", cr.Annotations, err)
+	}
+
+	// the static captain must be reachable
+	cr.Spec.StaticCaptain = true
+	down[1] = true
+	err = mgr.applyCaptainConfig(ctx)
+	if err == nil {
+		t.Errorf("static captain errors should be reported")
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/remotecommand"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// A SplunkBackup backs up the KV store of a SearchHeadCluster or a Standalone on a schedule. The backup is taken with
+// `splunk backup kvstore` on the captain of the search head cluster, or on the standalone pod, and once it completes,
+// the archive is copied from the pod to the operator, and uploaded to the remote storage volume of the SplunkBackup.
+// The oldest backups beyond the retention count are deleted from the remote storage. A SplunkBackup of type etc takes
+// etc snapshots of a ClusterManager or a search head cluster deployer the same way.
+
+const (
+	// splunkBackupPollInterval is how often a running backup or restore is checked
+	splunkBackupPollInterval = 10 * time.Second
+
+	// splunkBackupTargetRetryInterval is how often a target, which is not ready, is checked
+	splunkBackupTargetRetryInterval = 30 * time.Second
+
+	// splunkBackupTimeout is how long a backup or restore may run
+	splunkBackupTimeout = time.Hour
+
+	// splunkBackupArchiveSuffix is the suffix added to the archive name by `splunk backup kvstore`, and of the etc snapshots
+	splunkBackupArchiveSuffix = ".tar.gz"
+)
+
+// splunkBackupStagingDir is the directory on the operator where the backups are staged
+var splunkBackupStagingDir = filepath.Join(splcommon.AppDownloadVolume, "backups")
+
+// getSplunkBackupPodExecClient returns the client to run the commands on the pod, used in mocking
+var getSplunkBackupPodExecClient = func(client splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+	return splutil.GetPodExecClient(client, cr, podName)
+}
+
+// copyFileFromPod copies a file from the pod to the local file. The file is streamed to the local file, instead of
+// being kept in memory, and the size of the copy is checked against the size of the file on the pod
+var copyFileFromPod = func(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, podName string, podPath string, localFile string) error {
+	stdOut, stdErr, err := splutil.PodExecCommand(ctx, client, podName, cr.GetNamespace(), []string{"stat", "-c", "%s", podPath}, &remotecommand.StreamOptions{}, false, false, "")
+	if err != nil {
+		return fmt.Errorf("unable to get the size of %s on pod %s. stdErr: %s, err: %v", podPath, podName, stdErr, err)
+	}
+	podFileSize, err := strconv.ParseInt(strings.TrimSpace(stdOut), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid size of %s on pod %s: %s", podPath, podName, stdOut)
+	}
+
+	file, err := os.OpenFile(localFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	stdErr, err = splutil.PodExecStreamCommand(ctx, client, podName, cr.GetNamespace(), []string{"cat", podPath}, file)
+	closeErr := file.Close()
+	if err != nil {
+		return fmt.Errorf("unable to read %s from pod %s. stdErr: %s, err: %v", podPath, podName, stdErr, err)
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	return checkCopiedFileSize(localFile, podFileSize)
+}
+
+// checkCopiedFileSize checks that the local copy of a file has the size of the file on the pod
+func checkCopiedFileSize(localFile string, podFileSize int64) error {
+	fileInfo, err := os.Stat(localFile)
+	if err != nil {
+		return err
+	}
+	if fileInfo.Size() != podFileSize {
+		return fmt.Errorf("incomplete copy of %s. copied %d bytes of %d", filepath.Base(localFile), fileInfo.Size(), podFileSize)
+	}
+	return nil
+}
+
+// getSplunkBackupRemoteDataClientMgr returns the RemoteDataClientManager for the volume of the SplunkBackup
+func getSplunkBackupRemoteDataClientMgr(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup) *RemoteDataClientManager {
+	vol := cr.Spec.Volume
+	if _, ok := splclient.RemoteDataClientsMap[vol.Provider]; !ok {
+		splclient.RegisterRemoteDataClient(ctx, vol.Provider)
+	}
+	remoteDataClientWrapper := splclient.RemoteDataClientsMap[vol.Provider]
+	return &RemoteDataClientManager{
+		client:              client,
+		cr:                  cr,
+		vol:                 &vol,
+		location:            cr.Spec.Location,
+		initFn:              remoteDataClientWrapper.GetRemoteDataClientInitFuncPtr(ctx),
+		getRemoteDataClient: GetRemoteStorageClient,
+	}
+}
+
+// getSplunkBackupRemoteFile returns the path of the archive relative to the bucket of the SplunkBackup volume
+func getSplunkBackupRemoteFile(cr *enterpriseApi.SplunkBackup, archiveName string) string {
+	_, prefix := getRemoteStorageBucketAndPrefix(&cr.Spec.Volume, cr.Spec.Location)
+	return prefix + archiveName
+}
+
+// UploadSplunkBackupCall used in mocking this function
+var UploadSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, localFile string, archiveName string) error {
+	return getSplunkBackupRemoteDataClientMgr(ctx, client, cr).UploadData(ctx, localFile, getSplunkBackupRemoteFile(cr, archiveName))
+}
+
+// DeleteSplunkBackupCall used in mocking this function
+var DeleteSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, archiveName string) error {
+	return getSplunkBackupRemoteDataClientMgr(ctx, client, cr).DeleteData(ctx, getSplunkBackupRemoteFile(cr, archiveName))
+}
+
+// getBackupTargetPod returns the CR backed up or restored, and the pod running the backup or restore. A KV store
+// backup runs on the captain of a SearchHeadCluster, or on the pod of a Standalone
+func getBackupTargetPod(ctx context.Context, client splcommon.ControllerClient, namespace string, backupType string, target enterpriseApi.BackupTargetReference) (splcommon.MetaObject, string, error) {
+	if backupType == enterpriseApi.SplunkBackupTypeEtc {
+		return getEtcBackupTargetPod(ctx, client, namespace, target)
+	}
+
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: target.Name}
+	switch target.Kind {
+	case "SearchHeadCluster":
+		cr := &enterpriseApi.SearchHeadCluster{}
+		err := client.Get(ctx, namespacedName, cr)
+		if err != nil {
+			return nil, "", err
+		}
+		if cr.Status.Captain == "" || !cr.Status.CaptainReady {
+			return nil, "", fmt.Errorf("search head cluster %s has no captain ready", target.Name)
+		}
+		return cr, cr.Status.Captain, nil
+	case "Standalone":
+		cr := &enterpriseApi.Standalone{}
+		err := client.Get(ctx, namespacedName, cr)
+		if err != nil {
+			return nil, "", err
+		}
+		if cr.Status.Phase != enterpriseApi.PhaseReady {
+			return nil, "", fmt.Errorf("standalone %s is not ready", target.Name)
+		}
+		return cr, GetSplunkStatefulsetPodName(SplunkStandalone, target.Name, 0), nil
+	default:
+		return nil, "", fmt.Errorf("kind %s can't be backed up", target.Kind)
+	}
+}
+
+// validateBackupTarget validates the CR backed up or restored
+func validateBackupTarget(backupType string, target enterpriseApi.BackupTargetReference) error {
+	switch backupType {
+	case enterpriseApi.SplunkBackupTypeKVStore:
+		if target.Kind != "SearchHeadCluster" && target.Kind != "Standalone" {
+			return fmt.Errorf("target kind should be either SearchHeadCluster or Standalone")
+		}
+	case enterpriseApi.SplunkBackupTypeEtc:
+		if _, ok := etcBackupDirs[target.Kind]; !ok {
+			return fmt.Errorf("target kind should be either ClusterManager or SearchHeadCluster for an etc snapshot")
+		}
+	default:
+		return fmt.Errorf("backup type should be either %s or %s", enterpriseApi.SplunkBackupTypeKVStore, enterpriseApi.SplunkBackupTypeEtc)
+	}
+	if target.Name == "" {
+		return fmt.Errorf("target name is missing")
+	}
+	return nil
+}
+
+// validateSplunkBackupSpec validates the SplunkBackup spec
+func validateSplunkBackupSpec(ctx context.Context, cr *enterpriseApi.SplunkBackup) error {
+	err := validateBackupTarget(getSplunkBackupType(cr), cr.Spec.Target)
+	if err != nil {
+		return err
+	}
+
+	err = validateRemoteVolumeSpec(ctx, []enterpriseApi.VolumeSpec{cr.Spec.Volume}, true)
+	if err != nil {
+		return err
+	}
+
+	if !splclient.IsDataUploadSupported(cr.Spec.Volume.Provider) {
+		return fmt.Errorf("provider '%s' can't keep backups. Valid values are 'aws', 'minio' and 'azure'", cr.Spec.Volume.Provider)
+	}
+
+	if cr.Spec.Location == "" {
+		return fmt.Errorf("location is missing for SplunkBackup: %s", cr.GetName())
+	}
+
+	return nil
+}
+
+// getSplunkBackupArchiveName returns the name of the archive for a backup taken at the given time, without the suffix
+func getSplunkBackupArchiveName(cr *enterpriseApi.SplunkBackup, backupTime time.Time) string {
+	return fmt.Sprintf("%s-%s-%s", getSplunkBackupType(cr), cr.Spec.Target.Name, backupTime.UTC().Format("20060102150405"))
+}
+
+// getSplunkBackupPathOnPod returns the path of the archive of a backup type on the pod
+func getSplunkBackupPathOnPod(backupType string, archiveFile string) string {
+	if backupType == enterpriseApi.SplunkBackupTypeEtc {
+		return filepath.Join(etcBackupDirOnPod, archiveFile)
+	}
+	return filepath.Join(kvStoreBackupDirOnPod, archiveFile)
+}
+
+// getNextSplunkBackupTime returns the time of the next backup, or zero time when no more backups are due
+func getNextSplunkBackupTime(cr *enterpriseApi.SplunkBackup) time.Time {
+	if cr.Spec.Schedule.Suspend {
+		return time.Time{}
+	}
+	if cr.Status.LastBackupTime == 0 {
+		return time.Unix(0, 0)
+	}
+	if cr.Spec.Schedule.Interval <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(cr.Status.LastBackupTime+cr.Spec.Schedule.Interval, 0)
+}
+
+// isKVStoreBackupRestoreDone checks if the KV store backup or restore is done on the pod. When a file path
+// is given, it must exist as well
+func isKVStoreBackupRestoreDone(ctx context.Context, podExecClient splutil.PodExecClientImpl, podPath string) (bool, error) {
+	command := kvStoreBackupRestoreReadyCmdStr + "; echo -n $?"
+	if podPath != "" {
+		command = fmt.Sprintf("test -f %s && %s", podPath, command)
+	}
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return false, fmt.Errorf("unable to check the KV store status. stdErr: %s, err: %v", stdErr, err)
+	}
+	result, _ := strconv.Atoi(stdOut)
+	return result == 0, nil
+}
+
+// startKVStoreBackup starts the KV store backup on the pod of the target
+func startKVStoreBackup(ctx context.Context, podExecClient splutil.PodExecClientImpl, archiveName string) error {
+	command := fmt.Sprintf(kvStoreBackupCmdStr, archiveName)
+	_, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to start the KV store backup. stdErr: %s, err: %v", stdErr, err)
+	}
+	return nil
+}
+
+// uploadSplunkBackup copies the archive of the backup from the pod, and uploads it to the remote storage
+func uploadSplunkBackup(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, target splcommon.MetaObject, podExecClient splutil.PodExecClientImpl, archive *enterpriseApi.SplunkBackupArchive) error {
+	podPath := getSplunkBackupPathOnPod(getSplunkBackupType(cr), archive.Name)
+	localDir := filepath.Join(splunkBackupStagingDir, cr.GetNamespace(), cr.GetName())
+	err := os.MkdirAll(localDir, 0700)
+	if err != nil {
+		return err
+	}
+	localFile := filepath.Join(localDir, archive.Name)
+	defer os.Remove(localFile)
+
+	// the archive is not kept on the pod, a failed upload is retried with a new backup
+	defer func() {
+		command := fmt.Sprintf("rm -f %s", podPath)
+		_, _, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+		if err != nil {
+			log.FromContext(ctx).Info("Unable to remove the backup from the pod", "pod", archive.Pod, "path", podPath, "error", err.Error())
+		}
+	}()
+
+	err = copyFileFromPod(ctx, client, target, archive.Pod, podPath, localFile)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := os.Stat(localFile)
+	if err != nil {
+		return err
+	}
+	archive.Size = fileInfo.Size()
+
+	err = UploadSplunkBackupCall(ctx, client, cr, localFile, archive.Name)
+	if err != nil {
+		return fmt.Errorf("unable to upload the backup %s. error: %v", archive.Name, err)
+	}
+	return nil
+}
+
+// pruneSplunkBackups deletes the oldest backups beyond the retention count from the remote storage
+func pruneSplunkBackups(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("pruneSplunkBackups").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	for cr.Spec.Retention > 0 && int32(len(cr.Status.Backups)) > cr.Spec.Retention {
+		oldest := cr.Status.Backups[0]
+		err := DeleteSplunkBackupCall(ctx, client, cr, oldest.Name)
+		if err != nil {
+			// deleting the backup is retried after the next backup
+			scopedLog.Error(err, "Unable to delete the backup", "archive", oldest.Name)
+			return
+		}
+		scopedLog.Info("Deleted the backup beyond the retention count", "archive", oldest.Name)
+		cr.Status.Backups = cr.Status.Backups[1:]
+	}
+}
+
+// reconcileSplunkBackup takes the backup once it is due, and returns how long to wait for the next check
+func reconcileSplunkBackup(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup) (time.Duration, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("reconcileSplunkBackup").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	now := time.Now()
+	inProgress := cr.Status.InProgress
+	if inProgress == nil {
+		nextBackupTime := getNextSplunkBackupTime(cr)
+		if nextBackupTime.IsZero() || nextBackupTime.After(now) {
+			cr.Status.Phase = enterpriseApi.PhaseReady
+			cr.Status.Message = ""
+			if nextBackupTime.IsZero() {
+				return 0, nil
+			}
+			return nextBackupTime.Sub(now), nil
+		}
+	}
+
+	backupType := getSplunkBackupType(cr)
+	target, podName, err := getBackupTargetPod(ctx, client, cr.GetNamespace(), backupType, cr.Spec.Target)
+	if err != nil {
+		scopedLog.Info("Waiting for the target to be ready for the backup", "error", err.Error())
+		cr.Status.Phase = enterpriseApi.PhasePending
+		cr.Status.Message = err.Error()
+		return splunkBackupTargetRetryInterval, nil
+	}
+
+	if inProgress == nil {
+		archiveName := getSplunkBackupArchiveName(cr, now)
+		podExecClient := getSplunkBackupPodExecClient(client, target, podName)
+		scopedLog.Info("Starting the backup", "type", backupType, "pod", podName, "archive", archiveName)
+		if backupType == enterpriseApi.SplunkBackupTypeEtc {
+			err = startEtcBackup(ctx, podExecClient, cr.Spec.Target.Kind, getSplunkBackupPathOnPod(backupType, archiveName+splunkBackupArchiveSuffix))
+		} else {
+			err = startKVStoreBackup(ctx, podExecClient, archiveName)
+		}
+		if err != nil {
+			return 0, err
+		}
+		cr.Status.InProgress = &enterpriseApi.SplunkBackupArchive{Name: archiveName + splunkBackupArchiveSuffix, Time: now.Unix(), Pod: podName}
+		cr.Status.Phase = enterpriseApi.PhaseUpdating
+		return splunkBackupPollInterval, nil
+	}
+
+	// the backup is completed on the pod it was started on, even if the captain changed since
+	podExecClient := getSplunkBackupPodExecClient(client, target, inProgress.Pod)
+	var done bool
+	podPath := getSplunkBackupPathOnPod(backupType, inProgress.Name)
+	if backupType == enterpriseApi.SplunkBackupTypeEtc {
+		done, err = isEtcBackupDone(ctx, podExecClient, podPath)
+	} else {
+		done, err = isKVStoreBackupRestoreDone(ctx, podExecClient, podPath)
+	}
+	if err == nil && !done {
+		if now.Sub(time.Unix(inProgress.Time, 0)) < splunkBackupTimeout {
+			return splunkBackupPollInterval, nil
+		}
+		err = fmt.Errorf("backup %s didn't complete within %s", inProgress.Name, splunkBackupTimeout)
+	}
+	if err == nil {
+		err = uploadSplunkBackup(ctx, client, cr, target, podExecClient, inProgress)
+	}
+	if err != nil {
+		// the backup is taken again
+		cr.Status.InProgress = nil
+		return 0, err
+	}
+
+	scopedLog.Info("Backup completed", "type", backupType, "archive", inProgress.Name, "size", inProgress.Size)
+	cr.Status.Backups = append(cr.Status.Backups, *inProgress)
+	cr.Status.LastBackup = inProgress.Name
+	cr.Status.LastBackupTime = inProgress.Time
+	cr.Status.InProgress = nil
+	cr.Status.Phase = enterpriseApi.PhaseReady
+	cr.Status.Message = ""
+	pruneSplunkBackups(ctx, client, cr)
+
+	nextBackupTime := getNextSplunkBackupTime(cr)
+	if nextBackupTime.IsZero() {
+		return 0, nil
+	}
+	return time.Until(nextBackupTime), nil
+}
+
+// ApplySplunkBackup takes the backups of the SplunkBackup on its schedule
+func ApplySplunkBackup(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup) (reconcile.Result, error) {
+	result := reconcile.Result{}
+
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("ApplySplunkBackup").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	cr.Kind = "SplunkBackup"
+
+	oldStatus := cr.Status.DeepCopy()
+
+	var requeueAfter time.Duration
+	err := validateSplunkBackupSpec(ctx, cr)
+	if err == nil {
+		requeueAfter, err = reconcileSplunkBackup(ctx, client, cr)
+	}
+
+	if err != nil {
+		scopedLog.Error(err, "Backup failed")
+		cr.Status.Phase = enterpriseApi.PhaseError
+		cr.Status.Message = err.Error()
+	}
+
+	if requeueAfter > 0 {
+		result.Requeue = true
+		result.RequeueAfter = requeueAfter
+	}
+
+	if !reflect.DeepEqual(*oldStatus, cr.Status) {
+		updateErr := client.Status().Update(ctx, cr)
+		if updateErr != nil {
+			scopedLog.Error(updateErr, "status update failed")
+			return result, updateErr
+		}
+	}
+
+	return result, err
+}
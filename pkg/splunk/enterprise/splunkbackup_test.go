@@ -0,0 +1,257 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getTestSplunkBackup() *enterpriseApi.SplunkBackup {
+	return &enterpriseApi.SplunkBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup1",
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.SplunkBackupSpec{
+			Target: enterpriseApi.BackupTargetReference{Kind: "Standalone", Name: "stack1"},
+			Volume: enterpriseApi.VolumeSpec{
+				Name:      "backups",
+				Endpoint:  "https://s3-us-west-2.amazonaws.com",
+				Path:      "testbucket/splunk",
+				SecretRef: "s3-secret",
+				Type:      "s3",
+				Provider:  "aws",
+			},
+			Location: "kvstore",
+			Schedule: enterpriseApi.SplunkBackupSchedule{Interval: 3600},
+		},
+	}
+}
+
+func TestValidateSplunkBackupSpec(t *testing.T) {
+	ctx := context.TODO()
+	cr := getTestSplunkBackup()
+	if err := validateSplunkBackupSpec(ctx, cr); err != nil {
+		t.Errorf("SplunkBackup should be valid. error: %v", err)
+	}
+
+	cr.Spec.Target.Kind = "IndexerCluster"
+	if err := validateSplunkBackupSpec(ctx, cr); err == nil {
+		t.Errorf("only a SearchHeadCluster or a Standalone should be backed up")
+	}
+	cr.Spec.Target.Kind = "SearchHeadCluster"
+
+	cr.Spec.Volume.Type = "git"
+	cr.Spec.Volume.Provider = "git"
+	if err := validateSplunkBackupSpec(ctx, cr); err == nil {
+		t.Errorf("backups should not be kept on a git volume")
+	}
+	cr.Spec.Volume.Type = "blob"
+	cr.Spec.Volume.Provider = "azure"
+
+	cr.Spec.Location = ""
+	if err := validateSplunkBackupSpec(ctx, cr); err == nil {
+		t.Errorf("location should be required")
+	}
+}
+
+func TestGetSplunkBackupRemoteFile(t *testing.T) {
+	cr := getTestSplunkBackup()
+	got := getSplunkBackupRemoteFile(cr, "kvstore-stack1-20221015000000.tar.gz")
+	if got != "splunk/kvstore/kvstore-stack1-20221015000000.tar.gz" {
+		t.Errorf("getSplunkBackupRemoteFile() = %s; want splunk/kvstore/kvstore-stack1-20221015000000.tar.gz", got)
+	}
+}
+
+func TestCheckCopiedFileSize(t *testing.T) {
+	localFile := filepath.Join(t.TempDir(), "kvstore-stack1-20221015000000.tar.gz")
+	if err := checkCopiedFileSize(localFile, 8); err == nil {
+		t.Errorf("missing copy should fail the check")
+	}
+
+	os.WriteFile(localFile, []byte("backup"), 0600)
+	if err := checkCopiedFileSize(localFile, 8); err == nil || !strings.Contains(err.Error(), "copied 6 bytes of 8") {
+		t.Errorf("incomplete copy should fail the check. error: %v", err)
+	}
+	if err := checkCopiedFileSize(localFile, 6); err != nil {
+		t.Errorf("complete copy should pass the check. error: %v", err)
+	}
+}
+
+func TestGetNextSplunkBackupTime(t *testing.T) {
+	cr := getTestSplunkBackup()
+	if !getNextSplunkBackupTime(cr).Equal(time.Unix(0, 0)) {
+		t.Errorf("first backup should be due right away")
+	}
+
+	cr.Status.LastBackupTime = 1000
+	if !getNextSplunkBackupTime(cr).Equal(time.Unix(4600, 0)) {
+		t.Errorf("next backup should be due after the interval. got: %v", getNextSplunkBackupTime(cr))
+	}
+
+	cr.Spec.Schedule.Interval = 0
+	if !getNextSplunkBackupTime(cr).IsZero() {
+		t.Errorf("a single backup should be taken without an interval")
+	}
+
+	cr.Spec.Schedule.Interval = 3600
+	cr.Spec.Schedule.Suspend = true
+	if !getNextSplunkBackupTime(cr).IsZero() {
+		t.Errorf("no backup should be taken, when the schedule is suspended")
+	}
+}
+
+func TestApplySplunkBackup(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	cr := getTestSplunkBackup()
+	cr.Spec.Retention = 2
+	cr.Status.Backups = []enterpriseApi.SplunkBackupArchive{{Name: "kvstore-stack1-1.tar.gz"}, {Name: "kvstore-stack1-2.tar.gz"}}
+	err := c.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("SplunkBackup should be created. error: %v", err)
+	}
+
+	savedStagingDir := splunkBackupStagingDir
+	savedGetPodExecClient := getSplunkBackupPodExecClient
+	savedCopyFileFromPod := copyFileFromPod
+	savedUploadCall := UploadSplunkBackupCall
+	savedDeleteCall := DeleteSplunkBackupCall
+	defer func() {
+		splunkBackupStagingDir = savedStagingDir
+		getSplunkBackupPodExecClient = savedGetPodExecClient
+		copyFileFromPod = savedCopyFileFromPod
+		UploadSplunkBackupCall = savedUploadCall
+		DeleteSplunkBackupCall = savedDeleteCall
+	}()
+	splunkBackupStagingDir = t.TempDir()
+
+	podExecClient := &spltest.MockPodExecClient{}
+	statusContext := &spltest.MockPodExecReturnContext{StdOut: "1"}
+	podExecClient.AddMockPodExecReturnContext(ctx, "backup kvstore", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "kvstore-status", statusContext)
+	podExecClient.AddMockPodExecReturnContext(ctx, "rm -f", &spltest.MockPodExecReturnContext{})
+	var podNames []string
+	getSplunkBackupPodExecClient = func(client splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+		podNames = append(podNames, podName)
+		return podExecClient
+	}
+	copyFileFromPod = func(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, podName string, podPath string, localFile string) error {
+		return os.WriteFile(localFile, []byte("archive"), 0600)
+	}
+	var uploads, deletes []string
+	UploadSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, localFile string, archiveName string) error {
+		data, err := os.ReadFile(localFile)
+		uploads = append(uploads, fmt.Sprintf("%s=%s", archiveName, data))
+		return err
+	}
+	DeleteSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, archiveName string) error {
+		deletes = append(deletes, archiveName)
+		return nil
+	}
+
+	// waits for the standalone
+	result, err := ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhasePending || result.RequeueAfter != splunkBackupTargetRetryInterval {
+		t.Errorf("backup should wait for the standalone. phase: %s, result: %v, error: %v", cr.Status.Phase, result, err)
+	}
+
+	standalone := &enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	err = c.Create(ctx, standalone)
+	if err != nil {
+		t.Fatalf("Standalone should be created. error: %v", err)
+	}
+	standalone.Status.Phase = enterpriseApi.PhaseReady
+	err = c.Status().Update(ctx, standalone)
+	if err != nil {
+		t.Fatalf("Standalone status should be updated. error: %v", err)
+	}
+
+	// backup is started on the standalone pod
+	result, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhaseUpdating || cr.Status.InProgress == nil || result.RequeueAfter != splunkBackupPollInterval {
+		t.Errorf("backup should be started. status: %v, result: %v, error: %v", cr.Status, result, err)
+	}
+	if podNames[0] != "splunk-stack1-standalone-0" || !strings.HasPrefix(cr.Status.InProgress.Name, "kvstore-stack1-") {
+		t.Errorf("backup should be taken on the standalone pod. pods: %v, status: %v", podNames, cr.Status.InProgress)
+	}
+	archiveName := cr.Status.InProgress.Name
+
+	// waits for the backup to complete
+	_, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.InProgress == nil || len(uploads) != 0 {
+		t.Errorf("backup should be in progress. status: %v, error: %v", cr.Status, err)
+	}
+
+	// backup is uploaded, and the oldest backup is deleted beyond the retention count
+	statusContext.StdOut = "0"
+	result, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhaseReady || cr.Status.InProgress != nil || cr.Status.LastBackup != archiveName {
+		t.Errorf("backup should be completed. status: %v, error: %v", cr.Status, err)
+	}
+	if len(uploads) != 1 || uploads[0] != archiveName+"=archive" || cr.Status.Backups[len(cr.Status.Backups)-1].Size != int64(len("archive")) {
+		t.Errorf("backup should be uploaded. uploads: %v", uploads)
+	}
+	if len(deletes) != 1 || deletes[0] != "kvstore-stack1-1.tar.gz" || len(cr.Status.Backups) != 2 {
+		t.Errorf("oldest backup should be deleted. deletes: %v, backups: %v", deletes, cr.Status.Backups)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour {
+		t.Errorf("next backup should be scheduled after the interval. result: %v", result)
+	}
+	wantCmds := []string{"backup kvstore", "kvstore-status", "rm -f"}
+	if strings.Join(podExecClient.GotCmdList, ",") != strings.Join(wantCmds, ",") {
+		t.Errorf("unexpected pod exec commands. got: %v, want: %v", podExecClient.GotCmdList, wantCmds)
+	}
+
+	// no backup is due
+	_, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.InProgress != nil {
+		t.Errorf("no backup should be started. status: %v, error: %v", cr.Status, err)
+	}
+
+	// upload errors are reported, and the backup is taken again
+	cr.Status.LastBackupTime = 0
+	UploadSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, localFile string, archiveName string) error {
+		return fmt.Errorf("access denied")
+	}
+	_, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.InProgress == nil {
+		t.Errorf("backup should be started. status: %v, error: %v", cr.Status, err)
+	}
+	_, err = ApplySplunkBackup(ctx, c, cr)
+	if err == nil || cr.Status.Phase != enterpriseApi.PhaseError || cr.Status.InProgress != nil {
+		t.Errorf("upload error should be reported. status: %v, error: %v", cr.Status, err)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// An etc snapshot keeps the apps distributed by a cluster manager or a search head cluster deployer, along with
+// their local configuration, which would be lost with the PVC of the pod. The directories are archived with tar on the
+// pod, and the archive is uploaded like a KV store backup. A snapshot is restored by extracting it on a fresh cluster
+// manager or deployer, and restarting splunk. The target is annotated with the SplunkRestore for the restore, and the
+// peers of the indexer cluster, or the search heads, are not created or updated till the restore is done, so that they
+// connect to the restored configuration. The indexer cluster is kept in maintenance mode while the cluster manager is
+// restored, in case the peers are already running.
+
+// etcBackupDirs are the directories under /opt/splunk kept in the etc snapshots of each kind. etc/auth holds the
+// splunk.secret which the passwords of the local configuration are encrypted with
+var etcBackupDirs = map[string][]string{
+	"ClusterManager":    {"etc/manager-apps", "etc/system/local", "etc/auth"},
+	"SearchHeadCluster": {"etc/shcluster/apps", "etc/system/local", "etc/auth"},
+}
+
+// getSplunkBackupType returns the type of the backups of the SplunkBackup
+func getSplunkBackupType(cr *enterpriseApi.SplunkBackup) string {
+	if cr.Spec.Type == "" {
+		return enterpriseApi.SplunkBackupTypeKVStore
+	}
+	return cr.Spec.Type
+}
+
+// getEtcBackupTargetPod returns the CR whose configuration is backed up or restored, and the pod running the etc
+// snapshot or restore: the active cluster manager of a ClusterManager, or the deployer of a SearchHeadCluster
+func getEtcBackupTargetPod(ctx context.Context, client splcommon.ControllerClient, namespace string, target enterpriseApi.BackupTargetReference) (splcommon.MetaObject, string, error) {
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: target.Name}
+	switch target.Kind {
+	case "ClusterManager":
+		cr := &enterpriseApi.ClusterManager{}
+		err := client.Get(ctx, namespacedName, cr)
+		if err != nil {
+			return nil, "", err
+		}
+		if cr.Status.Phase != enterpriseApi.PhaseReady {
+			return nil, "", fmt.Errorf("cluster manager %s is not ready", target.Name)
+		}
+		return cr, getActiveClusterManagerPodName(cr), nil
+	case "SearchHeadCluster":
+		cr := &enterpriseApi.SearchHeadCluster{}
+		err := client.Get(ctx, namespacedName, cr)
+		if err != nil {
+			return nil, "", err
+		}
+		if cr.Status.DeployerPhase != enterpriseApi.PhaseReady {
+			return nil, "", fmt.Errorf("deployer of search head cluster %s is not ready", target.Name)
+		}
+		return cr, GetSplunkStatefulsetPodName(SplunkDeployer, target.Name, 0), nil
+	default:
+		return nil, "", fmt.Errorf("kind %s has no etc snapshot", target.Kind)
+	}
+}
+
+// getEtcRestoreTarget returns the ClusterManager or the SearchHeadCluster an etc snapshot is restored into
+func getEtcRestoreTarget(ctx context.Context, client splcommon.ControllerClient, namespace string, target enterpriseApi.BackupTargetReference) (splcommon.MetaObject, error) {
+	var cr splcommon.MetaObject
+	switch target.Kind {
+	case "ClusterManager":
+		cr = &enterpriseApi.ClusterManager{}
+	case "SearchHeadCluster":
+		cr = &enterpriseApi.SearchHeadCluster{}
+	default:
+		return nil, fmt.Errorf("kind %s has no etc snapshot", target.Kind)
+	}
+	err := client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: target.Name}, cr)
+	return cr, err
+}
+
+// setEtcRestorePending annotates the target with the SplunkRestore, or removes the annotation once the restore is done
+func setEtcRestorePending(ctx context.Context, client splcommon.ControllerClient, restore *enterpriseApi.SplunkRestore, pending bool) error {
+	cr, err := getEtcRestoreTarget(ctx, client, restore.GetNamespace(), restore.Spec.Target)
+	if err != nil {
+		if !pending && k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	annotations := cr.GetAnnotations()
+	// only the annotation of this SplunkRestore is removed
+	if (annotations[enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation] == restore.GetName()) == pending {
+		return nil
+	}
+
+	if pending {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation] = restore.GetName()
+	} else {
+		delete(annotations, enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation)
+	}
+	cr.SetAnnotations(annotations)
+	return client.Update(ctx, cr)
+}
+
+// isEtcRestorePending checks if an etc snapshot is being restored into the cluster manager or the deployer of the CR.
+// The annotation is ignored once its SplunkRestore is completed or deleted
+func isEtcRestorePending(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject) (bool, error) {
+	restoreName, ok := cr.GetAnnotations()[enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation]
+	if !ok {
+		return false, nil
+	}
+
+	restore := &enterpriseApi.SplunkRestore{}
+	err := client.Get(ctx, types.NamespacedName{Namespace: cr.GetNamespace(), Name: restoreName}, restore)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			log.FromContext(ctx).Info("Ignoring the pending etc restore, as the SplunkRestore is not found", "name", cr.GetName(), "splunkRestore", restoreName)
+			return false, nil
+		}
+		return false, err
+	}
+	return restore.Status.RestoreTime == 0, nil
+}
+
+// startEtcBackup archives the etc directories of the kind into the file on the pod. Unlike a KV store backup, the
+// archive is complete when the command returns
+func startEtcBackup(ctx context.Context, podExecClient splutil.PodExecClientImpl, kind string, podPath string) error {
+	command := fmt.Sprintf(etcBackupCmdStr, podPath, strings.Join(etcBackupDirs[kind], " "))
+	_, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to take the etc snapshot. stdErr: %s, err: %v", stdErr, err)
+	}
+	return nil
+}
+
+// isEtcBackupDone checks if the etc snapshot is on the pod
+func isEtcBackupDone(ctx context.Context, podExecClient splutil.PodExecClientImpl, podPath string) (bool, error) {
+	command := fmt.Sprintf("test -f %s; echo -n $?", podPath)
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return false, fmt.Errorf("unable to check the etc snapshot. stdErr: %s, err: %v", stdErr, err)
+	}
+	result, _ := strconv.Atoi(stdOut)
+	return result == 0, nil
+}
+
+// startEtcRestore extracts the etc snapshot on the pod, and restarts splunk. The restore is complete when the command returns
+func startEtcRestore(ctx context.Context, podExecClient splutil.PodExecClientImpl, podPath string) error {
+	command := fmt.Sprintf(etcRestoreCmdStr, podPath)
+	// splunk restart logs its progress on stderr, so, rely only on the exit status
+	_, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to restore the etc snapshot. stdErr: %s, err: %v", stdErr, err)
+	}
+	return nil
+}
+
+// setIdxcMaintenanceMode puts the indexer cluster of the cluster manager in maintenance mode, or takes it out of it
+func setIdxcMaintenanceMode(ctx context.Context, podExecClient splutil.PodExecClientImpl, enable bool) error {
+	command := idxcDisableMaintenanceCmdStr
+	if enable {
+		command = idxcEnableMaintenanceCmdStr
+	}
+	_, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to change the indexer cluster maintenance mode to %t. stdErr: %s, err: %v", enable, stdErr, err)
+	}
+	return nil
+}
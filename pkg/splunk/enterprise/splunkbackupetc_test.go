@@ -0,0 +1,340 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateEtcBackupTarget(t *testing.T) {
+	ctx := context.TODO()
+	cr := getTestSplunkBackup()
+	cr.Spec.Type = enterpriseApi.SplunkBackupTypeEtc
+	cr.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "ClusterManager", Name: "cm"}
+	if err := validateSplunkBackupSpec(ctx, cr); err != nil {
+		t.Errorf("etc snapshot of a cluster manager should be valid. error: %v", err)
+	}
+
+	cr.Spec.Target.Kind = "SearchHeadCluster"
+	if err := validateSplunkBackupSpec(ctx, cr); err != nil {
+		t.Errorf("etc snapshot of a deployer should be valid. error: %v", err)
+	}
+
+	cr.Spec.Target.Kind = "Standalone"
+	if err := validateSplunkBackupSpec(ctx, cr); err == nil {
+		t.Errorf("etc snapshot of a standalone should not be valid")
+	}
+
+	cr.Spec.Type = ""
+	cr.Spec.Target.Kind = "ClusterManager"
+	if err := validateSplunkBackupSpec(ctx, cr); err == nil {
+		t.Errorf("KV store backup of a cluster manager should not be valid")
+	}
+}
+
+func TestGetEtcBackupTargetPod(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	cm := &enterpriseApi.ClusterManager{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "test"}}
+	shc := &enterpriseApi.SearchHeadCluster{ObjectMeta: metav1.ObjectMeta{Name: "shc1", Namespace: "test"}}
+	for _, cr := range []splcommon.MetaObject{cm, shc} {
+		err := c.Create(ctx, cr)
+		if err != nil {
+			t.Fatalf("CR should be created. error: %v", err)
+		}
+	}
+
+	cmTarget := enterpriseApi.BackupTargetReference{Kind: "ClusterManager", Name: "cm"}
+	_, _, err := getEtcBackupTargetPod(ctx, c, "test", cmTarget)
+	if err == nil {
+		t.Errorf("cluster manager should not be ready")
+	}
+
+	cm.Status.Phase = enterpriseApi.PhaseReady
+	err = c.Status().Update(ctx, cm)
+	if err != nil {
+		t.Fatalf("ClusterManager status should be updated. error: %v", err)
+	}
+	_, podName, err := getEtcBackupTargetPod(ctx, c, "test", cmTarget)
+	if err != nil || podName != "splunk-cm-cluster-manager-0" {
+		t.Errorf("etc snapshot should be taken on the cluster manager. pod: %s, error: %v", podName, err)
+	}
+
+	// the active one of redundant cluster managers
+	cm.Status.ActiveManager = "splunk-cm-cluster-manager-1"
+	err = c.Status().Update(ctx, cm)
+	if err != nil {
+		t.Fatalf("ClusterManager status should be updated. error: %v", err)
+	}
+	_, podName, err = getEtcBackupTargetPod(ctx, c, "test", cmTarget)
+	if err != nil || podName != "splunk-cm-cluster-manager-1" {
+		t.Errorf("etc snapshot should be taken on the active cluster manager. pod: %s, error: %v", podName, err)
+	}
+
+	shcTarget := enterpriseApi.BackupTargetReference{Kind: "SearchHeadCluster", Name: "shc1"}
+	_, _, err = getEtcBackupTargetPod(ctx, c, "test", shcTarget)
+	if err == nil {
+		t.Errorf("deployer should not be ready")
+	}
+
+	shc.Status.DeployerPhase = enterpriseApi.PhaseReady
+	err = c.Status().Update(ctx, shc)
+	if err != nil {
+		t.Fatalf("SearchHeadCluster status should be updated. error: %v", err)
+	}
+	_, podName, err = getEtcBackupTargetPod(ctx, c, "test", shcTarget)
+	if err != nil || podName != "splunk-shc1-deployer-0" {
+		t.Errorf("etc snapshot should be taken on the deployer. pod: %s, error: %v", podName, err)
+	}
+}
+
+func TestApplySplunkBackupEtc(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	shc := &enterpriseApi.SearchHeadCluster{ObjectMeta: metav1.ObjectMeta{Name: "shc1", Namespace: "test"}}
+	err := c.Create(ctx, shc)
+	if err != nil {
+		t.Fatalf("SearchHeadCluster should be created. error: %v", err)
+	}
+	shc.Status.DeployerPhase = enterpriseApi.PhaseReady
+	err = c.Status().Update(ctx, shc)
+	if err != nil {
+		t.Fatalf("SearchHeadCluster status should be updated. error: %v", err)
+	}
+
+	cr := getTestSplunkBackup()
+	cr.Spec.Type = enterpriseApi.SplunkBackupTypeEtc
+	cr.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "SearchHeadCluster", Name: "shc1"}
+	err = c.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("SplunkBackup should be created. error: %v", err)
+	}
+
+	savedStagingDir := splunkBackupStagingDir
+	savedGetPodExecClient := getSplunkBackupPodExecClient
+	savedCopyFileFromPod := copyFileFromPod
+	savedUploadCall := UploadSplunkBackupCall
+	defer func() {
+		splunkBackupStagingDir = savedStagingDir
+		getSplunkBackupPodExecClient = savedGetPodExecClient
+		copyFileFromPod = savedCopyFileFromPod
+		UploadSplunkBackupCall = savedUploadCall
+	}()
+	splunkBackupStagingDir = t.TempDir()
+
+	podExecClient := &spltest.MockPodExecClient{}
+	podExecClient.AddMockPodExecReturnContext(ctx, "tar -czf", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "test -f", &spltest.MockPodExecReturnContext{StdOut: "0"})
+	podExecClient.AddMockPodExecReturnContext(ctx, "rm -f", &spltest.MockPodExecReturnContext{})
+	var podNames []string
+	getSplunkBackupPodExecClient = func(client splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+		podNames = append(podNames, podName)
+		return podExecClient
+	}
+	var podPaths []string
+	copyFileFromPod = func(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, podName string, podPath string, localFile string) error {
+		podPaths = append(podPaths, podPath)
+		return os.WriteFile(localFile, []byte("snapshot"), 0600)
+	}
+	var uploads []string
+	UploadSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, localFile string, archiveName string) error {
+		uploads = append(uploads, archiveName)
+		return nil
+	}
+
+	// the deployer etc directories are archived
+	_, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.InProgress == nil || !strings.HasPrefix(cr.Status.InProgress.Name, "etc-shc1-") || podNames[0] != "splunk-shc1-deployer-0" {
+		t.Errorf("etc snapshot should be started on the deployer. status: %v, pods: %v, error: %v", cr.Status, podNames, err)
+	}
+	archiveName := cr.Status.InProgress.Name
+
+	// the snapshot is uploaded
+	_, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhaseReady || len(uploads) != 1 || uploads[0] != archiveName || cr.Status.LastBackup != archiveName {
+		t.Errorf("etc snapshot should be uploaded. status: %v, uploads: %v, error: %v", cr.Status, uploads, err)
+	}
+	if len(podPaths) != 1 || podPaths[0] != "/opt/splunk/var/lib/splunk/etcbackup/"+archiveName {
+		t.Errorf("etc snapshot should be copied from the pod. paths: %v", podPaths)
+	}
+	wantCmds := []string{"tar -czf", "test -f", "rm -f"}
+	if strings.Join(podExecClient.GotCmdList, ",") != strings.Join(wantCmds, ",") {
+		t.Errorf("unexpected pod exec commands. got: %v, want: %v", podExecClient.GotCmdList, wantCmds)
+	}
+}
+
+func TestApplySplunkRestoreEtc(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	cm := &enterpriseApi.ClusterManager{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "test"}}
+	err := c.Create(ctx, cm)
+	if err != nil {
+		t.Fatalf("ClusterManager should be created. error: %v", err)
+	}
+	cm.Status.Phase = enterpriseApi.PhaseReady
+	err = c.Status().Update(ctx, cm)
+	if err != nil {
+		t.Fatalf("ClusterManager status should be updated. error: %v", err)
+	}
+
+	backup := getTestSplunkBackup()
+	backup.Spec.Type = enterpriseApi.SplunkBackupTypeEtc
+	backup.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "ClusterManager", Name: "cm"}
+	backup.Status.Backups = []enterpriseApi.SplunkBackupArchive{{Name: "etc-cm-1.tar.gz"}}
+	backup.Status.LastBackup = "etc-cm-1.tar.gz"
+	err = c.Create(ctx, backup)
+	if err != nil {
+		t.Fatalf("SplunkBackup should be created. error: %v", err)
+	}
+
+	cr := getTestSplunkRestore()
+	cr.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "Standalone", Name: "stack1"}
+	err = c.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("SplunkRestore should be created. error: %v", err)
+	}
+
+	savedStagingDir := splunkBackupStagingDir
+	savedGetPodExecClient := getSplunkBackupPodExecClient
+	savedDownloadCall := DownloadSplunkBackupCall
+	defer func() {
+		splunkBackupStagingDir = savedStagingDir
+		getSplunkBackupPodExecClient = savedGetPodExecClient
+		DownloadSplunkBackupCall = savedDownloadCall
+	}()
+	splunkBackupStagingDir = t.TempDir()
+
+	podExecClient := &streamPodExecClient{}
+	digest := sha256.Sum256([]byte("snapshot"))
+	podExecClient.AddMockPodExecReturnContext(ctx, "mkdir -p", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "sha256sum", &spltest.MockPodExecReturnContext{StdOut: hex.EncodeToString(digest[:]) + "  etc-cm-1.tar.gz\n"})
+	podExecClient.AddMockPodExecReturnContext(ctx, "enable maintenance-mode", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "tar -xzf", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "disable maintenance-mode", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "rm -f", &spltest.MockPodExecReturnContext{})
+	getSplunkBackupPodExecClient = func(client splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+		return podExecClient
+	}
+	DownloadSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, backup *enterpriseApi.SplunkBackup, archiveName string, localFile string) error {
+		return os.WriteFile(localFile, []byte("snapshot"), 0600)
+	}
+
+	// an etc snapshot of a cluster manager can't be restored into a standalone
+	_, err = ApplySplunkRestore(ctx, c, cr)
+	if err == nil || cr.Status.Phase != enterpriseApi.PhaseError {
+		t.Errorf("etc snapshot should not be restored into a standalone. status: %v", cr.Status)
+	}
+
+	// the snapshot is extracted on the cluster manager, while the indexer cluster is in maintenance mode
+	cr.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "ClusterManager", Name: "cm"}
+	_, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Type != enterpriseApi.SplunkBackupTypeEtc || !cr.Status.MaintenanceMode || cr.Status.Pod != "splunk-cm-cluster-manager-0" {
+		t.Errorf("etc snapshot should be restored. status: %v, error: %v", cr.Status, err)
+	}
+	if podExecClient.streamed.String() != "snapshot" {
+		t.Errorf("etc snapshot should be copied to the cluster manager. streamed: %s", podExecClient.streamed.String())
+	}
+
+	// the peers wait for the restore of the cluster manager
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "cm"}, cm)
+	if err != nil || cm.GetAnnotations()[enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation] != cr.GetName() {
+		t.Errorf("cluster manager should be annotated with the pending restore. annotations: %v, error: %v", cm.GetAnnotations(), err)
+	}
+	pending, err := isEtcRestorePending(ctx, c, cm)
+	if err != nil || !pending {
+		t.Errorf("etc restore should be pending. error: %v", err)
+	}
+
+	// maintenance mode is disabled once the cluster manager is restarted
+	_, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhaseReady || cr.Status.MaintenanceMode || cr.Status.RestoreTime == 0 {
+		t.Errorf("etc restore should be completed. status: %v, error: %v", cr.Status, err)
+	}
+	wantCmds := []string{"mkdir -p", "dd of=/opt/splunk/var/lib/splunk/etcbackup/etc-cm-1.tar.gz bs=1M", "sha256sum", "enable maintenance-mode",
+		"tar -xzf", "disable maintenance-mode", "rm -f"}
+	if strings.Join(podExecClient.GotCmdList, ",") != strings.Join(wantCmds, ",") {
+		t.Errorf("unexpected pod exec commands. got: %v, want: %v", podExecClient.GotCmdList, wantCmds)
+	}
+
+	// the annotation is removed once the restore is done
+	cm = &enterpriseApi.ClusterManager{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "cm"}, cm)
+	if _, ok := cm.GetAnnotations()[enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation]; err != nil || ok {
+		t.Errorf("pending restore annotation should be removed. annotations: %v, error: %v", cm.GetAnnotations(), err)
+	}
+}
+
+func TestIsEtcRestorePending(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	shc := &enterpriseApi.SearchHeadCluster{ObjectMeta: metav1.ObjectMeta{Name: "shc1", Namespace: "test"}}
+	pending, err := isEtcRestorePending(ctx, c, shc)
+	if err != nil || pending {
+		t.Errorf("etc restore should not be pending without the annotation. error: %v", err)
+	}
+
+	// the annotation of a deleted SplunkRestore is ignored
+	shc.SetAnnotations(map[string]string{enterpriseApi.SplunkRestorePendingEtcRestoreAnnotation: "restore1"})
+	pending, err = isEtcRestorePending(ctx, c, shc)
+	if err != nil || pending {
+		t.Errorf("etc restore should not be pending without the SplunkRestore. error: %v", err)
+	}
+
+	restore := getTestSplunkRestore()
+	restore.Name = "restore1"
+	err = c.Create(ctx, restore)
+	if err != nil {
+		t.Fatalf("SplunkRestore should be created. error: %v", err)
+	}
+	pending, err = isEtcRestorePending(ctx, c, shc)
+	if err != nil || !pending {
+		t.Errorf("etc restore should be pending till the SplunkRestore is completed. error: %v", err)
+	}
+
+	// the annotation of a completed SplunkRestore is ignored
+	restore.Status.RestoreTime = 1
+	err = c.Status().Update(ctx, restore)
+	if err != nil {
+		t.Fatalf("SplunkRestore status should be updated. error: %v", err)
+	}
+	pending, err = isEtcRestorePending(ctx, c, shc)
+	if err != nil || pending {
+		t.Errorf("etc restore should not be pending once the SplunkRestore is completed. error: %v", err)
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// A SplunkRestore restores one of the KV store backups of a SplunkBackup into a SearchHeadCluster or a Standalone, once.
+// The archive is downloaded from the remote storage to the operator, and streamed to the KV store backup directory on
+// the captain of the search head cluster, or on the standalone pod. The KV store of a search head cluster is put in
+// maintenance mode for the restore, and taken out of it once the restore is done. An etc snapshot is restored into the
+// cluster manager of a ClusterManager, which indexer cluster is kept in maintenance mode, or into the deployer of a
+// SearchHeadCluster.
+
+// DownloadSplunkBackupCall used in mocking this function
+var DownloadSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, backup *enterpriseApi.SplunkBackup, archiveName string, localFile string) error {
+	remoteDataClientMgr := getSplunkBackupRemoteDataClientMgr(ctx, client, backup)
+	remoteFile := getSplunkBackupRemoteFile(backup, archiveName)
+
+	// the archive is downloaded with the etag from the listing. The remote data clients page through the listing, so
+	// the archive is found among any number of backups
+	response, err := remoteDataClientMgr.GetAppsList(ctx)
+	if err != nil {
+		return err
+	}
+	for _, object := range response.Objects {
+		if object.Key != nil && *object.Key == remoteFile && object.Etag != nil {
+			return remoteDataClientMgr.DownloadApp(ctx, remoteFile, localFile, *object.Etag, 0)
+		}
+	}
+	return fmt.Errorf("backup %s is not found on the remote storage", remoteFile)
+}
+
+// getSplunkRestoreArchive returns the SplunkBackup of the SplunkRestore, and the name of the archive to restore
+func getSplunkRestoreArchive(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore) (*enterpriseApi.SplunkBackup, string, error) {
+	backup := &enterpriseApi.SplunkBackup{}
+	err := client.Get(ctx, types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.Spec.Backup}, backup)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to get SplunkBackup %s. %s", cr.Spec.Backup, err)
+	}
+
+	archiveName := cr.Spec.Archive
+	if archiveName == "" {
+		archiveName = backup.Status.LastBackup
+	}
+	for _, archive := range backup.Status.Backups {
+		if archive.Name == archiveName {
+			return backup, archiveName, nil
+		}
+	}
+	return nil, "", fmt.Errorf("SplunkBackup %s has no backup %s", cr.Spec.Backup, archiveName)
+}
+
+// validateSplunkRestoreSpec validates the SplunkRestore spec. The kind of the target is validated against the type of
+// the SplunkBackup, when the restore starts
+func validateSplunkRestoreSpec(cr *enterpriseApi.SplunkRestore) error {
+	if cr.Spec.Target.Name == "" {
+		return fmt.Errorf("target name is missing")
+	}
+
+	if cr.Spec.Backup == "" {
+		return fmt.Errorf("backup is missing for SplunkRestore: %s", cr.GetName())
+	}
+
+	return nil
+}
+
+// copySplunkBackupToPod downloads the archive from the remote storage, and streams it to the backup directory on the pod
+func copySplunkBackupToPod(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore, backup *enterpriseApi.SplunkBackup, archiveName string, podExecClient splutil.PodExecClientImpl) error {
+	localDir := filepath.Join(splunkBackupStagingDir, cr.GetNamespace(), cr.GetName())
+	err := os.MkdirAll(localDir, 0700)
+	if err != nil {
+		return err
+	}
+	localFile := filepath.Join(localDir, archiveName)
+	defer os.Remove(localFile)
+
+	err = DownloadSplunkBackupCall(ctx, client, backup, archiveName, localFile)
+	if err != nil {
+		return fmt.Errorf("unable to download the backup %s. error: %v", archiveName, err)
+	}
+
+	file, err := os.Open(localFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	podPath := getSplunkBackupPathOnPod(getSplunkBackupType(backup), archiveName)
+	command := fmt.Sprintf("mkdir -p %s", filepath.Dir(podPath))
+	_, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to create the backup directory on the pod. stdErr: %s, err: %v", stdErr, err)
+	}
+
+	hash := sha256.New()
+	streamOptions := splutil.NewStreamOptionsObject("")
+	streamOptions.Stdin = io.TeeReader(file, hash)
+	_, stdErr, err = podExecClient.RunPodExecCommand(ctx, streamOptions, []string{"dd", "of=" + podPath, "bs=1M"})
+	if err != nil {
+		return fmt.Errorf("unable to copy the backup %s to the pod. stdErr: %s, err: %v", archiveName, stdErr, err)
+	}
+
+	// the archive is verified on the pod, before it is extracted
+	command = fmt.Sprintf("sha256sum %s", podPath)
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to verify the backup %s on the pod. stdErr: %s, err: %v", archiveName, stdErr, err)
+	}
+	fields := strings.Fields(stdOut)
+	expectedDigest := hex.EncodeToString(hash.Sum(nil))
+	if len(fields) == 0 || fields[0] != expectedDigest {
+		return fmt.Errorf("checksum mismatch for the backup %s copied to the pod. expected: %s, got: %s", archiveName, expectedDigest, stdOut)
+	}
+	return nil
+}
+
+// setKVStoreMaintenanceMode puts the KV store of the search head cluster in maintenance mode, or takes it out of it
+func setKVStoreMaintenanceMode(ctx context.Context, podExecClient splutil.PodExecClientImpl, enable bool) error {
+	command := kvStoreDisableMaintenanceCmdStr
+	if enable {
+		command = kvStoreEnableMaintenanceCmdStr
+	}
+	_, stdErr, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to change the KV store maintenance mode to %t. stdErr: %s, err: %v", enable, stdErr, err)
+	}
+	return nil
+}
+
+// isSplunkRestoreMaintenanceMode checks if the target is put in maintenance mode for the restore: the KV store of a
+// search head cluster, or the indexer cluster of a cluster manager
+func isSplunkRestoreMaintenanceMode(backupType string, kind string) bool {
+	if backupType == enterpriseApi.SplunkBackupTypeEtc {
+		return kind == "ClusterManager"
+	}
+	return kind == "SearchHeadCluster"
+}
+
+// setSplunkRestoreMaintenanceMode puts the target of the restore in maintenance mode, or takes it out of it
+func setSplunkRestoreMaintenanceMode(ctx context.Context, podExecClient splutil.PodExecClientImpl, backupType string, enable bool) error {
+	if backupType == enterpriseApi.SplunkBackupTypeEtc {
+		return setIdxcMaintenanceMode(ctx, podExecClient, enable)
+	}
+	return setKVStoreMaintenanceMode(ctx, podExecClient, enable)
+}
+
+// startSplunkRestore copies the archive to the pod of the target, and starts the restore
+func startSplunkRestore(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore) (time.Duration, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("startSplunkRestore").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	backup, archiveName, err := getSplunkRestoreArchive(ctx, client, cr)
+	if err != nil {
+		return 0, err
+	}
+
+	backupType := getSplunkBackupType(backup)
+	err = validateBackupTarget(backupType, cr.Spec.Target)
+	if err != nil {
+		return 0, err
+	}
+
+	// the peers of the indexer cluster, or the search heads, wait for the etc restore, so that they connect to the
+	// restored configuration
+	if backupType == enterpriseApi.SplunkBackupTypeEtc {
+		err = setEtcRestorePending(ctx, client, cr, true)
+	}
+
+	var podName string
+	if err == nil {
+		_, podName, err = getBackupTargetPod(ctx, client, cr.GetNamespace(), backupType, cr.Spec.Target)
+	}
+	if err != nil {
+		scopedLog.Info("Waiting for the target to be ready for the restore", "error", err.Error())
+		cr.Status.Phase = enterpriseApi.PhasePending
+		cr.Status.Message = err.Error()
+		return splunkBackupTargetRetryInterval, nil
+	}
+
+	podExecClient := getSplunkBackupPodExecClient(client, cr, podName)
+	err = copySplunkBackupToPod(ctx, client, cr, backup, archiveName, podExecClient)
+	if err != nil {
+		return 0, err
+	}
+
+	cr.Status.Type = backupType
+	cr.Status.Archive = archiveName
+	cr.Status.Pod = podName
+	if isSplunkRestoreMaintenanceMode(backupType, cr.Spec.Target.Kind) {
+		scopedLog.Info("Putting the target in maintenance mode", "pod", podName, "type", backupType)
+		err = setSplunkRestoreMaintenanceMode(ctx, podExecClient, backupType, true)
+		if err != nil {
+			return 0, err
+		}
+		cr.Status.MaintenanceMode = true
+	}
+
+	scopedLog.Info("Starting the restore", "pod", podName, "type", backupType, "archive", archiveName)
+	if backupType == enterpriseApi.SplunkBackupTypeEtc {
+		err = startEtcRestore(ctx, podExecClient, getSplunkBackupPathOnPod(backupType, archiveName))
+	} else {
+		command := fmt.Sprintf(kvStoreRestoreCmdStr, archiveName)
+		var stdErr string
+		_, stdErr, err = podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+		if err != nil {
+			err = fmt.Errorf("unable to start the KV store restore. stdErr: %s, err: %v", stdErr, err)
+		}
+	}
+	if err != nil {
+		finishErr := finishSplunkRestore(ctx, cr, podExecClient)
+		if finishErr != nil {
+			scopedLog.Error(finishErr, "Unable to take the target out of maintenance mode")
+		}
+		return 0, err
+	}
+
+	cr.Status.StartTime = time.Now().Unix()
+	cr.Status.Phase = enterpriseApi.PhaseUpdating
+	return splunkBackupPollInterval, nil
+}
+
+// finishSplunkRestore takes the target out of maintenance mode, and removes the archive from the pod
+func finishSplunkRestore(ctx context.Context, cr *enterpriseApi.SplunkRestore, podExecClient splutil.PodExecClientImpl) error {
+	backupType := cr.Status.Type
+	if backupType == "" {
+		backupType = enterpriseApi.SplunkBackupTypeKVStore
+	}
+	if cr.Status.MaintenanceMode {
+		err := setSplunkRestoreMaintenanceMode(ctx, podExecClient, backupType, false)
+		if err != nil {
+			return err
+		}
+		cr.Status.MaintenanceMode = false
+	}
+
+	command := fmt.Sprintf("rm -f %s", getSplunkBackupPathOnPod(backupType, cr.Status.Archive))
+	_, _, err := podExecClient.RunPodExecCommand(ctx, splutil.NewStreamOptionsObject(command), []string{"/bin/sh"})
+	if err != nil {
+		log.FromContext(ctx).Info("Unable to remove the backup from the pod", "pod", cr.Status.Pod, "archive", cr.Status.Archive, "error", err.Error())
+	}
+	return nil
+}
+
+// reconcileSplunkRestore restores the backup once, and returns how long to wait for the next check
+func reconcileSplunkRestore(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore) (time.Duration, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("reconcileSplunkRestore").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	if cr.Status.RestoreTime > 0 {
+		return 0, nil
+	}
+
+	if cr.Status.StartTime == 0 {
+		return startSplunkRestore(ctx, client, cr)
+	}
+
+	// the restore is completed on the pod it was started on
+	// an etc snapshot is restored by the time the restore is started
+	podExecClient := getSplunkBackupPodExecClient(client, cr, cr.Status.Pod)
+	done := true
+	var err error
+	if cr.Status.Type != enterpriseApi.SplunkBackupTypeEtc {
+		done, err = isKVStoreBackupRestoreDone(ctx, podExecClient, "")
+	}
+	if err == nil && !done {
+		if time.Since(time.Unix(cr.Status.StartTime, 0)) < splunkBackupTimeout {
+			return splunkBackupPollInterval, nil
+		}
+		err = fmt.Errorf("restore of %s didn't complete within %s", cr.Status.Archive, splunkBackupTimeout)
+	}
+
+	finishErr := finishSplunkRestore(ctx, cr, podExecClient)
+	if finishErr != nil {
+		return 0, finishErr
+	}
+	if err != nil {
+		// the restore is started again
+		cr.Status.StartTime = 0
+		return 0, err
+	}
+
+	// the annotation of a completed restore is ignored, so, it is left behind if it can't be removed
+	if cr.Status.Type == enterpriseApi.SplunkBackupTypeEtc {
+		err = setEtcRestorePending(ctx, client, cr, false)
+		if err != nil {
+			scopedLog.Info("Unable to remove the pending etc restore annotation from the target", "error", err.Error())
+		}
+	}
+
+	scopedLog.Info("Restore completed", "pod", cr.Status.Pod, "archive", cr.Status.Archive)
+	cr.Status.RestoreTime = time.Now().Unix()
+	cr.Status.Phase = enterpriseApi.PhaseReady
+	cr.Status.Message = ""
+	return 0, nil
+}
+
+// ApplySplunkRestore restores a backup of a SplunkBackup into the target of the SplunkRestore
+func ApplySplunkRestore(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore) (reconcile.Result, error) {
+	result := reconcile.Result{}
+
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("ApplySplunkRestore").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	cr.Kind = "SplunkRestore"
+
+	oldStatus := cr.Status.DeepCopy()
+
+	var requeueAfter time.Duration
+	err := validateSplunkRestoreSpec(cr)
+	if err == nil {
+		requeueAfter, err = reconcileSplunkRestore(ctx, client, cr)
+	}
+
+	if err != nil {
+		scopedLog.Error(err, "Restore failed")
+		cr.Status.Phase = enterpriseApi.PhaseError
+		cr.Status.Message = err.Error()
+	}
+
+	if requeueAfter > 0 {
+		result.Requeue = true
+		result.RequeueAfter = requeueAfter
+	}
+
+	if !reflect.DeepEqual(*oldStatus, cr.Status) {
+		updateErr := client.Status().Update(ctx, cr)
+		if updateErr != nil {
+			scopedLog.Error(updateErr, "status update failed")
+			return result, updateErr
+		}
+	}
+
+	return result, err
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getTestSplunkRestore() *enterpriseApi.SplunkRestore {
+	return &enterpriseApi.SplunkRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore1",
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.SplunkRestoreSpec{
+			Target: enterpriseApi.BackupTargetReference{Kind: "SearchHeadCluster", Name: "shc1"},
+			Backup: "backup1",
+		},
+	}
+}
+
+func TestValidateSplunkRestoreSpec(t *testing.T) {
+	cr := getTestSplunkRestore()
+	if err := validateSplunkRestoreSpec(cr); err != nil {
+		t.Errorf("SplunkRestore should be valid. error: %v", err)
+	}
+
+	cr.Spec.Target.Name = ""
+	if err := validateSplunkRestoreSpec(cr); err == nil {
+		t.Errorf("target name should be required")
+	}
+	cr.Spec.Target.Name = "shc1"
+
+	cr.Spec.Backup = ""
+	if err := validateSplunkRestoreSpec(cr); err == nil {
+		t.Errorf("backup should be required")
+	}
+}
+
+func TestGetSplunkRestoreArchive(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	cr := getTestSplunkRestore()
+
+	_, _, err := getSplunkRestoreArchive(ctx, c, cr)
+	if err == nil {
+		t.Errorf("SplunkBackup should be required")
+	}
+
+	backup := getTestSplunkBackup()
+	backup.Status.Backups = []enterpriseApi.SplunkBackupArchive{{Name: "kvstore-shc1-1.tar.gz"}, {Name: "kvstore-shc1-2.tar.gz"}}
+	backup.Status.LastBackup = "kvstore-shc1-2.tar.gz"
+	err = c.Create(ctx, backup)
+	if err != nil {
+		t.Fatalf("SplunkBackup should be created. error: %v", err)
+	}
+
+	_, archiveName, err := getSplunkRestoreArchive(ctx, c, cr)
+	if err != nil || archiveName != "kvstore-shc1-2.tar.gz" {
+		t.Errorf("last backup should be restored. archive: %s, error: %v", archiveName, err)
+	}
+
+	cr.Spec.Archive = "kvstore-shc1-1.tar.gz"
+	_, archiveName, err = getSplunkRestoreArchive(ctx, c, cr)
+	if err != nil || archiveName != "kvstore-shc1-1.tar.gz" {
+		t.Errorf("archive should be restored. archive: %s, error: %v", archiveName, err)
+	}
+
+	cr.Spec.Archive = "kvstore-shc1-0.tar.gz"
+	_, _, err = getSplunkRestoreArchive(ctx, c, cr)
+	if err == nil {
+		t.Errorf("archive should be one of the backups")
+	}
+}
+
+func TestApplySplunkRestore(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	backup := getTestSplunkBackup()
+	backup.Status.Backups = []enterpriseApi.SplunkBackupArchive{{Name: "kvstore-shc1-1.tar.gz"}}
+	backup.Status.LastBackup = "kvstore-shc1-1.tar.gz"
+	err := c.Create(ctx, backup)
+	if err != nil {
+		t.Fatalf("SplunkBackup should be created. error: %v", err)
+	}
+	cr := getTestSplunkRestore()
+	err = c.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("SplunkRestore should be created. error: %v", err)
+	}
+
+	savedStagingDir := splunkBackupStagingDir
+	savedGetPodExecClient := getSplunkBackupPodExecClient
+	savedDownloadCall := DownloadSplunkBackupCall
+	defer func() {
+		splunkBackupStagingDir = savedStagingDir
+		getSplunkBackupPodExecClient = savedGetPodExecClient
+		DownloadSplunkBackupCall = savedDownloadCall
+	}()
+	splunkBackupStagingDir = t.TempDir()
+
+	podExecClient := &streamPodExecClient{}
+	statusContext := &spltest.MockPodExecReturnContext{StdOut: "1"}
+	digest := sha256.Sum256([]byte("archive"))
+	checksumContext := &spltest.MockPodExecReturnContext{StdOut: hex.EncodeToString(digest[:]) + "  kvstore-shc1-1.tar.gz\n"}
+	podExecClient.AddMockPodExecReturnContext(ctx, "mkdir -p", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "sha256sum", checksumContext)
+	podExecClient.AddMockPodExecReturnContext(ctx, "enable kvstore-maintenance-mode", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "restore kvstore", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "kvstore-status", statusContext)
+	podExecClient.AddMockPodExecReturnContext(ctx, "disable kvstore-maintenance-mode", &spltest.MockPodExecReturnContext{})
+	podExecClient.AddMockPodExecReturnContext(ctx, "rm -f", &spltest.MockPodExecReturnContext{})
+	var podNames []string
+	getSplunkBackupPodExecClient = func(client splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+		podNames = append(podNames, podName)
+		return podExecClient
+	}
+	var downloads []string
+	DownloadSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, backup *enterpriseApi.SplunkBackup, archiveName string, localFile string) error {
+		downloads = append(downloads, archiveName)
+		return os.WriteFile(localFile, []byte("archive"), 0600)
+	}
+
+	// waits for the captain of the search head cluster
+	result, err := ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhasePending || result.RequeueAfter != splunkBackupTargetRetryInterval {
+		t.Errorf("restore should wait for the search head cluster. phase: %s, result: %v, error: %v", cr.Status.Phase, result, err)
+	}
+
+	shc := &enterpriseApi.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "shc1", Namespace: "test"},
+	}
+	err = c.Create(ctx, shc)
+	if err != nil {
+		t.Fatalf("SearchHeadCluster should be created. error: %v", err)
+	}
+	shc.Status.Captain = "splunk-shc1-search-head-1"
+	shc.Status.CaptainReady = true
+	err = c.Status().Update(ctx, shc)
+	if err != nil {
+		t.Fatalf("SearchHeadCluster status should be updated. error: %v", err)
+	}
+
+	// archive is copied to the captain, and the restore is started in maintenance mode
+	result, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhaseUpdating || !cr.Status.MaintenanceMode || result.RequeueAfter != splunkBackupPollInterval {
+		t.Errorf("restore should be started. status: %v, result: %v, error: %v", cr.Status, result, err)
+	}
+	if cr.Status.Pod != "splunk-shc1-search-head-1" || cr.Status.Archive != "kvstore-shc1-1.tar.gz" || len(downloads) != 1 || podExecClient.streamed.String() != "archive" {
+		t.Errorf("archive should be copied to the captain. status: %v, streamed: %s", cr.Status, podExecClient.streamed.String())
+	}
+
+	// waits for the restore to complete
+	_, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.RestoreTime != 0 || !cr.Status.MaintenanceMode {
+		t.Errorf("restore should be in progress. status: %v, error: %v", cr.Status, err)
+	}
+
+	// maintenance mode is disabled once the restore is completed
+	statusContext.StdOut = "0"
+	result, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.PhaseReady || cr.Status.RestoreTime == 0 || cr.Status.MaintenanceMode || result.Requeue {
+		t.Errorf("restore should be completed. status: %v, result: %v, error: %v", cr.Status, result, err)
+	}
+	wantCmds := []string{"mkdir -p", "dd of=/opt/splunk/var/lib/splunk/kvstorebackup/kvstore-shc1-1.tar.gz bs=1M", "sha256sum", "enable kvstore-maintenance-mode",
+		"restore kvstore", "kvstore-status", "disable kvstore-maintenance-mode", "rm -f"}
+	if strings.Join(podExecClient.GotCmdList, ",") != strings.Join(wantCmds, ",") {
+		t.Errorf("unexpected pod exec commands. got: %v, want: %v", podExecClient.GotCmdList, wantCmds)
+	}
+	for _, podName := range podNames {
+		if podName != "splunk-shc1-search-head-1" {
+			t.Errorf("restore should run on the captain. got: %s", podName)
+		}
+	}
+
+	// the restore is done once
+	_, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || len(downloads) != 1 {
+		t.Errorf("restore should not be started again. error: %v", err)
+	}
+
+	// a corrupted copy on the pod is not restored
+	cr = getTestSplunkRestore()
+	cr.Name = "restore3"
+	cr.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "SearchHeadCluster", Name: "shc1"}
+	err = c.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("SplunkRestore should be created. error: %v", err)
+	}
+	checksumContext.StdOut = "0123  kvstore-shc1-1.tar.gz\n"
+	_, err = ApplySplunkRestore(ctx, c, cr)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") || cr.Status.StartTime != 0 || cr.Status.MaintenanceMode {
+		t.Errorf("restore should fail for a corrupted copy. status: %v, error: %v", cr.Status, err)
+	}
+
+	// download errors are reported
+	cr = getTestSplunkRestore()
+	cr.Name = "restore2"
+	cr.Spec.Target = enterpriseApi.BackupTargetReference{Kind: "SearchHeadCluster", Name: "shc1"}
+	err = c.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("SplunkRestore should be created. error: %v", err)
+	}
+	DownloadSplunkBackupCall = func(ctx context.Context, client splcommon.ControllerClient, backup *enterpriseApi.SplunkBackup, archiveName string, localFile string) error {
+		return fmt.Errorf("access denied")
+	}
+	_, err = ApplySplunkRestore(ctx, c, cr)
+	if err == nil || cr.Status.Phase != enterpriseApi.PhaseError || cr.Status.MaintenanceMode {
+		t.Errorf("download error should be reported. status: %v, error: %v", cr.Status, err)
+	}
+}
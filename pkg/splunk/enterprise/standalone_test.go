@@ -335,7 +335,7 @@ func TestGetStandaloneStatefulSet(t *testing.T) {
 
 	test := func(want string) {
 		f := func() (interface{}, error) {
-			if err := validateStandaloneSpec(ctx, c, &cr); err != nil {
+			if _, err := validateStandaloneSpec(ctx, c, &cr); err != nil {
 				t.Errorf("validateStandaloneSpec() returned error: %v", err)
 			}
 			return getStandaloneStatefulSet(ctx, c, &cr)
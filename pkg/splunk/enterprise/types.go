@@ -88,6 +88,62 @@ type globalResourceTracker struct {
 	storage *storageTracker
 
 	commonResourceTracker *commonResourceTracker
+
+	// operator wide schedulers for the App Framework pipeline phases
+	appPhaseSchedulers map[enterpriseApi.AppPhaseType]*appPhaseScheduler
+}
+
+// appPhaseScheduler hands out the operator wide worker slots of an App Framework pipeline phase to the CRs.
+// The waiting CRs with the highest priority are served first, and the CRs of the same priority share the
+// slots based on their weights, using weighted fair queuing
+type appPhaseScheduler struct {
+	// pipeline phase served by the scheduler
+	phase enterpriseApi.AppPhaseType
+
+	// max. number of workers that can run at the same time, across all the CRs
+	maxSlots int
+
+	// number of slots in use
+	activeSlots int
+
+	// virtual time of the fair queuing, which is the start tag of the latest granted slot
+	virtualTime float64
+
+	// finish tag of the latest slot request, per CR
+	lastFinishTags map[string]float64
+
+	// slot requests waiting for a slot
+	waiting []*appPhaseSlot
+
+	// mutex to serialize the access
+	mutex sync.Mutex
+}
+
+// appPhaseSlot represents a request for a worker slot, and the slot once it is granted
+type appPhaseSlot struct {
+	// scheduler handing out the slot
+	scheduler *appPhaseScheduler
+
+	// key of the requesting CR
+	crKey string
+
+	// scheduling priority and weight of the requesting CR
+	priority string
+	weight   uint32
+
+	// fair queuing tags
+	startTag  float64
+	finishTag float64
+
+	// time the slot was requested
+	requestTime time.Time
+
+	// closed when the slot is granted
+	ready chan struct{}
+
+	// indicates if the slot is granted, and if it is released
+	granted  bool
+	released bool
 }
 
 type storageTracker struct {
@@ -129,6 +185,9 @@ type PipelineWorker struct {
 
 	// indicates a fan out worker
 	fanOut bool
+
+	// operator wide slot held by the worker, while running
+	phaseSlot *appPhaseSlot
 }
 
 // PipelinePhase represents one phase in the overall installation pipeline
@@ -165,6 +224,9 @@ type AppInstallPipeline struct {
 
 	// statefulset to know replicaset details
 	sts *appsv1.StatefulSet
+
+	// app installs and bundle pushes are held till the next maintenance window
+	waitingForMaintenanceWindow bool
 }
 
 // PlaybookImpl is an interface to implement individual playbooks
@@ -209,12 +271,24 @@ type premiumAppScopePlaybookContext struct {
 	afwPipeline *AppInstallPipeline
 }
 
+// premiumAppTypeImpl is an interface to implement the steps specific to a type of premium app
+type premiumAppTypeImpl interface {
+	// validate validates the premium app properties of an app source for the given kind of CR
+	validate(appSrc *enterpriseApi.AppSourceSpec, crKind string) error
+
+	// postInstall runs the post install steps, once the app package is installed on the pod
+	postInstall(rctx context.Context, preCtx *premiumAppScopePlaybookContext, phaseInfo *enterpriseApi.PhaseInfo) error
+}
+
 type localScopePlaybookContext struct {
 	worker *PipelineWorker
 
 	// semaphore to track only one app install at any time for a given replicaset pod
 	sem           chan struct{}
 	podExecClient splutil.PodExecClientImpl
+
+	// pipeline running the worker, used to stop the install verification on the pipeline termination
+	afwPipeline *AppInstallPipeline
 }
 
 // ToString returns a string for a given InstanceType
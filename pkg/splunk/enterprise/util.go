@@ -71,6 +71,9 @@ func initGlobalResourceTracker() {
 
 	// initialize the resource tracker
 	initCommonResourceTracker()
+
+	// initialize the App Framework phase schedulers
+	initAppPhaseSchedulers()
 }
 
 func initCommonResourceTracker() {
@@ -136,6 +139,20 @@ func GetRemoteStorageClient(ctx context.Context, client splcommon.ControllerClie
 	scopedLog := reqLogger.WithName("GetRemoteStorageClient").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
 
 	remoteDataClient := splclient.SplunkRemoteDataClient{}
+
+	// volume of a shared AppSource is accessed with the secret from the namespace of the AppSource
+	secretNamespace := cr.GetNamespace()
+	appSource, err := getSharedAppSourceForVolume(ctx, client, cr, appFrameworkRef, vol)
+	if err != nil {
+		return remoteDataClient, err
+	}
+	if appSource != nil {
+		sharedVol := appSource.Spec.Volume
+		sharedVol.Name = vol.Name
+		vol = &sharedVol
+		secretNamespace = appSource.GetNamespace()
+	}
+
 	//use the provider name to get the corresponding function pointer
 	getClientWrapper := splclient.RemoteDataClientsMap[vol.Provider]
 	getClient := getClientWrapper.GetRemoteDataClientFuncPtr(ctx)
@@ -150,7 +167,7 @@ func GetRemoteStorageClient(ctx context.Context, client splcommon.ControllerClie
 		secretAccessKey = ""
 	} else {
 		// Get credentials through the secretRef
-		remoteDataClientSecret, err := splutil.GetSecretByName(ctx, client, cr.GetNamespace(), cr.GetName(), appSecretRef)
+		remoteDataClientSecret, err := splutil.GetSecretByName(ctx, client, secretNamespace, cr.GetName(), appSecretRef)
 		if err != nil {
 			return remoteDataClient, err
 		}
@@ -159,13 +176,35 @@ func GetRemoteStorageClient(ctx context.Context, client splcommon.ControllerClie
 		if vol.Provider == "azure" {
 			accessKeyID = string(remoteDataClientSecret.Data["azure_sa_name"])
 			secretAccessKey = string(remoteDataClientSecret.Data["azure_sa_secret_key"])
+		} else if vol.Provider == "git" {
+			// user name is optional, and is used only along with an access token
+			accessKeyID = string(remoteDataClientSecret.Data["git_username"])
+			secretAccessKey = string(remoteDataClientSecret.Data["git_ssh_key"])
+			if secretAccessKey == "" {
+				secretAccessKey = string(remoteDataClientSecret.Data["git_token"])
+			}
+			if secretAccessKey == "" {
+				err = fmt.Errorf("git ssh key or token is missing")
+				return remoteDataClient, err
+			}
+		} else if vol.Provider == "http" {
+			// basic auth is used with the user name and password, otherwise bearer auth with the token
+			accessKeyID = string(remoteDataClientSecret.Data["http_username"])
+			secretAccessKey = string(remoteDataClientSecret.Data["http_password"])
+			if accessKeyID == "" {
+				secretAccessKey = string(remoteDataClientSecret.Data["http_token"])
+			}
+			if secretAccessKey == "" {
+				err = fmt.Errorf("http password or token is missing")
+				return remoteDataClient, err
+			}
 		} else {
 			accessKeyID = string(remoteDataClientSecret.Data["s3_access_key"])
 			secretAccessKey = string(remoteDataClientSecret.Data["s3_secret_key"])
 		}
 
 		// Do we need to handle if IAM_ROLE is set in the secret as well?
-		if accessKeyID == "" {
+		if accessKeyID == "" && vol.Provider != "git" && vol.Provider != "http" {
 			err = fmt.Errorf("accessKey missing")
 			return remoteDataClient, err
 		}
@@ -175,6 +214,27 @@ func GetRemoteStorageClient(ctx context.Context, client splcommon.ControllerClie
 		}
 	}
 
+	bucket, prefix := getRemoteStorageBucketAndPrefix(vol, location)
+
+	scopedLog.Info("Creating the client", "volume", vol.Name, "bucket", bucket, "bucket path", prefix)
+
+	remoteDataClient.Client, err = getClient(ctx, bucket, accessKeyID, secretAccessKey, prefix, prefix /* startAfter*/, vol.Region, vol.Endpoint, fn)
+
+	if err != nil {
+		scopedLog.Error(err, "Failed to get the S3 client")
+		return remoteDataClient, err
+	}
+
+	return remoteDataClient, nil
+}
+
+// getRemoteStorageBucketAndPrefix returns the bucket and the prefix of the app source location on the remote storage
+func getRemoteStorageBucketAndPrefix(vol *enterpriseApi.VolumeSpec, location string) (string, string) {
+	// the git ref, when set, takes the place of the bucket name, and the whole path is the directory in the repository
+	if vol.Provider == "git" && vol.Ref != "" {
+		return vol.Ref, filepath.Join(vol.Path, location) + "/"
+	}
+
 	// Get the bucket name form the "path" field
 	bucket := strings.Split(vol.Path, "/")[0]
 
@@ -190,18 +250,7 @@ func GetRemoteStorageClient(ctx context.Context, client splcommon.ControllerClie
 	// Ex. ("a/b" + "c"),  ("a/b/" + "c"),  ("a/b/" + "/c"),  ("a/b/" + "/c"), ("a/b//", + "c/././") ("a/b/../b", + "c/../c") all are joined as "a/b/c"
 	prefix := filepath.Join(basePrefix, location) + "/"
 
-	scopedLog.Info("Creating the client", "volume", vol.Name, "bucket", bucket, "bucket path", prefix)
-
-	var err error
-
-	remoteDataClient.Client, err = getClient(ctx, bucket, accessKeyID, secretAccessKey, prefix, prefix /* startAfter*/, vol.Region, vol.Endpoint, fn)
-
-	if err != nil {
-		scopedLog.Error(err, "Failed to get the S3 client")
-		return remoteDataClient, err
-	}
-
-	return remoteDataClient, nil
+	return bucket, prefix
 }
 
 // ApplySplunkConfig reconciles the state of Kubernetes Secrets, ConfigMaps and other general settings for Splunk Enterprise instances.
@@ -820,8 +869,8 @@ func (rdcMgr *RemoteDataClientManager) GetAppsList(ctx context.Context) (splclie
 	return remoteDataListResponse, nil
 }
 
-// DownloadApp downloads the app from remote storage
-func (rdcMgr *RemoteDataClientManager) DownloadApp(ctx context.Context, remoteFile string, localFile string, etag string) error {
+// DownloadApp downloads the app from remote storage. A non-zero startOffset resumes the partial download in the localFile
+func (rdcMgr *RemoteDataClientManager) DownloadApp(ctx context.Context, remoteFile string, localFile string, etag string, startOffset int64) error {
 
 	c, err := rdcMgr.getRemoteDataClient(ctx, rdcMgr.client, rdcMgr.cr, rdcMgr.appFrameworkRef, rdcMgr.vol, rdcMgr.location, rdcMgr.initFn)
 	if err != nil {
@@ -829,9 +878,10 @@ func (rdcMgr *RemoteDataClientManager) DownloadApp(ctx context.Context, remoteFi
 	}
 
 	downloadRequest := splclient.RemoteDataDownloadRequest{
-		LocalFile:  localFile,
-		RemoteFile: remoteFile,
-		Etag:       etag,
+		LocalFile:   localFile,
+		RemoteFile:  remoteFile,
+		Etag:        etag,
+		StartOffset: startOffset,
 	}
 
 	_, err = c.Client.DownloadApp(ctx, downloadRequest)
@@ -841,6 +891,66 @@ func (rdcMgr *RemoteDataClientManager) DownloadApp(ctx context.Context, remoteFi
 	return err
 }
 
+// StreamApp opens a stream to read the app from remote storage
+func (rdcMgr *RemoteDataClientManager) StreamApp(ctx context.Context, remoteFile string, etag string) (io.ReadCloser, error) {
+
+	c, err := rdcMgr.getRemoteDataClient(ctx, rdcMgr.client, rdcMgr.cr, rdcMgr.appFrameworkRef, rdcMgr.vol, rdcMgr.location, rdcMgr.initFn)
+	if err != nil {
+		return nil, err
+	}
+
+	streamClient, ok := c.Client.(splclient.RemoteDataStreamClient)
+	if !ok {
+		return nil, fmt.Errorf("streaming the apps is not supported for the provider %s", rdcMgr.vol.Provider)
+	}
+
+	downloadRequest := splclient.RemoteDataDownloadRequest{
+		RemoteFile: remoteFile,
+		Etag:       etag,
+	}
+
+	return streamClient.StreamApp(ctx, downloadRequest)
+}
+
+// getUploadClient returns the RemoteDataClient, if it can write to the remote storage
+func (rdcMgr *RemoteDataClientManager) getUploadClient(ctx context.Context) (splclient.RemoteDataUploadClient, error) {
+	c, err := rdcMgr.getRemoteDataClient(ctx, rdcMgr.client, rdcMgr.cr, rdcMgr.appFrameworkRef, rdcMgr.vol, rdcMgr.location, rdcMgr.initFn)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadClient, ok := c.Client.(splclient.RemoteDataUploadClient)
+	if !ok {
+		return nil, fmt.Errorf("uploading data is not supported for the provider %s", rdcMgr.vol.Provider)
+	}
+	return uploadClient, nil
+}
+
+// UploadData uploads the local file to remote storage
+func (rdcMgr *RemoteDataClientManager) UploadData(ctx context.Context, localFile string, remoteFile string) error {
+	uploadClient, err := rdcMgr.getUploadClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	uploadRequest := splclient.RemoteDataUploadRequest{
+		LocalFile:  localFile,
+		RemoteFile: remoteFile,
+	}
+
+	return uploadClient.UploadData(ctx, uploadRequest)
+}
+
+// DeleteData deletes the file from remote storage
+func (rdcMgr *RemoteDataClientManager) DeleteData(ctx context.Context, remoteFile string) error {
+	uploadClient, err := rdcMgr.getUploadClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	return uploadClient.DeleteData(ctx, remoteFile)
+}
+
 // GetAppsList this func pointer is to use this function in unit test cases
 var GetAppsList = func(ctx context.Context, RemoteDataClientMgr RemoteDataClientManager) (splclient.RemoteDataListResponse, error) {
 	remoteDataListResponse, err := RemoteDataClientMgr.GetAppsList(ctx)
@@ -849,6 +959,11 @@ var GetAppsList = func(ctx context.Context, RemoteDataClientMgr RemoteDataClient
 
 // GetAppListFromRemoteBucket gets the list of apps from remote storage.
 func GetAppListFromRemoteBucket(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appFrameworkRef *enterpriseApi.AppFrameworkSpec) (map[string]splclient.RemoteDataListResponse, error) {
+	return getAppListFromRemoteBucketForAppSources(ctx, client, cr, appFrameworkRef, appFrameworkRef.AppSources)
+}
+
+// getAppListFromRemoteBucketForAppSources gets the list of apps from remote storage, only for the given app sources
+func getAppListFromRemoteBucketForAppSources(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appFrameworkRef *enterpriseApi.AppFrameworkSpec, appSources []enterpriseApi.AppSourceSpec) (map[string]splclient.RemoteDataListResponse, error) {
 
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("GetAppListFromRemoteBucket").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
@@ -862,7 +977,24 @@ func GetAppListFromRemoteBucket(ctx context.Context, client splcommon.Controller
 	var err error
 	var allSuccess bool = true
 
-	for _, appSource := range appFrameworkRef.AppSources {
+	for _, appSource := range appSources {
+		// listing of a shared AppSource is done once for all the referring CRs
+		if appSource.AppSourceRef != nil {
+			var sharedAppSource *enterpriseApi.AppSource
+			sharedAppSource, err = getSharedAppSource(ctx, client, cr, appSource.AppSourceRef)
+			if err == nil {
+				remoteDataListResponse, err = getSharedAppSourceAppsList(ctx, client, sharedAppSource)
+			}
+			if err != nil {
+				scopedLog.Error(err, "Unable to get apps list", "appSource", appSource.Name)
+				allSuccess = false
+				continue
+			}
+
+			sourceToAppListMap[appSource.Name] = remoteDataListResponse
+			continue
+		}
+
 		vol, err = splclient.GetAppSrcVolume(ctx, appSource, appFrameworkRef)
 		if err != nil {
 			allSuccess = false
@@ -1141,6 +1273,8 @@ func handleAppRepoChanges(ctx context.Context, client splcommon.ControllerClient
 			var modified bool
 
 			modified, appSrcDeploymentInfo.AppDeploymentInfoList = setStateAndStatusForAppDeployInfoList(curAppDeployList, enterpriseApi.RepoStateDeleted, enterpriseApi.DeployStatusPending)
+			// the apps are not rolled back anymore
+			removeAppRollbackPkgs(ctx, filepath.Join(getAppRollbackDir(cr), appSrc))
 
 			if modified {
 				// Finally update the Map entry with latest info
@@ -1151,34 +1285,57 @@ func handleAppRepoChanges(ctx context.Context, client splcommon.ControllerClient
 
 	// 2. Go through each AppSrc from the remote listing
 	for appSrc, remoteDataListResponse := range remoteObjListingMap {
-		// 2.1 Mark Apps for deletion if they are missing in remote listing
-		appSrcDeploymentInfo, appSrcExistsLocally := appDeployContext.AppsSrcDeployStatus[appSrc]
-
-		if appSrcExistsLocally {
-			currentList := appSrcDeploymentInfo.AppDeploymentInfoList
-			for appIdx := range currentList {
-				if !isAppRepoStateDeleted(appSrcDeploymentInfo.AppDeploymentInfoList[appIdx]) && !checkIfAnAppIsActiveOnRemoteStore(currentList[appIdx].AppName, remoteDataListResponse.Objects) {
-					scopedLog.Info("App change", "deleting/disabling the App: ", currentList[appIdx].AppName, "as it is missing in the remote listing", nil)
-					setStateAndStatusForAppDeployInfo(&currentList[appIdx], enterpriseApi.RepoStateDeleted, enterpriseApi.DeployStatusComplete)
-				}
-			}
+		appsModified, err = handleAppSrcRepoChanges(ctx, client, cr, appDeployContext, appSrc, remoteDataListResponse, appFrameworkConfig)
+		if err != nil {
+			return appsModified, err
 		}
+	}
 
-		// 2.2 Check for any App changes(Ex. A new App source, a new App added/updated)
-		appsModified = AddOrUpdateAppSrcDeploymentInfoList(ctx, &appSrcDeploymentInfo, remoteDataListResponse.Objects)
-		scope := getAppSrcScope(ctx, appFrameworkConfig, appSrc)
-		// if some apps were modified or added, and we have cluster scoped apps,
-		// then set the bundle push state to Pending
-		if appsModified && scope == enterpriseApi.ScopeCluster {
-			appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushPending
+	return appsModified, err
+}
 
+// handleAppSrcRepoChanges parses the remote storage listing of an App source and updates the deployment info of its apps
+func handleAppSrcRepoChanges(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject,
+	appDeployContext *enterpriseApi.AppDeploymentContext, appSrc string, remoteDataListResponse splclient.RemoteDataListResponse, appFrameworkConfig *enterpriseApi.AppFrameworkSpec) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("handleAppSrcRepoChanges").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "appSrc", appSrc)
+
+	// 2.1 Mark Apps for deletion if they are missing in remote listing
+	appSrcDeploymentInfo, appSrcExistsLocally := appDeployContext.AppsSrcDeployStatus[appSrc]
+
+	if appSrcExistsLocally {
+		currentList := appSrcDeploymentInfo.AppDeploymentInfoList
+		for appIdx := range currentList {
+			if !isAppRepoStateDeleted(appSrcDeploymentInfo.AppDeploymentInfoList[appIdx]) && !checkIfAnAppIsActiveOnRemoteStore(currentList[appIdx].AppName, remoteDataListResponse.Objects) {
+				scopedLog.Info("App change", "deleting/disabling the App: ", currentList[appIdx].AppName, "as it is missing in the remote listing", nil)
+				setStateAndStatusForAppDeployInfo(&currentList[appIdx], enterpriseApi.RepoStateDeleted, enterpriseApi.DeployStatusComplete)
+				removeAppRollbackPkg(ctx, cr, appSrc, currentList[appIdx].AppName)
+			}
 		}
+	}
+
+	// 2.2 Check for any App changes(Ex. A new App source, a new App added/updated)
+	appsModified := AddOrUpdateAppSrcDeploymentInfoList(ctx, &appSrcDeploymentInfo, remoteDataListResponse.Objects)
+
+	// 2.3 Check for any changes to the App config overlays
+	overlaysModified, err := updateAppConfigOverlayHashes(ctx, client, cr, appFrameworkConfig, appSrc, &appSrcDeploymentInfo)
+	if err != nil {
+		return appsModified, err
+	}
+	appsModified = appsModified || overlaysModified
+
+	scope := getAppSrcScope(ctx, appFrameworkConfig, appSrc)
+	// if some apps were modified or added, and we have cluster scoped apps,
+	// then set the bundle push state to Pending
+	if appsModified && scope == enterpriseApi.ScopeCluster {
+		appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushPending
 
-		// Finally update the Map entry with latest info
-		appDeployContext.AppsSrcDeployStatus[appSrc] = appSrcDeploymentInfo
 	}
 
-	return appsModified, err
+	// Finally update the Map entry with latest info
+	appDeployContext.AppsSrcDeployStatus[appSrc] = appSrcDeploymentInfo
+
+	return appsModified, nil
 }
 
 // isAppExtentionValid checks if an app extention is supported or not
@@ -1195,6 +1352,12 @@ func isAppExtentionValid(receivedKey string) bool {
 	case "tgz":
 		return true
 
+	case "gz":
+		return strings.HasSuffix(receivedKey, ".tar.gz")
+
+	case "zip":
+		return true
+
 	default:
 		return false
 	}
@@ -1230,12 +1393,8 @@ func AddOrUpdateAppSrcDeploymentInfoList(ctx context.Context, appSrcDeploymentIn
 				if appList[idx].ObjectHash != *remoteObj.Etag || appList[idx].RepoState == enterpriseApi.RepoStateDeleted {
 					scopedLog.Info("App change detected.  Marking for an update.", "appName", appName)
 					appList[idx].ObjectHash = *remoteObj.Etag
-					appList[idx].IsUpdate = true
-					appList[idx].DeployStatus = enterpriseApi.DeployStatusPending
-					appList[idx].PhaseInfo.Phase = enterpriseApi.PhaseDownload
-					appList[idx].PhaseInfo.Status = enterpriseApi.AppPkgDownloadPending
-					appList[idx].PhaseInfo.FailCount = 0
-					appList[idx].AuxPhaseInfo = nil
+					appList[idx].Size = getRemoteObjectSize(remoteObj)
+					markAppDeployInfoForUpdate(&appList[idx])
 
 					// Make the state active for an app that was deleted earlier, and got activated again
 					if appList[idx].RepoState == enterpriseApi.RepoStateDeleted {
@@ -1255,6 +1414,7 @@ func AddOrUpdateAppSrcDeploymentInfoList(ctx context.Context, appSrcDeploymentIn
 			scopedLog.Info("New App found", "appName", appName)
 			appDeployInfo.AppName = appName
 			appDeployInfo.ObjectHash = *remoteObj.Etag
+			appDeployInfo.Size = getRemoteObjectSize(remoteObj)
 			appDeployInfo.RepoState = enterpriseApi.RepoStateActive
 			appDeployInfo.DeployStatus = enterpriseApi.DeployStatusPending
 			appDeployInfo.PhaseInfo.Phase = enterpriseApi.PhaseDownload
@@ -1272,6 +1432,14 @@ func AddOrUpdateAppSrcDeploymentInfoList(ctx context.Context, appSrcDeploymentIn
 	return appChangesDetected
 }
 
+// getRemoteObjectSize returns the size of the remote object, or 0 if the remote storage didn't report it
+func getRemoteObjectSize(remoteObj *splclient.RemoteObject) uint64 {
+	if remoteObj.Size == nil || *remoteObj.Size < 0 {
+		return 0
+	}
+	return uint64(*remoteObj.Size)
+}
+
 // markAppsStatusToComplete sets the required status for a given state.
 // Gets called from glue logic based on how we want to hand-off to init/side car, and look for the return status
 // For now, two possible cases:
@@ -1344,7 +1512,8 @@ func isAppAlreadyDownloaded(ctx context.Context, downloadWorker *PipelineWorker)
 
 	localSize := fileInfo.Size()
 	remoteSize := int64(downloadWorker.appDeployInfo.Size)
-	if localSize != remoteSize {
+	// size is not known for some of the remote storage providers
+	if remoteSize != 0 && localSize != remoteSize {
 		err = fmt.Errorf("local size does not match with size on remote storage. localSize=%d, remoteSize=%d", localSize, remoteSize)
 		scopedLog.Error(err, "incorrect app size")
 		return false
@@ -1492,7 +1661,13 @@ func initAndCheckAppInfoStatus(ctx context.Context, client splcommon.ControllerC
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("initAndCheckAppInfoStatus").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
 
-	var err error
+	// The deployment status of the apps is kept in the AppDeployments, so load it before looking at the apps
+	err := loadAppDeployStatusFromAppDeployments(ctx, client, cr, appStatusContext)
+	if err != nil {
+		scopedLog.Error(err, "Unable to load the app deployment status")
+		return err
+	}
+
 	// Register the RemoteData Clients specific to providers if not done already
 	// This is done to prevent the null pointer dereference in case when
 	// operator crashes and comes back up and the status of app context was updated
@@ -1503,9 +1678,18 @@ func initAndCheckAppInfoStatus(ctx context.Context, client splcommon.ControllerC
 		return err
 	}
 
+	// In the dry run mode, only the plan of the app changes is recorded
+	if appFrameworkConf.DryRun {
+		return planAppInfoStatus(ctx, client, cr, appFrameworkConf, appStatusContext)
+	}
+	appStatusContext.DryRunPlan = nil
+
 	var turnOffManualChecking bool
 	kind := cr.GetObjectKind().GroupVersionKind().Kind
 
+	// App sources reported as changed by the remote storage notifications, if any
+	dirtyAppSources := getDirtyAppSources(cr)
+
 	//check if the apps need to be downloaded from remote storage
 	if shouldCheckAppRepoStatus(ctx, client, cr, appStatusContext, kind, &turnOffManualChecking) || !reflect.DeepEqual(appStatusContext.AppFrameworkConfig, *appFrameworkConf) {
 
@@ -1527,18 +1711,10 @@ func initAndCheckAppInfoStatus(ctx context.Context, client splcommon.ControllerC
 			scopedLog.Error(err, "Unable to get apps list, will retry in next reconcile...")
 		} else {
 			for _, appSource := range appFrameworkConf.AppSources {
-				// Clean-up for the object digest value
-				for i := range sourceToAppsList[appSource.Name].Objects {
-					cleanDigest, err := getCleanObjectDigest(sourceToAppsList[appSource.Name].Objects[i].Etag)
-					if err != nil {
-						scopedLog.Error(err, "unable to fetch clean object digest value", "Object Hash", sourceToAppsList[appSource.Name].Objects[i].Etag)
-						return err
-					}
-
-					sourceToAppsList[appSource.Name].Objects[i].Etag = cleanDigest
+				err = cleanAppSrcObjectDigests(ctx, appSource.Name, sourceToAppsList[appSource.Name])
+				if err != nil {
+					return err
 				}
-
-				scopedLog.Info("Apps List retrieved from remote storage", "App Source", appSource.Name, "Content", sourceToAppsList[appSource.Name].Objects)
 			}
 
 			// Only handle the app repo changes if we were able to successfully get the apps list
@@ -1549,6 +1725,9 @@ func initAndCheckAppInfoStatus(ctx context.Context, client splcommon.ControllerC
 			}
 
 			appStatusContext.AppFrameworkConfig = *appFrameworkConf
+
+			// full listing covers the app sources reported as changed till now
+			clearDirtyAppSources(cr, dirtyAppSources)
 		}
 
 		// Set the last check time, irrespective of the polling type. This way, it is easy to switch
@@ -1562,11 +1741,33 @@ func initAndCheckAppInfoStatus(ctx context.Context, client splcommon.ControllerC
 				return err
 			}
 		}
+	} else if len(dirtyAppSources) != 0 {
+		return checkDirtyAppSources(ctx, client, cr, appFrameworkConf, appStatusContext, dirtyAppSources)
 	}
 
 	return nil
 }
 
+// cleanAppSrcObjectDigests cleans up the object digest values in the remote storage listing of an App source
+func cleanAppSrcObjectDigests(ctx context.Context, appSrc string, remoteDataListResponse splclient.RemoteDataListResponse) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("cleanAppSrcObjectDigests")
+
+	// Clean-up for the object digest value
+	for i := range remoteDataListResponse.Objects {
+		cleanDigest, err := getCleanObjectDigest(remoteDataListResponse.Objects[i].Etag)
+		if err != nil {
+			scopedLog.Error(err, "unable to fetch clean object digest value", "Object Hash", remoteDataListResponse.Objects[i].Etag)
+			return err
+		}
+
+		remoteDataListResponse.Objects[i].Etag = cleanDigest
+	}
+
+	scopedLog.Info("Apps List retrieved from remote storage", "App Source", appSrc, "Content", remoteDataListResponse.Objects)
+	return nil
+}
+
 // SetConfigMapOwnerRef sets the owner references for the configMap
 func SetConfigMapOwnerRef(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, configMap *corev1.ConfigMap) error {
 	reqLogger := log.FromContext(ctx)
@@ -1879,6 +2080,7 @@ func setInstallStateForClusterScopedApps(ctx context.Context, appDeployContext *
 			if deployInfoList[i].PhaseInfo.Phase == enterpriseApi.PhasePodCopy && deployInfoList[i].PhaseInfo.Status == enterpriseApi.AppPkgPodCopyComplete {
 				deployInfoList[i].PhaseInfo.Phase = enterpriseApi.PhaseInstall
 				deployInfoList[i].PhaseInfo.Status = enterpriseApi.AppPkgInstallComplete
+				deployInfoList[i].LastError = ""
 				scopedLog.Info("Cluster scoped app installed", "app name", deployInfoList[i].AppName, "digest", deployInfoList[i].ObjectHash)
 			} else if deployInfoList[i].PhaseInfo.Phase != enterpriseApi.PhaseInstall || deployInfoList[i].PhaseInfo.Status != enterpriseApi.AppPkgInstallComplete {
 				scopedLog.Error(nil, "app missing from bundle push", "app name", deployInfoList[i].AppName, "digest", deployInfoList[i].ObjectHash, "phase", deployInfoList[i].PhaseInfo.Phase, "status", deployInfoList[i].PhaseInfo.Status)
@@ -1963,6 +2165,18 @@ func handleAppFrameworkActivity(ctx context.Context, client splcommon.Controller
 		updateReconcileRequeueTime(ctx, finalResult, requeueAfter, true)
 	}
 
+	// No pipeline phase is run in the dry run mode
+	if appFrameworkConfig.DryRun {
+		scopedLog.Info("Dry run mode. Skipping the app framework pipeline")
+		return finalResult
+	}
+
+	// app installs and bundle pushes held outside of the maintenance windows are resumed when the next window starts
+	updateMaintenanceWindowStatus(ctx, appDeployContext, appFrameworkConfig)
+	if requeueAfter := getMaintenanceWindowRequeueTime(appDeployContext); requeueAfter > 0 {
+		updateReconcileRequeueTime(ctx, finalResult, requeueAfter, true)
+	}
+
 	if appDeployContext.AppsSrcDeployStatus != nil {
 		requeue, err := afwSchedulerEntry(ctx, client, cr, appDeployContext, appFrameworkConfig)
 		if err != nil {
@@ -2081,6 +2295,18 @@ func updateCRStatus(ctx context.Context, client splcommon.ControllerClient, orig
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("updateCRStatus").WithValues("original cr version", origCR.GetResourceVersion())
 
+	// The deployment status of each app is kept in its AppDeployment, so that the CR status keeps only a summary.
+	// If the AppDeployments can't be updated, the CR status keeps the deployment status of the apps for now
+	var appDeployStatusPublished bool
+	if appDeployContext := getAppDeploymentContext(origCR); origCR.GetDeletionTimestamp() == nil && appDeployContext != nil && len(appDeployContext.AppsSrcDeployStatus) != 0 {
+		err := publishAppDeployments(ctx, client, origCR)
+		if err != nil {
+			scopedLog.Error(err, "Unable to publish the app deployments")
+		} else {
+			appDeployStatusPublished = true
+		}
+	}
+
 	var tryCnt int
 	for tryCnt = 0; tryCnt < maxRetryCountForCRStatusUpdate; tryCnt++ {
 		latestCR, err := fetchCurrentCRWithStatusUpdate(ctx, client, origCR)
@@ -2092,6 +2318,10 @@ func updateCRStatus(ctx context.Context, client splcommon.ControllerClient, orig
 			continue
 		}
 
+		if appDeployStatusPublished {
+			getAppDeploymentContext(latestCR).AppsSrcDeployStatus = nil
+		}
+
 		scopedLog.Info("Trying to update", "count", tryCnt)
 		curCRVersion := latestCR.GetResourceVersion()
 		err = client.Status().Update(ctx, latestCR)
@@ -151,6 +151,74 @@ func TestGetRemoteStorageClient(t *testing.T) {
 	if err == nil {
 		t.Errorf("Expeceted error")
 	}
+
+	// git provider needs either an ssh key or a token
+	splclient.RegisterRemoteDataClient(ctx, "git")
+	getClientWrapper = splclient.RemoteDataClientsMap["git"]
+	getClientWrapper.SetRemoteDataClientFuncPtr(ctx, "git", splclient.NewMockAWSS3Client)
+	cm.Spec.AppFrameworkConfig.VolList[1].Type = "git"
+	cm.Spec.AppFrameworkConfig.VolList[1].Provider = "git"
+	_, err = GetRemoteStorageClient(ctx, c, &cm, &cm.Spec.AppFrameworkConfig, &cm.Spec.AppFrameworkConfig.VolList[1], "location", fn)
+	if err == nil || err.Error() != "git ssh key or token is missing" {
+		t.Errorf("Expeceted error for missing git credentials")
+	}
+
+	// user name is optional for git
+	secret.Data["git_token"] = []byte("abcd")
+	c.Update(ctx, &secret)
+	_, err = GetRemoteStorageClient(ctx, c, &cm, &cm.Spec.AppFrameworkConfig, &cm.Spec.AppFrameworkConfig.VolList[1], "location", fn)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// http provider needs either a password or a token
+	splclient.RegisterRemoteDataClient(ctx, "http")
+	getClientWrapper = splclient.RemoteDataClientsMap["http"]
+	getClientWrapper.SetRemoteDataClientFuncPtr(ctx, "http", splclient.NewMockAWSS3Client)
+	cm.Spec.AppFrameworkConfig.VolList[1].Type = "http"
+	cm.Spec.AppFrameworkConfig.VolList[1].Provider = "http"
+	secret.Data["http_username"] = []byte("admin")
+	c.Update(ctx, &secret)
+	_, err = GetRemoteStorageClient(ctx, c, &cm, &cm.Spec.AppFrameworkConfig, &cm.Spec.AppFrameworkConfig.VolList[1], "location", fn)
+	if err == nil || err.Error() != "http password or token is missing" {
+		t.Errorf("Expeceted error for missing http credentials")
+	}
+
+	secret.Data["http_password"] = []byte("password")
+	c.Update(ctx, &secret)
+	_, err = GetRemoteStorageClient(ctx, c, &cm, &cm.Spec.AppFrameworkConfig, &cm.Spec.AppFrameworkConfig.VolList[1], "location", fn)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestGetRemoteStorageBucketAndPrefix(t *testing.T) {
+	vol := &enterpriseApi.VolumeSpec{Name: "vol1", Path: "bucket1/apps", Provider: "aws"}
+	bucket, prefix := getRemoteStorageBucketAndPrefix(vol, "adminApps")
+	if bucket != "bucket1" || prefix != "apps/adminApps/" {
+		t.Errorf("Unexpected bucket: %s, prefix: %s", bucket, prefix)
+	}
+
+	// the first element of the path is the git ref, when the ref is not set
+	vol.Provider = "git"
+	vol.Path = "release/1.0/apps"
+	bucket, prefix = getRemoteStorageBucketAndPrefix(vol, "adminApps")
+	if bucket != "release" || prefix != "1.0/apps/adminApps/" {
+		t.Errorf("Unexpected bucket: %s, prefix: %s", bucket, prefix)
+	}
+
+	// the whole path is the directory in the repository, when the ref is set
+	vol.Ref = "release/1.0"
+	vol.Path = "apps"
+	bucket, prefix = getRemoteStorageBucketAndPrefix(vol, "adminApps")
+	if bucket != "release/1.0" || prefix != "apps/adminApps/" {
+		t.Errorf("Unexpected bucket: %s, prefix: %s", bucket, prefix)
+	}
+	vol.Path = ""
+	bucket, prefix = getRemoteStorageBucketAndPrefix(vol, "adminApps")
+	if bucket != "release/1.0" || prefix != "adminApps/" {
+		t.Errorf("Unexpected bucket: %s, prefix: %s", bucket, prefix)
+	}
 }
 
 func TestGetRemoteObjectKey(t *testing.T) {
@@ -1180,7 +1248,11 @@ func TestIsAppExtentionValid(t *testing.T) {
 		t.Errorf("failed to detect valid app extension")
 	}
 
-	if isAppExtentionValid("testapp.aspl") || isAppExtentionValid("testapp.ttgz") {
+	if !isAppExtentionValid("testapp.tar.gz") || !isAppExtentionValid("testapp.zip") {
+		t.Errorf("failed to detect valid app extension")
+	}
+
+	if isAppExtentionValid("testapp.aspl") || isAppExtentionValid("testapp.ttgz") || isAppExtentionValid("testapp.gz") {
 		t.Errorf("failed to detect invalid app extension")
 	}
 }
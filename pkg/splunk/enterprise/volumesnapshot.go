@@ -0,0 +1,561 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// The etc and var PVCs of a CR with a snapshotPolicy are snapshotted on a schedule with CSI VolumeSnapshots. The
+// snapshots taken together make a snapshot set, named <cr>-<time> and labeled with its name. Indexers are quiesced with
+// the maintenance mode of their cluster manager, and search head cluster members with detention, until every snapshot
+// of the set is cut. The snapshots are not owned by the CR, so that they outlive it: a CR created with
+// restoreFromSnapshotSet gets its PVCs pre-populated from the snapshots of the set before its statefulsets are created.
+
+const (
+	// volumeSnapshotAPIGroup is the API group of the CSI VolumeSnapshots
+	volumeSnapshotAPIGroup = "snapshot.storage.k8s.io"
+
+	// volumeSnapshotKind is the kind of the CSI VolumeSnapshots
+	volumeSnapshotKind = "VolumeSnapshot"
+
+	// volumeSnapshotSetLabel is the label with the name of the snapshot set of a VolumeSnapshot
+	volumeSnapshotSetLabel = "enterprise.splunk.com/snapshot-set"
+
+	// volumeSnapshotKindLabel is the label with the kind of the CR of a VolumeSnapshot
+	volumeSnapshotKindLabel = "enterprise.splunk.com/snapshot-kind"
+
+	// volumeSnapshotNameLabel is the label with the name of the CR of a VolumeSnapshot
+	volumeSnapshotNameLabel = "enterprise.splunk.com/snapshot-cr"
+
+	// volumeSnapshotComponentLabel is the label with the component of the PVC of a VolumeSnapshot, i.e. search-head
+	volumeSnapshotComponentLabel = "enterprise.splunk.com/snapshot-component"
+
+	// volumeSnapshotVolumeLabel is the label with the volume of the PVC of a VolumeSnapshot: etc or var
+	volumeSnapshotVolumeLabel = "enterprise.splunk.com/snapshot-volume"
+
+	// volumeSnapshotOrdinalLabel is the label with the ordinal of the pod of the PVC of a VolumeSnapshot
+	volumeSnapshotOrdinalLabel = "enterprise.splunk.com/snapshot-ordinal"
+
+	// volumeSnapshotSetTimeFormat is the format of the time in the name of a snapshot set
+	volumeSnapshotSetTimeFormat = "20060102150405"
+
+	// volumeSnapshotPollInterval is the interval to check if the snapshots of a set are cut
+	volumeSnapshotPollInterval = 10 * time.Second
+
+	// volumeSnapshotRetryInterval is the interval to retry a snapshot set which failed
+	volumeSnapshotRetryInterval = 5 * time.Minute
+
+	// volumeSnapshotTimeout is the time given to the CSI driver to cut the snapshots of a set
+	volumeSnapshotTimeout = 10 * time.Minute
+
+	// defaultVolumeSnapshotRetention is the number of snapshot sets kept when the snapshotPolicy doesn't set it
+	defaultVolumeSnapshotRetention = 7
+)
+
+// volumeSnapshotGVK is the group, version and kind of the CSI VolumeSnapshots
+var volumeSnapshotGVK = schema.GroupVersionKind{Group: volumeSnapshotAPIGroup, Version: "v1", Kind: volumeSnapshotKind}
+
+// volumeSnapshotSource is a PVC of a CR to snapshot
+type volumeSnapshotSource struct {
+	pvcName   string
+	component string
+	volume    string
+	ordinal   string
+}
+
+// getVolumeSnapshotPodExecClient returns the client to run the commands on the pod, used in mocking
+var getVolumeSnapshotPodExecClient = func(client splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+	return splutil.GetPodExecClient(client, cr, podName)
+}
+
+// QuiesceVolumeSnapshotCall used in mocking this function. Returns true if the Splunk instances of the CR were quiesced.
+var QuiesceVolumeSnapshotCall = func(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, quiesce bool) (bool, error) {
+	switch cr := cr.(type) {
+	case *enterpriseApi.IndexerCluster:
+		// leave the maintenance mode alone when it was enabled for something else
+		if quiesce && getVolumeSnapshotNotQuiescedReason(cr) != "" {
+			return false, nil
+		}
+		cmPodName, err := getVolumeSnapshotClusterManagerPodName(ctx, c, cr)
+		if err != nil {
+			return false, err
+		}
+		podExecClient := getVolumeSnapshotPodExecClient(c, cr, cmPodName)
+		err = SetClusterMaintenanceMode(ctx, c, cr, quiesce, cmPodName, podExecClient)
+		return err == nil, err
+	case *enterpriseApi.SearchHeadCluster:
+		mgr := newSearchHeadClusterPodManager(c, log.FromContext(ctx), cr, nil, splclient.NewSplunkClient)
+		for n := int32(0); n < cr.Spec.Replicas; n++ {
+			err := mgr.getClient(ctx, n).SetSearchHeadDetention(quiesce)
+			if err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// getVolumeSnapshotClusterManagerPodName returns the pod of the active cluster manager of an IndexerCluster
+func getVolumeSnapshotClusterManagerPodName(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster) (string, error) {
+	if len(cr.Spec.ClusterManagerRef.Name) > 0 {
+		cm := &enterpriseApi.ClusterManager{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.Spec.ClusterManagerRef.Name}, cm)
+		if err != nil {
+			return "", err
+		}
+		return getActiveClusterManagerPodName(cm), nil
+	}
+	return GetSplunkStatefulsetPodName(SplunkClusterMaster, cr.Spec.ClusterMasterRef.Name, 0), nil
+}
+
+// getVolumeSnapshotNotQuiescedReason returns why the Splunk instances of a CR can't be quiesced for a snapshot set,
+// empty if they can be, or are never quiesced
+func getVolumeSnapshotNotQuiescedReason(cr splcommon.MetaObject) string {
+	if cr, ok := cr.(*enterpriseApi.IndexerCluster); ok {
+		if len(cr.Spec.ClusterManagerRef.Name) == 0 && len(cr.Spec.ClusterMasterRef.Name) == 0 {
+			return "indexer cluster has no cluster manager"
+		}
+		if cr.Status.MaintenanceMode {
+			return "maintenance mode was already enabled on the cluster manager"
+		}
+	}
+	return ""
+}
+
+// validateSnapshotPolicy checks the snapshotPolicy of a CommonSplunkSpec
+func validateSnapshotPolicy(spec *enterpriseApi.CommonSplunkSpec) error {
+	if spec.SnapshotPolicy == nil {
+		return nil
+	}
+	if spec.SnapshotPolicy.Interval <= 0 {
+		return fmt.Errorf("snapshotPolicy intervalSeconds should be positive")
+	}
+	if spec.SnapshotPolicy.Retention < 0 {
+		return fmt.Errorf("negative value (%d) is not allowed for snapshotPolicy retention", spec.SnapshotPolicy.Retention)
+	}
+	if spec.EtcVolumeStorageConfig.EphemeralStorage && spec.VarVolumeStorageConfig.EphemeralStorage {
+		return fmt.Errorf("snapshotPolicy requires the etc or var volumes on persistent volume claims")
+	}
+	return nil
+}
+
+// getVolumeSnapshotRetention returns the number of snapshot sets to keep
+func getVolumeSnapshotRetention(policy *enterpriseApi.SnapshotPolicySpec) int {
+	if policy.Retention > 0 {
+		return int(policy.Retention)
+	}
+	return defaultVolumeSnapshotRetention
+}
+
+// getNextVolumeSnapshotTime returns when the next snapshot set is due, a failed one being retried after volumeSnapshotRetryInterval
+func getNextVolumeSnapshotTime(policy *enterpriseApi.SnapshotPolicySpec, status *enterpriseApi.VolumeSnapshotStatus) int64 {
+	next := status.LastSnapshotTime + policy.Interval
+	if status.Message != "" && status.StartTime > status.LastSnapshotTime {
+		retry := status.StartTime + int64(volumeSnapshotRetryInterval.Seconds())
+		if retry > next {
+			next = retry
+		}
+	}
+	return next
+}
+
+// getVolumeSnapshotLabels returns the labels of the VolumeSnapshots of a CR
+func getVolumeSnapshotLabels(cr splcommon.MetaObject) map[string]string {
+	return map[string]string{
+		volumeSnapshotKindLabel: cr.GetObjectKind().GroupVersionKind().Kind,
+		volumeSnapshotNameLabel: cr.GetName(),
+	}
+}
+
+// listVolumeSnapshots returns the VolumeSnapshots of a namespace with the given labels
+func listVolumeSnapshots(ctx context.Context, c splcommon.ControllerClient, namespace string, labels map[string]string) (*unstructured.UnstructuredList, error) {
+	snapshots := &unstructured.UnstructuredList{}
+	snapshots.SetGroupVersionKind(volumeSnapshotGVK.GroupVersion().WithKind(volumeSnapshotKind + "List"))
+	listOpts := []client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabels(labels),
+	}
+	err := c.List(ctx, snapshots, listOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// getVolumeSnapshotSources returns the etc and var PVCs of a CR
+func getVolumeSnapshotSources(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject) ([]volumeSnapshotSource, error) {
+	var sources []volumeSnapshotSource
+	for _, component := range getSplunkPvcComponents(cr.GetObjectKind().GroupVersionKind().Kind) {
+		statefulSetName := fmt.Sprintf("splunk-%s-%s", cr.GetName(), component)
+		listOpts := []client.ListOption{
+			client.InNamespace(cr.GetNamespace()),
+			client.MatchingLabels(map[string]string{"app.kubernetes.io/instance": statefulSetName}),
+		}
+		pvcList := corev1.PersistentVolumeClaimList{}
+		err := c.List(ctx, &pvcList, listOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		// the PVCs of a statefulset are named <template>-<statefulset>-<ordinal>
+		for _, pvc := range pvcList.Items {
+			for _, volume := range []string{splcommon.EtcVolumeStorage, splcommon.VarVolumeStorage} {
+				prefix := fmt.Sprintf("%s-%s-", fmt.Sprintf(splcommon.PvcNamePrefix, volume), statefulSetName)
+				if strings.HasPrefix(pvc.GetName(), prefix) {
+					sources = append(sources, volumeSnapshotSource{
+						pvcName:   pvc.GetName(),
+						component: component,
+						volume:    volume,
+						ordinal:   strings.TrimPrefix(pvc.GetName(), prefix),
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].pvcName < sources[j].pvcName })
+	return sources, nil
+}
+
+// getVolumeSnapshot returns the VolumeSnapshot of a PVC for a snapshot set
+func getVolumeSnapshot(cr splcommon.MetaObject, policy *enterpriseApi.SnapshotPolicySpec, setName string, source volumeSnapshotSource) *unstructured.Unstructured {
+	labels := getVolumeSnapshotLabels(cr)
+	labels[volumeSnapshotSetLabel] = setName
+	labels[volumeSnapshotComponentLabel] = source.component
+	labels[volumeSnapshotVolumeLabel] = source.volume
+	labels[volumeSnapshotOrdinalLabel] = source.ordinal
+
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": source.pvcName,
+		},
+	}
+	if policy.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = policy.VolumeSnapshotClassName
+	}
+
+	snapshot := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetName(fmt.Sprintf("%s-%s", setName, source.pvcName))
+	snapshot.SetNamespace(cr.GetNamespace())
+	snapshot.SetLabels(labels)
+	return snapshot
+}
+
+// deleteVolumeSnapshotSet deletes the VolumeSnapshots of a snapshot set of a CR
+func deleteVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, setName string) error {
+	labels := getVolumeSnapshotLabels(cr)
+	labels[volumeSnapshotSetLabel] = setName
+	snapshots, err := listVolumeSnapshots(ctx, c, cr.GetNamespace(), labels)
+	if err != nil {
+		return err
+	}
+	for i := range snapshots.Items {
+		err = c.Delete(ctx, &snapshots.Items[i])
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneVolumeSnapshotSets deletes the oldest snapshot sets of a CR beyond the retention of its snapshotPolicy
+func pruneVolumeSnapshotSets(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, policy *enterpriseApi.SnapshotPolicySpec) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("pruneVolumeSnapshotSets").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	snapshots, err := listVolumeSnapshots(ctx, c, cr.GetNamespace(), getVolumeSnapshotLabels(cr))
+	if err != nil {
+		return err
+	}
+
+	// the names of the sets of a CR sort by time
+	seen := make(map[string]bool)
+	var sets []string
+	for _, snapshot := range snapshots.Items {
+		setName := snapshot.GetLabels()[volumeSnapshotSetLabel]
+		if setName != "" && !seen[setName] {
+			seen[setName] = true
+			sets = append(sets, setName)
+		}
+	}
+	sort.Strings(sets)
+
+	retention := getVolumeSnapshotRetention(policy)
+	for n := 0; n < len(sets)-retention; n++ {
+		scopedLog.Info("Deleting snapshot set beyond retention", "snapshotSet", sets[n])
+		err = deleteVolumeSnapshotSet(ctx, c, cr, sets[n])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeAfterVolumeSnapshots resumes the Splunk instances of a CR quiesced for a snapshot set
+func resumeAfterVolumeSnapshots(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, status *enterpriseApi.VolumeSnapshotStatus) error {
+	if !status.Quiesced {
+		return nil
+	}
+	_, err := QuiesceVolumeSnapshotCall(ctx, c, cr, false)
+	if err != nil {
+		return err
+	}
+	status.Quiesced = false
+	return nil
+}
+
+// abortVolumeSnapshotSet resumes the Splunk instances of a CR and deletes the snapshots of a set which failed, so that
+// no incomplete set is left to restore from
+func abortVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, status *enterpriseApi.VolumeSnapshotStatus, cause error) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("abortVolumeSnapshotSet").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	setName := status.InProgress
+	status.InProgress = ""
+	err := resumeAfterVolumeSnapshots(ctx, c, cr, status)
+	if err != nil {
+		scopedLog.Error(err, "Unable to resume after the snapshot set", "snapshotSet", setName)
+	}
+	err = deleteVolumeSnapshotSet(ctx, c, cr, setName)
+	if err != nil {
+		scopedLog.Error(err, "Unable to delete the snapshots of the set", "snapshotSet", setName)
+	}
+	return cause
+}
+
+// startVolumeSnapshotSet quiesces the Splunk instances of a CR and creates the VolumeSnapshots of its PVCs
+func startVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec, status *enterpriseApi.VolumeSnapshotStatus, now int64) (time.Duration, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("startVolumeSnapshotSet").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	sources, err := getVolumeSnapshotSources(ctx, c, cr)
+	if err != nil {
+		return volumeSnapshotPollInterval, err
+	}
+	status.StartTime = now
+	if len(sources) == 0 {
+		return 0, fmt.Errorf("no persistent volume claim to snapshot")
+	}
+
+	setName := fmt.Sprintf("%s-%s", cr.GetName(), time.Unix(now, 0).UTC().Format(volumeSnapshotSetTimeFormat))
+	scopedLog.Info("Taking snapshot set", "snapshotSet", setName, "persistentVolumeClaims", len(sources))
+	status.InProgress = setName
+	status.Message = ""
+
+	// quiescing enables the maintenance mode of an indexer cluster, so the reason is looked at before
+	notQuiescedReason := getVolumeSnapshotNotQuiescedReason(cr)
+	quiesced, err := QuiesceVolumeSnapshotCall(ctx, c, cr, true)
+	status.Quiesced = quiesced
+	if err != nil {
+		return 0, abortVolumeSnapshotSet(ctx, c, cr, status, err)
+	}
+	status.NotQuiescedReason = ""
+	if !quiesced {
+		status.NotQuiescedReason = notQuiescedReason
+	}
+	if status.NotQuiescedReason != "" {
+		scopedLog.Info("Taking snapshot set without quiescing", "snapshotSet", setName, "reason", status.NotQuiescedReason)
+	}
+
+	for _, source := range sources {
+		err = c.Create(ctx, getVolumeSnapshot(cr, spec.SnapshotPolicy, setName, source))
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return 0, abortVolumeSnapshotSet(ctx, c, cr, status, err)
+		}
+	}
+	return volumeSnapshotPollInterval, nil
+}
+
+// checkVolumeSnapshotSet resumes the Splunk instances of a CR once every snapshot of the set in progress is cut
+func checkVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec, status *enterpriseApi.VolumeSnapshotStatus) (time.Duration, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("checkVolumeSnapshotSet").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	labels := getVolumeSnapshotLabels(cr)
+	labels[volumeSnapshotSetLabel] = status.InProgress
+	snapshots, err := listVolumeSnapshots(ctx, c, cr.GetNamespace(), labels)
+	if err != nil {
+		return volumeSnapshotPollInterval, err
+	}
+
+	// a snapshot is cut once the CSI driver reports its creation time, it may become ready to use later on
+	cut := len(snapshots.Items) > 0
+	for _, snapshot := range snapshots.Items {
+		message, _, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message")
+		if message != "" {
+			return 0, abortVolumeSnapshotSet(ctx, c, cr, status, fmt.Errorf("snapshot %s failed: %s", snapshot.GetName(), message))
+		}
+		creationTime, _, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime")
+		if creationTime == "" {
+			cut = false
+		}
+	}
+	if !cut {
+		if time.Now().Unix()-status.StartTime > int64(volumeSnapshotTimeout.Seconds()) {
+			return 0, abortVolumeSnapshotSet(ctx, c, cr, status, fmt.Errorf("snapshot set %s timed out", status.InProgress))
+		}
+		return volumeSnapshotPollInterval, nil
+	}
+
+	err = resumeAfterVolumeSnapshots(ctx, c, cr, status)
+	if err != nil {
+		return volumeSnapshotPollInterval, err
+	}
+
+	scopedLog.Info("Snapshot set taken", "snapshotSet", status.InProgress)
+	status.LastSnapshotSet = status.InProgress
+	status.LastSnapshotTime = status.StartTime
+	status.InProgress = ""
+	status.Message = ""
+
+	if spec.SnapshotPolicy == nil {
+		return 0, nil
+	}
+	err = pruneVolumeSnapshotSets(ctx, c, cr, spec.SnapshotPolicy)
+	if err != nil {
+		return volumeSnapshotRetryInterval, err
+	}
+	return time.Duration(getNextVolumeSnapshotTime(spec.SnapshotPolicy, status)-time.Now().Unix()) * time.Second, nil
+}
+
+// reconcileVolumeSnapshots takes the snapshot sets of a CR, returning when to check on them again
+func reconcileVolumeSnapshots(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec, status *enterpriseApi.VolumeSnapshotStatus, ready bool) (time.Duration, error) {
+	// a set in progress is followed through even if the snapshotPolicy was removed
+	if status.InProgress != "" {
+		return checkVolumeSnapshotSet(ctx, c, cr, spec, status)
+	}
+
+	// a failed set may have left the Splunk instances quiesced
+	err := resumeAfterVolumeSnapshots(ctx, c, cr, status)
+	if err != nil {
+		return volumeSnapshotPollInterval, err
+	}
+
+	policy := spec.SnapshotPolicy
+	if policy == nil || policy.Suspend || !ready {
+		return 0, nil
+	}
+	now := time.Now().Unix()
+	next := getNextVolumeSnapshotTime(policy, status)
+	if now < next {
+		return time.Duration(next-now) * time.Second, nil
+	}
+	return startVolumeSnapshotSet(ctx, c, cr, spec, status, now)
+}
+
+// applyVolumeSnapshotPolicy takes the snapshot sets of the PVCs of a CR on the schedule of its snapshotPolicy, and
+// updates the requeue result accordingly. A new set is only started once the CR is ready.
+func applyVolumeSnapshotPolicy(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec, status *enterpriseApi.VolumeSnapshotStatus, ready bool, result *reconcile.Result) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyVolumeSnapshotPolicy").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	requeueAfter, err := reconcileVolumeSnapshots(ctx, c, cr, spec, status, ready)
+	if err != nil {
+		scopedLog.Error(err, "Unable to take the snapshot set")
+		status.Message = err.Error()
+		if requeueAfter == 0 && spec.SnapshotPolicy != nil {
+			requeueAfter = volumeSnapshotRetryInterval
+		}
+	}
+	if requeueAfter > 0 && (!result.Requeue || (result.RequeueAfter > 0 && requeueAfter < result.RequeueAfter)) {
+		result.Requeue = true
+		result.RequeueAfter = requeueAfter
+	}
+}
+
+// restoreVolumeSnapshotSet pre-populates the PVCs of a new statefulset from the snapshots of a snapshot set. PVCs
+// which already exist are left alone.
+func restoreVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, setName string, instanceType InstanceType, statefulSet *appsv1.StatefulSet) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("restoreVolumeSnapshotSet").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	snapshots, err := listVolumeSnapshots(ctx, c, cr.GetNamespace(), map[string]string{volumeSnapshotSetLabel: setName})
+	if err != nil {
+		return err
+	}
+	if len(snapshots.Items) == 0 {
+		return fmt.Errorf("snapshot set %s not found", setName)
+	}
+
+	apiGroup := volumeSnapshotAPIGroup
+	for _, snapshot := range snapshots.Items {
+		labels := snapshot.GetLabels()
+		if labels[volumeSnapshotComponentLabel] != instanceType.ToString() {
+			continue
+		}
+		ordinal, err := strconv.Atoi(labels[volumeSnapshotOrdinalLabel])
+		if err != nil || ordinal >= int(*statefulSet.Spec.Replicas) {
+			continue
+		}
+
+		// the volume may be on ephemeral storage now
+		var template *corev1.PersistentVolumeClaim
+		for i := range statefulSet.Spec.VolumeClaimTemplates {
+			if statefulSet.Spec.VolumeClaimTemplates[i].GetName() == fmt.Sprintf(splcommon.PvcNamePrefix, labels[volumeSnapshotVolumeLabel]) {
+				template = &statefulSet.Spec.VolumeClaimTemplates[i]
+			}
+		}
+		if template == nil {
+			continue
+		}
+
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%s-%d", template.GetName(), statefulSet.GetName(), ordinal),
+				Namespace: statefulSet.GetNamespace(),
+				Labels:    template.GetLabels(),
+			},
+			Spec: *template.Spec.DeepCopy(),
+		}
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     volumeSnapshotKind,
+			Name:     snapshot.GetName(),
+		}
+		err = c.Create(ctx, pvc)
+		if k8serrors.IsAlreadyExists(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		scopedLog.Info("Restored persistent volume claim from snapshot", "persistentVolumeClaim", pvc.GetName(), "snapshot", snapshot.GetName())
+	}
+	return nil
+}
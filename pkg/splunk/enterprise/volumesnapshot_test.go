@@ -0,0 +1,406 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func getVolumeSnapshotTestSearchHeadCluster() *enterpriseApi.SearchHeadCluster {
+	return &enterpriseApi.SearchHeadCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "SearchHeadCluster",
+			APIVersion: "enterprise.splunk.com/v4",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterpriseApi.SearchHeadClusterSpec{
+			Replicas: 1,
+			CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{
+				SnapshotPolicy: &enterpriseApi.SnapshotPolicySpec{
+					Interval:                86400,
+					VolumeSnapshotClassName: "csi-snapclass",
+				},
+			},
+		},
+	}
+}
+
+func TestValidateSnapshotPolicy(t *testing.T) {
+	spec := &enterpriseApi.CommonSplunkSpec{}
+	if err := validateSnapshotPolicy(spec); err != nil {
+		t.Errorf("no snapshotPolicy should be valid. error: %v", err)
+	}
+
+	spec.SnapshotPolicy = &enterpriseApi.SnapshotPolicySpec{Interval: 3600}
+	if err := validateSnapshotPolicy(spec); err != nil {
+		t.Errorf("snapshotPolicy should be valid. error: %v", err)
+	}
+
+	spec.EtcVolumeStorageConfig.EphemeralStorage = true
+	spec.VarVolumeStorageConfig.EphemeralStorage = true
+	if err := validateSnapshotPolicy(spec); err == nil {
+		t.Errorf("snapshotPolicy should require persistent volume claims")
+	}
+
+	spec.VarVolumeStorageConfig.EphemeralStorage = false
+	spec.SnapshotPolicy.Interval = 0
+	if err := validateSnapshotPolicy(spec); err == nil {
+		t.Errorf("snapshotPolicy should require an interval")
+	}
+}
+
+func TestGetNextVolumeSnapshotTime(t *testing.T) {
+	policy := &enterpriseApi.SnapshotPolicySpec{Interval: 3600}
+	status := &enterpriseApi.VolumeSnapshotStatus{}
+	if next := getNextVolumeSnapshotTime(policy, status); next != 3600 {
+		t.Errorf("first snapshot set should be due. got: %d", next)
+	}
+
+	status.LastSnapshotTime = 10000
+	if next := getNextVolumeSnapshotTime(policy, status); next != 13600 {
+		t.Errorf("next snapshot set should be due after the interval. got: %d", next)
+	}
+
+	// a failed set is retried after the retry interval
+	status.StartTime = 14000
+	status.Message = "snapshot failed"
+	if next := getNextVolumeSnapshotTime(policy, status); next != 14300 {
+		t.Errorf("failed snapshot set should be retried. got: %d", next)
+	}
+
+	if retention := getVolumeSnapshotRetention(policy); retention != defaultVolumeSnapshotRetention {
+		t.Errorf("default retention should be used. got: %d", retention)
+	}
+}
+
+func TestQuiesceIndexerClusterForVolumeSnapshots(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	cr := &enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"},
+	}
+
+	// indexers without a cluster manager are not quiesced
+	if reason := getVolumeSnapshotNotQuiescedReason(cr); reason != "indexer cluster has no cluster manager" {
+		t.Errorf("getVolumeSnapshotNotQuiescedReason() = %s", reason)
+	}
+	quiesced, err := QuiesceVolumeSnapshotCall(ctx, c, cr, true)
+	if quiesced || err != nil {
+		t.Errorf("indexers without a cluster manager should not be quiesced. error: %v", err)
+	}
+
+	// the active cluster manager is used
+	cr.Spec.ClusterManagerRef.Name = "cm"
+	if _, err = getVolumeSnapshotClusterManagerPodName(ctx, c, cr); err == nil {
+		t.Errorf("missing cluster manager should be reported")
+	}
+	cm := &enterpriseApi.ClusterManager{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "test"}}
+	if err = c.Create(ctx, cm); err != nil {
+		t.Errorf("cluster manager should be created. error: %v", err)
+	}
+	cm.Status.ActiveManager = "splunk-cm-cluster-manager-1"
+	if err = c.Status().Update(ctx, cm); err != nil {
+		t.Errorf("cluster manager status should be updated. error: %v", err)
+	}
+	podName, err := getVolumeSnapshotClusterManagerPodName(ctx, c, cr)
+	if err != nil || podName != "splunk-cm-cluster-manager-1" {
+		t.Errorf("getVolumeSnapshotClusterManagerPodName() = %s; want splunk-cm-cluster-manager-1. error: %v", podName, err)
+	}
+	cr.Spec.ClusterManagerRef.Name = ""
+	cr.Spec.ClusterMasterRef.Name = "cmaster"
+	podName, _ = getVolumeSnapshotClusterManagerPodName(ctx, c, cr)
+	if podName != "splunk-cmaster-cluster-master-0" {
+		t.Errorf("getVolumeSnapshotClusterManagerPodName() = %s; want splunk-cmaster-cluster-master-0", podName)
+	}
+
+	// the maintenance mode enabled for something else is left alone
+	cr.Status.MaintenanceMode = true
+	quiesced, err = QuiesceVolumeSnapshotCall(ctx, c, cr, true)
+	if quiesced || err != nil || getVolumeSnapshotNotQuiescedReason(cr) == "" {
+		t.Errorf("indexers in maintenance mode should not be quiesced. error: %v", err)
+	}
+}
+
+func TestStartVolumeSnapshotSetForIndexerCluster(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	cr := &enterpriseApi.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "IndexerCluster",
+			APIVersion: "enterprise.splunk.com/v4",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"},
+	}
+	cr.Spec.ClusterMasterRef.Name = "cmaster"
+	cr.Spec.SnapshotPolicy = &enterpriseApi.SnapshotPolicySpec{Interval: 86400, VolumeSnapshotClassName: "csi-snapclass"}
+
+	// the admin password is read through the secret mounted on the cluster manager pod
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-cmaster-cluster-master-0", Namespace: "test"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{VolumeMounts: []corev1.VolumeMount{{MountPath: "/mnt/splunk-secrets", Name: "mnt-splunk-secrets"}}},
+			},
+			Volumes: []corev1.Volume{
+				{Name: "mnt-splunk-secrets", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "cmaster-secrets"}}},
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cmaster-secrets", Namespace: "test"},
+		Data:       map[string][]byte{"password": []byte("123")},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pvc-etc-splunk-idxc-indexer-0",
+			Namespace: "test",
+			Labels:    map[string]string{"app.kubernetes.io/instance": "splunk-idxc-indexer"},
+		},
+	}
+	for _, obj := range []client.Object{pod, secret, pvc} {
+		if err := c.Create(ctx, obj); err != nil {
+			t.Errorf("%s should be created. error: %v", obj.GetName(), err)
+		}
+	}
+
+	savedGetPodExecClient := getVolumeSnapshotPodExecClient
+	defer func() { getVolumeSnapshotPodExecClient = savedGetPodExecClient }()
+	podExecClient := &spltest.MockPodExecClient{}
+	podExecClient.AddMockPodExecReturnContext(ctx, "enable maintenance-mode", &spltest.MockPodExecReturnContext{})
+	getVolumeSnapshotPodExecClient = func(client splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+		return podExecClient
+	}
+
+	// quiescing enables the maintenance mode, which is not mistaken for the maintenance mode enabled for something else
+	status := &enterpriseApi.VolumeSnapshotStatus{}
+	_, err := startVolumeSnapshotSet(ctx, c, cr, &cr.Spec.CommonSplunkSpec, status, 10000)
+	if err != nil || !status.Quiesced || status.NotQuiescedReason != "" || !cr.Status.MaintenanceMode {
+		t.Errorf("snapshot set should be quiesced. error: %v, status: %v", err, status)
+	}
+	if !reflect.DeepEqual(podExecClient.GotCmdList, []string{"enable maintenance-mode"}) {
+		t.Errorf("maintenance mode should be enabled. got: %v", podExecClient.GotCmdList)
+	}
+
+	// the maintenance mode enabled for something else is reported
+	podExecClient.GotCmdList = nil
+	status = &enterpriseApi.VolumeSnapshotStatus{}
+	_, err = startVolumeSnapshotSet(ctx, c, cr, &cr.Spec.CommonSplunkSpec, status, 20000)
+	if err != nil || status.Quiesced || status.NotQuiescedReason != "maintenance mode was already enabled on the cluster manager" || len(podExecClient.GotCmdList) != 0 {
+		t.Errorf("snapshot set should not be quiesced. error: %v, status: %v", err, status)
+	}
+}
+
+func TestApplyVolumeSnapshotPolicy(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	cr := getVolumeSnapshotTestSearchHeadCluster()
+
+	for _, name := range []string{"pvc-etc-splunk-stack1-search-head-0", "pvc-var-splunk-stack1-search-head-0", "pvc-etc-splunk-stack1-deployer-0"} {
+		component := "search-head"
+		if name == "pvc-etc-splunk-stack1-deployer-0" {
+			component = "deployer"
+		}
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test",
+				Labels:    map[string]string{"app.kubernetes.io/instance": fmt.Sprintf("splunk-stack1-%s", component)},
+			},
+		}
+		if err := c.Create(ctx, pvc); err != nil {
+			t.Errorf("pvc should be created. error: %v", err)
+		}
+	}
+
+	// an older snapshot set beyond the retention
+	oldSnapshot := getVolumeSnapshot(cr, cr.Spec.SnapshotPolicy, "stack1-20200101000000", volumeSnapshotSource{pvcName: "pvc-etc-splunk-stack1-search-head-0", component: "search-head", volume: "etc", ordinal: "0"})
+	if err := c.Create(ctx, oldSnapshot); err != nil {
+		t.Errorf("snapshot should be created. error: %v", err)
+	}
+	cr.Spec.SnapshotPolicy.Retention = 1
+
+	savedCall := QuiesceVolumeSnapshotCall
+	defer func() { QuiesceVolumeSnapshotCall = savedCall }()
+	var calls []bool
+	QuiesceVolumeSnapshotCall = func(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, quiesce bool) (bool, error) {
+		calls = append(calls, quiesce)
+		return true, nil
+	}
+
+	listSet := func(setName string) []unstructured.Unstructured {
+		snapshots, err := listVolumeSnapshots(ctx, c, "test", map[string]string{volumeSnapshotSetLabel: setName})
+		if err != nil {
+			t.Errorf("snapshots should be listed. error: %v", err)
+			return nil
+		}
+		return snapshots.Items
+	}
+
+	// no snapshot set until the CR is ready
+	result := reconcile.Result{}
+	applyVolumeSnapshotPolicy(ctx, c, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, false, &result)
+	if cr.Status.VolumeSnapshots.InProgress != "" || len(calls) != 0 || result.Requeue {
+		t.Errorf("no snapshot set should be taken. status: %v", cr.Status.VolumeSnapshots)
+	}
+
+	// the snapshots are created once quiesced
+	applyVolumeSnapshotPolicy(ctx, c, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, true, &result)
+	setName := cr.Status.VolumeSnapshots.InProgress
+	if setName == "" || !cr.Status.VolumeSnapshots.Quiesced || cr.Status.VolumeSnapshots.NotQuiescedReason != "" || !reflect.DeepEqual(calls, []bool{true}) || result.RequeueAfter != volumeSnapshotPollInterval {
+		t.Errorf("snapshot set should be in progress. status: %v, result: %v", cr.Status.VolumeSnapshots, result)
+	}
+	snapshots := listSet(setName)
+	if len(snapshots) != 3 {
+		t.Errorf("snapshots should be created. got: %d", len(snapshots))
+	}
+	for _, snapshot := range snapshots {
+		pvcName, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+		className, _, _ := unstructured.NestedString(snapshot.Object, "spec", "volumeSnapshotClassName")
+		if snapshot.GetName() != fmt.Sprintf("%s-%s", setName, pvcName) || className != "csi-snapclass" {
+			t.Errorf("snapshot should refer to the pvc. got: %v", snapshot.Object)
+		}
+	}
+
+	// waits for the snapshots to be cut
+	applyVolumeSnapshotPolicy(ctx, c, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, true, &result)
+	if cr.Status.VolumeSnapshots.InProgress != setName || len(calls) != 1 {
+		t.Errorf("snapshot set should still be in progress. status: %v", cr.Status.VolumeSnapshots)
+	}
+
+	for i := range snapshots {
+		_ = unstructured.SetNestedField(snapshots[i].Object, "2022-10-15T00:00:00Z", "status", "creationTime")
+		if err := c.Update(ctx, &snapshots[i]); err != nil {
+			t.Errorf("snapshot should be updated. error: %v", err)
+		}
+	}
+	result = reconcile.Result{}
+	applyVolumeSnapshotPolicy(ctx, c, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, true, &result)
+	if cr.Status.VolumeSnapshots.InProgress != "" || cr.Status.VolumeSnapshots.LastSnapshotSet != setName || cr.Status.VolumeSnapshots.Quiesced || !reflect.DeepEqual(calls, []bool{true, false}) {
+		t.Errorf("snapshot set should be taken. status: %v", cr.Status.VolumeSnapshots)
+	}
+	if !result.Requeue || result.RequeueAfter < 86000*time.Second {
+		t.Errorf("next snapshot set should be scheduled. result: %v", result)
+	}
+	if len(listSet("stack1-20200101000000")) != 0 {
+		t.Errorf("older snapshot set should be deleted")
+	}
+
+	// a failed snapshot set is deleted
+	cr.Status.VolumeSnapshots.LastSnapshotTime = 0
+	calls = nil
+	applyVolumeSnapshotPolicy(ctx, c, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, true, &result)
+	setName = cr.Status.VolumeSnapshots.InProgress
+	snapshots = listSet(setName)
+	if len(snapshots) == 0 {
+		t.Errorf("snapshots should be created")
+		return
+	}
+	_ = unstructured.SetNestedField(snapshots[0].Object, "no space left", "status", "error", "message")
+	if err := c.Update(ctx, &snapshots[0]); err != nil {
+		t.Errorf("snapshot should be updated. error: %v", err)
+	}
+	applyVolumeSnapshotPolicy(ctx, c, cr, &cr.Spec.CommonSplunkSpec, &cr.Status.VolumeSnapshots, true, &result)
+	if cr.Status.VolumeSnapshots.InProgress != "" || cr.Status.VolumeSnapshots.Message == "" || cr.Status.VolumeSnapshots.Quiesced || !reflect.DeepEqual(calls, []bool{true, false}) {
+		t.Errorf("snapshot set should fail. status: %v", cr.Status.VolumeSnapshots)
+	}
+	if len(listSet(setName)) != 0 {
+		t.Errorf("failed snapshot set should be deleted")
+	}
+}
+
+func TestRestoreVolumeSnapshotSet(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	cr := getVolumeSnapshotTestSearchHeadCluster()
+
+	for _, source := range []volumeSnapshotSource{
+		{pvcName: "pvc-etc-splunk-stack1-search-head-0", component: "search-head", volume: "etc", ordinal: "0"},
+		{pvcName: "pvc-var-splunk-stack1-search-head-0", component: "search-head", volume: "var", ordinal: "0"},
+		{pvcName: "pvc-etc-splunk-stack1-search-head-1", component: "search-head", volume: "etc", ordinal: "1"},
+		{pvcName: "pvc-etc-splunk-stack1-deployer-0", component: "deployer", volume: "etc", ordinal: "0"},
+	} {
+		if err := c.Create(ctx, getVolumeSnapshot(cr, cr.Spec.SnapshotPolicy, "stack1-20221015000000", source)); err != nil {
+			t.Errorf("snapshot should be created. error: %v", err)
+		}
+	}
+
+	// restored into a new search head cluster with one member and its var volume on ephemeral storage
+	replicas := int32(1)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-restored-search-head", Namespace: "test"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{ObjectMeta: metav1.ObjectMeta{Name: "pvc-etc", Labels: map[string]string{"app.kubernetes.io/instance": "splunk-restored-search-head"}}},
+			},
+		},
+	}
+	restored := getVolumeSnapshotTestSearchHeadCluster()
+	restored.Name = "restored"
+
+	err := restoreVolumeSnapshotSet(ctx, c, restored, "stack1-20221015000000", SplunkSearchHead, statefulSet)
+	if err != nil {
+		t.Errorf("snapshot set should be restored. error: %v", err)
+	}
+	pvcList := corev1.PersistentVolumeClaimList{}
+	if err = c.List(ctx, &pvcList); err != nil || len(pvcList.Items) != 1 {
+		t.Errorf("one pvc should be created. got: %v, error: %v", pvcList.Items, err)
+		return
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "pvc-etc-splunk-restored-search-head-0"}, pvc)
+	if err != nil || pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Name != "stack1-20221015000000-pvc-etc-splunk-stack1-search-head-0" || pvc.Spec.DataSource.Kind != "VolumeSnapshot" {
+		t.Errorf("pvc should be pre-populated from the snapshot. got: %v, error: %v", pvc.Spec.DataSource, err)
+	}
+
+	// restoring again leaves the pvcs alone
+	err = restoreVolumeSnapshotSet(ctx, c, restored, "stack1-20221015000000", SplunkSearchHead, statefulSet)
+	if err != nil {
+		t.Errorf("snapshot set should be restored again. error: %v", err)
+	}
+
+	err = restoreVolumeSnapshotSet(ctx, c, restored, "stack1-20221016000000", SplunkSearchHead, statefulSet)
+	if err == nil {
+		t.Errorf("missing snapshot set should be reported")
+	}
+}
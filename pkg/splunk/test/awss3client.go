@@ -94,8 +94,11 @@ func (mockClient MockAWSS3ClientError) ListObjectsV2(options *s3.ListObjectsV2In
 // MockAWSDownloadClient is mock aws client for download
 type MockAWSDownloadClient struct{}
 
+// MockAWSDownloadSizes is the number of bytes written by the mock download of a remote file
+var MockAWSDownloadSizes = map[string]int64{}
+
 // Download is a mock call for aws sdk download api.
-// It just does some error checking.
+// It does some error checking, and writes as many bytes as set for the remote file in MockAWSDownloadSizes.
 func (mockDownloadClient MockAWSDownloadClient) Download(w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (size int64, err error) {
 	var bytes int64
 	remoteFile := *input.Key
@@ -107,5 +110,10 @@ func (mockDownloadClient MockAWSDownloadClient) Download(w io.WriterAt, input *s
 		return bytes, err
 	}
 
+	if size, ok := MockAWSDownloadSizes[remoteFile]; ok {
+		written, err := w.WriteAt(make([]byte, size), 0)
+		return int64(written), err
+	}
+
 	return bytes, nil
 }
@@ -130,6 +130,8 @@ func enterpriseObjListCopier(dst, src *client.ObjectList) bool {
 		*dstP.(*enterpriseApi.StandaloneList) = *srcP.(*enterpriseApi.StandaloneList)
 	case *enterpriseApi.MonitoringConsoleList:
 		*dstP.(*enterpriseApi.MonitoringConsoleList) = *srcP.(*enterpriseApi.MonitoringConsoleList)
+	case *enterpriseApi.AppDeploymentList:
+		*dstP.(*enterpriseApi.AppDeploymentList) = *srcP.(*enterpriseApi.AppDeploymentList)
 	default:
 		return false
 	}
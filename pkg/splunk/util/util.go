@@ -18,6 +18,7 @@ package util
 import (
 	"bytes"
 	"context"
+	"io"
 	"net/http"
 	"strings"
 
@@ -148,13 +149,44 @@ var podExecNewSPDYExecutor = remotecommand.NewSPDYExecutor
 
 // PodExecCommand execute a shell command in the specified pod
 func PodExecCommand(ctx context.Context, c splcommon.ControllerClient, podName string, namespace string, cmd []string, streamOptions *remotecommand.StreamOptions, tty bool, mock bool, mockKubPath string) (string, string, error) {
+	exec, err := newPodExecutor(ctx, c, podName, namespace, cmd, streamOptions.Stdin != nil, tty, mock, mockKubPath)
+	if err != nil {
+		return "", "", err
+	}
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	streamOptions.Stdout = stdout
+	streamOptions.Stderr = stderr
+
+	err = exec.Stream(*streamOptions)
+
+	return stdout.String(), stderr.String(), err
+}
+
+// PodExecStreamCommand execs a command on the pod, and writes its stdout to the given writer as it is received,
+// instead of keeping it in memory. Used for the large outputs, like the files copied from the pod
+func PodExecStreamCommand(ctx context.Context, c splcommon.ControllerClient, podName string, namespace string, cmd []string, stdout io.Writer) (string, error) {
+	exec, err := newPodExecutor(ctx, c, podName, namespace, cmd, false, false, false, "")
+	if err != nil {
+		return "", err
+	}
+	stderr := new(bytes.Buffer)
+
+	err = exec.Stream(remotecommand.StreamOptions{Stdout: stdout, Stderr: stderr})
+
+	return stderr.String(), err
+}
+
+// newPodExecutor returns the executor running the command on the splunk container of the pod
+func newPodExecutor(ctx context.Context, c splcommon.ControllerClient, podName string, namespace string, cmd []string, stdin bool, tty bool, mock bool, mockKubPath string) (remotecommand.Executor, error) {
 	var pod corev1.Pod
 
 	// Get Pod
 	namespacedName := types.NamespacedName{Namespace: namespace, Name: podName}
 	err := c.Get(ctx, namespacedName, &pod)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	gvk, _ := apiutil.GVKForObject(&pod, scheme.Scheme)
@@ -162,47 +194,32 @@ func PodExecCommand(ctx context.Context, c splcommon.ControllerClient, podName s
 	if !mock {
 		restConfig, err = podExecGetConfig()
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 	} else {
 		restConfig, err = clientcmd.BuildConfigFromFlags("", mockKubPath)
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 	}
 	restClient, err := podExecRESTClientForGVK(gvk, false, restConfig, serializer.NewCodecFactory(scheme.Scheme))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	execReq := restClient.Post().Resource("pods").Name(podName).Namespace(namespace).SubResource("exec")
 	option := &corev1.PodExecOptions{
 		Container: "splunk",
 		Command:   cmd,
-		Stdin:     true,
+		Stdin:     stdin,
 		Stdout:    true,
 		Stderr:    true,
 		TTY:       tty,
 	}
-	if streamOptions.Stdin == nil {
-		option.Stdin = false
-	}
 	execReq.VersionedParams(
 		option,
 		scheme.ParameterCodec,
 	)
-	exec, err := podExecNewSPDYExecutor(restConfig, http.MethodPost, execReq.URL())
-	if err != nil {
-		return "", "", err
-	}
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-
-	streamOptions.Stdout = stdout
-	streamOptions.Stderr = stderr
-
-	err = exec.Stream(*streamOptions)
-
-	return stdout.String(), stderr.String(), err
+	return podExecNewSPDYExecutor(restConfig, http.MethodPost, execReq.URL())
 }
 
 // PodExecClientImpl is an interface which is used to implement
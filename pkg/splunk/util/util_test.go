@@ -16,6 +16,7 @@
 package util
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"net/url"
@@ -394,3 +395,50 @@ func TestSuppressHarmlessErrorMessages(t *testing.T) {
 		t.Errorf("Known messages did not get suppressed.")
 	}
 }
+
+// streamingExecutor writes its output to the stdout of the stream
+type streamingExecutor struct {
+	out string
+}
+
+func (f *streamingExecutor) Stream(options remotecommand.StreamOptions) error {
+	_, err := options.Stdout.Write([]byte(f.out))
+	return err
+}
+
+func (f *streamingExecutor) StreamWithContext(ctx context.Context, options remotecommand.StreamOptions) error {
+	return f.Stream(options)
+}
+
+func TestPodExecStreamCommand(t *testing.T) {
+	ctx := context.TODO()
+	defer func() {
+		podExecGetConfig = config.GetConfig
+		podExecNewSPDYExecutor = remotecommand.NewSPDYExecutor
+	}()
+	podExecGetConfig = func() (*rest.Config, error) {
+		return &rest.Config{}, nil
+	}
+
+	c := spltest.NewMockClient()
+	stdout := new(bytes.Buffer)
+	_, err := PodExecStreamCommand(ctx, c, "splunk-stack1-0", "test", []string{"cat", "/tmp/file"}, stdout)
+	if err == nil {
+		t.Errorf("Expected error, when the pod doesn't exist")
+	}
+
+	c.AddObject(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-0", Namespace: "test"}})
+	podExecNewSPDYExecutor = fakePodExecNewSPDYExecutor
+	_, err = PodExecStreamCommand(ctx, c, "splunk-stack1-0", "test", []string{"cat", "/tmp/file"}, stdout)
+	if err == nil {
+		t.Errorf("Expected error")
+	}
+
+	podExecNewSPDYExecutor = func(config *rest.Config, method string, url *url.URL) (remotecommand.Executor, error) {
+		return &streamingExecutor{out: "file contents"}, nil
+	}
+	_, err = PodExecStreamCommand(ctx, c, "splunk-stack1-0", "test", []string{"cat", "/tmp/file"}, stdout)
+	if err != nil || stdout.String() != "file contents" {
+		t.Errorf("command output should be written to the writer. got: %s, error: %v", stdout.String(), err)
+	}
+}
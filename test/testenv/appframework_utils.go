@@ -14,6 +14,7 @@ import (
 
 	enterpriseApiV3 "github.com/splunk/splunk-operator/api/v3"
 	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -228,6 +229,32 @@ func GetAppframeworkManualUpdateConfigMap(ctx context.Context, deployment *Deplo
 	return ConfigMap, err
 }
 
+// getAppDeploymentInfoList returns the deployment info of the apps of an app source of a CR. The CR status has it only until
+// the operator moves it to the AppDeployments owned by the CR
+func getAppDeploymentInfoList(ctx context.Context, deployment *Deployment, kind string, name string, appSourceName string, appSrcDeployStatus map[string]enterpriseApi.AppSrcDeployInfo) []enterpriseApi.AppDeploymentInfo {
+	if appSrcDeployInfo, ok := appSrcDeployStatus[appSourceName]; ok {
+		return appSrcDeployInfo.AppDeploymentInfoList
+	}
+
+	appDeploymentList := &enterpriseApi.AppDeploymentList{}
+	err := deployment.testenv.GetKubeClient().List(ctx, appDeploymentList, client.InNamespace(deployment.testenv.namespace), client.MatchingLabels{
+		enterpriseApi.AppDeploymentCRKindLabel: kind,
+		enterpriseApi.AppDeploymentCRNameLabel: name,
+	})
+	if err != nil {
+		logf.Log.Error(err, "Failed to list the app deployments", "CR Kind", kind, "CR Name", name)
+		return nil
+	}
+
+	var appInfoList []enterpriseApi.AppDeploymentInfo
+	for _, appDeployment := range appDeploymentList.Items {
+		if appDeployment.Status.AppSourceName == appSourceName {
+			appInfoList = append(appInfoList, appDeployment.Status.AppDeploymentInfo)
+		}
+	}
+	return appInfoList
+}
+
 // GetAppDeploymentInfoStandalone returns AppDeploymentInfo for given standalone, appSourceName and appName
 func GetAppDeploymentInfoStandalone(ctx context.Context, deployment *Deployment, testenvInstance *TestCaseEnv, name string, appSourceName string, appName string) (enterpriseApi.AppDeploymentInfo, error) {
 	standalone := &enterpriseApi.Standalone{}
@@ -237,7 +264,7 @@ func GetAppDeploymentInfoStandalone(ctx context.Context, deployment *Deployment,
 		testenvInstance.Log.Error(err, "Failed to get CR ", "CR Name", name)
 		return appDeploymentInfo, err
 	}
-	appInfoList := standalone.Status.AppContext.AppsSrcDeployStatus[appSourceName].AppDeploymentInfoList
+	appInfoList := getAppDeploymentInfoList(ctx, deployment, "Standalone", name, appSourceName, standalone.Status.AppContext.AppsSrcDeployStatus)
 	for _, appInfo := range appInfoList {
 		testenvInstance.Log.Info("Checking Standalone AppInfo Struct", "App Name", appName, "App Source", appSourceName, "Standalone Name", name, "AppDeploymentInfo", appInfo)
 		if strings.Contains(appName, appInfo.AppName) {
@@ -259,7 +286,7 @@ func GetAppDeploymentInfoMonitoringConsole(ctx context.Context, deployment *Depl
 		testenvInstance.Log.Error(err, "Failed to get CR ", "CR Name", name)
 		return appDeploymentInfo, err
 	}
-	appInfoList := mc.Status.AppContext.AppsSrcDeployStatus[appSourceName].AppDeploymentInfoList
+	appInfoList := getAppDeploymentInfoList(ctx, deployment, "MonitoringConsole", name, appSourceName, mc.Status.AppContext.AppsSrcDeployStatus)
 	for _, appInfo := range appInfoList {
 		testenvInstance.Log.Info("Checking Monitoring Console AppInfo Struct", "App Name", appName, "App Source", appSourceName, "Monitoring Console Name", name, "AppDeploymentInfo", appInfo)
 		if strings.Contains(appName, appInfo.AppName) {
@@ -281,7 +308,7 @@ func GetAppDeploymentInfoClusterManager(ctx context.Context, deployment *Deploym
 		testenvInstance.Log.Error(err, "Failed to get CR ", "CR Name", name)
 		return appDeploymentInfo, err
 	}
-	appInfoList := cm.Status.AppContext.AppsSrcDeployStatus[appSourceName].AppDeploymentInfoList
+	appInfoList := getAppDeploymentInfoList(ctx, deployment, "ClusterManager", name, appSourceName, cm.Status.AppContext.AppsSrcDeployStatus)
 	for _, appInfo := range appInfoList {
 		testenvInstance.Log.Info("Checking Cluster Manager AppInfo Struct", "App Name", appName, "App Source", appSourceName, "Cluster Manager Name", name, "AppDeploymentInfo", appInfo)
 		if strings.Contains(appName, appInfo.AppName) {
@@ -303,7 +330,7 @@ func GetAppDeploymentInfoClusterMaster(ctx context.Context, deployment *Deployme
 		testenvInstance.Log.Error(err, "Failed to get CR ", "CR Name", name)
 		return appDeploymentInfo, err
 	}
-	appInfoList := cm.Status.AppContext.AppsSrcDeployStatus[appSourceName].AppDeploymentInfoList
+	appInfoList := getAppDeploymentInfoList(ctx, deployment, "ClusterMaster", name, appSourceName, cm.Status.AppContext.AppsSrcDeployStatus)
 	for _, appInfo := range appInfoList {
 		testenvInstance.Log.Info("Checking Cluster Master AppInfo Struct", "App Name", appName, "App Source", appSourceName, "Cluster Master Name", name, "AppDeploymentInfo", appInfo)
 		if strings.Contains(appName, appInfo.AppName) {
@@ -325,7 +352,7 @@ func GetAppDeploymentInfoSearchHeadCluster(ctx context.Context, deployment *Depl
 		testenvInstance.Log.Error(err, "Failed to get CR ", "CR Name", name)
 		return appDeploymentInfo, err
 	}
-	appInfoList := cm.Status.AppContext.AppsSrcDeployStatus[appSourceName].AppDeploymentInfoList
+	appInfoList := getAppDeploymentInfoList(ctx, deployment, "SearchHeadCluster", name, appSourceName, cm.Status.AppContext.AppsSrcDeployStatus)
 	for _, appInfo := range appInfoList {
 		testenvInstance.Log.Info("Checking Search Head Cluster AppInfo Struct", "App Name", appName, "App Source", appSourceName, "Search Head Name Name", name, "AppDeploymentInfo", appInfo)
 		if strings.Contains(appName, appInfo.AppName) {